@@ -0,0 +1,287 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dialout provides a southbound adapter for targets that only support
+// dial-out (target-initiated) telemetry. Instead of connecting to the target
+// the gateway waits for the target to connect to the DialOutListener and
+// publish gNMI SubscribeResponses, which are inserted into the cache under
+// the configured target name.
+//
+// Select the adapter for a target with the Protocol target meta field:
+//		Protocol: dialout
+// Targets publish with the gnmi_dialout.gNMIDialOut service:
+//		service gNMIDialOut {
+//			rpc Publish(stream gnmi.SubscribeResponse) returns (stream PublishResponse);
+//		}
+//		message PublishResponse {}
+// The stream must include "target" metadata with the configured target name.
+// If the target has credentials the stream must also include matching
+// "username" and "password" metadata, otherwise the target must present a
+// verified client certificate (see ServerListener.ClientCA) for the target
+// name. Only one stream per target is accepted at a time and streams are only
+// accepted by the gateway instance that holds the target's lock; other
+// instances return Unavailable so that the target retries another address.
+package dialout
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+// Protocol is the Protocol target meta value that selects this adapter.
+const Protocol = "dialout"
+
+// Stream metadata keys.
+const (
+	metadataTarget   = "target"
+	metadataUsername = "username"
+	metadataPassword = "password"
+)
+
+func init() {
+	connections.RegisterAdapter(Protocol, New)
+}
+
+// waiting contains the clients waiting for a target to connect, by target
+// name.
+var waiting = struct {
+	sync.Mutex
+	clients map[string]*Client
+}{clients: make(map[string]*Client)}
+
+// Client waits for a dial-out stream from a target and implements
+// client.Client.
+type Client struct {
+	mutex    sync.Mutex
+	query    *client.Query
+	attached bool
+	ended    chan error
+	closed   chan struct{}
+}
+
+// New creates a dial-out adapter client for the target.
+func New(_ *configuration.GatewayConfig, _ *targetpb.Target) (client.Client, error) {
+	return &Client{closed: make(chan struct{})}, nil
+}
+
+// Subscribe implements client.Client. Subscribe waits until ctx is canceled
+// or the target's dial-out stream ends.
+func (c *Client) Subscribe(ctx context.Context, q client.Query, _ ...string) error {
+	ended := make(chan error, 1)
+	c.mutex.Lock()
+	c.query = &q
+	c.ended = ended
+	c.mutex.Unlock()
+
+	waiting.Lock()
+	waiting.clients[q.Target] = c
+	waiting.Unlock()
+	defer func() {
+		waiting.Lock()
+		if waiting.clients[q.Target] == c {
+			delete(waiting.clients, q.Target)
+		}
+		waiting.Unlock()
+		c.mutex.Lock()
+		c.query = nil
+		c.mutex.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return errors.New("client closed")
+	case err := <-ended:
+		return err
+	}
+}
+
+// attach returns the query for a new stream if the stream is authenticated
+// and no other stream is attached.
+func (c *Client) attach(ctx context.Context, md metadata.MD) (*client.Query, chan<- error, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.query == nil {
+		return nil, nil, status.Error(codes.Unavailable, "target is not waiting for a dial-out stream")
+	}
+	if err := authenticate(ctx, md, c.query); err != nil {
+		return nil, nil, err
+	}
+	if c.attached {
+		return nil, nil, status.Errorf(codes.AlreadyExists, "target %s already has a dial-out stream", c.query.Target)
+	}
+	c.attached = true
+	return c.query, c.ended, nil
+}
+
+func (c *Client) detach() {
+	c.mutex.Lock()
+	c.attached = false
+	c.mutex.Unlock()
+}
+
+// authenticate checks the stream's credentials against the target's
+// credentials, or the client certificate if the target has no credentials.
+func authenticate(ctx context.Context, md metadata.MD, q *client.Query) error {
+	if q.Credentials != nil {
+		username := []byte(firstValue(md, metadataUsername))
+		password := []byte(firstValue(md, metadataPassword))
+		if subtle.ConstantTimeCompare(username, []byte(q.Credentials.Username)) != 1 ||
+			subtle.ConstantTimeCompare(password, []byte(q.Credentials.Password)) != 1 {
+			return status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return nil
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			for _, chain := range tlsInfo.State.VerifiedChains {
+				if len(chain) > 0 && (chain[0].Subject.CommonName == q.Target || chain[0].VerifyHostname(q.Target) == nil) {
+					return nil
+				}
+			}
+		}
+	}
+	return status.Errorf(codes.Unauthenticated, "target %s has no credentials and no verified client certificate was presented for it", q.Target)
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Poll implements client.Client. Polling is not supported for dial-out
+// targets.
+func (c *Client) Poll() error {
+	return errors.New("poll is not supported for dial-out targets")
+}
+
+// Close implements client.Client.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+// Impl implements client.Client. Dial-out clients don't have a gNMI client
+// implementation.
+func (c *Client) Impl() (client.Impl, error) {
+	return nil, errors.New("dial-out targets don't have a gNMI client implementation")
+}
+
+// publisher is the server API for the gNMIDialOut service.
+type publisher interface {
+	Publish(stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gnmi_dialout.gNMIDialOut",
+	HandlerType: (*publisher)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Publish",
+		Handler:       publishHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "gnmi_dialout.proto",
+}
+
+func publishHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(publisher).Publish(stream)
+}
+
+// Server accepts dial-out streams from targets.
+type Server struct{}
+
+// RegisterServer registers the gNMIDialOut service with the gRPC server.
+func RegisterServer(s *grpc.Server) {
+	s.RegisterService(&serviceDesc, new(Server))
+}
+
+// Publish receives SubscribeResponses from a target and passes them to the
+// target's connection.
+func (s *Server) Publish(stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	target := firstValue(md, metadataTarget)
+	if target == "" {
+		return status.Errorf(codes.InvalidArgument, "missing '%s' metadata", metadataTarget)
+	}
+
+	waiting.Lock()
+	c, exists := waiting.clients[target]
+	waiting.Unlock()
+	if !exists {
+		return status.Errorf(codes.Unavailable, "target %s is not waiting for a dial-out stream on this gateway", target)
+	}
+	q, ended, err := c.attach(stream.Context(), md)
+	if err != nil {
+		return err
+	}
+	defer c.detach()
+
+	for {
+		resp := new(gnmipb.SubscribeResponse)
+		if err := stream.RecvMsg(resp); err != nil {
+			if err == io.EOF {
+				end(ended, errors.New("target closed the dial-out stream"))
+				return nil
+			}
+			end(ended, err)
+			return err
+		}
+		if update := resp.GetUpdate(); update != nil {
+			if update.Prefix == nil {
+				update.Prefix = new(gnmipb.Path)
+			}
+			update.Prefix.Target = q.Target
+		}
+		if err := q.ProtoHandler(resp); err != nil {
+			end(ended, err)
+			return status.Errorf(codes.Internal, "unable to process notification: %v", err)
+		}
+	}
+}
+
+// end reports the end of a stream to Subscribe, unless Subscribe has already
+// returned.
+func end(ended chan<- error, err error) {
+	select {
+	case ended <- err:
+	default:
+	}
+}
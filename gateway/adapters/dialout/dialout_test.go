@@ -0,0 +1,149 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialout
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func startTestServer(t *testing.T) (*grpc.Server, *grpc.ClientConn) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	RegisterServer(srv)
+	go func() { _ = srv.Serve(lis) }()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, conn
+}
+
+func publish(conn *grpc.ClientConn, md metadata.MD, responses ...*gnmipb.SubscribeResponse) error {
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/gnmi_dialout.gNMIDialOut/Publish")
+	if err != nil {
+		return err
+	}
+	for _, resp := range responses {
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	// Wait for the server to finish the stream.
+	return stream.RecvMsg(new(gnmipb.SubscribeResponse))
+}
+
+func waitForTarget(target string) {
+	for i := 0; i < 100; i++ {
+		waiting.Lock()
+		_, exists := waiting.clients[target]
+		waiting.Unlock()
+		if exists {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_Publish(t *testing.T) {
+	assertion := assert.New(t)
+
+	srv, conn := startTestServer(t)
+	defer srv.Stop()
+	defer conn.Close()
+
+	c, err := New(nil, nil)
+	assertion.NoError(err)
+	received := make(chan *gnmipb.SubscribeResponse, 10)
+	subscribeErr := make(chan error, 1)
+	go func() {
+		subscribeErr <- c.Subscribe(context.Background(), client.Query{
+			Target:      "dev1",
+			Credentials: &client.Credentials{Username: "user", Password: "pass"},
+			ProtoHandler: func(msg proto.Message) error {
+				received <- msg.(*gnmipb.SubscribeResponse)
+				return nil
+			},
+		})
+	}()
+	waitForTarget("dev1")
+
+	err = publish(conn, metadata.Pairs(metadataTarget, "unknown"))
+	assertion.Equal(codes.Unavailable, status.Code(err))
+	err = publish(conn, metadata.Pairs(metadataTarget, "dev1", metadataUsername, "user", metadataPassword, "wrong"))
+	assertion.Equal(codes.Unauthenticated, status.Code(err))
+
+	update := &gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_Update{Update: &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "device-hostname"},
+		Update: []*gnmipb.Update{{Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "a"}}}}},
+	}}}
+	sync := &gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}}
+	_ = publish(conn, metadata.Pairs(metadataTarget, "dev1", metadataUsername, "user", metadataPassword, "pass"), update, sync)
+
+	select {
+	case resp := <-received:
+		assertion.Equal("dev1", resp.GetUpdate().GetPrefix().GetTarget())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the update")
+	}
+	select {
+	case resp := <-received:
+		assertion.True(resp.GetSyncResponse())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the sync response")
+	}
+	select {
+	case err := <-subscribeErr:
+		assertion.Error(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe didn't return after the stream ended")
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	c, err := New(nil, nil)
+	assert.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Subscribe(context.Background(), client.Query{Target: "dev2"})
+	}()
+	waitForTarget("dev2")
+	assert.NoError(t, c.Close())
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe didn't return after Close")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// endOfMessage is the NETCONF 1.0 message delimiter (RFC 6242 section 4.3).
+var endOfMessage = []byte("]]>]]>")
+
+// framer reads and writes NETCONF messages using end-of-message framing
+// (NETCONF 1.0) or chunked framing (NETCONF 1.1, RFC 6242 section 4.2).
+type framer struct {
+	r       *bufio.Reader
+	w       io.Writer
+	chunked bool
+}
+
+func newFramer(r io.Reader, w io.Writer) *framer {
+	return &framer{r: bufio.NewReaderSize(r, 64*1024), w: w}
+}
+
+// write sends a single message.
+func (f *framer) write(msg []byte) error {
+	var err error
+	if f.chunked {
+		_, err = fmt.Fprintf(f.w, "\n#%d\n%s\n##\n", len(msg), msg)
+	} else {
+		_, err = fmt.Fprintf(f.w, "%s%s", msg, endOfMessage)
+	}
+	return err
+}
+
+// read returns the next message.
+func (f *framer) read() ([]byte, error) {
+	if f.chunked {
+		return f.readChunked()
+	}
+	return f.readEndOfMessage()
+}
+
+func (f *framer) readEndOfMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, b)
+		if bytes.HasSuffix(msg, endOfMessage) {
+			return bytes.TrimSpace(msg[:len(msg)-len(endOfMessage)]), nil
+		}
+	}
+}
+
+func (f *framer) readChunked() ([]byte, error) {
+	var msg []byte
+	for {
+		size, err := f.readChunkHeader()
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return msg, nil
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(f.r, chunk); err != nil {
+			return nil, err
+		}
+		msg = append(msg, chunk...)
+	}
+}
+
+// readChunkHeader reads "\n#<size>\n" and returns size, or 0 for the
+// end-of-chunks marker "\n##\n".
+func (f *framer) readChunkHeader() (int, error) {
+	// Skip whitespace between messages.
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b == '#' {
+			break
+		}
+		if b != '\n' && b != '\r' && b != ' ' {
+			return 0, fmt.Errorf("invalid chunk header: unexpected %q", b)
+		}
+	}
+	line, err := f.r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = line[:len(line)-1]
+	if line == "#" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(line)
+	if err != nil || size <= 0 {
+		return 0, errors.New("invalid chunk size: " + line)
+	}
+	return size, nil
+}
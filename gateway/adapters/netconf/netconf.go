@@ -0,0 +1,423 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netconf provides a southbound adapter that subscribes to NETCONF
+// targets with YANG-push (RFC 8641) and converts the pushed updates into gNMI
+// Notifications that are inserted into the target cache.
+//
+// Select the adapter for a target with the Protocol target meta field:
+//		Protocol: netconf
+// The target address should be the NETCONF over SSH port (usually 830) and
+// the target must have credentials. Each subscription in the target's
+// SubscribeRequest is established as a separate YANG-push subscription with an
+// XPath filter built from the subscription path. ON_CHANGE and TARGET_DEFINED
+// subscriptions use on-change updates and SAMPLE subscriptions use periodic
+// updates at the sample interval. The adapter supports these additional
+// target meta fields:
+//		NetconfHostKey		- The target's SSH host key in authorized_keys format.
+//							  Host keys are not verified if this isn't set.
+//		NetconfDatastore	- The datastore to subscribe to (default "operational").
+//
+// XML has no type information so all values are inserted as strings. List
+// keys are taken from the OpenConfig models if OpenConfigDirectory is set.
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+// Protocol is the Protocol target meta value that selects this adapter.
+const Protocol = "netconf"
+
+// Target meta fields for NETCONF targets.
+const (
+	MetaHostKey   = "NetconfHostKey"
+	MetaDatastore = "NetconfDatastore"
+)
+
+const (
+	baseCapability10 = "urn:ietf:params:netconf:base:1.0"
+	baseCapability11 = "urn:ietf:params:netconf:base:1.1"
+	defaultDatastore = "operational"
+	// defaultPeriod is used for SAMPLE subscriptions without an interval.
+	defaultPeriod = 10 * time.Second
+)
+
+func init() {
+	connections.RegisterAdapter(Protocol, New)
+}
+
+// Client is a NETCONF YANG-push client that implements client.Client.
+type Client struct {
+	config    *configuration.GatewayConfig
+	converter *converter
+	datastore string
+	hostKey   ssh.PublicKey
+
+	mutex sync.Mutex
+	conn  *ssh.Client
+}
+
+// New creates a NETCONF adapter client for the target.
+func New(config *configuration.GatewayConfig, target *targetpb.Target) (client.Client, error) {
+	c := &Client{
+		config:    config,
+		converter: new(converter),
+		datastore: defaultDatastore,
+	}
+	if datastore, exists := target.GetMeta()[MetaDatastore]; exists {
+		c.datastore = datastore
+	}
+	if hostKey, exists := target.GetMeta()[MetaHostKey]; exists {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", MetaHostKey, err)
+		}
+		c.hostKey = key
+	}
+	if config.OpenConfigDirectory != "" {
+		schema, err := connections.LoadSchema(config.OpenConfigDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load OpenConfig models from %s: %v", config.OpenConfigDirectory, err)
+		}
+		c.converter.schema = schema
+	}
+	return c, nil
+}
+
+// Subscribe implements client.Client. Subscribe blocks until the NETCONF
+// session ends or ctx is canceled.
+func (c *Client) Subscribe(ctx context.Context, q client.Query, _ ...string) error {
+	subscriptions := q.SubReq.GetSubscribe().GetSubscription()
+	if len(subscriptions) == 0 {
+		return errors.New("NETCONF targets require a subscribe request with at least one subscription")
+	}
+	conn, err := c.dial(q)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.conn = conn
+	c.mutex.Unlock()
+	defer c.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return fmt.Errorf("unable to open SSH session: %v", err)
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("netconf"); err != nil {
+		return fmt.Errorf("unable to start the netconf subsystem: %v", err)
+	}
+
+	f := newFramer(stdout, stdin)
+	if err := hello(f); err != nil {
+		return err
+	}
+	prefix := q.SubReq.GetSubscribe().GetPrefix()
+	for i, subscription := range subscriptions {
+		if err := f.write(establishSubscription(i+1, c.datastore, xpathFilter(prefix, subscription.GetPath()), subscription)); err != nil {
+			return err
+		}
+	}
+
+	err = c.receive(f, q, len(subscriptions))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// dial connects to the first reachable target address.
+func (c *Client) dial(q client.Query) (*ssh.Client, error) {
+	if q.Credentials == nil {
+		return nil, errors.New("NETCONF targets require credentials")
+	}
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if c.hostKey != nil {
+		hostKeyCallback = ssh.FixedHostKey(c.hostKey)
+	}
+	password := q.Credentials.Password
+	sshConfig := &ssh.ClientConfig{
+		User: q.Credentials.Username,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         q.Timeout,
+	}
+
+	var errs []string
+	for _, address := range q.Addrs {
+		conn, err := ssh.Dial("tcp", address, sshConfig)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, fmt.Sprintf("dialing %s: %v", address, err))
+	}
+	return nil, errors.New(strings.Join(errs, "; "))
+}
+
+// hello exchanges hello messages and enables chunked framing if both sides
+// support NETCONF 1.1.
+func hello(f *framer) error {
+	msg := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities>` +
+		`<capability>` + baseCapability10 + `</capability>` +
+		`<capability>` + baseCapability11 + `</capability>` +
+		`</capabilities></hello>`
+	if err := f.write([]byte(msg)); err != nil {
+		return err
+	}
+	reply, err := f.read()
+	if err != nil {
+		return fmt.Errorf("unable to read hello: %v", err)
+	}
+	root, err := parseXML(reply)
+	if err != nil {
+		return fmt.Errorf("invalid hello: %v", err)
+	}
+	if root.name != "hello" {
+		return fmt.Errorf("expected hello, got %s", root.name)
+	}
+	if capabilities := root.child("capabilities"); capabilities != nil {
+		for _, capability := range capabilities.children {
+			if capability.text == baseCapability11 {
+				f.chunked = true
+			}
+		}
+	}
+	return nil
+}
+
+// establishSubscription returns an establish-subscription RPC for the XPath
+// filter.
+func establishSubscription(messageID int, datastore string, filter string, subscription *gnmipb.Subscription) []byte {
+	var trigger string
+	switch subscription.GetMode() {
+	case gnmipb.SubscriptionMode_SAMPLE:
+		period := time.Duration(subscription.GetSampleInterval())
+		if period <= 0 {
+			period = defaultPeriod
+		}
+		centiseconds := int64(period / (10 * time.Millisecond))
+		if centiseconds < 1 {
+			centiseconds = 1
+		}
+		trigger = fmt.Sprintf("<yp:periodic><yp:period>%d</yp:period></yp:periodic>", centiseconds)
+	default:
+		trigger = "<yp:on-change/>"
+	}
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(filter))
+	return []byte(fmt.Sprintf(`<rpc message-id="%d" xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">`+
+		`<establish-subscription xmlns="urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications" xmlns:yp="urn:ietf:params:xml:ns:yang:ietf-yang-push">`+
+		`<yp:datastore xmlns:ds="urn:ietf:params:xml:ns:yang:ietf-datastores">ds:%s</yp:datastore>`+
+		`<yp:datastore-xpath-filter>%s</yp:datastore-xpath-filter>%s`+
+		`</establish-subscription></rpc>`, messageID, datastore, escaped.String(), trigger))
+}
+
+// receive handles RPC replies and notifications until the session fails. A
+// sync response is sent once every subscription has been established and
+// has sent its first update.
+func (c *Client) receive(f *framer, q client.Query, subscriptions int) error {
+	var replies int
+	var synced bool
+	waiting := make(map[string]bool)
+	for {
+		msg, err := f.read()
+		if err != nil {
+			return err
+		}
+		root, err := parseXML(msg)
+		if err != nil {
+			return fmt.Errorf("invalid message: %v", err)
+		}
+
+		switch root.name {
+		case "rpc-reply":
+			if rpcErr := root.child("rpc-error"); rpcErr != nil {
+				return fmt.Errorf("establish-subscription failed: %s", rpcErrorMessage(rpcErr))
+			}
+			replies++
+			if id := root.child("id"); id != nil {
+				waiting[id.text] = true
+			}
+		case "notification":
+			id, notification, err := c.notification(root, q.Target)
+			if err != nil {
+				return err
+			}
+			if notification != nil {
+				err := q.ProtoHandler(&gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_Update{Update: notification}})
+				if err != nil {
+					return err
+				}
+			}
+			delete(waiting, id)
+		default:
+			continue
+		}
+
+		if !synced && replies == subscriptions && len(waiting) == 0 {
+			synced = true
+			if err := q.ProtoHandler(&gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// notification converts a YANG-push notification into a gNMI Notification.
+// The subscription ID is returned along with the Notification. A nil
+// Notification is returned for notifications that don't contain data.
+func (c *Client) notification(root *node, target string) (string, *gnmipb.Notification, error) {
+	timestamp := time.Now()
+	if eventTime := root.child("eventTime"); eventTime != nil {
+		if parsed, err := time.Parse(time.RFC3339Nano, eventTime.text); err == nil {
+			timestamp = parsed
+		}
+	}
+	notification := &gnmipb.Notification{
+		Timestamp: timestamp.UnixNano(),
+		Prefix:    &gnmipb.Path{Target: target},
+	}
+
+	if update := root.child("push-update"); update != nil {
+		if contents := update.child("datastore-contents"); contents != nil {
+			notification.Update = c.converter.updates(nil, contents)
+		}
+		return childText(update, "id"), notification, nil
+	}
+
+	if change := root.child("push-change-update"); change != nil {
+		patch := change.child("datastore-changes")
+		if patch != nil {
+			patch = patch.child("yang-patch")
+		}
+		if patch == nil {
+			return childText(change, "id"), nil, nil
+		}
+		for _, edit := range patch.children {
+			if edit.name != "edit" {
+				continue
+			}
+			elems, err := parseInstanceIdentifier(childText(edit, "target"))
+			if err != nil {
+				return "", nil, err
+			}
+			switch childText(edit, "operation") {
+			case "delete", "remove":
+				notification.Delete = append(notification.Delete, &gnmipb.Path{Elem: elems})
+			default:
+				value := edit.child("value")
+				if value == nil || len(elems) == 0 {
+					continue
+				}
+				for _, child := range value.children {
+					notification.Update = append(notification.Update, c.converter.walk(elems[:len(elems)-1], child)...)
+				}
+			}
+		}
+		return childText(change, "id"), notification, nil
+	}
+
+	for _, name := range []string{"subscription-terminated", "subscription-suspended"} {
+		if event := root.child(name); event != nil {
+			return "", nil, fmt.Errorf("subscription %s %s: %s", childText(event, "id"), strings.TrimPrefix(name, "subscription-"), childText(event, "reason"))
+		}
+	}
+	return "", nil, nil
+}
+
+// rpcErrorMessage returns the most descriptive text in an rpc-error.
+func rpcErrorMessage(rpcErr *node) string {
+	for _, name := range []string{"error-message", "error-app-tag", "error-tag"} {
+		if text := childText(rpcErr, name); text != "" {
+			return text
+		}
+	}
+	return "unknown error"
+}
+
+func childText(n *node, name string) string {
+	if child := n.child(name); child != nil {
+		return child.text
+	}
+	return ""
+}
+
+// Poll implements client.Client. Polling is not supported for NETCONF
+// targets.
+func (c *Client) Poll() error {
+	return errors.New("poll is not supported for NETCONF targets")
+}
+
+// Close implements client.Client.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mutex.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// Impl implements client.Client. NETCONF clients don't have a gNMI client
+// implementation.
+func (c *Client) Impl() (client.Impl, error) {
+	return nil, errors.New("NETCONF targets don't have a gNMI client implementation")
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netconf
+
+import (
+	"bytes"
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFramer_EndOfMessage(t *testing.T) {
+	assertion := assert.New(t)
+
+	var buf bytes.Buffer
+	f := newFramer(&buf, &buf)
+	assertion.NoError(f.write([]byte("<hello/>")))
+	assertion.NoError(f.write([]byte("<rpc/>")))
+
+	msg, err := f.read()
+	assertion.NoError(err)
+	assertion.Equal("<hello/>", string(msg))
+	msg, err = f.read()
+	assertion.NoError(err)
+	assertion.Equal("<rpc/>", string(msg))
+}
+
+func TestFramer_Chunked(t *testing.T) {
+	assertion := assert.New(t)
+
+	f := newFramer(bytes.NewBufferString("\n#4\n<rpc\n#8\n-reply/>\n##\n"), nil)
+	f.chunked = true
+	msg, err := f.read()
+	assertion.NoError(err)
+	assertion.Equal("<rpc-reply/>", string(msg))
+
+	var buf bytes.Buffer
+	f = newFramer(&buf, &buf)
+	f.chunked = true
+	assertion.NoError(f.write([]byte("<rpc/>")))
+	msg, err = f.read()
+	assertion.NoError(err)
+	assertion.Equal("<rpc/>", string(msg))
+}
+
+func TestParseInstanceIdentifier(t *testing.T) {
+	assertion := assert.New(t)
+
+	elems, err := parseInstanceIdentifier(`/if:interfaces/if:interface[if:name='eth/0']/if:state`)
+	assertion.NoError(err)
+	assertion.Equal([]*gnmipb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "eth/0"}},
+		{Name: "state"},
+	}, elems)
+
+	_, err = parseInstanceIdentifier(`/interfaces/interface[name='eth0`)
+	assertion.Error(err)
+}
+
+func TestXPathFilter(t *testing.T) {
+	path := &gnmipb.Path{Elem: []*gnmipb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "*"}},
+		{Name: "subinterfaces"},
+		{Name: "subinterface", Key: map[string]string{"index": "0"}},
+	}}
+	assert.Equal(t, "/interfaces/interface/subinterfaces/subinterface[index='0']", xpathFilter(nil, path))
+}
+
+func TestClient_Notification_PushUpdate(t *testing.T) {
+	assertion := assert.New(t)
+
+	root, err := parseXML([]byte(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2020-10-01T00:00:00Z</eventTime>
+  <push-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push">
+    <id>7</id>
+    <datastore-contents>
+      <interfaces xmlns="http://openconfig.net/yang/interfaces">
+        <interface>
+          <name>eth0</name>
+          <state><oper-status>UP</oper-status></state>
+        </interface>
+      </interfaces>
+    </datastore-contents>
+  </push-update>
+</notification>`))
+	assertion.NoError(err)
+
+	c := &Client{converter: new(converter)}
+	id, n, err := c.notification(root, "dev1")
+	assertion.NoError(err)
+	assertion.Equal("7", id)
+	assertion.Equal("dev1", n.GetPrefix().GetTarget())
+	assertion.Equal(int64(1601510400000000000), n.GetTimestamp())
+
+	values := make(map[string]string)
+	for _, u := range n.GetUpdate() {
+		values[pathString(u.GetPath())] = u.GetVal().GetStringVal()
+	}
+	assertion.Equal(map[string]string{
+		"/interfaces/interface[name=eth0]/name":              "eth0",
+		"/interfaces/interface[name=eth0]/state/oper-status": "UP",
+	}, values)
+}
+
+func TestClient_Notification_PushChangeUpdate(t *testing.T) {
+	assertion := assert.New(t)
+
+	root, err := parseXML([]byte(`<notification>
+  <eventTime>2020-10-01T00:00:00Z</eventTime>
+  <push-change-update>
+    <id>7</id>
+    <datastore-changes>
+      <yang-patch>
+        <patch-id>0</patch-id>
+        <edit>
+          <edit-id>1</edit-id>
+          <operation>replace</operation>
+          <target>/if:interfaces/if:interface[if:name='eth0']/if:state/if:oper-status</target>
+          <value><oper-status>DOWN</oper-status></value>
+        </edit>
+        <edit>
+          <edit-id>2</edit-id>
+          <operation>delete</operation>
+          <target>/if:interfaces/if:interface[if:name='eth1']</target>
+        </edit>
+      </yang-patch>
+    </datastore-changes>
+  </push-change-update>
+</notification>`))
+	assertion.NoError(err)
+
+	c := &Client{converter: new(converter)}
+	id, n, err := c.notification(root, "dev1")
+	assertion.NoError(err)
+	assertion.Equal("7", id)
+	if assertion.Len(n.GetUpdate(), 1) {
+		assertion.Equal("/interfaces/interface[name=eth0]/state/oper-status", pathString(n.GetUpdate()[0].GetPath()))
+		assertion.Equal("DOWN", n.GetUpdate()[0].GetVal().GetStringVal())
+	}
+	if assertion.Len(n.GetDelete(), 1) {
+		assertion.Equal("/interfaces/interface[name=eth1]", pathString(n.GetDelete()[0]))
+	}
+}
+
+func TestClient_Notification_Terminated(t *testing.T) {
+	root, err := parseXML([]byte(`<notification><subscription-terminated><id>7</id><reason>no-such-subscription</reason></subscription-terminated></notification>`))
+	assert.NoError(t, err)
+	_, _, err = new(Client).notification(root, "dev1")
+	assert.Error(t, err)
+}
+
+func pathString(p *gnmipb.Path) string {
+	var s string
+	for _, elem := range p.GetElem() {
+		s += "/" + elem.GetName()
+		for name, value := range elem.GetKey() {
+			s += "[" + name + "=" + value + "]"
+		}
+	}
+	return s
+}
@@ -0,0 +1,246 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/openconfig"
+)
+
+// defaultListKeys are the key leaves used for lists that aren't in the
+// OpenConfig schema, in order of preference.
+var defaultListKeys = []string{"name", "index", "id"}
+
+// node is an element in a decoded XML document. Namespaces are ignored.
+type node struct {
+	name     string
+	text     string
+	children []*node
+}
+
+// child returns the first child element with the given name.
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// parseXML decodes an XML document into a tree of nodes.
+func parseXML(data []byte) (*node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	root := &node{}
+	stack := []*node{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local}
+			current.children = append(current.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			current.text = strings.TrimSpace(current.text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			current.text += string(t)
+		}
+	}
+	if len(root.children) != 1 {
+		return nil, fmt.Errorf("expected a single root element, got %d", len(root.children))
+	}
+	return root.children[0], nil
+}
+
+// converter converts XML data trees into gNMI updates.
+type converter struct {
+	// schema is used to find list keys. schema may be nil.
+	schema *openconfig.TypeLookup
+}
+
+// updates returns an update for each leaf below the children of n. elems is
+// the path of n.
+func (c *converter) updates(elems []*gnmipb.PathElem, n *node) []*gnmipb.Update {
+	var updates []*gnmipb.Update
+	for _, child := range n.children {
+		updates = append(updates, c.walk(elems, child)...)
+	}
+	return updates
+}
+
+func (c *converter) walk(parent []*gnmipb.PathElem, n *node) []*gnmipb.Update {
+	elem := &gnmipb.PathElem{Name: n.name}
+	elems := append(append([]*gnmipb.PathElem{}, parent...), elem)
+	if len(n.children) == 0 {
+		return []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: elems},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: n.text}},
+		}}
+	}
+	elem.Key = c.listKeys(elems, n)
+	return c.updates(elems, n)
+}
+
+// listKeys returns the keys of n if n is a list entry. Without a schema an
+// element is considered to be a list entry if it contains both a
+// defaultListKeys leaf and a container, which matches OpenConfig lists.
+func (c *converter) listKeys(elems []*gnmipb.PathElem, n *node) map[string]string {
+	var names []string
+	if c.schema != nil {
+		path := make([]string, len(elems))
+		for i, elem := range elems {
+			path[i] = elem.Name
+		}
+		if entry := c.schema.GetEntryByPath(path); entry != nil {
+			if !entry.IsList() {
+				return nil
+			}
+			names = strings.Fields(entry.Key)
+		}
+	}
+	if names == nil {
+		var hasContainer bool
+		for _, child := range n.children {
+			if len(child.children) > 0 {
+				hasContainer = true
+				break
+			}
+		}
+		if !hasContainer {
+			return nil
+		}
+		for _, name := range defaultListKeys {
+			if key := n.child(name); key != nil && len(key.children) == 0 {
+				names = []string{name}
+				break
+			}
+		}
+	}
+
+	keys := make(map[string]string, len(names))
+	for _, name := range names {
+		if key := n.child(name); key != nil {
+			keys[name] = key.text
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return keys
+}
+
+// parseInstanceIdentifier converts a YANG instance-identifier (e.g.
+// "/if:interfaces/if:interface[if:name='eth0']") into gNMI path elements.
+// Module prefixes are removed.
+func parseInstanceIdentifier(s string) ([]*gnmipb.PathElem, error) {
+	var elems []*gnmipb.PathElem
+	for len(s) > 0 {
+		if s[0] != '/' {
+			return nil, fmt.Errorf("invalid instance-identifier: expected '/' at %q", s)
+		}
+		s = s[1:]
+		end := strings.IndexAny(s, "/[")
+		if end < 0 {
+			end = len(s)
+		}
+		elem := &gnmipb.PathElem{Name: stripPrefix(s[:end])}
+		s = s[end:]
+		for len(s) > 0 && s[0] == '[' {
+			eq := strings.IndexByte(s, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("invalid instance-identifier key at %q", s)
+			}
+			name := stripPrefix(strings.TrimSpace(s[1:eq]))
+			rest := strings.TrimSpace(s[eq+1:])
+			if len(rest) == 0 {
+				return nil, fmt.Errorf("invalid instance-identifier key at %q", s)
+			}
+			var value string
+			if quote := rest[0]; quote == '\'' || quote == '"' {
+				closing := strings.IndexByte(rest[1:], quote)
+				if closing < 0 {
+					return nil, fmt.Errorf("unterminated key value at %q", s)
+				}
+				value = rest[1 : closing+1]
+				rest = strings.TrimSpace(rest[closing+2:])
+			} else {
+				closing := strings.IndexByte(rest, ']')
+				if closing < 0 {
+					return nil, fmt.Errorf("unterminated key at %q", s)
+				}
+				value = rest[:closing]
+				rest = rest[closing:]
+			}
+			if len(rest) == 0 || rest[0] != ']' {
+				return nil, fmt.Errorf("unterminated key at %q", s)
+			}
+			if elem.Key == nil {
+				elem.Key = make(map[string]string)
+			}
+			elem.Key[name] = value
+			s = rest[1:]
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
+}
+
+func stripPrefix(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// xpathFilter converts a gNMI path into an XPath filter for an
+// establish-subscription request.
+func xpathFilter(prefix *gnmipb.Path, p *gnmipb.Path) string {
+	var b strings.Builder
+	for _, elem := range append(append([]*gnmipb.PathElem{}, prefix.GetElem()...), p.GetElem()...) {
+		b.WriteString("/")
+		b.WriteString(elem.GetName())
+		names := make([]string, 0, len(elem.GetKey()))
+		for name := range elem.GetKey() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value := elem.GetKey()[name]; value != "*" {
+				fmt.Fprintf(&b, "[%s='%s']", name, value)
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
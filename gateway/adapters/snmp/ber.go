@@ -0,0 +1,329 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags used by SNMPv2c.
+const (
+	tagInteger        = 0x02
+	tagOctetString    = 0x04
+	tagNull           = 0x05
+	tagOID            = 0x06
+	tagSequence       = 0x30
+	tagIPAddress      = 0x40
+	tagCounter32      = 0x41
+	tagGauge32        = 0x42
+	tagTimeTicks      = 0x43
+	tagOpaque         = 0x44
+	tagCounter64      = 0x46
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+)
+
+// SNMPv2c PDU types.
+const (
+	pduGet      = 0xa0
+	pduGetNext  = 0xa1
+	pduResponse = 0xa2
+	pduGetBulk  = 0xa5
+)
+
+// snmpVersion2c is the version field value for SNMPv2c messages.
+const snmpVersion2c = 1
+
+// oid is an SNMP object identifier.
+type oid []uint64
+
+// parseOID parses a dotted OID (e.g. "1.3.6.1.2.1.1.3.0"). A leading dot is
+// allowed.
+func parseOID(s string) (oid, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID '%s': an OID must have at least two components", s)
+	}
+	o := make(oid, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID '%s': %v", s, err)
+		}
+		o[i] = v
+	}
+	return o, nil
+}
+
+func (o oid) String() string {
+	parts := make([]string, len(o))
+	for i, v := range o {
+		parts[i] = strconv.FormatUint(v, 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// hasPrefix returns true if o is equal to or below prefix.
+func (o oid) hasPrefix(prefix oid) bool {
+	if len(o) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if o[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// variable is a decoded variable binding.
+type variable struct {
+	oid   oid
+	tag   byte
+	value []byte
+}
+
+// encodeRequest encodes an SNMPv2c request message for the OIDs. For GetBulk
+// requests a and b are the non-repeaters and max-repetitions fields,
+// otherwise they are the error-status and error-index fields.
+func encodeRequest(community string, pduType byte, requestID int32, a int, b int, oids []oid) []byte {
+	variables := make([]variable, len(oids))
+	for i, o := range oids {
+		variables[i] = variable{oid: o, tag: tagNull}
+	}
+	return encodeMessage(community, pduType, requestID, a, b, variables)
+}
+
+// encodeMessage encodes an SNMPv2c message.
+func encodeMessage(community string, pduType byte, requestID int32, a int, b int, variables []variable) []byte {
+	var varbinds []byte
+	for _, v := range variables {
+		varbinds = append(varbinds, encodeTLV(tagSequence, append(encodeTLV(tagOID, encodeOID(v.oid)), encodeTLV(v.tag, v.value)...))...)
+	}
+	var pdu []byte
+	pdu = append(pdu, encodeInteger(int64(requestID))...)
+	pdu = append(pdu, encodeInteger(int64(a))...)
+	pdu = append(pdu, encodeInteger(int64(b))...)
+	pdu = append(pdu, encodeTLV(tagSequence, varbinds)...)
+
+	var msg []byte
+	msg = append(msg, encodeInteger(snmpVersion2c)...)
+	msg = append(msg, encodeTLV(tagOctetString, []byte(community))...)
+	msg = append(msg, encodeTLV(pduType, pdu)...)
+	return encodeTLV(tagSequence, msg)
+}
+
+// pdu is a decoded SNMPv2c PDU.
+type pdu struct {
+	pduType     byte
+	requestID   int32
+	errorStatus int
+	errorIndex  int
+	variables   []variable
+}
+
+// decodeMessage decodes an SNMPv2c message.
+func decodeMessage(data []byte) (*pdu, error) {
+	msg, _, err := decodeTLV(data)
+	if err != nil {
+		return nil, err
+	}
+	if msg.tag != tagSequence {
+		return nil, fmt.Errorf("expected a message sequence, got tag 0x%02x", msg.tag)
+	}
+	fields, err := decodeAll(msg.value)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 3 || fields[0].tag != tagInteger || fields[1].tag != tagOctetString {
+		return nil, errors.New("invalid SNMP message")
+	}
+	if version := decodeInteger(fields[0].value); version != snmpVersion2c {
+		return nil, fmt.Errorf("unsupported SNMP version %d", version)
+	}
+
+	pduFields, err := decodeAll(fields[2].value)
+	if err != nil {
+		return nil, err
+	}
+	if len(pduFields) != 4 || pduFields[0].tag != tagInteger || pduFields[1].tag != tagInteger || pduFields[2].tag != tagInteger || pduFields[3].tag != tagSequence {
+		return nil, errors.New("invalid PDU")
+	}
+	r := &pdu{
+		pduType:     fields[2].tag,
+		requestID:   int32(decodeInteger(pduFields[0].value)),
+		errorStatus: int(decodeInteger(pduFields[1].value)),
+		errorIndex:  int(decodeInteger(pduFields[2].value)),
+	}
+	varbinds, err := decodeAll(pduFields[3].value)
+	if err != nil {
+		return nil, err
+	}
+	for _, varbind := range varbinds {
+		pair, err := decodeAll(varbind.value)
+		if err != nil {
+			return nil, err
+		}
+		if varbind.tag != tagSequence || len(pair) != 2 || pair[0].tag != tagOID {
+			return nil, errors.New("invalid variable binding")
+		}
+		o, err := decodeOID(pair[0].value)
+		if err != nil {
+			return nil, err
+		}
+		r.variables = append(r.variables, variable{oid: o, tag: pair[1].tag, value: pair[1].value})
+	}
+	return r, nil
+}
+
+type tlv struct {
+	tag   byte
+	value []byte
+}
+
+func encodeTLV(tag byte, value []byte) []byte {
+	out := append([]byte{tag}, encodeLength(len(value))...)
+	return append(out, value...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func encodeInteger(v int64) []byte {
+	b := []byte{byte(v)}
+	for v > 127 || v < -128 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return encodeTLV(tagInteger, b)
+}
+
+func encodeOID(o oid) []byte {
+	if len(o) < 2 {
+		return nil
+	}
+	b := base128(o[0]*40 + o[1])
+	for _, v := range o[2:] {
+		b = append(b, base128(v)...)
+	}
+	return b
+}
+
+func base128(v uint64) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// decodeTLV decodes the first TLV in data and returns the remaining bytes.
+func decodeTLV(data []byte) (tlv, []byte, error) {
+	if len(data) < 2 {
+		return tlv{}, nil, errors.New("truncated BER data")
+	}
+	tag := data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(data) < offset+n {
+			return tlv{}, nil, errors.New("invalid BER length")
+		}
+		length = 0
+		for _, b := range data[offset : offset+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if length < 0 || len(data)-offset < length {
+		return tlv{}, nil, errors.New("truncated BER data")
+	}
+	return tlv{tag: tag, value: data[offset : offset+length]}, data[offset+length:], nil
+}
+
+// decodeAll decodes all of the TLVs in data.
+func decodeAll(data []byte) ([]tlv, error) {
+	var all []tlv
+	for len(data) > 0 {
+		t, rest, err := decodeTLV(data)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, t)
+		data = rest
+	}
+	return all, nil
+}
+
+func decodeInteger(b []byte) int64 {
+	var v int64
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func decodeUnsigned(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func decodeOID(b []byte) (oid, error) {
+	var o oid
+	var v uint64
+	for i, c := range b {
+		v = v<<7 | uint64(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return nil, errors.New("truncated OID")
+			}
+			continue
+		}
+		if len(o) == 0 {
+			if v < 80 {
+				o = append(o, v/40, v%40)
+			} else {
+				o = append(o, 2, v-80)
+			}
+		} else {
+			o = append(o, v)
+		}
+		v = 0
+	}
+	if len(o) == 0 {
+		return nil, errors.New("empty OID")
+	}
+	return o, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// maxGetOIDs is the maximum number of OIDs in a single Get request.
+	maxGetOIDs = 32
+	// maxMessageSize is the largest SNMP message accepted over UDP.
+	maxMessageSize = 65535
+	// maxRepetitions is the max-repetitions field of GetBulk requests.
+	maxRepetitions = 25
+	// requestRetries is the number of times a request is retried after a
+	// timeout.
+	requestRetries = 2
+)
+
+// errorStatusNames are the names of the SNMPv2c error-status values.
+var errorStatusNames = []string{
+	"noError", "tooBig", "noSuchName", "badValue", "readOnly", "genErr",
+	"noAccess", "wrongType", "wrongLength", "wrongEncoding", "wrongValue",
+	"noCreation", "inconsistentValue", "resourceUnavailable", "commitFailed",
+	"undoFailed", "authorizationError", "notWritable", "inconsistentName",
+}
+
+// session is an SNMPv2c session with an agent.
+type session struct {
+	conn      net.Conn
+	community string
+	timeout   time.Duration
+	requestID int32
+	buf       []byte
+}
+
+// dialSession creates a session with the agent at address. The default SNMP
+// port is used if address doesn't have a port.
+func dialSession(address string, community string, timeout time.Duration) (*session, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &session{
+		conn:      conn,
+		community: community,
+		timeout:   timeout,
+		buf:       make([]byte, maxMessageSize),
+	}, nil
+}
+
+func (s *session) close() error {
+	return s.conn.Close()
+}
+
+// get returns the values of the OIDs, in order.
+func (s *session) get(oids []oid) ([]variable, error) {
+	var variables []variable
+	for start := 0; start < len(oids); start += maxGetOIDs {
+		end := start + maxGetOIDs
+		if end > len(oids) {
+			end = len(oids)
+		}
+		r, err := s.request(pduGet, 0, 0, oids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, r.variables...)
+	}
+	return variables, nil
+}
+
+// walk calls fn for each variable below base in OID order.
+func (s *session) walk(base oid, fn func(variable)) error {
+	next := base
+	for {
+		r, err := s.request(pduGetBulk, 0, maxRepetitions, []oid{next})
+		if err != nil {
+			return err
+		}
+		if len(r.variables) == 0 {
+			return nil
+		}
+		for _, v := range r.variables {
+			if v.tag == tagEndOfMibView || !v.oid.hasPrefix(base) {
+				return nil
+			}
+			if compareOIDs(v.oid, next) <= 0 {
+				return fmt.Errorf("agent returned OID %s out of order after %s", v.oid, next)
+			}
+			fn(v)
+			next = v.oid
+		}
+	}
+}
+
+// request sends a request and waits for the response, retrying after
+// timeouts.
+func (s *session) request(pduType byte, a int, b int, oids []oid) (*pdu, error) {
+	s.requestID++
+	msg := encodeRequest(s.community, pduType, s.requestID, a, b, oids)
+	var err error
+	for attempt := 0; attempt <= requestRetries; attempt++ {
+		if _, err = s.conn.Write(msg); err != nil {
+			return nil, err
+		}
+		var r *pdu
+		r, err = s.receive(s.requestID)
+		if err == nil {
+			if r.errorStatus != 0 {
+				return nil, fmt.Errorf("agent returned error %s at index %d", errorStatusName(r.errorStatus), r.errorIndex)
+			}
+			return r, nil
+		}
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no response from %s: %v", s.conn.RemoteAddr(), err)
+}
+
+// receive returns the response to the request ID. Invalid messages and
+// responses to earlier requests are ignored.
+func (s *session) receive(requestID int32) (*pdu, error) {
+	if err := s.conn.SetReadDeadline(time.Now().Add(s.timeout)); err != nil {
+		return nil, err
+	}
+	for {
+		n, err := s.conn.Read(s.buf)
+		if err != nil {
+			return nil, err
+		}
+		r, err := decodeMessage(s.buf[:n])
+		if err != nil || r.pduType != pduResponse || r.requestID != requestID {
+			continue
+		}
+		return r, nil
+	}
+}
+
+func errorStatusName(status int) string {
+	if status >= 0 && status < len(errorStatusNames) {
+		return errorStatusNames[status]
+	}
+	return fmt.Sprintf("%d", status)
+}
+
+// compareOIDs returns -1, 0, or 1 if a is before, equal to, or after b.
+func compareOIDs(a oid, b oid) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,374 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snmp provides a southbound adapter that polls SNMPv2c agents and
+// converts the polled values into gNMI Notifications so that legacy devices
+// can be served alongside gNMI targets.
+//
+// Select the adapter for a target with the Protocol target meta field:
+//		Protocol: snmp
+// The OIDs that are polled and the gNMI paths they are inserted at are
+// configured with SNMPMappings in the gateway configuration. Scalars are
+// polled with Get requests and table columns are walked with GetBulk
+// requests. The target's SubscribeRequest is not used. Only the first target
+// address is used and the default port is 161. The adapter supports these
+// additional target meta fields:
+//		SNMPCommunity	- The community string. Defaults to the target's
+//						  credentials password or "public".
+//		SNMPInterval	- The polling interval (default "1m").
+//		SNMPMappings	- Comma-separated SNMPMapping groups to poll. All
+//						  mappings are polled if this isn't set.
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+// Protocol is the Protocol target meta value that selects this adapter.
+const Protocol = "snmp"
+
+// Target meta fields for SNMP targets.
+const (
+	MetaCommunity = "SNMPCommunity"
+	MetaInterval  = "SNMPInterval"
+	MetaMappings  = "SNMPMappings"
+)
+
+const (
+	defaultCommunity = "public"
+	defaultInterval  = time.Minute
+	defaultPort      = "161"
+	defaultTimeout   = 5 * time.Second
+	// indexPlaceholder is replaced with the row index in table mapping paths.
+	indexPlaceholder = "{index}"
+)
+
+func init() {
+	connections.RegisterAdapter(Protocol, New)
+}
+
+// Client is an SNMP polling client that implements client.Client.
+type Client struct {
+	community string
+	interval  time.Duration
+	mappings  []*mapping
+
+	mutex   sync.Mutex
+	session *session
+}
+
+// mapping is a parsed configuration.SNMPMapping.
+type mapping struct {
+	oid      oid
+	indexOID oid
+	path     *gnmipb.Path
+	table    bool
+}
+
+// New creates an SNMP adapter client for the target.
+func New(config *configuration.GatewayConfig, target *targetpb.Target) (client.Client, error) {
+	c := &Client{
+		community: defaultCommunity,
+		interval:  defaultInterval,
+	}
+	if password := target.GetCredentials().GetPassword(); password != "" {
+		c.community = password
+	}
+	if community, exists := target.GetMeta()[MetaCommunity]; exists {
+		c.community = community
+	}
+	if interval, exists := target.GetMeta()[MetaInterval]; exists {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid %s '%s': must be a positive duration", MetaInterval, interval)
+		}
+		c.interval = parsed
+	}
+
+	var groups map[string]bool
+	if names, exists := target.GetMeta()[MetaMappings]; exists {
+		groups = make(map[string]bool)
+		for _, name := range strings.Split(names, ",") {
+			groups[strings.TrimSpace(name)] = true
+		}
+	}
+	for _, m := range config.SNMPMappings {
+		if groups != nil && !groups[m.Group] {
+			continue
+		}
+		parsed, err := newMapping(m)
+		if err != nil {
+			return nil, err
+		}
+		c.mappings = append(c.mappings, parsed)
+	}
+	if len(c.mappings) == 0 {
+		return nil, errors.New("no SNMP mappings are configured for the target")
+	}
+	return c, nil
+}
+
+func newMapping(m configuration.SNMPMapping) (*mapping, error) {
+	o, err := parseOID(m.OID)
+	if err != nil {
+		return nil, err
+	}
+	path, err := xpath.ToGNMIPath(m.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SNMP mapping path '%s': %v", m.Path, err)
+	}
+	parsed := &mapping{
+		oid:   o,
+		path:  path,
+		table: strings.Contains(m.Path, indexPlaceholder),
+	}
+	if m.IndexOID != "" {
+		if !parsed.table {
+			return nil, fmt.Errorf("SNMP mapping for %s has an index_oid but the path doesn't contain %s", m.OID, indexPlaceholder)
+		}
+		if parsed.indexOID, err = parseOID(m.IndexOID); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// pathFor returns the mapping's path with the index placeholder replaced.
+func (m *mapping) pathFor(index string) *gnmipb.Path {
+	p := proto.Clone(m.path).(*gnmipb.Path)
+	for _, elem := range p.Elem {
+		elem.Name = strings.Replace(elem.Name, indexPlaceholder, index, -1)
+		for name, value := range elem.Key {
+			elem.Key[name] = strings.Replace(value, indexPlaceholder, index, -1)
+		}
+	}
+	return p
+}
+
+// Subscribe implements client.Client. Subscribe polls the target every
+// interval until polling fails or ctx is canceled.
+func (c *Client) Subscribe(ctx context.Context, q client.Query, _ ...string) error {
+	if len(q.Addrs) == 0 {
+		return errors.New("SNMP targets require an address")
+	}
+	timeout := q.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	s, err := dialSession(q.Addrs[0], c.community, timeout)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.session = s
+	c.mutex.Unlock()
+	defer c.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+		case <-done:
+		}
+	}()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	var synced bool
+	for {
+		notification, err := c.poll(s, q.Target)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if len(notification.Update) > 0 {
+			if err := q.ProtoHandler(&gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_Update{Update: notification}}); err != nil {
+				return err
+			}
+		}
+		if !synced {
+			synced = true
+			if err := q.ProtoHandler(&gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}}); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll polls all of the mappings and returns the values in a Notification.
+func (c *Client) poll(s *session, target string) (*gnmipb.Notification, error) {
+	notification := &gnmipb.Notification{
+		Timestamp: time.Now().UnixNano(),
+		Prefix:    &gnmipb.Path{Target: target},
+	}
+
+	var scalars []*mapping
+	var oids []oid
+	indexNames := make(map[string]map[string]string)
+	for _, m := range c.mappings {
+		if !m.table {
+			scalars = append(scalars, m)
+			oids = append(oids, m.oid)
+			continue
+		}
+
+		var names map[string]string
+		if m.indexOID != nil {
+			var exists bool
+			names, exists = indexNames[m.indexOID.String()]
+			if !exists {
+				names = make(map[string]string)
+				err := s.walk(m.indexOID, func(v variable) {
+					names[v.oid[len(m.indexOID):].String()] = valueString(v)
+				})
+				if err != nil {
+					return nil, fmt.Errorf("unable to walk %s: %v", m.indexOID, err)
+				}
+				indexNames[m.indexOID.String()] = names
+			}
+		}
+		err := s.walk(m.oid, func(v variable) {
+			index := v.oid[len(m.oid):].String()
+			if name, exists := names[index]; exists {
+				index = name
+			}
+			if val := typedValue(v); val != nil {
+				notification.Update = append(notification.Update, &gnmipb.Update{Path: m.pathFor(index), Val: val})
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to walk %s: %v", m.oid, err)
+		}
+	}
+
+	if len(oids) > 0 {
+		variables, err := s.get(oids)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get scalars: %v", err)
+		}
+		for i, v := range variables {
+			if i >= len(scalars) {
+				break
+			}
+			if val := typedValue(v); val != nil {
+				notification.Update = append(notification.Update, &gnmipb.Update{Path: scalars[i].pathFor(""), Val: val})
+			}
+		}
+	}
+	return notification, nil
+}
+
+// typedValue converts an SNMP value to a gNMI TypedValue. nil is returned for
+// missing values.
+func typedValue(v variable) *gnmipb.TypedValue {
+	switch v.tag {
+	case tagInteger:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: decodeInteger(v.value)}}
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: decodeUnsigned(v.value)}}
+	case tagOctetString:
+		if printable(v.value) {
+			return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: string(v.value)}}
+		}
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BytesVal{BytesVal: v.value}}
+	case tagIPAddress, tagOID:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: valueString(v)}}
+	case tagOpaque:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BytesVal{BytesVal: v.value}}
+	}
+	return nil
+}
+
+// valueString returns the value as a string for use as a path key.
+func valueString(v variable) string {
+	switch v.tag {
+	case tagInteger:
+		return strconv.FormatInt(decodeInteger(v.value), 10)
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return strconv.FormatUint(decodeUnsigned(v.value), 10)
+	case tagIPAddress:
+		return net.IP(v.value).String()
+	case tagOID:
+		o, err := decodeOID(v.value)
+		if err != nil {
+			return ""
+		}
+		return o.String()
+	}
+	return string(v.value)
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Poll implements client.Client. Polling on demand is not supported.
+func (c *Client) Poll() error {
+	return errors.New("poll is not supported for SNMP targets")
+}
+
+// Close implements client.Client.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	s := c.session
+	c.session = nil
+	c.mutex.Unlock()
+	if s == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// Impl implements client.Client. SNMP clients don't have a gNMI client
+// implementation.
+func (c *Client) Impl() (client.Impl, error) {
+	return nil, errors.New("SNMP targets don't have a gNMI client implementation")
+}
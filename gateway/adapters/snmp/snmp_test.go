@@ -0,0 +1,227 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestBER_Integer(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 127, 128, -128, -129, 255, 256, 1 << 31, -(1 << 31)} {
+		decoded, _, err := decodeTLV(encodeInteger(v))
+		assert.NoError(t, err)
+		assert.Equal(t, v, decodeInteger(decoded.value))
+	}
+}
+
+func TestBER_OID(t *testing.T) {
+	o, err := parseOID(".1.3.6.1.4.1.2636.3.1.13.1.8.9.1.0.0")
+	assert.NoError(t, err)
+	decoded, err := decodeOID(encodeOID(o))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.6.1.4.1.2636.3.1.13.1.8.9.1.0.0", decoded.String())
+
+	_, err = parseOID("1")
+	assert.Error(t, err)
+	_, err = parseOID("1.3.x")
+	assert.Error(t, err)
+}
+
+func TestBER_Message(t *testing.T) {
+	assertion := assert.New(t)
+
+	long := strings.Repeat("x", 300)
+	o := oid{1, 3, 6, 1, 2, 1, 1, 5, 0}
+	msg := encodeMessage("public", pduResponse, 42, 0, 0, []variable{{oid: o, tag: tagOctetString, value: []byte(long)}})
+	decoded, err := decodeMessage(msg)
+	assertion.NoError(err)
+	assertion.Equal(byte(pduResponse), decoded.pduType)
+	assertion.Equal(int32(42), decoded.requestID)
+	if assertion.Len(decoded.variables, 1) {
+		assertion.Equal(o, decoded.variables[0].oid)
+		assertion.Equal(long, string(decoded.variables[0].value))
+	}
+
+	_, err = decodeMessage(msg[:len(msg)-1])
+	assertion.Error(err)
+}
+
+// testAgent is a minimal SNMPv2c agent that serves a fixed set of values.
+type testAgent struct {
+	conn   net.PacketConn
+	oids   []oid
+	values map[string]variable
+}
+
+func newTestAgent(t *testing.T, variables []variable) *testAgent {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &testAgent{conn: conn, values: make(map[string]variable)}
+	for _, v := range variables {
+		a.oids = append(a.oids, v.oid)
+		a.values[v.oid.String()] = v
+	}
+	sort.Slice(a.oids, func(i, j int) bool {
+		return compareOIDs(a.oids[i], a.oids[j]) < 0
+	})
+	go a.serve()
+	return a
+}
+
+func (a *testAgent) serve() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request, err := decodeMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		var variables []variable
+		switch request.pduType {
+		case pduGet:
+			for _, v := range request.variables {
+				value, exists := a.values[v.oid.String()]
+				if !exists {
+					value = variable{oid: v.oid, tag: tagNoSuchObject}
+				}
+				variables = append(variables, value)
+			}
+		case pduGetBulk:
+			next := request.variables[0].oid
+			for _, o := range a.oids {
+				if compareOIDs(o, next) > 0 && len(variables) < request.errorIndex {
+					variables = append(variables, a.values[o.String()])
+				}
+			}
+			if len(variables) == 0 {
+				variables = append(variables, variable{oid: next, tag: tagEndOfMibView})
+			}
+		}
+		_, _ = a.conn.WriteTo(encodeMessage("public", pduResponse, request.requestID, 0, 0, variables), addr)
+	}
+}
+
+func uintBytes(v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append([]byte{0}, b...)
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	assertion := assert.New(t)
+
+	agent := newTestAgent(t, []variable{
+		{oid: oid{1, 3, 6, 1, 2, 1, 1, 3, 0}, tag: tagTimeTicks, value: uintBytes(12345)},
+		{oid: oid{1, 3, 6, 1, 2, 1, 1, 5, 0}, tag: tagOctetString, value: []byte("dev1")},
+		{oid: oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 2, 1}, tag: tagOctetString, value: []byte("eth0")},
+		{oid: oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 2, 2}, tag: tagOctetString, value: []byte("eth1")},
+		{oid: oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1}, tag: tagCounter32, value: uintBytes(100)},
+		{oid: oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 2}, tag: tagCounter32, value: uintBytes(200)},
+	})
+	defer agent.conn.Close()
+
+	config := &configuration.GatewayConfig{
+		SNMPMappings: []configuration.SNMPMapping{
+			{Group: "system", OID: "1.3.6.1.2.1.1.3.0", Path: "/system/state/uptime"},
+			{Group: "system", OID: "1.3.6.1.2.1.1.4.0", Path: "/system/state/contact"},
+			{Group: "interfaces", OID: "1.3.6.1.2.1.2.2.1.10", Path: "/interfaces/interface[name={index}]/state/counters/in-octets", IndexOID: "1.3.6.1.2.1.2.2.1.2"},
+		},
+	}
+	c, err := New(config, &targetpb.Target{Meta: map[string]string{MetaCommunity: "public"}})
+	assertion.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values := make(map[string]uint64)
+	var synced bool
+	err = c.Subscribe(ctx, client.Query{
+		Addrs:   []string{agent.conn.LocalAddr().String()},
+		Target:  "dev1",
+		Timeout: time.Second,
+		ProtoHandler: func(msg proto.Message) error {
+			switch resp := msg.(*gnmipb.SubscribeResponse).Response.(type) {
+			case *gnmipb.SubscribeResponse_Update:
+				assertion.Equal("dev1", resp.Update.GetPrefix().GetTarget())
+				for _, u := range resp.Update.GetUpdate() {
+					values[pathString(u.GetPath())] = u.GetVal().GetUintVal()
+				}
+			case *gnmipb.SubscribeResponse_SyncResponse:
+				synced = true
+				cancel()
+			}
+			return nil
+		},
+	})
+	assertion.Equal(context.Canceled, err)
+	assertion.True(synced)
+	assertion.Equal(map[string]uint64{
+		"/system/state/uptime": 12345,
+		"/interfaces/interface[name=eth0]/state/counters/in-octets": 100,
+		"/interfaces/interface[name=eth1]/state/counters/in-octets": 200,
+	}, values)
+}
+
+func TestNew_Mappings(t *testing.T) {
+	config := &configuration.GatewayConfig{
+		SNMPMappings: []configuration.SNMPMapping{
+			{Group: "system", OID: "1.3.6.1.2.1.1.3.0", Path: "/system/state/uptime"},
+			{Group: "interfaces", OID: "1.3.6.1.2.1.2.2.1.10", Path: "/interfaces/interface[name={index}]/state/counters/in-octets"},
+		},
+	}
+	c, err := New(config, &targetpb.Target{Meta: map[string]string{MetaMappings: "interfaces"}})
+	assert.NoError(t, err)
+	assert.Len(t, c.(*Client).mappings, 1)
+
+	_, err = New(config, &targetpb.Target{Meta: map[string]string{MetaMappings: "unknown"}})
+	assert.Error(t, err)
+	_, err = New(config, &targetpb.Target{Meta: map[string]string{MetaInterval: "0s"}})
+	assert.Error(t, err)
+
+	config.SNMPMappings = append(config.SNMPMappings, configuration.SNMPMapping{OID: "1.3.6.1.2.1.1.5.0", Path: "/system/state/hostname", IndexOID: "1.3.6.1.2.1.2.2.1.2"})
+	_, err = New(config, &targetpb.Target{})
+	assert.Error(t, err)
+}
+
+func pathString(p *gnmipb.Path) string {
+	var s string
+	for _, elem := range p.GetElem() {
+		s += "/" + elem.GetName()
+		for name, value := range elem.GetKey() {
+			s += "[" + name + "=" + value + "]"
+		}
+	}
+	return s
+}
@@ -0,0 +1,337 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/golang/protobuf/jsonpb"
+
+	"github.com/openconfig/gnmi-gateway/gateway/admin"
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+// registerAdminHandlers adds the gateway's handlers to the admin server.
+func (g *Gateway) registerAdminHandlers(s *admin.Server) {
+	s.HandleFunc("/cache", g.handleCache)
+	s.HandleFunc("/capture/start", g.handleCaptureStart)
+	s.HandleFunc("/capture/stop", g.handleCaptureStop)
+	s.HandleFunc("/clients", g.handleClients)
+	s.HandleFunc("/events", g.handleEvents)
+	s.HandleFunc("/targets", g.handleTargets)
+	s.HandleFunc("/targets/disable", g.handleTargetDisable)
+	s.HandleFunc("/targets/enable", g.handleTargetEnable)
+	s.HandleFunc("/targets/errors", g.handleTargetErrors)
+	s.HandleFunc("/targets/reconnect", g.handleTargetReconnect)
+	if g.config.AdminDiagnostics {
+		s.HandleDiagnostics()
+		s.HandleFunc("/debug/connections", g.handleConnections)
+	}
+}
+
+// handleCache returns the cached notifications of a target, or of all targets
+// if target is "*" or not set, below the path. The path is an XPath whose
+// elements and key values may be "*"; by default every path is returned.
+//		GET /cache?target=<name>&path=<xpath>
+func (g *Gateway) handleCache(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		target = "*"
+	}
+	query, err := shardedcache.ParseQuery(r.URL.Query().Get("path"))
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if target != "*" && !g.connMgr.Cache().HasTarget(target) {
+		admin.WriteError(w, http.StatusNotFound, fmt.Errorf("no such target: '%s'", target))
+		return
+	}
+	snapshot, err := g.connMgr.Cache().Snapshot(target, query)
+	if err != nil {
+		admin.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	marshaler := jsonpb.Marshaler{}
+	notifications := make([]json.RawMessage, 0, len(snapshot))
+	for _, notification := range snapshot {
+		encoded, err := marshaler.MarshalToString(notification)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		notifications = append(notifications, json.RawMessage(encoded))
+	}
+	admin.WriteJSON(w, http.StatusOK, notifications)
+}
+
+// handleCaptureStart starts a debug capture of raw SubscribeResponses for a target.
+// The file is created in the CaptureDirectory and must not already exist.
+//		POST /capture/start?target=<name>&file=<name>&format=<proto|json>
+func (g *Gateway) handleCaptureStart(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if g.config.CaptureDirectory == "" {
+		admin.WriteError(w, http.StatusServiceUnavailable, errors.New("captures are disabled because no capture directory is configured"))
+		return
+	}
+	target := r.URL.Query().Get("target")
+	name := r.URL.Query().Get("file")
+	if target == "" || name == "" {
+		admin.WriteError(w, http.StatusBadRequest, errors.New("target and file parameters are required"))
+		return
+	}
+	if err := capture.ValidateFileName(name); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	file := filepath.Join(g.config.CaptureDirectory, name)
+	format, err := capture.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := g.connMgr.StartCapture(target, file, format); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, map[string]string{
+		"target": target,
+		"file":   file,
+		"format": string(format),
+	})
+}
+
+// handleCaptureStop stops a debug capture for a target.
+//		POST /capture/stop?target=<name>
+func (g *Gateway) handleCaptureStop(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		admin.WriteError(w, http.StatusBadRequest, errors.New("target parameter is required"))
+		return
+	}
+	if err := g.connMgr.StopCapture(target); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, map[string]string{"target": target})
+}
+
+// handleClients lists the connected gNMI Subscribe clients along with their
+// subscriptions, send queue depth, and send rate.
+//		GET /clients
+func (g *Gateway) handleClients(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	g.gnmiServerLock.Lock()
+	gnmiServer := g.gnmiServer
+	g.gnmiServerLock.Unlock()
+	if gnmiServer == nil {
+		admin.WriteError(w, http.StatusServiceUnavailable, errors.New("gNMI server is not running"))
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, gnmiServer.Clients())
+}
+
+// eventStreamBuffer is the number of events buffered for each /events
+// request. Events are dropped for a client that doesn't keep up.
+const eventStreamBuffer = 100
+
+// handleEvents streams the target events published from now on as
+// newline-delimited JSON until the client disconnects. Events are only sent
+// for the targets matching the target shell pattern and of the event type,
+// if set.
+//		GET /events?target=<pattern>&type=<type>
+func (g *Gateway) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	pattern := r.URL.Query().Get("target")
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid target pattern: %v", err))
+		return
+	}
+	eventType := events.Type(r.URL.Query().Get("type"))
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		admin.WriteError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	c, unsubscribe := events.Subscribe(eventStreamBuffer)
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case e := <-c:
+			if pattern != "" {
+				if matched, _ := filepath.Match(pattern, e.Target); !matched {
+					continue
+				}
+			}
+			if eventType != "" && e.Type != eventType {
+				continue
+			}
+			if err := encoder.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// connectionDump is the response of the /debug/connections endpoint.
+type connectionDump struct {
+	Clients []server.ClientInfo        `json:"clients"`
+	Targets []connections.TargetStatus `json:"targets"`
+}
+
+// handleConnections dumps the state of every target connection and, if the
+// gNMI server is running, every connected Subscribe client.
+//		GET /debug/connections
+func (g *Gateway) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	dump := connectionDump{Targets: g.connMgr.Targets()}
+	g.gnmiServerLock.Lock()
+	gnmiServer := g.gnmiServer
+	g.gnmiServerLock.Unlock()
+	if gnmiServer != nil {
+		dump.Clients = gnmiServer.Clients()
+	}
+	admin.WriteJSON(w, http.StatusOK, dump)
+}
+
+// handleTargets lists the configured targets along with their connection
+// status and the address currently in use.
+//		GET /targets
+func (g *Gateway) handleTargets(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, g.connMgr.Targets())
+}
+
+// handleTargetDisable disconnects from a target and keeps it disconnected
+// until it is enabled, e.g. during maintenance. The target's cached data is
+// kept unless flush is true (default is TargetDisabledFlush).
+//		POST /targets/disable?target=<name>&flush=<true|false>
+func (g *Gateway) handleTargetDisable(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		admin.WriteError(w, http.StatusBadRequest, errors.New("target parameter is required"))
+		return
+	}
+	flush := g.config.TargetDisabledFlush
+	if value := r.URL.Query().Get("flush"); value != "" {
+		var err error
+		flush, err = strconv.ParseBool(value)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("flush parameter must be true or false"))
+			return
+		}
+	}
+	if err := g.connMgr.DisableTarget(target, flush); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, map[string]interface{}{"target": target, "flushed": flush})
+}
+
+// handleTargetEnable connects to a target disabled with /targets/disable.
+//		POST /targets/enable?target=<name>
+func (g *Gateway) handleTargetEnable(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		admin.WriteError(w, http.StatusBadRequest, errors.New("target parameter is required"))
+		return
+	}
+	if err := g.connMgr.EnableTarget(target); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, map[string]string{"target": target})
+}
+
+// handleTargetErrors lists the most recent errors returned by a target's
+// connection, oldest first, with their gRPC status codes.
+//		GET /targets/errors?target=<name>
+func (g *Gateway) handleTargetErrors(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		admin.WriteError(w, http.StatusBadRequest, errors.New("target parameter is required"))
+		return
+	}
+	targetErrors, err := g.connMgr.TargetErrors(target)
+	if err != nil {
+		admin.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, targetErrors)
+}
+
+// handleTargetReconnect closes the connection to a target so that it connects
+// again.
+//		POST /targets/reconnect?target=<name>
+func (g *Gateway) handleTargetReconnect(w http.ResponseWriter, r *http.Request) {
+	if !admin.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		admin.WriteError(w, http.StatusBadRequest, errors.New("target parameter is required"))
+		return
+	}
+	if err := g.connMgr.ReconnectTarget(target); err != nil {
+		admin.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	admin.WriteJSON(w, http.StatusOK, map[string]string{"target": target})
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admin provides the HTTP server that exposes runtime state and
+// controls for a running gateway. Other gateway components register their
+// handlers with the Server before it is started.
+//
+// All responses from the admin API are JSON encoded. Errors are returned as
+// an object with a single "error" field. If an AdminToken is configured every
+// request must include it as a bearer token in the Authorization header.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Server is the admin HTTP server.
+type Server struct {
+	config *configuration.GatewayConfig
+	mux    *http.ServeMux
+
+	httpServerMutex sync.Mutex
+	httpServer      *http.Server
+}
+
+// NewServer creates a new admin server. Start must be called to begin
+// serving requests.
+func NewServer(config *configuration.GatewayConfig) *Server {
+	return &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+}
+
+// Handle registers the handler for the given pattern.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern.
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		WriteError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized returns true if no AdminToken is configured or the request
+// includes it as a bearer token.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.config.AdminToken == "" {
+		return true
+	}
+	expected := "Bearer " + s.config.AdminToken
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) == 1
+}
+
+// Start listens on AdminListenAddress and serves admin requests. Start blocks
+// until the server stops. Start returns nil if the server was stopped with
+// Shutdown.
+func (s *Server) Start() error {
+	s.config.Log.Info().Msgf("Starting admin server on %s.", s.config.AdminListenAddress)
+	s.httpServerMutex.Lock()
+	s.httpServer = &http.Server{Addr: s.config.AdminListenAddress, Handler: s}
+	httpServer := s.httpServer
+	s.httpServerMutex.Unlock()
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.httpServerMutex.Lock()
+	httpServer := s.httpServer
+	s.httpServerMutex.Unlock()
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
+}
+
+// WriteJSON writes v to the response as JSON with the provided status code.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+// WriteError writes an error response with the provided status code.
+func WriteError(w http.ResponseWriter, status int, err error) {
+	WriteJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// RequireMethod returns false and writes an error response if the request
+// method is not the expected method.
+func RequireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestServer_AdminToken(t *testing.T) {
+	assertion := assert.New(t)
+
+	s := NewServer(&configuration.GatewayConfig{AdminToken: "secret"})
+	s.HandleFunc("/targets", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, []string{})
+	})
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		request := httptest.NewRequest(http.MethodGet, "/targets", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		assertion.Equal(http.StatusUnauthorized, recorder.Code, header)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/targets", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, request)
+	assertion.Equal(http.StatusOK, recorder.Code)
+}
+
+func TestServer_HandleDiagnostics(t *testing.T) {
+	assertion := assert.New(t)
+
+	s := NewServer(&configuration.GatewayConfig{})
+	s.HandleDiagnostics()
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	assertion.Equal(http.StatusOK, recorder.Code)
+	assertion.Contains(recorder.Body.String(), `"goroutines"`)
+
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	assertion.Equal(http.StatusOK, recorder.Code)
+}
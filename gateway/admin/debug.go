@@ -0,0 +1,94 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/metrics"
+)
+
+// HandleDiagnostics registers the net/http/pprof handlers under /debug/pprof/
+// (e.g. /debug/pprof/heap or /debug/pprof/goroutine?debug=2 for a dump of all
+// goroutines) and the runtime/metrics samples under /debug/runtime.
+//		GET /debug/pprof/
+//		GET /debug/runtime
+func (s *Server) HandleDiagnostics() {
+	s.HandleFunc("/debug/pprof/", pprof.Index)
+	s.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.HandleFunc("/debug/runtime", handleRuntime)
+}
+
+// histogramBucket is a bucket of a runtime/metrics histogram. The buckets
+// with an infinite upper bound are left out because they can't be encoded
+// as JSON; they are still included in the histogram count.
+type histogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+type histogram struct {
+	Count   uint64            `json:"count"`
+	Buckets []histogramBucket `json:"buckets"`
+}
+
+// handleRuntime writes the value of every supported runtime/metrics metric
+// along with the number of goroutines.
+func handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if !RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	descriptions := metrics.All()
+	samples := make([]metrics.Sample, len(descriptions))
+	for i, description := range descriptions {
+		samples[i].Name = description.Name
+	}
+	metrics.Read(samples)
+
+	values := map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+	}
+	for _, sample := range samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			values[sample.Name] = sample.Value.Uint64()
+		case metrics.KindFloat64:
+			if value := sample.Value.Float64(); !math.IsNaN(value) && !math.IsInf(value, 0) {
+				values[sample.Name] = value
+			}
+		case metrics.KindFloat64Histogram:
+			values[sample.Name] = newHistogram(sample.Value.Float64Histogram())
+		}
+	}
+	WriteJSON(w, http.StatusOK, values)
+}
+
+func newHistogram(h *metrics.Float64Histogram) histogram {
+	var result histogram
+	for i, count := range h.Counts {
+		result.Count += count
+		// Buckets[i+1] is the upper bound of Counts[i].
+		if upper := h.Buckets[i+1]; count > 0 && !math.IsInf(upper, 0) {
+			result.Buckets = append(result.Buckets, histogramBucket{UpperBound: upper, Count: count})
+		}
+	}
+	return result
+}
@@ -0,0 +1,409 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+// AdminTokenEnv is the environment variable the admin command reads the
+// AdminToken from if -Token isn't set.
+const AdminTokenEnv = "GNMI_GATEWAY_ADMIN_TOKEN"
+
+const adminUsage = `Usage: gnmi-gateway admin [flags] <command> [arguments]
+
+Commands:
+  targets                                  List the targets and their connection state
+  stats <target>                           Show the status and recent errors of a target
+  errors <target>                          List the recent errors of a target
+  reconnect <target>                       Close the connection to a target so it connects again
+  disable [-Flush=<true|false>] <target>   Disconnect from a target and keep it disconnected
+  enable <target>                          Connect to a disabled target
+  capture start [-Format=<proto|json>] <target> <file>
+                                           Start a debug capture of a target's raw responses
+  capture stop <target>                    Stop a debug capture
+  clients                                  List the connected gNMI Subscribe clients
+  events [-Target=<pattern>] [-Type=<type>]
+                                           Print target events as they are published
+
+Flags:
+`
+
+// RunAdmin parses the admin command-line arguments and runs the command
+// against the admin API of a running gateway (see -EnableAdminServer),
+// writing the result to w. The events command runs until the gateway closes
+// the stream.
+func RunAdmin(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	address := flags.String("Address", "127.0.0.1:6160", "The address and port, or URL, of the gateway's admin HTTP server")
+	token := flags.String("Token", "", "Bearer token for the admin HTTP server (default is $"+AdminTokenEnv+")")
+	timeout := flags.Duration("Timeout", 10*time.Second, "Timeout of the admin requests, except for events")
+	asJSON := flags.Bool("JSON", false, "Print the JSON responses of the admin API instead of tables")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), adminUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no admin command given")
+	}
+	if *token == "" {
+		*token = os.Getenv(AdminTokenEnv)
+	}
+	c := &adminClient{
+		base:    strings.TrimRight(*address, "/"),
+		token:   *token,
+		timeout: *timeout,
+		json:    *asJSON,
+		w:       w,
+	}
+	if !strings.Contains(c.base, "://") {
+		c.base = "http://" + c.base
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "targets":
+		return c.targets()
+	case "stats":
+		target, err := oneTarget(command, args)
+		if err != nil {
+			return err
+		}
+		return c.stats(target)
+	case "errors":
+		target, err := oneTarget(command, args)
+		if err != nil {
+			return err
+		}
+		return c.errorHistory(target)
+	case "reconnect", "enable":
+		target, err := oneTarget(command, args)
+		if err != nil {
+			return err
+		}
+		return c.post("/targets/"+command, url.Values{"target": {target}})
+	case "disable":
+		disableFlags := flag.NewFlagSet("disable", flag.ContinueOnError)
+		flush := disableFlags.String("Flush", "", "Flush the target's cached data (default is the gateway's -TargetDisabledFlush)")
+		if err := disableFlags.Parse(args); err != nil {
+			return err
+		}
+		target, err := oneTarget(command, disableFlags.Args())
+		if err != nil {
+			return err
+		}
+		params := url.Values{"target": {target}}
+		if *flush != "" {
+			params.Set("flush", *flush)
+		}
+		return c.post("/targets/disable", params)
+	case "capture":
+		return c.capture(args)
+	case "clients":
+		return c.clients()
+	case "events":
+		eventFlags := flag.NewFlagSet("events", flag.ContinueOnError)
+		pattern := eventFlags.String("Target", "", "Only print the events of targets matching the shell pattern")
+		eventType := eventFlags.String("Type", "", fmt.Sprintf("Only print the events of the type (one of %v)", events.Types))
+		if err := eventFlags.Parse(args); err != nil {
+			return err
+		}
+		return c.tailEvents(*pattern, *eventType)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown admin command '%s'", command)
+	}
+}
+
+// oneTarget returns the target argument of command.
+func oneTarget(command string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("%s requires exactly one target argument", command)
+	}
+	return args[0], nil
+}
+
+// adminClient sends requests to the admin API of a running gateway.
+type adminClient struct {
+	base    string
+	token   string
+	timeout time.Duration
+	json    bool
+	w       io.Writer
+}
+
+// request sends a request to the admin API and returns the response if its
+// status is 200 OK. The error message of the admin API is returned for other
+// responses. The response body must be closed.
+func (c *adminClient) request(method string, path string, params url.Values, timeout time.Duration) (*http.Response, error) {
+	u := c.base + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var apiError struct {
+		Error string `json:"error"`
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if json.Unmarshal(body, &apiError) == nil && apiError.Error != "" {
+		return nil, fmt.Errorf("%s %s: %s", method, path, apiError.Error)
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+}
+
+// get decodes the JSON response of a GET request into v. With -JSON the
+// response is also printed as is.
+func (c *adminClient) get(path string, params url.Values, v interface{}) error {
+	resp, err := c.request(http.MethodGet, path, params, c.timeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		_, err = c.w.Write(body)
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// post sends a POST request and prints the JSON response.
+func (c *adminClient) post(path string, params url.Values) error {
+	resp, err := c.request(http.MethodPost, path, params, c.timeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(c.w, resp.Body)
+	return err
+}
+
+func (c *adminClient) targets() error {
+	var targets []connections.TargetStatus
+	if err := c.get("/targets", nil, &targets); err != nil || c.json {
+		return err
+	}
+	tw := tabwriter.NewWriter(c.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tSYNCED\tADDRESS\tCONNECTED FOR\tRECEIVED\tLAST ERROR")
+	for _, t := range targets {
+		var connectedFor string
+		if t.Connected {
+			connectedFor = connectedDuration(t.ConnectedFor).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%d\t%s\n", t.Name, t.State, t.Synced, t.Address, connectedFor, t.Received, t.LastError)
+	}
+	return tw.Flush()
+}
+
+// connectedDuration returns the ConnectedFor seconds of a TargetStatus as a
+// duration rounded to seconds.
+func connectedDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second)
+}
+
+func (c *adminClient) stats(target string) error {
+	var targets []connections.TargetStatus
+	if err := c.get("/targets", nil, &targets); err != nil || c.json {
+		return err
+	}
+	var status *connections.TargetStatus
+	for i := range targets {
+		if targets[i].Name == target {
+			status = &targets[i]
+		}
+	}
+	if status == nil {
+		return fmt.Errorf("no such target: '%s'", target)
+	}
+	tw := tabwriter.NewWriter(c.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", status.Name)
+	fmt.Fprintf(tw, "State:\t%s\n", status.State)
+	fmt.Fprintf(tw, "Connected:\t%t\n", status.Connected)
+	if status.Connected {
+		fmt.Fprintf(tw, "Connected for:\t%s\n", connectedDuration(status.ConnectedFor))
+	}
+	fmt.Fprintf(tw, "Synced:\t%t\n", status.Synced)
+	fmt.Fprintf(tw, "Disabled:\t%t\n", status.Disabled)
+	fmt.Fprintf(tw, "Quarantined:\t%t\n", status.Quarantined)
+	fmt.Fprintf(tw, "Cluster member:\t%t\n", status.ClusterMember)
+	fmt.Fprintf(tw, "Addresses:\t%s\n", strings.Join(status.Addresses, ", "))
+	if status.Address != "" {
+		fmt.Fprintf(tw, "Address:\t%s (%s)\n", status.Address, status.AddressFamily)
+	}
+	for _, field := range []struct{ name, value string }{
+		{"Pool", status.Pool},
+		{"Tenant", status.Tenant},
+		{"Maintenance", status.Maintenance},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", field.name, field.value)
+		}
+	}
+	fmt.Fprintf(tw, "Received:\t%d messages, %d bytes\n", status.Received, status.ReceivedBytes)
+	if capabilities := status.Capabilities; capabilities != nil {
+		fmt.Fprintf(tw, "gNMI version:\t%s\n", capabilities.GNMIVersion)
+		fmt.Fprintf(tw, "Encoding:\t%s\n", capabilities.Encoding)
+		fmt.Fprintf(tw, "Models:\t%d\n", len(capabilities.Models))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.w)
+	return c.errorHistory(target)
+}
+
+func (c *adminClient) errorHistory(target string) error {
+	var targetErrors []connections.TargetError
+	if err := c.get("/targets/errors", url.Values{"target": {target}}, &targetErrors); err != nil || c.json {
+		return err
+	}
+	if len(targetErrors) == 0 {
+		_, err := fmt.Fprintln(c.w, "No errors.")
+		return err
+	}
+	tw := tabwriter.NewWriter(c.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tCODE\tMESSAGE")
+	for _, e := range targetErrors {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.Code, e.Message)
+	}
+	return tw.Flush()
+}
+
+func (c *adminClient) capture(args []string) error {
+	if len(args) == 0 {
+		return errors.New("capture requires a start or stop argument")
+	}
+	switch args[0] {
+	case "start":
+		captureFlags := flag.NewFlagSet("capture start", flag.ContinueOnError)
+		format := captureFlags.String("Format", "", "The format of the capture file, proto or json (default is proto)")
+		if err := captureFlags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if captureFlags.NArg() != 2 {
+			return errors.New("capture start requires a target and a file argument")
+		}
+		params := url.Values{"target": {captureFlags.Arg(0)}, "file": {captureFlags.Arg(1)}}
+		if *format != "" {
+			params.Set("format", *format)
+		}
+		return c.post("/capture/start", params)
+	case "stop":
+		target, err := oneTarget("capture stop", args[1:])
+		if err != nil {
+			return err
+		}
+		return c.post("/capture/stop", url.Values{"target": {target}})
+	default:
+		return fmt.Errorf("unknown capture command '%s'", args[0])
+	}
+}
+
+func (c *adminClient) clients() error {
+	var clients []server.ClientInfo
+	if err := c.get("/clients", nil, &clients); err != nil || c.json {
+		return err
+	}
+	tw := tabwriter.NewWriter(c.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPEER\tTARGET\tMODE\tPATHS\tQUEUE\tDROPPED\tSENT\tSENT/S")
+	for _, client := range clients {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%.1f\n", client.ID, client.Peer, client.Target, client.Mode,
+			strings.Join(client.Paths, ","), client.QueueDepth, client.Dropped, client.Sent, client.SentPerSecond)
+	}
+	return tw.Flush()
+}
+
+// tailEvents prints the events streamed by the admin API, one per line,
+// until the stream is closed. The stream isn't subject to -Timeout.
+func (c *adminClient) tailEvents(pattern string, eventType string) error {
+	params := url.Values{}
+	if pattern != "" {
+		params.Set("target", pattern)
+	}
+	if eventType != "" {
+		params.Set("type", eventType)
+	}
+	resp, err := c.request(http.MethodGet, "/events", params, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if c.json {
+			fmt.Fprintf(c.w, "%s\n", line)
+			continue
+		}
+		var e events.Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("invalid event: %v", err)
+		}
+		fmt.Fprintf(c.w, "%s %-20s %s", e.Time.Format(time.RFC3339), e.Type, e.Target)
+		if e.Address != "" {
+			fmt.Fprintf(c.w, " address=%s", e.Address)
+		}
+		if e.Member != "" {
+			fmt.Fprintf(c.w, " member=%s", e.Member)
+		}
+		if e.Message != "" {
+			fmt.Fprintf(c.w, " message=%q", e.Message)
+		}
+		fmt.Fprintln(c.w)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+)
+
+// fakeAdminAPI serves the admin API endpoints used by RunAdmin and records
+// the requests it receives.
+func fakeAdminAPI(t *testing.T, requests *[]string) *httptest.Server {
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}
+	mux.HandleFunc("/targets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []connections.TargetStatus{
+			{Name: "router1", State: "synced", Connected: true, ConnectedFor: 90, Synced: true, Address: "192.0.2.10:9339", Received: 42},
+			{Name: "router2", State: "connecting", LastError: "connection refused"},
+		})
+	})
+	mux.HandleFunc("/targets/errors", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("target") != "router2" {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]string{"error": "no such target: '" + r.URL.Query().Get("target") + "'"})
+			return
+		}
+		writeJSON(w, []connections.TargetError{{Time: time.Unix(0, 0).UTC(), Code: "Unavailable", Message: "connection refused"}})
+	})
+	mux.HandleFunc("/targets/reconnect", func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.String(), r.Header.Get("Authorization")))
+		writeJSON(w, map[string]string{"target": r.URL.Query().Get("target")})
+	})
+	mux.HandleFunc("/targets/disable", func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, fmt.Sprintf("%s %s", r.Method, r.URL.String()))
+		writeJSON(w, map[string]interface{}{"target": r.URL.Query().Get("target"), "flushed": true})
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, fmt.Sprintf("%s %s", r.Method, r.URL.String()))
+		for _, e := range []events.Event{
+			{Type: events.TargetConnected, Target: "router1", Time: time.Unix(0, 0).UTC(), Address: "192.0.2.10:9339"},
+			{Type: events.TargetDisconnected, Target: "router1", Time: time.Unix(1, 0).UTC(), Message: "EOF"},
+		} {
+			writeJSON(w, e)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRunAdmin(t *testing.T) {
+	assertion := assert.New(t)
+
+	var requests []string
+	api := fakeAdminAPI(t, &requests)
+	defer api.Close()
+	address := strings.TrimPrefix(api.URL, "http://")
+	run := func(args ...string) (string, error) {
+		out := new(bytes.Buffer)
+		err := RunAdmin(append([]string{"-Address", address, "-Token", "secret"}, args...), out)
+		return out.String(), err
+	}
+
+	out, err := run("targets")
+	if assertion.NoError(err) {
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if assertion.Len(lines, 3) {
+			assertion.Contains(lines[0], "NAME")
+			assertion.Contains(lines[1], "router1")
+			assertion.Contains(lines[1], "1m30s")
+			assertion.Contains(lines[2], "connection refused")
+		}
+	}
+
+	out, err = run("stats", "router2")
+	if assertion.NoError(err) {
+		assertion.Contains(out, "State:")
+		assertion.Contains(out, "Unavailable")
+	}
+	_, err = run("stats", "router3")
+	assertion.EqualError(err, "no such target: 'router3'")
+	_, err = run("errors", "router3")
+	assertion.EqualError(err, "GET /targets/errors: no such target: 'router3'")
+
+	out, err = run("-JSON", "targets")
+	if assertion.NoError(err) {
+		var targets []connections.TargetStatus
+		assertion.NoError(json.Unmarshal([]byte(out), &targets))
+		assertion.Len(targets, 2)
+	}
+
+	_, err = run("reconnect", "router1")
+	assertion.NoError(err)
+	_, err = run("disable", "-Flush=true", "router1")
+	assertion.NoError(err)
+	out, err = run("events", "-Target", "router*")
+	if assertion.NoError(err) {
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if assertion.Len(lines, 2) {
+			assertion.Contains(lines[0], "target.connected")
+			assertion.Contains(lines[1], `message="EOF"`)
+		}
+	}
+	assertion.Equal([]string{
+		"POST /targets/reconnect?target=router1 Bearer secret",
+		"POST /targets/disable?flush=true&target=router1",
+		"GET /events?target=router%2A",
+	}, requests)
+
+	_, err = run("reconnect")
+	assertion.Error(err)
+	_, err = run("unknown")
+	assertion.Error(err)
+	assertion.Error(RunAdmin(nil, new(bytes.Buffer)))
+}
@@ -0,0 +1,328 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aws provides the AWS credential chain and request signing used by
+// the gateway's AWS integrations, without depending on the AWS SDK.
+package aws
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	containerCredentialsHost = "http://169.254.170.2"
+	instanceMetadataHost     = "http://169.254.169.254"
+	credentialsExpirySkew    = 5 * time.Minute
+)
+
+// Credentials are AWS access keys.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expiration is zero for static credentials.
+	Expiration time.Time
+}
+
+// CredentialsProvider returns the credentials used to sign AWS requests.
+type CredentialsProvider interface {
+	Retrieve() (*Credentials, error)
+}
+
+// DefaultCredentials finds credentials in the same order as the AWS SDKs'
+// default credential chain:
+//		1. The AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+//		   environment variables.
+//		2. A web identity token (AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN),
+//		   e.g. EKS IAM roles for service accounts.
+//		3. The shared credentials file (AWS_SHARED_CREDENTIALS_FILE or
+//		   ~/.aws/credentials) and the AWS_PROFILE profile.
+//		4. ECS container credentials.
+//		5. The EC2 instance profile.
+type DefaultCredentials struct {
+	client *http.Client
+	region string
+
+	mutex  sync.Mutex
+	cached *Credentials
+}
+
+// NewDefaultCredentials creates DefaultCredentials that use client for the
+// credential requests. The region is used for STS requests.
+func NewDefaultCredentials(client *http.Client, region string) *DefaultCredentials {
+	return &DefaultCredentials{client: client, region: region}
+}
+
+// Region returns configured if it's set, otherwise the AWS_REGION or
+// AWS_DEFAULT_REGION environment variable.
+func Region(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// Retrieve implements CredentialsProvider. Credentials are cached until
+// shortly before they expire.
+func (d *DefaultCredentials) Retrieve() (*Credentials, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.cached != nil && (d.cached.Expiration.IsZero() || time.Now().Before(d.cached.Expiration.Add(-credentialsExpirySkew))) {
+		return d.cached, nil
+	}
+	creds, err := d.retrieve()
+	if err != nil {
+		return nil, err
+	}
+	d.cached = creds
+	return creds, nil
+}
+
+func (d *DefaultCredentials) retrieve() (*Credentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &Credentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" {
+		return d.webIdentityCredentials(tokenFile, os.Getenv("AWS_ROLE_ARN"))
+	}
+	creds, err := sharedCredentials()
+	if err != nil || creds != nil {
+		return creds, err
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return d.fetchCredentials(containerCredentialsHost+uri, nil)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		var headers map[string]string
+		if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+			headers = map[string]string{"Authorization": token}
+		}
+		return d.fetchCredentials(uri, headers)
+	}
+	return d.instanceCredentials()
+}
+
+// sharedCredentials reads the AWS shared credentials file. It returns nil
+// credentials if the file doesn't exist.
+func sharedCredentials() (*Credentials, error) {
+	filename := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		filename = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var creds Credentials
+	var section string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = value
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = value
+		case "aws_session_token":
+			creds.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if creds.AccessKeyID == "" {
+		return nil, nil
+	}
+	return &creds, nil
+}
+
+// credentialsResponse is the format of ECS container and EC2 instance
+// credentials.
+type credentialsResponse struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (d *DefaultCredentials) fetchCredentials(uri string, headers map[string]string) (*Credentials, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	body, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var resp credentialsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unable to parse AWS credentials: %v", err)
+	}
+	if resp.AccessKeyID == "" {
+		return nil, errors.New("AWS credentials response is missing the access key")
+	}
+	return &Credentials{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SessionToken:    resp.Token,
+		Expiration:      resp.Expiration,
+	}, nil
+}
+
+// instanceCredentials gets the EC2 instance profile credentials using IMDSv2.
+func (d *DefaultCredentials) instanceCredentials() (*Credentials, error) {
+	req, err := http.NewRequest(http.MethodPut, instanceMetadataHost+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := d.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no AWS credentials found and the instance metadata service is unavailable: %v", err)
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": string(token)}
+
+	req, err = http.NewRequest(http.MethodGet, instanceMetadataHost+"/latest/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	roles, err := d.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the EC2 instance profile role: %v", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("the EC2 instance has no instance profile role")
+	}
+	return d.fetchCredentials(instanceMetadataHost+"/latest/meta-data/iam/security-credentials/"+role, headers)
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// webIdentityCredentials exchanges a web identity token for role credentials
+// with STS. AssumeRoleWithWebIdentity requests aren't signed.
+func (d *DefaultCredentials) webIdentityCredentials(tokenFile, roleARN string) (*Credentials, error) {
+	if roleARN == "" {
+		return nil, errors.New("AWS_ROLE_ARN must be set with AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read web identity token: %v", err)
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "gnmi-gateway"
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://sts."+d.region+".amazonaws.com/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := d.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to assume role with web identity: %v", err)
+	}
+	var resp assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unable to parse STS response: %v", err)
+	}
+	return &Credentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		SessionToken:    resp.Credentials.SessionToken,
+		Expiration:      resp.Credentials.Expiration,
+	}, nil
+}
+
+func (d *DefaultCredentials) do(req *http.Request) ([]byte, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,423 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/fakedevice"
+	"github.com/openconfig/gnmi-gateway/gateway/loaders"
+)
+
+// benchLatencySamples is the number of latency samples kept to compute the
+// latency percentiles.
+const benchLatencySamples = 100000
+
+// BenchOpts are the parameters of a benchmark run.
+type BenchOpts struct {
+	// Targets is the number of synthetic targets.
+	Targets int
+	// Leaves is the number of leaves of each target.
+	Leaves int
+	// UpdateRate is the number of updates per second sent by each target.
+	UpdateRate float64
+	// BatchSize is the maximum number of updates in each notification sent
+	// by the targets.
+	BatchSize int
+	// Clients is the number of gNMI clients subscribed to all targets.
+	Clients int
+	// CacheShards is the number of cache shards of the gateway.
+	CacheShards int
+	// FanoutWorkers is the number of workers delivering updates to the
+	// gNMI server subscriptions.
+	FanoutWorkers int
+	// Warmup is the time waited after the targets are synced before
+	// measuring.
+	Warmup time.Duration
+	// Duration is the length of the measurement.
+	Duration time.Duration
+}
+
+// BenchResult is the result of a benchmark run. Latencies are measured from
+// the time the targets send the notifications to the time the clients
+// receive them.
+type BenchResult struct {
+	Targets    int     `json:"targets"`
+	Leaves     int     `json:"leaves"`
+	UpdateRate float64 `json:"update_rate"`
+	Clients    int     `json:"clients"`
+	// CacheShards is the number of cache shards of the gateway.
+	CacheShards int `json:"cache_shards"`
+	// FanoutWorkers is the number of fan-out workers of the gateway.
+	FanoutWorkers int `json:"fanout_workers"`
+	// Duration is the length of the measurement in seconds.
+	Duration float64 `json:"duration"`
+	// Sent is the number of updates sent by the targets.
+	Sent uint64 `json:"sent"`
+	// Received is the number of updates received by all of the clients.
+	Received uint64 `json:"received"`
+	// SentPerSecond and ReceivedPerSecond are the update throughputs.
+	SentPerSecond     float64 `json:"sent_per_second"`
+	ReceivedPerSecond float64 `json:"received_per_second"`
+	// Latencies in milliseconds.
+	LatencyP50 float64 `json:"latency_p50_ms"`
+	LatencyP99 float64 `json:"latency_p99_ms"`
+	LatencyMax float64 `json:"latency_max_ms"`
+}
+
+// benchLoader sends a single target configuration and waits to be stopped.
+type benchLoader struct {
+	config   *targetpb.Configuration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ loaders.TargetLoader = &benchLoader{}
+
+func (l *benchLoader) GetConfiguration() (*targetpb.Configuration, error) {
+	return l.config, nil
+}
+
+func (l *benchLoader) Start() error {
+	return nil
+}
+
+func (l *benchLoader) WatchConfiguration(targetChan chan<- *connections.TargetConnectionControl) error {
+	targetChan <- &connections.TargetConnectionControl{Insert: l.config}
+	<-l.stop
+	return nil
+}
+
+func (l *benchLoader) Stop() {
+	l.stopOnce.Do(func() { close(l.stop) })
+}
+
+// benchStats are the counters of the benchmark clients.
+type benchStats struct {
+	mutex     sync.Mutex
+	received  uint64
+	samples   []time.Duration
+	seen      int
+	max       time.Duration
+	measuring bool
+}
+
+// record records a notification with updates updates and latency.
+func (s *benchStats) record(updates int, latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.measuring {
+		return
+	}
+	s.received += uint64(updates)
+	if latency > s.max {
+		s.max = latency
+	}
+	// reservoir sampling keeps a uniform sample of the latencies
+	s.seen++
+	if len(s.samples) < benchLatencySamples {
+		s.samples = append(s.samples, latency)
+	} else if i := rand.Intn(s.seen); i < benchLatencySamples {
+		s.samples[i] = latency
+	}
+}
+
+// setMeasuring starts or stops the measurement.
+func (s *benchStats) setMeasuring(measuring bool) {
+	s.mutex.Lock()
+	s.measuring = measuring
+	s.mutex.Unlock()
+}
+
+// percentile returns the p-th percentile of the latency samples in
+// milliseconds.
+func (s *benchStats) percentile(p float64) float64 {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	sort.Slice(s.samples, func(i, j int) bool { return s.samples[i] < s.samples[j] })
+	i := int(p * float64(len(s.samples)-1))
+	return milliseconds(s.samples[i])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Bench measures the throughput and latency of the cache and gNMI server by
+// running a gateway connected to in-process synthetic targets with gNMI
+// clients subscribed to all of the targets.
+func Bench(opts BenchOpts) (*BenchResult, error) {
+	if opts.Targets <= 0 || opts.Clients <= 0 {
+		return nil, errors.New("the number of targets and clients must be greater than 0")
+	}
+
+	devices := make([]*fakedevice.Device, 0, opts.Targets)
+	defer func() {
+		for _, device := range devices {
+			device.Stop()
+		}
+	}()
+	targets := &targetpb.Configuration{
+		Request: map[string]*gnmi.SubscribeRequest{
+			"bench": {
+				Request: &gnmi.SubscribeRequest_Subscribe{
+					Subscribe: &gnmi.SubscriptionList{
+						Prefix:       &gnmi.Path{Target: "*"},
+						Subscription: []*gnmi.Subscription{{Path: &gnmi.Path{}}},
+					},
+				},
+			},
+		},
+		Target: make(map[string]*targetpb.Target),
+	}
+	for i := 0; i < opts.Targets; i++ {
+		name := "bench" + strconv.Itoa(i)
+		device, err := fakedevice.New(fakedevice.Config{
+			Name:       name,
+			Leaves:     opts.Leaves,
+			UpdateRate: opts.UpdateRate,
+			BatchSize:  opts.BatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+		targets.Target[name] = &targetpb.Target{
+			Addresses: []string{device.Address()},
+			Request:   "bench",
+			Meta:      map[string]string{"NoTLSVerify": "yes"},
+		}
+	}
+
+	config, err := benchConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	gateway := NewGateway(config)
+	started := make(chan struct{})
+	loader := &benchLoader{config: targets, stop: make(chan struct{})}
+	result := gateway.Start(&StartOpts{
+		TargetLoaders: []loaders.TargetLoader{loader},
+		Hooks: Hooks{
+			OnStarted: func(g *Gateway) { close(started) },
+		},
+	})
+	defer func() {
+		gateway.Stop()
+		<-result
+	}()
+	select {
+	case <-started:
+	case err := <-result:
+		return nil, fmt.Errorf("gateway exited: %v", err)
+	}
+	if err := waitForSync(gateway, opts.Targets, time.Minute); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stats := new(benchStats)
+	address := net.JoinHostPort(config.ServerListenAddress, strconv.Itoa(config.ServerListenPort))
+	errs := make(chan error, opts.Clients)
+	for i := 0; i < opts.Clients; i++ {
+		go func() {
+			errs <- benchClient(ctx, address, stats)
+		}()
+	}
+
+	time.Sleep(opts.Warmup)
+	sentStart := benchSent(devices)
+	start := time.Now()
+	stats.setMeasuring(true)
+	select {
+	case <-time.After(opts.Duration):
+	case err := <-errs:
+		return nil, fmt.Errorf("benchmark client failed: %v", err)
+	}
+	stats.setMeasuring(false)
+	elapsed := time.Since(start)
+	sent := benchSent(devices) - sentStart
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	return &BenchResult{
+		Targets:           opts.Targets,
+		Leaves:            opts.Leaves,
+		UpdateRate:        opts.UpdateRate,
+		Clients:           opts.Clients,
+		CacheShards:       opts.CacheShards,
+		FanoutWorkers:     opts.FanoutWorkers,
+		Duration:          elapsed.Seconds(),
+		Sent:              sent,
+		Received:          stats.received,
+		SentPerSecond:     float64(sent) / elapsed.Seconds(),
+		ReceivedPerSecond: float64(stats.received) / elapsed.Seconds(),
+		LatencyP50:        stats.percentile(0.5),
+		LatencyP99:        stats.percentile(0.99),
+		LatencyMax:        milliseconds(stats.max),
+	}, nil
+}
+
+// benchConfig returns the gateway configuration for a benchmark run: the gNMI
+// server listens on a free local port with a self-signed certificate.
+func benchConfig(opts BenchOpts) (*configuration.GatewayConfig, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	cert, err := fakedevice.SelfSignedCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.Log = zerolog.New(os.Stderr).With().Timestamp().Logger().Level(zerolog.WarnLevel)
+	config.EnableGNMIServer = true
+	config.GatewayTransitionBufferSize = 100000
+	config.ServerListenAddress = "127.0.0.1"
+	config.ServerListenPort = port
+	config.ServerTLSCreds = credentials.NewServerTLSFromCert(&cert)
+	config.TargetDialTimeout = 5 * time.Second
+	config.CacheShards = opts.CacheShards
+	config.FanoutWorkers = opts.FanoutWorkers
+	config.TargetLimit = opts.Targets
+	return config, nil
+}
+
+// waitForSync waits until the gateway is synced with all of the targets.
+func waitForSync(gateway *Gateway, targets int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		synced := 0
+		for _, target := range gateway.ConnectionManager().Targets() {
+			if target.Synced {
+				synced++
+			}
+		}
+		if synced == targets {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("the gateway didn't sync with the %d targets within %s", targets, timeout)
+}
+
+// benchSent returns the number of updates sent by the devices.
+func benchSent(devices []*fakedevice.Device) uint64 {
+	var sent uint64
+	for _, device := range devices {
+		sent += device.Sent()
+	}
+	return sent
+}
+
+// benchClient subscribes to the updates of all targets and records them
+// until ctx is canceled.
+func benchClient(ctx context.Context, address string, stats *benchStats) error {
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stream, err := gnmi.NewGNMIClient(conn).Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&gnmi.SubscribeRequest{Request: &gnmi.SubscribeRequest_Subscribe{
+		Subscribe: &gnmi.SubscriptionList{
+			Prefix:       &gnmi.Path{Target: "*"},
+			Subscription: []*gnmi.Subscription{{Path: &gnmi.Path{}}},
+			Mode:         gnmi.SubscriptionList_STREAM,
+			UpdatesOnly:  true,
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if n := resp.GetUpdate(); n != nil {
+			stats.record(len(n.GetUpdate()), time.Since(time.Unix(0, n.GetTimestamp())))
+		}
+	}
+}
+
+// RunBench runs the "bench" subcommand with the command-line arguments args
+// and writes the result to w.
+func RunBench(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	opts := BenchOpts{}
+	flags.IntVar(&opts.Targets, "Targets", 10, "Number of synthetic targets")
+	flags.IntVar(&opts.Leaves, "Leaves", 1000, "Number of leaves of each target")
+	flags.Float64Var(&opts.UpdateRate, "UpdateRate", 1000, "Updates per second sent by each target")
+	flags.IntVar(&opts.BatchSize, "BatchSize", 10, "Maximum number of updates in each notification")
+	flags.IntVar(&opts.Clients, "Clients", 1, "Number of gNMI clients subscribed to all targets")
+	flags.IntVar(&opts.CacheShards, "CacheShards", 1, "Number of cache shards of the gateway")
+	flags.IntVar(&opts.FanoutWorkers, "FanoutWorkers", 1, "Number of workers delivering updates to the gNMI server subscriptions")
+	flags.DurationVar(&opts.Warmup, "Warmup", 5*time.Second, "Time to wait after the targets are synced before measuring")
+	flags.DurationVar(&opts.Duration, "Duration", 30*time.Second, "Length of the measurement")
+	jsonOutput := flags.Bool("JSON", false, "Write the result as a JSON object")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	result, err := Bench(opts)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return json.NewEncoder(w).Encode(result)
+	}
+	_, err = fmt.Fprintf(w, "targets: %d, leaves: %d, update rate: %.0f/s per target, clients: %d, cache shards: %d, fan-out workers: %d, duration: %.1fs\n"+
+		"sent: %d (%.0f/s), received: %d (%.0f/s)\n"+
+		"latency: p50 %.2fms, p99 %.2fms, max %.2fms\n",
+		result.Targets, result.Leaves, result.UpdateRate, result.Clients, result.CacheShards, result.FanoutWorkers, result.Duration,
+		result.Sent, result.SentPerSecond, result.Received, result.ReceivedPerSecond,
+		result.LatencyP50, result.LatencyP99, result.LatencyMax)
+	return err
+}
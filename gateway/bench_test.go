@@ -0,0 +1,57 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBench(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the benchmark in short mode")
+	}
+	assertion := assert.New(t)
+
+	out := new(bytes.Buffer)
+	err := RunBench([]string{
+		"-Targets", "2",
+		"-Leaves", "10",
+		"-UpdateRate", "200",
+		"-Warmup", "200ms",
+		"-Duration", "1s",
+		"-JSON",
+	}, out)
+	if !assertion.NoError(err) {
+		return
+	}
+	var result BenchResult
+	assertion.NoError(json.Unmarshal(out.Bytes(), &result))
+	assertion.Equal(2, result.Targets)
+	assertion.True(result.Sent > 0)
+	assertion.True(result.Received > 0)
+	assertion.True(result.LatencyP50 <= result.LatencyMax)
+	assertion.True(result.Duration >= time.Second.Seconds())
+}
+
+func TestBench_invalid(t *testing.T) {
+	_, err := Bench(BenchOpts{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,237 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capture provides a Writer and Reader for dumping raw gNMI
+// SubscribeResponse messages to a file and reading them back.
+//
+// Two file formats are supported:
+//		proto	- Length-delimited binary protobuf messages. Each message is
+//				  prefixed with its length encoded as a varint.
+//		json	- One JSON encoded SubscribeResponse per line.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Format is the encoding used for messages in a capture file.
+type Format string
+
+const (
+	// FormatProto is the length-delimited binary protobuf format.
+	FormatProto Format = "proto"
+	// FormatJSON is the newline-delimited JSON format.
+	FormatJSON Format = "json"
+)
+
+// maxMessageSize is the largest message that will be read from a capture file.
+const maxMessageSize = 64 * 1024 * 1024
+
+// ParseFormat returns the Format for the provided name. An empty name
+// defaults to FormatProto.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case "", FormatProto:
+		return FormatProto, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unknown capture format '%s'", name)
+}
+
+// Writer writes SubscribeResponse messages to a capture file. Writer is safe
+// for concurrent use.
+type Writer struct {
+	file   *os.File
+	format Format
+	mutex  sync.Mutex
+	writer *bufio.Writer
+	count  uint64
+}
+
+// ValidateFileName returns an error if name can't be used as a capture file
+// name inside of a capture directory. Names may not contain path separators
+// or refer to the directory itself or its parent.
+func ValidateFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid capture file name '%s'", name)
+	}
+	return nil
+}
+
+// NewWriter creates the file at path and returns a Writer that will write
+// messages to it in the provided format. Existing files are never
+// overwritten.
+func NewWriter(path string, format Format) (*Writer, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create capture file '%s': %v", path, err)
+	}
+	return &Writer{
+		file:   f,
+		format: format,
+		writer: bufio.NewWriter(f),
+	}, nil
+}
+
+// Name returns the path of the capture file.
+func (w *Writer) Name() string {
+	return w.file.Name()
+}
+
+// Count returns the number of messages that have been written.
+func (w *Writer) Count() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.count
+}
+
+// Write appends a SubscribeResponse to the capture file.
+func (w *Writer) Write(resp *gnmipb.SubscribeResponse) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	switch w.format {
+	case FormatJSON:
+		marshaler := jsonpb.Marshaler{}
+		data, err := marshaler.MarshalToString(resp)
+		if err != nil {
+			return fmt.Errorf("unable to marshal message to JSON: %v", err)
+		}
+		if _, err := w.writer.WriteString(data + "\n"); err != nil {
+			return err
+		}
+	default:
+		data, err := proto.Marshal(resp)
+		if err != nil {
+			return fmt.Errorf("unable to marshal message: %v", err)
+		}
+		var size [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(size[:], uint64(len(data)))
+		if _, err := w.writer.Write(size[:n]); err != nil {
+			return err
+		}
+		if _, err := w.writer.Write(data); err != nil {
+			return err
+		}
+	}
+	w.count++
+	return nil
+}
+
+// Close flushes any buffered messages and closes the capture file.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.writer.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Reader reads SubscribeResponse messages from a capture file.
+type Reader struct {
+	file    *os.File
+	format  Format
+	reader  *bufio.Reader
+	scanner *bufio.Scanner
+}
+
+// NewReader opens the capture file at path for reading messages in the
+// provided format.
+func NewReader(path string, format Format) (*Reader, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open capture file '%s': %v", path, err)
+	}
+	r := &Reader{
+		file:   f,
+		format: format,
+	}
+	if format == FormatJSON {
+		r.scanner = bufio.NewScanner(f)
+		r.scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	} else {
+		r.reader = bufio.NewReader(f)
+	}
+	return r, nil
+}
+
+// Read returns the next SubscribeResponse in the capture file. Read returns
+// io.EOF when there are no more messages.
+func (r *Reader) Read() (*gnmipb.SubscribeResponse, error) {
+	resp := new(gnmipb.SubscribeResponse)
+	switch r.format {
+	case FormatJSON:
+		for {
+			if !r.scanner.Scan() {
+				if err := r.scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			line := r.scanner.Text()
+			if line == "" {
+				continue
+			}
+			if err := jsonpb.UnmarshalString(line, resp); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal JSON message: %v", err)
+			}
+			return resp, nil
+		}
+	default:
+		size, err := binary.ReadUvarint(r.reader)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxMessageSize {
+			return nil, fmt.Errorf("message size %d exceeds maximum of %d bytes", size, maxMessageSize)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r.reader, data); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if err := proto.Unmarshal(data, resp); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal message: %v", err)
+		}
+		return resp, nil
+	}
+}
+
+// Close closes the capture file.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
+)
+
+func testResponses() []*gnmipb.SubscribeResponse {
+	return []*gnmipb.SubscribeResponse{
+		{
+			Response: &gnmipb.SubscribeResponse_Update{
+				Update: &gnmipb.Notification{
+					Timestamp: 1,
+					Prefix:    &gnmipb.Path{Target: "a"},
+					Update: []*gnmipb.Update{
+						{
+							Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "x"}}},
+							Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: 1}},
+						},
+					},
+				},
+			},
+		},
+		{
+			Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true},
+		},
+	}
+}
+
+func TestCapture_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []capture.Format{capture.FormatProto, capture.FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			assertion := assert.New(t)
+			file := filepath.Join(dir, "capture."+string(format))
+
+			writer, err := capture.NewWriter(file, format)
+			assertion.NoError(err)
+			for _, resp := range testResponses() {
+				assertion.NoError(writer.Write(resp))
+			}
+			assertion.Equal(uint64(2), writer.Count())
+			assertion.NoError(writer.Close())
+
+			reader, err := capture.NewReader(file, format)
+			assertion.NoError(err)
+			defer reader.Close()
+			for _, expected := range testResponses() {
+				resp, err := reader.Read()
+				assertion.NoError(err)
+				assertion.True(proto.Equal(expected, resp), "got %v, want %v", resp, expected)
+			}
+			_, err = reader.Read()
+			assertion.Equal(io.EOF, err)
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	assertion := assert.New(t)
+
+	format, err := capture.ParseFormat("")
+	assertion.NoError(err)
+	assertion.Equal(capture.FormatProto, format)
+
+	format, err = capture.ParseFormat("json")
+	assertion.NoError(err)
+	assertion.Equal(capture.FormatJSON, format)
+
+	_, err = capture.ParseFormat("xml")
+	assertion.Error(err)
+}
+
+func TestNewWriter_Exists(t *testing.T) {
+	file, err := ioutil.TempFile("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	_, err = capture.NewWriter(file.Name(), capture.FormatProto)
+	assert.Error(t, err)
+}
+
+func TestValidateFileName(t *testing.T) {
+	assertion := assert.New(t)
+	assertion.NoError(capture.ValidateFileName("router1.pb"))
+	for _, name := range []string{"", ".", "..", "../etc/passwd", "/etc/passwd", "dir/file", `dir\file`} {
+		assertion.Error(capture.ValidateFileName(name), name)
+	}
+}
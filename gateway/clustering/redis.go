@@ -0,0 +1,196 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustering
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+)
+
+var _ ClusterMember = new(RedisClusterMember)
+
+// RedisClusterMember is a ClusterMember backed by Redis. Each member is a key
+// below the members prefix that expires after RedisTTL unless the member
+// refreshes it. Members that stop refreshing their key are removed from the
+// cluster once it expires.
+type RedisClusterMember struct {
+	client *redis.Client
+	config *configuration.GatewayConfig
+	member MemberID
+	prefix string
+
+	mutex          sync.Mutex
+	refreshStop    chan struct{}
+	callbackCancel func()
+}
+
+// NewRedisClusterMember creates a RedisClusterMember for member. The member
+// keys are prefixed with ZookeeperPrefix, with the path elements joined with
+// colons.
+func NewRedisClusterMember(config *configuration.GatewayConfig, client *redis.Client, member string) *RedisClusterMember {
+	return &RedisClusterMember{
+		client: client,
+		config: config,
+		member: MemberID(member),
+		prefix: redis.KeyFromPath(CleanPath(config.ZookeeperPrefix)+CleanPath(ClusterMemberPath)) + ":",
+	}
+}
+
+func (r *RedisClusterMember) MemberID() MemberID {
+	return r.member
+}
+
+// Register sets the member key and keeps refreshing it until Unregister is
+// called.
+func (r *RedisClusterMember) Register() error {
+	if err := r.refresh(); err != nil {
+		return fmt.Errorf("unable to register cluster member with Redis: %v", err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.refreshStop == nil {
+		r.refreshStop = make(chan struct{})
+		go r.keepAlive(r.refreshStop)
+	}
+	return nil
+}
+
+func (r *RedisClusterMember) refresh() error {
+	ttl := strconv.FormatInt(int64(r.config.RedisTTL/time.Millisecond), 10)
+	_, err := r.client.Do("SET", r.prefix+string(r.member), string(r.member), "PX", ttl)
+	return err
+}
+
+func (r *RedisClusterMember) keepAlive(stop chan struct{}) {
+	ticker := time.NewTicker(r.config.RedisTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		// mutex is held so that the key isn't set again after Unregister
+		// removes it
+		r.mutex.Lock()
+		if r.refreshStop != stop {
+			r.mutex.Unlock()
+			return
+		}
+		if err := r.refresh(); err != nil {
+			r.config.Log.Error().Msgf("Unable to refresh cluster member registration in Redis: %v", err)
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// Unregister stops refreshing the member key and removes it. The member list
+// callback is stopped.
+func (r *RedisClusterMember) Unregister() error {
+	r.mutex.Lock()
+	if r.refreshStop != nil {
+		close(r.refreshStop)
+		r.refreshStop = nil
+	}
+	if r.callbackCancel != nil {
+		r.callbackCancel()
+		r.callbackCancel = nil
+	}
+	r.mutex.Unlock()
+	if _, err := r.client.Do("DEL", r.prefix+string(r.member)); err != nil {
+		return fmt.Errorf("unable to unregister cluster member from Redis: %v", err)
+	}
+	return nil
+}
+
+// MemberList returns the other members with an unexpired member key.
+func (r *RedisClusterMember) MemberList() ([]MemberID, error) {
+	var clusterMembers []MemberID
+	cursor := "0"
+	for {
+		reply, err := r.client.Do("SCAN", cursor, "MATCH", r.prefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, fmt.Errorf("error while trying to list cluster members: %v", err)
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply: %v", reply)
+		}
+		keys, _ := page[1].([]interface{})
+		for _, key := range keys {
+			name, _ := key.(string)
+			member := MemberID(strings.TrimPrefix(name, r.prefix))
+			if member != "" && member != r.member && !memberIDInSlice(member, clusterMembers) {
+				clusterMembers = append(clusterMembers, member)
+			}
+		}
+		cursor, _ = page[0].(string)
+		if cursor == "0" || cursor == "" {
+			return clusterMembers, nil
+		}
+	}
+}
+
+// MemberListCallback polls the member list every third of RedisTTL and calls
+// the callback for each member added or removed.
+func (r *RedisClusterMember) MemberListCallback(callback MemberListCallbackFunc) error {
+	r.mutex.Lock()
+	if r.callbackCancel != nil {
+		r.callbackCancel()
+	}
+	stop := make(chan struct{})
+	r.callbackCancel = func() {
+		close(stop)
+	}
+	r.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(r.config.RedisTTL / 3)
+		defer ticker.Stop()
+		var previousMembers []MemberID
+		for {
+			currentMembers, err := r.MemberList()
+			if err != nil {
+				r.config.Log.Error().Msgf("Unable to list cluster members: %v", err)
+			} else {
+				for _, member := range currentMembers {
+					if !memberIDInSlice(member, previousMembers) {
+						callback(member, "") // add
+					}
+				}
+				for _, member := range previousMembers {
+					if !memberIDInSlice(member, currentMembers) {
+						callback("", member) // remove
+					}
+				}
+				previousMembers = currentMembers
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustering
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+	"github.com/openconfig/gnmi-gateway/gateway/redis/redistest"
+)
+
+func TestRedisClusterMember(t *testing.T) {
+	assertion := assert.New(t)
+
+	server, err := redistest.NewServer()
+	if !assertion.NoError(err) {
+		return
+	}
+	defer server.Close()
+	client := redis.NewClient(redis.Options{Address: server.Address(), Timeout: time.Second})
+	defer client.Close()
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.ZookeeperPrefix = "/gnmi/gateway/"
+	config.RedisTTL = 300 * time.Millisecond
+
+	a := NewRedisClusterMember(config, client, "10.0.0.1:9339")
+	b := NewRedisClusterMember(config, client, "10.0.0.2:9339")
+	assertion.NoError(a.Register())
+
+	var mutex sync.Mutex
+	var added, removed []MemberID
+	assertion.NoError(a.MemberListCallback(func(add MemberID, remove MemberID) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if add != "" {
+			added = append(added, add)
+		}
+		if remove != "" {
+			removed = append(removed, remove)
+		}
+	}))
+
+	assertion.NoError(b.Register())
+	_, exists := server.Get("gnmi:gateway:members:10.0.0.2:9339")
+	assertion.True(exists)
+	members, err := a.MemberList()
+	assertion.NoError(err)
+	assertion.Equal([]MemberID{"10.0.0.2:9339"}, members)
+
+	// registrations are refreshed before they expire
+	time.Sleep(400 * time.Millisecond)
+	members, err = a.MemberList()
+	assertion.NoError(err)
+	assertion.Equal([]MemberID{"10.0.0.2:9339"}, members)
+
+	assertion.NoError(b.Unregister())
+	members, err = a.MemberList()
+	assertion.NoError(err)
+	assertion.Empty(members)
+
+	time.Sleep(250 * time.Millisecond)
+	mutex.Lock()
+	assertion.Equal([]MemberID{"10.0.0.2:9339"}, added)
+	assertion.Equal([]MemberID{"10.0.0.2:9339"}, removed)
+	mutex.Unlock()
+	assertion.NoError(a.Unregister())
+}
@@ -18,9 +18,7 @@
 package configuration
 
 import (
-	"bytes"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -37,33 +35,272 @@ import (
 // Many of these options may be set via command-line flags. See main.go for details on flags that
 // are available.
 type GatewayConfig struct {
+	// AdminDiagnostics exposes pprof profiles, runtime metrics, and a dump of the target
+	// and client connections under /debug/ on the admin HTTP server.
+	AdminDiagnostics bool `json:"admin_diagnostics"`
+	// AdminListenAddress is the address and port the admin HTTP server will listen on.
+	AdminListenAddress string `json:"admin_listen_address"`
+	// AdminToken, if set, is the bearer token that must be included in the Authorization
+	// header of every admin API request.
+	AdminToken string `json:"admin_token"`
+	// CacheShards is the number of cache instances that targets are spread across by
+	// the hash of their name. More shards reduce lock contention between targets at
+	// high update rates. Zero or one uses a single cache.
+	CacheShards int `json:"cache_shards"`
+	// CaptureDirectory is the directory that capture files requested through the admin
+	// API are created in. Captures are disabled if CaptureDirectory is empty.
+	CaptureDirectory string `json:"capture_directory"`
 	// ClientTLSConfig are the gNMI client TLS credentials. Setting this will enable client TLS.
 	// TODO (cmcintosh): Add options to set client certificates by path (i.e. like the server TLS creds).
 	ClientTLSConfig *tls.Config `ignored:"true"`
+	// ClusterMemberCommonNames are the common names of the TLS client certificates that the
+	// other cluster members present when they subscribe to this gateway (see ClientTLSConfig).
+	// Clients with one of these certificates can subscribe to every target without the
+	// server ACL. Other cluster members are subject to the ACL like any other client.
+	ClusterMemberCommonNames []string `json:"cluster_member_common_names"`
+	// ClockSkewThreshold is the average difference between the receive time and the
+	// notification timestamps of a target above which a warning is logged and the
+	// gnmigateway.client.subscribe.clock_skew_exceeded gauge is set. Zero disables the check.
+	ClockSkewThreshold time.Duration `json:"clock_skew_threshold"`
+	// ConnectionPools are named groups of targets with their own connection slot limits so
+	// that targets in one pool can't use all of the connection slots. Targets are assigned
+	// to a pool with the ConnectionPool target meta field or the Targets patterns. Targets
+	// that aren't assigned to a pool share the TargetLimit slots. ConnectionPools can only
+	// be set in the configuration file.
+	ConnectionPools []ConnectionPool `json:"connection_pools"`
+	// CounterRates computes the deltas and per-second rates of the counters below the
+	// configured paths. CounterRates can only be set in the configuration file.
+	CounterRates []CounterRate `json:"counter_rates"`
+	// DefaultLeafTTL is the maximum amount of time a cached leaf may go without being
+	// updated before it is deleted from the cache. Zero disables expiry for leaves that
+	// don't match any of the LeafTTLs.
+	DefaultLeafTTL time.Duration `json:"default_leaf_ttl"`
+	// DialOutListener is the address that targets using the dialout southbound adapter
+	// connect to. The listener uses the same TLS options as ServerListeners. Dial-out is
+	// disabled if the Address is empty.
+	DialOutListener ServerListener `json:"dial_out_listener"`
+	// EnableAdminServer will run the admin HTTP server which exposes runtime state and
+	// controls (e.g. debug captures) for the gateway.
+	EnableAdminServer bool `json:"enable_admin_server"`
 	// EnableGNMIServer will run the gNMI server (the Subscribe server). TLS options are also required
 	// for the gNMI server to be enabled.
 	EnableGNMIServer bool `json:"enable_gnmi_server"`
+	// EventBufferSize is the number of events buffered for each event sink. Events are
+	// dropped for a sink whose buffer is full.
+	EventBufferSize int `json:"event_buffer_size"`
+	// EventKafkaBrokers are the Kafka broker addresses and ports of the kafka event sink.
+	EventKafkaBrokers []string `json:"event_kafka_brokers"`
+	// EventKafkaTopic is the Kafka topic the kafka event sink writes events to.
+	EventKafkaTopic string `json:"event_kafka_topic"`
+	// EventSinks are the names of the registered event sinks that target connection state
+	// changes (connected, synced, disconnected, lock lost, quarantined) are published to.
+	EventSinks []string `json:"event_sinks"`
+	// EventWebhookAuthHeader is the value of the Authorization header of the requests
+	// made by the webhook event sink, e.g. "Bearer <token>".
+	EventWebhookAuthHeader string `json:"event_webhook_auth_header"`
+	// EventWebhookRetries is the number of times the webhook event sink retries an event
+	// after a connection error or a 429 or 5xx response.
+	EventWebhookRetries int `json:"event_webhook_retries"`
+	// EventWebhookRetryBackoff is the time to wait before the first retry of an event. It
+	// doubles with each retry.
+	EventWebhookRetryBackoff time.Duration `json:"event_webhook_retry_backoff"`
+	// EventWebhookTemplate is a Go text/template for the JSON body of the webhook requests.
+	// The fields of the event are available as .Type, .Target, .Time, .Member, .Address,
+	// and .Message and the json function encodes a value as JSON. The event is posted as a
+	// JSON object if the template is empty.
+	EventWebhookTemplate string `json:"event_webhook_template"`
+	// EventWebhookTypes are the event types posted by the webhook event sink. All types are
+	// posted if it's empty.
+	EventWebhookTypes []string `json:"event_webhook_types"`
+	// EventWebhookURL is the URL the webhook event sink posts events to.
+	EventWebhookURL string `json:"event_webhook_url"`
 	// Exporters contains the configuration for the included exporters.
 	Exporters *ExportersConfig `json:"exporters"`
+	// FailoverDeduplication drops the updates of a target's initial sync whose values are
+	// identical to the state replicated from the cluster member that previously owned the
+	// target, so that a failover doesn't re-send the entire tree to exporters.
+	FailoverDeduplication bool `json:"failover_deduplication"`
+	// FanoutWorkers is the number of goroutines that deliver updates from the cache to the
+	// gNMI server subscriptions and to exporters that support concurrent exports. The updates
+	// of each target are delivered by the same worker so that their order is preserved.
+	FanoutWorkers int `json:"fanout_workers"`
+	// FederationID identifies this gateway to the gateways it subscribes to
+	// with gateway targets (see the Gateway target meta field) and is used
+	// to detect federation loops. It defaults to the hostname.
+	FederationID string `json:"federation_id"`
 	// GatewayTransitionBufferSize tunes the size of the buffer between targets and exporters/clients.
 	GatewayTransitionBufferSize uint64 `json:"gateway_transition_buffer_size"`
 	// Log is the logger used by the gateway code and gateway packages.
 	Log zerolog.Logger
+	// InventoryFile is a JSON file that maps target names to inventory labels (e.g. site,
+	// role, and region). The labels are added to the outputs of exporters that support them.
+	InventoryFile string `json:"inventory_file"`
+	// InventoryLabels are the names of the inventory labels added to exporter outputs. If
+	// set, every output has all of these labels (empty if unknown) and no others.
+	InventoryLabels []string `json:"inventory_labels"`
+	// InventoryNetBox loads the site, role, and region of devices as inventory labels from
+	// the NetBox instance configured for the NetBox target loader.
+	InventoryNetBox bool `json:"inventory_netbox"`
+	// InventoryReloadInterval is the interval between reloads of the inventory labels.
+	InventoryReloadInterval time.Duration `json:"inventory_reload_interval"`
+	// LeafTTLs are per-path overrides of DefaultLeafTTL. The most specific matching
+	// path is used. A TTL of zero disables expiry for matching leaves.
+	LeafTTLs []LeafTTL `json:"leaf_ttls"`
+	// LeafTTLSweepInterval is the interval between checks for expired leaves.
+	LeafTTLSweepInterval time.Duration `json:"leaf_ttl_sweep_interval"`
 	// LogCaller will add the file path and line number to all log messages.
 	LogCaller bool `json:"log_caller"`
+	// MaintenanceWindows are the scheduled maintenance windows of targets. Updates from
+	// targets in a maintenance window are still cached but the window is published in the
+	// /meta/gateway/maintenance leaf, and exporters can suppress paths during maintenance
+	// with the maintenance_exclude_paths filter. MaintenanceWindows can only be set in the
+	// configuration file.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows"`
+	// Middlewares are the names of the registered middlewares that notifications received
+	// from targets pass through, in order, before they are inserted into the cache.
+	Middlewares []string `json:"middlewares"`
+	// NormalizeJSON decodes JSON and JSON_IETF encoded target update values into an update
+	// for each leaf before they are inserted into the cache. It can be overridden per target
+	// with the NormalizeJSON target meta field.
+	NormalizeJSON bool `json:"normalize_json"`
 	// OpenConfigDirectory is the folder path to a clone of github.com/openconfig/public.
 	// OpenConfigDirectory is required for value typing if any exporters are enabled.
 	OpenConfigDirectory string `json:"openconfig_directory"`
+	// PolicyQuery is the decision evaluated in the PolicyRegoFile policy for each gNMI client
+	// request. The default is "data.gnmi.allow".
+	PolicyQuery string `json:"policy_query"`
+	// PolicyRegoFile is the path of a Rego policy file that authorizes the targets and paths
+	// gNMI clients subscribe to. The file is reloaded when it changes.
+	PolicyRegoFile string `json:"policy_rego_file"`
+	// PolicyTimeout is the maximum time to wait for a policy decision. Requests are denied
+	// if the decision isn't made in time. Zero disables the timeout.
+	PolicyTimeout time.Duration `json:"policy_timeout"`
+	// PolicyURL is the URL of an Open Policy Agent document (e.g.
+	// http://localhost:8181/v1/data/gnmi/allow) that authorizes the targets and paths gNMI
+	// clients subscribe to. PolicyURL and PolicyRegoFile can't both be set.
+	PolicyURL string `json:"policy_url"`
+	// Redactions remove sensitive leaves, such as SNMP community strings or user
+	// tables, from the notifications of targets before they are inserted into the cache,
+	// so they never reach gNMI clients or exporters. Redactions can only be set in the
+	// configuration file.
+	Redactions []Redaction `json:"redactions"`
+	// RedisAddress is the host:port of a Redis server used instead of Zookeeper for
+	// clustering: target locks, exporter leader election, and cluster membership. Setting
+	// RedisAddress enables clustering and can't be combined with ZookeeperHosts. The Redis
+	// keys are prefixed with ZookeeperPrefix.
+	RedisAddress string `json:"redis_address"`
+	// RedisDB is the Redis database used for clustering.
+	RedisDB int `json:"redis_db"`
+	// RedisPassword is the password sent to the Redis server, if set.
+	RedisPassword string `json:"redis_password"`
+	// RedisTLS enables TLS for the connection to the Redis server.
+	RedisTLS bool `json:"redis_tls"`
+	// RedisTTL is the time after which the target locks and cluster member registration
+	// of a member that stopped renewing them expire. Locks and registrations are renewed
+	// every third of RedisTTL. Failover of targets will take up to RedisTTL.
+	RedisTTL time.Duration `json:"redis_ttl"`
+	// RetentionDuration is the amount of time notifications are retained in memory for each
+	// target. Retained notifications are served to gNMI clients that send Subscribe requests
+	// with the gNMI History extension. Zero disables retention.
+	RetentionDuration time.Duration `json:"retention_duration"`
+	// RetentionSize is the maximum number of notifications retained for each target.
+	RetentionSize int `json:"retention_size"`
+	// SchemaValidation is the mode used to validate target updates against the OpenConfig
+	// models in OpenConfigDirectory: "flag" counts and logs invalid updates and "drop" also
+	// removes them. It can be overridden per target with the SchemaValidation target meta
+	// field. Schema validation is disabled if SchemaValidation is empty or "off".
+	SchemaValidation string `json:"schema_validation"`
+	// SecretKeyFile is the path of a file containing the base64-encoded AES-256 key that
+	// decrypts target credentials encrypted with the "local" key provider. Create one with
+	// the encrypt-secret command.
+	SecretKeyFile string `json:"secret_key_file"`
+	// SecretKMSEndpoint overrides the AWS KMS API endpoint for SecretKMSRegion.
+	SecretKMSEndpoint string `json:"secret_kms_endpoint"`
+	// SecretKMSKeyID is the ID, ARN, or alias of the AWS KMS key that target credentials
+	// are encrypted with. It's only required to encrypt; the KMS ciphertext identifies the key.
+	SecretKMSKeyID string `json:"secret_kms_key_id"`
+	// SecretKMSRegion is the AWS region of the KMS key. AWS_REGION is used if it's not set.
+	// Credentials encrypted with the "awskms" key provider are decrypted with AWS KMS if
+	// SecretKMSKeyID or SecretKMSRegion is set.
+	SecretKMSRegion string `json:"secret_kms_region"`
 	// ServerAddress is the address where other cluster members can reach the gNMI server.
 	// The first assigned IP address is used if the parameter is not provided.
 	ServerAddress string `json:"server_address"`
 	// ServerPort is the TCP port where other cluster members can reach the gNMI server.
 	// ServerListenPort is used if the parameter is not provided.
 	ServerPort int `json:"server_port"`
+	// ServerAccessLog enables the access log of the gNMI server RPCs. Each RPC is logged as a
+	// JSON object with the peer address, the TLS client certificate common name or username,
+	// the subscription, the duration, the number of messages sent and received, and the
+	// reason the RPC ended. ServerAccessLog is the path of the file the entries are appended
+	// to, "stdout", "stderr", or "log" to use the gateway logger. Empty disables the access log.
+	ServerAccessLog string `json:"server_access_log"`
+	// ServerIdleHeartbeat is the amount of time a STREAM mode Subscribe RPC on the primary
+	// listener or the Unix socket may go without sending a response before a heartbeat
+	// message is sent, so that load balancers don't close quiet streams. Zero disables
+	// idle heartbeats. ServerListeners configure their own IdleHeartbeat.
+	ServerIdleHeartbeat time.Duration `json:"server_idle_heartbeat"`
+	// ServerIdleHeartbeatMessage is the heartbeat message sent to idle clients: "notification"
+	// sends a notification without updates for the subscribed target and "sync" sends a sync
+	// response. The default is "notification".
+	ServerIdleHeartbeatMessage string `json:"server_idle_heartbeat_message"`
+	// ServerKeepaliveMinTime is the minimum amount of time a gNMI client should wait before
+	// sending a keepalive ping. Clients that ping more frequently are disconnected. The gRPC
+	// default (5 minutes) is used if ServerKeepaliveMinTime is zero.
+	ServerKeepaliveMinTime time.Duration `json:"server_keepalive_min_time"`
+	// ServerKeepalivePermitWithoutStream allows gNMI clients to send keepalive pings when there
+	// are no active streams.
+	ServerKeepalivePermitWithoutStream bool `json:"server_keepalive_permit_without_stream"`
+	// ServerKeepaliveTime is the amount of time after which the gNMI server pings an idle client
+	// connection to check that it's alive. The gRPC default (2 hours) is used if zero.
+	ServerKeepaliveTime time.Duration `json:"server_keepalive_time"`
+	// ServerKeepaliveTimeout is the amount of time the gNMI server waits for a keepalive ping
+	// response before closing the connection. The gRPC default (20 seconds) is used if zero.
+	ServerKeepaliveTimeout time.Duration `json:"server_keepalive_timeout"`
+	// ServerMaxConcurrentStreams limits the number of concurrent streams (RPCs) for each gNMI
+	// client connection. Zero uses the gRPC default (no limit).
+	ServerMaxConcurrentStreams uint32 `json:"server_max_concurrent_streams"`
+	// ServerMaxConnectionAge is the maximum amount of time a gNMI client connection may exist
+	// before it's gracefully closed. Zero disables the limit.
+	ServerMaxConnectionAge time.Duration `json:"server_max_connection_age"`
+	// ServerMaxConnectionAgeGrace is the amount of time allowed for RPCs to complete after
+	// ServerMaxConnectionAge before the connection is forcibly closed. Zero disables the limit.
+	ServerMaxConnectionAgeGrace time.Duration `json:"server_max_connection_age_grace"`
+	// ServerMaxConnectionIdle is the amount of time a gNMI client connection may be idle (have
+	// no active RPCs) before it's closed. Zero disables the limit.
+	ServerMaxConnectionIdle time.Duration `json:"server_max_connection_idle"`
+	// ServerMaxRecvMsgSize is the maximum message size in bytes the gNMI server can receive.
+	// Zero uses the gRPC default (4MB).
+	ServerMaxRecvMsgSize int `json:"server_max_recv_msg_size"`
+	// ServerMaxSendMsgSize is the maximum message size in bytes the gNMI server can send.
+	// Zero uses the gRPC default (no limit).
+	ServerMaxSendMsgSize int `json:"server_max_send_msg_size"`
+	// ServerClientQueueLimit is the maximum number of streaming updates that may be queued
+	// for a single gNMI client. ServerSlowConsumerPolicy is applied when the limit is
+	// reached. Zero disables the limit.
+	ServerClientQueueLimit int `json:"server_client_queue_limit"`
+	// ServerSlowConsumerPolicy is the action taken when a gNMI client's queue is full:
+	// "coalesce" keeps updating leaves that are already queued and drops updates for
+	// new leaves, "drop-oldest" drops the oldest queued update, and "disconnect" closes
+	// the client's Subscribe RPC. The default is "coalesce".
+	ServerSlowConsumerPolicy string `json:"server_slow_consumer_policy"`
 	// ServerListenAddress is the interface IP address the gNMI server will listen on.
 	ServerListenAddress string `json:"server_listen_address"`
 	// ServerListenPort is the TCP port the gNMI server will listen on.
 	ServerListenPort int `json:"server_listen_port"`
+	// ServerListeners are additional addresses the gNMI server will listen on, each with
+	// its own transport security. ServerListeners can only be set in the configuration file.
+	ServerListeners []ServerListener `json:"server_listeners"`
+	// ServerReflection registers the gRPC reflection service on the gNMI server so that
+	// tools like grpcurl and evans can discover and call the gNMI service without the
+	// compiled protos.
+	ServerReflection bool `json:"server_reflection"`
+	// ServerSocketMode is the octal file mode of the Unix domain socket (e.g. "0660").
+	ServerSocketMode string `json:"server_socket_mode"`
+	// ServerSocketPath is the path of a Unix domain socket the gNMI server will listen on in
+	// addition to TCP. Connections over the socket don't use TLS so access should be restricted
+	// with ServerSocketMode.
+	ServerSocketPath string `json:"server_socket_path"`
 	// ServerTLSCreds are the gNMI Server TLS credentials. You must specify either this or both
 	// ServerTLSCert and ServerTLSKey if you set -EnableGNMIServer.
 	ServerTLSCreds credentials.TransportCredentials
@@ -73,6 +310,9 @@ type GatewayConfig struct {
 	// ServerTLSCert is the path to the file containing the PEM-encoded x509 gNMI server TLS key.
 	// See the gateway package for instructions for generating a self-signed certificate key.
 	ServerTLSKey string `json:"server_tls_key"`
+	// SNMPMappings map SNMP OIDs to gNMI paths for targets that use the snmp southbound
+	// adapter. SNMPMappings can only be set in the configuration file.
+	SNMPMappings []SNMPMapping `json:"snmp_mappings"`
 	// StatsSpectatorConfig is the configuration used for Spectator.
 	// Either this or StatsSpectatorURI must be set to enable sending internal
 	// gnmi-gateway metrics to Atlas.
@@ -81,15 +321,108 @@ type GatewayConfig struct {
 	// Either this or StatsSpectatorConfig must be set to enable sending internal
 	// gnmi-gateway metrics to Atlas.
 	StatsSpectatorURI string `json:"stats_spectator_uri"`
+	// SyntheticDeletes delete list entries from the cache once they're missing from
+	// consecutive full samples of targets that don't send deletes for removed entries.
+	SyntheticDeletes []SyntheticDelete `json:"synthetic_deletes"`
 	// TargetLoaders contains the configuration for the included target loaders.
 	TargetLoaders *TargetLoadersConfig `json:"target_loaders"`
+	// TargetCompression is the gRPC compression used for target connections (e.g. "gzip" or
+	// "zstd"). It can be overridden per target with the Compression target meta field.
+	// Compression is disabled if TargetCompression is empty.
+	TargetCompression string `json:"target_compression"`
+	// TargetAddressFamily is the preferred address family, "ipv4" or "ipv6", for targets with
+	// both IPv4 and IPv6 addresses. The family of the first address is preferred if
+	// TargetAddressFamily is empty. It can be overridden per target with the AddressFamily
+	// target meta field.
+	TargetAddressFamily string `json:"target_address_family"`
+	// TargetAuthFailureBackoff is the time waited before reconnecting to a target after an
+	// authentication failure. The wait doubles with each consecutive failure up to an hour.
+	TargetAuthFailureBackoff time.Duration `json:"target_auth_failure_backoff"`
+	// TargetAuthFailureLimit is the number of consecutive authentication failures after
+	// which a target is quarantined. Zero disables quarantine.
+	TargetAuthFailureLimit int `json:"target_auth_failure_limit"`
+	// TargetAuthFailureStop stops connection attempts to quarantined targets until the
+	// target configuration (e.g. the credentials) changes.
+	TargetAuthFailureStop bool `json:"target_auth_failure_stop"`
+	// TargetCapabilitiesProbe sends a Capabilities request to each target before subscribing
+	// to record the target's gNMI version, encodings, and models and to select an encoding
+	// supported by the target if the SubscribeRequest encoding isn't. It can be overridden
+	// per target with the CapabilitiesProbe target meta field.
+	TargetCapabilitiesProbe bool `json:"target_capabilities_probe"`
 	// TargetDialTimeout is the network transport timeout time for dialing the target connection.
+	// It can be overridden per target with the DialTimeout target meta field.
 	TargetDialTimeout time.Duration `json:"target_dial_timeout"`
+	// TargetDisabledFlush removes the cached data of targets when they are
+	// disabled (with the Disabled target meta field or the admin API).
+	// The data of disabled targets is kept in the cache by default.
+	TargetDisabledFlush bool `json:"target_disabled_flush"`
+	// TargetErrorHistory is the number of errors kept for each target, which are listed
+	// by the /targets/errors admin endpoint. The default is 10.
+	TargetErrorHistory int `json:"target_error_history"`
+	// TargetHappyEyeballsDelay is the time a connection attempt to a target with both IPv4
+	// and IPv6 addresses is given before the address of the other family is tried in
+	// parallel (RFC 8305). Zero tries the addresses one after the other. It can be
+	// overridden per target with the HappyEyeballsDelay target meta field.
+	TargetHappyEyeballsDelay time.Duration `json:"target_happy_eyeballs_delay"`
 	// TargetLimit is the maximum number of targets that this instance will connect to at once.
 	// TargetLimit can also be considered the number of "connection slots" available on this
 	// gateway instance. For failover of targets to other cluster members to complete fully
 	// there needs to be sufficient connection slots available on other cluster members.
 	TargetLimit int `json:"target_limit"`
+	// TargetLocalAddress is the local IP address target connections are made from. It can be
+	// overridden per target with the LocalAddress target meta field.
+	TargetLocalAddress string `json:"target_local_address"`
+	// TargetLocalInterface is the network interface or VRF device (e.g. a management VRF) that
+	// target connections are bound to. It can be overridden per target with the
+	// LocalInterface target meta field. Only supported on Linux.
+	TargetLocalInterface string `json:"target_local_interface"`
+	// TargetMetadataInterval is the interval between updates of the cache meta leaves (e.g.
+	// /meta/sync and /meta/latency) and the gateway meta leaves below /meta/gateway for each
+	// target. Meta leaves are not updated if TargetMetadataInterval is zero.
+	TargetMetadataInterval time.Duration `json:"target_metadata_interval"`
+	// TargetNetworkNamespace is the name of a network namespace (in /var/run/netns) or the path
+	// of a network namespace file that target connections are made in. It can be overridden
+	// per target with the NetworkNamespace target meta field. Only supported on Linux.
+	TargetNetworkNamespace string `json:"target_network_namespace"`
+	// TargetSubscribeRefresh is how long a target subscription is kept open before the
+	// target is reconnected, for devices that degrade on very long-lived streams. Zero keeps
+	// subscriptions open until they fail. It can be overridden per target with the
+	// SubscribeRefresh target meta field.
+	TargetSubscribeRefresh time.Duration `json:"target_subscribe_refresh"`
+	// TargetSyncTimeout is the time a target is given to send its initial sync_response
+	// after subscribing before it's reconnected. Zero waits indefinitely. It can be
+	// overridden per target with the SyncTimeout target meta field.
+	TargetSyncTimeout time.Duration `json:"target_sync_timeout"`
+	// Tenants enables the multi-tenant mode: targets are assigned to a tenant with the
+	// Tenant meta field and gNMI clients can only subscribe to the targets of the tenant
+	// their TLS client certificate is assigned to. Tenants can only be set in the
+	// configuration file.
+	Tenants []Tenant `json:"tenants"`
+	// TimestampFixUnits will convert notification timestamps that appear to be in seconds,
+	// milliseconds, or microseconds to nanoseconds.
+	TimestampFixUnits bool `json:"timestamp_fix_units"`
+	// TimestampMaxFuture is the maximum amount of time a notification timestamp may be
+	// ahead of the time it was received. Zero disables the check.
+	TimestampMaxFuture time.Duration `json:"timestamp_max_future"`
+	// TimestampMaxPast is the maximum amount of time a notification timestamp may be
+	// behind the time it was received. Zero disables the check.
+	TimestampMaxPast time.Duration `json:"timestamp_max_past"`
+	// TimestampPolicy is the action taken for notifications with timestamps outside of
+	// TimestampMaxPast and TimestampMaxFuture: "reject" drops the notification, "clamp"
+	// replaces the timestamp with the receive time, and "overwrite" replaces all timestamps
+	// with the receive time. Timestamps are not checked if TimestampPolicy is empty.
+	TimestampPolicy string `json:"timestamp_policy"`
+	// TracingOTLPEndpoint is the OTLP/HTTP traces URL of an OpenTelemetry collector (e.g.
+	// http://localhost:4318/v1/traces). Tracing is disabled if TracingOTLPEndpoint is empty.
+	TracingOTLPEndpoint string `json:"tracing_otlp_endpoint"`
+	// TracingSampleRatio is the fraction of traces that are exported, between 0 and 1.
+	TracingSampleRatio float64 `json:"tracing_sample_ratio"`
+	// TunnelListener is the address of the gRPC tunnel server
+	// (github.com/openconfig/grpctunnel) that targets behind NAT register with. Targets
+	// with the UseTunnel meta field are dialed through the tunnel. The listener uses the
+	// same TLS options as ServerListeners. The tunnel server is disabled if the Address
+	// is empty.
+	TunnelListener ServerListener `json:"tunnel_listener"`
 	// UpdateRejections are a list of gNMI paths that may be matched against for messages that
 	// are to be dropped prior to being inserted into the cache. This is useful for blocking
 	// portions of the tree that you are not interested in but still need a subscription for.
@@ -110,6 +443,35 @@ type ExportersConfig struct {
 	// Enabled contains the list of named exporters that should be started.
 	Enabled []string `json:"enabled"`
 
+	// Aggregations contains the downsampling configuration for exporters, by
+	// exporter name. Aggregations can only be set in the configuration file.
+	Aggregations map[string]ExporterAggregation `json:"aggregations"`
+
+	// ElasticsearchAddresses contains the list of Elasticsearch or OpenSearch
+	// URLs to send bulk requests to.
+	ElasticsearchAddresses []string `json:"elasticsearch_addresses"`
+	// ElasticsearchFlushInterval is the interval between bulk requests for
+	// documents that have changed.
+	ElasticsearchFlushInterval time.Duration `json:"elasticsearch_flush_interval"`
+	// ElasticsearchIndexes contains the path subtrees that are indexed as
+	// documents. ElasticsearchIndexes can only be set in the configuration file.
+	ElasticsearchIndexes []ElasticsearchIndex `json:"elasticsearch_indexes"`
+	// ElasticsearchPassword is the password for basic authentication.
+	ElasticsearchPassword string `json:"elasticsearch_password"`
+	// ElasticsearchUsername is the username for basic authentication.
+	ElasticsearchUsername string `json:"elasticsearch_username"`
+
+	// FileDirectory is the directory the file exporter writes files to.
+	FileDirectory string `json:"file_directory"`
+	// FileFormat is the format of the files written by the file exporter:
+	// "jsonl" (default), "jsonl.gz", or "parquet".
+	FileFormat string `json:"file_format"`
+
+	// Filters contains the path and target filters for exporters, by
+	// exporter name. Exporters without a filter receive every notification.
+	// Filters can only be set in the configuration file.
+	Filters map[string]ExporterFilter `json:"filters"`
+
 	// KafkaBatchBytes is the max message bytes that will be buffered before
 	// flushing messages to a partition.
 	KafkaBatchBytes int64 `json:"kafka_batch_bytes"`
@@ -129,6 +491,27 @@ type ExportersConfig struct {
 	// to.
 	KafkaTopic string `json:"kafka_topic"`
 
+	// KinesisBatchSize is the max number of records that will be buffered
+	// before they are written to Kinesis (max 500).
+	KinesisBatchSize int `json:"kinesis_batch_size"`
+	// KinesisBatchTimeout is the max amount of time that will pass before
+	// buffered records are written to Kinesis.
+	KinesisBatchTimeout time.Duration `json:"kinesis_batch_timeout"`
+	// KinesisEndpoint overrides the Kinesis API endpoint for the region.
+	KinesisEndpoint string `json:"kinesis_endpoint"`
+	// KinesisRegion is the AWS region of the Kinesis stream. If it's not set
+	// the AWS_REGION environment variable is used.
+	KinesisRegion string `json:"kinesis_region"`
+	// KinesisStream is the name of the Kinesis data stream to write exported
+	// gNMI messages to.
+	KinesisStream string `json:"kinesis_stream"`
+
+	// LeaderElectionInterval is the interval at which cluster members
+	// campaign for leadership of exporters that implement
+	// exporters.LeaderExporter. A new leader is elected within about one
+	// interval of the leader stopping or losing its Zookeeper session.
+	LeaderElectionInterval time.Duration `json:"leader_election_interval"`
+
 	// InfluxDBTarget is the target URL for influx connections
 	InfluxDBTarget string `json:"influxdb_target"`
 	// InfluxDBOrg is organizaion workspace
@@ -140,12 +523,261 @@ type ExportersConfig struct {
 	// InfluxDBBatchSize the number of lines or individual data points in a
 	// line protocol batch.
 	InfluxDBBatchSize uint `json:"influxdb_batch_size"`
+
+	// NATSAckTimeout is the max amount of time to wait for JetStream to
+	// acknowledge a published message before it is published again.
+	NATSAckTimeout time.Duration `json:"nats_ack_timeout"`
+	// NATSSubjectTemplate is the Go template used to build the NATS subject
+	// for each notification. See exporters/nats for the available fields.
+	NATSSubjectTemplate string `json:"nats_subject_template"`
+	// NATSURL is the nats:// or tls:// URL of the NATS server to publish to.
+	// Credentials may be included as user:password or as a token.
+	NATSURL string `json:"nats_url"`
+
+	// OTLPEndpoint is the OTLP/HTTP metrics endpoint of the OpenTelemetry
+	// collector to push metrics to, e.g. http://localhost:4318/v1/metrics.
+	OTLPEndpoint string `json:"otlp_endpoint"`
+	// OTLPInterval is the interval between pushes of updated metrics to the
+	// OTLP endpoint.
+	OTLPInterval time.Duration `json:"otlp_interval"`
+
+	// PrometheusSnapshot serves the Prometheus metrics from a snapshot of
+	// the cache taken for each scrape instead of from the exported updates,
+	// so the exporter doesn't keep its own copy of the metrics. Counters are
+	// then exported with the values reported by the targets.
+	PrometheusSnapshot bool `json:"prometheus_snapshot"`
+
+	// PubSubBatchSize is the max number of messages that will be buffered
+	// before they are published to Pub/Sub (max 1000).
+	PubSubBatchSize int `json:"pubsub_batch_size"`
+	// PubSubBatchTimeout is the max amount of time that will pass before
+	// buffered messages are published to Pub/Sub.
+	PubSubBatchTimeout time.Duration `json:"pubsub_batch_timeout"`
+	// PubSubEndpoint overrides the Pub/Sub API endpoint, e.g. to use a
+	// regional endpoint for ordered delivery.
+	PubSubEndpoint string `json:"pubsub_endpoint"`
+	// PubSubProject is the Google Cloud project of the Pub/Sub topic.
+	PubSubProject string `json:"pubsub_project"`
+	// PubSubTopic is the name of the Pub/Sub topic to publish exported gNMI
+	// messages to.
+	PubSubTopic string `json:"pubsub_topic"`
+
+	// SyncBarriers hold back the updates of targets from exporters until the
+	// targets have synced, by exporter name. SyncBarriers can only be set in
+	// the configuration file.
+	SyncBarriers map[string]ExporterSyncBarrier `json:"sync_barriers"`
+}
+
+// CounterRate computes the deltas and rates of monotonic counters and publishes them
+// as leaves next to each counter, named with the counter name plus a suffix.
+type CounterRate struct {
+	// Path is the XPath of the counters or of a subtree of counters, e.g.
+	// "/interfaces/interface/state/counters".
+	Path string `json:"path"`
+	// DeltaSuffix is appended to the counter name for the delta leaf, e.g. "-delta".
+	// Deltas are not published if DeltaSuffix is empty.
+	DeltaSuffix string `json:"delta_suffix"`
+	// RateSuffix is appended to the counter name for the per-second rate leaf. The
+	// default is "-rate" if neither suffix is set.
+	RateSuffix string `json:"rate_suffix"`
+	// ExportersOnly sends the derived leaves to exporters only instead of inserting
+	// them into the cache, where they are also visible to gNMI clients.
+	ExportersOnly bool `json:"exporters_only"`
+}
+
+// Tenant is a group of targets that is isolated from the targets of other
+// tenants on the gNMI server, which serves the clients of each tenant from a
+// cache that only holds the tenant's targets.
+type Tenant struct {
+	// Name is the value of the Tenant meta field of the tenant's targets.
+	Name string `json:"name"`
+	// Clients are the common names of the TLS client certificates of the gNMI
+	// clients that belong to the tenant.
+	Clients []string `json:"clients"`
+}
+
+// MaintenanceWindow is a recurring maintenance window for a group of targets.
+type MaintenanceWindow struct {
+	// Name identifies the window in the /meta/gateway/maintenance leaf.
+	Name string `json:"name"`
+	// Targets are the target names or patterns (e.g. "dc1-*") of the targets
+	// in the window.
+	Targets []string `json:"targets"`
+	// Schedule is the start time of the window as a cron expression with
+	// five fields: minute, hour, day of month, month, and day of week, e.g.
+	// "0 2 * * 6" for 02:00 every Saturday.
+	Schedule string `json:"schedule"`
+	// Duration is the length of the window.
+	Duration time.Duration `json:"duration"`
+	// Timezone is the IANA time zone of Schedule, e.g. "Europe/Amsterdam".
+	// The default is UTC.
+	Timezone string `json:"timezone"`
+}
+
+// ElasticsearchIndex indexes every instance of a path as a document.
+type ElasticsearchIndex struct {
+	// Path is the XPath of the documents, e.g.
+	// "/lldp/interfaces/interface/neighbors/neighbor". Each instance of the
+	// path (i.e. each set of keys) is a document.
+	Path string `json:"path"`
+	// Index is the name of the index, as a Go template with the fields
+	// .Target and .Origin, e.g. "lldp-neighbors" or "bgp-{{.Target}}".
+	Index string `json:"index"`
+}
+
+// ExporterAggregation downsamples the numeric updates sent to an exporter.
+type ExporterAggregation struct {
+	// Window is the interval that samples are aggregated over. Aggregation is
+	// disabled if Window isn't set.
+	Window time.Duration `json:"window"`
+	// Function is the aggregation function: "last" (default), "min", "max",
+	// "avg", or "delta" (the increase of a counter over the window).
+	Function string `json:"function"`
+	// Paths are the XPaths of the subtrees to aggregate. If Paths is empty
+	// all numeric leaves are aggregated.
+	Paths []string `json:"paths"`
+}
+
+// ExporterSyncBarrier holds back the updates of a target from an exporter until
+// the target has sent its initial sync, e.g. for exporters that compute deltas.
+type ExporterSyncBarrier struct {
+	// Mode is "ignore" to drop the updates of a target until it has synced or
+	// "buffer" to hold them and export them together once it has synced.
+	Mode string `json:"mode"`
+	// Limit is the maximum number of notifications buffered for a target. The
+	// buffered notifications are exported when the limit is reached. Defaults
+	// to 100000.
+	Limit int `json:"limit"`
+}
+
+// ExporterFilter selects the notifications that are sent to an exporter.
+type ExporterFilter struct {
+	// IncludePaths are the XPaths of the subtrees to export, e.g.
+	// "/interfaces/interface/state/counters". Elem names and key values may
+	// be "*". If IncludePaths is empty all paths are included.
+	IncludePaths []string `json:"include_paths"`
+	// ExcludePaths are the XPaths of subtrees to exclude from the included
+	// paths.
+	ExcludePaths []string `json:"exclude_paths"`
+	// IncludeTargets are the target name patterns (e.g. "core-*") to export.
+	// If IncludeTargets is empty all targets are included.
+	IncludeTargets []string `json:"include_targets"`
+	// ExcludeTargets are the target name patterns to exclude from the
+	// included targets.
+	ExcludeTargets []string `json:"exclude_targets"`
+	// MaintenanceExcludePaths are the XPaths of subtrees that aren't
+	// exported while a target is in a maintenance window, e.g. the paths
+	// that feed alerts.
+	MaintenanceExcludePaths []string `json:"maintenance_exclude_paths"`
+}
+
+// SNMPMapping maps an SNMP scalar or table column to a gNMI path.
+type SNMPMapping struct {
+	// Group is the name used to select mappings with the SNMPMappings target meta field.
+	// Targets without SNMPMappings use all of the mappings.
+	Group string `json:"group"`
+	// OID is the dotted OID of a scalar (e.g. "1.3.6.1.2.1.1.3.0") or table column (e.g.
+	// "1.3.6.1.2.1.2.2.1.10").
+	OID string `json:"oid"`
+	// Path is the XPath that values are inserted at. The OID is walked as a table column
+	// if Path contains "{index}", which is replaced with the index of each row.
+	Path string `json:"path"`
+	// IndexOID is an optional table column (e.g. ifName, "1.3.6.1.2.1.31.1.1.1.1") with
+	// the same indexes whose values replace "{index}" instead of the row index.
+	IndexOID string `json:"index_oid"`
+}
+
+// ServerListener is an additional listener for the gNMI server.
+type ServerListener struct {
+	// Address is the host and port to listen on (e.g. "127.0.0.1:9340" or "[::1]:9340").
+	Address string `json:"address"`
+	// ClientCA is the path to a PEM-encoded CA bundle. Setting ClientCA requires clients
+	// to present a certificate signed by one of the CAs (mTLS).
+	ClientCA string `json:"client_ca"`
+	// IdleHeartbeat is the amount of time a STREAM mode Subscribe RPC on the listener may
+	// go without sending a response before a heartbeat message is sent. Zero disables
+	// idle heartbeats. See ServerIdleHeartbeat.
+	IdleHeartbeat time.Duration `json:"idle_heartbeat"`
+	// IdleHeartbeatMessage is the heartbeat message sent to idle clients of the listener.
+	// See ServerIdleHeartbeatMessage.
+	IdleHeartbeatMessage string `json:"idle_heartbeat_message"`
+	// Insecure disables TLS for the listener. Insecure listeners should only be used on
+	// localhost.
+	Insecure bool `json:"insecure"`
+	// TLSCert is the path to the PEM-encoded x509 certificate for the listener.
+	// ServerTLSCert is used if TLSCert and TLSKey are empty.
+	TLSCert string `json:"tls_cert"`
+	// TLSKey is the path to the PEM-encoded x509 key for the listener.
+	// ServerTLSKey is used if TLSCert and TLSKey are empty.
+	TLSKey string `json:"tls_key"`
+}
+
+// ConnectionPool is a named group of targets with its own connection slot limit.
+type ConnectionPool struct {
+	// Name is the name of the pool used in the ConnectionPool target meta field.
+	Name string `json:"name"`
+	// Limit is the maximum number of targets in the pool that this instance will connect
+	// to at once.
+	Limit int `json:"limit"`
+	// Targets are shell patterns (e.g. "core-*") matched against target names to assign
+	// targets to the pool. The first matching pool is used.
+	Targets []string `json:"targets"`
+}
+
+// LeafTTL is the maximum amount of time leaves matching Path may go without being
+// updated before they are deleted from the cache.
+type LeafTTL struct {
+	// Path is an XPath prefix (e.g. /interfaces/interface[name=*]) that is matched
+	// against the full path of cached leaves. Key values of "*" match any value.
+	Path string `json:"path"`
+	// TTL is the maximum age of matching leaves.
+	TTL time.Duration `json:"ttl"`
+}
+
+// Redaction removes the leaves below a path from the notifications of targets.
+type Redaction struct {
+	// Path is an XPath prefix (e.g. /system/aaa/authentication/users) that is matched
+	// against the full path of received leaves. Key values of "*" match any value.
+	Path string `json:"path"`
+	// Targets are shell patterns matched against the target names. The redaction
+	// applies to all targets if Targets is empty.
+	Targets []string `json:"targets"`
+}
+
+// SyntheticDelete deletes the entries of a list from the cache once they are missing
+// from consecutive full samples of a target.
+type SyntheticDelete struct {
+	// Path is the XPath of the list (e.g.
+	// /network-instances/network-instance[name=*]/protocols/protocol[identifier=*][name=*]/bgp/neighbors/neighbor).
+	// Each entry of the list is identified by the key values of the last element.
+	Path string `json:"path"`
+	// Interval is the sample interval of the target's subscription to the list. A sample
+	// is the updates received within each Interval. If Interval is zero a sample is the
+	// updates received between sync responses, e.g. for POLL subscriptions. STREAM
+	// subscriptions only send one sync response, so for them a zero Interval defaults to
+	// the longest sample interval of the target's subscriptions.
+	Interval time.Duration `json:"interval"`
+	// Samples is the number of consecutive samples an entry must be missing from before
+	// it is deleted. The default is 2 so that an entry sent late in one sample and early
+	// in the next isn't deleted.
+	Samples int `json:"samples"`
+	// Targets are shell patterns matched against the target names. The rule applies to
+	// all targets if Targets is empty.
+	Targets []string `json:"targets"`
 }
 
 type TargetLoadersConfig struct {
 	// Enabled contains the list of named target loaders that should be started.
 	Enabled []string `json:"enabled"`
 
+	// DiscoveryFile is the path to the YAML file that configures the
+	// discovery loader: the seed targets, the allow list, and the template
+	// of discovered targets.
+	DiscoveryFile string `json:"discovery_file"`
+	// DiscoveryInterval is the interval to read the LLDP neighbors of the
+	// seed and discovered targets from the cache.
+	DiscoveryInterval time.Duration `json:"discovery_interval"`
+
 	// JSONFile is the path to a JSON file containing the configuration for
 	// gNMI targets and subscribe requests. The file will be checked for
 	// changes every TargetJSONFileReloadInterval.
@@ -153,6 +785,21 @@ type TargetLoadersConfig struct {
 	// JSONFileReloadInterval is the interval to check TargetJSONFile for changes.
 	JSONFileReloadInterval time.Duration `json:"json_file_reload_interval"`
 
+	// KubernetesAPIServer is the URL of the Kubernetes API server. If empty
+	// the API server and service account of the pod the gateway runs in are
+	// used.
+	KubernetesAPIServer string `json:"kubernetes_api_server"`
+	// KubernetesNamespace is the namespace to load GNMITarget resources
+	// from. Resources are loaded from all namespaces if it is empty.
+	KubernetesNamespace string `json:"kubernetes_namespace"`
+	// KubernetesReloadInterval is the interval after which the GNMITarget
+	// resources are listed again if no changes have been seen.
+	KubernetesReloadInterval time.Duration `json:"kubernetes_reload_interval"`
+	// KubernetesStatusInterval is the interval to write the connection
+	// status of each target to its GNMITarget resource. The status isn't
+	// written if KubernetesStatusInterval is zero.
+	KubernetesStatusInterval time.Duration `json:"kubernetes_status_interval"`
+
 	// NetBoxAPIKey is a valid API for the NetBox instance.
 	NetBoxAPIKey string
 	// NetBoxDeviceGNMIPort is the port on the device that the gNMI server is
@@ -192,15 +839,21 @@ func NewDefaultGatewayConfig() *GatewayConfig {
 	return config
 }
 
+// NewGatewayConfigFromFile returns the default configuration overridden by
+// the JSON or YAML configuration file at filePath.
 func NewGatewayConfigFromFile(filePath string) (*GatewayConfig, error) {
-	var config GatewayConfig
-	err := PopulateGatewayConfigFromFile(&config, filePath)
+	config := NewDefaultGatewayConfig()
+	err := PopulateGatewayConfigFromFile(config, filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new config from file: %v", err)
 	}
-	return &config, nil
+	return config, nil
 }
 
+// PopulateGatewayConfigFromFile overrides the fields of config that are set
+// in the JSON or YAML configuration file at filePath. Files ending in .yaml
+// or .yml are decoded as YAML. Unknown keys and values of the wrong type
+// are reported with the key they were found at.
 func PopulateGatewayConfigFromFile(config *GatewayConfig, filePath string) error {
 	path := filepath.Clean(filePath)
 	data, err := ioutil.ReadFile(path)
@@ -208,20 +861,8 @@ func PopulateGatewayConfigFromFile(config *GatewayConfig, filePath string) error
 		return fmt.Errorf("failed to read file at '%s': %v", path, err)
 	}
 
-	decoder := json.NewDecoder(bytes.NewReader(data))
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(config); err != nil {
+	if err := decodeConfigFile(config, path, data); err != nil {
 		return fmt.Errorf("failed to parse config file: %v", err)
 	}
-
-	if config.TargetDialTimeout < time.Second {
-		config.TargetDialTimeout *= time.Second
-	}
-	if config.TargetLoaders.JSONFileReloadInterval < time.Second {
-		config.TargetLoaders.JSONFileReloadInterval *= time.Second
-	}
-	if config.ZookeeperTimeout < time.Second {
-		config.ZookeeperTimeout *= time.Second
-	}
 	return nil
 }
@@ -0,0 +1,280 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ValidationError contains every problem found in a configuration. Each
+// problem is prefixed with the configuration key it refers to.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// decodeConfigFile decodes a JSON or YAML (selected by the file extension)
+// configuration file into config. Durations may be given either as a
+// string (e.g. "30s") or as a number; numbers below one second are
+// interpreted as seconds and larger numbers as nanoseconds.
+func decodeConfigFile(config *GatewayConfig, path string, data []byte) error {
+	var tree interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw interface{}
+		if err := yaml.UnmarshalStrict(data, &raw); err != nil {
+			return fmt.Errorf("failed to parse YAML: %v", err)
+		}
+		tree = stringKeys(raw)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&tree); err != nil {
+			return fmt.Errorf("failed to parse JSON: %v", err)
+		}
+	}
+	if tree == nil {
+		return nil
+	}
+
+	var problems []string
+	tree = normalize(tree, reflect.TypeOf(config).Elem(), "", &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	normalized, err := json.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("failed to encode normalized config: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(config)
+}
+
+// stringKeys converts the map[interface{}]interface{} values produced by
+// the YAML decoder into map[string]interface{} so that they can be
+// encoded as JSON.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			out[fmt.Sprint(key)] = stringKeys(elem)
+		}
+		return out
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = stringKeys(elem)
+		}
+	}
+	return value
+}
+
+// normalize checks value against the type it will be decoded into,
+// recording a problem for each unknown key or mismatched type, and
+// converts durations to nanoseconds.
+func normalize(value interface{}, t reflect.Type, key string, problems *[]string) interface{} {
+	if value == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	problem := func(format string, args ...interface{}) {
+		name := key
+		if name == "" {
+			name = "<root>"
+		}
+		*problems = append(*problems, name+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t == durationType {
+		if s, ok := value.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				problem("invalid duration %q", s)
+				return nil
+			}
+			return int64(d)
+		}
+		if number, ok := value.(json.Number); ok {
+			if n, err := number.Int64(); err == nil && n >= int64(time.Second) {
+				return n
+			}
+		}
+		n, ok := toFloat(value)
+		if !ok {
+			problem("expected a duration, got %s", describe(value))
+			return nil
+		}
+		if n < float64(time.Second) {
+			n *= float64(time.Second)
+		}
+		return int64(n)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			problem("expected a mapping, got %s", describe(value))
+			return nil
+		}
+		fields := jsonFields(t)
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field, exists := fields[name]
+			if !exists {
+				field, exists = fields[strings.ToLower(name)]
+			}
+			if !exists {
+				*problems = append(*problems, joinKey(key, name)+": unknown configuration key")
+				delete(m, name)
+				continue
+			}
+			m[name] = normalize(m[name], field.Type, joinKey(key, name), problems)
+		}
+		return m
+	case reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			problem("expected a mapping, got %s", describe(value))
+			return nil
+		}
+		for name, elem := range m {
+			m[name] = normalize(elem, t.Elem(), joinKey(key, name), problems)
+		}
+		return m
+	case reflect.Slice:
+		s, ok := value.([]interface{})
+		if !ok {
+			problem("expected a list, got %s", describe(value))
+			return nil
+		}
+		for i, elem := range s {
+			s[i] = normalize(elem, t.Elem(), fmt.Sprintf("%s[%d]", key, i), problems)
+		}
+		return s
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			problem("expected a string, got %s", describe(value))
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			problem("expected a boolean, got %s", describe(value))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toFloat(value)
+		if !ok || n != float64(int64(n)) {
+			problem("expected an integer, got %s", describe(value))
+		} else if n < 0 && t.Kind() >= reflect.Uint {
+			problem("must not be negative")
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := toFloat(value); !ok {
+			problem("expected a number, got %s", describe(value))
+		}
+	}
+	return value
+}
+
+// jsonFields returns the fields of t indexed by their JSON key as well as
+// the lowercased JSON key, matching the case-insensitive behavior of
+// encoding/json.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = field
+		if _, exists := fields[strings.ToLower(name)]; !exists {
+			fields[strings.ToLower(name)] = field
+		}
+	}
+	return fields
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case map[string]interface{}:
+		return "a mapping"
+	case []interface{}:
+		return "a list"
+	}
+	if n, ok := toFloat(value); ok {
+		return fmt.Sprintf("number %v", n)
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name string, contents string) string {
+	dir, err := ioutil.TempDir("", "gateway-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewGatewayConfigFromFile_YAML(t *testing.T) {
+	assertion := assert.New(t)
+	path := writeConfigFile(t, "config.yaml", `
+server_listen_port: 9339
+target_dial_timeout: 30s
+zookeeper_timeout: 2
+leaf_ttls:
+  - path: /interfaces
+    ttl: 1m
+exporters:
+  enabled: [kafka]
+  aggregations:
+    kafka:
+      window: 500ms
+      function: avg
+target_loaders:
+  NetBoxReloadInterval: 5m
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	config, err := NewGatewayConfigFromFile(path)
+	if !assertion.NoError(err) {
+		return
+	}
+	assertion.Equal(9339, config.ServerListenPort)
+	assertion.Equal(30*time.Second, config.TargetDialTimeout)
+	assertion.Equal(2*time.Second, config.ZookeeperTimeout)
+	assertion.Equal([]LeafTTL{{Path: "/interfaces", TTL: time.Minute}}, config.LeafTTLs)
+	assertion.Equal([]string{"kafka"}, config.Exporters.Enabled)
+	assertion.Equal(500*time.Millisecond, config.Exporters.Aggregations["kafka"].Window)
+	assertion.Equal(5*time.Minute, config.TargetLoaders.NetBoxReloadInterval)
+}
+
+func TestNewGatewayConfigFromFile_JSON(t *testing.T) {
+	assertion := assert.New(t)
+	path := writeConfigFile(t, "config.json", `{
+		"clock_skew_threshold": 5,
+		"retention_duration": "10m",
+		"server_keepalive_time": 30000000000,
+		"tracing_sample_ratio": 0.5
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	config, err := NewGatewayConfigFromFile(path)
+	if !assertion.NoError(err) {
+		return
+	}
+	assertion.Equal(5*time.Second, config.ClockSkewThreshold)
+	assertion.Equal(10*time.Minute, config.RetentionDuration)
+	assertion.Equal(30*time.Second, config.ServerKeepaliveTime)
+	assertion.Equal(0.5, config.TracingSampleRatio)
+	assertion.NotNil(config.Exporters)
+	assertion.NotNil(config.TargetLoaders)
+}
+
+func TestNewGatewayConfigFromFile_Errors(t *testing.T) {
+	assertion := assert.New(t)
+	path := writeConfigFile(t, "config.yml", `
+server_port: "9339"
+target_dial_timeout: soon
+exporters:
+  kafka_topc: gnmi
+leaf_ttls:
+  - path: /interfaces
+    ttl: [1]
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := NewGatewayConfigFromFile(path)
+	if !assertion.Error(err) {
+		return
+	}
+	assertion.Contains(err.Error(), "exporters.kafka_topc: unknown configuration key")
+	assertion.Contains(err.Error(), `leaf_ttls[0].ttl: expected a duration, got a list`)
+	assertion.Contains(err.Error(), `server_port: expected an integer, got string "9339"`)
+	assertion.Contains(err.Error(), `target_dial_timeout: invalid duration "soon"`)
+}
+
+func TestGatewayConfig_Validate(t *testing.T) {
+	assertion := assert.New(t)
+	config := NewDefaultGatewayConfig()
+	assertion.NoError(config.Validate())
+
+	config.ServerPort = 70000
+	config.TracingSampleRatio = 2
+	config.TargetDialTimeout = -time.Second
+	config.ConnectionPools = []ConnectionPool{{Name: "a", Limit: 1}, {Name: "a"}}
+	config.Exporters.Enabled = []string{"kafka", "kafka"}
+	config.Exporters.OTLPEndpoint = "localhost:4318"
+	config.RedisAddress = "localhost:6379"
+	config.ZookeeperHosts = []string{"localhost:2181"}
+
+	err := config.Validate()
+	validationErr, ok := err.(*ValidationError)
+	if !assertion.True(ok) {
+		return
+	}
+	assertion.Equal([]string{
+		"target_dial_timeout: must not be negative",
+		"server_port: port 70000 is out of range",
+		"tracing_sample_ratio: must be between 0 and 1",
+		`connection_pools[1].name: duplicate connection pool "a"`,
+		"connection_pools[1].limit: must be greater than 0",
+		"redis_address: can't be used together with zookeeper_hosts",
+		`exporters.enabled: "kafka" is listed more than once`,
+		`exporters.otlp_endpoint: invalid URL "localhost:4318"`,
+	}, validationErr.Problems)
+}
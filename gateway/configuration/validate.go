@@ -0,0 +1,259 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Validate checks the configuration for values that are out of range or
+// malformed. All of the problems that are found are returned together in a
+// *ValidationError. Validate does not check values that are only
+// meaningful to other packages, such as exporter or target loader names.
+func (c *GatewayConfig) Validate() error {
+	var problems []string
+	problem := func(key string, format string, args ...interface{}) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	negativeDurations(reflect.ValueOf(c).Elem(), "", &problems)
+
+	for _, port := range []struct {
+		key   string
+		value int
+	}{
+		{"server_port", c.ServerPort},
+		{"server_listen_port", c.ServerListenPort},
+	} {
+		if port.value < 0 || port.value > 65535 {
+			problem(port.key, "port %d is out of range", port.value)
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		problem("tracing_sample_ratio", "must be between 0 and 1")
+	}
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"cache_shards", c.CacheShards},
+		{"fanout_workers", c.FanoutWorkers},
+		{"retention_size", c.RetentionSize},
+		{"server_client_queue_limit", c.ServerClientQueueLimit},
+		{"server_max_recv_msg_size", c.ServerMaxRecvMsgSize},
+		{"server_max_send_msg_size", c.ServerMaxSendMsgSize},
+		{"target_auth_failure_limit", c.TargetAuthFailureLimit},
+		{"target_error_history", c.TargetErrorHistory},
+		{"target_limit", c.TargetLimit},
+	} {
+		if limit.value < 0 {
+			problem(limit.key, "must not be negative")
+		}
+	}
+	checkURL(&problems, "tracing_otlp_endpoint", c.TracingOTLPEndpoint)
+
+	pools := make(map[string]bool)
+	for i, pool := range c.ConnectionPools {
+		key := fmt.Sprintf("connection_pools[%d]", i)
+		if pool.Name == "" {
+			problem(key+".name", "must be set")
+		} else if pools[pool.Name] {
+			problem(key+".name", "duplicate connection pool %q", pool.Name)
+		}
+		pools[pool.Name] = true
+		if pool.Limit <= 0 {
+			problem(key+".limit", "must be greater than 0")
+		}
+	}
+	for i, rate := range c.CounterRates {
+		if rate.Path == "" {
+			problem(fmt.Sprintf("counter_rates[%d].path", i), "must be set")
+		}
+	}
+	for i, ttl := range c.LeafTTLs {
+		if ttl.Path == "" {
+			problem(fmt.Sprintf("leaf_ttls[%d].path", i), "must be set")
+		}
+	}
+	for i, redaction := range c.Redactions {
+		if redaction.Path == "" {
+			problem(fmt.Sprintf("redactions[%d].path", i), "must be set")
+		}
+	}
+	for i, rule := range c.SyntheticDeletes {
+		key := fmt.Sprintf("synthetic_deletes[%d]", i)
+		if rule.Path == "" {
+			problem(key+".path", "must be set")
+		}
+		if rule.Samples < 0 {
+			problem(key+".samples", "must not be negative")
+		}
+	}
+	for i, window := range c.MaintenanceWindows {
+		key := fmt.Sprintf("maintenance_windows[%d]", i)
+		if window.Name == "" {
+			problem(key+".name", "must be set")
+		}
+		if window.Schedule == "" {
+			problem(key+".schedule", "must be set")
+		}
+		if window.Duration <= 0 {
+			problem(key+".duration", "must be greater than 0")
+		}
+	}
+	var tenants, tenantClients []string
+	for i, tenant := range c.Tenants {
+		key := fmt.Sprintf("tenants[%d]", i)
+		if tenant.Name == "" {
+			problem(key+".name", "must be set")
+		}
+		if len(tenant.Clients) == 0 {
+			problem(key+".clients", "must not be empty")
+		}
+		tenants = append(tenants, tenant.Name)
+		tenantClients = append(tenantClients, tenant.Clients...)
+	}
+	checkDuplicates(&problems, "tenants", tenants)
+	checkDuplicates(&problems, "tenants.clients", tenantClients)
+	for i, listener := range c.ServerListeners {
+		key := fmt.Sprintf("server_listeners[%d]", i)
+		if listener.Address == "" {
+			problem(key+".address", "must be set")
+		}
+		if !listener.Insecure && (listener.TLSCert == "") != (listener.TLSKey == "") {
+			problem(key, "tls_cert and tls_key must be set together")
+		}
+	}
+	if l := c.TunnelListener; l.Address != "" && !l.Insecure && (l.TLSCert == "") != (l.TLSKey == "") {
+		problem("tunnel_listener", "tls_cert and tls_key must be set together")
+	}
+	if (c.ServerTLSCert == "") != (c.ServerTLSKey == "") {
+		problem("server_tls_cert", "server_tls_cert and server_tls_key must be set together")
+	}
+	if c.RedisAddress != "" && len(c.ZookeeperHosts) > 0 {
+		problem("redis_address", "can't be used together with zookeeper_hosts")
+	}
+
+	if c.Exporters != nil {
+		checkDuplicates(&problems, "exporters.enabled", c.Exporters.Enabled)
+		for i, address := range c.Exporters.ElasticsearchAddresses {
+			checkURL(&problems, fmt.Sprintf("exporters.elasticsearch_addresses[%d]", i), address)
+		}
+		for i, index := range c.Exporters.ElasticsearchIndexes {
+			key := fmt.Sprintf("exporters.elasticsearch_indexes[%d]", i)
+			if index.Path == "" {
+				problem(key+".path", "must be set")
+			}
+			if index.Index == "" {
+				problem(key+".index", "must be set")
+			}
+		}
+		checkURL(&problems, "exporters.kinesis_endpoint", c.Exporters.KinesisEndpoint)
+		checkURL(&problems, "exporters.nats_url", c.Exporters.NATSURL)
+		checkURL(&problems, "exporters.otlp_endpoint", c.Exporters.OTLPEndpoint)
+		checkURL(&problems, "exporters.pubsub_endpoint", c.Exporters.PubSubEndpoint)
+		for _, size := range []struct {
+			key   string
+			value int
+		}{
+			{"exporters.kafka_batch_size", c.Exporters.KafkaBatchSize},
+			{"exporters.kinesis_batch_size", c.Exporters.KinesisBatchSize},
+			{"exporters.pubsub_batch_size", c.Exporters.PubSubBatchSize},
+		} {
+			if size.value < 0 {
+				problem(size.key, "must not be negative")
+			}
+		}
+	}
+	if c.TargetLoaders != nil {
+		checkDuplicates(&problems, "target_loaders.enabled", c.TargetLoaders.Enabled)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+var packagePath = reflect.TypeOf(GatewayConfig{}).PkgPath()
+
+// negativeDurations records a problem for each negative time.Duration
+// found in v. Only the structs of this package are walked.
+func negativeDurations(v reflect.Value, key string, problems *[]string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			negativeDurations(v.Elem(), key, problems)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		if t.PkgPath() != packagePath {
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			negativeDurations(v.Field(i), joinKey(key, name), problems)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			negativeDurations(v.Index(i), fmt.Sprintf("%s[%d]", key, i), problems)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+		for _, k := range keys {
+			negativeDurations(v.MapIndex(k), joinKey(key, fmt.Sprint(k.Interface())), problems)
+		}
+	case reflect.Int64:
+		if v.Type() == durationType && v.Int() < 0 {
+			*problems = append(*problems, key+": must not be negative")
+		}
+	}
+}
+
+func checkURL(problems *[]string, key string, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		*problems = append(*problems, fmt.Sprintf("%s: invalid URL %q", key, value))
+	}
+}
+
+func checkDuplicates(problems *[]string, key string, names []string) {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			*problems = append(*problems, fmt.Sprintf("%s: %q is listed more than once", key, name))
+		}
+		seen[name] = true
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+
+	"github.com/openconfig/gnmi/client"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// MetaProtocol is the target meta field that selects the southbound adapter
+// used to connect to a non-gNMI target (e.g. "netconf"). gNMI is used if
+// Protocol is not set or is "gnmi".
+const MetaProtocol = "Protocol"
+
+// ProtocolGNMI is the default southbound protocol.
+const ProtocolGNMI = "gnmi"
+
+// AdapterRegistry contains the southbound adapters by protocol name.
+var AdapterRegistry = make(map[string]NewAdapterFunc)
+
+// NewAdapterFunc creates a southbound adapter client for a target.
+//
+// Southbound adapters connect to targets that don't support gNMI. The
+// adapter's Subscribe method receives the same client.Query a gNMI target
+// would: the target addresses, credentials, TLS configuration, and the
+// target's SubscribeRequest. The adapter converts the data it receives from
+// the target into gNMI SubscribeResponses and passes them to the query's
+// ProtoHandler so that they're processed and cached the same way as data
+// from gNMI targets. A SubscribeResponse with SyncResponse set should be
+// passed once the initial state of the target has been sent. Subscribe
+// should block until ctx is canceled or the connection fails; the connection
+// manager reconnects when Subscribe returns.
+type NewAdapterFunc func(config *configuration.GatewayConfig, target *targetpb.Target) (client.Client, error)
+
+// RegisterAdapter adds a southbound adapter for the named protocol.
+func RegisterAdapter(protocol string, new NewAdapterFunc) {
+	AdapterRegistry[protocol] = new
+}
+
+// newTargetClient creates the client for the target's protocol.
+func (t *ConnectionState) newTargetClient() (client.Client, error) {
+	protocol, exists := t.target.Meta[MetaProtocol]
+	if !exists || protocol == ProtocolGNMI {
+		return t.newClient()
+	}
+	newAdapter, exists := AdapterRegistry[protocol]
+	if !exists {
+		return nil, fmt.Errorf("unknown %s '%s'", MetaProtocol, protocol)
+	}
+	adapter, err := newAdapter(t.config, t.target)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s adapter: %v", protocol, err)
+	}
+	return adapter, nil
+}
+
+// validateOptions checks the connection options and redactions in the target
+// meta without connecting to the target. Adapter options are checked by the
+// adapter when it's created.
+func (t *ConnectionState) validateOptions() error {
+	if _, err := parseRedactions(t.config, t.name, t.target.Meta); err != nil {
+		return err
+	}
+	protocol, exists := t.target.Meta[MetaProtocol]
+	if !exists || protocol == ProtocolGNMI {
+		_, err := t.newClient()
+		return err
+	}
+	if _, exists := AdapterRegistry[protocol]; !exists {
+		return fmt.Errorf("unknown %s '%s'", MetaProtocol, protocol)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxAuthFailureBackoff is the longest time waited between connection
+// attempts after authentication failures.
+const maxAuthFailureBackoff = time.Hour
+
+// authTracker counts consecutive authentication failures for a target and
+// decides how long to wait before the next connection attempt.
+type authTracker struct {
+	// backoff is the wait after the first authentication failure. It doubles
+	// with each consecutive failure up to maxAuthFailureBackoff.
+	backoff time.Duration
+	// limit is the number of consecutive failures after which the target is
+	// quarantined. Zero disables quarantine.
+	limit int
+	// stop disables connection attempts once the target is quarantined.
+	stop bool
+	// onQuarantine is called when the target enters or leaves quarantine.
+	onQuarantine func(quarantined bool, err error)
+
+	failures int
+	retryAt  time.Time
+}
+
+// isAuthError returns true if err is a gRPC Unauthenticated or
+// PermissionDenied error. The gNMI client wraps some errors as strings so the
+// error message is checked as well.
+func isAuthError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "code = "+codes.Unauthenticated.String()) ||
+		strings.Contains(msg, "code = "+codes.PermissionDenied.String())
+}
+
+// wait blocks until the next connection attempt is allowed or ctx is done.
+func (a *authTracker) wait(ctx context.Context) error {
+	if a.stop && a.quarantined() {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	delay := time.Until(a.retryAt)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// connected resets the failure count after a successful subscription.
+func (a *authTracker) connected() {
+	wasQuarantined := a.quarantined()
+	a.failures = 0
+	a.retryAt = time.Time{}
+	if wasQuarantined && a.onQuarantine != nil {
+		a.onQuarantine(false, nil)
+	}
+}
+
+// failed records the error that ended a connection attempt. Returns true if
+// the error was an authentication failure.
+func (a *authTracker) failed(err error) bool {
+	if err == nil || !isAuthError(err) {
+		return false
+	}
+	a.failures++
+	delay := a.backoff
+	for i := 1; i < a.failures && delay < maxAuthFailureBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxAuthFailureBackoff {
+		delay = maxAuthFailureBackoff
+	}
+	a.retryAt = time.Now().Add(delay)
+	if a.limit > 0 && a.failures == a.limit && a.onQuarantine != nil {
+		a.onQuarantine(true, err)
+	}
+	return true
+}
+
+// quarantined returns true if the consecutive failures reached the limit.
+func (a *authTracker) quarantined() bool {
+	return a.limit > 0 && a.failures >= a.limit
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsAuthError(t *testing.T) {
+	assertion := assert.New(t)
+
+	unauthenticated := status.Error(codes.Unauthenticated, "bad password")
+	assertion.True(isAuthError(unauthenticated))
+	assertion.True(isAuthError(status.Error(codes.PermissionDenied, "denied")))
+	assertion.True(isAuthError(fmt.Errorf("client.Subscribe: %v", unauthenticated)))
+	assertion.False(isAuthError(status.Error(codes.Unavailable, "connection refused")))
+	assertion.False(isAuthError(errors.New("EOF")))
+}
+
+func TestAuthTracker(t *testing.T) {
+	assertion := assert.New(t)
+
+	var quarantined []bool
+	tracker := &authTracker{
+		backoff: time.Minute,
+		limit:   2,
+		stop:    true,
+		onQuarantine: func(q bool, _ error) {
+			quarantined = append(quarantined, q)
+		},
+	}
+	authErr := status.Error(codes.Unauthenticated, "bad password")
+
+	assertion.False(tracker.failed(errors.New("connection refused")))
+	assertion.True(tracker.failed(authErr))
+	assertion.WithinDuration(time.Now().Add(time.Minute), tracker.retryAt, time.Second)
+	assertion.False(tracker.quarantined())
+
+	assertion.True(tracker.failed(authErr))
+	assertion.WithinDuration(time.Now().Add(2*time.Minute), tracker.retryAt, time.Second)
+	assertion.True(tracker.quarantined())
+	assertion.Equal([]bool{true}, quarantined)
+
+	// Quarantined targets with stop set don't retry until ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assertion.Error(tracker.wait(ctx))
+
+	tracker.connected()
+	assertion.False(tracker.quarantined())
+	assertion.NoError(tracker.wait(context.Background()))
+	assertion.Equal([]bool{true, false}, quarantined)
+
+	for i := 0; i < 10; i++ {
+		tracker.failed(authErr)
+	}
+	assertion.WithinDuration(time.Now().Add(maxAuthFailureBackoff), tracker.retryAt, time.Second)
+}
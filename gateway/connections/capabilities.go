@@ -0,0 +1,158 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetaCapabilitiesProbe is the target meta field that enables ("true") or
+// disables ("false") the Capabilities probe for the target, overriding
+// TargetCapabilitiesProbe in GatewayConfig.
+const MetaCapabilitiesProbe = "CapabilitiesProbe"
+
+// preferredEncodings is the order in which encodings are selected if the
+// target doesn't support the encoding in the SubscribeRequest.
+var preferredEncodings = []gnmipb.Encoding{
+	gnmipb.Encoding_PROTO,
+	gnmipb.Encoding_JSON_IETF,
+	gnmipb.Encoding_JSON,
+	gnmipb.Encoding_BYTES,
+	gnmipb.Encoding_ASCII,
+}
+
+// TargetCapabilities is the result of the Capabilities probe for a target.
+type TargetCapabilities struct {
+	// GNMIVersion is the gNMI version supported by the target.
+	GNMIVersion string `json:"gnmiVersion"`
+	// Encodings are the encodings supported by the target.
+	Encodings []string `json:"encodings"`
+	// Encoding is the encoding used for the SubscribeRequest.
+	Encoding string `json:"encoding"`
+	// Models are the YANG models supported by the target.
+	Models []TargetModel `json:"models"`
+}
+
+// TargetModel is a YANG model supported by a target.
+type TargetModel struct {
+	Name         string `json:"name"`
+	Organization string `json:"organization,omitempty"`
+	Version      string `json:"version,omitempty"`
+}
+
+// probeCapabilities sends a Capabilities request to the target and returns a
+// copy of the query with the SubscribeRequest encoding set to one supported by
+// the target.
+func probeCapabilities(ctx context.Context, conn *grpc.ClientConn, q client.Query) (client.Query, *TargetCapabilities, error) {
+	if q.Credentials != nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, "username", q.Credentials.Username, "password", q.Credentials.Password)
+	}
+	if q.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.Timeout)
+		defer cancel()
+	}
+	resp, err := gnmipb.NewGNMIClient(conn).Capabilities(ctx, &gnmipb.CapabilityRequest{})
+	if err != nil {
+		return q, nil, fmt.Errorf("capabilities request failed: %v", err)
+	}
+
+	capabilities := &TargetCapabilities{GNMIVersion: resp.GetGNMIVersion()}
+	for _, encoding := range resp.GetSupportedEncodings() {
+		capabilities.Encodings = append(capabilities.Encodings, encoding.String())
+	}
+	for _, model := range resp.GetSupportedModels() {
+		capabilities.Models = append(capabilities.Models, TargetModel{
+			Name:         model.GetName(),
+			Organization: model.GetOrganization(),
+			Version:      model.GetVersion(),
+		})
+	}
+
+	subscribe := q.SubReq.GetSubscribe()
+	if subscribe == nil {
+		return q, capabilities, nil
+	}
+	encoding := selectEncoding(subscribe.GetEncoding(), resp.GetSupportedEncodings())
+	capabilities.Encoding = encoding.String()
+	if encoding != subscribe.GetEncoding() {
+		q.SubReq = proto.Clone(q.SubReq).(*gnmipb.SubscribeRequest)
+		q.SubReq.GetSubscribe().Encoding = encoding
+	}
+	return q, capabilities, nil
+}
+
+// selectEncoding returns requested if it's supported or the first supported
+// preferred encoding otherwise. requested is returned if the target didn't
+// report any encodings.
+func selectEncoding(requested gnmipb.Encoding, supported []gnmipb.Encoding) gnmipb.Encoding {
+	if len(supported) == 0 {
+		return requested
+	}
+	isSupported := make(map[gnmipb.Encoding]bool, len(supported))
+	for _, encoding := range supported {
+		isSupported[encoding] = true
+	}
+	if isSupported[requested] {
+		return requested
+	}
+	for _, encoding := range preferredEncodings {
+		if isSupported[encoding] {
+			return encoding
+		}
+	}
+	return supported[0]
+}
+
+// capabilitiesProbeEnabled returns true if the Capabilities probe is enabled
+// for the target.
+func (t *ConnectionState) capabilitiesProbeEnabled() (bool, error) {
+	enabled := t.config.TargetCapabilitiesProbe
+	if value, exists := t.target.Meta[MetaCapabilitiesProbe]; exists {
+		var err error
+		enabled, err = strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s '%s': %v", MetaCapabilitiesProbe, value, err)
+		}
+	}
+	return enabled, nil
+}
+
+// setCapabilities records the result of the Capabilities probe.
+func (t *ConnectionState) setCapabilities(capabilities *TargetCapabilities) {
+	t.capabilitiesMutex.Lock()
+	previous := t.capabilities
+	t.capabilities = capabilities
+	t.capabilitiesMutex.Unlock()
+	if previous == nil || previous.Encoding != capabilities.Encoding {
+		t.config.Log.Info().Msgf("Target %s: gNMI %s, using %s encoding (supported: %v)", t.name, capabilities.GNMIVersion, capabilities.Encoding, capabilities.Encodings)
+	}
+}
+
+// Capabilities returns the result of the last Capabilities probe, if any.
+func (t *ConnectionState) Capabilities() *TargetCapabilities {
+	t.capabilitiesMutex.Lock()
+	defer t.capabilitiesMutex.Unlock()
+	return t.capabilities
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectEncoding(t *testing.T) {
+	assertion := assert.New(t)
+
+	assertion.Equal(gnmipb.Encoding_JSON, selectEncoding(gnmipb.Encoding_JSON, nil))
+	assertion.Equal(gnmipb.Encoding_JSON, selectEncoding(gnmipb.Encoding_JSON, []gnmipb.Encoding{gnmipb.Encoding_PROTO, gnmipb.Encoding_JSON}))
+	assertion.Equal(gnmipb.Encoding_PROTO, selectEncoding(gnmipb.Encoding_JSON, []gnmipb.Encoding{gnmipb.Encoding_JSON_IETF, gnmipb.Encoding_PROTO}))
+	assertion.Equal(gnmipb.Encoding_JSON_IETF, selectEncoding(gnmipb.Encoding_PROTO, []gnmipb.Encoding{gnmipb.Encoding_JSON_IETF}))
+}
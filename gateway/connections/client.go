@@ -0,0 +1,553 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	gnmiclient "github.com/openconfig/gnmi/client/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip"
+
+	_ "github.com/openconfig/gnmi-gateway/gateway/encoding/zstd"
+	"github.com/openconfig/gnmi-gateway/gateway/tracing"
+)
+
+// Target meta fields used to configure the target connection.
+const (
+	// MetaCompression sets the gRPC compression (e.g. "gzip" or "zstd") used
+	// for the target connection, overriding TargetCompression in GatewayConfig.
+	// A value of "none" disables compression.
+	MetaCompression = "Compression"
+	// MetaAddressPolicy sets the order in which the target addresses are
+	// tried: AddressPolicyOrdered (the default) or AddressPolicyRoundRobin.
+	MetaAddressPolicy = "AddressPolicy"
+	// MetaFailbackInterval is how often the preferred (first) address is
+	// checked while connected to another address with the ordered policy. The
+	// connection is moved back to the preferred address once it's reachable.
+	// The value is a duration; the default is defaultFailbackInterval and "0"
+	// disables failback.
+	MetaFailbackInterval = "FailbackInterval"
+)
+
+// Address policies for targets with multiple addresses.
+const (
+	// AddressPolicyOrdered tries the addresses in order on every connection
+	// attempt so that the first reachable address is always used.
+	AddressPolicyOrdered = "ordered"
+	// AddressPolicyRoundRobin starts each connection attempt at the address
+	// after the one that was last connected to.
+	AddressPolicyRoundRobin = "round-robin"
+)
+
+// defaultFailbackInterval is used if MetaFailbackInterval isn't set.
+const defaultFailbackInterval = time.Minute
+
+// gatewayClient is a client.Client for gNMI targets. Unlike client.BaseClient
+// it dials the target itself so that the gateway controls the gRPC dial and
+// call options used for the connection.
+type gatewayClient struct {
+	dialOptions []grpc.DialOption
+	// addressPolicy is the order in which addresses are tried.
+	addressPolicy string
+	// preferredFamily is the address family tried first, if set.
+	preferredFamily string
+	// happyEyeballsDelay is the time given to each connection attempt to a
+	// dual-stack target before the next address is tried in parallel.
+	happyEyeballsDelay time.Duration
+	// failbackInterval is how often the preferred address is checked.
+	failbackInterval time.Duration
+	// dialer is the dialer set with the dial options, if any. It is also
+	// used to check the preferred address.
+	dialer contextDialer
+	// onAddress is called with the address once a connection is made.
+	onAddress func(address string)
+	// onError is called with the error that ended each subscription.
+	onError func(err error)
+	// auth backs off connection attempts after authentication failures.
+	auth *authTracker
+	// probeCapabilities sends a Capabilities request before subscribing.
+	probeCapabilities bool
+	// onCapabilities is called with the result of the Capabilities probe.
+	onCapabilities func(*TargetCapabilities)
+	// resolver resolves SRV addresses.
+	resolver srvResolver
+	// resolveInterval is how often SRV addresses are re-resolved.
+	resolveInterval time.Duration
+	// syncTimeout is the time the target is given to sync before the
+	// subscription is closed.
+	syncTimeout time.Duration
+	// synced returns true once the target has synced.
+	synced func() bool
+	// refreshInterval is how long the subscription is kept open.
+	refreshInterval time.Duration
+
+	// splitStreams is the number of Subscribe streams the subscription is
+	// split across.
+	splitStreams int
+	// splitConnections uses a separate connection for each split stream.
+	splitConnections bool
+
+	mutex sync.Mutex
+	impls []client.Impl
+	// next is the index of the address to start at for round-robin.
+	next int
+}
+
+// newGatewayClient creates a client that adds dialOptions to the default dial
+// options when connecting to a target.
+func newGatewayClient(dialOptions ...grpc.DialOption) *gatewayClient {
+	return &gatewayClient{
+		dialOptions:   dialOptions,
+		addressPolicy: AddressPolicyOrdered,
+		resolver:      net.DefaultResolver,
+	}
+}
+
+// Subscribe implements client.Client. Subscribe blocks until the subscription
+// ends or ctx is canceled. The client type is ignored; gatewayClient only
+// supports gNMI.
+func (c *gatewayClient) Subscribe(ctx context.Context, q client.Query, _ ...string) error {
+	if c.auth != nil {
+		if err := c.auth.wait(ctx); err != nil {
+			return err
+		}
+	}
+	ctx, span := tracing.Start(ctx, "target.subscribe")
+	span.SetAttribute("gnmi.target", q.Target)
+	defer span.End()
+	err := c.subscribe(ctx, q)
+	if err != nil && ctx.Err() == nil {
+		span.SetError(err)
+		if c.auth != nil {
+			c.auth.failed(err)
+		}
+		if c.onError != nil {
+			c.onError(err)
+		}
+	}
+	return err
+}
+
+func (c *gatewayClient) subscribe(ctx context.Context, q client.Query) error {
+	d := q.Destination()
+	configured := d.Addrs
+	addresses, err := resolveAddresses(ctx, c.resolver, configured)
+	if err != nil {
+		return err
+	}
+	if c.addressPolicy == AddressPolicyOrdered {
+		addresses = orderByFamily(addresses, c.preferredFamily)
+	}
+	d.Addrs = addresses
+	conn, address, err := c.dialAny(ctx, d)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if c.onAddress != nil {
+		c.onAddress(address)
+	}
+
+	if c.probeCapabilities {
+		probed, capabilities, err := probeCapabilities(ctx, conn, q)
+		if err != nil {
+			if c.onError != nil {
+				c.onError(err)
+			}
+		} else {
+			q = probed
+			if c.onCapabilities != nil {
+				c.onCapabilities(capabilities)
+			}
+		}
+	}
+
+	var streams []client.Impl
+	for i, streamQuery := range splitQuery(q, c.splitStreams) {
+		streamConn := conn
+		if i > 0 && c.splitConnections {
+			streamConn, _, err = c.dialAny(ctx, d)
+			if err != nil {
+				return err
+			}
+			defer streamConn.Close()
+		}
+		impl, err := gnmiclient.NewFromConn(ctx, streamConn, d)
+		if err != nil {
+			return err
+		}
+		defer impl.Close()
+		if err := impl.Subscribe(ctx, streamQuery); err != nil {
+			return err
+		}
+		streams = append(streams, impl)
+	}
+	c.mutex.Lock()
+	c.impls = streams
+	c.mutex.Unlock()
+
+	if c.addressPolicy == AddressPolicyOrdered && address != d.Addrs[0] && c.failbackInterval > 0 {
+		failbackCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go c.failback(failbackCtx, d.Addrs[0], d.Timeout, c.Close)
+	}
+	if hasSRVAddress(configured) && c.resolveInterval > 0 {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go c.watchAddress(watchCtx, configured, address, c.Close)
+	}
+	if c.syncTimeout <= 0 && c.refreshInterval <= 0 {
+		return c.receive(streams)
+	}
+
+	deadlineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	expired := make(chan error, 1)
+	go func() {
+		expired <- c.watchDeadlines(deadlineCtx, c.Close)
+	}()
+	err = c.receive(streams)
+	cancel()
+	switch deadlineErr := <-expired; deadlineErr {
+	case nil:
+		return err
+	case errSubscribeRefresh:
+		return nil
+	default:
+		return deadlineErr
+	}
+}
+
+// receive receives from each of the streams until one of them fails or all of
+// them end.
+func (c *gatewayClient) receive(streams []client.Impl) error {
+	var connected sync.Once
+	recv := func(impl client.Impl) error {
+		for {
+			if err := impl.Recv(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			connected.Do(func() {
+				if c.auth != nil {
+					c.auth.connected()
+				}
+			})
+		}
+	}
+	if len(streams) == 1 {
+		return recv(streams[0])
+	}
+
+	errs := make(chan error, len(streams))
+	for _, impl := range streams {
+		go func(impl client.Impl) {
+			errs <- recv(impl)
+		}(impl)
+	}
+	for range streams {
+		if err := <-errs; err != nil {
+			// the deferred Close calls in subscribe end the other streams
+			return err
+		}
+	}
+	return nil
+}
+
+// dialAny tries each of the destination addresses in the order set by the
+// address policy and returns the first successful connection.
+func (c *gatewayClient) dialAny(ctx context.Context, d client.Destination) (*grpc.ClientConn, string, error) {
+	if len(d.Addrs) == 0 {
+		return nil, "", errors.New("destination has no addresses")
+	}
+	if c.addressPolicy == AddressPolicyOrdered && c.happyEyeballsDelay > 0 && mixedFamilies(d.Addrs) {
+		return c.dialRace(ctx, d)
+	}
+	var start int
+	if c.addressPolicy == AddressPolicyRoundRobin {
+		c.mutex.Lock()
+		start = c.next % len(d.Addrs)
+		c.mutex.Unlock()
+	}
+
+	var errs []string
+	for i := 0; i < len(d.Addrs); i++ {
+		index := (start + i) % len(d.Addrs)
+		_, span := tracing.Start(ctx, "target.dial")
+		span.SetAttribute("net.peer.address", d.Addrs[index])
+		conn, err := c.dial(ctx, d, d.Addrs[index])
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			errs = append(errs, err.Error())
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		c.mutex.Lock()
+		c.next = index + 1
+		c.mutex.Unlock()
+		return conn, d.Addrs[index], nil
+	}
+	return nil, "", fmt.Errorf("unable to connect to any address: %s", strings.Join(errs, "; "))
+}
+
+// failback closes the subscription once the preferred address is reachable so
+// that the reconnect uses the preferred address.
+func (c *gatewayClient) failback(ctx context.Context, preferred string, timeout time.Duration, closer func() error) {
+	ticker := time.NewTicker(c.failbackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			conn, err := c.dialTCP(ctx, preferred, timeout)
+			if err != nil {
+				continue
+			}
+			_ = conn.Close()
+			_ = closer()
+			return
+		}
+	}
+}
+
+// dialTCP opens a TCP connection to the address with the client's dialer.
+func (c *gatewayClient) dialTCP(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	if c.dialer == nil {
+		return net.DialTimeout("tcp", address, timeout)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return c.dialer(ctx, address)
+}
+
+// dial connects to the address.
+func (c *gatewayClient) dial(ctx context.Context, d client.Destination, address string) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32)),
+	}
+	if d.TLS != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(d.TLS)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	opts = append(opts, c.dialOptions...)
+
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	conn, err := grpc.DialContext(ctx, address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %v", address, err)
+	}
+	return conn, nil
+}
+
+// Poll implements client.Client. Each of the split streams is polled.
+func (c *gatewayClient) Poll() error {
+	c.mutex.Lock()
+	streams := c.impls
+	c.mutex.Unlock()
+	if len(streams) == 0 {
+		return errors.New("client is not connected")
+	}
+	for _, impl := range streams {
+		if err := impl.Poll(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements client.Client.
+func (c *gatewayClient) Close() error {
+	c.mutex.Lock()
+	streams := c.impls
+	c.impls = nil
+	c.mutex.Unlock()
+	var firstErr error
+	for _, impl := range streams {
+		if err := impl.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Impl implements client.Client. The first stream is returned if the
+// subscription is split.
+func (c *gatewayClient) Impl() (client.Impl, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.impls) == 0 {
+		return nil, errors.New("client is not connected")
+	}
+	return c.impls[0], nil
+}
+
+// ValidateCompression returns an error if the compression name isn't a
+// registered gRPC compressor. Empty and "none" disable compression.
+func ValidateCompression(name string) error {
+	if name == "" || name == "none" {
+		return nil
+	}
+	if encoding.GetCompressor(name) == nil {
+		return fmt.Errorf("unsupported compression '%s'", name)
+	}
+	return nil
+}
+
+// newClient creates the client used to connect to the target.
+func (t *ConnectionState) newClient() (*gatewayClient, error) {
+	dialOptions, dialer, err := t.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	c := newGatewayClient(dialOptions...)
+	c.dialer = dialer
+	c.onAddress = t.setAddress
+	c.onError = t.setError
+	c.auth = &authTracker{
+		backoff:      t.config.TargetAuthFailureBackoff,
+		limit:        t.config.TargetAuthFailureLimit,
+		stop:         t.config.TargetAuthFailureStop,
+		onQuarantine: t.setQuarantined,
+	}
+
+	if policy, exists := t.target.Meta[MetaAddressPolicy]; exists {
+		switch policy {
+		case AddressPolicyOrdered, AddressPolicyRoundRobin:
+			c.addressPolicy = policy
+		default:
+			return nil, fmt.Errorf("unknown %s '%s'", MetaAddressPolicy, policy)
+		}
+	}
+
+	c.preferredFamily, c.happyEyeballsDelay, err = parseAddressFamily(t.config, t.target.Meta)
+	if err != nil {
+		return nil, err
+	}
+
+	c.splitStreams, c.splitConnections, err = parseSubscriptionSplit(t.target.Meta)
+	if err != nil {
+		return nil, err
+	}
+
+	c.probeCapabilities, err = t.capabilitiesProbeEnabled()
+	if err != nil {
+		return nil, err
+	}
+	c.onCapabilities = t.setCapabilities
+
+	c.failbackInterval = defaultFailbackInterval
+	if value, exists := t.target.Meta[MetaFailbackInterval]; exists {
+		c.failbackInterval, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaFailbackInterval, value, err)
+		}
+	}
+
+	timeouts, err := parseConnectionTimeouts(t.config, t.target.Meta)
+	if err != nil {
+		return nil, err
+	}
+	c.syncTimeout = timeouts.sync
+	c.refreshInterval = timeouts.refresh
+	c.synced = t.isSynced
+
+	if hasSRVAddress(t.target.Addresses) {
+		c.resolveInterval = defaultResolveInterval
+		if value, exists := t.target.Meta[MetaResolveInterval]; exists {
+			c.resolveInterval, err = time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s '%s': %v", MetaResolveInterval, value, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// setAddress records the address the target is connected to.
+func (t *ConnectionState) setAddress(address string) {
+	t.addressMutex.Lock()
+	previous := t.address
+	t.address = address
+	t.addressMutex.Unlock()
+	if previous != address {
+		t.config.Log.Info().Msgf("Target %s: Using address %s", t.name, address)
+	}
+}
+
+// Address returns the target address currently in use, if connected.
+func (t *ConnectionState) Address() string {
+	t.addressMutex.Lock()
+	defer t.addressMutex.Unlock()
+	return t.address
+}
+
+// dialOptions returns the additional gRPC dial options for the target and
+// the dialer set with them, if any.
+func (t *ConnectionState) dialOptions() ([]grpc.DialOption, contextDialer, error) {
+	var opts []grpc.DialOption
+
+	compression := t.config.TargetCompression
+	if value, exists := t.target.Meta[MetaCompression]; exists {
+		compression = value
+	}
+	if err := ValidateCompression(compression); err != nil {
+		return nil, nil, err
+	}
+	if compression != "" && compression != "none" {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(compression)))
+	}
+
+	tunnelOpt, err := t.tunnelDialOption()
+	if err != nil {
+		return nil, nil, err
+	}
+	connectionOpts, dialer, err := t.connectionDialOptions(tunnelOpt != nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = append(opts, connectionOpts...)
+	if tunnelOpt != nil {
+		opts = append(opts, tunnelOpt)
+	}
+	return opts, dialer, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// startTestServer starts an empty gRPC server and returns its address.
+func startTestServer(t *testing.T) (*grpc.Server, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	go func() { _ = s.Serve(listener) }()
+	return s, listener.Addr().String()
+}
+
+// closedAddress returns a local address with nothing listening on it.
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	return address
+}
+
+func TestGatewayClient_dialAny(t *testing.T) {
+	assertion := assert.New(t)
+
+	down := closedAddress(t)
+	firstServer, first := startTestServer(t)
+	defer firstServer.Stop()
+	secondServer, second := startTestServer(t)
+	defer secondServer.Stop()
+	d := client.Destination{Addrs: []string{down, first, second}, Timeout: time.Second}
+
+	c := newGatewayClient()
+	for i := 0; i < 2; i++ {
+		conn, address, err := c.dialAny(context.Background(), d)
+		assertion.NoError(err)
+		assertion.Equal(first, address)
+		_ = conn.Close()
+	}
+
+	c.addressPolicy = AddressPolicyRoundRobin
+	var addresses []string
+	for i := 0; i < 3; i++ {
+		conn, address, err := c.dialAny(context.Background(), d)
+		assertion.NoError(err)
+		addresses = append(addresses, address)
+		_ = conn.Close()
+	}
+	assertion.Equal([]string{second, first, second}, addresses)
+
+	_, _, err := c.dialAny(context.Background(), client.Destination{Addrs: []string{down}, Timeout: 100 * time.Millisecond})
+	assertion.Error(err)
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/spectator-go"
+	"github.com/openconfig/gnmi/cache"
+	"github.com/openconfig/gnmi/path"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// MetaCoalesceWindow is the target meta field that enables update coalescing.
+// The value is a duration (e.g. "5s"). Updates to the same path received
+// within the window are merged and only the latest value is inserted into the
+// cache when the window ends.
+const MetaCoalesceWindow = "CoalesceWindow"
+
+// coalescedUpdate is the latest pending update for a path.
+type coalescedUpdate struct {
+	cache     *cache.Target
+	prefix    *gnmipb.Path
+	prefixKey string
+	timestamp int64
+	update    *gnmipb.Update
+}
+
+// updateCoalescer buffers updates for a window and merges updates to the same
+// path so that high-frequency counters are only inserted into the cache once
+// per window.
+type updateCoalescer struct {
+	window    time.Duration
+	flushFunc func(*cache.Target, *gnmipb.Notification)
+
+	// flushMutex serializes flushes so that pending updates are inserted
+	// in order. mutex guards the pending updates and is not held while
+	// updates are inserted into the cache.
+	flushMutex sync.Mutex
+	mutex      sync.Mutex
+	pending    map[string]*coalescedUpdate
+	order      []string
+	timer      *time.Timer
+	// merged counts updates that were replaced by a newer update within the window.
+	merged *spectator.Counter
+}
+
+// newUpdateCoalescer creates an updateCoalescer from the target meta
+// configuration. flush is called with the merged notifications at the end of
+// each window. A nil updateCoalescer is returned if coalescing isn't
+// configured for the target.
+func newUpdateCoalescer(meta map[string]string, flush func(*cache.Target, *gnmipb.Notification), merged *spectator.Counter) (*updateCoalescer, error) {
+	value, exists := meta[MetaCoalesceWindow]
+	if !exists {
+		return nil, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %v", MetaCoalesceWindow, value, err)
+	}
+	if window <= 0 {
+		return nil, nil
+	}
+	return &updateCoalescer{
+		window:    window,
+		flushFunc: flush,
+		pending:   make(map[string]*coalescedUpdate),
+		merged:    merged,
+	}, nil
+}
+
+// add buffers the updates in the notification. Notifications that contain
+// deletes are not buffered: pending updates are flushed and add returns false
+// so that the caller can insert the notification into the cache immediately.
+func (c *updateCoalescer) add(targetCache *cache.Target, notification *gnmipb.Notification) bool {
+	if len(notification.Delete) > 0 {
+		c.flush()
+		return false
+	}
+
+	prefixKey := notification.GetPrefix().GetOrigin() + ":" + strings.Join(path.ToStrings(notification.GetPrefix(), true), "/")
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, update := range notification.Update {
+		key := prefixKey + "/" + strings.Join(path.ToStrings(update.GetPath(), false), "/")
+		existing, exists := c.pending[key]
+		if exists {
+			if existing.timestamp > notification.Timestamp {
+				continue
+			}
+			c.merged.Increment()
+		} else {
+			c.order = append(c.order, key)
+		}
+		c.pending[key] = &coalescedUpdate{
+			cache:     targetCache,
+			prefix:    notification.Prefix,
+			prefixKey: prefixKey,
+			timestamp: notification.Timestamp,
+			update:    update,
+		}
+	}
+	if c.timer == nil && len(c.pending) > 0 {
+		c.timer = time.AfterFunc(c.window, c.flush)
+	}
+	return true
+}
+
+// flush sends the pending updates to the flush function. Updates with the same
+// prefix and timestamp are merged into a single notification. The pending
+// updates are swapped out under the lock so that add isn't blocked while the
+// flush function inserts them into the cache.
+func (c *updateCoalescer) flush() {
+	c.flushMutex.Lock()
+	defer c.flushMutex.Unlock()
+
+	c.mutex.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	pendingUpdates, order := c.pending, c.order
+	c.pending = make(map[string]*coalescedUpdate)
+	c.order = nil
+	c.mutex.Unlock()
+
+	var notifications []*gnmipb.Notification
+	var caches []*cache.Target
+	groups := make(map[string]int)
+	for _, key := range order {
+		pending := pendingUpdates[key]
+		groupKey := fmt.Sprintf("%s@%d", pending.prefixKey, pending.timestamp)
+		i, exists := groups[groupKey]
+		if !exists {
+			i = len(notifications)
+			groups[groupKey] = i
+			notifications = append(notifications, &gnmipb.Notification{
+				Timestamp: pending.timestamp,
+				Prefix:    pending.prefix,
+			})
+			caches = append(caches, pending.cache)
+		}
+		notifications[i].Update = append(notifications[i].Update, pending.update)
+	}
+
+	for i, notification := range notifications {
+		c.flushFunc(caches[i], notification)
+	}
+}
+
+// reset discards all pending updates.
+func (c *updateCoalescer) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.pending = make(map[string]*coalescedUpdate)
+	c.order = nil
+}
+
+// flushCoalesced inserts a merged notification into the cache.
+func (t *ConnectionState) flushCoalesced(targetCache *cache.Target, notification *gnmipb.Notification) {
+	if err := t.updateTargetCache(targetCache, notification); err != nil {
+		t.config.Log.Error().Msgf("Target %s: unable to insert coalesced updates: %v", t.name, err)
+	}
+}
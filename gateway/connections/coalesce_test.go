@@ -0,0 +1,104 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/cache"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+func counterNotification(timestamp int64, values map[string]int64) *gnmipb.Notification {
+	n := &gnmipb.Notification{Timestamp: timestamp, Prefix: &gnmipb.Path{Target: "a"}}
+	for name, value := range values {
+		n.Update = append(n.Update, &gnmipb.Update{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: name}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: value}},
+		})
+	}
+	return n
+}
+
+func TestUpdateCoalescer(t *testing.T) {
+	assertion := assert.New(t)
+
+	var flushed []*gnmipb.Notification
+	flush := func(_ *cache.Target, n *gnmipb.Notification) {
+		flushed = append(flushed, n)
+	}
+	counter := stats.Registry.Counter("test_update_coalescer", stats.NoTags)
+
+	coalescer, err := newUpdateCoalescer(map[string]string{}, flush, counter)
+	assertion.NoError(err)
+	assertion.Nil(coalescer)
+
+	_, err = newUpdateCoalescer(map[string]string{MetaCoalesceWindow: "soon"}, flush, counter)
+	assertion.Error(err)
+
+	coalescer, err = newUpdateCoalescer(map[string]string{MetaCoalesceWindow: "1h"}, flush, counter)
+	assertion.NoError(err)
+
+	assertion.True(coalescer.add(nil, counterNotification(1, map[string]int64{"in": 1})))
+	assertion.True(coalescer.add(nil, counterNotification(2, map[string]int64{"in": 2, "out": 2})))
+	assertion.True(coalescer.add(nil, counterNotification(1, map[string]int64{"out": 1})))
+	assertion.Empty(flushed)
+
+	coalescer.flush()
+	assertion.Len(flushed, 1)
+	assertion.Equal(int64(2), flushed[0].Timestamp)
+	assertion.Len(flushed[0].Update, 2)
+	for _, u := range flushed[0].Update {
+		assertion.Equal(int64(2), u.Val.GetIntVal())
+	}
+
+	// Deletes flush pending updates and aren't buffered.
+	flushed = nil
+	assertion.True(coalescer.add(nil, counterNotification(3, map[string]int64{"in": 3})))
+	assertion.False(coalescer.add(nil, &gnmipb.Notification{Timestamp: 4, Delete: []*gnmipb.Path{{}}}))
+	assertion.Len(flushed, 1)
+
+	flushed = nil
+	assertion.True(coalescer.add(nil, counterNotification(5, map[string]int64{"in": 5})))
+	coalescer.reset()
+	coalescer.flush()
+	assertion.Empty(flushed)
+}
+
+func TestUpdateCoalescer_addDuringFlush(t *testing.T) {
+	assertion := assert.New(t)
+	counter := stats.Registry.Counter("test_update_coalescer", stats.NoTags)
+
+	// The flush function runs without the coalescer lock held, so updates
+	// can be added while a flush is inserting into the cache.
+	var coalescer *updateCoalescer
+	var flushed int
+	flush := func(_ *cache.Target, n *gnmipb.Notification) {
+		flushed++
+		assertion.True(coalescer.add(nil, counterNotification(n.Timestamp+1, map[string]int64{"in": 1})))
+	}
+	coalescer, err := newUpdateCoalescer(map[string]string{MetaCoalesceWindow: "1h"}, flush, counter)
+	assertion.NoError(err)
+
+	assertion.True(coalescer.add(nil, counterNotification(1, map[string]int64{"in": 1})))
+	coalescer.flush()
+	assertion.Equal(1, flushed)
+	assertion.Len(coalescer.pending, 1)
+	coalescer.reset()
+}
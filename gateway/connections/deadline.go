@@ -0,0 +1,125 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Target meta fields used to configure the connection timeouts. The values
+// are durations (e.g. "30s").
+const (
+	// MetaDialTimeout is the dial timeout of the target connection,
+	// overriding TargetDialTimeout in GatewayConfig.
+	MetaDialTimeout = "DialTimeout"
+	// MetaSyncTimeout is the time the target is given to send its initial
+	// sync_response before it's reconnected, overriding TargetSyncTimeout in
+	// GatewayConfig. "0" waits indefinitely.
+	MetaSyncTimeout = "SyncTimeout"
+	// MetaSubscribeRefresh is how long a subscription is kept open before the
+	// target is reconnected, overriding TargetSubscribeRefresh in
+	// GatewayConfig. "0" keeps the subscription open until it fails.
+	MetaSubscribeRefresh = "SubscribeRefresh"
+)
+
+// refreshJitter is the fraction of the subscribe refresh interval added at
+// random so that targets connected at the same time aren't all reconnected
+// at once.
+const refreshJitter = 0.1
+
+// errSubscribeRefresh is returned by watchDeadlines when the subscription was
+// closed to be refreshed.
+var errSubscribeRefresh = errors.New("subscription refreshed")
+
+// connectionTimeouts are the timeouts of a target connection.
+type connectionTimeouts struct {
+	dial    time.Duration
+	sync    time.Duration
+	refresh time.Duration
+}
+
+// parseConnectionTimeouts returns the connection timeouts from the target meta
+// fields, using the GatewayConfig defaults for fields that aren't set.
+func parseConnectionTimeouts(config *configuration.GatewayConfig, meta map[string]string) (connectionTimeouts, error) {
+	timeouts := connectionTimeouts{
+		dial:    config.TargetDialTimeout,
+		sync:    config.TargetSyncTimeout,
+		refresh: config.TargetSubscribeRefresh,
+	}
+	for _, field := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{MetaDialTimeout, &timeouts.dial},
+		{MetaSyncTimeout, &timeouts.sync},
+		{MetaSubscribeRefresh, &timeouts.refresh},
+	} {
+		value, exists := meta[field.name]
+		if !exists {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return connectionTimeouts{}, fmt.Errorf("invalid %s '%s': %v", field.name, value, err)
+		}
+		if d < 0 {
+			return connectionTimeouts{}, fmt.Errorf("invalid %s '%s': must not be negative", field.name, value)
+		}
+		*field.value = d
+	}
+	return timeouts, nil
+}
+
+// watchDeadlines closes the subscription if the target hasn't synced within
+// the sync timeout or once the subscribe refresh interval has passed. It
+// returns the reason the subscription was closed, or nil if ctx was canceled
+// first.
+func (c *gatewayClient) watchDeadlines(ctx context.Context, closer func() error) error {
+	var syncDeadline, refreshDeadline <-chan time.Time
+	if c.syncTimeout > 0 {
+		timer := time.NewTimer(c.syncTimeout)
+		defer timer.Stop()
+		syncDeadline = timer.C
+	}
+	if c.refreshInterval > 0 {
+		jitter := time.Duration(rand.Int63n(int64(float64(c.refreshInterval)*refreshJitter) + 1))
+		timer := time.NewTimer(c.refreshInterval + jitter)
+		defer timer.Stop()
+		refreshDeadline = timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-syncDeadline:
+			if c.synced != nil && c.synced() {
+				syncDeadline = nil
+				continue
+			}
+			_ = closer()
+			return fmt.Errorf("no sync_response received within %s", c.syncTimeout)
+		case <-refreshDeadline:
+			_ = closer()
+			return errSubscribeRefresh
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestParseConnectionTimeouts(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.TargetDialTimeout = 10 * time.Second
+	config.TargetSyncTimeout = time.Minute
+
+	timeouts, err := parseConnectionTimeouts(config, map[string]string{})
+	assertion.NoError(err)
+	assertion.Equal(connectionTimeouts{dial: 10 * time.Second, sync: time.Minute}, timeouts)
+
+	timeouts, err = parseConnectionTimeouts(config, map[string]string{
+		MetaDialTimeout:      "30s",
+		MetaSyncTimeout:      "0",
+		MetaSubscribeRefresh: "24h",
+	})
+	assertion.NoError(err)
+	assertion.Equal(connectionTimeouts{dial: 30 * time.Second, refresh: 24 * time.Hour}, timeouts)
+
+	_, err = parseConnectionTimeouts(config, map[string]string{MetaSyncTimeout: "soon"})
+	assertion.Error(err)
+	_, err = parseConnectionTimeouts(config, map[string]string{MetaDialTimeout: "-1s"})
+	assertion.Error(err)
+}
+
+func TestGatewayClient_watchDeadlines(t *testing.T) {
+	assertion := assert.New(t)
+
+	var closed int32
+	closer := func() error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+
+	// not synced within the sync timeout
+	c := &gatewayClient{syncTimeout: 10 * time.Millisecond, synced: func() bool { return false }}
+	err := c.watchDeadlines(context.Background(), closer)
+	assertion.Error(err)
+	assertion.NotEqual(errSubscribeRefresh, err)
+	assertion.Equal(int32(1), atomic.LoadInt32(&closed))
+
+	// synced in time, refreshed later
+	c = &gatewayClient{syncTimeout: 10 * time.Millisecond, synced: func() bool { return true }, refreshInterval: 50 * time.Millisecond}
+	start := time.Now()
+	err = c.watchDeadlines(context.Background(), closer)
+	assertion.Equal(errSubscribeRefresh, err)
+	assertion.True(time.Since(start) >= 50*time.Millisecond)
+	assertion.Equal(int32(2), atomic.LoadInt32(&closed))
+
+	// canceled before the deadlines
+	c = &gatewayClient{syncTimeout: time.Minute, refreshInterval: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assertion.NoError(c.watchDeadlines(ctx, closer))
+	assertion.Equal(int32(2), atomic.LoadInt32(&closed))
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/path"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// deduplicateSync returns true if the updates of the target's initial sync
+// should be compared against the state already in the cache.
+//
+// The cache only holds data for a target that isn't synced yet if it was
+// replicated from the cluster member that previously owned the target: the
+// data is reset whenever this instance's own connection to the target drops.
+func (t *ConnectionState) deduplicateSync() bool {
+	return t.config.FailoverDeduplication && !t.isSynced() && !t.clusterMember
+}
+
+// dropReplicated removes the updates from the notification whose values are
+// identical to the values already in the cache and returns true if nothing is
+// left to insert. The receive time of the dropped leaves is still tracked so
+// that they don't expire.
+func (t *ConnectionState) dropReplicated(notification *gnmipb.Notification) bool {
+	prefix := path.ToStrings(notification.GetPrefix(), true)[1:]
+	var kept, dropped []*gnmipb.Update
+	for _, update := range notification.Update {
+		leafPath := append(append([]string{}, prefix...), path.ToStrings(update.GetPath(), false)...)
+		if t.cachedValueEqual(notification.GetPrefix().GetTarget(), leafPath, update.GetVal()) {
+			dropped = append(dropped, update)
+		} else {
+			kept = append(kept, update)
+		}
+	}
+	if len(dropped) == 0 {
+		return false
+	}
+	t.counterDeduplicated.Add(int64(len(dropped)))
+	t.leafTimesMutex.Lock()
+	t.trackLeaves(&gnmipb.Notification{Prefix: notification.Prefix, Update: dropped}, time.Now())
+	t.leafTimesMutex.Unlock()
+	notification.Update = kept
+	return len(kept) == 0 && len(notification.Delete) == 0
+}
+
+// cachedValueEqual returns true if the cache has a leaf for target at leafPath
+// with a value equal to val.
+func (t *ConnectionState) cachedValueEqual(target string, leafPath []string, val *gnmipb.TypedValue) bool {
+	var equal bool
+	_ = t.connManager.Cache().Query(target, leafPath, func(p []string, _ *ctree.Leaf, value interface{}) error {
+		// Query also visits the leaves below leafPath if it isn't a leaf.
+		cached, ok := value.(*gnmipb.Notification)
+		if ok && len(p) == len(leafPath) && len(cached.Update) == 1 && proto.Equal(cached.Update[0].GetVal(), val) {
+			equal = true
+		}
+		return nil
+	})
+	return equal
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func intUpdate(name string, value int64) *gnmipb.Update {
+	return &gnmipb.Update{
+		Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "interfaces"}, {Name: name}}},
+		Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: value}},
+	}
+}
+
+func TestConnectionState_dropReplicated(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{FailoverDeduplication: true}
+	mgr, err := NewZookeeperConnectionManagerDefault(config, nil, nil)
+	assertion.NoError(err)
+	targetCache := mgr.Cache().Add("a")
+	// state replicated from the previous owner of the target
+	assertion.NoError(targetCache.GnmiUpdate(&gnmipb.Notification{
+		Timestamp: 1,
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Update:    []*gnmipb.Update{intUpdate("x", 1), intUpdate("y", 2)},
+	}))
+
+	state := &ConnectionState{
+		config:      config,
+		connManager: mgr,
+		name:        "a",
+		queryTarget: "a",
+		targetCache: targetCache,
+	}
+	state.InitializeMetrics()
+	assertion.True(state.deduplicateSync())
+
+	notification := &gnmipb.Notification{
+		Timestamp: 2,
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Update:    []*gnmipb.Update{intUpdate("x", 1), intUpdate("y", 3), intUpdate("z", 4)},
+	}
+	assertion.False(state.dropReplicated(notification))
+	if assertion.Len(notification.Update, 2) {
+		assertion.True(proto.Equal(intUpdate("y", 3), notification.Update[0]))
+		assertion.True(proto.Equal(intUpdate("z", 4), notification.Update[1]))
+	}
+
+	unchanged := &gnmipb.Notification{
+		Timestamp: 2,
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Update:    []*gnmipb.Update{intUpdate("x", 1)},
+	}
+	assertion.True(state.dropReplicated(unchanged))
+
+	state.synced = true
+	assertion.False(state.deduplicateSync())
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/path"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// defaultSyntheticDeleteSamples is used if the Samples of a SyntheticDelete
+// isn't set.
+const defaultSyntheticDeleteSamples = 2
+
+// ValidateSyntheticDeletes returns an error if a path or target pattern of the
+// synthetic delete rules is invalid.
+func ValidateSyntheticDeletes(rules []configuration.SyntheticDelete) error {
+	for _, rule := range rules {
+		if _, err := xpath.ToGNMIPath(rule.Path); err != nil {
+			return fmt.Errorf("invalid synthetic delete path '%s': %v", rule.Path, err)
+		}
+		for _, pattern := range rule.Targets {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid synthetic delete target pattern '%s': %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// listEntry is a list entry tracked by a synthetic delete rule.
+type listEntry struct {
+	origin string
+	elems  []*gnmipb.PathElem
+	// missed is the number of consecutive samples the entry was missing from.
+	missed int
+	// seen is set if the entry was updated in the current sample.
+	seen bool
+	// timestamp is the latest timestamp of the entry's updates.
+	timestamp int64
+}
+
+// deleteRule tracks the entries of a list for a synthetic delete rule.
+type deleteRule struct {
+	path     []*gnmipb.PathElem
+	interval time.Duration
+	samples  int
+	// entries are the list entries by their joined path.
+	entries     map[string]*listEntry
+	sampleStart time.Time
+}
+
+// deleteGenerator generates deletes for the list entries that are missing
+// from consecutive full samples of a target that doesn't delete them itself.
+type deleteGenerator struct {
+	mutex sync.Mutex
+	rules []*deleteRule
+}
+
+// newDeleteGenerator creates the generator for the synthetic delete rules
+// that apply to the named target subscribed to with request. A nil generator
+// is returned if no rules apply.
+//
+// A STREAM subscription only sends one sync response, so the rules without
+// an interval use the longest sample interval of its subscriptions. An error
+// is returned if it has none.
+func newDeleteGenerator(config *configuration.GatewayConfig, target string, request *gnmipb.SubscribeRequest) (*deleteGenerator, error) {
+	g := new(deleteGenerator)
+	for _, rule := range config.SyntheticDeletes {
+		if len(rule.Targets) > 0 && !matchTarget(rule.Targets, target) {
+			continue
+		}
+		parsed, err := xpath.ToGNMIPath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid synthetic delete path '%s': %v", rule.Path, err)
+		}
+		samples := rule.Samples
+		if samples <= 0 {
+			samples = defaultSyntheticDeleteSamples
+		}
+		interval := rule.Interval
+		if interval <= 0 && request.GetSubscribe().GetMode() == gnmipb.SubscriptionList_STREAM {
+			interval = sampleInterval(request)
+			if interval <= 0 {
+				return nil, fmt.Errorf("synthetic delete rule '%s' needs an interval because the STREAM subscription has no sample interval", rule.Path)
+			}
+		}
+		g.rules = append(g.rules, &deleteRule{
+			path:     parsed.Elem,
+			interval: interval,
+			samples:  samples,
+			entries:  make(map[string]*listEntry),
+		})
+	}
+	if len(g.rules) == 0 {
+		return nil, nil
+	}
+	return g, nil
+}
+
+// sampleInterval returns the longest sample interval of the subscriptions of
+// the request.
+func sampleInterval(request *gnmipb.SubscribeRequest) time.Duration {
+	var interval time.Duration
+	for _, subscription := range request.GetSubscribe().GetSubscription() {
+		if sample := time.Duration(subscription.GetSampleInterval()); sample > interval {
+			interval = sample
+		}
+	}
+	return interval
+}
+
+// entryKey returns the key of the list entry at the concatenated paths.
+func entryKey(origin string, paths ...*gnmipb.Path) string {
+	key := []string{origin}
+	for _, p := range paths {
+		key = append(key, path.ToStrings(p, false)...)
+	}
+	return strings.Join(key, "\x00")
+}
+
+// observe records the list entries updated by the notification and forgets
+// the entries it deletes. The samples of the rules whose interval ended
+// before now are closed first and the deletes of the entries that were
+// missing from too many samples are returned.
+func (g *deleteGenerator) observe(notification *gnmipb.Notification, now time.Time) []*gnmipb.Notification {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	origin := notification.GetPrefix().GetOrigin()
+	prefix := notification.GetPrefix().GetElem()
+	var deletes []*gnmipb.Notification
+	for _, rule := range g.rules {
+		// the notification is part of the next sample if the interval ended
+		if rule.interval > 0 {
+			if rule.sampleStart.IsZero() {
+				rule.sampleStart = now
+			} else if now.Sub(rule.sampleStart) >= rule.interval {
+				deletes = append(deletes, rule.closeSample(now)...)
+				rule.sampleStart = now
+			}
+		}
+		for _, u := range notification.GetUpdate() {
+			full := make([]*gnmipb.PathElem, 0, len(prefix)+len(u.GetPath().GetElem()))
+			full = append(append(full, prefix...), u.GetPath().GetElem()...)
+			if !utils.MatchPathPrefix(full, rule.path) {
+				continue
+			}
+			elems := full[:len(rule.path)]
+			key := entryKey(origin, &gnmipb.Path{Elem: elems})
+			entry, exists := rule.entries[key]
+			if !exists {
+				entry = &listEntry{origin: origin, elems: elems}
+				rule.entries[key] = entry
+			}
+			entry.seen = true
+			entry.missed = 0
+			if notification.GetTimestamp() > entry.timestamp {
+				entry.timestamp = notification.GetTimestamp()
+			}
+		}
+		for _, d := range notification.GetDelete() {
+			deleted := entryKey(origin, &gnmipb.Path{Elem: prefix}, d)
+			for key := range rule.entries {
+				if key == deleted || strings.HasPrefix(key, deleted+"\x00") {
+					delete(rule.entries, key)
+				}
+			}
+		}
+	}
+	return deletes
+}
+
+// sync closes the samples of the rules without an interval and returns the
+// deletes of the entries that were missing from too many samples.
+func (g *deleteGenerator) sync(now time.Time) []*gnmipb.Notification {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var deletes []*gnmipb.Notification
+	for _, rule := range g.rules {
+		if rule.interval <= 0 {
+			deletes = append(deletes, rule.closeSample(now)...)
+		}
+	}
+	return deletes
+}
+
+// reset forgets all entries, e.g. after the target disconnected.
+func (g *deleteGenerator) reset() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, rule := range g.rules {
+		rule.entries = make(map[string]*listEntry)
+		rule.sampleStart = time.Time{}
+	}
+}
+
+// closeSample counts a missed sample for the entries that weren't updated
+// since the last sample and returns the deletes of the entries that were
+// missing from the rule's number of samples.
+func (r *deleteRule) closeSample(now time.Time) []*gnmipb.Notification {
+	var deletes []*gnmipb.Notification
+	for key, entry := range r.entries {
+		if entry.seen {
+			entry.seen = false
+			continue
+		}
+		if entry.missed++; entry.missed < r.samples {
+			continue
+		}
+		delete(r.entries, key)
+		// the delete only removes leaves with older timestamps
+		timestamp := now.UnixNano()
+		if timestamp <= entry.timestamp {
+			timestamp = entry.timestamp + 1
+		}
+		deletes = append(deletes, &gnmipb.Notification{
+			Timestamp: timestamp,
+			Prefix:    &gnmipb.Path{Origin: entry.origin},
+			Delete:    []*gnmipb.Path{{Elem: entry.elems}},
+		})
+	}
+	return deletes
+}
+
+// insertSyntheticDeletes inserts the deletes generated for the target into
+// the cache.
+func (t *ConnectionState) insertSyntheticDeletes(deletes []*gnmipb.Notification) {
+	for _, notification := range deletes {
+		notification.Prefix.Target = t.name
+		if err := t.gnmiUpdate(t.targetCache, notification); err != nil {
+			t.config.Log.Error().Msgf("Target %s: unable to insert synthetic delete: %v", t.name, err)
+			continue
+		}
+		t.counterSyntheticDeletes.Increment()
+		t.config.Log.Debug().Msgf("Target %s: Deleted %s after it was missing from consecutive samples", t.name, utils.PathToXPath(notification.Delete[0]))
+	}
+}
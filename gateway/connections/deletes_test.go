@@ -0,0 +1,232 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+const neighborsPath = "/bgp/neighbors/neighbor[address=*]"
+
+var pollRequest = &gnmipb.SubscribeRequest{Request: &gnmipb.SubscribeRequest_Subscribe{
+	Subscribe: &gnmipb.SubscriptionList{Mode: gnmipb.SubscriptionList_POLL},
+}}
+
+func TestValidateSyntheticDeletes(t *testing.T) {
+	assert.NoError(t, ValidateSyntheticDeletes([]configuration.SyntheticDelete{{Path: neighborsPath, Targets: []string{"edge*"}}}))
+	assert.Error(t, ValidateSyntheticDeletes([]configuration.SyntheticDelete{{Path: "/bgp/neighbors/neighbor[address=*"}}))
+	assert.Error(t, ValidateSyntheticDeletes([]configuration.SyntheticDelete{{Path: neighborsPath, Targets: []string{"edge["}}}))
+}
+
+func TestNewDeleteGenerator(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{
+		SyntheticDeletes: []configuration.SyntheticDelete{
+			{Path: neighborsPath},
+			{Path: "/interfaces/interface[name=*]", Samples: 3, Targets: []string{"edge*"}},
+		},
+	}
+	g, err := newDeleteGenerator(config, "core1", pollRequest)
+	if assertion.NoError(err) && assertion.NotNil(g) && assertion.Len(g.rules, 1) {
+		assertion.Equal(defaultSyntheticDeleteSamples, g.rules[0].samples)
+	}
+	g, err = newDeleteGenerator(config, "edge1", pollRequest)
+	if assertion.NoError(err) && assertion.NotNil(g) && assertion.Len(g.rules, 2) {
+		assertion.Equal(3, g.rules[1].samples)
+	}
+
+	g, err = newDeleteGenerator(&configuration.GatewayConfig{}, "core1", pollRequest)
+	assertion.NoError(err)
+	assertion.Nil(g)
+}
+
+func TestNewDeleteGenerator_stream(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{
+		SyntheticDeletes: []configuration.SyntheticDelete{
+			{Path: neighborsPath},
+			{Path: "/interfaces/interface[name=*]", Interval: time.Minute},
+		},
+	}
+	request := &gnmipb.SubscribeRequest{Request: &gnmipb.SubscribeRequest_Subscribe{
+		Subscribe: &gnmipb.SubscriptionList{Subscription: []*gnmipb.Subscription{
+			{Mode: gnmipb.SubscriptionMode_SAMPLE, SampleInterval: uint64(10 * time.Second)},
+			{Mode: gnmipb.SubscriptionMode_SAMPLE, SampleInterval: uint64(30 * time.Second)},
+			{Mode: gnmipb.SubscriptionMode_ON_CHANGE},
+		}},
+	}}
+	// the rules without an interval use the longest sample interval
+	g, err := newDeleteGenerator(config, "core1", request)
+	if assertion.NoError(err) && assertion.NotNil(g) && assertion.Len(g.rules, 2) {
+		assertion.Equal(30*time.Second, g.rules[0].interval)
+		assertion.Equal(time.Minute, g.rules[1].interval)
+	}
+
+	request.GetSubscribe().Subscription = []*gnmipb.Subscription{{Mode: gnmipb.SubscriptionMode_ON_CHANGE}}
+	_, err = newDeleteGenerator(config, "core1", request)
+	assertion.Error(err)
+}
+
+func neighborNotification(timestamp int64, addresses ...string) *gnmipb.Notification {
+	notification := &gnmipb.Notification{Timestamp: timestamp, Prefix: &gnmipb.Path{Target: "core1"}}
+	for _, address := range addresses {
+		notification.Update = append(notification.Update, &gnmipb.Update{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{
+				{Name: "bgp"}, {Name: "neighbors"},
+				{Name: "neighbor", Key: map[string]string{"address": address}},
+				{Name: "state"}, {Name: "session-state"},
+			}},
+			Val: &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "ESTABLISHED"}},
+		})
+	}
+	return notification
+}
+
+func deletedAddresses(deletes []*gnmipb.Notification) []string {
+	var addresses []string
+	for _, d := range deletes {
+		elems := d.GetDelete()[0].GetElem()
+		addresses = append(addresses, elems[len(elems)-1].GetKey()["address"])
+	}
+	return addresses
+}
+
+func TestDeleteGenerator_sync(t *testing.T) {
+	assertion := assert.New(t)
+
+	g, err := newDeleteGenerator(&configuration.GatewayConfig{
+		SyntheticDeletes: []configuration.SyntheticDelete{{Path: neighborsPath}},
+	}, "core1", pollRequest)
+	if !assertion.NoError(err) {
+		return
+	}
+	now := time.Unix(100, 0)
+
+	assertion.Empty(g.observe(neighborNotification(1, "10.0.0.1", "10.0.0.2", "10.0.0.3"), now))
+	assertion.Empty(g.sync(now))
+	// 10.0.0.2 is missing from the second sample and 10.0.0.3 was deleted
+	assertion.Empty(g.observe(neighborNotification(2, "10.0.0.1"), now))
+	assertion.Empty(g.observe(&gnmipb.Notification{
+		Timestamp: 2,
+		Prefix:    &gnmipb.Path{Target: "core1", Elem: []*gnmipb.PathElem{{Name: "bgp"}}},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{
+			{Name: "neighbors"}, {Name: "neighbor", Key: map[string]string{"address": "10.0.0.3"}},
+		}}},
+	}, now))
+	assertion.Empty(g.sync(now))
+	assertion.Empty(g.observe(neighborNotification(3, "10.0.0.1"), now))
+	deletes := g.sync(now)
+	assertion.Equal([]string{"10.0.0.2"}, deletedAddresses(deletes))
+	if assertion.Len(deletes, 1) {
+		assertion.Equal(now.UnixNano(), deletes[0].GetTimestamp())
+		assertion.Len(deletes[0].GetDelete()[0].GetElem(), 3)
+	}
+	assertion.Empty(g.sync(now))
+
+	// the delete must be newer than the entry's leaves to remove them
+	g.observe(neighborNotification(now.UnixNano()+10, "10.0.0.4"), now)
+	assertion.Equal([]string{"10.0.0.1"}, deletedAddresses(g.sync(now)))
+	assertion.Empty(g.sync(now))
+	deletes = g.sync(now)
+	assertion.Equal([]string{"10.0.0.4"}, deletedAddresses(deletes))
+	if assertion.Len(deletes, 1) {
+		assertion.Equal(now.UnixNano()+11, deletes[0].GetTimestamp())
+	}
+
+	g.observe(neighborNotification(4, "10.0.0.1"), now)
+	g.reset()
+	assertion.Empty(g.sync(now))
+	assertion.Empty(g.sync(now))
+}
+
+func TestDeleteGenerator_interval(t *testing.T) {
+	assertion := assert.New(t)
+
+	g, err := newDeleteGenerator(&configuration.GatewayConfig{
+		SyntheticDeletes: []configuration.SyntheticDelete{{Path: neighborsPath, Interval: 10 * time.Second, Samples: 1}},
+	}, "core1", pollRequest)
+	if !assertion.NoError(err) {
+		return
+	}
+	start := time.Unix(100, 0)
+
+	assertion.Empty(g.observe(neighborNotification(1, "10.0.0.1", "10.0.0.2"), start))
+	// sync responses don't delimit the samples of interval rules
+	assertion.Empty(g.sync(start))
+	assertion.Empty(g.observe(neighborNotification(2, "10.0.0.1"), start.Add(10*time.Second)))
+	// 10.0.0.2 wasn't updated in the second sample
+	deletes := g.observe(neighborNotification(3, "10.0.0.1"), start.Add(20*time.Second))
+	assertion.Equal([]string{"10.0.0.2"}, deletedAddresses(deletes))
+	if assertion.Len(deletes, 1) {
+		assertion.Equal(start.Add(20*time.Second).UnixNano(), deletes[0].GetTimestamp())
+	}
+}
+
+func TestConnectionState_insertSyntheticDeletes(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{Log: zerolog.Nop()}
+	mgr, err := NewZookeeperConnectionManagerDefault(config, nil, nil)
+	if !assertion.NoError(err) {
+		return
+	}
+	state := &ConnectionState{
+		config:      config,
+		connManager: mgr,
+		name:        "core1",
+		queryTarget: "core1",
+		targetCache: mgr.Cache().Add("core1"),
+	}
+	state.InitializeMetrics()
+
+	var deletes []*gnmipb.Notification
+	mgr.Cache().SetClient(func(l *ctree.Leaf) {
+		if n, ok := l.Value().(*gnmipb.Notification); ok && len(n.GetDelete()) > 0 {
+			deletes = append(deletes, n)
+		}
+	})
+	latest := time.Now().Add(time.Hour).UnixNano()
+	assertion.NoError(state.gnmiUpdate(state.targetCache, neighborNotification(latest, "10.0.0.1", "10.0.0.2")))
+
+	state.insertSyntheticDeletes([]*gnmipb.Notification{{
+		Timestamp: latest + 1,
+		Prefix:    &gnmipb.Path{},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{
+			{Name: "bgp"}, {Name: "neighbors"}, {Name: "neighbor", Key: map[string]string{"address": "10.0.0.2"}},
+		}}},
+	}})
+	// the subscribers receive the delete of the leaf with its keys
+	if assertion.Len(deletes, 1) {
+		assertion.Equal("core1", deletes[0].GetPrefix().GetTarget())
+		elems := deletes[0].GetDelete()[0].GetElem()
+		if assertion.Len(elems, 5) {
+			assertion.Equal("10.0.0.2", elems[2].GetKey()["address"])
+		}
+	}
+	snapshot, err := mgr.Cache().Snapshot("core1", nil)
+	assertion.NoError(err)
+	assertion.Len(snapshot, 1)
+}
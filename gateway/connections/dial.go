@@ -0,0 +1,245 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Target meta fields used to set the gRPC dial options of the target
+// connection.
+const (
+	// MetaUserAgent sets the user-agent of the target connection.
+	MetaUserAgent = "UserAgent"
+	// MetaBearerToken is sent as a bearer token in the authorization
+	// metadata of each RPC.
+	MetaBearerToken = "BearerToken"
+	// MetaRPCMetadata is a comma-separated list of key=value pairs sent as
+	// metadata with each RPC.
+	MetaRPCMetadata = "RPCMetadata"
+	// MetaProxy is the URL of a proxy the target is dialed through:
+	// http://[user:password@]host:port for an HTTP CONNECT proxy or
+	// socks5://[user:password@]host:port for a SOCKS5 proxy.
+	MetaProxy = "Proxy"
+)
+
+// contextDialer dials a TCP connection to an address.
+type contextDialer func(ctx context.Context, address string) (net.Conn, error)
+
+// rpcCredentials implements credentials.PerRPCCredentials with static
+// metadata.
+type rpcCredentials struct {
+	metadata map[string]string
+}
+
+var _ credentials.PerRPCCredentials = rpcCredentials{}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (c rpcCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return c.metadata, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials. Target
+// connections always use TLS.
+func (c rpcCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// parseRPCMetadata parses the MetaRPCMetadata and MetaBearerToken meta
+// fields.
+func parseRPCMetadata(meta map[string]string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if value, exists := meta[MetaRPCMetadata]; exists {
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			key := strings.ToLower(strings.TrimSpace(parts[0]))
+			if len(parts) != 2 || key == "" {
+				return nil, fmt.Errorf("invalid %s '%s': expected key=value pairs", MetaRPCMetadata, value)
+			}
+			metadata[key] = strings.TrimSpace(parts[1])
+		}
+	}
+	if token, exists := meta[MetaBearerToken]; exists {
+		if _, exists := metadata["authorization"]; exists {
+			return nil, fmt.Errorf("%s and an authorization key in %s can't both be set", MetaBearerToken, MetaRPCMetadata)
+		}
+		metadata["authorization"] = "Bearer " + token
+	}
+	return metadata, nil
+}
+
+// newContextDialer returns a dialer for the MetaProxy meta field and the
+// source binding of the target connections (see newSourceDialer), or nil if
+// none are set.
+func newContextDialer(config *configuration.GatewayConfig, meta map[string]string) (contextDialer, error) {
+	base, err := newSourceDialer(config, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	value, exists := meta[MetaProxy]
+	if !exists {
+		if base == nil {
+			return nil, nil
+		}
+		return func(ctx context.Context, address string) (net.Conn, error) {
+			return base.DialContext(ctx, "tcp", address)
+		}, nil
+	}
+	if base == nil {
+		base = &sourceDialer{}
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %v", MetaProxy, value, err)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid %s '%s': missing host", MetaProxy, value)
+	}
+	switch proxyURL.Scheme {
+	case "http":
+		return func(ctx context.Context, address string) (net.Conn, error) {
+			return dialHTTPConnect(ctx, base, proxyURL, address)
+		}, nil
+	case "socks5":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, base)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaProxy, value, err)
+		}
+		socksContext, ok := socks.(proxy.ContextDialer)
+		if !ok {
+			return nil, errors.New("the SOCKS5 dialer doesn't support contexts")
+		}
+		return func(ctx context.Context, address string) (net.Conn, error) {
+			return socksContext.DialContext(ctx, "tcp", address)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid %s '%s': unsupported scheme '%s'", MetaProxy, value, proxyURL.Scheme)
+	}
+}
+
+// dialHTTPConnect connects to address through an HTTP CONNECT proxy.
+func dialHTTPConnect(ctx context.Context, base *sourceDialer, proxyURL *url.URL, address string) (net.Conn, error) {
+	conn, err := base.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("dialing proxy %s: %v", proxyURL.Host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s: %v", address, err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s: %v", address, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s: %s", address, resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn that first returns the data read ahead by a
+// bufio.Reader.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// connectionDialOptions returns the dial options for the MetaUserAgent,
+// MetaBearerToken, MetaRPCMetadata, MetaGateway, and MetaProxy meta fields
+// and the source binding, and the dialer used for the target connections, if
+// any. Targets connected through a tunnel don't use a dialer.
+func (t *ConnectionState) connectionDialOptions(tunnel bool) ([]grpc.DialOption, contextDialer, error) {
+	var opts []grpc.DialOption
+	if userAgent, exists := t.target.Meta[MetaUserAgent]; exists {
+		opts = append(opts, grpc.WithUserAgent(userAgent))
+	}
+	metadata, err := parseRPCMetadata(t.target.Meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.federated {
+		metadata[FederationMetadataKey] = FederationID(t.config)
+	}
+	if len(metadata) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(rpcCredentials{metadata: metadata}))
+	}
+	if tunnel {
+		for _, field := range []string{MetaProxy, MetaLocalAddress, MetaLocalInterface, MetaNetworkNamespace} {
+			if _, exists := t.target.Meta[field]; exists {
+				return nil, nil, fmt.Errorf("%s can't be combined with %s", MetaUseTunnel, field)
+			}
+		}
+		return opts, nil, nil
+	}
+	dialer, err := newContextDialer(t.config, t.target.Meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(dialer))
+	}
+	return opts, dialer, nil
+}
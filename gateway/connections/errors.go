@@ -0,0 +1,76 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// defaultErrorHistory is the number of errors kept for each target if
+// TargetErrorHistory isn't set.
+const defaultErrorHistory = 10
+
+// TargetError is an error returned by a target connection.
+type TargetError struct {
+	Time time.Time `json:"time"`
+	// Code is the gRPC status code of the error, Unknown for errors that
+	// aren't gRPC errors.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// newTargetError returns the TargetError for err received at now.
+func newTargetError(err error, now time.Time) TargetError {
+	s, _ := status.FromError(err)
+	return TargetError{Time: now, Code: s.Code().String(), Message: s.Message()}
+}
+
+// recordError adds err to the error history of the target, dropping the
+// oldest errors beyond TargetErrorHistory. The caller must hold stateMutex.
+func (t *ConnectionState) recordError(err error, now time.Time) {
+	limit := t.config.TargetErrorHistory
+	if limit <= 0 {
+		limit = defaultErrorHistory
+	}
+	t.errorHistory = append(t.errorHistory, newTargetError(err, now))
+	if over := len(t.errorHistory) - limit; over > 0 {
+		t.errorHistory = append(t.errorHistory[:0], t.errorHistory[over:]...)
+	}
+}
+
+// Errors returns the most recent errors returned by the target connection,
+// oldest first.
+func (t *ConnectionState) Errors() []TargetError {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	errors := make([]TargetError, len(t.errorHistory))
+	copy(errors, t.errorHistory)
+	return errors
+}
+
+// lastTargetError returns the most recent error returned by the target
+// connection, or nil.
+func (t *ConnectionState) lastTargetError() *TargetError {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	if len(t.errorHistory) == 0 {
+		return nil
+	}
+	last := t.errorHistory[len(t.errorHistory)-1]
+	return &last
+}
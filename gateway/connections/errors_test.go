@@ -0,0 +1,73 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestNewTargetError(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, TargetError{Time: now, Code: "Unavailable", Message: "connection refused"},
+		newTargetError(status.Error(codes.Unavailable, "connection refused"), now))
+	assert.Equal(t, TargetError{Time: now, Code: "Unknown", Message: "EOF"},
+		newTargetError(errors.New("EOF"), now))
+}
+
+func TestConnectionState_Errors(t *testing.T) {
+	assertion := assert.New(t)
+
+	state := &ConnectionState{config: &configuration.GatewayConfig{TargetErrorHistory: 3}}
+	state.InitializeMetrics()
+	assertion.Empty(state.Errors())
+	assertion.Nil(state.lastTargetError())
+
+	state.setError(nil)
+	assertion.Empty(state.Errors())
+
+	for i := 0; i < 5; i++ {
+		state.setError(status.Error(codes.Unavailable, strconv.Itoa(i)))
+	}
+	history := state.Errors()
+	if assertion.Len(history, 3) {
+		assertion.Equal("2", history[0].Message)
+		assertion.Equal("4", history[2].Message)
+	}
+	assertion.Equal("rpc error: code = Unavailable desc = 4", state.LastError())
+	if last := state.lastTargetError(); assertion.NotNil(last) {
+		assertion.Equal("Unavailable", last.Code)
+	}
+
+	// the returned history is a copy
+	history[0].Message = "changed"
+	assertion.Equal("2", state.Errors()[0].Message)
+
+	state = &ConnectionState{config: &configuration.GatewayConfig{}}
+	state.InitializeMetrics()
+	for i := 0; i < defaultErrorHistory+1; i++ {
+		state.setError(errors.New("EOF"))
+	}
+	assertion.Len(state.Errors(), defaultErrorHistory)
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	"google.golang.org/grpc"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/tracing"
+)
+
+// Target meta fields for targets with both IPv4 and IPv6 addresses.
+const (
+	// MetaAddressFamily is the preferred address family, FamilyIPv4 or
+	// FamilyIPv6, overriding TargetAddressFamily in GatewayConfig. By default
+	// the family of the first address is preferred.
+	MetaAddressFamily = "AddressFamily"
+	// MetaHappyEyeballsDelay is the time to wait for a connection attempt
+	// before the next address is tried in parallel, overriding
+	// TargetHappyEyeballsDelay in GatewayConfig. "0" tries the addresses one
+	// after the other.
+	MetaHappyEyeballsDelay = "HappyEyeballsDelay"
+)
+
+// Address families.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// parseAddressFamily returns the preferred address family and the Happy
+// Eyeballs delay for the target.
+func parseAddressFamily(config *configuration.GatewayConfig, meta map[string]string) (string, time.Duration, error) {
+	family := config.TargetAddressFamily
+	if value, exists := meta[MetaAddressFamily]; exists {
+		family = value
+	}
+	switch family {
+	case "", FamilyIPv4, FamilyIPv6:
+	default:
+		return "", 0, fmt.Errorf("unknown %s '%s'", MetaAddressFamily, family)
+	}
+
+	delay := config.TargetHappyEyeballsDelay
+	if value, exists := meta[MetaHappyEyeballsDelay]; exists {
+		var err error
+		delay, err = time.ParseDuration(value)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid %s '%s': %v", MetaHappyEyeballsDelay, value, err)
+		}
+	}
+	return family, delay, nil
+}
+
+// addressFamily returns the family of a host:port address, or an empty string
+// if the host isn't an IP address.
+func addressFamily(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
+	}
+}
+
+// mixedFamilies returns true if the addresses include both IPv4 and IPv6
+// addresses.
+func mixedFamilies(addresses []string) bool {
+	var ipv4, ipv6 bool
+	for _, address := range addresses {
+		switch addressFamily(address) {
+		case FamilyIPv4:
+			ipv4 = true
+		case FamilyIPv6:
+			ipv6 = true
+		}
+	}
+	return ipv4 && ipv6
+}
+
+// orderByFamily orders IP addresses as described in RFC 8305: the families
+// alternate, starting with the preferred family, and the order of the
+// addresses of each family is kept. The family of the first address is
+// preferred if preferred is empty. The addresses are returned unchanged if
+// any of them isn't an IP address.
+func orderByFamily(addresses []string, preferred string) []string {
+	if len(addresses) == 0 {
+		return addresses
+	}
+	if preferred == "" {
+		preferred = addressFamily(addresses[0])
+	}
+	var first, second []string
+	for _, address := range addresses {
+		switch addressFamily(address) {
+		case "":
+			return addresses
+		case preferred:
+			first = append(first, address)
+		default:
+			second = append(second, address)
+		}
+	}
+	ordered := make([]string, 0, len(addresses))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+	return ordered
+}
+
+// dialRace dials the destination addresses in order, starting the next
+// attempt when the previous one fails or hasn't connected within the Happy
+// Eyeballs delay, and returns the first connection made. The connections of
+// the other attempts are closed.
+func (c *gatewayClient) dialRace(ctx context.Context, d client.Destination) (*grpc.ClientConn, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn    *grpc.ClientConn
+		address string
+		err     error
+	}
+	results := make(chan result, len(d.Addrs))
+	next, pending := 0, 0
+	start := func() {
+		address := d.Addrs[next]
+		next++
+		pending++
+		go func() {
+			_, span := tracing.Start(ctx, "target.dial")
+			span.SetAttribute("net.peer.address", address)
+			conn, err := c.dial(ctx, d, address)
+			span.SetError(err)
+			span.End()
+			results <- result{conn: conn, address: address, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(c.happyEyeballsDelay)
+	defer timer.Stop()
+	resetTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(c.happyEyeballsDelay)
+	}
+
+	start()
+	var errs []string
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				cancel()
+				go func(pending int) {
+					for i := 0; i < pending; i++ {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, r.address, nil
+			}
+			errs = append(errs, r.err.Error())
+			if next < len(d.Addrs) && ctx.Err() == nil {
+				start()
+				resetTimer()
+			}
+		case <-timer.C:
+			if next < len(d.Addrs) && ctx.Err() == nil {
+				start()
+				timer.Reset(c.happyEyeballsDelay)
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("unable to connect to any address: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestAddressFamily(t *testing.T) {
+	assertion := assert.New(t)
+
+	assertion.Equal(FamilyIPv4, addressFamily("192.0.2.1:9339"))
+	assertion.Equal(FamilyIPv6, addressFamily("[2001:db8::1]:9339"))
+	assertion.Equal(FamilyIPv6, addressFamily("2001:db8::1"))
+	assertion.Equal("", addressFamily("router1:9339"))
+	assertion.Equal("", addressFamily(""))
+
+	assertion.True(mixedFamilies([]string{"192.0.2.1:9339", "[2001:db8::1]:9339"}))
+	assertion.False(mixedFamilies([]string{"192.0.2.1:9339", "192.0.2.2:9339"}))
+	assertion.False(mixedFamilies([]string{"router1:9339", "[2001:db8::1]:9339"}))
+}
+
+func TestOrderByFamily(t *testing.T) {
+	assertion := assert.New(t)
+
+	addresses := []string{"192.0.2.1:9339", "192.0.2.2:9339", "[2001:db8::1]:9339", "[2001:db8::2]:9339"}
+	assertion.Equal([]string{"192.0.2.1:9339", "[2001:db8::1]:9339", "192.0.2.2:9339", "[2001:db8::2]:9339"}, orderByFamily(addresses, ""))
+	assertion.Equal([]string{"[2001:db8::1]:9339", "192.0.2.1:9339", "[2001:db8::2]:9339", "192.0.2.2:9339"}, orderByFamily(addresses, FamilyIPv6))
+	assertion.Equal([]string{"[2001:db8::1]:9339", "192.0.2.1:9339"}, orderByFamily([]string{"[2001:db8::1]:9339", "192.0.2.1:9339"}, ""))
+
+	withName := []string{"router1:9339", "[2001:db8::1]:9339", "192.0.2.1:9339"}
+	assertion.Equal(withName, orderByFamily(withName, FamilyIPv4))
+	assertion.Empty(orderByFamily(nil, FamilyIPv4))
+}
+
+func TestParseAddressFamily(t *testing.T) {
+	assertion := assert.New(t)
+	config := &configuration.GatewayConfig{TargetAddressFamily: FamilyIPv4, TargetHappyEyeballsDelay: 300 * time.Millisecond}
+
+	family, delay, err := parseAddressFamily(config, nil)
+	assertion.NoError(err)
+	assertion.Equal(FamilyIPv4, family)
+	assertion.Equal(300*time.Millisecond, delay)
+
+	family, delay, err = parseAddressFamily(config, map[string]string{MetaAddressFamily: FamilyIPv6, MetaHappyEyeballsDelay: "0"})
+	assertion.NoError(err)
+	assertion.Equal(FamilyIPv6, family)
+	assertion.Equal(time.Duration(0), delay)
+
+	_, _, err = parseAddressFamily(config, map[string]string{MetaAddressFamily: "ipx"})
+	assertion.Error(err)
+	_, _, err = parseAddressFamily(config, map[string]string{MetaHappyEyeballsDelay: "soon"})
+	assertion.Error(err)
+}
+
+func TestGatewayClient_dialRace(t *testing.T) {
+	assertion := assert.New(t)
+
+	server, address := startTestServer(t)
+	defer server.Stop()
+	// 100::/64 is the discard prefix: the attempt either fails or never
+	// connects, so the IPv4 address is tried after the delay.
+	d := client.Destination{Addrs: []string{"[100::1]:9339", address}, Timeout: 2 * time.Second}
+
+	c := newGatewayClient()
+	c.happyEyeballsDelay = 50 * time.Millisecond
+	start := time.Now()
+	conn, connected, err := c.dialAny(context.Background(), d)
+	assertion.NoError(err)
+	assertion.Equal(address, connected)
+	assertion.True(time.Since(start) < d.Timeout)
+	_ = conn.Close()
+
+	_, _, err = c.dialAny(context.Background(), client.Destination{Addrs: []string{"[100::1]:9339", closedAddress(t)}, Timeout: 100 * time.Millisecond})
+	assertion.Error(err)
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// MetaGateway marks a target as another gnmi-gateway whose northbound gNMI
+// server is subscribed to, e.g. a regional gateway feeding a global one. The
+// notifications of a gateway target are cached under the target names in
+// their prefixes instead of the gateway target's name.
+const MetaGateway = "Gateway"
+
+// FederationMetadataKey is the gRPC metadata key with the FederationID of a
+// gateway subscribing to another gateway with a gateway target.
+const FederationMetadataKey = "gnmi-gateway-federation"
+
+// metaOrigin is the comma-separated list of the gateways the data of a target
+// passed through, starting with the gateway connected to the target. It is
+// sent to subscribed gateways ahead of the target's first notification and
+// isn't inserted into the cache.
+const metaOrigin = "origin"
+
+// FederationID returns the ID this gateway uses in a federation of gateways:
+// the FederationID configuration, or the hostname if it isn't set.
+func FederationID(config *configuration.GatewayConfig) string {
+	if config.FederationID != "" {
+		return config.FederationID
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return config.ServerAddress + ":" + strconv.Itoa(config.ServerPort)
+}
+
+// OriginNotification returns the notification with the origin of a target
+// that is sent to a subscribed gateway.
+func OriginNotification(target string, origin []string) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Timestamp: time.Now().UnixNano(),
+		Prefix:    &gnmipb.Path{Target: target},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: metaRoot}, {Name: metaGateway}, {Name: metaOrigin}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: strings.Join(origin, ",")}},
+		}},
+	}
+}
+
+// originOf returns the origin in a notification sent by OriginNotification,
+// if it is one.
+func originOf(notification *gnmipb.Notification) ([]string, bool) {
+	if len(notification.GetPrefix().GetElem()) != 0 || len(notification.GetUpdate()) != 1 {
+		return nil, false
+	}
+	u := notification.GetUpdate()[0]
+	elem := u.GetPath().GetElem()
+	if len(elem) != 3 || elem[0].Name != metaRoot || elem[1].Name != metaGateway || elem[2].Name != metaOrigin {
+		return nil, false
+	}
+	return strings.Split(u.GetVal().GetStringVal(), ","), true
+}
+
+// federatedRequest returns the request sent to a gateway target. Requests
+// without a prefix target subscribe to all the targets of the gateway.
+func federatedRequest(request *gnmipb.SubscribeRequest) *gnmipb.SubscribeRequest {
+	if request.GetSubscribe().GetPrefix().GetTarget() != "" {
+		return request
+	}
+	request = proto.Clone(request).(*gnmipb.SubscribeRequest)
+	if request.GetSubscribe() == nil {
+		request.Request = &gnmipb.SubscribeRequest_Subscribe{Subscribe: &gnmipb.SubscriptionList{}}
+	}
+	if request.GetSubscribe().Prefix == nil {
+		request.GetSubscribe().Prefix = &gnmipb.Path{}
+	}
+	request.GetSubscribe().Prefix.Target = "*"
+	return request
+}
+
+// checkOrigin records the origin of the targets received from a gateway
+// target. It returns false if the notification must not be inserted into the
+// cache: origin notifications and the notifications of targets whose origin
+// contains this gateway, which would otherwise loop back into the cache.
+func (t *ConnectionState) checkOrigin(notification *gnmipb.Notification) bool {
+	target := notification.GetPrefix().GetTarget()
+	t.originsMutex.Lock()
+	defer t.originsMutex.Unlock()
+	if t.origins == nil {
+		t.origins = make(map[string][]string)
+		t.looped = make(map[string]bool)
+	}
+	origin, ok := originOf(notification)
+	if !ok {
+		if t.looped[target] {
+			t.counterFederationLoops.Increment()
+			return false
+		}
+		return true
+	}
+	self := FederationID(t.config)
+	for _, id := range origin {
+		if id == self {
+			if !t.looped[target] {
+				t.config.Log.Warn().Msgf("Target %s: dropping target %s: federation loop through %s", t.name, target, strings.Join(origin, ","))
+			}
+			t.looped[target] = true
+			delete(t.origins, target)
+			return false
+		}
+	}
+	t.origins[target] = origin
+	delete(t.looped, target)
+	return false
+}
+
+// resetOrigins forgets the origins received from a gateway target. The
+// gateway sends them again on the next connection.
+func (t *ConnectionState) resetOrigins() {
+	t.originsMutex.Lock()
+	t.origins = nil
+	t.looped = nil
+	t.originsMutex.Unlock()
+}
+
+// origin returns the origin of a target received from a gateway target.
+func (t *ConnectionState) origin(target string) ([]string, bool) {
+	t.originsMutex.Lock()
+	defer t.originsMutex.Unlock()
+	origin, exists := t.origins[target]
+	return origin, exists
+}
+
+// Origin returns the gateways the data of the named target passed through
+// before reaching this gateway, starting with the gateway connected to the
+// target. It is empty for the targets this gateway connects to.
+func (c *ZookeeperConnectionManager) Origin(target string) []string {
+	c.connectionsMutex.Lock()
+	defer c.connectionsMutex.Unlock()
+	for _, conn := range c.connections {
+		if !conn.federated {
+			continue
+		}
+		if origin, exists := conn.origin(target); exists {
+			return append([]string(nil), origin...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestFederationID(t *testing.T) {
+	assert.Equal(t, "global", FederationID(&configuration.GatewayConfig{FederationID: "global"}))
+	assert.NotEmpty(t, FederationID(&configuration.GatewayConfig{}))
+}
+
+func TestOriginNotification(t *testing.T) {
+	assertion := assert.New(t)
+
+	notification := OriginNotification("router1", []string{"region1", "global"})
+	assertion.Equal("router1", notification.GetPrefix().GetTarget())
+	origin, ok := originOf(notification)
+	assertion.True(ok)
+	assertion.Equal([]string{"region1", "global"}, origin)
+
+	_, ok = originOf(&gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "router1"},
+		Update: []*gnmipb.Update{intUpdate("x", 1)},
+	})
+	assertion.False(ok)
+}
+
+func TestFederatedRequest(t *testing.T) {
+	assertion := assert.New(t)
+
+	request := &gnmipb.SubscribeRequest{
+		Request: &gnmipb.SubscribeRequest_Subscribe{
+			Subscribe: &gnmipb.SubscriptionList{
+				Subscription: []*gnmipb.Subscription{{Path: &gnmipb.Path{}}},
+			},
+		},
+	}
+	federated := federatedRequest(request)
+	assertion.Equal("*", federated.GetSubscribe().GetPrefix().GetTarget())
+	assertion.Len(federated.GetSubscribe().GetSubscription(), 1)
+	// the request may be shared with other targets
+	assertion.Nil(request.GetSubscribe().GetPrefix())
+
+	request.GetSubscribe().Prefix = &gnmipb.Path{Target: "edge*"}
+	assertion.Equal(request, federatedRequest(request))
+}
+
+func TestConnectionState_checkOrigin(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{FederationID: "global", Log: zerolog.Nop()}
+	mgr, err := NewZookeeperConnectionManagerDefault(config, nil, nil)
+	assertion.NoError(err)
+	state := &ConnectionState{
+		config:      config,
+		connManager: mgr,
+		federated:   true,
+		name:        "region1",
+		queryTarget: "*",
+	}
+	state.InitializeMetrics()
+	mgr.connections["region1"] = state
+
+	update := func(target string) *gnmipb.Notification {
+		return &gnmipb.Notification{
+			Prefix: &gnmipb.Path{Target: target},
+			Update: []*gnmipb.Update{intUpdate("x", 1)},
+		}
+	}
+
+	// origin notifications aren't inserted into the cache
+	assertion.False(state.checkOrigin(OriginNotification("router1", []string{"region1"})))
+	assertion.True(state.checkOrigin(update("router1")))
+	assertion.Equal([]string{"region1"}, mgr.Origin("router1"))
+
+	// the data of router2 already passed through this gateway
+	assertion.False(state.checkOrigin(OriginNotification("router2", []string{"region2", "global", "region1"})))
+	assertion.False(state.checkOrigin(update("router2")))
+	assertion.Nil(mgr.Origin("router2"))
+
+	// the origins are sent again after a reconnect
+	state.resetOrigins()
+	assertion.Nil(mgr.Origin("router1"))
+	assertion.True(state.checkOrigin(update("router2")))
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// metaFencingToken is the fencing token of the cluster lock held by the
+// member connected to the target. It is inserted into the cache when the
+// target connects, so that it is replicated to the other cluster members
+// ahead of the target's updates.
+const metaFencingToken = "fencingToken"
+
+// errFenced is returned for the updates of a target whose lock was lost, so
+// that the connection is closed instead of inserting stale updates.
+var errFenced = errors.New("the cluster lock of the target was lost")
+
+// fencingTokens tracks the member that holds the greatest fencing token seen
+// for each target. Updates replicated from other cluster members are only
+// accepted from that member, so a member that still believes it holds the
+// lock of a target after its session flapped can't overwrite the updates of
+// the new lock holder.
+type fencingTokens struct {
+	mutex  sync.Mutex
+	owners map[string]fencingOwner
+}
+
+type fencingOwner struct {
+	member string
+	token  uint64
+}
+
+func newFencingTokens() *fencingTokens {
+	return &fencingTokens{owners: make(map[string]fencingOwner)}
+}
+
+// observe records the fencing token of a target published by a member. It
+// returns false if a greater token was already seen for the target.
+func (f *fencingTokens) observe(target string, member string, token uint64) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	owner, exists := f.owners[target]
+	if exists && token < owner.token {
+		return false
+	}
+	f.owners[target] = fencingOwner{member: member, token: token}
+	return true
+}
+
+// allowed returns true if the updates of a target replicated from a member
+// can be accepted: no fencing token was seen for the target or the member
+// published the greatest one.
+func (f *fencingTokens) allowed(target string, member string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	owner, exists := f.owners[target]
+	return !exists || owner.member == member
+}
+
+// remove forgets the fencing token of a target.
+func (f *fencingTokens) remove(target string) {
+	f.mutex.Lock()
+	delete(f.owners, target)
+	f.mutex.Unlock()
+}
+
+// publishFencingToken inserts the fencing token of the target lock into the
+// cache.
+func (t *ConnectionState) publishFencingToken() error {
+	return t.gnmiUpdate(t.targetCache, &gnmipb.Notification{
+		Timestamp: time.Now().UnixNano(),
+		Prefix:    &gnmipb.Path{Target: t.name},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: metaRoot}, {Name: metaGateway}, {Name: metaFencingToken}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: t.fencingToken}},
+		}},
+	})
+}
+
+// fenced returns true if the lock the target connected with is no longer
+// held. Locks return a zero token once they are lost.
+func (t *ConnectionState) fenced() bool {
+	if !t.useLock || t.lock == nil {
+		return false
+	}
+	token := t.lock.Token()
+	return token == 0 || token != t.fencingToken
+}
+
+// fencingTokenOf returns the fencing token in a notification replicated from
+// another cluster member, if it contains one.
+func fencingTokenOf(notification *gnmipb.Notification) (uint64, bool) {
+	if len(notification.GetPrefix().GetElem()) != 0 {
+		return 0, false
+	}
+	for _, u := range notification.Update {
+		elem := u.GetPath().GetElem()
+		if len(elem) == 3 && elem[0].Name == metaRoot && elem[1].Name == metaGateway && elem[2].Name == metaFencingToken {
+			return u.GetVal().GetUintVal(), true
+		}
+	}
+	return 0, false
+}
+
+// checkReplicated returns false if a notification replicated from another
+// cluster member must be dropped because a different member holds a greater
+// fencing token for the target.
+func (t *ConnectionState) checkReplicated(notification *gnmipb.Notification) bool {
+	if t.fencing == nil {
+		return true
+	}
+	target := notification.GetPrefix().GetTarget()
+	if token, ok := fencingTokenOf(notification); ok {
+		if !t.fencing.observe(target, t.name, token) {
+			return false
+		}
+	}
+	return t.fencing.allowed(target, t.name)
+}
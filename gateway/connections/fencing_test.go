@@ -0,0 +1,109 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/cache"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/locking"
+)
+
+func fencingTokenNotification(target string, token uint64) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: target},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: metaRoot}, {Name: metaGateway}, {Name: metaFencingToken}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: token}},
+		}},
+	}
+}
+
+func TestFencingTokens(t *testing.T) {
+	assertion := assert.New(t)
+
+	f := newFencingTokens()
+	assertion.True(f.allowed("a", "member1"))
+	assertion.True(f.observe("a", "member1", 5))
+	assertion.True(f.allowed("a", "member1"))
+	assertion.False(f.allowed("a", "member2"))
+
+	// a greater token moves the target to the new lock holder
+	assertion.True(f.observe("a", "member2", 6))
+	assertion.False(f.allowed("a", "member1"))
+	assertion.False(f.observe("a", "member1", 5))
+	assertion.True(f.allowed("a", "member2"))
+
+	f.remove("a")
+	assertion.True(f.allowed("a", "member1"))
+}
+
+func TestConnectionState_checkReplicated(t *testing.T) {
+	assertion := assert.New(t)
+
+	fencing := newFencingTokens()
+	stale := &ConnectionState{name: "member1", clusterMember: true, fencing: fencing}
+	current := &ConnectionState{name: "member2", clusterMember: true, fencing: fencing}
+	update := &gnmipb.Notification{Prefix: &gnmipb.Path{Target: "a"}}
+
+	assertion.True(stale.checkReplicated(fencingTokenNotification("a", 5)))
+	assertion.True(stale.checkReplicated(update))
+	assertion.True(current.checkReplicated(fencingTokenNotification("a", 6)))
+	assertion.True(current.checkReplicated(update))
+	assertion.False(stale.checkReplicated(update))
+	assertion.False(stale.checkReplicated(fencingTokenNotification("a", 5)))
+	// other targets aren't affected
+	assertion.True(stale.checkReplicated(&gnmipb.Notification{Prefix: &gnmipb.Path{Target: "b"}}))
+}
+
+func TestConnectionState_fenced(t *testing.T) {
+	assertion := assert.New(t)
+
+	lock := locking.NewNonBlockingLock("fenced-target", "127.0.0.1:0")
+	acquired, err := lock.Try()
+	assertion.True(acquired)
+	assertion.NoError(err)
+
+	c := cache.New(nil)
+	state := &ConnectionState{
+		config:       configuration.NewDefaultGatewayConfig(),
+		name:         "a",
+		lock:         lock,
+		useLock:      true,
+		fencingToken: lock.Token(),
+		targetCache:  c.Add("a"),
+	}
+	assertion.False(state.fenced())
+	assertion.NoError(state.publishFencingToken())
+	var published uint64
+	assertion.NoError(c.Query("a", []string{metaRoot, metaGateway, metaFencingToken}, func(_ []string, l *ctree.Leaf, _ interface{}) error {
+		published, _ = fencingTokenOf(l.Value().(*gnmipb.Notification))
+		return nil
+	}))
+	assertion.Equal(lock.Token(), published)
+
+	assertion.NoError(lock.Unlock())
+	assertion.True(state.fenced())
+
+	// a lost lock is fenced even if the target connected without a token
+	state.fencingToken = 0
+	assertion.True(state.fenced())
+}
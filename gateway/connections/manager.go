@@ -33,28 +33,106 @@
 //				  are not provided this field will have no effect.
 //		NoLock	- Set this field to disable locking for the target. If clustering is not
 //				  enabled this field will have no effect.
+//		Disabled	- Set this field to keep the target configured without connecting
+//				  to it, e.g. during maintenance.
+//		Gateway	- Set this field if the target is another gnmi-gateway. Its targets are
+//				  cached under their own names.
 package connections
 
 import (
-	"github.com/openconfig/gnmi/cache"
+	"time"
+
 	targetpb "github.com/openconfig/gnmi/proto/target"
+
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 )
 
 // ConnectionManager provides an interface for connecting/disconnecting to/from
 // gNMI targets and forwards updates to a gNMI cache.
 // Start must be called to start listening for changes on the TargetControlChan.
 type ConnectionManager interface {
-	// Cache returns the *cache.Cache that contains gNMI Notifications.
-	Cache() *cache.Cache
+	// Cache returns the *shardedcache.Cache that contains gNMI Notifications.
+	Cache() *shardedcache.Cache
+	// DisableTarget disconnects from the named target and doesn't connect to
+	// it again until EnableTarget is called. The target's cached data is
+	// kept unless flush is true.
+	DisableTarget(target string, flush bool) error
+	// EnableTarget connects to a target disabled with DisableTarget.
+	EnableTarget(target string) error
 	// Forwardable returns true if this instance of the ConnectionManager
 	// holds the lock for a non-cluster member connection for the named target.
 	Forwardable(target string) bool
+	// Origin returns the gateways the data of the named target passed
+	// through before reaching this gateway if it was received from a
+	// gateway target.
+	Origin(target string) []string
+	// ReconnectTarget closes the connection to the named target so that it
+	// connects again, e.g. to clear a target's state without restarting the
+	// gateway.
+	ReconnectTarget(target string) error
 	// Start will start the loop to listen for TargetConnectionControl messages
 	// on TargetControlChan.
 	Start() error
+	// StartCapture will write every raw SubscribeResponse received from the
+	// named target to file in the provided format until StopCapture is called.
+	StartCapture(target string, file string, format capture.Format) error
+	// StopCapture stops a capture started with StartCapture.
+	StopCapture(target string) error
+	// Stop disconnects from all targets. Targets received on
+	// TargetControlChan after Stop is called are ignored.
+	Stop()
 	// TargetControlChan returns an input channel for TargetConnectionControl
 	// messages.
 	TargetControlChan() chan<- *TargetConnectionControl
+	// TargetErrors returns the most recent errors returned by the named
+	// target's connection, oldest first.
+	TargetErrors(target string) ([]TargetError, error)
+	// TargetTenant returns the tenant the named target is assigned to with
+	// the Tenant meta field, or an empty string.
+	TargetTenant(target string) string
+	// Targets returns the connection status of each configured target.
+	Targets() []TargetStatus
+}
+
+// TargetStatus is the connection status of a target.
+type TargetStatus struct {
+	Name string `json:"name"`
+	// Addresses are the configured addresses of the target.
+	Addresses []string `json:"addresses"`
+	// Address is the address currently in use, if connected.
+	Address string `json:"address,omitempty"`
+	// AddressFamily is the family of the address in use, ipv4 or ipv6.
+	AddressFamily string `json:"addressFamily,omitempty"`
+	// Capabilities is the result of the Capabilities probe, if enabled.
+	Capabilities  *TargetCapabilities `json:"capabilities,omitempty"`
+	ClusterMember bool                `json:"clusterMember"`
+	Connected     bool                `json:"connected"`
+	// ConnectedFor is the number of seconds since the target connected.
+	ConnectedFor float64 `json:"connectedFor,omitempty"`
+	// Disabled is set while the target is disabled for maintenance.
+	Disabled bool `json:"disabled"`
+	// LastError is the last error returned by the target connection.
+	LastError string `json:"lastError,omitempty"`
+	// LastErrorCode is the gRPC status code of the last error and
+	// LastErrorTime is when it was returned.
+	LastErrorCode string     `json:"lastErrorCode,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+	// Maintenance is the name of the active maintenance window, if any.
+	Maintenance string `json:"maintenance,omitempty"`
+	// Pool is the name of the connection pool, empty for the default pool.
+	Pool string `json:"pool,omitempty"`
+	// Quarantined is set after repeated authentication failures.
+	Quarantined bool `json:"quarantined"`
+	// Received is the number of messages received from the target and
+	// ReceivedBytes is their total encoded size.
+	Received      uint64 `json:"received"`
+	ReceivedBytes uint64 `json:"receivedBytes"`
+	// State is the connection state, as published in /meta/gateway/state.
+	State  string `json:"state"`
+	Synced bool   `json:"synced"`
+	// Tenant is the tenant the target is assigned to, if any.
+	Tenant string `json:"tenant,omitempty"`
 }
 
 // TargetConnectionControl messages are used to insert/update and remove targets in
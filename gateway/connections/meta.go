@@ -0,0 +1,346 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+)
+
+// Gateway meta leaves are published for each target below /meta/gateway,
+// alongside the cache meta leaves such as /meta/sync and /meta/latency.
+const (
+	metaRoot    = "meta"
+	metaGateway = "gateway"
+
+	// metaState is the connection state of the target.
+	metaState = "state"
+	// metaUpdatesPerSecond is the rate of notifications received from the
+	// target since the last meta update.
+	metaUpdatesPerSecond = "updatesPerSecond"
+	// metaRejected is the total number of notifications rejected.
+	metaRejected = "rejected"
+	// metaLastError is the last error returned by the target connection.
+	metaLastError = "lastError"
+	// metaLastErrorCode is the gRPC status code of the last error.
+	metaLastErrorCode = "lastErrorCode"
+	// metaLastErrorTime is the time of the last error in nanoseconds since
+	// the epoch, zero if the target connection hasn't returned an error.
+	metaLastErrorTime = "lastErrorTime"
+	// metaAddress is the target address currently in use.
+	metaAddress = "address"
+	// metaAddressFamily is the family of the address in use, ipv4 or ipv6.
+	metaAddressFamily = "addressFamily"
+	// metaMember is the cluster member connected to the target.
+	metaMember = "member"
+	// metaClockSkew is the average difference between the receive time and
+	// the notification timestamps in nanoseconds.
+	metaClockSkew = "clockSkew"
+	// metaMaintenance is the name of the active maintenance window, empty
+	// if the target isn't in a maintenance window.
+	metaMaintenance = "maintenance"
+	// metaSynced is true once the target has sent its initial sync. It is
+	// inserted right after the sync so that cache clients such as the
+	// exporter sync barriers see it after all of the initial updates.
+	metaSynced = "synced"
+)
+
+// Target connection states published in /meta/gateway/state.
+const (
+	stateDisconnected = "disconnected"
+	stateConnecting   = "connecting"
+	stateConnected    = "connected"
+	stateSynced       = "synced"
+	stateQuarantined  = "quarantined"
+	stateMaintenance  = "maintenance"
+)
+
+// runMetadata updates the cache and gateway meta leaves for every target each
+// interval.
+func (c *ZookeeperConnectionManager) runMetadata(interval time.Duration) {
+	member := c.config.ServerAddress + ":" + strconv.Itoa(c.config.ServerPort)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-c.stop:
+			return
+		}
+		c.cache.UpdateMetadata()
+		c.connectionsMutex.Lock()
+		for _, conn := range c.connections {
+			if !conn.publishesMeta() {
+				continue
+			}
+			if err := conn.updateMeta(now, interval, member, c.maintenance.Active(conn.name, now)); err != nil {
+				c.config.Log.Debug().Msgf("Target %s: unable to update meta leaves: %v", conn.name, err)
+			}
+		}
+		c.connectionsMutex.Unlock()
+	}
+}
+
+// publishesMeta returns true if this instance publishes the gateway meta
+// leaves for the target. Cluster members and other instances that don't hold
+// the target lock don't publish meta leaves, unless the target is disabled.
+func (t *ConnectionState) publishesMeta() bool {
+	if t.clusterMember || t.queryTarget == "*" {
+		return false
+	}
+	// no cluster member holds the lock of a disabled target
+	return !t.useLock || t.ConnectionLockAcquired || t.isDisabled()
+}
+
+// updateMeta inserts the gateway meta leaves for the target into the cache.
+func (t *ConnectionState) updateMeta(now time.Time, interval time.Duration, member string, maintenanceWindow string) error {
+	received := atomic.LoadUint64(&t.received)
+	rate := float32(received-t.receivedAtLastMeta) / float32(interval.Seconds())
+	t.receivedAtLastMeta = received
+
+	leaf := func(name string, val *gnmipb.TypedValue) *gnmipb.Update {
+		return &gnmipb.Update{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: metaRoot}, {Name: metaGateway}, {Name: name}}},
+			Val:  val,
+		}
+	}
+	stringVal := func(s string) *gnmipb.TypedValue {
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: s}}
+	}
+	var lastErrorCode string
+	var lastErrorTime int64
+	if last := t.lastTargetError(); last != nil {
+		lastErrorCode = last.Code
+		lastErrorTime = last.Time.UnixNano()
+	}
+	return t.gnmiUpdate(t.targetCache, &gnmipb.Notification{
+		Timestamp: now.UnixNano(),
+		Prefix:    &gnmipb.Path{Target: t.name},
+		Update: []*gnmipb.Update{
+			leaf(metaState, stringVal(t.State())),
+			leaf(metaUpdatesPerSecond, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_FloatVal{FloatVal: rate}}),
+			leaf(metaRejected, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: atomic.LoadUint64(&t.rejected)}}),
+			leaf(metaLastError, stringVal(t.LastError())),
+			leaf(metaLastErrorCode, stringVal(lastErrorCode)),
+			leaf(metaLastErrorTime, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: lastErrorTime}}),
+			leaf(metaAddress, stringVal(t.Address())),
+			leaf(metaAddressFamily, stringVal(addressFamily(t.Address()))),
+			leaf(metaMember, stringVal(member)),
+			leaf(metaClockSkew, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: atomic.LoadInt64(&t.skew)}}),
+			leaf(metaMaintenance, stringVal(maintenanceWindow)),
+			leaf(metaSynced, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: t.isSynced()}}),
+		},
+	})
+}
+
+// publishSynced inserts /meta/gateway/synced into the cache after the target
+// has synced. The leaf is removed with the rest of the target's data when the
+// cache is reset.
+func (t *ConnectionState) publishSynced() error {
+	if !t.publishesMeta() {
+		return nil
+	}
+	return t.gnmiUpdate(t.targetCache, &gnmipb.Notification{
+		Timestamp: time.Now().UnixNano(),
+		Prefix:    &gnmipb.Path{Target: t.name},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: metaRoot}, {Name: metaGateway}, {Name: metaSynced}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: true}},
+		}},
+	})
+}
+
+// State returns the connection state of the target: disconnected,
+// connecting, connected, synced, quarantined, or maintenance.
+func (t *ConnectionState) State() string {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	switch {
+	case t.disabled:
+		return stateMaintenance
+	case t.quarantined:
+		return stateQuarantined
+	case t.synced:
+		return stateSynced
+	case t.connected:
+		return stateConnected
+	case t.connecting:
+		return stateConnecting
+	default:
+		return stateDisconnected
+	}
+}
+
+// reject counts a rejected notification.
+func (t *ConnectionState) reject() {
+	t.counterRejected.Increment()
+	atomic.AddUint64(&t.rejected, 1)
+}
+
+// setError records the last error returned by the target connection and
+// adds it to the target's error history.
+func (t *ConnectionState) setError(err error) {
+	if err == nil {
+		return
+	}
+	t.stateMutex.Lock()
+	t.err = err
+	t.recordError(err, time.Now())
+	t.stateMutex.Unlock()
+	if isAuthError(err) {
+		t.counterAuthFailures.Increment()
+	}
+}
+
+// setQuarantined records that the target was quarantined, or released from
+// quarantine, after repeated authentication failures.
+func (t *ConnectionState) setQuarantined(quarantined bool, err error) {
+	t.stateMutex.Lock()
+	t.quarantined = quarantined
+	t.stateMutex.Unlock()
+	if quarantined {
+		t.gaugeQuarantined.Set(1)
+		events.Publish(events.TargetQuarantined, t.name, t.Address(), fmt.Sprint(err))
+		if t.config.TargetAuthFailureStop {
+			t.config.Log.Error().Msgf("Target %s: Quarantined after %d authentication failures; not retrying until the target configuration changes: %v", t.name, t.config.TargetAuthFailureLimit, err)
+		} else {
+			t.config.Log.Error().Msgf("Target %s: Quarantined after %d authentication failures: %v", t.name, t.config.TargetAuthFailureLimit, err)
+		}
+	} else {
+		t.gaugeQuarantined.Set(0)
+		events.Publish(events.TargetReleased, t.name, t.Address(), "")
+		t.config.Log.Info().Msgf("Target %s: Released from quarantine", t.name)
+	}
+}
+
+// setDisabled records that the target was disabled or enabled.
+func (t *ConnectionState) setDisabled(disabled bool) {
+	t.stateMutex.Lock()
+	t.disabled = disabled
+	t.stateMutex.Unlock()
+}
+
+// isDisabled returns true if the target is disabled for maintenance.
+func (t *ConnectionState) isDisabled() bool {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	return t.disabled
+}
+
+// isQuarantined returns true if the target is quarantined.
+func (t *ConnectionState) isQuarantined() bool {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	return t.quarantined
+}
+
+// isConnected returns true if a message was received since the target last
+// disconnected.
+func (t *ConnectionState) isConnected() bool {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	return t.connected
+}
+
+// isSynced returns true if the target sent a sync response since it last
+// disconnected.
+func (t *ConnectionState) isSynced() bool {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	return t.synced
+}
+
+// isStopped returns true once the target was disconnected with disconnect and
+// shouldn't be connected again.
+func (t *ConnectionState) isStopped() bool {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	return t.stopped
+}
+
+// setConnecting records that a connection attempt was started.
+func (t *ConnectionState) setConnecting() {
+	t.stateMutex.Lock()
+	t.connecting = true
+	t.stateMutex.Unlock()
+}
+
+// setConnected records that a message was received from the target. It
+// returns true if the target wasn't connected before.
+func (t *ConnectionState) setConnected() bool {
+	t.stateMutex.Lock()
+	defer t.stateMutex.Unlock()
+	if t.connected {
+		return false
+	}
+	t.connected = true
+	t.connectedAt = time.Now()
+	return true
+}
+
+// setDisconnected records that the connection to the target was lost. It
+// returns true if the target was connected before.
+func (t *ConnectionState) setDisconnected() bool {
+	t.stateMutex.Lock()
+	defer t.stateMutex.Unlock()
+	wasConnected := t.connected
+	t.connected = false
+	t.connectedAt = time.Time{}
+	t.synced = false
+	return wasConnected
+}
+
+// setSynced records that the target sent a sync response.
+func (t *ConnectionState) setSynced() {
+	t.stateMutex.Lock()
+	t.synced = true
+	t.stateMutex.Unlock()
+}
+
+// setStopped records that the target shouldn't be connected again.
+func (t *ConnectionState) setStopped() {
+	t.stateMutex.Lock()
+	t.stopped = true
+	t.stateMutex.Unlock()
+}
+
+// ConnectedFor returns the time since the target connected, or zero if it
+// isn't connected.
+func (t *ConnectionState) ConnectedFor() time.Duration {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	if !t.connected {
+		return 0
+	}
+	return time.Since(t.connectedAt)
+}
+
+// LastError returns the last error returned by the target connection.
+func (t *ConnectionState) LastError() string {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
+	if t.err == nil {
+		return ""
+	}
+	return t.err.Error()
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/cache"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestConnectionState_updateMeta(t *testing.T) {
+	assertion := assert.New(t)
+
+	c := cache.New(nil)
+	state := &ConnectionState{
+		config:      configuration.NewDefaultGatewayConfig(),
+		name:        "a",
+		queryTarget: "a",
+		targetCache: c.Add("a"),
+		connected:   true,
+		received:    20,
+	}
+	state.InitializeMetrics()
+	state.reject()
+	state.setError(errors.New("connection refused"))
+	assertion.True(state.publishesMeta())
+
+	assertion.NoError(state.updateMeta(time.Now(), 10*time.Second, "10.0.0.1:9339", "weekly"))
+
+	values := make(map[string]*gnmipb.TypedValue)
+	err := c.Query("a", []string{metaRoot, metaGateway, "*"}, func(path []string, l *ctree.Leaf, _ interface{}) error {
+		notification := l.Value().(*gnmipb.Notification)
+		values[path[len(path)-1]] = notification.Update[0].Val
+		return nil
+	})
+	assertion.NoError(err)
+	assertion.Equal(stateConnected, values[metaState].GetStringVal())
+	assertion.Equal(float32(2), values[metaUpdatesPerSecond].GetFloatVal())
+	assertion.Equal(uint64(1), values[metaRejected].GetUintVal())
+	assertion.Equal("connection refused", values[metaLastError].GetStringVal())
+	assertion.Equal("Unknown", values[metaLastErrorCode].GetStringVal())
+	assertion.NotZero(values[metaLastErrorTime].GetIntVal())
+	assertion.Equal("10.0.0.1:9339", values[metaMember].GetStringVal())
+	assertion.Equal("weekly", values[metaMaintenance].GetStringVal())
+	assertion.False(values[metaSynced].GetBoolVal())
+
+	state.clusterMember = true
+	assertion.False(state.publishesMeta())
+}
+
+func TestConnectionState_publishSynced(t *testing.T) {
+	assertion := assert.New(t)
+
+	c := cache.New(nil)
+	state := &ConnectionState{
+		config:      configuration.NewDefaultGatewayConfig(),
+		name:        "a",
+		queryTarget: "a",
+		targetCache: c.Add("a"),
+	}
+	var published []*gnmipb.Notification
+	c.SetClient(func(l *ctree.Leaf) {
+		published = append(published, l.Value().(*gnmipb.Notification))
+	})
+
+	assertion.NoError(state.publishSynced())
+	if assertion.Len(published, 1) {
+		update := published[0].Update[0]
+		assertion.Equal([]string{metaRoot, metaGateway, metaSynced}, []string{
+			update.Path.Elem[0].Name, update.Path.Elem[1].Name, update.Path.Elem[2].Name,
+		})
+		assertion.True(update.Val.GetBoolVal())
+	}
+
+	// cluster members replicate the leaf from the member connected to the target
+	state.clusterMember = true
+	assertion.NoError(state.publishSynced())
+	assertion.Len(published, 1)
+}
+
+func TestConnectionState_State(t *testing.T) {
+	assertion := assert.New(t)
+
+	state := &ConnectionState{config: configuration.NewDefaultGatewayConfig(), name: "a"}
+	assertion.Equal(stateDisconnected, state.State())
+	state.setConnecting()
+	assertion.Equal(stateConnecting, state.State())
+	assertion.Equal(time.Duration(0), state.ConnectedFor())
+
+	assertion.True(state.setConnected())
+	assertion.False(state.setConnected())
+	assertion.Equal(stateConnected, state.State())
+	assertion.True(state.ConnectedFor() >= 0)
+	assertion.False(state.connectedAt.IsZero())
+
+	state.setSynced()
+	assertion.Equal(stateSynced, state.State())
+
+	assertion.True(state.setDisconnected())
+	assertion.False(state.setDisconnected())
+	assertion.False(state.isSynced())
+	assertion.Equal(time.Duration(0), state.ConnectedFor())
+	assertion.Equal(stateConnecting, state.State())
+
+	state.setError(errors.New("connection refused"))
+	assertion.Equal("connection refused", state.LastError())
+}
+
+// TestConnectionState_StateConcurrent is meant to be run with -race.
+func TestConnectionState_StateConcurrent(t *testing.T) {
+	state := &ConnectionState{config: configuration.NewDefaultGatewayConfig(), name: "a"}
+	state.InitializeMetrics()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			state.setConnected()
+			state.setSynced()
+			state.setDisconnected()
+		}
+		state.setStopped()
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = state.State()
+			_ = state.ConnectedFor()
+			_ = state.LastError()
+		}
+	}()
+	wg.Wait()
+	assert.True(t, state.isStopped())
+}
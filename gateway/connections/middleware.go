@@ -0,0 +1,91 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// NotificationHandler handles a notification received from the named target.
+type NotificationHandler func(ctx context.Context, target string, notification *gnmipb.Notification) error
+
+// Middleware is a stage of the pipeline that notifications received from
+// targets pass through before they are inserted into the cache. A stage can
+// filter, rewrite, or enrich the notification before calling next, or drop
+// the notification by returning without calling next. Stages run after the
+// notification has been decoded and its timestamp checked, and before counter
+// rates are derived from it.
+type Middleware func(next NotificationHandler) NotificationHandler
+
+// MiddlewareRegistry contains the middlewares that can be enabled by name
+// with the Middlewares configuration.
+var MiddlewareRegistry = make(map[string]func(config *configuration.GatewayConfig) (Middleware, error))
+
+// RegisterMiddleware makes a middleware available to the Middlewares
+// configuration under the given name.
+func RegisterMiddleware(name string, new func(config *configuration.GatewayConfig) (Middleware, error)) {
+	MiddlewareRegistry[name] = new
+}
+
+// newMiddlewares creates the middlewares named in the Middlewares
+// configuration in order.
+func newMiddlewares(config *configuration.GatewayConfig) ([]Middleware, error) {
+	var middlewares []Middleware
+	for _, name := range config.Middlewares {
+		newMiddleware, exists := MiddlewareRegistry[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown middleware: %s", name)
+		}
+		middleware, err := newMiddleware(config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create middleware %s: %v", name, err)
+		}
+		middlewares = append(middlewares, middleware)
+	}
+	return middlewares, nil
+}
+
+// chainMiddleware returns a handler that passes notifications through the
+// middlewares in order and then to handler.
+func chainMiddleware(handler NotificationHandler, middlewares []Middleware) NotificationHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// newNotificationHandler returns the handler for the notifications received
+// on the connection. The UpdateRejections are always applied first.
+func (t *ConnectionState) newNotificationHandler() NotificationHandler {
+	middlewares := append([]Middleware{t.rejectMiddleware}, t.middlewares...)
+	return chainMiddleware(t.insertNotification, middlewares)
+}
+
+// rejectMiddleware drops the notifications that match the UpdateRejections.
+func (t *ConnectionState) rejectMiddleware(next NotificationHandler) NotificationHandler {
+	return func(ctx context.Context, target string, notification *gnmipb.Notification) error {
+		if t.rejectUpdate(notification) {
+			t.reject()
+			return nil
+		}
+		return next(ctx, target, notification)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestChainMiddleware(t *testing.T) {
+	assertion := assert.New(t)
+
+	var stages []string
+	stage := func(name string, drop bool) Middleware {
+		return func(next NotificationHandler) NotificationHandler {
+			return func(ctx context.Context, target string, notification *gnmipb.Notification) error {
+				stages = append(stages, name)
+				if drop {
+					return nil
+				}
+				return next(ctx, target, notification)
+			}
+		}
+	}
+	var handled int
+	handler := func(_ context.Context, _ string, _ *gnmipb.Notification) error {
+		handled++
+		return nil
+	}
+
+	chained := chainMiddleware(handler, []Middleware{stage("a", false), stage("b", false)})
+	assertion.NoError(chained(context.Background(), "target", &gnmipb.Notification{}))
+	assertion.Equal([]string{"a", "b"}, stages)
+	assertion.Equal(1, handled)
+
+	stages = nil
+	chained = chainMiddleware(handler, []Middleware{stage("a", true), stage("b", false)})
+	assertion.NoError(chained(context.Background(), "target", &gnmipb.Notification{}))
+	assertion.Equal([]string{"a"}, stages)
+	assertion.Equal(1, handled)
+}
+
+func TestNewMiddlewares(t *testing.T) {
+	assertion := assert.New(t)
+
+	RegisterMiddleware("test", func(_ *configuration.GatewayConfig) (Middleware, error) {
+		return func(next NotificationHandler) NotificationHandler { return next }, nil
+	})
+	defer delete(MiddlewareRegistry, "test")
+
+	middlewares, err := newMiddlewares(&configuration.GatewayConfig{Middlewares: []string{"test"}})
+	assertion.NoError(err)
+	assertion.Len(middlewares, 1)
+
+	_, err = newMiddlewares(&configuration.GatewayConfig{Middlewares: []string{"missing"}})
+	assertion.Error(err)
+}
@@ -0,0 +1,351 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/openconfig"
+)
+
+// MetaNormalizeJSON is the target meta field that enables ("true") or
+// disables ("false") JSON value normalization for the target, overriding
+// NormalizeJSON in GatewayConfig.
+const MetaNormalizeJSON = "NormalizeJSON"
+
+// defaultListKeys are the key leaves used for lists that aren't in the
+// OpenConfig schema, in order of preference.
+var defaultListKeys = []string{"name", "index", "id"}
+
+// jsonNormalizer decodes JSON and JSON_IETF encoded update values into one
+// update per leaf so that values are represented the same way regardless of
+// the encoding used by the target.
+type jsonNormalizer struct {
+	// schema is used to find list keys and the types of JSON_IETF values
+	// that are encoded as strings. schema may be nil.
+	schema *openconfig.TypeLookup
+}
+
+// newJSONNormalizer creates a jsonNormalizer for the target. A nil
+// jsonNormalizer is returned if normalization is disabled.
+func newJSONNormalizer(config *configuration.GatewayConfig, meta map[string]string) (*jsonNormalizer, error) {
+	enabled := config.NormalizeJSON
+	if value, exists := meta[MetaNormalizeJSON]; exists {
+		var err error
+		enabled, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaNormalizeJSON, value, err)
+		}
+	}
+	if !enabled {
+		return nil, nil
+	}
+	n := new(jsonNormalizer)
+	if config.OpenConfigDirectory != "" {
+		schema, err := LoadSchema(config.OpenConfigDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load OpenConfig models from %s: %v", config.OpenConfigDirectory, err)
+		}
+		n.schema = schema
+	}
+	return n, nil
+}
+
+// normalize replaces each JSON encoded update in the notification with an
+// update for each leaf in the JSON value. Updates that can't be decoded are
+// left unchanged and the first error is returned.
+func (n *jsonNormalizer) normalize(notification *gnmipb.Notification) error {
+	var firstErr error
+	updates := make([]*gnmipb.Update, 0, len(notification.GetUpdate()))
+	for _, update := range notification.GetUpdate() {
+		var data []byte
+		switch v := update.GetVal().GetValue().(type) {
+		case *gnmipb.TypedValue_JsonIetfVal:
+			data = v.JsonIetfVal
+		case *gnmipb.TypedValue_JsonVal:
+			data = v.JsonVal
+		default:
+			updates = append(updates, update)
+			continue
+		}
+		leaves, err := n.decode(notification.GetPrefix(), update, data)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			updates = append(updates, update)
+			continue
+		}
+		updates = append(updates, leaves...)
+	}
+	notification.Update = updates
+	return firstErr
+}
+
+// decode returns an update for each leaf in the JSON encoded data.
+func (n *jsonNormalizer) decode(prefix *gnmipb.Path, update *gnmipb.Update, data []byte) ([]*gnmipb.Update, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("unable to decode JSON value: %v", err)
+	}
+
+	var leaves []*gnmipb.Update
+	emit := func(elems []*gnmipb.PathElem, val *gnmipb.TypedValue) {
+		leaves = append(leaves, &gnmipb.Update{
+			Path:       &gnmipb.Path{Origin: update.GetPath().GetOrigin(), Elem: elems},
+			Val:        val,
+			Duplicates: update.GetDuplicates(),
+		})
+	}
+	schemaPath := append(elemNames(prefix), elemNames(update.GetPath())...)
+	if err := n.walk(update.GetPath().GetElem(), schemaPath, value, emit); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// walk emits a leaf for each scalar and leaf-list found in value.
+func (n *jsonNormalizer) walk(elems []*gnmipb.PathElem, schemaPath []string, value interface{}, emit func([]*gnmipb.PathElem, *gnmipb.TypedValue)) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			elem := &gnmipb.PathElem{Name: stripModule(name)}
+			childElems := append(append([]*gnmipb.PathElem{}, elems...), elem)
+			childSchemaPath := append(append([]string{}, schemaPath...), elem.Name)
+			if err := n.walk(childElems, childSchemaPath, v[name], emit); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if isObjectList(v) {
+			return n.walkList(elems, schemaPath, v, emit)
+		}
+		if len(v) == 1 && v[0] == nil {
+			// JSON_IETF encodes leaves of the YANG empty type as [null].
+			emit(elems, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: true}})
+			return nil
+		}
+		leafList := &gnmipb.ScalarArray{}
+		for _, element := range v {
+			val, err := n.scalar(schemaPath, element)
+			if err != nil {
+				return err
+			}
+			leafList.Element = append(leafList.Element, val)
+		}
+		emit(elems, &gnmipb.TypedValue{Value: &gnmipb.TypedValue_LeaflistVal{LeaflistVal: leafList}})
+		return nil
+	default:
+		val, err := n.scalar(schemaPath, v)
+		if err != nil {
+			return err
+		}
+		emit(elems, val)
+		return nil
+	}
+}
+
+// walkList walks each entry of a YANG list, adding the entry's keys to the
+// last path element.
+func (n *jsonNormalizer) walkList(elems []*gnmipb.PathElem, schemaPath []string, list []interface{}, emit func([]*gnmipb.PathElem, *gnmipb.TypedValue)) error {
+	if len(elems) == 0 {
+		return fmt.Errorf("list value at the root of the path")
+	}
+	last := elems[len(elems)-1]
+	for _, entry := range list {
+		fields := entry.(map[string]interface{})
+		keys, err := n.listKeys(schemaPath, fields)
+		if err != nil {
+			return err
+		}
+		entryElems := append(append([]*gnmipb.PathElem{}, elems[:len(elems)-1]...), &gnmipb.PathElem{Name: last.GetName(), Key: keys})
+		if err := n.walk(entryElems, schemaPath, fields, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listKeys returns the keys of a list entry. The key names are taken from the
+// schema, if available, or defaultListKeys.
+func (n *jsonNormalizer) listKeys(schemaPath []string, fields map[string]interface{}) (map[string]string, error) {
+	var names []string
+	if n.schema != nil {
+		if entry := n.schema.GetEntryByPath(schemaPath); entry != nil && entry.Key != "" {
+			names = strings.Fields(entry.Key)
+		}
+	}
+	if names == nil {
+		for _, name := range defaultListKeys {
+			if _, exists := lookupField(fields, name); exists {
+				names = []string{name}
+				break
+			}
+		}
+	}
+	if names == nil {
+		return nil, fmt.Errorf("unable to find the keys for list /%s", strings.Join(schemaPath, "/"))
+	}
+
+	keys := make(map[string]string, len(names))
+	for _, name := range names {
+		value, exists := lookupField(fields, name)
+		if !exists {
+			return nil, fmt.Errorf("list /%s entry is missing key %s", strings.Join(schemaPath, "/"), name)
+		}
+		switch v := value.(type) {
+		case string:
+			keys[name] = v
+		case json.Number:
+			keys[name] = v.String()
+		case bool:
+			keys[name] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("list /%s key %s is not a scalar value", strings.Join(schemaPath, "/"), name)
+		}
+	}
+	return keys, nil
+}
+
+// scalar converts a decoded JSON scalar to a TypedValue. JSON_IETF encodes
+// 64-bit integers and decimals as strings so strings are converted to the
+// type of the leaf if the leaf is in the schema.
+func (n *jsonNormalizer) scalar(schemaPath []string, value interface{}) (*gnmipb.TypedValue, error) {
+	var kind yang.TypeKind = yang.Ynone
+	if n.schema != nil {
+		if entry := n.schema.GetEntryByPath(schemaPath); entry != nil && entry.Type != nil {
+			kind = entry.Type.Kind
+		}
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: v}}, nil
+	case json.Number:
+		return numberValue(v.String(), kind)
+	case string:
+		switch kind {
+		case yang.Yint8, yang.Yint16, yang.Yint32, yang.Yint64, yang.Yuint8, yang.Yuint16, yang.Yuint32, yang.Yuint64, yang.Ydecimal64:
+			if val, err := numberValue(v, kind); err == nil {
+				return val, nil
+			}
+		}
+		return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: v}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported JSON value %T at /%s", value, strings.Join(schemaPath, "/"))
+	}
+}
+
+// numberValue parses s as an integer, unsigned integer, decimal, or double
+// depending on the YANG type kind and the value of s.
+func numberValue(s string, kind yang.TypeKind) (*gnmipb.TypedValue, error) {
+	switch kind {
+	case yang.Yuint8, yang.Yuint16, yang.Yuint32, yang.Yuint64:
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: u}}, nil
+		}
+	case yang.Ydecimal64:
+		if d, ok := decimalValue(s); ok {
+			return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_DecimalVal{DecimalVal: d}}, nil
+		}
+	default:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: i}}, nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: u}}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number '%s': %v", s, err)
+	}
+	return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_DoubleVal{DoubleVal: f}}, nil
+}
+
+// decimalValue parses a decimal number such as "-12.345" without losing
+// precision. false is returned if s isn't a plain decimal number or doesn't
+// fit in a decimal64.
+func decimalValue(s string) (*gnmipb.Decimal64, bool) {
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	if strings.ContainsAny(fraction, "+-") || len(fraction) > 18 {
+		return nil, false
+	}
+	digits, err := strconv.ParseInt(integer+fraction, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &gnmipb.Decimal64{Digits: digits, Precision: uint32(len(fraction))}, true
+}
+
+// isObjectList returns true if list is a non-empty list of JSON objects,
+// which is how YANG lists are encoded.
+func isObjectList(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, element := range list {
+		if _, ok := element.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupField returns the value of the named field, ignoring any module
+// prefix on the field name.
+func lookupField(fields map[string]interface{}, name string) (interface{}, bool) {
+	if value, exists := fields[name]; exists {
+		return value, true
+	}
+	for field, value := range fields {
+		if stripModule(field) == name {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// stripModule removes the module name prefix used by JSON_IETF member names
+// (e.g. "openconfig-interfaces:interfaces").
+func stripModule(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONNormalizer_normalize(t *testing.T) {
+	assertion := assert.New(t)
+
+	n := new(jsonNormalizer)
+	notification := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a"},
+		Update: []*gnmipb.Update{
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "interfaces"}}},
+				Val: &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{
+					"openconfig-interfaces:interface": [
+						{"name": "eth0", "state": {"mtu": 1500, "enabled": true, "description": "uplink"}}
+					]
+				}`)}},
+			},
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "system"}, {Name: "dns"}, {Name: "servers"}}},
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonVal{JsonVal: []byte(`["10.0.0.1", "10.0.0.2"]`)}},
+			},
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "system"}, {Name: "hostname"}}},
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "router1"}},
+			},
+		},
+	}
+	assertion.NoError(n.normalize(notification))
+
+	values := make(map[string]*gnmipb.TypedValue)
+	for _, u := range notification.Update {
+		values[pathString(u.Path)] = u.Val
+	}
+	assertion.Len(values, 6)
+	assertion.Equal(int64(1500), values["/interfaces/interface[name=eth0]/state/mtu"].GetIntVal())
+	assertion.True(values["/interfaces/interface[name=eth0]/state/enabled"].GetBoolVal())
+	assertion.Equal("uplink", values["/interfaces/interface[name=eth0]/state/description"].GetStringVal())
+	assertion.Equal("eth0", values["/interfaces/interface[name=eth0]/name"].GetStringVal())
+	assertion.Len(values["/system/dns/servers"].GetLeaflistVal().GetElement(), 2)
+	assertion.Equal("router1", values["/system/hostname"].GetStringVal())
+
+	invalid := &gnmipb.Notification{
+		Update: []*gnmipb.Update{
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "things"}}},
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonVal{JsonVal: []byte(`{"thing": [{"color": "blue"}]}`)}},
+			},
+		},
+	}
+	assertion.Error(n.normalize(invalid))
+	assertion.Len(invalid.Update, 1)
+	assertion.NotNil(invalid.Update[0].Val.GetJsonVal())
+}
+
+func pathString(p *gnmipb.Path) string {
+	var s string
+	for _, elem := range p.Elem {
+		s += "/" + elem.Name
+		for k, v := range elem.Key {
+			s += "[" + k + "=" + v + "]"
+		}
+	}
+	return s
+}
+
+func TestNumberValue(t *testing.T) {
+	assertion := assert.New(t)
+
+	val, err := numberValue("12345678.9", yang.Ynone)
+	assertion.NoError(err)
+	assertion.Equal(12345678.9, val.GetDoubleVal())
+
+	val, err = numberValue("1e300", yang.Ynone)
+	assertion.NoError(err)
+	assertion.Equal(1e300, val.GetDoubleVal())
+
+	val, err = numberValue("123456789012.345678", yang.Ydecimal64)
+	assertion.NoError(err)
+	assertion.Equal(&gnmipb.Decimal64{Digits: 123456789012345678, Precision: 6}, val.GetDecimalVal())
+
+	val, err = numberValue("-0.05", yang.Ydecimal64)
+	assertion.NoError(err)
+	assertion.Equal(&gnmipb.Decimal64{Digits: -5, Precision: 2}, val.GetDecimalVal())
+
+	val, err = numberValue("18446744073709551615", yang.Yuint64)
+	assertion.NoError(err)
+	assertion.Equal(uint64(18446744073709551615), val.GetUintVal())
+
+	_, err = numberValue("twelve", yang.Ydecimal64)
+	assertion.Error(err)
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"path"
+
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// MetaConnectionPool is the target meta field that assigns the target to a
+// named connection pool from ConnectionPools in GatewayConfig.
+const MetaConnectionPool = "ConnectionPool"
+
+// connectionPools holds the connection slots for the default pool (sized by
+// TargetLimit) and each named connection pool.
+type connectionPools struct {
+	defaultPool *semaphore.Weighted
+	pools       []configuration.ConnectionPool
+	slots       map[string]*semaphore.Weighted
+}
+
+// newConnectionPools creates the connection pools from the configuration.
+func newConnectionPools(config *configuration.GatewayConfig) (*connectionPools, error) {
+	p := &connectionPools{
+		defaultPool: semaphore.NewWeighted(int64(config.TargetLimit)),
+		pools:       config.ConnectionPools,
+		slots:       make(map[string]*semaphore.Weighted),
+	}
+	for _, pool := range config.ConnectionPools {
+		if pool.Name == "" {
+			return nil, fmt.Errorf("connection pool name must not be empty")
+		}
+		if _, exists := p.slots[pool.Name]; exists {
+			return nil, fmt.Errorf("duplicate connection pool '%s'", pool.Name)
+		}
+		if pool.Limit <= 0 {
+			return nil, fmt.Errorf("connection pool '%s' limit must be greater than zero", pool.Name)
+		}
+		for _, pattern := range pool.Targets {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("connection pool '%s' has an invalid target pattern '%s': %v", pool.Name, pattern, err)
+			}
+		}
+		p.slots[pool.Name] = semaphore.NewWeighted(int64(pool.Limit))
+	}
+	return p, nil
+}
+
+// assign returns the name of the connection pool for the target and the
+// pool's connection slots. The ConnectionPool target meta field takes
+// precedence over the target patterns of the pools. Targets that aren't
+// assigned to a pool use the default pool, which has an empty name. An error
+// is returned along with the default pool if the target meta names a pool
+// that doesn't exist.
+func (p *connectionPools) assign(name string, target *targetpb.Target) (string, *semaphore.Weighted, error) {
+	if pool, exists := target.GetMeta()[MetaConnectionPool]; exists {
+		slots, exists := p.slots[pool]
+		if !exists {
+			return "", p.defaultPool, fmt.Errorf("unknown connection pool '%s'", pool)
+		}
+		return pool, slots, nil
+	}
+	for _, pool := range p.pools {
+		for _, pattern := range pool.Targets {
+			if matched, _ := path.Match(pattern, name); matched {
+				return pool.Name, p.slots[pool.Name], nil
+			}
+		}
+	}
+	return "", p.defaultPool, nil
+}
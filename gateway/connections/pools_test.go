@@ -0,0 +1,64 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestConnectionPools_assign(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.TargetLimit = 10
+	config.ConnectionPools = []configuration.ConnectionPool{
+		{Name: "core", Limit: 2, Targets: []string{"core-*"}},
+		{Name: "lab", Limit: 1, Targets: []string{"lab-*", "core-lab-*"}},
+	}
+	pools, err := newConnectionPools(config)
+	assertion.NoError(err)
+
+	pool, slots, err := pools.assign("core-1", &targetpb.Target{})
+	assertion.NoError(err)
+	assertion.Equal("core", pool)
+	assertion.True(slots.TryAcquire(2))
+	assertion.False(slots.TryAcquire(1))
+
+	pool, _, err = pools.assign("core-1", &targetpb.Target{Meta: map[string]string{MetaConnectionPool: "lab"}})
+	assertion.NoError(err)
+	assertion.Equal("lab", pool)
+
+	pool, slots, err = pools.assign("edge-1", &targetpb.Target{})
+	assertion.NoError(err)
+	assertion.Equal("", pool)
+	assertion.True(slots.TryAcquire(10))
+
+	pool, _, err = pools.assign("edge-1", &targetpb.Target{Meta: map[string]string{MetaConnectionPool: "missing"}})
+	assertion.Error(err)
+	assertion.Equal("", pool)
+
+	config.ConnectionPools = []configuration.ConnectionPool{{Name: "core", Limit: 0}}
+	_, err = newConnectionPools(config)
+	assertion.Error(err)
+	config.ConnectionPools = []configuration.ConnectionPool{{Name: "core", Limit: 1}, {Name: "core", Limit: 1}}
+	_, err = newConnectionPools(config)
+	assertion.Error(err)
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Netflix/spectator-go"
+	"github.com/google/gnxi/utils/xpath"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// MetaRedact is the target meta field with a comma-separated list of XPaths
+// whose leaves are redacted from the target's notifications, in addition to
+// the Redactions in GatewayConfig.
+const MetaRedact = "Redact"
+
+// redaction is a parsed redaction path.
+type redaction struct {
+	xpath   string
+	path    []*gnmipb.PathElem
+	counter *spectator.Counter
+}
+
+// redactor removes the leaves below the redaction paths from notifications.
+type redactor struct {
+	rules []redaction
+}
+
+// ValidateRedactions returns an error if a path or target pattern of the
+// redactions is invalid.
+func ValidateRedactions(redactions []configuration.Redaction) error {
+	for _, r := range redactions {
+		if _, err := xpath.ToGNMIPath(r.Path); err != nil {
+			return fmt.Errorf("invalid redaction path '%s': %v", r.Path, err)
+		}
+		for _, pattern := range r.Targets {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid redaction target pattern '%s': %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseRedactions returns the redactions that apply to the named target.
+func parseRedactions(config *configuration.GatewayConfig, target string, meta map[string]string) ([]redaction, error) {
+	var paths []string
+	for _, r := range config.Redactions {
+		if len(r.Targets) > 0 && !matchTarget(r.Targets, target) {
+			continue
+		}
+		paths = append(paths, r.Path)
+	}
+	if value, exists := meta[MetaRedact]; exists {
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	var rules []redaction
+	for _, p := range paths {
+		parsed, err := xpath.ToGNMIPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction path '%s': %v", p, err)
+		}
+		rules = append(rules, redaction{xpath: p, path: parsed.Elem})
+	}
+	return rules, nil
+}
+
+// matchTarget returns true if the target name matches one of the shell
+// patterns.
+func matchTarget(patterns []string, target string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// newRedactor creates the redactor for the target. A nil redactor is
+// returned if no redactions apply to the target. The redactions applied are
+// counted for each path with the target's metric tags.
+func newRedactor(config *configuration.GatewayConfig, target string, meta map[string]string, tags map[string]string) (*redactor, error) {
+	rules, err := parseRedactions(config, target, meta)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	for i := range rules {
+		ruleTags := map[string]string{"gnmigateway.client.redaction.path": rules[i].xpath}
+		for k, v := range tags {
+			ruleTags[k] = v
+		}
+		rules[i].counter = stats.Registry.Counter("gnmigateway.client.subscribe.redacted", ruleTags)
+	}
+	return &redactor{rules: rules}, nil
+}
+
+// redact removes the updates of the leaves below the redaction paths from
+// the notification in place and returns the number of updates removed. JSON
+// encoded values of a subtree containing a redaction path are removed as a
+// whole because the redacted leaves can't be removed from them; enable JSON
+// normalization to only remove the redacted leaves of such targets.
+func (r *redactor) redact(notification *gnmipb.Notification) int {
+	prefix := notification.GetPrefix().GetElem()
+	var removed int
+	updates := notification.Update[:0]
+	for _, u := range notification.Update {
+		full := make([]*gnmipb.PathElem, 0, len(prefix)+len(u.GetPath().GetElem()))
+		full = append(append(full, prefix...), u.GetPath().GetElem()...)
+		if rule := r.match(full, isJSONValue(u.GetVal())); rule != nil {
+			rule.counter.Increment()
+			removed++
+			continue
+		}
+		updates = append(updates, u)
+	}
+	for i := len(updates); i < len(notification.Update); i++ {
+		notification.Update[i] = nil
+	}
+	notification.Update = updates
+	return removed
+}
+
+// match returns the redaction that applies to the leaf at path, or the
+// redaction below path if the value is a JSON encoded subtree.
+func (r *redactor) match(path []*gnmipb.PathElem, subtree bool) *redaction {
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if utils.MatchPathPrefix(path, rule.path) {
+			return rule
+		}
+		if subtree && containsPath(path, rule.path) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// containsPath returns true if the subtree at path may contain redacted,
+// i.e. path is shorter than redacted and matches its first elems. Keys that
+// aren't in path, such as the keys of a list encoded as JSON, match any value.
+func containsPath(path []*gnmipb.PathElem, redacted []*gnmipb.PathElem) bool {
+	if len(path) >= len(redacted) {
+		return false
+	}
+	for i, elem := range path {
+		if redacted[i].Name != "*" && redacted[i].Name != elem.Name {
+			return false
+		}
+		for k, v := range redacted[i].Key {
+			if ov, exists := elem.Key[k]; exists && v != "*" && v != ov {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isJSONValue(val *gnmipb.TypedValue) bool {
+	switch val.GetValue().(type) {
+	case *gnmipb.TypedValue_JsonVal, *gnmipb.TypedValue_JsonIetfVal:
+		return true
+	}
+	return false
+}
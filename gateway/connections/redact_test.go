@@ -0,0 +1,107 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	"github.com/google/gnxi/utils/xpath"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestValidateRedactions(t *testing.T) {
+	assert.NoError(t, ValidateRedactions([]configuration.Redaction{{Path: "/snmp/communities", Targets: []string{"edge*"}}}))
+	assert.Error(t, ValidateRedactions([]configuration.Redaction{{Path: "/snmp/communities[name=a"}}))
+	assert.Error(t, ValidateRedactions([]configuration.Redaction{{Path: "/snmp/communities", Targets: []string{"edge["}}}))
+}
+
+func TestNewRedactor(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{
+		Redactions: []configuration.Redaction{
+			{Path: "/snmp/communities"},
+			{Path: "/system/aaa/authentication/users", Targets: []string{"edge*"}},
+		},
+	}
+	r, err := newRedactor(config, "core1", nil, nil)
+	if assertion.NoError(err) && assertion.NotNil(r) {
+		assertion.Len(r.rules, 1)
+	}
+	r, err = newRedactor(config, "edge1", map[string]string{MetaRedact: "/system/ntp/keys, /bgp/neighbors/neighbor/config/auth-password"}, nil)
+	if assertion.NoError(err) && assertion.NotNil(r) {
+		assertion.Len(r.rules, 4)
+	}
+
+	r, err = newRedactor(&configuration.GatewayConfig{}, "core1", nil, nil)
+	assertion.NoError(err)
+	assertion.Nil(r)
+	_, err = newRedactor(&configuration.GatewayConfig{}, "core1", map[string]string{MetaRedact: "/a[b=c"}, nil)
+	assertion.Error(err)
+}
+
+func redactUpdate(t *testing.T, p string, val *gnmipb.TypedValue) *gnmipb.Update {
+	path, err := xpath.ToGNMIPath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gnmipb.Update{Path: path, Val: val}
+}
+
+func TestRedactor_redact(t *testing.T) {
+	assertion := assert.New(t)
+
+	r, err := newRedactor(&configuration.GatewayConfig{
+		Redactions: []configuration.Redaction{
+			{Path: "/snmp/communities"},
+			{Path: "/system/aaa/authentication/users/user[username=*]/config/password"},
+		},
+	}, "core1", nil, nil)
+	if !assertion.NoError(err) {
+		return
+	}
+	stringVal := &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "secret"}}
+	jsonVal := &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"user": []}`)}}
+
+	notification := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "core1"},
+		Update: []*gnmipb.Update{
+			redactUpdate(t, "/snmp/communities/community[name=public]/config/name", stringVal),
+			redactUpdate(t, "/snmp/engine/config/id", stringVal),
+			redactUpdate(t, "/system/aaa/authentication/users/user[username=admin]/config/password", stringVal),
+			redactUpdate(t, "/system/aaa/authentication/users/user[username=admin]/config/role", stringVal),
+			// the redacted leaves can't be removed from a JSON subtree
+			redactUpdate(t, "/system/aaa/authentication/users", jsonVal),
+			redactUpdate(t, "/system/aaa/authentication/users", stringVal),
+		},
+	}
+	assertion.Equal(3, r.redact(notification))
+	assertion.Len(notification.Update, 3)
+	assertion.Equal("id", notification.Update[0].GetPath().GetElem()[3].GetName())
+	assertion.Equal("role", notification.Update[1].GetPath().GetElem()[6].GetName())
+	assertion.Equal("users", notification.Update[2].GetPath().GetElem()[3].GetName())
+
+	// the prefix is part of the matched path
+	prefixed := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "core1", Elem: []*gnmipb.PathElem{{Name: "snmp"}}},
+		Update: []*gnmipb.Update{redactUpdate(t, "/communities/community[name=public]/state/name", stringVal)},
+	}
+	assertion.Equal(1, r.redact(prefixed))
+	assertion.Empty(prefixed.Update)
+}
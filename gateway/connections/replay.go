@@ -0,0 +1,140 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
+)
+
+// Target meta fields used to configure a replay target. A replay target reads
+// SubscribeResponses from a capture file instead of connecting to a device.
+const (
+	// MetaReplay is the path to the capture file to replay.
+	MetaReplay = "Replay"
+	// MetaReplayFormat is the capture file format (proto or json). Defaults to proto.
+	MetaReplayFormat = "ReplayFormat"
+	// MetaReplaySpeed is the playback speed multiplier relative to the original
+	// notification timestamps. A speed of 0 replays as fast as possible. Defaults to 1.
+	MetaReplaySpeed = "ReplaySpeed"
+	// MetaReplayLoop will restart the replay from the beginning of the file
+	// when the end of the file is reached.
+	MetaReplayLoop = "ReplayLoop"
+	// MetaReplayKeepTimestamps will keep the original notification timestamps
+	// instead of replacing them with the time the notification is replayed.
+	MetaReplayKeepTimestamps = "ReplayKeepTimestamps"
+)
+
+// isReplay returns true if the target is configured as a replay target.
+func (t *ConnectionState) isReplay() bool {
+	_, replay := t.target.Meta[MetaReplay]
+	return replay
+}
+
+// doReplay feeds the messages in the configured capture file through handleUpdate
+// as if they were received from a connected target. doReplay returns after the
+// replay is cancelled.
+func (t *ConnectionState) doReplay() {
+	t.setConnecting()
+	var ctx context.Context
+	ctx, t.clientCancel = context.WithCancel(context.Background())
+	defer t.disconnected()
+
+	t.queryTarget = t.name
+	file := t.target.Meta[MetaReplay]
+	format, err := capture.ParseFormat(t.target.Meta[MetaReplayFormat])
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: invalid replay configuration: %v", t.name, err)
+		<-ctx.Done()
+		return
+	}
+	speed := 1.0
+	if s, exists := t.target.Meta[MetaReplaySpeed]; exists {
+		speed, err = strconv.ParseFloat(s, 64)
+		if err != nil || speed < 0 {
+			t.config.Log.Error().Msgf("Target %s: invalid replay speed '%s'", t.name, s)
+			<-ctx.Done()
+			return
+		}
+	}
+	_, loop := t.target.Meta[MetaReplayLoop]
+	_, keepTimestamps := t.target.Meta[MetaReplayKeepTimestamps]
+
+	for {
+		t.config.Log.Info().Msgf("Target %s: Replaying '%s'", t.name, file)
+		err := t.replayFile(ctx, file, format, speed, keepTimestamps)
+		if err != nil {
+			t.config.Log.Error().Msgf("Target %s: replay stopped: %v", t.name, err)
+			break
+		}
+		if !loop || ctx.Err() != nil {
+			break
+		}
+	}
+	t.config.Log.Info().Msgf("Target %s: Replay finished", t.name)
+	<-ctx.Done()
+}
+
+// replayFile replays a single pass of the capture file.
+func (t *ConnectionState) replayFile(ctx context.Context, file string, format capture.Format, speed float64, keepTimestamps bool) error {
+	reader, err := capture.NewReader(file, format)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var last int64
+	for ctx.Err() == nil {
+		resp, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read replay file: %v", err)
+		}
+
+		if update := resp.GetUpdate(); update != nil {
+			if speed > 0 && last > 0 && update.Timestamp > last {
+				delay := time.Duration(float64(update.Timestamp-last) / speed)
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(delay):
+				}
+			}
+			last = update.Timestamp
+			if update.Prefix == nil {
+				update.Prefix = &gnmipb.Path{}
+			}
+			update.Prefix.Target = t.name
+			if !keepTimestamps {
+				update.Timestamp = time.Now().UnixNano()
+			}
+		}
+
+		if err := t.handleUpdate(resp); err != nil {
+			t.config.Log.Warn().Msgf("Target %s: unable to handle replayed message: %v", t.name, err)
+		}
+	}
+	return nil
+}
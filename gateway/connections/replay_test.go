@@ -0,0 +1,116 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/cache"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/metadata"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestConnectionState_doReplay(t *testing.T) {
+	assertion := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "capture.json")
+
+	writer, err := capture.NewWriter(file, capture.FormatJSON)
+	assertion.NoError(err)
+	for i, name := range []string{"x", "y"} {
+		assertion.NoError(writer.Write(&gnmipb.SubscribeResponse{
+			Response: &gnmipb.SubscribeResponse_Update{
+				Update: &gnmipb.Notification{
+					Timestamp: int64(i + 1),
+					Prefix:    &gnmipb.Path{Target: "original-target"},
+					Update: []*gnmipb.Update{
+						{
+							Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: name}}},
+							Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: int64(i)}},
+						},
+					},
+				},
+			},
+		}))
+	}
+	assertion.NoError(writer.Write(&gnmipb.SubscribeResponse{
+		Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true},
+	}))
+	assertion.NoError(writer.Close())
+
+	c := cache.New(nil)
+	state := &ConnectionState{
+		config:      configuration.NewDefaultGatewayConfig(),
+		name:        "replay-target",
+		targetCache: c.Add("replay-target"),
+		target: &targetpb.Target{
+			Addresses: []string{"replay"},
+			Meta: map[string]string{
+				MetaReplay:       file,
+				MetaReplayFormat: "json",
+				MetaReplaySpeed:  "0",
+			},
+		},
+		seen: make(map[string]bool),
+	}
+	state.InitializeMetrics()
+	assertion.True(state.isReplay())
+
+	done := make(chan struct{})
+	go func() {
+		state.doConnect()
+		close(done)
+	}()
+
+	var count int
+	for i := 0; i < 50; i++ {
+		count = 0
+		_ = c.Query("replay-target", []string{"*"}, func(path []string, l *ctree.Leaf, val interface{}) error {
+			notification, ok := val.(*gnmipb.Notification)
+			if ok && notification.GetPrefix().GetTarget() == "replay-target" && path[0] != metadata.Root {
+				count++
+			}
+			return nil
+		})
+		if count == 2 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assertion.Equal(2, count)
+
+	assertion.NoError(state.disconnect())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay did not stop after disconnect")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddressSchemeSRV is the prefix of target addresses that are DNS SRV records
+// (e.g. "srv://_gnmi._tcp.router1.example.com"). SRV records are resolved to
+// host:port addresses in priority and weight order each time the target is
+// connected to. Consul and Kubernetes services can be used through their SRV
+// records (e.g. "srv://_gnmi._tcp.router1.default.svc.cluster.local").
+const AddressSchemeSRV = "srv://"
+
+// MetaResolveInterval is the target meta field that sets how often SRV target
+// addresses are re-resolved while connected. If the address in use is no
+// longer returned the target is reconnected. The value is a duration; the
+// default is defaultResolveInterval and "0" disables re-resolution.
+const MetaResolveInterval = "ResolveInterval"
+
+// defaultResolveInterval is used if MetaResolveInterval isn't set.
+const defaultResolveInterval = 5 * time.Minute
+
+// srvResolver looks up DNS SRV records. It is implemented by *net.Resolver.
+type srvResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// hasSRVAddress returns true if any of the addresses is an SRV record.
+func hasSRVAddress(addresses []string) bool {
+	for _, address := range addresses {
+		if strings.HasPrefix(address, AddressSchemeSRV) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAddresses replaces each SRV address with the addresses it resolves
+// to. Other addresses are returned unchanged and in the same position.
+func resolveAddresses(ctx context.Context, resolver srvResolver, addresses []string) ([]string, error) {
+	if !hasSRVAddress(addresses) {
+		return addresses, nil
+	}
+	var resolved []string
+	for _, address := range addresses {
+		if !strings.HasPrefix(address, AddressSchemeSRV) {
+			resolved = append(resolved, address)
+			continue
+		}
+		name := strings.TrimPrefix(address, AddressSchemeSRV)
+		_, records, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %v", address, err)
+		}
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			resolved = append(resolved, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		}
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no addresses found for %v", addresses)
+	}
+	return resolved, nil
+}
+
+// watchAddress re-resolves the addresses every resolve interval and closes
+// the subscription once the address in use is no longer returned so that the
+// reconnect uses the new addresses.
+func (c *gatewayClient) watchAddress(ctx context.Context, addresses []string, inUse string, closer func() error) {
+	ticker := time.NewTicker(c.resolveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			resolved, err := resolveAddresses(ctx, c.resolver, addresses)
+			if err != nil {
+				// Keep the current connection if DNS is unavailable.
+				continue
+			}
+			if !containsString(resolved, inUse) {
+				_ = closer()
+				return
+			}
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
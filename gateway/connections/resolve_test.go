@@ -0,0 +1,62 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResolver map[string][]*net.SRV
+
+func (f fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	records, exists := f[name]
+	if !exists {
+		return "", nil, errors.New("no such host")
+	}
+	return name, records, nil
+}
+
+func TestResolveAddresses(t *testing.T) {
+	assertion := assert.New(t)
+
+	resolver := fakeResolver{
+		"_gnmi._tcp.router1.example.com": {
+			{Target: "a.example.com.", Port: 9339},
+			{Target: "b.example.com.", Port: 9340},
+		},
+		"_gnmi._tcp.empty.example.com": {},
+	}
+
+	addresses := []string{"10.0.0.1:9339"}
+	resolved, err := resolveAddresses(context.Background(), resolver, addresses)
+	assertion.NoError(err)
+	assertion.Equal(addresses, resolved)
+
+	resolved, err = resolveAddresses(context.Background(), resolver, []string{"srv://_gnmi._tcp.router1.example.com", "10.0.0.1:9339"})
+	assertion.NoError(err)
+	assertion.Equal([]string{"a.example.com:9339", "b.example.com:9340", "10.0.0.1:9339"}, resolved)
+
+	_, err = resolveAddresses(context.Background(), resolver, []string{"srv://_gnmi._tcp.router2.example.com"})
+	assertion.Error(err)
+
+	_, err = resolveAddresses(context.Background(), resolver, []string{"srv://_gnmi._tcp.empty.example.com"})
+	assertion.Error(err)
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/gnxi/utils/xpath"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Target meta fields used to rewrite the paths of notifications received from
+// a target before they are inserted into the cache.
+const (
+	// MetaSetOrigin sets the origin of notifications that don't have an origin.
+	MetaSetOrigin = "SetOrigin"
+	// MetaStripOrigin removes the origin from all notifications.
+	MetaStripOrigin = "StripOrigin"
+	// MetaRewriteOrigin is a comma-separated list of old=new origin rewrites.
+	MetaRewriteOrigin = "RewriteOrigin"
+	// MetaAddPathPrefix is an XPath that is prepended to the prefix of all notifications.
+	MetaAddPathPrefix = "AddPathPrefix"
+	// MetaRemovePathPrefix is an XPath that is removed from the beginning of
+	// notification paths if present.
+	MetaRemovePathPrefix = "RemovePathPrefix"
+)
+
+// pathRewriter modifies the origin and path prefix of notifications.
+type pathRewriter struct {
+	addPrefix    []*gnmipb.PathElem
+	originMap    map[string]string
+	removePrefix []*gnmipb.PathElem
+	setOrigin    string
+	stripOrigin  bool
+}
+
+// newPathRewriter creates a pathRewriter from target meta configuration. A nil
+// pathRewriter is returned if there are no rewrite rules configured.
+func newPathRewriter(meta map[string]string) (*pathRewriter, error) {
+	r := new(pathRewriter)
+	var configured bool
+	if origin, exists := meta[MetaSetOrigin]; exists {
+		r.setOrigin = origin
+		configured = true
+	}
+	if _, exists := meta[MetaStripOrigin]; exists {
+		r.stripOrigin = true
+		configured = true
+	}
+	if rewrites, exists := meta[MetaRewriteOrigin]; exists {
+		r.originMap = make(map[string]string)
+		for _, rewrite := range strings.Split(rewrites, ",") {
+			parts := strings.SplitN(strings.TrimSpace(rewrite), "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("invalid %s rule '%s': must be in the form old=new", MetaRewriteOrigin, rewrite)
+			}
+			r.originMap[parts[0]] = parts[1]
+		}
+		configured = true
+	}
+	if prefix, exists := meta[MetaAddPathPrefix]; exists {
+		path, err := xpath.ToGNMIPath(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaAddPathPrefix, prefix, err)
+		}
+		r.addPrefix = path.Elem
+		configured = true
+	}
+	if prefix, exists := meta[MetaRemovePathPrefix]; exists {
+		path, err := xpath.ToGNMIPath(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaRemovePathPrefix, prefix, err)
+		}
+		r.removePrefix = path.Elem
+		configured = true
+	}
+	if !configured {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// rewrite applies the rewrite rules to the notification in place.
+func (r *pathRewriter) rewrite(notification *gnmipb.Notification) {
+	if notification.Prefix == nil {
+		notification.Prefix = &gnmipb.Path{}
+	}
+
+	var paths []*gnmipb.Path
+	for _, update := range notification.Update {
+		if update.Path == nil {
+			update.Path = &gnmipb.Path{}
+		}
+		paths = append(paths, update.Path)
+	}
+	paths = append(paths, notification.Delete...)
+
+	r.rewriteOrigin(notification.Prefix, paths)
+
+	if len(r.removePrefix) > 0 {
+		if matchPath(notification.Prefix.Elem, r.removePrefix) {
+			notification.Prefix.Elem = notification.Prefix.Elem[len(r.removePrefix):]
+		} else if len(notification.Prefix.Elem) == 0 {
+			for _, path := range paths {
+				if matchPath(path.Elem, r.removePrefix) {
+					path.Elem = path.Elem[len(r.removePrefix):]
+				}
+			}
+		}
+	}
+
+	if len(r.addPrefix) > 0 {
+		elems := make([]*gnmipb.PathElem, 0, len(r.addPrefix)+len(notification.Prefix.Elem))
+		elems = append(elems, r.addPrefix...)
+		notification.Prefix.Elem = append(elems, notification.Prefix.Elem...)
+	}
+}
+
+// rewriteOrigin applies the origin rules to the prefix and the paths. SetOrigin is
+// only applied if neither the prefix nor any of the paths have an origin.
+func (r *pathRewriter) rewriteOrigin(prefix *gnmipb.Path, paths []*gnmipb.Path) {
+	if r.stripOrigin {
+		prefix.Origin = ""
+		for _, path := range paths {
+			path.Origin = ""
+		}
+		return
+	}
+
+	if r.originMap != nil {
+		if newOrigin, exists := r.originMap[prefix.Origin]; exists && prefix.Origin != "" {
+			prefix.Origin = newOrigin
+		}
+		for _, path := range paths {
+			if newOrigin, exists := r.originMap[path.Origin]; exists && path.Origin != "" {
+				path.Origin = newOrigin
+			}
+		}
+	}
+
+	if r.setOrigin != "" && prefix.Origin == "" {
+		var pathOrigin bool
+		for _, path := range paths {
+			if path.Origin != "" {
+				pathOrigin = true
+				break
+			}
+		}
+		if !pathOrigin {
+			prefix.Origin = r.setOrigin
+		}
+	}
+}
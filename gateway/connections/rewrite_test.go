@@ -0,0 +1,121 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+func TestPathRewriter_rewrite(t *testing.T) {
+	tests := []struct {
+		name           string
+		meta           map[string]string
+		prefix         *gnmipb.Path
+		path           *gnmipb.Path
+		expectedPrefix string
+		expectedPath   string
+	}{
+		{
+			name:           "set origin",
+			meta:           map[string]string{MetaSetOrigin: "openconfig"},
+			prefix:         &gnmipb.Path{Target: "a"},
+			path:           &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "x"}}},
+			expectedPrefix: "/openconfig/a",
+			expectedPath:   "/x",
+		},
+		{
+			name:           "set origin with existing path origin",
+			meta:           map[string]string{MetaSetOrigin: "openconfig"},
+			prefix:         &gnmipb.Path{Target: "a"},
+			path:           &gnmipb.Path{Origin: "vendor", Elem: []*gnmipb.PathElem{{Name: "x"}}},
+			expectedPrefix: "/a",
+			expectedPath:   "/vendor/x",
+		},
+		{
+			name:           "strip origin",
+			meta:           map[string]string{MetaStripOrigin: ""},
+			prefix:         &gnmipb.Path{Target: "a", Origin: "openconfig"},
+			path:           &gnmipb.Path{Origin: "vendor", Elem: []*gnmipb.PathElem{{Name: "x"}}},
+			expectedPrefix: "/a",
+			expectedPath:   "/x",
+		},
+		{
+			name:           "rewrite origin",
+			meta:           map[string]string{MetaRewriteOrigin: "oc=openconfig, vendor=native"},
+			prefix:         &gnmipb.Path{Target: "a", Origin: "oc"},
+			path:           &gnmipb.Path{Origin: "vendor", Elem: []*gnmipb.PathElem{{Name: "x"}}},
+			expectedPrefix: "/openconfig/a",
+			expectedPath:   "/native/x",
+		},
+		{
+			name:           "add path prefix",
+			meta:           map[string]string{MetaAddPathPrefix: "/device/state"},
+			prefix:         &gnmipb.Path{Target: "a", Elem: []*gnmipb.PathElem{{Name: "interfaces"}}},
+			path:           &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "x"}}},
+			expectedPrefix: "/a/device/state/interfaces",
+			expectedPath:   "/x",
+		},
+		{
+			name:           "remove path prefix from prefix",
+			meta:           map[string]string{MetaRemovePathPrefix: "/device"},
+			prefix:         &gnmipb.Path{Target: "a", Elem: []*gnmipb.PathElem{{Name: "device"}, {Name: "interfaces"}}},
+			path:           &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "x"}}},
+			expectedPrefix: "/a/interfaces",
+			expectedPath:   "/x",
+		},
+		{
+			name:           "remove path prefix from path",
+			meta:           map[string]string{MetaRemovePathPrefix: "/device"},
+			prefix:         &gnmipb.Path{Target: "a"},
+			path:           &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "device"}, {Name: "x"}}},
+			expectedPrefix: "/a",
+			expectedPath:   "/x",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertion := assert.New(t)
+			rewriter, err := newPathRewriter(test.meta)
+			assertion.NoError(err)
+			assertion.NotNil(rewriter)
+
+			notification := &gnmipb.Notification{
+				Prefix: test.prefix,
+				Update: []*gnmipb.Update{{Path: test.path}},
+			}
+			rewriter.rewrite(notification)
+			assertion.Equal(test.expectedPrefix, utils.PathToXPath(notification.Prefix))
+			assertion.Equal(test.expectedPath, utils.PathToXPath(notification.Update[0].Path))
+		})
+	}
+}
+
+func TestNewPathRewriter(t *testing.T) {
+	assertion := assert.New(t)
+
+	rewriter, err := newPathRewriter(map[string]string{"NoLock": ""})
+	assertion.NoError(err)
+	assertion.Nil(rewriter)
+
+	_, err = newPathRewriter(map[string]string{MetaRewriteOrigin: "invalid"})
+	assertion.Error(err)
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Netflix/spectator-go"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/openconfig"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+// MetaSchemaValidation is the target meta field that sets the schema
+// validation mode for the target, overriding SchemaValidation in
+// GatewayConfig.
+const MetaSchemaValidation = "SchemaValidation"
+
+// Schema validation modes.
+const (
+	// SchemaValidationOff disables schema validation.
+	SchemaValidationOff = "off"
+	// SchemaValidationFlag counts and logs schema-invalid updates but still
+	// inserts them into the cache.
+	SchemaValidationFlag = "flag"
+	// SchemaValidationDrop counts, logs, and drops schema-invalid updates.
+	SchemaValidationDrop = "drop"
+)
+
+// ValidateSchemaValidation returns an error if mode is not a known schema
+// validation mode. An empty mode disables schema validation.
+func ValidateSchemaValidation(mode string) error {
+	switch mode {
+	case "", SchemaValidationOff, SchemaValidationFlag, SchemaValidationDrop:
+		return nil
+	}
+	return fmt.Errorf("unknown schema validation mode '%s': must be %s, %s, or %s", mode, SchemaValidationOff, SchemaValidationFlag, SchemaValidationDrop)
+}
+
+// schemas holds the OpenConfig schemas that have been loaded, by directory, so
+// that the models are only loaded once for all targets.
+var schemas = struct {
+	sync.Mutex
+	loaded map[string]*openconfig.TypeLookup
+}{loaded: make(map[string]*openconfig.TypeLookup)}
+
+// LoadSchema returns the OpenConfig schema loaded from directory. The schema
+// is only loaded once for each directory and shared with southbound adapters.
+func LoadSchema(directory string) (*openconfig.TypeLookup, error) {
+	schemas.Lock()
+	defer schemas.Unlock()
+	if schema, exists := schemas.loaded[directory]; exists {
+		return schema, nil
+	}
+	schema := new(openconfig.TypeLookup)
+	if err := schema.LoadAllModules(directory); err != nil {
+		return nil, err
+	}
+	schemas.loaded[directory] = schema
+	return schema, nil
+}
+
+// schemaValidator checks notification updates against the OpenConfig schema.
+type schemaValidator struct {
+	drop            bool
+	schema          *openconfig.TypeLookup
+	counterDropped  *spectator.Counter
+	counterMismatch *spectator.Counter
+	counterUnknown  *spectator.Counter
+}
+
+// newSchemaValidator creates a schemaValidator for the target. A nil
+// schemaValidator is returned if schema validation is disabled.
+func newSchemaValidator(config *configuration.GatewayConfig, meta map[string]string, metricTags map[string]string) (*schemaValidator, error) {
+	mode := config.SchemaValidation
+	if value, exists := meta[MetaSchemaValidation]; exists {
+		mode = value
+	}
+	if err := ValidateSchemaValidation(mode); err != nil {
+		return nil, err
+	}
+	if mode == "" || mode == SchemaValidationOff {
+		return nil, nil
+	}
+	if config.OpenConfigDirectory == "" {
+		return nil, fmt.Errorf("OpenConfigDirectory must be set for schema validation")
+	}
+	schema, err := LoadSchema(config.OpenConfigDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load OpenConfig models from %s: %v", config.OpenConfigDirectory, err)
+	}
+
+	reasonTags := func(reason string) map[string]string {
+		tags := map[string]string{"gnmigateway.client.schema.reason": reason}
+		for k, v := range metricTags {
+			tags[k] = v
+		}
+		return tags
+	}
+	return &schemaValidator{
+		drop:            mode == SchemaValidationDrop,
+		schema:          schema,
+		counterDropped:  stats.Registry.Counter("gnmigateway.client.subscribe.schema_dropped", metricTags),
+		counterMismatch: stats.Registry.Counter("gnmigateway.client.subscribe.schema_invalid", reasonTags("type_mismatch")),
+		counterUnknown:  stats.Registry.Counter("gnmigateway.client.subscribe.schema_invalid", reasonTags("unknown_path")),
+	}, nil
+}
+
+// validate checks each update in the notification against the schema. Invalid
+// updates are counted and returned as errors and, if the validator is set to
+// drop, removed from the notification. Notifications with a non-OpenConfig
+// origin are not checked.
+func (v *schemaValidator) validate(notification *gnmipb.Notification) []error {
+	switch notification.GetPrefix().GetOrigin() {
+	case "", "openconfig":
+	default:
+		return nil
+	}
+
+	var errs []error
+	prefix := elemNames(notification.GetPrefix())
+	valid := notification.Update[:0]
+	for _, update := range notification.GetUpdate() {
+		p := append(append([]string{}, prefix...), elemNames(update.GetPath())...)
+		err := v.schema.Validate(p, update.GetVal())
+		if err == nil {
+			valid = append(valid, update)
+			continue
+		}
+		if err == openconfig.ErrUnknownPath {
+			v.counterUnknown.Increment()
+		} else {
+			v.counterMismatch.Increment()
+		}
+		errs = append(errs, fmt.Errorf("/%s: %v", strings.Join(p, "/"), err))
+		if v.drop {
+			v.counterDropped.Increment()
+		} else {
+			valid = append(valid, update)
+		}
+	}
+	notification.Update = valid
+	return errs
+}
+
+// elemNames returns the element names of p without keys.
+func elemNames(p *gnmipb.Path) []string {
+	names := make([]string, 0, len(p.GetElem()))
+	for _, elem := range p.GetElem() {
+		names = append(names, elem.GetName())
+	}
+	return names
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Target meta fields that bind the target connections to a source, e.g. so
+// that telemetry egresses the management network instead of the default
+// route. They override the TargetLocalAddress, TargetLocalInterface, and
+// TargetNetworkNamespace options in GatewayConfig.
+const (
+	// MetaLocalAddress is the local IP address target connections are made
+	// from.
+	MetaLocalAddress = "LocalAddress"
+	// MetaLocalInterface is the network interface or VRF device target
+	// connections are bound to. Only supported on Linux.
+	MetaLocalInterface = "LocalInterface"
+	// MetaNetworkNamespace is the name of a network namespace (in
+	// /var/run/netns) or the path of a network namespace file that target
+	// connections are made in. Only supported on Linux.
+	MetaNetworkNamespace = "NetworkNamespace"
+)
+
+// sourceDialer dials TCP connections from a local address or interface,
+// optionally in another network namespace.
+type sourceDialer struct {
+	dialer    net.Dialer
+	namespace string
+}
+
+// newSourceDialer returns a sourceDialer for the source binding of a target,
+// or nil if the target isn't bound to a source.
+func newSourceDialer(config *configuration.GatewayConfig, meta map[string]string) (*sourceDialer, error) {
+	option := func(field string, value string) string {
+		if metaValue, exists := meta[field]; exists {
+			return metaValue
+		}
+		return value
+	}
+	address := option(MetaLocalAddress, config.TargetLocalAddress)
+	iface := option(MetaLocalInterface, config.TargetLocalInterface)
+	namespace := option(MetaNetworkNamespace, config.TargetNetworkNamespace)
+	if address == "" && iface == "" && namespace == "" {
+		return nil, nil
+	}
+
+	d := &sourceDialer{}
+	if address != "" {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid %s '%s'", MetaLocalAddress, address)
+		}
+		d.dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	if iface != "" {
+		control, err := bindToInterface(iface)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaLocalInterface, iface, err)
+		}
+		d.dialer.Control = control
+	}
+	if namespace != "" {
+		var err error
+		d.namespace, err = namespacePath(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", MetaNetworkNamespace, namespace, err)
+		}
+	}
+	return d, nil
+}
+
+// DialContext connects to the address on the named network.
+func (d *sourceDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if d.namespace == "" {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	return dialInNamespace(d.namespace, func() (net.Conn, error) {
+		return d.dialer.DialContext(ctx, network, address)
+	})
+}
+
+// Dial connects to the address on the named network.
+func (d *sourceDialer) Dial(network string, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
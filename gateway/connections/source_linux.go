@@ -0,0 +1,108 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToInterface returns a net.Dialer Control function that binds sockets
+// to the network interface or VRF device with SO_BINDTODEVICE.
+func bindToInterface(name string) (func(network, address string, c syscall.RawConn) error, error) {
+	if _, err := net.InterfaceByName(name); err != nil {
+		return nil, err
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var bindErr error
+		err := c.Control(func(fd uintptr) {
+			bindErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+		})
+		if err != nil {
+			return err
+		}
+		if bindErr != nil {
+			return fmt.Errorf("binding to interface %s: %v", name, bindErr)
+		}
+		return nil
+	}, nil
+}
+
+// namespacePath returns the path of the network namespace file for a
+// namespace name created with "ip netns add" or a namespace file path.
+func namespacePath(namespace string) (string, error) {
+	path := namespace
+	if !filepath.IsAbs(path) {
+		path = filepath.Join("/var/run/netns", namespace)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// dialInNamespace calls dial on an OS thread switched to the network
+// namespace, so that the sockets it creates belong to the namespace. The
+// thread is switched back before it's released; if that fails the thread is
+// discarded when the goroutine exits.
+func dialInNamespace(path string, dial func() (net.Conn, error)) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		runtime.LockOSThread()
+		original, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			results <- result{err: fmt.Errorf("opening the current network namespace: %v", err)}
+			return
+		}
+		defer original.Close()
+		target, err := os.Open(path)
+		if err != nil {
+			runtime.UnlockOSThread()
+			results <- result{err: fmt.Errorf("opening network namespace %s: %v", path, err)}
+			return
+		}
+		defer target.Close()
+		if err := setns(target); err != nil {
+			runtime.UnlockOSThread()
+			results <- result{err: fmt.Errorf("entering network namespace %s: %v", path, err)}
+			return
+		}
+		conn, err := dial()
+		if setns(original) == nil {
+			// the thread keeps the lock (and is discarded) otherwise
+			runtime.UnlockOSThread()
+		}
+		results <- result{conn: conn, err: err}
+	}()
+	r := <-results
+	return r.conn, r.err
+}
+
+// setns switches the calling thread to the network namespace of the file.
+func setns(namespace *os.File) error {
+	return unix.Setns(int(namespace.Fd()), unix.CLONE_NEWNET)
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !linux
+
+package connections
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func bindToInterface(string) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, errors.New("binding to an interface is only supported on Linux")
+}
+
+func namespacePath(string) (string, error) {
+	return "", errors.New("network namespaces are only supported on Linux")
+}
+
+func dialInNamespace(string, func() (net.Conn, error)) (net.Conn, error) {
+	return nil, errors.New("network namespaces are only supported on Linux")
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestNewSourceDialer(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	d, err := newSourceDialer(config, map[string]string{})
+	assertion.NoError(err)
+	assertion.Nil(d)
+
+	config.TargetLocalAddress = "127.0.0.1"
+	d, err = newSourceDialer(config, map[string]string{})
+	assertion.NoError(err)
+	assertion.Equal(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}, d.dialer.LocalAddr)
+
+	// the meta field overrides the configuration
+	d, err = newSourceDialer(config, map[string]string{MetaLocalAddress: "::1"})
+	assertion.NoError(err)
+	assertion.Equal(&net.TCPAddr{IP: net.ParseIP("::1")}, d.dialer.LocalAddr)
+
+	_, err = newSourceDialer(config, map[string]string{MetaLocalAddress: "localhost"})
+	assertion.Error(err)
+	_, err = newSourceDialer(config, map[string]string{MetaLocalInterface: "no-such-interface0"})
+	assertion.Error(err)
+	_, err = newSourceDialer(config, map[string]string{MetaNetworkNamespace: "no-such-namespace"})
+	assertion.Error(err)
+}
+
+func TestSourceDialer_Interface(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("binding to an interface is only supported on Linux")
+	}
+	assertion := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assertion.NoError(err)
+	defer listener.Close()
+
+	d, err := newSourceDialer(configuration.NewDefaultGatewayConfig(), map[string]string{
+		MetaLocalAddress:   "127.0.0.1",
+		MetaLocalInterface: "lo",
+	})
+	if !assertion.NoError(err) {
+		return
+	}
+	conn, err := d.DialContext(context.Background(), "tcp", listener.Addr().String())
+	if err != nil {
+		// SO_BINDTODEVICE requires CAP_NET_RAW on older kernels
+		t.Skipf("unable to bind to lo: %v", err)
+	}
+	assertion.Equal("127.0.0.1", conn.LocalAddr().(*net.TCPAddr).IP.String())
+	conn.Close()
+}
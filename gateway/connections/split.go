@@ -0,0 +1,122 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/protobuf/proto"
+)
+
+// Target meta fields used to split the target subscription across multiple
+// Subscribe streams.
+const (
+	// MetaSubscriptionSplit is the number of Subscribe streams the
+	// subscription list of the target is split across. The subscriptions are
+	// distributed evenly across the streams.
+	MetaSubscriptionSplit = "SubscriptionSplit"
+	// MetaSubscriptionSplitMode sets whether the streams share the target
+	// connection (SplitModeStream, the default) or each use their own
+	// connection (SplitModeConnection).
+	MetaSubscriptionSplitMode = "SubscriptionSplitMode"
+)
+
+// Subscription split modes.
+const (
+	SplitModeStream     = "stream"
+	SplitModeConnection = "connection"
+)
+
+// parseSubscriptionSplit returns the number of streams and whether each
+// stream uses its own connection.
+func parseSubscriptionSplit(meta map[string]string) (int, bool, error) {
+	streams := 1
+	if value, exists := meta[MetaSubscriptionSplit]; exists {
+		var err error
+		streams, err = strconv.Atoi(value)
+		if err != nil || streams < 1 {
+			return 0, false, fmt.Errorf("invalid %s '%s': must be a positive integer", MetaSubscriptionSplit, value)
+		}
+	}
+	switch mode := meta[MetaSubscriptionSplitMode]; mode {
+	case "", SplitModeStream:
+		return streams, false, nil
+	case SplitModeConnection:
+		return streams, true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown %s '%s'", MetaSubscriptionSplitMode, mode)
+	}
+}
+
+// splitQuery splits the subscriptions of the query across up to n queries.
+// The ProtoHandler of each query is serialized with the others and sync
+// responses are only passed on once every query has synced so that the
+// split subscriptions look like a single subscription to the handler.
+func splitQuery(q client.Query, n int) []client.Query {
+	subscribe := q.SubReq.GetSubscribe()
+	if n < 2 || len(subscribe.GetSubscription()) < 2 {
+		return []client.Query{q}
+	}
+	if n > len(subscribe.GetSubscription()) {
+		n = len(subscribe.GetSubscription())
+	}
+
+	groups := make([][]*gnmipb.Subscription, n)
+	for i, subscription := range subscribe.GetSubscription() {
+		groups[i%n] = append(groups[i%n], subscription)
+	}
+
+	merger := &syncMerger{handler: q.ProtoHandler, streams: n, synced: make(map[int]bool)}
+	queries := make([]client.Query, n)
+	for i, group := range groups {
+		request := proto.Clone(q.SubReq).(*gnmipb.SubscribeRequest)
+		request.GetSubscribe().Subscription = group
+		queries[i] = q
+		queries[i].SubReq = request
+		queries[i].ProtoHandler = merger.handlerFor(i)
+	}
+	return queries
+}
+
+// syncMerger passes responses from multiple streams to a single handler.
+type syncMerger struct {
+	handler client.ProtoHandler
+	streams int
+
+	mutex    sync.Mutex
+	synced   map[int]bool
+	complete bool
+}
+
+// handlerFor returns the ProtoHandler for the stream with the given index.
+func (m *syncMerger) handlerFor(stream int) client.ProtoHandler {
+	return func(msg proto.Message) error {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if resp, ok := msg.(*gnmipb.SubscribeResponse); ok && resp.GetSyncResponse() && !m.complete {
+			m.synced[stream] = true
+			if len(m.synced) < m.streams {
+				return nil
+			}
+			m.complete = true
+		}
+		return m.handler(msg)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSplitQuery(t *testing.T) {
+	assertion := assert.New(t)
+
+	var handled []*gnmipb.SubscribeResponse
+	request := &gnmipb.SubscribeRequest{Request: &gnmipb.SubscribeRequest_Subscribe{Subscribe: &gnmipb.SubscriptionList{
+		Subscription: []*gnmipb.Subscription{
+			{Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "a"}}}},
+			{Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "b"}}}},
+			{Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "c"}}}},
+		},
+	}}}
+	q := client.Query{
+		SubReq: request,
+		ProtoHandler: func(msg proto.Message) error {
+			handled = append(handled, msg.(*gnmipb.SubscribeResponse))
+			return nil
+		},
+	}
+
+	assertion.Len(splitQuery(q, 1), 1)
+	queries := splitQuery(q, 5)
+	assertion.Len(queries, 3)
+	queries = splitQuery(q, 2)
+	assertion.Len(queries, 2)
+	assertion.Len(queries[0].SubReq.GetSubscribe().Subscription, 2)
+	assertion.Len(queries[1].SubReq.GetSubscribe().Subscription, 1)
+	assertion.Len(request.GetSubscribe().Subscription, 3)
+
+	update := &gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_Update{Update: &gnmipb.Notification{}}}
+	sync := &gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}}
+	assertion.NoError(queries[0].ProtoHandler(update))
+	assertion.NoError(queries[0].ProtoHandler(sync))
+	assertion.Len(handled, 1)
+	assertion.NoError(queries[1].ProtoHandler(update))
+	assertion.NoError(queries[1].ProtoHandler(sync))
+	assertion.Len(handled, 3)
+	assertion.True(handled[2].GetSyncResponse())
+}
+
+func TestParseSubscriptionSplit(t *testing.T) {
+	assertion := assert.New(t)
+
+	streams, connections, err := parseSubscriptionSplit(map[string]string{})
+	assertion.NoError(err)
+	assertion.Equal(1, streams)
+	assertion.False(connections)
+
+	streams, connections, err = parseSubscriptionSplit(map[string]string{MetaSubscriptionSplit: "4", MetaSubscriptionSplitMode: SplitModeConnection})
+	assertion.NoError(err)
+	assertion.Equal(4, streams)
+	assertion.True(connections)
+
+	_, _, err = parseSubscriptionSplit(map[string]string{MetaSubscriptionSplit: "0"})
+	assertion.Error(err)
+	_, _, err = parseSubscriptionSplit(map[string]string{MetaSubscriptionSplitMode: "carrier-pigeon"})
+	assertion.Error(err)
+}
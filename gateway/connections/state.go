@@ -33,24 +33,30 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"github.com/go-zookeeper/zk"
 	"github.com/openconfig/gnmi/errlist"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Netflix/spectator-go"
 	"github.com/Netflix/spectator-go/histogram"
-	"github.com/golang/protobuf/proto"
 	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/client"
 	gnmiclient "github.com/openconfig/gnmi/client/gnmi"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 	targetpb "github.com/openconfig/gnmi/proto/target"
 	"golang.org/x/sync/semaphore"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
 	"github.com/openconfig/gnmi-gateway/gateway/locking"
+	"github.com/openconfig/gnmi-gateway/gateway/rates"
+	"github.com/openconfig/gnmi-gateway/gateway/secrets"
 	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/tracing"
 	"github.com/openconfig/gnmi-gateway/gateway/utils"
 )
 
@@ -58,26 +64,99 @@ import (
 // the target's cache data. It is created once for every device and used as a closure parameter by ProtoHandler.
 type ConnectionState struct {
 	ConnectionLockAcquired bool
-	client                 *client.ReconnectClient
-	clientCancel           context.CancelFunc
-	clusterMember          bool
-	config                 *configuration.GatewayConfig
+	// address is the target address currently in use.
+	address      string
+	addressMutex sync.Mutex
+	// capabilities is the result of the last Capabilities probe.
+	capabilities      *TargetCapabilities
+	capabilitiesMutex sync.Mutex
+	// capture is the debug capture writer for raw SubscribeResponses, if a capture is running.
+	capture       *capture.Writer
+	captureMutex  sync.Mutex
+	client        *client.ReconnectClient
+	clientCancel  context.CancelFunc
+	clusterMember bool
+	// clockOffset is added to the timestamp of each notification.
+	clockOffset time.Duration
+	// coalescer merges updates to the same path within a window, if configured.
+	coalescer *updateCoalescer
+	config    *configuration.GatewayConfig
+	// stateMutex guards the connection state below, which is changed by the
+	// connection goroutines and read by the connection manager, the meta
+	// leaves, and the admin API. Use the accessors in meta.go.
+	stateMutex sync.RWMutex
 	// connected status is set to true when the first gnmi notification is received.
 	// it gets reset to false when disconnect call back of ReconnectClient is called.
 	connected bool
+	// connectedAt is the time connected was last set.
+	connectedAt time.Time
 	// connecting status is used to signal that some of the connection process has been started and
 	// full reconnection is necessary if the target configuration changes
 	connecting  bool
 	connManager ConnectionManager
+	// deleter generates deletes for the list entries missing from
+	// consecutive samples, if configured.
+	deleter *deleteGenerator
+	// err is the last error returned by the target connection and
+	// errorHistory the most recent errors, oldest first.
+	err          error
+	errorHistory []TargetError
+	// quarantined is set after repeated authentication failures.
+	quarantined bool
+	// disabled is set while the target is disabled for maintenance.
+	disabled bool
+	// fencingToken is the token of the lock the target is connected with.
+	fencingToken uint64
+	// fencing tracks the fencing tokens of the targets replicated from
+	// other cluster members.
+	fencing *fencingTokens
+	// federated is set for gateway targets (see MetaGateway).
+	federated bool
+	// handler passes notifications through the middlewares and inserts them
+	// into the cache. It is created from middlewares for each connection.
+	handler     NotificationHandler
+	middlewares []Middleware
+	// leafTimes is the time each leaf was last inserted into the cache. It is
+	// only populated when leaf expiry is enabled.
+	leafTimes      map[string]time.Time
+	leafTimesMutex sync.Mutex
 	// lock is the distributed lock that must be acquired before a connection is made if .connectWithLock() is called
 	lock locking.DistributedLocker
 	// The unique name of the target that is being connected to
 	name string
+	// origins are the origins of the targets received from a gateway target
+	// and looped are the targets whose origin contains this gateway.
+	origins      map[string][]string
+	looped       map[string]bool
+	originsMutex sync.Mutex
 	// noTLSWarning indicates if the warning about the NoTLS flag deprecation
 	// has been displayed yet.
 	noTLSWarning bool
-	queryTarget  string
-	request      *gnmipb.SubscribeRequest
+	// pool is the name of the connection pool the target is assigned to.
+	pool string
+	// normalizer decodes JSON encoded values into individual leaves, if configured.
+	normalizer  *jsonNormalizer
+	queryTarget string
+	request     *gnmipb.SubscribeRequest
+	// skew is the moving average of the difference between the receive time
+	// and notification timestamp in nanoseconds.
+	skew         int64
+	skewExceeded bool
+	// received and rejected are the total number of notifications received
+	// from the target and rejected, for the gateway meta leaves. receivedBytes
+	// is the encoded size of the received messages.
+	received           uint64
+	receivedAtLastMeta uint64
+	receivedBytes      uint64
+	rejected           uint64
+	// rates computes counter deltas and rates, if configured. A new
+	// Calculator is created from rateRules for each connection.
+	rates     *rates.Calculator
+	rateRules *rates.Rules
+	// redactor removes sensitive leaves from notifications, if configured.
+	redactor *redactor
+	// rewriter modifies notification origins and paths per the target's meta configuration.
+	rewriter *pathRewriter
 	// seen is the list of targets that have been seen on this connection
 	seen      map[string]bool
 	seenMutex sync.Mutex
@@ -89,32 +168,66 @@ type ConnectionState struct {
 	target      *targetpb.Target
 	targetCache *cache.Target
 	useLock     bool
+	// validator checks updates against the OpenConfig schema, if configured.
+	validator *schemaValidator
 
 	// metrics
-	metricTags           map[string]string
-	counterCoalesced     *spectator.Counter
-	counterNotifications *spectator.Counter
-	counterRejected      *spectator.Counter
-	counterStale         *spectator.Counter
-	counterSync          *spectator.Counter
-	gaugeSynced          *spectator.Gauge
-	timerLatency         *histogram.PercentileTimer
+	metricTags              map[string]string
+	counterAuthFailures     *spectator.Counter
+	counterBytes            *spectator.Counter
+	counterClockSkew        *spectator.Counter
+	counterCoalesced        *spectator.Counter
+	counterDeduplicated     *spectator.Counter
+	counterExpired          *spectator.Counter
+	counterFederationLoops  *spectator.Counter
+	counterFenced           *spectator.Counter
+	counterNormalizeFailed  *spectator.Counter
+	counterNotifications    *spectator.Counter
+	counterPanics           *spectator.Counter
+	counterRejected         *spectator.Counter
+	counterStale            *spectator.Counter
+	counterSyntheticDeletes *spectator.Counter
+	counterSync             *spectator.Counter
+	counterTimestampInvalid *spectator.Counter
+	counterTimestampUnits   *spectator.Counter
+	counterWindowMerged     *spectator.Counter
+	gaugeClockSkew          *spectator.Gauge
+	gaugeQuarantined        *spectator.Gauge
+	gaugeClockSkewExceeded  *spectator.Gauge
+	gaugeSynced             *spectator.Gauge
+	timerLatency            *histogram.PercentileTimer
 }
 
 func (t *ConnectionState) InitializeMetrics() {
 	t.metricTags = map[string]string{"gnmigateway.client.target": t.name}
+	t.counterAuthFailures = stats.Registry.Counter("gnmigateway.client.subscribe.auth_failures", t.metricTags)
+	t.counterBytes = stats.Registry.Counter("gnmigateway.client.subscribe.bytes", t.metricTags)
+	t.counterClockSkew = stats.Registry.Counter("gnmigateway.client.subscribe.clock_skew_exceeded_total", t.metricTags)
 	t.counterCoalesced = stats.Registry.Counter("gnmigateway.client.subscribe.coalesced", t.metricTags)
+	t.counterDeduplicated = stats.Registry.Counter("gnmigateway.client.subscribe.deduplicated", t.metricTags)
+	t.counterExpired = stats.Registry.Counter("gnmigateway.client.subscribe.expired", t.metricTags)
+	t.counterFederationLoops = stats.Registry.Counter("gnmigateway.client.subscribe.federation_loops", t.metricTags)
+	t.counterFenced = stats.Registry.Counter("gnmigateway.client.subscribe.fenced", t.metricTags)
+	t.counterNormalizeFailed = stats.Registry.Counter("gnmigateway.client.subscribe.normalize_failed", t.metricTags)
 	t.counterNotifications = stats.Registry.Counter("gnmigateway.client.subscribe.notifications", t.metricTags)
+	t.counterPanics = stats.Registry.Counter("gnmigateway.client.subscribe.panics", t.metricTags)
 	t.counterRejected = stats.Registry.Counter("gnmigateway.client.subscribe.rejected", t.metricTags)
 	t.counterStale = stats.Registry.Counter("gnmigateway.client.subscribe.stale", t.metricTags)
+	t.counterSyntheticDeletes = stats.Registry.Counter("gnmigateway.client.subscribe.synthetic_deletes", t.metricTags)
 	t.counterSync = stats.Registry.Counter("gnmigateway.client.subscribe.sync", t.metricTags)
+	t.counterTimestampInvalid = stats.Registry.Counter("gnmigateway.client.subscribe.timestamp_invalid", t.metricTags)
+	t.counterTimestampUnits = stats.Registry.Counter("gnmigateway.client.subscribe.timestamp_units", t.metricTags)
+	t.counterWindowMerged = stats.Registry.Counter("gnmigateway.client.subscribe.window_merged", t.metricTags)
+	t.gaugeClockSkew = stats.Registry.Gauge("gnmigateway.client.subscribe.clock_skew", t.metricTags)
+	t.gaugeClockSkewExceeded = stats.Registry.Gauge("gnmigateway.client.subscribe.clock_skew_exceeded", t.metricTags)
+	t.gaugeQuarantined = stats.Registry.Gauge("gnmigateway.client.subscribe.quarantined", t.metricTags)
 	t.gaugeSynced = stats.Registry.Gauge("gnmigateway.client.subscribe.synced", t.metricTags)
 	t.timerLatency = histogram.NewPercentileTimer(stats.Registry, "gnmigateway.client.subscribe.latency", t.metricTags)
 
 }
 
-// Equal returns true if the target config is different than the target config for
-// this ConnectionState instance.
+// Equal returns true if the target config is the same as the target config for
+// this ConnectionState instance. Addresses, credentials, and meta are compared.
 func (t *ConnectionState) Equal(other *targetpb.Target) bool {
 	if len(t.target.Addresses) != len(other.Addresses) {
 		return false
@@ -137,6 +250,15 @@ func (t *ConnectionState) Equal(other *targetpb.Target) bool {
 			return false
 		}
 	}
+
+	if len(t.target.Meta) != len(other.Meta) {
+		return false
+	}
+	for k, v := range t.target.Meta {
+		if ov, exists := other.Meta[k]; !exists || ov != v {
+			return false
+		}
+	}
 	return true
 }
 
@@ -147,20 +269,85 @@ func (t *ConnectionState) Seen(target string) bool {
 	return t.seen[target]
 }
 
-func (t *ConnectionState) doConnect() {
-	t.connecting = true
+// connectRetryDelay is how long to wait before trying again when a connection
+// can't be attempted because the target configuration is invalid.
+const connectRetryDelay = 30 * time.Second
+
+// doConnect connects to the target and blocks until the subscription stops.
+// An error is returned if the connection couldn't be attempted.
+func (t *ConnectionState) doConnect() error {
+	var err error
+	t.redactor, err = newRedactor(t.config, t.name, t.target.Meta, t.metricTags)
+	if err != nil {
+		// don't connect rather than let the leaves that should be redacted into the cache
+		t.config.Log.Error().Msgf("Target %s: invalid redactions: %v", t.name, err)
+		return err
+	}
+	if t.queryTarget != "*" {
+		t.deleter, err = newDeleteGenerator(t.config, t.name, t.request)
+		if err != nil {
+			t.config.Log.Error().Msgf("Target %s: synthetic deletes are disabled: %v", t.name, err)
+		}
+	}
+	t.rewriter, err = newPathRewriter(t.target.Meta)
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: path rewriting is disabled: %v", t.name, err)
+	}
+	if t.coalescer != nil {
+		t.coalescer.reset()
+	}
+	t.coalescer, err = newUpdateCoalescer(t.target.Meta, t.flushCoalesced, t.counterWindowMerged)
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: update coalescing is disabled: %v", t.name, err)
+	}
+
+	t.clockOffset, err = parseClockOffset(t.target.Meta)
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: clock offset is disabled: %v", t.name, err)
+	}
+	t.normalizer, err = newJSONNormalizer(t.config, t.target.Meta)
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: JSON normalization is disabled: %v", t.name, err)
+	}
+	t.validator, err = newSchemaValidator(t.config, t.target.Meta, t.metricTags)
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: schema validation is disabled: %v", t.name, err)
+	}
+	t.rates = t.rateRules.NewCalculator()
+	t.handler = t.newNotificationHandler()
+
+	if t.isReplay() {
+		t.doReplay()
+		return nil
+	}
+	t.setConnecting()
 	t.config.Log.Info().Msgf("Target %s: Connecting", t.name)
-	query, err := client.NewQuery(t.request)
+	request := t.request
+	if t.federated {
+		request = federatedRequest(t.request)
+	}
+	query, err := client.NewQuery(request)
 	if err != nil {
-		t.config.Log.Error().Msgf("Target %s: unable to create query: NewQuery(%s): %v", t.name, t.request.String(), err)
-		return
+		t.config.Log.Error().Msgf("Target %s: unable to create query: NewQuery(%s): %v", t.name, request.String(), err)
+		return err
 	}
 	query.Addrs = t.target.Addresses
 
 	if t.target.Credentials != nil {
+		// Encrypted credentials are only decrypted in memory for the connection.
+		username, err := secrets.Decrypt(t.target.Credentials.Username)
+		if err != nil {
+			t.config.Log.Error().Msgf("Target %s: unable to decrypt username: %v", t.name, err)
+			return err
+		}
+		password, err := secrets.Decrypt(t.target.Credentials.Password)
+		if err != nil {
+			t.config.Log.Error().Msgf("Target %s: unable to decrypt password: %v", t.name, err)
+			return err
+		}
 		query.Credentials = &client.Credentials{
-			Username: t.target.Credentials.Username,
-			Password: t.target.Credentials.Password,
+			Username: username,
+			Password: password,
 		}
 	}
 
@@ -183,8 +370,8 @@ func (t *ConnectionState) doConnect() {
 	}
 
 	var prefixTarget string
-	if t.request.GetSubscribe() != nil && t.request.GetSubscribe().GetPrefix() != nil {
-		prefixTarget = t.request.GetSubscribe().GetPrefix().GetTarget()
+	if request.GetSubscribe() != nil && request.GetSubscribe().GetPrefix() != nil {
+		prefixTarget = request.GetSubscribe().GetPrefix().GetTarget()
 	}
 
 	if prefixTarget != "" {
@@ -194,35 +381,55 @@ func (t *ConnectionState) doConnect() {
 		query.Target = t.name
 		t.queryTarget = t.name
 	}
+	if t.federated {
+		// the targets of a gateway target are cached under their own names
+		t.queryTarget = "*"
+	}
 
-	query.Timeout = t.config.TargetDialTimeout
+	timeouts, err := parseConnectionTimeouts(t.config, t.target.Meta)
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: invalid connection options: %v", t.name, err)
+		return err
+	}
+	query.Timeout = timeouts.dial
 
 	query.ProtoHandler = t.handleUpdate
 
 	if err := query.Validate(); err != nil {
 		t.config.Log.Error().Err(err).Msgf("query.Validate(): %v", err)
-		return
+		return err
+	}
+
+	targetClient, err := t.newTargetClient()
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: invalid connection options: %v", t.name, err)
+		return err
 	}
 
 	var ctx context.Context
 	ctx, t.clientCancel = context.WithCancel(context.Background())
 	t.config.Log.Info().Msgf("Target %s: Subscribing", t.name)
-	t.client = client.Reconnect(&client.BaseClient{}, t.disconnected, t.reset)
+	t.client = client.Reconnect(targetClient, t.disconnected, t.reset)
 	if err := t.client.Subscribe(ctx, query, gnmiclient.Type); err != nil {
 		t.config.Log.Info().Msgf("Target %s: Subscribe stopped: %v", t.name, err)
 	}
+	return nil
 }
 
 // Attempt to acquire a connection slot and connect to the target. If ConnectionState.disconnect() is called
 // all attempts and connections are aborted.
 func (t *ConnectionState) connect(connectionSlot *semaphore.Weighted) {
 	var connectionSlotAcquired = false
-	for !t.stopped {
+	for !t.isStopped() {
 		if !connectionSlotAcquired {
 			connectionSlotAcquired = connectionSlot.TryAcquire(1)
 		}
 		if connectionSlotAcquired {
-			t.doConnect()
+			if err := t.doConnect(); err != nil {
+				// the target configuration can't be used; wait instead of
+				// retrying in a tight loop
+				time.Sleep(connectRetryDelay)
+			}
 		}
 	}
 	if connectionSlotAcquired {
@@ -235,7 +442,7 @@ func (t *ConnectionState) connect(connectionSlot *semaphore.Weighted) {
 // all attempts and connections are aborted.
 func (t *ConnectionState) connectWithLock(connectionSlot *semaphore.Weighted) {
 	var connectionSlotAcquired = false
-	for !t.stopped {
+	for !t.isStopped() {
 		if !connectionSlotAcquired {
 			t.config.Log.Info().Msgf("Target %s: Acquiring connection slot", t.name)
 			connectionSlotAcquired = connectionSlot.TryAcquire(1)
@@ -251,16 +458,22 @@ func (t *ConnectionState) connectWithLock(connectionSlot *semaphore.Weighted) {
 				}
 			}
 			if t.ConnectionLockAcquired {
-				t.config.Log.Info().Msgf("Target %s: Lock acquired", t.name)
-				t.doConnect()
+				t.fencingToken = t.lock.Token()
+				t.config.Log.Info().Msgf("Target %s: Lock acquired (fencing token %d)", t.name, t.fencingToken)
+				connectErr := t.doConnect()
 				if t.lock.LockAcquired() {
 					err := t.lock.Unlock()
-					if err != nil && err != zk.ErrNotLocked {
+					if err != nil && err != locking.ErrNotLocked {
 						t.config.Log.Error().Msgf("Target %s: error while releasing lock: %v", t.name, err)
 					}
 				}
 				t.ConnectionLockAcquired = false
 				t.config.Log.Info().Msgf("Target %s: Lock released", t.name)
+				if connectErr != nil {
+					// release the lock and wait so that another member can
+					// try the target and the lock isn't taken in a tight loop
+					time.Sleep(connectRetryDelay)
+				}
 			} else {
 				time.Sleep(1 * time.Second)
 			}
@@ -275,7 +488,14 @@ func (t *ConnectionState) connectWithLock(connectionSlot *semaphore.Weighted) {
 // Disconnect from the target or stop trying to connect.
 func (t *ConnectionState) disconnect() error {
 	t.config.Log.Info().Msgf("Target %s: Disconnecting", t.name)
-	t.stopped = true
+	t.setStopped()
+	if t.client == nil {
+		// Replay targets don't have a client.
+		if t.clientCancel != nil {
+			t.clientCancel()
+		}
+		return nil
+	}
 	return t.client.Close() // this will disconnect and reset the cache via the disconnect callback
 }
 
@@ -286,25 +506,50 @@ func (t *ConnectionState) reset() {
 
 // Callback for gNMI client to signal that it has disconnected.
 func (t *ConnectionState) disconnected() {
-	t.connected = false
-	t.synced = false
+	if t.setDisconnected() {
+		events.Publish(events.TargetDisconnected, t.name, t.Address(), t.LastError())
+	}
+	t.setAddress("")
 	t.seenMutex.Lock()
 	t.seen = map[string]bool{}
 	t.seenMutex.Unlock()
-	if t.queryTarget != "*" {
-		t.targetCache.Reset()
+	t.resetOrigins()
+	if t.coalescer != nil {
+		t.coalescer.reset()
+	}
+	if t.deleter != nil {
+		t.deleter.reset()
+	}
+	if t.queryTarget != "*" && !t.isDisabled() {
+		// the data of disabled targets is kept until DisableTarget flushes it
+		t.resetTargetCache()
 	}
 	t.config.Log.Info().Msgf("Target %s: Disconnected", t.name)
 }
 
+// flush removes the target's data from the cache.
+func (t *ConnectionState) flush() {
+	if !t.clusterMember && t.queryTarget != "*" {
+		t.resetTargetCache()
+	}
+}
+
 func (t *ConnectionState) reconnect() error {
 	t.config.Log.Info().Msgf("Target %s: Reconnecting", t.name)
+	if t.client == nil {
+		if t.clientCancel != nil {
+			t.clientCancel()
+		}
+		return nil
+	}
 	return t.client.Close()
 }
 
 func (t *ConnectionState) unlock() error {
 	t.config.Log.Info().Msgf("Target %s: Unlocking", t.name)
-	t.clientCancel()
+	if t.clientCancel != nil {
+		t.clientCancel()
+	}
 	return nil
 	//return t.client.Close()
 }
@@ -314,68 +559,148 @@ func (t *ConnectionState) unlock() error {
 // cache.Target is called to generate an update. If the message is a sync_response, then targetCache is
 // marked as synchronised.
 func (t *ConnectionState) handleUpdate(msg proto.Message) error {
-	//fmt.Printf("%+v\n", msg)
+	ctx, span := tracing.Start(context.Background(), "target.update")
+	if span != nil {
+		span.SetAttribute("gnmi.target", t.name)
+		if resp, ok := msg.(*gnmipb.SubscribeResponse); ok && resp.GetUpdate() != nil {
+			span.SetAttribute("gnmi.updates", len(resp.GetUpdate().GetUpdate()))
+			span.SetAttribute("gnmi.deletes", len(resp.GetUpdate().GetDelete()))
+		}
+	}
+	err := t.recoverUpdate(ctx, msg)
+	span.SetError(err)
+	span.End()
+	return err
+}
+
+// recoverUpdate calls processUpdate and turns a panic, e.g. caused by a badly
+// encoded message, into an error so that only the target's connection is
+// restarted instead of the gateway crashing.
+func (t *ConnectionState) recoverUpdate(ctx context.Context, msg proto.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.counterPanics.Increment()
+			t.config.Log.Error().Msgf("Target %s: panic while handling update: %v\n%s", t.name, r, debug.Stack())
+			err = fmt.Errorf("panic while handling update: %v", r)
+		}
+	}()
+	return t.processUpdate(ctx, msg)
+}
+
+// processUpdate passes a SubscribeResponse through the update pipeline and
+// inserts it into the target cache. The updates are linked to the span in ctx
+// so that the spans for sending them to gNMI clients are its children.
+func (t *ConnectionState) processUpdate(ctx context.Context, msg proto.Message) error {
+	received := time.Now()
+	size := proto.Size(msg)
 	t.counterNotifications.Increment()
-	if !t.connected {
+	t.counterBytes.Add(int64(size))
+	atomic.AddUint64(&t.received, 1)
+	atomic.AddUint64(&t.receivedBytes, uint64(size))
+	if t.fenced() {
+		// another member may hold the lock now; stop inserting updates and
+		// reconnect once the lock is acquired again
+		t.counterFenced.Increment()
+		t.config.Log.Error().Msgf("Target %s: Lock lost; dropping updates and disconnecting", t.name)
+		events.Publish(events.TargetLockLost, t.name, t.Address(), errFenced.Error())
+		if t.clientCancel != nil {
+			t.clientCancel()
+		}
+		return errFenced
+	}
+	if t.setConnected() {
 		if t.queryTarget != "*" {
+			locker := t.cacheLocker(t.name)
+			locker.Lock()
 			t.targetCache.Connect()
+			locker.Unlock()
+			if t.useLock {
+				if err := t.publishFencingToken(); err != nil {
+					t.config.Log.Error().Msgf("Target %s: unable to publish fencing token: %v", t.name, err)
+				}
+			}
 		}
-		t.connected = true
 		t.config.Log.Info().Msgf("Target %s: Connected", t.name)
+		events.Publish(events.TargetConnected, t.name, t.Address(), "")
 	}
 	resp, ok := msg.(*gnmipb.SubscribeResponse)
 	if !ok {
 		return fmt.Errorf("failed to type assert message %#v", msg)
 	}
+	t.writeCapture(resp)
 	switch v := resp.Response.(type) {
 	case *gnmipb.SubscribeResponse_Update:
-		if t.rejectUpdate(v.Update) {
-			t.counterRejected.Increment()
-			return nil
+		if t.rewriter != nil {
+			t.rewriter.rewrite(v.Update)
 		}
 
-		if t.synced {
-			for _, u := range v.Update.Update {
-				t.counterCoalesced.Add(int64(u.Duplicates))
+		if t.normalizer != nil {
+			if err := t.normalizer.normalize(v.Update); err != nil {
+				t.counterNormalizeFailed.Increment()
+				t.config.Log.Debug().Msgf("Target %s: unable to normalize JSON value: %v", t.name, err)
 			}
-			t.timerLatency.Record(time.Duration(time.Now().UnixNano() - v.Update.Timestamp))
 		}
 
-		switch t.queryTarget {
-		case "*":
-			targetCache := t.connManager.Cache().GetTarget(v.Update.Prefix.Target)
-			if targetCache == nil {
-				targetCache = t.connManager.Cache().Add(v.Update.Prefix.Target)
-			}
-			t.seenMutex.Lock()
-			t.seen[v.Update.Prefix.Target] = true
-			t.seenMutex.Unlock()
-			err := t.updateTargetCache(targetCache, v.Update)
-			if err != nil {
-				return err
+		if t.redactor != nil && t.redactor.redact(v.Update) > 0 {
+			if len(v.Update.Update) == 0 && len(v.Update.Delete) == 0 {
+				return nil
 			}
-		default:
-			// Gracefully handle gNMI implementations that do not set Prefix.Target in their
-			// SubscribeResponse Updates.
-			if v.Update.GetPrefix() == nil {
-				v.Update.Prefix = &gnmipb.Path{}
+		}
+
+		if t.validator != nil {
+			errs := t.validator.validate(v.Update)
+			for _, err := range errs {
+				t.config.Log.Debug().Msgf("Target %s: schema-invalid update: %v", t.name, err)
 			}
-			if v.Update.Prefix.Target == "" {
-				v.Update.Prefix.Target = t.queryTarget
+			if len(errs) > 0 && len(v.Update.Update) == 0 && len(v.Update.Delete) == 0 {
+				t.reject()
+				return nil
 			}
-			err := t.updateTargetCache(t.targetCache, v.Update)
-			if err != nil {
-				return err
+		}
+
+		if !t.checkTimestamp(v.Update, received) {
+			t.reject()
+			return nil
+		}
+
+		tracing.LinkNotification(ctx, v.Update)
+
+		if t.isSynced() {
+			for _, u := range v.Update.Update {
+				t.counterCoalesced.Add(int64(u.Duplicates))
 			}
+			t.timerLatency.Record(time.Duration(time.Now().UnixNano() - v.Update.Timestamp))
+			t.measureSkew(v.Update.Timestamp, received)
 		}
 
+		// the list entries are observed before the handler may drop the
+		// updates of unchanged leaves
+		var deletes []*gnmipb.Notification
+		if t.deleter != nil {
+			deletes = t.deleter.observe(v.Update, time.Now())
+		}
+
+		if err := t.handler(ctx, t.name, v.Update); err != nil {
+			return err
+		}
+		t.insertSyntheticDeletes(deletes)
+
 	case *gnmipb.SubscribeResponse_SyncResponse:
 		t.sync()
 		switch t.queryTarget {
 		case "*":
 			// do nothing
 		default:
+			if t.deleter != nil {
+				t.insertSyntheticDeletes(t.deleter.sync(time.Now()))
+			}
+			locker := t.cacheLocker(t.name)
+			locker.Lock()
 			t.targetCache.Sync()
+			locker.Unlock()
+			if err := t.publishSynced(); err != nil {
+				t.config.Log.Debug().Msgf("Target %s: unable to publish the sync meta leaf: %v", t.name, err)
+			}
 		}
 	case *gnmipb.SubscribeResponse_Error:
 		return fmt.Errorf("error in response: %s", v)
@@ -385,14 +710,102 @@ func (t *ConnectionState) handleUpdate(msg proto.Message) error {
 	return nil
 }
 
+// startCapture begins writing raw SubscribeResponses to file. Any running
+// capture is stopped first.
+func (t *ConnectionState) startCapture(file string, format capture.Format) error {
+	writer, err := capture.NewWriter(file, format)
+	if err != nil {
+		return err
+	}
+	t.captureMutex.Lock()
+	previous := t.capture
+	t.capture = writer
+	t.captureMutex.Unlock()
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			t.config.Log.Error().Msgf("Target %s: error closing capture file '%s': %v", t.name, previous.Name(), err)
+		}
+	}
+	t.config.Log.Info().Msgf("Target %s: Capturing to '%s' (%s)", t.name, file, format)
+	return nil
+}
+
+// stopCapture stops the running capture and closes the capture file.
+func (t *ConnectionState) stopCapture() error {
+	t.captureMutex.Lock()
+	writer := t.capture
+	t.capture = nil
+	t.captureMutex.Unlock()
+	if writer == nil {
+		return fmt.Errorf("no capture is running for target '%s'", t.name)
+	}
+	t.config.Log.Info().Msgf("Target %s: Capture to '%s' stopped after %d messages", t.name, writer.Name(), writer.Count())
+	return writer.Close()
+}
+
+// writeCapture writes the message to the capture file if a capture is running.
+func (t *ConnectionState) writeCapture(resp *gnmipb.SubscribeResponse) {
+	t.captureMutex.Lock()
+	defer t.captureMutex.Unlock()
+	if t.capture == nil {
+		return
+	}
+	if err := t.capture.Write(resp); err != nil {
+		t.config.Log.Error().Msgf("Target %s: unable to write capture; stopping capture: %v", t.name, err)
+		_ = t.capture.Close()
+		t.capture = nil
+	}
+}
+
+// insertNotification is the last stage of the notification handler: it
+// derives counter rates and inserts the notification into the cache.
+func (t *ConnectionState) insertNotification(_ context.Context, _ string, notification *gnmipb.Notification) error {
+	if t.rates != nil {
+		notification.Update = append(notification.Update, t.rates.Derive(notification)...)
+	}
+
+	switch t.queryTarget {
+	case "*":
+		if t.clusterMember && !t.checkReplicated(notification) {
+			t.counterFenced.Increment()
+			return nil
+		}
+		if t.federated && !t.checkOrigin(notification) {
+			return nil
+		}
+		targetCache := t.connManager.Cache().GetTarget(notification.Prefix.Target)
+		if targetCache == nil {
+			targetCache = t.connManager.Cache().Add(notification.Prefix.Target)
+		}
+		t.seenMutex.Lock()
+		t.seen[notification.Prefix.Target] = true
+		t.seenMutex.Unlock()
+		return t.insertUpdate(targetCache, notification)
+	default:
+		// Gracefully handle gNMI implementations that do not set Prefix.Target in their
+		// SubscribeResponse Updates.
+		if notification.GetPrefix() == nil {
+			notification.Prefix = &gnmipb.Path{}
+		}
+		if notification.Prefix.Target == "" {
+			notification.Prefix.Target = t.queryTarget
+		}
+		if t.deduplicateSync() && t.dropReplicated(notification) {
+			return nil
+		}
+		return t.insertUpdate(t.targetCache, notification)
+	}
+}
+
 // sync sets the state of the ConnectionState to synced.
 func (t *ConnectionState) sync() {
 	t.config.Log.Info().Msgf("Target %s: Synced", t.name)
-	t.synced = true
+	t.setSynced()
 	t.counterSync.Increment()
+	events.Publish(events.TargetSynced, t.name, t.Address(), "")
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
-		for t.synced {
+		for t.isSynced() {
 			select {
 			case <-ticker.C:
 				t.gaugeSynced.Set(1)
@@ -401,9 +814,18 @@ func (t *ConnectionState) sync() {
 	}()
 }
 
+// insertUpdate inserts the notification into the cache or, once the target has
+// synced, passes it to the update coalescer if one is configured.
+func (t *ConnectionState) insertUpdate(targetCache *cache.Target, update *gnmipb.Notification) error {
+	if t.isSynced() && t.coalescer != nil && t.coalescer.add(targetCache, update) {
+		return nil
+	}
+	return t.updateTargetCache(targetCache, update)
+}
+
 func (t *ConnectionState) updateTargetCache(cache *cache.Target, update *gnmipb.Notification) error {
 	var hasError bool
-	err := cache.GnmiUpdate(update)
+	err := t.gnmiUpdate(cache, update)
 	if err != nil {
 		// Some errors won't corrupt the cache so no need to return an error to the ProtoHandler caller. For these
 		// errors we just log them and move on.
@@ -425,6 +847,46 @@ func (t *ConnectionState) updateTargetCache(cache *cache.Target, update *gnmipb.
 	return nil
 }
 
+// gnmiUpdate inserts the notification into the cache and records the receive
+// time of its leaves for leaf expiry. The cache clients receive the deletes
+// of the notification with Elem paths (see shardedcache.ResolveDeletes).
+func (t *ConnectionState) gnmiUpdate(cache *cache.Target, notification *gnmipb.Notification) error {
+	t.leafTimesMutex.Lock()
+	defer t.leafTimesMutex.Unlock()
+	locker := t.cacheLocker(cache.Name())
+	locker.Lock()
+	defer locker.Unlock()
+	if len(notification.Delete) > 0 && t.connManager != nil {
+		defer t.connManager.Cache().ResolveDeletes(notification)()
+	}
+	err := cache.GnmiUpdate(notification)
+	t.trackLeaves(notification, time.Now())
+	return err
+}
+
+// cacheLocker returns the lock that is held while the target is updated in
+// the cache so that cache snapshots don't include partial updates.
+func (t *ConnectionState) cacheLocker(target string) sync.Locker {
+	if t.connManager == nil {
+		return noLocker{}
+	}
+	return t.connManager.Cache().UpdateLocker(target)
+}
+
+// noLocker is the cacheLocker of connections without a connection manager.
+type noLocker struct{}
+
+func (noLocker) Lock()   {}
+func (noLocker) Unlock() {}
+
+// resetTargetCache removes the target's data from the cache.
+func (t *ConnectionState) resetTargetCache() {
+	locker := t.cacheLocker(t.name)
+	locker.Lock()
+	defer locker.Unlock()
+	t.targetCache.Reset()
+}
+
 func (t *ConnectionState) handleCacheError(err error) bool {
 	switch err.Error() {
 	case "suppressed duplicate value":
@@ -450,7 +912,8 @@ func (t *ConnectionState) rejectUpdate(notification *gnmipb.Notification) bool {
 	return false
 }
 
-// Return true if all of the elements in toMatch are found in path.
+// Return true if all of the elements in toMatch are found in path. Element names
+// and key values of "*" in toMatch will match any value.
 func matchPath(path []*gnmipb.PathElem, toMatch []*gnmipb.PathElem) bool {
 	if len(path) < len(toMatch) {
 		return false
@@ -18,8 +18,11 @@ package connections
 import (
 	"github.com/openconfig/gnmi/cache"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"testing"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 )
 
 func TestConnectionState_updateTargetCache(t *testing.T) {
@@ -80,3 +83,23 @@ func TestConnectionState_updateTargetCache(t *testing.T) {
 	}
 	assert.Equal(t, float64(3), state.counterStale.Count())
 }
+
+func TestConnectionState_handleUpdate_Panic(t *testing.T) {
+	config := &configuration.GatewayConfig{Log: zerolog.Nop(), TimestampPolicy: TimestampPolicyNone}
+	// The nil connManager causes a panic when the update is inserted.
+	state := &ConnectionState{
+		config:      config,
+		connected:   true,
+		name:        "test_state",
+		queryTarget: "*",
+		seen:        make(map[string]bool),
+	}
+	state.InitializeMetrics()
+	state.handler = state.newNotificationHandler()
+
+	err := state.handleUpdate(&gnmipb.SubscribeResponse{Response: &gnmipb.SubscribeResponse_Update{
+		Update: &gnmipb.Notification{Prefix: &gnmipb.Path{Target: "a"}},
+	}})
+	assert.Error(t, err)
+	assert.Equal(t, float64(1), state.counterPanics.Count())
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Policies for handling notifications with out-of-range timestamps. See
+// TimestampPolicy in GatewayConfig.
+const (
+	// TimestampPolicyNone does not check timestamps.
+	TimestampPolicyNone = ""
+	// TimestampPolicyReject drops notifications with out-of-range timestamps.
+	TimestampPolicyReject = "reject"
+	// TimestampPolicyClamp replaces out-of-range timestamps with the receive time.
+	TimestampPolicyClamp = "clamp"
+	// TimestampPolicyOverwrite replaces all timestamps with the receive time.
+	TimestampPolicyOverwrite = "overwrite"
+)
+
+// MetaClockOffset is the target meta field that sets a duration (e.g. "-2.5s")
+// added to the timestamps of all notifications from the target to correct
+// devices with known-bad clocks.
+const MetaClockOffset = "ClockOffset"
+
+// skewSmoothing is the weight of each new sample in the clock skew moving
+// average (1/skewSmoothing).
+const skewSmoothing = 16
+
+// ValidateTimestampPolicy returns an error if the policy is not a known policy.
+func ValidateTimestampPolicy(policy string) error {
+	switch policy {
+	case TimestampPolicyNone, TimestampPolicyReject, TimestampPolicyClamp, TimestampPolicyOverwrite:
+		return nil
+	}
+	return fmt.Errorf("unknown timestamp policy '%s'", policy)
+}
+
+// checkTimestamp validates and corrects the timestamp of the notification per the
+// configured TimestampPolicy. checkTimestamp returns false if the notification
+// should be rejected.
+func (t *ConnectionState) checkTimestamp(notification *gnmipb.Notification, received time.Time) bool {
+	if t.config.TimestampFixUnits {
+		fixed := normalizeTimestampUnits(notification.Timestamp)
+		if fixed != notification.Timestamp {
+			t.counterTimestampUnits.Increment()
+			notification.Timestamp = fixed
+		}
+	}
+	if t.clockOffset != 0 {
+		notification.Timestamp += int64(t.clockOffset)
+	}
+
+	switch t.config.TimestampPolicy {
+	case TimestampPolicyNone:
+		return true
+	case TimestampPolicyOverwrite:
+		notification.Timestamp = received.UnixNano()
+		return true
+	}
+
+	if timestampInRange(notification.Timestamp, received, t.config.TimestampMaxPast, t.config.TimestampMaxFuture) {
+		return true
+	}
+
+	t.counterTimestampInvalid.Increment()
+	if t.config.TimestampPolicy == TimestampPolicyClamp {
+		notification.Timestamp = received.UnixNano()
+		return true
+	}
+	return false
+}
+
+// parseClockOffset returns the clock offset configured for the target.
+func parseClockOffset(meta map[string]string) (time.Duration, error) {
+	value, exists := meta[MetaClockOffset]
+	if !exists {
+		return 0, nil
+	}
+	offset, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %v", MetaClockOffset, value, err)
+	}
+	return offset, nil
+}
+
+// measureSkew updates the moving average of the difference between the
+// receive time and the notification timestamp. Once the target is synced this
+// is the sum of the transport latency and the skew between the target's clock
+// and the gateway's clock. A warning is logged when the average exceeds
+// ClockSkewThreshold in either direction.
+func (t *ConnectionState) measureSkew(timestamp int64, received time.Time) {
+	offset := received.UnixNano() - timestamp
+	skew := atomic.LoadInt64(&t.skew)
+	skew += (offset - skew) / skewSmoothing
+	atomic.StoreInt64(&t.skew, skew)
+	t.gaugeClockSkew.Set(time.Duration(skew).Seconds())
+
+	threshold := t.config.ClockSkewThreshold
+	if threshold <= 0 {
+		return
+	}
+	exceeded := skew > int64(threshold) || skew < -int64(threshold)
+	if exceeded == t.skewExceeded {
+		return
+	}
+	t.skewExceeded = exceeded
+	if exceeded {
+		t.counterClockSkew.Increment()
+		t.gaugeClockSkewExceeded.Set(1)
+		t.config.Log.Warn().Msgf("Target %s: clock skew of %s exceeds %s", t.name, time.Duration(skew), threshold)
+	} else {
+		t.gaugeClockSkewExceeded.Set(0)
+		t.config.Log.Info().Msgf("Target %s: clock skew of %s is within %s", t.name, time.Duration(skew), threshold)
+	}
+}
+
+// timestampInRange returns true if the timestamp (in nanoseconds) is no older than
+// maxPast and no further in the future than maxFuture relative to received.
+// A zero maxPast or maxFuture disables the respective check.
+func timestampInRange(timestamp int64, received time.Time, maxPast time.Duration, maxFuture time.Duration) bool {
+	offset := time.Duration(timestamp - received.UnixNano())
+	if maxFuture > 0 && offset > maxFuture {
+		return false
+	}
+	if maxPast > 0 && offset < -maxPast {
+		return false
+	}
+	return true
+}
+
+// normalizeTimestampUnits converts timestamps that appear to be in seconds,
+// milliseconds, or microseconds to nanoseconds. The unit is inferred from the
+// magnitude of the timestamp which works for any time after 1973.
+func normalizeTimestampUnits(timestamp int64) int64 {
+	switch {
+	case timestamp <= 0:
+		return timestamp
+	case timestamp < 1e11: // seconds
+		return timestamp * int64(time.Second)
+	case timestamp < 1e14: // milliseconds
+		return timestamp * int64(time.Millisecond)
+	case timestamp < 1e17: // microseconds
+		return timestamp * int64(time.Microsecond)
+	}
+	return timestamp
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestNormalizeTimestampUnits(t *testing.T) {
+	assertion := assert.New(t)
+	expected := time.Unix(1600000000, 0).UnixNano()
+
+	assertion.Equal(expected, normalizeTimestampUnits(1600000000))
+	assertion.Equal(expected, normalizeTimestampUnits(1600000000000))
+	assertion.Equal(expected, normalizeTimestampUnits(1600000000000000))
+	assertion.Equal(expected, normalizeTimestampUnits(expected))
+	assertion.Equal(int64(0), normalizeTimestampUnits(0))
+}
+
+func TestConnectionState_checkTimestamp(t *testing.T) {
+	received := time.Unix(1600000000, 0)
+	future := received.Add(time.Hour).UnixNano()
+	past := received.Add(-time.Hour).UnixNano()
+
+	tests := []struct {
+		name      string
+		policy    string
+		timestamp int64
+		fixUnits  bool
+		accepted  bool
+		expected  int64
+	}{
+		{"none", TimestampPolicyNone, future, false, true, future},
+		{"reject future", TimestampPolicyReject, future, false, false, future},
+		{"reject past", TimestampPolicyReject, past, false, false, past},
+		{"accept in range", TimestampPolicyReject, received.UnixNano(), false, true, received.UnixNano()},
+		{"clamp", TimestampPolicyClamp, future, false, true, received.UnixNano()},
+		{"overwrite", TimestampPolicyOverwrite, received.Add(time.Second).UnixNano(), false, true, received.UnixNano()},
+		{"fix units", TimestampPolicyReject, received.Unix(), true, true, received.UnixNano()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertion := assert.New(t)
+			config := configuration.NewDefaultGatewayConfig()
+			config.TimestampPolicy = test.policy
+			config.TimestampFixUnits = test.fixUnits
+			config.TimestampMaxFuture = time.Minute
+			config.TimestampMaxPast = time.Minute
+			state := &ConnectionState{
+				config: config,
+				name:   "test_timestamp_" + test.name,
+			}
+			state.InitializeMetrics()
+
+			notification := &gnmipb.Notification{Timestamp: test.timestamp}
+			assertion.Equal(test.accepted, state.checkTimestamp(notification, received))
+			assertion.Equal(test.expected, notification.Timestamp)
+		})
+	}
+}
+
+func TestConnectionState_clockOffset(t *testing.T) {
+	assertion := assert.New(t)
+	received := time.Unix(1600000000, 0)
+
+	offset, err := parseClockOffset(map[string]string{MetaClockOffset: "-1h"})
+	assertion.NoError(err)
+	_, err = parseClockOffset(map[string]string{MetaClockOffset: "soon"})
+	assertion.Error(err)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.TimestampPolicy = TimestampPolicyReject
+	config.TimestampMaxFuture = time.Minute
+	state := &ConnectionState{config: config, name: "a", clockOffset: offset}
+	state.InitializeMetrics()
+
+	notification := &gnmipb.Notification{Timestamp: received.Add(time.Hour).UnixNano()}
+	assertion.True(state.checkTimestamp(notification, received))
+	assertion.Equal(received.UnixNano(), notification.Timestamp)
+}
+
+func TestConnectionState_measureSkew(t *testing.T) {
+	assertion := assert.New(t)
+	received := time.Unix(1600000000, 0)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.ClockSkewThreshold = time.Second
+	state := &ConnectionState{config: config, name: "a"}
+	state.InitializeMetrics()
+
+	for i := 0; i < 100; i++ {
+		state.measureSkew(received.Add(-10*time.Millisecond).UnixNano(), received)
+	}
+	assertion.InDelta(float64(10*time.Millisecond), float64(state.skew), float64(time.Millisecond))
+	assertion.False(state.skewExceeded)
+
+	for i := 0; i < 100; i++ {
+		state.measureSkew(received.Add(time.Minute).UnixNano(), received)
+	}
+	assertion.True(state.skewExceeded)
+	assertion.Less(state.skew, int64(0))
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/metadata"
+	"github.com/openconfig/gnmi/path"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// defaultLeafTTLSweepInterval is used if LeafTTLSweepInterval is not configured.
+const defaultLeafTTLSweepInterval = time.Minute
+
+// leafTTL is a parsed configuration.LeafTTL.
+type leafTTL struct {
+	path []*gnmipb.PathElem
+	ttl  time.Duration
+}
+
+// leafExpiry contains the TTL rules used to expire leaves from the cache.
+type leafExpiry struct {
+	defaultTTL time.Duration
+	rules      []leafTTL
+}
+
+// newLeafExpiry parses the leaf TTL configuration. A nil leafExpiry is
+// returned if no TTLs are configured.
+func newLeafExpiry(config *configuration.GatewayConfig) (*leafExpiry, error) {
+	if config.DefaultLeafTTL <= 0 && len(config.LeafTTLs) == 0 {
+		return nil, nil
+	}
+	e := &leafExpiry{defaultTTL: config.DefaultLeafTTL}
+	for _, rule := range config.LeafTTLs {
+		path, err := xpath.ToGNMIPath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid leaf TTL path '%s': %v", rule.Path, err)
+		}
+		e.rules = append(e.rules, leafTTL{path: path.Elem, ttl: rule.TTL})
+	}
+	return e, nil
+}
+
+// ttl returns the TTL for the path. The most specific matching rule is used;
+// the default TTL is returned if no rules match. Rule paths may use "*" for
+// element names and key values.
+func (e *leafExpiry) ttl(path []*gnmipb.PathElem) time.Duration {
+	ttl := e.defaultTTL
+	var matchedLen = -1
+	for _, rule := range e.rules {
+		if len(rule.path) > matchedLen && utils.MatchPathPrefix(path, rule.path) {
+			ttl = rule.ttl
+			matchedLen = len(rule.path)
+		}
+	}
+	return ttl
+}
+
+// leafKey returns the key used to track the receive time of a leaf.
+func leafKey(prefix *gnmipb.Path, p *gnmipb.Path) string {
+	return strings.Join(append(path.ToStrings(prefix, true), path.ToStrings(p, false)...), "\x00")
+}
+
+// trackLeaves records the receive time of the leaves updated by the
+// notification and forgets the leaves it deletes. It must be called with
+// leafTimesMutex held.
+func (t *ConnectionState) trackLeaves(notification *gnmipb.Notification, received time.Time) {
+	if t.leafTimes == nil {
+		return
+	}
+	for _, update := range notification.Update {
+		t.leafTimes[leafKey(notification.Prefix, update.Path)] = received
+	}
+	for _, del := range notification.Delete {
+		deleted := leafKey(notification.Prefix, del)
+		for key := range t.leafTimes {
+			if key == deleted || strings.HasPrefix(key, deleted+"\x00") {
+				delete(t.leafTimes, key)
+			}
+		}
+	}
+}
+
+// runLeafExpiry periodically removes expired leaves from the cache until the
+// connection manager is stopped.
+func (c *ZookeeperConnectionManager) runLeafExpiry(expiry *leafExpiry) {
+	interval := c.config.LeafTTLSweepInterval
+	if interval <= 0 {
+		interval = defaultLeafTTLSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-c.stop:
+			return
+		case now = <-ticker.C:
+		}
+
+		c.connectionsMutex.Lock()
+		var conns []*ConnectionState
+		for _, conn := range c.connections {
+			if !conn.clusterMember {
+				conns = append(conns, conn)
+			}
+		}
+		c.connectionsMutex.Unlock()
+
+		for _, conn := range conns {
+			expired := conn.expireLeaves(c.cache, expiry, now)
+			if expired > 0 {
+				c.config.Log.Info().Msgf("Target %s: Expired %d stale leaves", conn.name, expired)
+			}
+		}
+	}
+}
+
+// expireLeaves deletes leaves from the target cache that haven't been received
+// within their TTL. Deletes are propagated to cache clients as gNMI delete
+// notifications. Returns the number of leaves deleted.
+//
+// Leaves are aged by the time they were inserted into the cache rather than
+// by their notification timestamps so that targets with skewed clocks are
+// handled correctly. Leaves without a recorded receive time, such as those
+// inserted before expiry was enabled, are treated as received at now.
+func (t *ConnectionState) expireLeaves(c *shardedcache.Cache, expiry *leafExpiry, now time.Time) int {
+	if t.queryTarget == "*" || !c.HasTarget(t.name) {
+		return 0
+	}
+
+	// leafTimesMutex is held until the deletes are made so that an update
+	// received between the query and the delete isn't removed.
+	t.leafTimesMutex.Lock()
+	defer t.leafTimesMutex.Unlock()
+	if t.leafTimes == nil {
+		t.leafTimes = make(map[string]time.Time)
+	}
+
+	present := make(map[string]bool)
+	var deletes []*gnmipb.Notification
+	// expired are the leafTimes keys of the leaves deleted by deletes.
+	var expired []string
+	err := c.Query(t.name, []string{"*"}, func(p []string, _ *ctree.Leaf, val interface{}) error {
+		notification, ok := val.(*gnmipb.Notification)
+		if !ok || notification == nil {
+			return nil
+		}
+		// The metadata leaves are maintained by the cache.
+		if len(p) > 0 && p[0] == metadata.Root {
+			return nil
+		}
+		prefix := notification.GetPrefix()
+		for _, update := range notification.Update {
+			key := leafKey(prefix, update.GetPath())
+			present[key] = true
+			received, exists := t.leafTimes[key]
+			if !exists {
+				t.leafTimes[key] = now
+				continue
+			}
+			fullPath := make([]*gnmipb.PathElem, 0, len(prefix.GetElem())+len(update.GetPath().GetElem()))
+			fullPath = append(fullPath, prefix.GetElem()...)
+			fullPath = append(fullPath, update.GetPath().GetElem()...)
+			ttl := expiry.ttl(fullPath)
+			if ttl <= 0 || now.Sub(received) <= ttl {
+				continue
+			}
+			// The cache only deletes leaves older than the delete, so the
+			// delete must be newer than leaves with skewed timestamps.
+			timestamp := now.UnixNano()
+			if notification.GetTimestamp() >= timestamp {
+				timestamp = notification.GetTimestamp() + 1
+			}
+			expired = append(expired, key)
+			deletes = append(deletes, &gnmipb.Notification{
+				Timestamp: timestamp,
+				Prefix: &gnmipb.Path{
+					Origin: prefix.GetOrigin(),
+					Target: prefix.GetTarget(),
+					Elem:   prefix.GetElem(),
+				},
+				Delete: []*gnmipb.Path{update.GetPath()},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.config.Log.Error().Msgf("Target %s: unable to query cache for expired leaves: %v", t.name, err)
+		return 0
+	}
+
+	// Forget leaves that are no longer in the cache.
+	for key := range t.leafTimes {
+		if !present[key] {
+			delete(t.leafTimes, key)
+		}
+	}
+
+	// Deletes are made after the query completes because the query holds
+	// a read lock on the cache tree.
+	for i, notification := range deletes {
+		resolved := c.ResolveDeletes(notification)
+		locker := c.UpdateLocker(t.name)
+		locker.Lock()
+		err := t.targetCache.GnmiUpdate(notification)
+		locker.Unlock()
+		resolved()
+		if err != nil {
+			t.config.Log.Error().Msgf("Target %s: unable to delete expired leaf: %v", t.name, err)
+			continue
+		}
+		// The deletes are exact leaf paths, so the leaves are forgotten
+		// without the prefix scan of trackLeaves.
+		delete(t.leafTimes, expired[i])
+		t.counterExpired.Increment()
+	}
+	return len(deletes)
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+func TestLeafExpiry_ttl(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.DefaultLeafTTL = time.Hour
+	config.LeafTTLs = []configuration.LeafTTL{
+		{Path: "/interfaces", TTL: 10 * time.Minute},
+		{Path: "/interfaces/interface[name=*]/state/counters", TTL: 0},
+	}
+	expiry, err := newLeafExpiry(config)
+	assertion.NoError(err)
+
+	interfaces := []*gnmipb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "state"}}
+	assertion.Equal(10*time.Minute, expiry.ttl(append(interfaces, &gnmipb.PathElem{Name: "oper-status"})))
+	assertion.Equal(time.Duration(0), expiry.ttl(append(interfaces, &gnmipb.PathElem{Name: "counters"}, &gnmipb.PathElem{Name: "in-octets"})))
+	assertion.Equal(time.Hour, expiry.ttl([]*gnmipb.PathElem{{Name: "system"}}))
+
+	expiry, err = newLeafExpiry(configuration.NewDefaultGatewayConfig())
+	assertion.NoError(err)
+	assertion.Nil(expiry)
+}
+
+func TestConnectionState_expireLeaves(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.DefaultLeafTTL = time.Minute
+	expiry, err := newLeafExpiry(config)
+	assertion.NoError(err)
+
+	now := time.Now()
+	c := shardedcache.New(1, nil)
+	state := &ConnectionState{
+		config:      config,
+		name:        "a",
+		queryTarget: "a",
+		targetCache: c.Add("a"),
+	}
+	state.InitializeMetrics()
+
+	var deletes int
+	c.SetClient(func(l *ctree.Leaf) {
+		if notification, ok := l.Value().(*gnmipb.Notification); ok && len(notification.Delete) > 0 {
+			deletes++
+		}
+	})
+
+	// "old" has a current timestamp but was received an hour ago; "skewed"
+	// has an old timestamp but was just received.
+	state.leafTimes = make(map[string]time.Time)
+	for name, leaf := range map[string]struct{ timestamp, received time.Time }{
+		"old":    {now, now.Add(-time.Hour)},
+		"skewed": {now.Add(-2 * time.Hour), now},
+	} {
+		notification := &gnmipb.Notification{
+			Timestamp: leaf.timestamp.UnixNano(),
+			Prefix:    &gnmipb.Path{Target: "a"},
+			Update: []*gnmipb.Update{
+				{
+					Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: name}}},
+					Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: 1}},
+				},
+			},
+		}
+		assertion.NoError(state.targetCache.GnmiUpdate(notification))
+		state.trackLeaves(notification, leaf.received)
+	}
+	// "untracked" has no receive time and is treated as received now.
+	assertion.NoError(state.targetCache.GnmiUpdate(&gnmipb.Notification{
+		Timestamp: now.Add(-time.Hour).UnixNano(),
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Update: []*gnmipb.Update{
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "untracked"}}},
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: 1}},
+			},
+		},
+	}))
+
+	// the metadata leaves aren't expired
+	state.targetCache.Sync()
+
+	assertion.Equal(1, state.expireLeaves(c, expiry, now))
+	assertion.Equal(1, deletes)
+
+	var remaining []string
+	_ = c.Query("a", []string{"*"}, func(path []string, _ *ctree.Leaf, _ interface{}) error {
+		remaining = append(remaining, path[len(path)-1])
+		return nil
+	})
+	assertion.ElementsMatch([]string{"skewed", "untracked", "sync"}, remaining)
+	assertion.Len(state.leafTimes, 2)
+	assertion.NotContains(state.leafTimes, leafKey(&gnmipb.Path{Target: "a"}, &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "old"}}}))
+}
+
+func TestMatchPath_NoWildcards(t *testing.T) {
+	assertion := assert.New(t)
+	path := []*gnmipb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}}
+
+	assertion.True(matchPath(path, []*gnmipb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}}))
+	// UpdateRejections match names and key values literally.
+	assertion.False(matchPath(path, []*gnmipb.PathElem{{Name: "*"}}))
+	assertion.False(matchPath(path, []*gnmipb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "*"}}}))
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// MetaUseTunnel is the target meta field that connects to the target through
+// the registered TunnelDialer instead of dialing the target's addresses. Set
+// it to "true" for devices behind NAT that register with a tunnel server.
+const MetaUseTunnel = "UseTunnel"
+
+// MetaTunnelTarget is the target meta field with the ID the target registers
+// with the tunnel server. The target name is used if it isn't set.
+const MetaTunnelTarget = "TunnelTarget"
+
+// TunnelDialer dials targets through a tunnel, such as a gRPC tunnel server
+// (github.com/openconfig/grpctunnel) that devices register with.
+type TunnelDialer interface {
+	// DialTunnel returns a connection to the target with the tunnel ID. An
+	// error should be returned if the target isn't registered.
+	DialTunnel(ctx context.Context, target string) (net.Conn, error)
+}
+
+var tunnel = struct {
+	sync.Mutex
+	dialer TunnelDialer
+}{}
+
+// RegisterTunnelDialer sets the TunnelDialer used for targets with
+// UseTunnel set.
+func RegisterTunnelDialer(dialer TunnelDialer) {
+	tunnel.Lock()
+	tunnel.dialer = dialer
+	tunnel.Unlock()
+}
+
+// tunnelDialOption returns a dial option that connects through the tunnel
+// if the target has UseTunnel set, or nil otherwise.
+func (t *ConnectionState) tunnelDialOption() (grpc.DialOption, error) {
+	value, exists := t.target.Meta[MetaUseTunnel]
+	if !exists {
+		return nil, nil
+	}
+	useTunnel, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %v", MetaUseTunnel, value, err)
+	}
+	if !useTunnel {
+		return nil, nil
+	}
+
+	tunnel.Lock()
+	dialer := tunnel.dialer
+	tunnel.Unlock()
+	if dialer == nil {
+		return nil, fmt.Errorf("%s is set but no tunnel dialer is registered", MetaUseTunnel)
+	}
+	id := t.name
+	if value, exists := t.target.Meta[MetaTunnelTarget]; exists {
+		id = value
+	}
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return dialer.DialTunnel(ctx, id)
+	}), nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connections
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/openconfig/gnmi/client"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// testTunnel dials a fixed address for registered targets.
+type testTunnel struct {
+	address string
+	targets map[string]bool
+	dialed  []string
+}
+
+func (d *testTunnel) DialTunnel(ctx context.Context, target string) (net.Conn, error) {
+	d.dialed = append(d.dialed, target)
+	if !d.targets[target] {
+		return nil, net.UnknownNetworkError("target not registered")
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", d.address)
+}
+
+func TestConnectionState_tunnelDialOption(t *testing.T) {
+	assertion := assert.New(t)
+
+	server, address := startTestServer(t)
+	defer server.Stop()
+	defer RegisterTunnelDialer(nil)
+
+	state := &ConnectionState{
+		config: configuration.NewDefaultGatewayConfig(),
+		name:   "dev1",
+		target: &targetpb.Target{
+			Addresses: []string{"unreachable.invalid:9339"},
+			Meta:      map[string]string{MetaUseTunnel: "true"},
+		},
+	}
+	_, err := state.newClient()
+	assertion.Error(err, "UseTunnel without a registered dialer")
+
+	tunnel := &testTunnel{address: address, targets: map[string]bool{"dev1": true}}
+	RegisterTunnelDialer(tunnel)
+	c, err := state.newClient()
+	assertion.NoError(err)
+	conn, _, err := c.dialAny(context.Background(), client.Destination{Addrs: state.target.Addresses})
+	assertion.NoError(err)
+	if conn != nil {
+		_ = conn.Close()
+	}
+	assertion.Equal([]string{"dev1"}, tunnel.dialed)
+
+	state.target.Meta[MetaUseTunnel] = "maybe"
+	_, err = state.newClient()
+	assertion.Error(err)
+}
@@ -16,43 +16,99 @@
 package connections
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-zookeeper/zk"
+	"github.com/golang/protobuf/proto"
 	"github.com/openconfig/gnmi/cache"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
 	targetlib "github.com/openconfig/gnmi/target"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/semaphore"
 
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
 	"github.com/openconfig/gnmi-gateway/gateway/locking"
+	"github.com/openconfig/gnmi-gateway/gateway/maintenance"
+	"github.com/openconfig/gnmi-gateway/gateway/rates"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 )
 
 var _ ConnectionManager = new(ZookeeperConnectionManager)
 
 type ZookeeperConnectionManager struct {
-	cache             *cache.Cache
-	config            *configuration.GatewayConfig
-	connLimit         *semaphore.Weighted
-	connections       map[string]*ConnectionState
-	connectionsMutex  sync.Mutex
+	cache            *shardedcache.Cache
+	config           *configuration.GatewayConfig
+	pools            *connectionPools
+	rateRules        *rates.Rules
+	maintenance      *maintenance.Windows
+	middlewares      []Middleware
+	connections      map[string]*ConnectionState
+	connectionsMutex sync.Mutex
+	// fencing tracks the fencing tokens of the targets replicated from other
+	// cluster members.
+	fencing *fencingTokens
+	// disabled are the targets disabled with DisableTarget.
+	disabled map[string]bool
+	// tenants are the Tenant meta fields of the targets. They are kept apart
+	// from connections so that they can be looked up for every notification.
+	tenants      map[string]string
+	tenantsMutex sync.RWMutex
+	// stop is closed when the connection manager is stopped.
+	stop              chan struct{}
+	stopOnce          sync.Once
 	targetsConfigChan chan *TargetConnectionControl
-	zkConn            *zk.Conn
+	// newLock creates the target locks. Locking is disabled if newLock is
+	// nil.
+	newLock locking.LockFactory
 }
 
-// NewZookeeperConnectionManagerDefault creates a new ConnectionManager with an empty *cache.Cache.
-// Locking will be enabled if zkConn is not nil.
+// NewZookeeperConnectionManagerDefault creates a new ConnectionManager with an empty *shardedcache.Cache.
+// Locking will be enabled if zkConn is not nil or a lock factory is set with SetLockFactory.
 func NewZookeeperConnectionManagerDefault(config *configuration.GatewayConfig, zkConn *zk.Conn, zkEvents <-chan zk.Event) (*ZookeeperConnectionManager, error) {
+	pools, err := newConnectionPools(config)
+	if err != nil {
+		return nil, err
+	}
+	rateRules, err := rates.Parse(config.CounterRates, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid counter rates: %v", err)
+	}
+	windows, err := maintenance.New(config.MaintenanceWindows)
+	if err != nil {
+		return nil, err
+	}
+	middlewares, err := newMiddlewares(config)
+	if err != nil {
+		return nil, err
+	}
 	mgr := ZookeeperConnectionManager{
 		config:            config,
-		connLimit:         semaphore.NewWeighted(int64(config.TargetLimit)),
+		pools:             pools,
+		rateRules:         rateRules,
+		maintenance:       windows,
+		middlewares:       middlewares,
 		connections:       make(map[string]*ConnectionState),
+		disabled:          make(map[string]bool),
+		fencing:           newFencingTokens(),
+		tenants:           make(map[string]string),
+		stop:              make(chan struct{}),
 		targetsConfigChan: make(chan *TargetConnectionControl, 10),
-		zkConn:            zkConn,
 	}
-	mgr.cache = cache.New(nil)
+	if zkConn != nil {
+		mgr.newLock = func(id string, member string) locking.DistributedLocker {
+			return locking.NewZookeeperNonBlockingLock(zkConn, id, member, zk.WorldACL(zk.PermAll))
+		}
+	}
+	mgr.cache = shardedcache.New(config.CacheShards, nil)
 	go mgr.eventListener(zkEvents)
 	return &mgr, nil
 }
@@ -64,6 +120,9 @@ func (c *ZookeeperConnectionManager) eventListener(zkEvents <-chan zk.Event) {
 			c.config.Log.Info().Msgf("Zookeeper disconnected. Resetting locked target connections.")
 			c.connectionsMutex.Lock()
 			for _, targetConfig := range c.connections {
+				if targetConfig.useLock && targetConfig.ConnectionLockAcquired {
+					events.Publish(events.TargetLockLost, targetConfig.name, targetConfig.Address(), "Zookeeper disconnected")
+				}
 				if targetConfig.useLock {
 					err := targetConfig.unlock()
 					if err != nil {
@@ -76,7 +135,13 @@ func (c *ZookeeperConnectionManager) eventListener(zkEvents <-chan zk.Event) {
 	}
 }
 
-func (c *ZookeeperConnectionManager) Cache() *cache.Cache {
+// SetLockFactory enables target locking with locks created by factory, e.g.
+// for locking backends other than Zookeeper. It must be called before Start.
+func (c *ZookeeperConnectionManager) SetLockFactory(factory locking.LockFactory) {
+	c.newLock = factory
+}
+
+func (c *ZookeeperConnectionManager) Cache() *shardedcache.Cache {
 	return c.cache
 }
 
@@ -104,15 +169,119 @@ func (c *ZookeeperConnectionManager) Forwardable(target string) bool {
 	return false
 }
 
+// StartCapture starts a debug capture of raw SubscribeResponses for the named target.
+func (c *ZookeeperConnectionManager) StartCapture(target string, file string, format capture.Format) error {
+	c.connectionsMutex.Lock()
+	conn, exists := c.connections[target]
+	c.connectionsMutex.Unlock()
+	if !exists {
+		return fmt.Errorf("no such target: '%s'", target)
+	}
+	return conn.startCapture(file, format)
+}
+
+// StopCapture stops a debug capture for the named target.
+func (c *ZookeeperConnectionManager) StopCapture(target string) error {
+	c.connectionsMutex.Lock()
+	conn, exists := c.connections[target]
+	c.connectionsMutex.Unlock()
+	if !exists {
+		return fmt.Errorf("no such target: '%s'", target)
+	}
+	return conn.stopCapture()
+}
+
+// ReconnectTarget closes the connection to the named target so that it
+// connects again. Disabled targets aren't connected to.
+func (c *ZookeeperConnectionManager) ReconnectTarget(target string) error {
+	c.connectionsMutex.Lock()
+	conn, exists := c.connections[target]
+	c.connectionsMutex.Unlock()
+	if !exists {
+		return fmt.Errorf("no such target: '%s'", target)
+	}
+	if conn.isDisabled() {
+		return fmt.Errorf("target '%s' is disabled", target)
+	}
+	return conn.reconnect()
+}
+
+// TargetErrors returns the most recent errors returned by the named target's
+// connection, oldest first.
+func (c *ZookeeperConnectionManager) TargetErrors(target string) ([]TargetError, error) {
+	c.connectionsMutex.Lock()
+	conn, exists := c.connections[target]
+	c.connectionsMutex.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("no such target: '%s'", target)
+	}
+	return conn.Errors(), nil
+}
+
 func (c *ZookeeperConnectionManager) TargetControlChan() chan<- *TargetConnectionControl {
 	return c.targetsConfigChan
 }
 
+// Targets returns the connection status of each configured target, sorted by
+// name.
+func (c *ZookeeperConnectionManager) Targets() []TargetStatus {
+	c.connectionsMutex.Lock()
+	defer c.connectionsMutex.Unlock()
+	now := time.Now()
+	targets := make([]TargetStatus, 0, len(c.connections))
+	for name, conn := range c.connections {
+		status := TargetStatus{
+			Name:          name,
+			Addresses:     conn.target.GetAddresses(),
+			Address:       conn.Address(),
+			AddressFamily: addressFamily(conn.Address()),
+			Capabilities:  conn.Capabilities(),
+			ClusterMember: conn.clusterMember,
+			Connected:     conn.isConnected(),
+			ConnectedFor:  conn.ConnectedFor().Seconds(),
+			Disabled:      conn.isDisabled(),
+			Maintenance:   c.maintenance.Active(name, now),
+			Pool:          conn.pool,
+			Quarantined:   conn.isQuarantined(),
+			Received:      atomic.LoadUint64(&conn.received),
+			ReceivedBytes: atomic.LoadUint64(&conn.receivedBytes),
+			LastError:     conn.LastError(),
+			State:         conn.State(),
+			Synced:        conn.isSynced(),
+			Tenant:        c.TargetTenant(name),
+		}
+		if last := conn.lastTargetError(); last != nil {
+			status.LastErrorCode = last.Code
+			status.LastErrorTime = &last.Time
+		}
+		targets = append(targets, status)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+	return targets
+}
+
 // ReloadTargets is a blocking loop to listen for target configurations from
 // the TargetControlChan and handles connects/reconnects and disconnects to targets.
 func (c *ZookeeperConnectionManager) ReloadTargets() {
-	for targetControlMsg := range c.targetsConfigChan {
-		c.handleTargetControlMsg(targetControlMsg)
+	for {
+		select {
+		case targetControlMsg := <-c.targetsConfigChan:
+			c.handleTargetControlMsg(targetControlMsg)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// stopped returns true once Stop has been called.
+func (c *ZookeeperConnectionManager) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -128,67 +297,258 @@ func (c *ZookeeperConnectionManager) handleTargetControlMsg(msg *TargetConnectio
 	c.connectionsMutex.Lock()
 	// Disconnect from everything we want to remove
 	for _, toRemove := range msg.Remove {
+		delete(c.disabled, toRemove)
 		conn, exists := c.connections[toRemove]
 		if exists {
-			err := conn.disconnect()
-			if err != nil {
-				c.config.Log.Warn().Msgf("error while disconnecting from target '%s': %v", toRemove, err)
+			if !conn.isDisabled() {
+				_ = conn.stopCapture()
+				err := conn.disconnect()
+				if err != nil {
+					c.config.Log.Warn().Msgf("error while disconnecting from target '%s': %v", toRemove, err)
+				}
 			}
 			delete(c.connections, toRemove)
+			c.removeFromCache(conn)
+			c.maintenance.Forget(toRemove)
+			c.fencing.remove(toRemove)
+			c.tenantsMutex.Lock()
+			delete(c.tenants, toRemove)
+			c.tenantsMutex.Unlock()
 		}
 	}
 
-	// Make new connections or update existing connections
-	if msg.Insert != nil {
+	// Make new connections or update existing connections. Targets aren't
+	// connected once the connection manager has been stopped.
+	if msg.Insert != nil && !c.stopped() {
 		for name, newConfig := range msg.Insert.Target {
+			candidate := &ConnectionState{config: c.config, name: name, target: newConfig}
+			if err := candidate.validateOptions(); err != nil {
+				c.config.Log.Error().Msgf("Target %s: rejected: invalid connection options: %v", name, err)
+				continue
+			}
+			pool, slots, err := c.pools.assign(name, newConfig)
+			if err != nil {
+				c.config.Log.Error().Msgf("Target %s: %v; using the default connection pool", name, err)
+			}
+			if existingConn, exists := c.connections[name]; exists && existingConn.pool != pool {
+				// the connection slot is held for the life of the connection so
+				// moving the target to another pool requires a new connection
+				_ = existingConn.stopCapture()
+				c.config.Log.Info().Msgf("Moving %s to connection pool '%s'.", name, pool)
+				if err := existingConn.disconnect(); err != nil {
+					c.config.Log.Warn().Msgf("error while disconnecting from target '%s': %v", name, err)
+				}
+				delete(c.connections, name)
+			}
+			newRequest := msg.Insert.Request[newConfig.Request]
 			if existingConn, exists := c.connections[name]; exists {
-				if !existingConn.Equal(newConfig) {
-					// target is different; update the current config with the old one and reconnect
-					c.config.Log.Info().Msgf("Updating connection for %s.", name)
-
+				if existingConn.Equal(newConfig) && proto.Equal(existingConn.request, newRequest) {
+					continue
+				}
+				// target is different; update the current config with the old one and reconnect
+				c.config.Log.Info().Msgf("Updating connection for %s.", name)
+				if !existingConn.isDisabled() && !c.targetDisabled(name, newConfig) {
 					existingConn.target = newConfig
-					existingConn.request = msg.Insert.Request[newConfig.Request]
+					existingConn.request = newRequest
 					err := existingConn.reconnect()
 					if err != nil {
 						c.config.Log.Error().Err(err).Msgf("Error reconnecting to target: %s", name)
 					}
+					continue
+				}
+				// the target is or will be disabled; replace the connection
+				if !existingConn.isDisabled() {
+					c.disableConnection(existingConn, c.config.TargetDisabledFlush)
+				} else if !c.targetDisabled(name, newConfig) {
+					// data retained while the target was disabled is stale
+					existingConn.flush()
 				}
+				delete(c.connections, name)
+				c.addConnection(name, newConfig, newRequest, pool, slots, existingConn.targetCache)
 			} else {
 				// no previous targetCache existed
 				c.config.Log.Info().Msgf("Initializing target %s (%v) %v.", name, newConfig.Addresses, newConfig.Meta)
-				_, noLock := newConfig.Meta["NoLock"]
-				_, clusterMember := newConfig.Meta["ClusterMember"]
-				c.connections[name] = &ConnectionState{
-					clusterMember: clusterMember,
-					config:        c.config,
-					connManager:   c,
-					name:          name,
-					targetCache:   c.cache.Add(name),
-					target:        newConfig,
-					request:       msg.Insert.Request[newConfig.Request],
-					seen:          make(map[string]bool),
-					useLock:       c.zkConn != nil && !noLock,
-				}
-				c.connections[name].InitializeMetrics()
-				if c.connections[name].useLock {
-					lockPath := MakeTargetLockPath(c.config.ZookeeperPrefix, name)
-					clusterMemberAddress := c.config.ServerAddress + ":" + strconv.Itoa(c.config.ServerPort)
-					c.connections[name].lock = locking.NewZookeeperNonBlockingLock(c.zkConn, lockPath, clusterMemberAddress, zk.WorldACL(zk.PermAll))
-					go c.connections[name].connectWithLock(c.connLimit)
-				} else {
-					go c.connections[name].connect(c.connLimit)
-				}
+				c.addConnection(name, newConfig, newRequest, pool, slots, c.cache.Add(name))
 			}
 		}
 	}
 	c.connectionsMutex.Unlock()
 }
 
+// addConnection adds the target's connection and starts connecting to the
+// target unless it's disabled. connectionsMutex must be held.
+func (c *ZookeeperConnectionManager) addConnection(name string, config *targetpb.Target, request *gnmipb.SubscribeRequest, pool string, slots *semaphore.Weighted, targetCache *cache.Target) {
+	_, noLock := config.Meta["NoLock"]
+	_, clusterMember := config.Meta["ClusterMember"]
+	_, federated := config.Meta[MetaGateway]
+	conn := &ConnectionState{
+		clusterMember: clusterMember,
+		config:        c.config,
+		connManager:   c,
+		disabled:      c.targetDisabled(name, config),
+		federated:     federated,
+		fencing:       c.fencing,
+		name:          name,
+		pool:          pool,
+		middlewares:   c.middlewares,
+		rateRules:     c.rateRules,
+		targetCache:   targetCache,
+		target:        config,
+		request:       request,
+		seen:          make(map[string]bool),
+		useLock:       c.newLock != nil && !noLock,
+	}
+	c.connections[name] = conn
+	conn.InitializeMetrics()
+	c.tenantsMutex.Lock()
+	c.tenants[name] = config.GetMeta()["Tenant"]
+	c.tenantsMutex.Unlock()
+	if conn.disabled {
+		c.config.Log.Info().Msgf("Target %s: disabled; not connecting", name)
+		conn.setStopped()
+		return
+	}
+	if conn.useLock {
+		lockPath := MakeTargetLockPath(c.config.ZookeeperPrefix, name)
+		clusterMemberAddress := c.config.ServerAddress + ":" + strconv.Itoa(c.config.ServerPort)
+		conn.lock = c.newLock(lockPath, clusterMemberAddress)
+		if notifier, ok := conn.lock.(locking.LossNotifier); ok {
+			notifier.OnLost(func() {
+				events.Publish(events.TargetLockLost, conn.name, conn.Address(), "lock lost")
+				if err := conn.unlock(); err != nil {
+					c.config.Log.Error().Msgf("error while unlocking target: %v", err)
+				}
+			})
+		}
+		go conn.connectWithLock(slots)
+	} else {
+		go conn.connect(slots)
+	}
+}
+
+// TargetTenant implements ConnectionManager.
+func (c *ZookeeperConnectionManager) TargetTenant(target string) string {
+	c.tenantsMutex.RLock()
+	defer c.tenantsMutex.RUnlock()
+	return c.tenants[target]
+}
+
+// targetDisabled returns true if the target is disabled with DisableTarget or
+// with the Disabled meta field of its configuration.
+func (c *ZookeeperConnectionManager) targetDisabled(name string, config *targetpb.Target) bool {
+	_, disabled := config.GetMeta()["Disabled"]
+	return disabled || c.disabled[name]
+}
+
+// DisableTarget disconnects from the named target and doesn't connect to it
+// again until EnableTarget is called. The target's cached data is kept
+// unless flush is true.
+func (c *ZookeeperConnectionManager) DisableTarget(target string, flush bool) error {
+	c.connectionsMutex.Lock()
+	defer c.connectionsMutex.Unlock()
+	conn, exists := c.connections[target]
+	if !exists {
+		return fmt.Errorf("no such target: '%s'", target)
+	}
+	c.disabled[target] = true
+	if conn.isDisabled() {
+		if flush {
+			conn.flush()
+		}
+		return nil
+	}
+	c.disableConnection(conn, flush)
+	return nil
+}
+
+// disableConnection disconnects from a target that is being disabled.
+func (c *ZookeeperConnectionManager) disableConnection(conn *ConnectionState, flush bool) {
+	c.config.Log.Info().Msgf("Target %s: disabling", conn.name)
+	conn.setDisabled(true)
+	_ = conn.stopCapture()
+	if err := conn.disconnect(); err != nil {
+		c.config.Log.Warn().Msgf("error while disconnecting from target '%s': %v", conn.name, err)
+	}
+	if flush {
+		conn.flush()
+	}
+}
+
+// EnableTarget connects to a target disabled with DisableTarget. Targets
+// disabled with the Disabled meta field can't be enabled.
+func (c *ZookeeperConnectionManager) EnableTarget(target string) error {
+	c.connectionsMutex.Lock()
+	defer c.connectionsMutex.Unlock()
+	conn, exists := c.connections[target]
+	if !exists {
+		return fmt.Errorf("no such target: '%s'", target)
+	}
+	delete(c.disabled, target)
+	if !conn.isDisabled() {
+		return nil
+	}
+	if c.targetDisabled(target, conn.target) {
+		return fmt.Errorf("target '%s' is disabled in its configuration", target)
+	}
+	c.config.Log.Info().Msgf("Target %s: enabling", target)
+	pool, slots, err := c.pools.assign(target, conn.target)
+	if err != nil {
+		c.config.Log.Error().Msgf("Target %s: %v; using the default connection pool", target, err)
+	}
+	// data retained while the target was disabled is stale
+	conn.flush()
+	delete(c.connections, target)
+	c.addConnection(target, conn.target, conn.request, pool, slots, conn.targetCache)
+	return nil
+}
+
+// removeFromCache removes the cached data of a removed target. Cache clients,
+// such as the retention buffer and exporters, receive a delete notification
+// for the whole target. Targets received from cluster members are left in
+// the cache because another member may take over the connection.
+func (c *ZookeeperConnectionManager) removeFromCache(conn *ConnectionState) {
+	if conn.clusterMember || conn.queryTarget == "*" {
+		return
+	}
+	if c.cache.HasTarget(conn.name) {
+		c.cache.Remove(conn.name)
+	}
+}
+
+// Use appends middlewares to the pipeline that notifications received from
+// targets pass through, after the middlewares enabled in the configuration.
+// Use must be called before Start.
+func (c *ZookeeperConnectionManager) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
 func (c *ZookeeperConnectionManager) Start() error {
+	expiry, err := newLeafExpiry(c.config)
+	if err != nil {
+		return err
+	}
+	if expiry != nil {
+		go c.runLeafExpiry(expiry)
+	}
+	if c.config.TargetMetadataInterval > 0 {
+		go c.runMetadata(c.config.TargetMetadataInterval)
+	}
 	go c.ReloadTargets()
 	return nil
 }
 
+// Stop disconnects from all targets and releases their locks.
+func (c *ZookeeperConnectionManager) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.connectionsMutex.Lock()
+	names := make([]string, 0, len(c.connections))
+	for name := range c.connections {
+		names = append(names, name)
+	}
+	c.connectionsMutex.Unlock()
+	c.handleTargetControlMsg(&TargetConnectionControl{Remove: names})
+}
+
 func MakeTargetLockPath(prefix string, target string) string {
 	return strings.TrimRight(prefix, "/") + "/target/" + target
 }
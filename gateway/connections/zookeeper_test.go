@@ -19,6 +19,9 @@ import (
 	"testing"
 
 	"github.com/openconfig/gnmi/client"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
@@ -73,3 +76,65 @@ func TestZookeeperConnectionManager_handleTargetControlMsg_Delete(t *testing.T)
 	assertion.Len(mgr.connections, 0)
 	assertion.Nil(mgr.connections["three"])
 }
+
+func TestZookeeperConnectionManager_handleTargetControlMsg_InvalidOptions(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{Log: zerolog.Nop()}
+	mgr, err := NewZookeeperConnectionManagerDefault(config, nil, nil)
+	assertion.NoError(err)
+
+	mgr.handleTargetControlMsg(&TargetConnectionControl{
+		Insert: &targetpb.Configuration{
+			Request: map[string]*gnmipb.SubscribeRequest{"default": {}},
+			Target: map[string]*targetpb.Target{
+				"bad": {
+					Addresses: []string{"127.0.0.1:9339"},
+					Request:   "default",
+					Meta:      map[string]string{MetaCompression: "lz4"},
+				},
+			},
+		},
+	})
+	assertion.Empty(mgr.connections)
+}
+
+func TestZookeeperConnectionManager_DisableTarget(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := &configuration.GatewayConfig{Log: zerolog.Nop()}
+	mgr, err := NewZookeeperConnectionManagerDefault(config, nil, nil)
+	assertion.NoError(err)
+
+	mgr.handleTargetControlMsg(&TargetConnectionControl{
+		Insert: &targetpb.Configuration{
+			Request: map[string]*gnmipb.SubscribeRequest{"default": {}},
+			Target: map[string]*targetpb.Target{
+				"a": {
+					Addresses: []string{"127.0.0.1:9339"},
+					Request:   "default",
+					Meta:      map[string]string{"Disabled": ""},
+				},
+			},
+		},
+	})
+	conn := mgr.connections["a"]
+	if !assertion.NotNil(conn) {
+		return
+	}
+	assertion.True(conn.isStopped())
+	assertion.Equal(stateMaintenance, conn.State())
+	assertion.True(mgr.Targets()[0].Disabled)
+
+	assertion.Error(mgr.EnableTarget("a"), "disabled in the configuration")
+	assertion.Error(mgr.DisableTarget("b", false), "unknown target")
+	assertion.Error(mgr.ReconnectTarget("a"), "disabled target")
+	assertion.Error(mgr.ReconnectTarget("b"), "unknown target")
+	assertion.NoError(mgr.DisableTarget("a", true))
+	assertion.True(mgr.disabled["a"])
+
+	// Removing the target forgets that it was disabled.
+	mgr.handleTargetControlMsg(&TargetConnectionControl{Remove: []string{"a"}})
+	assertion.Empty(mgr.connections)
+	assertion.Empty(mgr.disabled)
+}
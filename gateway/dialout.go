@@ -0,0 +1,50 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/openconfig/gnmi-gateway/gateway/adapters/dialout"
+)
+
+// StartDialOutServer starts the server that accepts dial-out streams from
+// targets that use the dialout protocol. StartDialOutServer blocks until the
+// gateway is stopped.
+func (g *Gateway) StartDialOutServer() error {
+	l, err := g.newServerListener(g.config.DialOutListener)
+	if err != nil {
+		return fmt.Errorf("invalid dial-out listener: %v", err)
+	}
+	opts := g.serverOptions()
+	if l.creds != nil {
+		opts = append(opts, grpc.Creds(l.creds))
+	}
+	srv := grpc.NewServer(opts...)
+	g.gnmiServerLock.Lock()
+	g.grpcServers = append(g.grpcServers, srv)
+	g.gnmiServerLock.Unlock()
+	dialout.RegisterServer(srv)
+
+	lis, err := l.listen()
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", l.address, err)
+	}
+	g.config.Log.Info().Msgf("Dial-out server listening on %s (%s).", l.address, l.description)
+	return srv.Serve(lis)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/openconfig/gnmi/ctree"
+
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/locking"
+)
+
+const defaultLeaderElectionInterval = 5 * time.Second
+
+// newExporterElection creates the leader election for a LeaderExporter. The
+// election uses a Zookeeper or Redis lock when clustering is enabled,
+// otherwise a local lock that this gateway always acquires.
+func (g *Gateway) newExporterElection(exporter exporters.LeaderExporter) *locking.Election {
+	id := strings.TrimRight(g.config.ZookeeperPrefix, "/") + "/election/exporter/" + exporter.Name()
+	member := g.config.ServerAddress + ":" + strconv.Itoa(g.config.ServerPort)
+	var lock locking.DistributedLocker
+	if g.zkConn != nil {
+		lock = locking.NewZookeeperNonBlockingLock(g.zkConn, id, member, zk.WorldACL(zk.PermAll))
+	} else if g.newLock != nil {
+		lock = g.newLock(id, member)
+	} else {
+		lock = locking.NewNonBlockingLock(id, member)
+	}
+	interval := g.config.Exporters.LeaderElectionInterval
+	if interval <= 0 {
+		interval = defaultLeaderElectionInterval
+	}
+	return locking.NewElection(lock, interval, exporter.SetLeader)
+}
+
+// leaderOnly drops the notifications sent to export while this gateway isn't
+// the leader of the election.
+func leaderOnly(election *locking.Election, export func(leaf *ctree.Leaf)) func(leaf *ctree.Leaf) {
+	return func(leaf *ctree.Leaf) {
+		if election.Leader() {
+			export(leaf)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zstd registers a zstd compressor with gRPC. Import this package for
+// its side effects to allow gRPC clients and servers to use "zstd" compression:
+//		import _ "github.com/openconfig/gnmi-gateway/gateway/encoding/zstd"
+//
+// Note that zstd is not one of the standard gRPC encodings so both ends of the
+// connection need to support it.
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name registered for the zstd compressor.
+const Name = "zstd"
+
+// encoder and decoder are shared by all messages. zstd encoders and decoders
+// are expensive to create and EncodeAll and DecodeAll are safe for concurrent
+// use, so messages are buffered and compressed or decompressed in one call
+// rather than creating a streaming encoder or decoder for each message.
+var (
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+)
+
+func init() {
+	var err error
+	encoder, err = zstd.NewWriter(nil)
+	if err != nil {
+		panic(err)
+	}
+	decoder, err = zstd.NewReader(nil)
+	if err != nil {
+		panic(err)
+	}
+	encoding.RegisterCompressor(&compressor{})
+}
+
+type compressor struct {
+	// writers is a pool of *writer so that the message buffers are reused.
+	writers sync.Pool
+}
+
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	zw, ok := c.writers.Get().(*writer)
+	if !ok {
+		zw = &writer{pool: &c.writers}
+	}
+	zw.w = w
+	return zw, nil
+}
+
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	message, err := decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(message), nil
+}
+
+func (c *compressor) Name() string {
+	return Name
+}
+
+// writer buffers a message and compresses it when it's closed. The writer is
+// returned to the pool by Close and must not be used afterwards.
+type writer struct {
+	w          io.Writer
+	message    []byte
+	compressed []byte
+	pool       *sync.Pool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.message = append(w.message, p...)
+	return len(p), nil
+}
+
+func (w *writer) Close() error {
+	w.compressed = encoder.EncodeAll(w.message, w.compressed[:0])
+	_, err := w.w.Write(w.compressed)
+	w.w = nil
+	w.message = w.message[:0]
+	w.pool.Put(w)
+	return err
+}
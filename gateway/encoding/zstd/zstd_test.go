@@ -0,0 +1,67 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompressor(t *testing.T) {
+	assertion := assert.New(t)
+	c := encoding.GetCompressor(Name)
+	assertion.NotNil(c)
+
+	message := bytes.Repeat([]byte("interfaces/interface/state/counters"), 100)
+	var compressed bytes.Buffer
+	w, err := c.Compress(&compressed)
+	assertion.NoError(err)
+	_, err = w.Write(message)
+	assertion.NoError(err)
+	assertion.NoError(w.Close())
+	assertion.Less(compressed.Len(), len(message))
+
+	r, err := c.Decompress(&compressed)
+	assertion.NoError(err)
+	decompressed, err := ioutil.ReadAll(r)
+	assertion.NoError(err)
+	assertion.Equal(message, decompressed)
+}
+
+func TestCompressor_Reuse(t *testing.T) {
+	assertion := assert.New(t)
+	c := encoding.GetCompressor(Name)
+
+	// Writers are pooled; each message must be compressed independently.
+	for _, message := range [][]byte{bytes.Repeat([]byte("a"), 1000), []byte("b")} {
+		var compressed bytes.Buffer
+		w, err := c.Compress(&compressed)
+		assertion.NoError(err)
+		_, err = w.Write(message)
+		assertion.NoError(err)
+		assertion.NoError(w.Close())
+
+		r, err := c.Decompress(&compressed)
+		assertion.NoError(err)
+		decompressed, err := ioutil.ReadAll(r)
+		assertion.NoError(err)
+		assertion.Equal(message, decompressed)
+	}
+}
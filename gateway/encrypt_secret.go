@@ -0,0 +1,88 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openconfig/gnmi-gateway/gateway/secrets"
+)
+
+// RunEncryptSecret parses the encrypt-secret command-line arguments, encrypts
+// the secret read from r, and writes the encrypted value for a target
+// configuration to w. The secret is the first line of r so that it doesn't
+// end up in the shell history.
+//
+// With -KeyFile the secret is encrypted with the local key in the file; add
+// -GenerateKey to create the key file first. With -KMSKeyID the secret is
+// encrypted with AWS KMS.
+func RunEncryptSecret(args []string, r io.Reader, w io.Writer) error {
+	flags := flag.NewFlagSet("encrypt-secret", flag.ContinueOnError)
+	keyFile := flags.String("KeyFile", "", "File containing the local key used to encrypt the secret")
+	generateKey := flags.Bool("GenerateKey", false, "Generate a new local key in KeyFile before encrypting")
+	kmsKeyID := flags.String("KMSKeyID", "", "ID, ARN, or alias of the AWS KMS key used to encrypt the secret")
+	kmsRegion := flags.String("KMSRegion", "", "AWS region of the KMS key (default is $AWS_REGION)")
+	kmsEndpoint := flags.String("KMSEndpoint", "", "Overrides the AWS KMS API endpoint for the region")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var provider secrets.KeyProvider
+	switch {
+	case *keyFile != "" && *kmsKeyID != "":
+		return errors.New("only one of -KeyFile and -KMSKeyID can be set")
+	case *keyFile != "":
+		if *generateKey {
+			if err := secrets.GenerateLocalKey(*keyFile); err != nil {
+				return fmt.Errorf("unable to generate key file: %v", err)
+			}
+		}
+		local, err := secrets.NewLocalKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		provider = local
+	case *kmsKeyID != "":
+		kms, err := secrets.NewAWSKMS(*kmsKeyID, *kmsRegion, *kmsEndpoint)
+		if err != nil {
+			return err
+		}
+		provider = kms
+	default:
+		return errors.New("one of -KeyFile or -KMSKeyID must be set")
+	}
+
+	secret, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("unable to read secret: %v", err)
+	}
+	secret = strings.TrimRight(secret, "\r\n")
+	if secret == "" {
+		return errors.New("no secret was read from standard input")
+	}
+
+	encrypted, err := secrets.Encrypt(provider, secret)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, encrypted)
+	return err
+}
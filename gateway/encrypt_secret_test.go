@@ -0,0 +1,59 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/secrets"
+)
+
+func TestRunEncryptSecret(t *testing.T) {
+	assertion := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "encrypt-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "secret.key")
+
+	out := new(bytes.Buffer)
+	err = RunEncryptSecret([]string{"-KeyFile", keyFile, "-GenerateKey"}, strings.NewReader("hunter2\n"), out)
+	if !assertion.NoError(err) {
+		return
+	}
+	encrypted := strings.TrimSpace(out.String())
+	assertion.True(secrets.IsEncrypted(encrypted))
+
+	key, err := secrets.NewLocalKey(keyFile)
+	if !assertion.NoError(err) {
+		return
+	}
+	decrypted, err := secrets.DecryptWith([]secrets.KeyProvider{key}, encrypted)
+	assertion.NoError(err)
+	assertion.Equal("hunter2", decrypted)
+
+	assertion.Error(RunEncryptSecret([]string{"-KeyFile", keyFile}, strings.NewReader(""), new(bytes.Buffer)))
+	assertion.Error(RunEncryptSecret(nil, strings.NewReader("hunter2\n"), new(bytes.Buffer)))
+}
@@ -0,0 +1,286 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events publishes the target connection state changes observed by
+// the gateway (connected, synced, disconnected, lock lost, quarantined) to the
+// configured event sinks, so that other tooling can react to them.
+//
+// Events are dropped until Enable is called, except for the subscribers added
+// with Subscribe. Each sink has its own buffer and goroutine so a slow sink
+// doesn't delay the others, and events are dropped for a sink or subscriber
+// whose buffer is full instead of blocking the target connections.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+// Type is the type of an Event.
+type Type string
+
+const (
+	// TargetConnected is published when the first message is received from a
+	// target after it was disconnected.
+	TargetConnected Type = "target.connected"
+	// TargetSynced is published when a target sends a sync response.
+	TargetSynced Type = "target.synced"
+	// TargetDisconnected is published when the connection to a target is lost
+	// or closed.
+	TargetDisconnected Type = "target.disconnected"
+	// TargetLockLost is published when the cluster lock of a connected target
+	// is lost, e.g. because the Zookeeper session expired.
+	TargetLockLost Type = "target.lock_lost"
+	// TargetQuarantined is published when a target is quarantined after
+	// repeated authentication failures.
+	TargetQuarantined Type = "target.quarantined"
+	// TargetReleased is published when a target is released from quarantine.
+	TargetReleased Type = "target.released"
+)
+
+// Types are all of the event types.
+var Types = []Type{
+	TargetConnected,
+	TargetSynced,
+	TargetDisconnected,
+	TargetLockLost,
+	TargetQuarantined,
+	TargetReleased,
+}
+
+// Event is a target connection state change.
+type Event struct {
+	Type   Type      `json:"type"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+	// Member is the address of the cluster member that published the event.
+	Member string `json:"member,omitempty"`
+	// Address is the target address in use, if known.
+	Address string `json:"address,omitempty"`
+	// Message describes the cause of the event, e.g. the last error.
+	Message string `json:"message,omitempty"`
+}
+
+// Sink receives published events.
+type Sink interface {
+	// Send delivers an event. Send is only called by one goroutine at a time.
+	Send(e Event) error
+	// Close flushes any buffered events and releases the sink's resources.
+	Close() error
+}
+
+// SinkFactory creates a Sink from the gateway configuration.
+type SinkFactory func(config *configuration.GatewayConfig) (Sink, error)
+
+var registry = struct {
+	sync.Mutex
+	sinks map[string]SinkFactory
+}{sinks: make(map[string]SinkFactory)}
+
+// Register adds a sink to the sinks that can be enabled with EventSinks.
+func Register(name string, factory SinkFactory) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.sinks[name] = factory
+}
+
+// Registered returns the names of the registered sinks, sorted.
+func Registered() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	names := make([]string, 0, len(registry.sinks))
+	for name := range registry.sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bus delivers published events to its sinks.
+type Bus struct {
+	member  string
+	workers []*sinkWorker
+	wg      sync.WaitGroup
+}
+
+type sinkWorker struct {
+	name string
+	sink Sink
+	c    chan Event
+}
+
+var global = struct {
+	sync.RWMutex
+	bus *Bus
+}{}
+
+// Enable starts delivering published events to the EventSinks in the
+// configuration.
+func Enable(config *configuration.GatewayConfig) error {
+	if len(config.EventSinks) == 0 {
+		return errors.New("EventSinks is required to enable events")
+	}
+	bus, err := NewBus(config)
+	if err != nil {
+		return err
+	}
+	setBus(bus)
+	config.Log.Info().Msgf("Publishing target events to %v.", config.EventSinks)
+	return nil
+}
+
+// Disable stops publishing events and closes the sinks after the buffered
+// events are delivered.
+func Disable() {
+	setBus(nil)
+}
+
+func setBus(bus *Bus) {
+	global.Lock()
+	previous := global.bus
+	global.bus = bus
+	global.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+// NewBus creates a Bus that delivers events to the EventSinks in the
+// configuration.
+func NewBus(config *configuration.GatewayConfig) (*Bus, error) {
+	bufferSize := config.EventBufferSize
+	if bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	bus := &Bus{}
+	if config.ServerAddress != "" {
+		bus.member = config.ServerAddress + ":" + strconv.Itoa(config.ServerPort)
+	}
+	for _, name := range config.EventSinks {
+		registry.Lock()
+		factory, exists := registry.sinks[name]
+		registry.Unlock()
+		if !exists {
+			bus.Close()
+			return nil, fmt.Errorf("unknown event sink '%s' (registered: %v)", name, Registered())
+		}
+		sink, err := factory(config)
+		if err != nil {
+			bus.Close()
+			return nil, fmt.Errorf("unable to create event sink '%s': %v", name, err)
+		}
+		worker := &sinkWorker{name: name, sink: sink, c: make(chan Event, bufferSize)}
+		bus.workers = append(bus.workers, worker)
+		bus.wg.Add(1)
+		go bus.run(config, worker)
+	}
+	return bus, nil
+}
+
+func (b *Bus) run(config *configuration.GatewayConfig, w *sinkWorker) {
+	defer b.wg.Done()
+	counterErrors := stats.Registry.Counter("gnmigateway.events.errors", map[string]string{"gnmigateway.events.sink": w.name})
+	for e := range w.c {
+		if err := w.sink.Send(e); err != nil {
+			counterErrors.Increment()
+			config.Log.Error().Msgf("Unable to send %s event for target %s to event sink '%s': %v", e.Type, e.Target, w.name, err)
+		}
+	}
+	if err := w.sink.Close(); err != nil {
+		config.Log.Error().Msgf("Unable to close event sink '%s': %v", w.name, err)
+	}
+}
+
+// Publish delivers an event to the sinks without blocking. The event is
+// dropped for sinks whose buffer is full.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Member == "" {
+		e.Member = b.member
+	}
+	stats.Registry.Counter("gnmigateway.events.published", map[string]string{"gnmigateway.events.type": string(e.Type)}).Increment()
+	for _, w := range b.workers {
+		select {
+		case w.c <- e:
+		default:
+			stats.Registry.Counter("gnmigateway.events.dropped", map[string]string{"gnmigateway.events.sink": w.name}).Increment()
+		}
+	}
+}
+
+// Close stops the Bus and waits for the buffered events to be delivered.
+// Publish must not be called after Close.
+func (b *Bus) Close() {
+	for _, w := range b.workers {
+		close(w.c)
+	}
+	b.wg.Wait()
+}
+
+// Publish publishes an event for a target to the enabled sinks and the
+// subscribers. Events are only delivered to sinks if events are enabled.
+func Publish(eventType Type, target string, address string, message string) {
+	e := Event{Type: eventType, Target: target, Time: time.Now(), Address: address, Message: message}
+	global.RLock()
+	if global.bus != nil {
+		e.Member = global.bus.member
+		global.bus.Publish(e)
+	}
+	global.RUnlock()
+
+	subscribers.Lock()
+	defer subscribers.Unlock()
+	for c := range subscribers.chans {
+		select {
+		case c <- e:
+		default:
+			stats.Registry.Counter("gnmigateway.events.dropped", map[string]string{"gnmigateway.events.sink": "subscriber"}).Increment()
+		}
+	}
+}
+
+var subscribers = struct {
+	sync.Mutex
+	chans map[chan Event]struct{}
+}{chans: make(map[chan Event]struct{})}
+
+// Subscribe returns a channel that receives the events published from now
+// on, whether or not events are enabled, e.g. to stream them from the admin
+// API. Events are dropped for the subscriber while buffer events are waiting
+// to be received. The returned function unsubscribes and closes the channel.
+func Subscribe(buffer int) (<-chan Event, func()) {
+	c := make(chan Event, buffer)
+	subscribers.Lock()
+	subscribers.chans[c] = struct{}{}
+	subscribers.Unlock()
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			subscribers.Lock()
+			delete(subscribers.chans, c)
+			subscribers.Unlock()
+			close(c)
+		})
+	}
+}
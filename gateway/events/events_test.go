@@ -0,0 +1,129 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+type testSink struct {
+	mutex  sync.Mutex
+	events []Event
+	closed bool
+	err    error
+}
+
+func (s *testSink) Send(e Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, e)
+	return s.err
+}
+
+func (s *testSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return nil
+}
+
+func testConfig(sinks ...string) *configuration.GatewayConfig {
+	config := configuration.NewDefaultGatewayConfig()
+	config.Log = zerolog.Nop()
+	config.EventSinks = sinks
+	config.ServerAddress = "192.0.2.1"
+	config.ServerPort = 9339
+	return config
+}
+
+func TestPublish(t *testing.T) {
+	sink := &testSink{}
+	Register("test", func(*configuration.GatewayConfig) (Sink, error) {
+		return sink, nil
+	})
+
+	// Events are dropped until events are enabled.
+	Publish(TargetConnected, "router1", "", "")
+
+	assert.NoError(t, Enable(testConfig("test")))
+	Publish(TargetConnected, "router1", "192.0.2.10:9339", "")
+	Publish(TargetDisconnected, "router1", "192.0.2.10:9339", "EOF")
+	Disable()
+	Publish(TargetSynced, "router1", "", "")
+
+	assert.True(t, sink.closed)
+	if assert.Len(t, sink.events, 2) {
+		assert.Equal(t, TargetConnected, sink.events[0].Type)
+		assert.Equal(t, "router1", sink.events[0].Target)
+		assert.Equal(t, "192.0.2.10:9339", sink.events[0].Address)
+		assert.Equal(t, "192.0.2.1:9339", sink.events[0].Member)
+		assert.False(t, sink.events[0].Time.IsZero())
+		assert.Equal(t, TargetDisconnected, sink.events[1].Type)
+		assert.Equal(t, "EOF", sink.events[1].Message)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c, unsubscribe := Subscribe(1)
+
+	// Subscribers receive events whether or not events are enabled.
+	Publish(TargetConnected, "router1", "192.0.2.10:9339", "")
+	// The buffer is full.
+	Publish(TargetSynced, "router1", "", "")
+	e := <-c
+	assert.Equal(t, TargetConnected, e.Type)
+	assert.Equal(t, "router1", e.Target)
+	assert.False(t, e.Time.IsZero())
+
+	unsubscribe()
+	unsubscribe()
+	Publish(TargetDisconnected, "router1", "", "EOF")
+	_, open := <-c
+	assert.False(t, open)
+}
+
+func TestNewBus_Errors(t *testing.T) {
+	Register("broken", func(*configuration.GatewayConfig) (Sink, error) {
+		return nil, errors.New("broken")
+	})
+
+	_, err := NewBus(testConfig("unknown"))
+	assert.Error(t, err)
+	_, err = NewBus(testConfig("broken"))
+	assert.Error(t, err)
+	assert.Error(t, Enable(testConfig()))
+}
+
+func TestBus_SinkError(t *testing.T) {
+	sink := &testSink{err: errors.New("unavailable")}
+	Register("failing", func(*configuration.GatewayConfig) (Sink, error) {
+		return sink, nil
+	})
+	bus, err := NewBus(testConfig("failing"))
+	assert.NoError(t, err)
+	bus.Publish(Event{Type: TargetSynced, Target: "router1"})
+	bus.Publish(Event{Type: TargetSynced, Target: "router2"})
+	bus.Close()
+	// A failed event doesn't stop the delivery of the next ones.
+	assert.Len(t, sink.events, 2)
+}
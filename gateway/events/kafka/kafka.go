@@ -0,0 +1,75 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka provides an event sink that writes target events to a Kafka
+// topic.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+)
+
+const Name = "kafka"
+
+func init() {
+	events.Register(Name, NewSink)
+}
+
+// Sink writes each event as a JSON message keyed by the target name to
+// EventKafkaTopic, so that the events of a target stay in order.
+type Sink struct {
+	writer *kafka.Writer
+}
+
+// NewSink creates a Kafka Sink.
+func NewSink(config *configuration.GatewayConfig) (events.Sink, error) {
+	if config.EventKafkaTopic == "" {
+		return nil, errors.New("EventKafkaTopic is required for the kafka event sink")
+	}
+	if len(config.EventKafkaBrokers) == 0 {
+		return nil, errors.New("EventKafkaBrokers is required for the kafka event sink")
+	}
+	return &Sink{
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(config.EventKafkaBrokers...),
+			Topic:    config.EventKafkaTopic,
+			Balancer: &kafka.Hash{},
+		},
+	}, nil
+}
+
+// Send implements events.Sink.
+func (s *Sink) Send(e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(e.Target),
+		Value: data,
+	})
+}
+
+// Close implements events.Sink.
+func (s *Sink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// LogSinkName is the name of the sink that writes events to the gateway log.
+const LogSinkName = "log"
+
+func init() {
+	Register(LogSinkName, newLogSink)
+}
+
+type logSink struct {
+	config *configuration.GatewayConfig
+}
+
+func newLogSink(config *configuration.GatewayConfig) (Sink, error) {
+	return &logSink{config: config}, nil
+}
+
+// Send implements Sink.
+func (s *logSink) Send(e Event) error {
+	s.config.Log.Info().
+		Str("event", string(e.Type)).
+		Str("target", e.Target).
+		Str("member", e.Member).
+		Str("address", e.Address).
+		Time("time", e.Time).
+		Msg(e.Message)
+	return nil
+}
+
+// Close implements Sink.
+func (s *logSink) Close() error {
+	return nil
+}
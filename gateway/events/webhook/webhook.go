@@ -0,0 +1,183 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook provides an event sink that posts target events to an HTTP
+// endpoint.
+//
+// The request body is the event encoded as a JSON object, or the output of
+// the EventWebhookTemplate, a Go text/template with the fields:
+//		.Type    the event type, e.g. target.disconnected
+//		.Target  the target name
+//		.Time    the time of the event (time.Time)
+//		.Member  the cluster member that published the event
+//		.Address the target address in use
+//		.Message the cause of the event, e.g. the last error
+// and the json function that encodes a value as JSON, e.g.
+//		{"text": {{printf "%s is %s" .Target .Type | json}}}
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+)
+
+const Name = "webhook"
+
+func init() {
+	events.Register(Name, NewSink)
+}
+
+// Sink posts events to EventWebhookURL.
+type Sink struct {
+	url        string
+	authHeader string
+	retries    int
+	backoff    time.Duration
+	body       *template.Template
+	// types are the event types to post. All types are posted if it's nil.
+	types  map[events.Type]bool
+	client *http.Client
+}
+
+// NewSink creates a webhook Sink.
+func NewSink(config *configuration.GatewayConfig) (events.Sink, error) {
+	if config.EventWebhookURL == "" {
+		return nil, errors.New("EventWebhookURL is required for the webhook event sink")
+	}
+	if config.EventWebhookRetries < 0 {
+		return nil, errors.New("EventWebhookRetries can't be negative")
+	}
+	s := &Sink{
+		url:        config.EventWebhookURL,
+		authHeader: config.EventWebhookAuthHeader,
+		retries:    config.EventWebhookRetries,
+		backoff:    config.EventWebhookRetryBackoff,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if config.EventWebhookTemplate != "" {
+		var err error
+		s.body, err = parseBodyTemplate(config.EventWebhookTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(config.EventWebhookTypes) > 0 {
+		s.types = make(map[events.Type]bool)
+		for _, name := range config.EventWebhookTypes {
+			if !validType(events.Type(name)) {
+				return nil, fmt.Errorf("unknown event type '%s' in EventWebhookTypes (valid types: %v)", name, events.Types)
+			}
+			s.types[events.Type(name)] = true
+		}
+	}
+	return s, nil
+}
+
+func parseBodyTemplate(text string) (*template.Template, error) {
+	body, err := template.New("body").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %v", err)
+	}
+	return body, nil
+}
+
+func validType(t events.Type) bool {
+	for _, valid := range events.Types {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Send implements events.Sink. Events are retried with an exponential
+// backoff after connection errors and 429 or 5xx responses.
+func (s *Sink) Send(e events.Event) error {
+	if s.types != nil && !s.types[e.Type] {
+		return nil
+	}
+	body, err := s.render(e)
+	if err != nil {
+		return err
+	}
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil || !retry || attempt >= s.retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// render returns the request body for an event.
+func (s *Sink) render(e events.Event) ([]byte, error) {
+	if s.body == nil {
+		return json.Marshal(e)
+	}
+	var buf bytes.Buffer
+	if err := s.body.Execute(&buf, e); err != nil {
+		return nil, fmt.Errorf("unable to render webhook template: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook template rendered invalid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends a request and returns whether it should be retried if it
+// failed.
+func (s *Sink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.authHeader != "" {
+		req.Header.Set("Authorization", s.authHeader)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Close implements events.Sink.
+func (s *Sink) Close() error {
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
+)
+
+func TestSink_Send(t *testing.T) {
+	var received events.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.EventWebhookURL = srv.URL
+	sink, err := NewSink(config)
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Send(events.Event{Type: events.TargetSynced, Target: "router1"}))
+	assert.Equal(t, events.TargetSynced, received.Type)
+	assert.Equal(t, "router1", received.Target)
+}
+
+func TestSink_SendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	config := configuration.NewDefaultGatewayConfig()
+	_, err := NewSink(config)
+	assert.Error(t, err)
+
+	config.EventWebhookURL = srv.URL
+	sink, err := NewSink(config)
+	assert.NoError(t, err)
+	assert.Error(t, sink.Send(events.Event{Type: events.TargetSynced, Target: "router1"}))
+}
+
+func TestSink_Retry(t *testing.T) {
+	var requests int32
+	status := int32(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+		}
+	}))
+	defer srv.Close()
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.EventWebhookURL = srv.URL
+	config.EventWebhookRetries = 2
+	config.EventWebhookRetryBackoff = time.Millisecond
+	sink, err := NewSink(config)
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Send(events.Event{Type: events.TargetSynced, Target: "router1"}))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// Client errors aren't retried.
+	atomic.StoreInt32(&status, http.StatusBadRequest)
+	atomic.StoreInt32(&requests, 0)
+	assert.Error(t, sink.Send(events.Event{Type: events.TargetSynced, Target: "router1"}))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestSink_Template(t *testing.T) {
+	var received map[string]interface{}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.EventWebhookURL = srv.URL
+	config.EventWebhookAuthHeader = "Bearer secret"
+	config.EventWebhookTemplate = `{"text": {{printf "%s is %s: %s" .Target .Type .Message | json}}}`
+	config.EventWebhookTypes = []string{string(events.TargetDisconnected)}
+	sink, err := NewSink(config)
+	assert.NoError(t, err)
+
+	// Filtered event types aren't posted.
+	assert.NoError(t, sink.Send(events.Event{Type: events.TargetSynced, Target: "router1"}))
+	assert.Nil(t, received)
+
+	assert.NoError(t, sink.Send(events.Event{Type: events.TargetDisconnected, Target: "router1", Message: `read "EOF"`}))
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, map[string]interface{}{"text": `router1 is target.disconnected: read "EOF"`}, received)
+}
+
+func TestNewSink_Errors(t *testing.T) {
+	config := configuration.NewDefaultGatewayConfig()
+	config.EventWebhookURL = "http://localhost"
+	config.EventWebhookTemplate = "{{.Target"
+	_, err := NewSink(config)
+	assert.Error(t, err)
+
+	config.EventWebhookTemplate = ""
+	config.EventWebhookTypes = []string{"target.unknown"}
+	_, err = NewSink(config)
+	assert.Error(t, err)
+
+	config.EventWebhookTypes = nil
+	config.EventWebhookTemplate = "{{.Target}}"
+	sink, err := NewSink(config)
+	assert.NoError(t, err)
+	// The rendered body must be JSON.
+	assert.Error(t, sink.Send(events.Event{Type: events.TargetSynced, Target: "router1"}))
+}
@@ -0,0 +1,251 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// Aggregation functions.
+const (
+	AggregateLast  = "last"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+	AggregateAvg   = "avg"
+	AggregateDelta = "delta"
+)
+
+// Aggregator downsamples numeric updates before they are exported. The
+// samples of each leaf are aggregated over a window and a single update with
+// the aggregated value and the timestamp of the last sample is exported at
+// the end of the window. Non-numeric updates, updates that don't match the
+// aggregation paths, and deletes are exported immediately.
+type Aggregator struct {
+	window   time.Duration
+	function string
+	paths    *pathTrie
+
+	mutex  sync.Mutex
+	series map[string]*aggregateSeries
+}
+
+// aggregateSeries contains the samples of a leaf in the current window.
+type aggregateSeries struct {
+	prefix    *gnmipb.Path
+	path      *gnmipb.Path
+	last      *gnmipb.TypedValue
+	timestamp int64
+	count     int
+	min       float64
+	max       float64
+	sum       float64
+	first     float64
+	lastValue float64
+	// previous is the last value of the previous window, used by delta.
+	previous    float64
+	hasPrevious bool
+}
+
+// NewAggregator validates the aggregation configuration. A nil Aggregator is
+// returned if the window isn't set.
+func NewAggregator(config configuration.ExporterAggregation) (*Aggregator, error) {
+	if config.Window <= 0 {
+		return nil, nil
+	}
+	function := strings.ToLower(config.Function)
+	switch function {
+	case "":
+		function = AggregateLast
+	case AggregateLast, AggregateMin, AggregateMax, AggregateAvg, AggregateDelta:
+	default:
+		return nil, fmt.Errorf("unknown aggregation function '%s'", config.Function)
+	}
+	paths, err := newPathTrie(config.Paths)
+	if err != nil {
+		return nil, err
+	}
+	return &Aggregator{
+		window:   config.Window,
+		function: function,
+		paths:    paths,
+		series:   make(map[string]*aggregateSeries),
+	}, nil
+}
+
+// Wrap returns an Export function that aggregates updates and calls export
+// with the aggregated updates at the end of each window.
+func (a *Aggregator) Wrap(name string, export func(leaf *ctree.Leaf)) func(leaf *ctree.Leaf) {
+	if a == nil {
+		return export
+	}
+	aggregated := stats.Registry.Counter("gnmigateway.exporters.aggregated", map[string]string{"exporter": name})
+	go a.run(export)
+	return func(leaf *ctree.Leaf) {
+		notification, ok := leaf.Value().(*gnmipb.Notification)
+		if !ok {
+			export(leaf)
+			return
+		}
+		passthrough := a.add(notification)
+		if passthrough == nil {
+			aggregated.Increment()
+			return
+		}
+		if passthrough == notification {
+			export(leaf)
+		} else {
+			export(ctree.DetachedLeaf(passthrough))
+		}
+	}
+}
+
+// add adds the numeric updates of the notification to their series. It
+// returns the notification with the updates that must be exported
+// immediately, or nil if there are none.
+func (a *Aggregator) add(notification *gnmipb.Notification) *gnmipb.Notification {
+	prefix := notification.GetPrefix()
+	var passthrough []*gnmipb.Update
+
+	a.mutex.Lock()
+	for _, p := range notification.Delete {
+		delete(a.series, seriesKey(prefix, p))
+	}
+	for _, update := range notification.Update {
+		value, isNumber := utils.GetNumberValues(update.GetVal())
+		if !isNumber || !a.matchPath(prefix, update.GetPath()) {
+			passthrough = append(passthrough, update)
+			continue
+		}
+		key := seriesKey(prefix, update.GetPath())
+		s, exists := a.series[key]
+		if !exists {
+			s = &aggregateSeries{prefix: prefix, path: update.GetPath()}
+			a.series[key] = s
+		}
+		s.add(value, update.GetVal(), notification.GetTimestamp())
+	}
+	a.mutex.Unlock()
+
+	if len(passthrough) == len(notification.Update) {
+		return notification
+	}
+	if len(passthrough) == 0 && len(notification.Delete) == 0 {
+		return nil
+	}
+	filtered := utils.ShallowCopyNotification(notification)
+	filtered.Update = passthrough
+	return filtered
+}
+
+func (a *Aggregator) matchPath(prefix *gnmipb.Path, path *gnmipb.Path) bool {
+	if a.paths == nil {
+		return true
+	}
+	return utils.MatchJoinedPath(prefix.GetElem(), path.GetElem(), a.paths.match)
+}
+
+func (a *Aggregator) run(export func(leaf *ctree.Leaf)) {
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, notification := range a.flush() {
+			export(ctree.DetachedLeaf(notification))
+		}
+	}
+}
+
+// flush returns the aggregated updates for the window that ended and resets
+// the series for the next window.
+func (a *Aggregator) flush() []*gnmipb.Notification {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var notifications []*gnmipb.Notification
+	for _, s := range a.series {
+		if s.count == 0 {
+			continue
+		}
+		notifications = append(notifications, &gnmipb.Notification{
+			Timestamp: s.timestamp,
+			Prefix:    s.prefix,
+			Update:    []*gnmipb.Update{{Path: s.path, Val: s.aggregate(a.function)}},
+		})
+		s.reset()
+	}
+	return notifications
+}
+
+func (s *aggregateSeries) add(value float64, tv *gnmipb.TypedValue, timestamp int64) {
+	if s.count == 0 {
+		s.min, s.max, s.sum, s.first = value, value, 0, value
+	}
+	if value < s.min {
+		s.min = value
+	}
+	if value > s.max {
+		s.max = value
+	}
+	s.sum += value
+	s.count++
+	s.last = tv
+	s.lastValue = value
+	s.timestamp = timestamp
+}
+
+func (s *aggregateSeries) aggregate(function string) *gnmipb.TypedValue {
+	var value float64
+	switch function {
+	case AggregateLast:
+		return s.last
+	case AggregateMin:
+		value = s.min
+	case AggregateMax:
+		value = s.max
+	case AggregateAvg:
+		value = s.sum / float64(s.count)
+	case AggregateDelta:
+		start := s.first
+		if s.hasPrevious {
+			start = s.previous
+		}
+		value = s.lastValue - start
+		if value < 0 {
+			// The counter was reset during the window.
+			value = s.lastValue
+		}
+	}
+	return &gnmipb.TypedValue{Value: &gnmipb.TypedValue_DoubleVal{DoubleVal: value}}
+}
+
+func (s *aggregateSeries) reset() {
+	s.previous = s.lastValue
+	s.hasPrevious = true
+	s.count = 0
+}
+
+func seriesKey(prefix *gnmipb.Path, path *gnmipb.Path) string {
+	return prefix.GetTarget() + ":" + prefix.GetOrigin() + ":" +
+		utils.PathToXPath(&gnmipb.Path{Elem: prefix.GetElem()}) + utils.PathToXPath(&gnmipb.Path{Elem: path.GetElem()})
+}
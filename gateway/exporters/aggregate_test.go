@@ -0,0 +1,139 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"testing"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func counterNotification(timestamp int64, leaf string, value uint64) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Timestamp: timestamp,
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "counters"}, {Name: leaf}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: value}},
+		}},
+	}
+}
+
+func TestAggregator_Delta(t *testing.T) {
+	assertion := assert.New(t)
+	aggregator, err := NewAggregator(configuration.ExporterAggregation{Window: time.Minute, Function: "delta"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, value := range []uint64{100, 110, 130} {
+		assertion.Nil(aggregator.add(counterNotification(int64(i), "in-octets", value)))
+	}
+	notifications := aggregator.flush()
+	if assertion.Len(notifications, 1) {
+		assertion.Equal(int64(2), notifications[0].Timestamp)
+		assertion.Equal(float64(30), notifications[0].Update[0].Val.GetDoubleVal())
+	}
+	assertion.Empty(aggregator.flush())
+
+	// The next window starts from the last value of the previous window.
+	assertion.Nil(aggregator.add(counterNotification(3, "in-octets", 150)))
+	notifications = aggregator.flush()
+	if assertion.Len(notifications, 1) {
+		assertion.Equal(float64(20), notifications[0].Update[0].Val.GetDoubleVal())
+	}
+
+	// Counter reset.
+	assertion.Nil(aggregator.add(counterNotification(4, "in-octets", 5)))
+	notifications = aggregator.flush()
+	if assertion.Len(notifications, 1) {
+		assertion.Equal(float64(5), notifications[0].Update[0].Val.GetDoubleVal())
+	}
+}
+
+func TestAggregator_Functions(t *testing.T) {
+	tests := map[string]float64{
+		"min": 1,
+		"max": 7,
+		"avg": 4,
+	}
+	for function, expected := range tests {
+		aggregator, err := NewAggregator(configuration.ExporterAggregation{Window: time.Minute, Function: function})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, value := range []uint64{4, 1, 7} {
+			aggregator.add(counterNotification(int64(i), "errors", value))
+		}
+		notifications := aggregator.flush()
+		if assert.Len(t, notifications, 1, function) {
+			assert.Equal(t, expected, notifications[0].Update[0].Val.GetDoubleVal(), function)
+		}
+	}
+}
+
+func TestAggregator_Passthrough(t *testing.T) {
+	assertion := assert.New(t)
+	aggregator, err := NewAggregator(configuration.ExporterAggregation{
+		Window: time.Minute,
+		Paths:  []string{"/counters"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a"},
+		Update: []*gnmipb.Update{
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "counters"}, {Name: "in-octets"}}},
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: 1}},
+			},
+			{
+				Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "state"}, {Name: "mtu"}}},
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: 1500}},
+			},
+		},
+	}
+	passthrough := aggregator.add(notification)
+	if assertion.NotNil(passthrough) && assertion.Len(passthrough.Update, 1) {
+		assertion.Equal("mtu", passthrough.Update[0].Path.Elem[1].Name)
+	}
+	assertion.Len(notification.Update, 2, "the original notification must not be modified")
+	assertion.Len(aggregator.flush(), 1)
+
+	// Deletes are exported immediately and clear the series.
+	aggregator.add(counterNotification(1, "in-octets", 2))
+	deleteNotification := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a"},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{{Name: "counters"}, {Name: "in-octets"}}}},
+	}
+	assertion.Equal(deleteNotification, aggregator.add(deleteNotification))
+	assertion.Empty(aggregator.flush())
+}
+
+func TestNewAggregator_Invalid(t *testing.T) {
+	_, err := NewAggregator(configuration.ExporterAggregation{Window: time.Minute, Function: "median"})
+	assert.Error(t, err)
+
+	aggregator, err := NewAggregator(configuration.ExporterAggregation{})
+	assert.NoError(t, err)
+	assert.Nil(t, aggregator)
+}
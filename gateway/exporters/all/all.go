@@ -13,11 +13,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package all imports the included Exporters so that they register
+// themselves. The debug and file Exporters are always included; the others
+// can be left out of the binary with build tags:
+//		go build -tags no_exporter_kafka,no_exporter_kinesis .
+// With the minimal tag only the Exporters selected with a with_exporter_<name>
+// tag are included:
+//		go build -tags minimal,with_exporter_prometheus .
 package all
 
 import (
 	_ "github.com/openconfig/gnmi-gateway/gateway/exporters/debug"
-	_ "github.com/openconfig/gnmi-gateway/gateway/exporters/influxdb"
-	_ "github.com/openconfig/gnmi-gateway/gateway/exporters/kafka"
-	_ "github.com/openconfig/gnmi-gateway/gateway/exporters/prometheus"
+	_ "github.com/openconfig/gnmi-gateway/gateway/exporters/file"
 )
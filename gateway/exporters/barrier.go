@@ -0,0 +1,212 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+// Sync barrier modes.
+const (
+	SyncBarrierIgnore = "ignore"
+	SyncBarrierBuffer = "buffer"
+)
+
+// defaultSyncBarrierLimit is the default maximum number of notifications
+// buffered for a target.
+const defaultSyncBarrierLimit = 100000
+
+// syncedPath is the gateway meta leaf that is set to true once a target has
+// sent its initial sync.
+var syncedPath = []string{"meta", "gateway", "synced"}
+
+// SyncBarrier tracks the sync state of targets from the notifications sent to
+// an exporter and holds back the updates of targets until they have synced.
+//
+// A target is synced once /meta/gateway/synced is true and stops being synced
+// when the leaf is false or the target's data is reset (a delete of "*"),
+// e.g. because it disconnected. Deletes are always exported.
+type SyncBarrier struct {
+	mode  string
+	limit int
+	// synced returns the sync state of a target that hasn't been seen yet,
+	// e.g. from the cache when the exporter starts after the target synced.
+	synced func(target string) bool
+
+	mutex   sync.Mutex
+	targets map[string]*barrierTarget
+}
+
+type barrierTarget struct {
+	mutex  sync.Mutex
+	synced bool
+	buffer []*ctree.Leaf
+}
+
+// NewSyncBarrier validates the sync barrier configuration. The mode may be
+// empty to only track the sync state of targets for a SyncExporter. synced
+// may be nil if the initial sync state of targets is unknown.
+func NewSyncBarrier(config configuration.ExporterSyncBarrier, synced func(target string) bool) (*SyncBarrier, error) {
+	mode := strings.ToLower(config.Mode)
+	switch mode {
+	case "", SyncBarrierIgnore, SyncBarrierBuffer:
+	default:
+		return nil, fmt.Errorf("unknown sync barrier mode '%s'", config.Mode)
+	}
+	if config.Limit < 0 {
+		return nil, fmt.Errorf("sync barrier limit can't be negative")
+	}
+	limit := config.Limit
+	if limit == 0 {
+		limit = defaultSyncBarrierLimit
+	}
+	return &SyncBarrier{
+		mode:    mode,
+		limit:   limit,
+		synced:  synced,
+		targets: make(map[string]*barrierTarget),
+	}, nil
+}
+
+// Wrap returns an Export function that holds back the updates of targets
+// until they have synced. With the buffer mode the buffered notifications of
+// a target are exported in order, without other notifications of the target
+// in between, before the notification that marks it synced. notify, if not
+// nil, is called with the sync state of a target after it changes.
+func (b *SyncBarrier) Wrap(name string, export func(leaf *ctree.Leaf), notify func(target string, synced bool)) func(leaf *ctree.Leaf) {
+	if b == nil || (b.mode == "" && notify == nil) {
+		return export
+	}
+	tags := map[string]string{"exporter": name}
+	dropped := stats.Registry.Counter("gnmigateway.exporters.sync_barrier.dropped", tags)
+	overflows := stats.Registry.Counter("gnmigateway.exporters.sync_barrier.overflows", tags)
+	return func(leaf *ctree.Leaf) {
+		notification, ok := leaf.Value().(*gnmipb.Notification)
+		if !ok {
+			export(leaf)
+			return
+		}
+		target := notification.GetPrefix().GetTarget()
+		t := b.target(target)
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+
+		wasSynced := t.synced
+		switch reset, synced, marker := syncState(notification); {
+		case reset:
+			t.synced = false
+			t.buffer = nil
+			b.forget(target)
+			export(leaf)
+		case marker && synced:
+			for _, buffered := range t.buffer {
+				export(buffered)
+			}
+			t.buffer = nil
+			t.synced = true
+			export(leaf)
+		case marker:
+			t.synced = false
+			export(leaf)
+		case t.synced || b.mode == "" || len(notification.Update) == 0:
+			export(leaf)
+		case b.mode == SyncBarrierIgnore:
+			dropped.Increment()
+		default:
+			t.buffer = append(t.buffer, leaf)
+			if len(t.buffer) >= b.limit {
+				// Treat the target as synced rather than holding an
+				// unbounded backlog for a target that never syncs.
+				overflows.Increment()
+				for _, buffered := range t.buffer {
+					export(buffered)
+				}
+				t.buffer = nil
+				t.synced = true
+			}
+		}
+		if notify != nil && t.synced != wasSynced {
+			notify(target, t.synced)
+		}
+	}
+}
+
+// target returns the state of the named target.
+func (b *SyncBarrier) target(name string) *barrierTarget {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	t, exists := b.targets[name]
+	if !exists {
+		t = &barrierTarget{synced: b.synced != nil && b.synced(name)}
+		b.targets[name] = t
+	}
+	return t
+}
+
+// forget removes the state of a target whose data was reset. The state is
+// created again, unsynced, when the target's next notification arrives.
+func (b *SyncBarrier) forget(name string) {
+	b.mutex.Lock()
+	delete(b.targets, name)
+	b.mutex.Unlock()
+}
+
+// syncState returns whether the notification resets the target's data and
+// whether it contains the synced meta leaf (marker) and its value.
+func syncState(notification *gnmipb.Notification) (reset bool, synced bool, marker bool) {
+	prefix := notification.GetPrefix().GetElem()
+	for _, p := range notification.Delete {
+		elems := p.GetElem()
+		if len(prefix) == 0 && len(elems) == 1 && elems[0].GetName() == "*" {
+			return true, false, false
+		}
+		if len(prefix) == 0 && len(elems) == 0 && len(p.GetElement()) == 1 && p.GetElement()[0] == "*" {
+			return true, false, false
+		}
+	}
+	for _, update := range notification.Update {
+		if isSyncedPath(prefix, update.GetPath().GetElem()) {
+			return false, update.GetVal().GetBoolVal(), true
+		}
+	}
+	return false, false, false
+}
+
+func isSyncedPath(prefix []*gnmipb.PathElem, elems []*gnmipb.PathElem) bool {
+	if len(prefix)+len(elems) != len(syncedPath) {
+		return false
+	}
+	for i, name := range syncedPath {
+		var elem *gnmipb.PathElem
+		if i < len(prefix) {
+			elem = prefix[i]
+		} else {
+			elem = elems[i-len(prefix)]
+		}
+		if elem.GetName() != name {
+			return false
+		}
+	}
+	return true
+}
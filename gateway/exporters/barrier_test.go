@@ -0,0 +1,153 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func syncedNotification(target string, synced bool) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: target},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "meta"}, {Name: "gateway"}, {Name: "synced"}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: synced}},
+		}},
+	}
+}
+
+func resetNotification(target string) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: target},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{{Name: "*"}}}},
+	}
+}
+
+// barrierRecorder records the exported notifications and sync states.
+type barrierRecorder struct {
+	exported []*gnmipb.Notification
+	states   []bool
+}
+
+func (r *barrierRecorder) export(leaf *ctree.Leaf) {
+	r.exported = append(r.exported, leaf.Value().(*gnmipb.Notification))
+}
+
+func (r *barrierRecorder) notify(_ string, synced bool) {
+	r.states = append(r.states, synced)
+}
+
+func TestSyncBarrier_Buffer(t *testing.T) {
+	assertion := assert.New(t)
+	barrier, err := NewSyncBarrier(configuration.ExporterSyncBarrier{Mode: "buffer"}, nil)
+	assertion.NoError(err)
+	recorder := &barrierRecorder{}
+	export := barrier.Wrap("test", recorder.export, recorder.notify)
+
+	first := counterNotification(1, "in-octets", 10)
+	second := counterNotification(2, "out-octets", 20)
+	export(ctree.DetachedLeaf(first))
+	export(ctree.DetachedLeaf(second))
+	assertion.Empty(recorder.exported)
+
+	synced := syncedNotification("a", true)
+	export(ctree.DetachedLeaf(synced))
+	assertion.Equal([]*gnmipb.Notification{first, second, synced}, recorder.exported)
+	assertion.Equal([]bool{true}, recorder.states)
+
+	third := counterNotification(3, "in-octets", 30)
+	export(ctree.DetachedLeaf(third))
+	assertion.Equal(third, recorder.exported[3])
+
+	// a reset is exported and the target has to sync again
+	reset := resetNotification("a")
+	export(ctree.DetachedLeaf(reset))
+	assertion.Equal(reset, recorder.exported[4])
+	assertion.Equal([]bool{true, false}, recorder.states)
+	export(ctree.DetachedLeaf(counterNotification(4, "in-octets", 40)))
+	assertion.Len(recorder.exported, 5)
+}
+
+func TestSyncBarrier_Ignore(t *testing.T) {
+	assertion := assert.New(t)
+	barrier, err := NewSyncBarrier(configuration.ExporterSyncBarrier{Mode: "ignore"}, nil)
+	assertion.NoError(err)
+	recorder := &barrierRecorder{}
+	export := barrier.Wrap("test", recorder.export, nil)
+
+	export(ctree.DetachedLeaf(counterNotification(1, "in-octets", 10)))
+	// deletes are exported before the target has synced
+	deleted := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a"},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{{Name: "counters"}}}},
+	}
+	export(ctree.DetachedLeaf(deleted))
+	synced := syncedNotification("a", true)
+	export(ctree.DetachedLeaf(synced))
+	update := counterNotification(2, "in-octets", 20)
+	export(ctree.DetachedLeaf(update))
+	assertion.Equal([]*gnmipb.Notification{deleted, synced, update}, recorder.exported)
+}
+
+func TestSyncBarrier_Limit(t *testing.T) {
+	assertion := assert.New(t)
+	barrier, err := NewSyncBarrier(configuration.ExporterSyncBarrier{Mode: "buffer", Limit: 2}, nil)
+	assertion.NoError(err)
+	recorder := &barrierRecorder{}
+	export := barrier.Wrap("test", recorder.export, recorder.notify)
+
+	export(ctree.DetachedLeaf(counterNotification(1, "in-octets", 10)))
+	assertion.Empty(recorder.exported)
+	export(ctree.DetachedLeaf(counterNotification(2, "in-octets", 20)))
+	assertion.Len(recorder.exported, 2)
+	assertion.Equal([]bool{true}, recorder.states)
+}
+
+func TestSyncBarrier_InitialState(t *testing.T) {
+	assertion := assert.New(t)
+	barrier, err := NewSyncBarrier(configuration.ExporterSyncBarrier{Mode: "ignore"}, func(target string) bool {
+		return target == "a"
+	})
+	assertion.NoError(err)
+	recorder := &barrierRecorder{}
+	export := barrier.Wrap("test", recorder.export, nil)
+
+	update := counterNotification(1, "in-octets", 10)
+	export(ctree.DetachedLeaf(update))
+	other := counterNotification(1, "in-octets", 10)
+	other.Prefix.Target = "b"
+	export(ctree.DetachedLeaf(other))
+	assertion.Equal([]*gnmipb.Notification{update}, recorder.exported)
+}
+
+func TestNewSyncBarrier(t *testing.T) {
+	_, err := NewSyncBarrier(configuration.ExporterSyncBarrier{Mode: "wait"}, nil)
+	assert.EqualError(t, err, "unknown sync barrier mode 'wait'")
+	_, err = NewSyncBarrier(configuration.ExporterSyncBarrier{Limit: -1}, nil)
+	assert.Error(t, err)
+
+	barrier, err := NewSyncBarrier(configuration.ExporterSyncBarrier{}, nil)
+	assert.NoError(t, err)
+	export := func(*ctree.Leaf) {}
+	// without a mode or a SyncExporter the barrier isn't needed
+	assert.NotNil(t, barrier.Wrap("test", export, nil))
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"sync"
+	"time"
+)
+
+// Batcher groups items into batches for exporters that send data to systems
+// with batch APIs. A batch is flushed when it reaches MaxSize items or when
+// Timeout has passed since the first item was added to it. Batches are
+// flushed one at a time and in order by a single goroutine, so the flush
+// function may block (e.g. while retrying) to apply backpressure to Add.
+type Batcher struct {
+	maxSize int
+	timeout time.Duration
+	flush   func([]interface{})
+
+	mutex   sync.Mutex
+	pending []interface{}
+	timer   *time.Timer
+	closed  bool
+	batches chan []interface{}
+	done    chan struct{}
+}
+
+// NewBatcher returns a Batcher that calls flush with each full or expired
+// batch.
+func NewBatcher(maxSize int, timeout time.Duration, flush func([]interface{})) *Batcher {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	b := &Batcher{
+		maxSize: maxSize,
+		timeout: timeout,
+		flush:   flush,
+		batches: make(chan []interface{}, 1),
+		done:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add adds an item to the current batch. Items added after Close are dropped.
+func (b *Batcher) Add(item interface{}) {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+	b.pending = append(b.pending, item)
+	if len(b.pending) >= b.maxSize {
+		b.flushLocked()
+	} else if len(b.pending) == 1 && b.timeout > 0 {
+		b.timer = time.AfterFunc(b.timeout, b.expire)
+	}
+	b.mutex.Unlock()
+}
+
+// Close flushes the current batch and waits for all batches to be flushed.
+func (b *Batcher) Close() {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		<-b.done
+		return
+	}
+	b.flushLocked()
+	b.closed = true
+	close(b.batches)
+	b.mutex.Unlock()
+	<-b.done
+}
+
+func (b *Batcher) expire() {
+	b.mutex.Lock()
+	if !b.closed {
+		b.flushLocked()
+	}
+	b.mutex.Unlock()
+}
+
+func (b *Batcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	batch := b.pending
+	b.pending = nil
+	b.batches <- batch
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+	for batch := range b.batches {
+		b.flush(batch)
+	}
+}
@@ -18,12 +18,12 @@
 package debug
 
 import (
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/ctree"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 	"github.com/openconfig/gnmi-gateway/gateway/utils"
 )
 
@@ -43,7 +43,7 @@ func NewDebugExporter(config *configuration.GatewayConfig) exporters.Exporter {
 }
 
 type DebugExporter struct {
-	cache  *cache.Cache
+	cache  *shardedcache.Cache
 	config *configuration.GatewayConfig
 }
 
@@ -56,8 +56,12 @@ func (e *DebugExporter) Export(leaf *ctree.Leaf) {
 	e.config.Log.Info().Msg(utils.GNMINotificationPrettyString(notification))
 }
 
-func (e *DebugExporter) Start(cache *cache.Cache) error {
+func (e *DebugExporter) Start(cache *shardedcache.Cache) error {
 	_ = cache
 	e.config.Log.Info().Msg("Starting Debug exporter.")
 	return nil
 }
+
+func (e *DebugExporter) Stop() {
+	// nothing to stop
+}
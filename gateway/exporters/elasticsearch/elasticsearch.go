@@ -0,0 +1,441 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elasticsearch provides an exporter that indexes snapshots of
+// selected path subtrees (e.g. LLDP neighbors or BGP sessions) as
+// Elasticsearch or OpenSearch documents for inventory and search use cases.
+//
+// Each configured ElasticsearchIndex matches a path such as
+// /lldp/interfaces/interface/neighbors/neighbor. Every instance of the path
+// on a target (e.g. every neighbor of every interface) is a document that is
+// re-indexed from the cache shortly after any leaf below it changes and that
+// is deleted when all of its leaves are deleted. Documents have the fields:
+//		target     - the target name.
+//		path       - the XPath of the instance, including keys.
+//		keys       - the keys of the instance path.
+//		values     - the leaves below the instance as nested objects.
+//		@timestamp - the latest leaf timestamp.
+//
+// The index name is a Go text/template with the fields .Target and .Origin.
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/path"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/value"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+const Name = "elasticsearch"
+
+const defaultFlushInterval = time.Second
+
+var _ exporters.ConcurrentExporter = new(ElasticsearchExporter)
+
+func init() {
+	exporters.Register(Name, NewElasticsearchExporter)
+}
+
+func NewElasticsearchExporter(config *configuration.GatewayConfig) exporters.Exporter {
+	return &ElasticsearchExporter{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		dirty:  make(map[string]*document),
+		stop:   make(chan struct{}),
+	}
+}
+
+type ElasticsearchExporter struct {
+	config *configuration.GatewayConfig
+	cache  *shardedcache.Cache
+	client *http.Client
+	rules  []*indexRule
+
+	mutex sync.Mutex
+	dirty map[string]*document
+	next  int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// indexRule is a parsed configuration.ElasticsearchIndex.
+type indexRule struct {
+	path  []*gnmipb.PathElem
+	index *template.Template
+}
+
+// document is an instance of an indexRule path on a target.
+type document struct {
+	rule     *indexRule
+	target   string
+	origin   string
+	instance []*gnmipb.PathElem
+}
+
+func (e *ElasticsearchExporter) Name() string {
+	return Name
+}
+
+// ConcurrentExport implements exporters.ConcurrentExporter.
+// The pending documents are updated under the mutex.
+func (e *ElasticsearchExporter) ConcurrentExport() {}
+
+// Export marks the documents that contain the updated or deleted paths to be
+// re-indexed on the next flush.
+func (e *ElasticsearchExporter) Export(leaf *ctree.Leaf) {
+	notification := leaf.Value().(*gnmipb.Notification)
+	prefix := notification.GetPrefix()
+	if prefix.GetTarget() == "" {
+		return
+	}
+	var paths []*gnmipb.Path
+	for _, update := range notification.Update {
+		paths = append(paths, update.GetPath())
+	}
+	paths = append(paths, notification.Delete...)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, p := range paths {
+		elems := append(append([]*gnmipb.PathElem{}, prefix.GetElem()...), p.GetElem()...)
+		for _, rule := range e.rules {
+			if !utils.MatchPathPrefix(elems, rule.path) {
+				continue
+			}
+			doc := &document{
+				rule:     rule,
+				target:   prefix.GetTarget(),
+				origin:   prefix.GetOrigin(),
+				instance: elems[:len(rule.path)],
+			}
+			e.dirty[doc.id()] = doc
+		}
+	}
+}
+
+func (e *ElasticsearchExporter) Start(cache *shardedcache.Cache) error {
+	e.config.Log.Info().Msg("Starting Elasticsearch exporter.")
+	if len(e.config.Exporters.ElasticsearchAddresses) == 0 {
+		return errors.New("configuration option for Elasticsearch Addresses is not set")
+	}
+	if len(e.config.Exporters.ElasticsearchIndexes) == 0 {
+		return errors.New("configuration option for Elasticsearch Indexes is not set")
+	}
+	for _, index := range e.config.Exporters.ElasticsearchIndexes {
+		p, err := xpath.ToGNMIPath(index.Path)
+		if err != nil {
+			return fmt.Errorf("invalid Elasticsearch index path '%s': %v", index.Path, err)
+		}
+		if len(p.Elem) == 0 {
+			return fmt.Errorf("invalid Elasticsearch index path '%s': path is empty", index.Path)
+		}
+		t, err := template.New("index").Parse(index.Index)
+		if err != nil {
+			return fmt.Errorf("invalid Elasticsearch index name '%s': %v", index.Index, err)
+		}
+		e.rules = append(e.rules, &indexRule{path: p.Elem, index: t})
+	}
+	e.cache = cache
+	go e.run()
+	return nil
+}
+
+func (e *ElasticsearchExporter) run() {
+	interval := e.config.Exporters.ElasticsearchFlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the flush loop and sends the remaining changed documents.
+func (e *ElasticsearchExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		e.flush()
+	})
+}
+
+// flush indexes or deletes every document that has changed since the last
+// flush with a single bulk request.
+func (e *ElasticsearchExporter) flush() {
+	e.mutex.Lock()
+	dirty := e.dirty
+	e.dirty = make(map[string]*document)
+	e.mutex.Unlock()
+	if len(dirty) == 0 {
+		return
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for id, doc := range dirty {
+		index, err := doc.indexName()
+		if err != nil {
+			e.config.Log.Warn().Msgf("failed to render Elasticsearch index name for '%s': %s", id, err)
+			continue
+		}
+		source, err := e.snapshot(doc)
+		if err != nil {
+			e.config.Log.Warn().Msgf("failed to build Elasticsearch document for '%s': %s", id, err)
+			continue
+		}
+		action := "index"
+		if source == nil {
+			action = "delete"
+		}
+		_ = encoder.Encode(map[string]interface{}{action: map[string]string{"_index": index, "_id": id}})
+		if source != nil {
+			_ = encoder.Encode(source)
+		}
+	}
+
+	err := e.bulk(body.Bytes())
+	if err != nil {
+		stats.Registry.Counter("gnmigateway.exporters.elasticsearch.bulk_errors", stats.NoTags).Increment()
+		e.config.Log.Warn().Msgf("failed to index %d documents in Elasticsearch: %s", len(dirty), err)
+		// Retry the documents on the next flush unless they changed again.
+		e.mutex.Lock()
+		for id, doc := range dirty {
+			if _, exists := e.dirty[id]; !exists {
+				e.dirty[id] = doc
+			}
+		}
+		e.mutex.Unlock()
+		return
+	}
+	stats.Registry.Counter("gnmigateway.exporters.elasticsearch.documents", stats.NoTags).Add(int64(len(dirty)))
+}
+
+// snapshot builds the document source from the cache. It returns nil if there
+// are no leaves below the document instance path.
+func (e *ElasticsearchExporter) snapshot(doc *document) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	var timestamp int64
+	var leaves int
+	var err error
+	queryPath := path.ToStrings(&gnmipb.Path{Elem: doc.instance}, false)
+	queryErr := e.cache.Query(doc.target, queryPath, func(_ []string, _ *ctree.Leaf, val interface{}) error {
+		notification, ok := val.(*gnmipb.Notification)
+		if !ok {
+			return nil
+		}
+		prefix := notification.GetPrefix().GetElem()
+		for _, update := range notification.Update {
+			elems := append(append([]*gnmipb.PathElem{}, prefix...), update.GetPath().GetElem()...)
+			if !utils.MatchPathPrefix(elems, doc.instance) {
+				continue
+			}
+			v, valueErr := decodeValue(update.GetVal())
+			if valueErr != nil {
+				err = valueErr
+				continue
+			}
+			setNested(values, elems[len(doc.instance):], v)
+			leaves++
+		}
+		if notification.Timestamp > timestamp {
+			timestamp = notification.Timestamp
+		}
+		return nil
+	})
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	if leaves == 0 {
+		return nil, err
+	}
+
+	keys := make(map[string]string)
+	for _, elem := range doc.instance {
+		for k, v := range elem.Key {
+			if _, exists := keys[k]; exists {
+				keys[elem.Name+"/"+k] = v
+			} else {
+				keys[k] = v
+			}
+		}
+	}
+	return map[string]interface{}{
+		"target":     doc.target,
+		"path":       utils.PathToXPath(&gnmipb.Path{Elem: doc.instance}),
+		"keys":       keys,
+		"values":     values,
+		"@timestamp": time.Unix(0, timestamp).UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends the request body to the _bulk API, trying each configured
+// address in turn until one succeeds.
+func (e *ElasticsearchExporter) bulk(body []byte) error {
+	addresses := e.config.Exporters.ElasticsearchAddresses
+	var err error
+	for i := 0; i < len(addresses); i++ {
+		address := addresses[(e.next+i)%len(addresses)]
+		err = e.bulkRequest(address, body)
+		if err == nil {
+			e.next = (e.next + i) % len(addresses)
+			return nil
+		}
+	}
+	return err
+}
+
+func (e *ElasticsearchExporter) bulkRequest(address string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(address, "/")+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if e.config.Exporters.ElasticsearchUsername != "" {
+		req.SetBasicAuth(e.config.Exporters.ElasticsearchUsername, e.config.Exporters.ElasticsearchPassword)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", address, resp.Status, respBody)
+	}
+
+	var result bulkResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("unable to parse bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for action, status := range item {
+			// Deleting a document that was never indexed isn't an error.
+			if status.Status >= 300 && !(action == "delete" && status.Status == http.StatusNotFound) {
+				return fmt.Errorf("bulk %s failed with status %d: %s", action, status.Status, status.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// id returns the document ID: the target and the XPath of the instance.
+func (d *document) id() string {
+	return d.target + ":" + utils.PathToXPath(&gnmipb.Path{Elem: d.instance})
+}
+
+func (d *document) indexName() (string, error) {
+	var b bytes.Buffer
+	err := d.rule.index.Execute(&b, struct {
+		Target string
+		Origin string
+	}{d.target, d.origin})
+	if err != nil {
+		return "", err
+	}
+	// Index names must be lowercase.
+	return strings.ToLower(b.String()), nil
+}
+
+// setNested sets the value in the nested object at the relative path. Elems
+// with keys are named like "neighbor[id=1]".
+func setNested(values map[string]interface{}, elems []*gnmipb.PathElem, v interface{}) {
+	if len(elems) == 0 {
+		return
+	}
+	current := values
+	for i, elem := range elems {
+		name := elemName(elem)
+		if i == len(elems)-1 {
+			current[name] = v
+			return
+		}
+		child, ok := current[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			current[name] = child
+		}
+		current = child
+	}
+}
+
+func elemName(elem *gnmipb.PathElem) string {
+	if len(elem.Key) == 0 {
+		return elem.Name
+	}
+	keys := make([]string, 0, len(elem.Key))
+	for k := range elem.Key {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+elem.Key[k])
+	}
+	return elem.Name + "[" + strings.Join(pairs, " ") + "]"
+}
+
+func decodeValue(tv *gnmipb.TypedValue) (interface{}, error) {
+	switch v := tv.GetValue().(type) {
+	case *gnmipb.TypedValue_JsonVal:
+		var decoded interface{}
+		err := json.Unmarshal(v.JsonVal, &decoded)
+		return decoded, err
+	case *gnmipb.TypedValue_JsonIetfVal:
+		var decoded interface{}
+		err := json.Unmarshal(v.JsonIetfVal, &decoded)
+		return decoded, err
+	}
+	return value.ToScalar(tv)
+}
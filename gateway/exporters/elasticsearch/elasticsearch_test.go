@@ -0,0 +1,115 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+func neighborPath(leaf string) *pb.Path {
+	return &pb.Path{Elem: []*pb.PathElem{
+		{Name: "lldp"},
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "eth0"}},
+		{Name: "neighbors"},
+		{Name: "neighbor", Key: map[string]string{"id": "1"}},
+		{Name: "state"},
+		{Name: leaf},
+	}}
+}
+
+func TestElasticsearchExporter_Flush(t *testing.T) {
+	assertion := assert.New(t)
+
+	requests := make(chan []map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal("/_bulk", r.URL.Path)
+		var lines []map[string]interface{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			line := make(map[string]interface{})
+			assertion.NoError(json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		requests <- lines
+		_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer server.Close()
+
+	config := &configuration.GatewayConfig{
+		Exporters: &configuration.ExportersConfig{
+			ElasticsearchAddresses:     []string{server.URL},
+			ElasticsearchFlushInterval: time.Hour,
+			ElasticsearchIndexes: []configuration.ElasticsearchIndex{
+				{Path: "/lldp/interfaces/interface/neighbors/neighbor", Index: "LLDP-{{.Target}}"},
+			},
+		},
+		Log: configuration.NewDefaultGatewayConfig().Log,
+	}
+	c := shardedcache.New(1, nil)
+	targetCache := c.Add("dev1")
+	e := NewElasticsearchExporter(config).(*ElasticsearchExporter)
+	assertion.NoError(e.Start(c))
+	c.SetClient(e.Export)
+
+	assertion.NoError(targetCache.GnmiUpdate(&pb.Notification{
+		Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+		Prefix:    &pb.Path{Target: "dev1"},
+		Update: []*pb.Update{
+			{Path: neighborPath("system-name"), Val: &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "switch1"}}},
+			{Path: neighborPath("ttl"), Val: &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 120}}},
+		},
+	}))
+	e.flush()
+
+	lines := <-requests
+	if assertion.Len(lines, 2) {
+		assertion.Equal(map[string]interface{}{"index": map[string]interface{}{
+			"_index": "lldp-dev1",
+			"_id":    "dev1:/lldp/interfaces/interface[name=eth0]/neighbors/neighbor[id=1]",
+		}}, lines[0])
+		assertion.Equal("dev1", lines[1]["target"])
+		assertion.Equal(map[string]interface{}{"name": "eth0", "id": "1"}, lines[1]["keys"])
+		assertion.Equal(map[string]interface{}{"state": map[string]interface{}{
+			"system-name": "switch1",
+			"ttl":         float64(120),
+		}}, lines[1]["values"])
+		assertion.Equal("2020-01-01T00:00:00Z", lines[1]["@timestamp"])
+	}
+
+	assertion.NoError(targetCache.GnmiUpdate(&pb.Notification{
+		Timestamp: time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC).UnixNano(),
+		Prefix:    &pb.Path{Target: "dev1"},
+		Delete:    []*pb.Path{neighborPath("system-name"), neighborPath("ttl")},
+	}))
+	e.flush()
+
+	lines = <-requests
+	if assertion.Len(lines, 1) {
+		assertion.Contains(lines[0], "delete")
+	}
+}
@@ -20,10 +20,10 @@ package exporters
 //go:generate mockgen -destination=exporter_mock_test.go -package=exporters_test github.com/openconfig/gnmi-gateway/gateway/exporters Exporter
 
 import (
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/ctree"
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 )
 
 var Registry = make(map[string]func(config *configuration.GatewayConfig) Exporter)
@@ -34,11 +34,11 @@ type Exporter interface {
 	// and recording internal stats.
 	Name() string
 	// Start will be called once by the gateway.Gateway after StartGateway
-	// is called. It will receive a pointer to the cache.Cache that
+	// is called. It will receive a pointer to the shardedcache.Cache that
 	// receives all of the updates from gNMI targets that the gateway has a
 	// subscription for. If Start returns an error the gateway will fail to
 	// start with an error.
-	Start(*cache.Cache) error
+	Start(*shardedcache.Cache) error
 	// Export will be called once for every gNMI notification that is inserted
 	// into the cache.Cache. Export should complete as quickly as possible to
 	// prevent delays in the system and upstream gNMI clients.
@@ -46,6 +46,53 @@ type Exporter interface {
 	// has a value of type *gnmipb.Notification. You can access the notification
 	// with a type assertion: leaf.Value().(*gnmipb.Notification)
 	Export(leaf *ctree.Leaf)
+	// Stop will be called once by the gateway.Gateway when it stops. Stop
+	// should flush buffered data and release any connections. Export may
+	// still be called after Stop and should drop the notification.
+	Stop()
+}
+
+// LeaderExporter may be implemented by an Exporter that must run on exactly
+// one cluster member, e.g. one that writes summary records. The cluster
+// members elect a leader for each LeaderExporter and Export is only called on
+// the leader. If the leader stops or loses its Zookeeper session another
+// member is elected. Without clustering the gateway is always the leader.
+type LeaderExporter interface {
+	Exporter
+	// SetLeader is called when this cluster member becomes (true) or stops
+	// being (false) the leader for the exporter.
+	SetLeader(leader bool)
+}
+
+// ConcurrentExporter may be implemented by an Exporter whose Export method is
+// safe to call from multiple goroutines. The updates of a ConcurrentExporter
+// are delivered by FanoutWorkers goroutines; the updates of each target are
+// still exported in order.
+type ConcurrentExporter interface {
+	Exporter
+	// ConcurrentExport is a marker method; it isn't called.
+	ConcurrentExport()
+}
+
+// SyncExporter may be implemented by an Exporter that needs to know when
+// targets have sent their initial sync, e.g. one that computes deltas.
+// TargetSynced is called with true after the initial sync of a target was
+// exported and with false when the target's data is reset, e.g. because it
+// disconnected. With a "buffer" sync barrier the notifications of the initial
+// sync are exported right before TargetSynced(target, true), so the exporter
+// can apply them as one snapshot.
+type SyncExporter interface {
+	Exporter
+	TargetSynced(target string, synced bool)
+}
+
+// LabelExporter may be implemented by an Exporter that can add the inventory
+// labels of targets (e.g. site, role, and region) to its outputs.
+type LabelExporter interface {
+	Exporter
+	// SetLabels is called before Start with the function that returns the
+	// inventory labels of a target. The returned map must not be modified.
+	SetLabels(labels func(target string) map[string]string)
 }
 
 func Register(name string, new func(config *configuration.GatewayConfig) Exporter) {
@@ -8,7 +8,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
-	cache "github.com/openconfig/gnmi/cache"
+	shardedcache "github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 	ctree "github.com/openconfig/gnmi/ctree"
 )
 
@@ -48,7 +48,7 @@ func (mr *MockExporterMockRecorder) Export(arg0 interface{}) *gomock.Call {
 }
 
 // Start mocks base method
-func (m *MockExporter) Start(arg0 *cache.Cache) error {
+func (m *MockExporter) Start(arg0 *shardedcache.Cache) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Start", arg0)
 	ret0, _ := ret[0].(error)
@@ -60,3 +60,15 @@ func (mr *MockExporterMockRecorder) Start(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockExporter)(nil).Start), arg0)
 }
+
+// Stop mocks base method
+func (m *MockExporter) Stop() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Stop")
+}
+
+// Stop indicates an expected call of Stop
+func (mr *MockExporterMockRecorder) Stop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockExporter)(nil).Stop))
+}
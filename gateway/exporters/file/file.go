@@ -0,0 +1,420 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package file provides an exporter that archives gNMI updates to rolling
+// files for long-term storage and offline analysis, e.g. with Spark or
+// DuckDB. Each update and delete is written as a row with the timestamp,
+// target, origin, path, and value.
+//
+// Files are partitioned by target and hour of the notification timestamp
+// with Hive-style directories:
+//
+//	<FileDirectory>/target=<target>/date=2020-10-01/hour=12/<file>
+//
+// The files of an hour are closed shortly after the hour ends. Files are
+// written with a ".tmp" suffix that is removed when the file is closed, so
+// readers only see complete files. The selected paths are set with the
+// exporter filter of the "file" exporter.
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/value"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+const Name = "file"
+
+// File formats.
+const (
+	FormatJSONL     = "jsonl"
+	FormatJSONLGzip = "jsonl.gz"
+	FormatParquet   = "parquet"
+)
+
+const (
+	// closeDelay is the time after the end of an hour that its files are
+	// closed, to include notifications that arrive late.
+	closeDelay = time.Minute
+	// closeCheckInterval is the interval between checks for files to close.
+	closeCheckInterval = 15 * time.Second
+	tmpSuffix          = ".tmp"
+)
+
+var _ exporters.Exporter = new(FileExporter)
+
+func init() {
+	exporters.Register(Name, NewFileExporter)
+}
+
+func NewFileExporter(config *configuration.GatewayConfig) exporters.Exporter {
+	return &FileExporter{
+		config: config,
+		files:  make(map[partition]*partitionFile),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+type FileExporter struct {
+	cache     *shardedcache.Cache
+	config    *configuration.GatewayConfig
+	directory string
+	format    string
+	hostname  string
+	now       func() time.Time
+
+	mutex   sync.Mutex
+	files   map[partition]*partitionFile
+	stopped bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// partition identifies the files of a target and hour.
+type partition struct {
+	target string
+	hour   int64
+}
+
+// partitionFile is an open file of a partition.
+type partitionFile struct {
+	path   string
+	writer rowWriter
+	rows   int64
+}
+
+// rowWriter writes rows to a file in one of the formats.
+type rowWriter interface {
+	Write(r row) error
+	// Close flushes the buffered rows and closes the file.
+	Close() error
+}
+
+// row is an exported update or delete.
+type row struct {
+	Timestamp int64       `json:"timestamp"`
+	Target    string      `json:"target"`
+	Origin    string      `json:"origin,omitempty"`
+	Path      string      `json:"path"`
+	Value     interface{} `json:"value,omitempty"`
+	Number    *float64    `json:"-"`
+	Deleted   bool        `json:"deleted,omitempty"`
+}
+
+// valueString returns string values as they are and other values encoded as
+// JSON.
+func (r *row) valueString() string {
+	if s, ok := r.Value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(r.Value)
+	if err != nil {
+		return fmt.Sprint(r.Value)
+	}
+	return string(encoded)
+}
+
+func (e *FileExporter) Name() string {
+	return Name
+}
+
+func (e *FileExporter) Export(leaf *ctree.Leaf) {
+	notification := leaf.Value().(*gnmipb.Notification)
+	prefix := notification.GetPrefix()
+	timestamp := notification.GetTimestamp()
+	if timestamp == 0 {
+		timestamp = e.now().UnixNano()
+	}
+
+	var rows []row
+	for _, update := range notification.GetUpdate() {
+		r := row{
+			Timestamp: timestamp,
+			Target:    prefix.GetTarget(),
+			Origin:    prefix.GetOrigin(),
+			Path:      joinedPath(prefix, update.GetPath()),
+		}
+		v, err := decodeValue(update.GetVal())
+		if err != nil {
+			stats.Registry.Counter("gnmigateway.exporters.file.decode_errors", stats.NoTags).Increment()
+			continue
+		}
+		r.Value = v
+		if _, isBool := update.GetVal().GetValue().(*gnmipb.TypedValue_BoolVal); !isBool {
+			if number, isNumber := utils.GetNumberValues(update.GetVal()); isNumber {
+				r.Number = &number
+			}
+		}
+		rows = append(rows, r)
+	}
+	for _, path := range notification.GetDelete() {
+		rows = append(rows, row{
+			Timestamp: timestamp,
+			Target:    prefix.GetTarget(),
+			Origin:    prefix.GetOrigin(),
+			Path:      joinedPath(prefix, path),
+			Deleted:   true,
+		})
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	key := partition{
+		target: prefix.GetTarget(),
+		hour:   time.Unix(0, timestamp).UTC().Truncate(time.Hour).Unix(),
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.stopped {
+		return
+	}
+	file, err := e.open(key)
+	if err != nil {
+		stats.Registry.Counter("gnmigateway.exporters.file.write_errors", stats.NoTags).Increment()
+		e.config.Log.Error().Err(err).Msgf("Unable to open export file for target %s: %v", key.target, err)
+		return
+	}
+	for _, r := range rows {
+		if err := file.writer.Write(r); err != nil {
+			stats.Registry.Counter("gnmigateway.exporters.file.write_errors", stats.NoTags).Increment()
+			e.config.Log.Error().Err(err).Msgf("Unable to write to export file %s: %v", file.path, err)
+			return
+		}
+		file.rows++
+	}
+	stats.Registry.Counter("gnmigateway.exporters.file.rows_written", stats.NoTags).Add(int64(len(rows)))
+}
+
+func (e *FileExporter) Start(cache *shardedcache.Cache) error {
+	e.config.Log.Info().Msg("Starting file exporter.")
+	e.directory = e.config.Exporters.FileDirectory
+	if e.directory == "" {
+		return errors.New("configuration option for the file exporter directory is not set")
+	}
+	e.format = e.config.Exporters.FileFormat
+	if e.format == "" {
+		e.format = FormatJSONL
+	}
+	if err := ValidateFormat(e.format); err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "gnmi-gateway"
+	}
+	e.hostname = hostname
+	e.cache = cache
+	go e.run()
+	return nil
+}
+
+// ValidateFormat returns an error if format isn't a supported file format.
+// An empty format selects FormatJSONL.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatJSONL, FormatJSONLGzip, FormatParquet:
+		return nil
+	}
+	return fmt.Errorf("invalid file exporter format '%s': must be %s, %s, or %s", format, FormatJSONL, FormatJSONLGzip, FormatParquet)
+}
+
+func (e *FileExporter) run() {
+	ticker := time.NewTicker(closeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.closeFinished()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Stop closes all of the open files.
+func (e *FileExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		e.stopped = true
+		for key, file := range e.files {
+			e.closeFile(key, file)
+		}
+	})
+}
+
+// closeFinished closes the files of the hours that ended more than
+// closeDelay ago.
+func (e *FileExporter) closeFinished() {
+	cutoff := e.now().Add(-closeDelay).Add(-time.Hour).Unix()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for key, file := range e.files {
+		if key.hour <= cutoff {
+			e.closeFile(key, file)
+		}
+	}
+}
+
+// open returns the open file of the partition or creates a new one. It must
+// be called with the mutex held.
+func (e *FileExporter) open(key partition) (*partitionFile, error) {
+	if file, exists := e.files[key]; exists {
+		return file, nil
+	}
+	hour := time.Unix(key.hour, 0).UTC()
+	target := key.target
+	if target == "" {
+		target = "_"
+	}
+	dir := filepath.Join(e.directory,
+		"target="+url.PathEscape(target),
+		"date="+hour.Format("2006-01-02"),
+		"hour="+hour.Format("15"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// The creation time keeps the files of a partition written by
+	// successive runs apart.
+	name := fmt.Sprintf("%s-%d.%s", e.hostname, e.now().UnixNano(), e.format)
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path+tmpSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := newRowWriter(f, e.format)
+	if err != nil {
+		f.Close()
+		os.Remove(path + tmpSuffix)
+		return nil, err
+	}
+	file := &partitionFile{path: path, writer: writer}
+	e.files[key] = file
+	return file, nil
+}
+
+// closeFile closes the file and removes the temporary file suffix. It must
+// be called with the mutex held.
+func (e *FileExporter) closeFile(key partition, file *partitionFile) {
+	delete(e.files, key)
+	err := file.writer.Close()
+	if err == nil {
+		err = os.Rename(file.path+tmpSuffix, file.path)
+	}
+	if err != nil {
+		stats.Registry.Counter("gnmigateway.exporters.file.write_errors", stats.NoTags).Increment()
+		e.config.Log.Error().Err(err).Msgf("Unable to close export file %s: %v", file.path, err)
+		return
+	}
+	stats.Registry.Counter("gnmigateway.exporters.file.files_written", stats.NoTags).Increment()
+	e.config.Log.Debug().Msgf("Closed export file %s with %d rows.", file.path, file.rows)
+}
+
+func newRowWriter(f *os.File, format string) (rowWriter, error) {
+	switch format {
+	case FormatJSONL:
+		return newJSONLWriter(f, nil), nil
+	case FormatJSONLGzip:
+		return newJSONLWriter(f, gzip.NewWriter(f)), nil
+	case FormatParquet:
+		return newParquetWriter(f)
+	}
+	return nil, ValidateFormat(format)
+}
+
+// jsonlWriter writes each row as a JSON object on its own line.
+type jsonlWriter struct {
+	file    io.Closer
+	gzip    *gzip.Writer
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(f io.WriteCloser, gz *gzip.Writer) *jsonlWriter {
+	w := &jsonlWriter{file: f, gzip: gz}
+	if gz != nil {
+		w.buf = bufio.NewWriter(gz)
+	} else {
+		w.buf = bufio.NewWriter(f)
+	}
+	w.encoder = json.NewEncoder(w.buf)
+	return w
+}
+
+func (w *jsonlWriter) Write(r row) error {
+	return w.encoder.Encode(r)
+}
+
+func (w *jsonlWriter) Close() error {
+	err := w.buf.Flush()
+	if w.gzip != nil {
+		if gzErr := w.gzip.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// joinedPath returns the XPath of the path below the prefix, without the
+// target and origin.
+func joinedPath(prefix *gnmipb.Path, path *gnmipb.Path) string {
+	elems := make([]*gnmipb.PathElem, 0, len(prefix.GetElem())+len(path.GetElem()))
+	elems = append(elems, prefix.GetElem()...)
+	elems = append(elems, path.GetElem()...)
+	xPath := utils.PathToXPath(&gnmipb.Path{Elem: elems})
+	if xPath == "" {
+		return "/"
+	}
+	return xPath
+}
+
+func decodeValue(tv *gnmipb.TypedValue) (interface{}, error) {
+	switch v := tv.GetValue().(type) {
+	case *gnmipb.TypedValue_JsonVal:
+		var decoded interface{}
+		err := json.Unmarshal(v.JsonVal, &decoded)
+		return decoded, err
+	case *gnmipb.TypedValue_JsonIetfVal:
+		var decoded interface{}
+		err := json.Unmarshal(v.JsonIetfVal, &decoded)
+		return decoded, err
+	}
+	return value.ToScalar(tv)
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func newTestExporter(t *testing.T, format string) (*FileExporter, string) {
+	dir, err := ioutil.TempDir("", "file-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := configuration.NewDefaultGatewayConfig()
+	config.Exporters.FileDirectory = dir
+	config.Exporters.FileFormat = format
+	e := NewFileExporter(config).(*FileExporter)
+	if err := e.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	e.hostname = "gw1"
+	return e, dir
+}
+
+func testNotification(target string, timestamp time.Time) *pb.Notification {
+	return &pb.Notification{
+		Timestamp: timestamp.UnixNano(),
+		Prefix:    &pb.Path{Target: target, Elem: []*pb.PathElem{{Name: "interfaces"}}},
+		Update: []*pb.Update{
+			{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "in-octets"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}},
+			},
+			{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "oper-status"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "UP"}},
+			},
+		},
+		Delete: []*pb.Path{{Elem: []*pb.PathElem{{Name: "interface", Key: map[string]string{"name": "eth1"}}}}},
+	}
+}
+
+func findFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestFileExporter_JSONL(t *testing.T) {
+	assertion := assert.New(t)
+
+	e, dir := newTestExporter(t, FormatJSONLGzip)
+	defer os.RemoveAll(dir)
+	now := time.Date(2020, 10, 1, 12, 30, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+
+	e.Export(ctree.DetachedLeaf(testNotification("router1", now)))
+	e.Export(ctree.DetachedLeaf(testNotification("router/2", now.Add(-time.Hour))))
+
+	// the previous hour is closed after the close delay
+	e.closeFinished()
+	files := findFiles(t, dir)
+	if !assertion.Len(files, 2) {
+		return
+	}
+	assertion.Regexp(`^target=router%2F2/date=2020-10-01/hour=11/gw1-\d+\.jsonl\.gz$`, files[0])
+	assertion.Regexp(`^target=router1/date=2020-10-01/hour=12/gw1-\d+\.jsonl\.gz\.tmp$`, files[1])
+
+	e.Stop()
+	files = findFiles(t, dir)
+	if !assertion.Len(files, 2) {
+		return
+	}
+	assertion.Regexp(`\.jsonl\.gz$`, files[1])
+
+	f, err := os.Open(filepath.Join(dir, files[1]))
+	if !assertion.NoError(err) {
+		return
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if !assertion.NoError(err) {
+		return
+	}
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var r map[string]interface{}
+		assertion.NoError(json.Unmarshal(scanner.Bytes(), &r))
+		rows = append(rows, r)
+	}
+	if !assertion.Len(rows, 3) {
+		return
+	}
+	assertion.Equal(map[string]interface{}{
+		"timestamp": float64(now.UnixNano()),
+		"target":    "router1",
+		"path":      "/interfaces/interface[name=eth0]/in-octets",
+		"value":     float64(42),
+	}, rows[0])
+	assertion.Equal("UP", rows[1]["value"])
+	assertion.Equal(map[string]interface{}{
+		"timestamp": float64(now.UnixNano()),
+		"target":    "router1",
+		"path":      "/interfaces/interface[name=eth1]",
+		"deleted":   true,
+	}, rows[2])
+
+	// notifications after Stop are dropped
+	e.Export(ctree.DetachedLeaf(testNotification("router1", now)))
+	assertion.Len(findFiles(t, dir), 2)
+}
+
+func TestFileExporter_Parquet(t *testing.T) {
+	assertion := assert.New(t)
+
+	e, dir := newTestExporter(t, FormatParquet)
+	defer os.RemoveAll(dir)
+	now := time.Date(2020, 10, 1, 12, 30, 0, 0, time.UTC)
+	e.Export(ctree.DetachedLeaf(testNotification("router1", now)))
+	e.Stop()
+
+	files := findFiles(t, dir)
+	if !assertion.Len(files, 1) {
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, files[0]))
+	if !assertion.NoError(err) {
+		return
+	}
+	if !assertion.True(bytes.HasPrefix(data, []byte(parquetMagic)) && bytes.HasSuffix(data, []byte(parquetMagic))) {
+		return
+	}
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadata := newThriftReader(data[len(data)-8-length : len(data)-8]).readStruct()
+
+	assertion.Equal(int64(3), metadata[3])
+	schema := metadata[2].([]interface{})
+	if !assertion.Len(schema, len(parquetSchema)+1) {
+		return
+	}
+	for i, column := range parquetSchema {
+		assertion.Equal(column.name, string(schema[i+1].(map[int16]interface{})[4].([]byte)))
+	}
+
+	rowGroups := metadata[4].([]interface{})
+	if !assertion.Len(rowGroups, 1) {
+		return
+	}
+	columns := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	if !assertion.Len(columns, len(parquetSchema)) {
+		return
+	}
+
+	// the path column
+	columnMetadata := columns[3].(map[int16]interface{})[3].(map[int16]interface{})
+	assertion.Equal(int64(3), columnMetadata[5])
+	offset := columnMetadata[9].(int64)
+	header := newThriftReader(data[offset:])
+	pageHeader := header.readStruct()
+	assertion.Equal(int64(3), pageHeader[5].(map[int16]interface{})[1])
+	pageStart := offset + int64(header.pos)
+	page, err := gzip.NewReader(bytes.NewReader(data[pageStart : pageStart+pageHeader[3].(int64)]))
+	if !assertion.NoError(err) {
+		return
+	}
+	values, err := ioutil.ReadAll(page)
+	if !assertion.NoError(err) {
+		return
+	}
+	assertion.Equal(int64(len(values)), pageHeader[2])
+	var paths []string
+	for len(values) > 0 {
+		n := binary.LittleEndian.Uint32(values)
+		paths = append(paths, string(values[4:4+n]))
+		values = values[4+n:]
+	}
+	assertion.Equal([]string{
+		"/interfaces/interface[name=eth0]/in-octets",
+		"/interfaces/interface[name=eth0]/oper-status",
+		"/interfaces/interface[name=eth1]",
+	}, paths)
+}
+
+func TestEncodeDefinitionLevels(t *testing.T) {
+	assert.Equal(t, []byte{4, 1, 2, 0, 2, 1}, encodeDefinitionLevels([]bool{true, true, false, true}))
+	assert.Equal(t, []byte{0x05}, packBits([]bool{true, false, true}))
+}
+
+func TestValidateFormat(t *testing.T) {
+	assert.NoError(t, ValidateFormat(""))
+	assert.NoError(t, ValidateFormat(FormatParquet))
+	assert.Error(t, ValidateFormat("csv"))
+}
+
+// thriftReader decodes the Thrift compact protocol structs written by
+// thriftWriter. Structs are decoded as maps by field ID, lists as slices,
+// integers as int64, and binary as []byte.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func newThriftReader(data []byte) *thriftReader {
+	return &thriftReader{data: data}
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		b := r.data[r.pos]
+		r.pos++
+		if b == 0 {
+			return fields
+		}
+		typ := b & 0x0f
+		if delta := int16(b >> 4); delta != 0 {
+			last += delta
+		} else {
+			last = int16(r.varint())
+		}
+		switch typ {
+		case thriftBoolTrue:
+			fields[last] = true
+		case thriftBoolFalse:
+			fields[last] = false
+		default:
+			fields[last] = r.readValue(typ)
+		}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n, size := binary.Uvarint(r.data[r.pos:])
+		r.pos += size
+		v := r.data[r.pos : r.pos+int(n)]
+		r.pos += int(n)
+		return v
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			n, s := binary.Uvarint(r.data[r.pos:])
+			r.pos += s
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.readValue(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unsupported thrift type")
+}
@@ -0,0 +1,464 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The Parquet writer writes the fixed row schema with one data page per
+// column chunk. Pages use the PLAIN encoding and are compressed with gzip.
+// The file metadata is encoded with the Thrift compact protocol; see
+// https://github.com/apache/parquet-format for the format definition.
+
+const parquetMagic = "PAR1"
+
+// parquetRowGroupRows is the number of rows buffered before a row group is
+// written.
+const parquetRowGroupRows = 10000
+
+// Parquet physical types.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet enum values used by the writer.
+const (
+	parquetRequired      = 0
+	parquetOptional      = 1
+	parquetConvertedUTF8 = 0
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecGzip     = 2
+	parquetPageTypeData  = 0
+	parquetFormatVersion = 1
+	parquetCreatedBy     = "gnmi-gateway"
+	parquetLogicalString = 1
+	parquetLogicalTime   = 8
+	parquetTimeUnitNanos = 3
+)
+
+type parquetColumn struct {
+	name       string
+	typ        int32
+	repetition int32
+	utf8       bool
+	timestamp  bool
+	// value returns the value of the column in a row: an int64, float64,
+	// string, or bool matching typ, or nil if the value is null.
+	value func(r *row) interface{}
+}
+
+// parquetSchema are the columns of the rows, in order.
+var parquetSchema = []parquetColumn{
+	{name: "timestamp", typ: parquetInt64, repetition: parquetRequired, timestamp: true,
+		value: func(r *row) interface{} { return r.Timestamp }},
+	{name: "target", typ: parquetByteArray, repetition: parquetRequired, utf8: true,
+		value: func(r *row) interface{} { return r.Target }},
+	{name: "origin", typ: parquetByteArray, repetition: parquetRequired, utf8: true,
+		value: func(r *row) interface{} { return r.Origin }},
+	{name: "path", typ: parquetByteArray, repetition: parquetRequired, utf8: true,
+		value: func(r *row) interface{} { return r.Path }},
+	{name: "value", typ: parquetByteArray, repetition: parquetOptional, utf8: true,
+		value: func(r *row) interface{} {
+			if r.Value == nil {
+				return nil
+			}
+			return r.valueString()
+		}},
+	{name: "number", typ: parquetDouble, repetition: parquetOptional,
+		value: func(r *row) interface{} {
+			if r.Number == nil {
+				return nil
+			}
+			return *r.Number
+		}},
+	{name: "deleted", typ: parquetBoolean, repetition: parquetRequired,
+		value: func(r *row) interface{} { return r.Deleted }},
+}
+
+type parquetColumnChunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type parquetRowGroup struct {
+	columns   []parquetColumnChunk
+	numRows   int64
+	totalSize int64
+}
+
+// parquetWriter writes rows to a Parquet file. Rows are buffered and written
+// as row groups; the file metadata is written by Close.
+type parquetWriter struct {
+	w         io.WriteCloser
+	offset    int64
+	rows      []row
+	rowGroups []parquetRowGroup
+	numRows   int64
+}
+
+func newParquetWriter(w io.WriteCloser) (*parquetWriter, error) {
+	p := &parquetWriter{w: w}
+	if err := p.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parquetWriter) Write(r row) error {
+	p.rows = append(p.rows, r)
+	if len(p.rows) >= parquetRowGroupRows {
+		return p.writeRowGroup()
+	}
+	return nil
+}
+
+// Close writes the buffered rows and the file metadata and closes the file.
+func (p *parquetWriter) Close() error {
+	err := p.writeRowGroup()
+	if err == nil {
+		metadata := p.fileMetadata()
+		length := make([]byte, 4)
+		binary.LittleEndian.PutUint32(length, uint32(len(metadata)))
+		err = p.write(append(append(metadata, length...), parquetMagic...))
+	}
+	if closeErr := p.w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+func (p *parquetWriter) writeRowGroup() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	group := parquetRowGroup{numRows: int64(len(p.rows))}
+	for i := range parquetSchema {
+		chunk, err := p.writeColumnChunk(i)
+		if err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+		group.totalSize += chunk.uncompressedSize
+	}
+	p.rowGroups = append(p.rowGroups, group)
+	p.numRows += group.numRows
+	p.rows = p.rows[:0]
+	return nil
+}
+
+// writeColumnChunk writes the values of column i of the buffered rows as a
+// single data page.
+func (p *parquetWriter) writeColumnChunk(i int) (parquetColumnChunk, error) {
+	column := parquetSchema[i]
+	values := make([]interface{}, len(p.rows))
+	for j := range p.rows {
+		values[j] = column.value(&p.rows[j])
+	}
+
+	var page bytes.Buffer
+	if column.repetition == parquetOptional {
+		levels := make([]bool, len(values))
+		for j, v := range values {
+			levels[j] = v != nil
+		}
+		encoded := encodeDefinitionLevels(levels)
+		length := make([]byte, 4)
+		binary.LittleEndian.PutUint32(length, uint32(len(encoded)))
+		page.Write(length)
+		page.Write(encoded)
+	}
+	var bits []bool
+	for _, v := range values {
+		switch v := v.(type) {
+		case int64:
+			_ = binary.Write(&page, binary.LittleEndian, v)
+		case float64:
+			_ = binary.Write(&page, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			writePlainByteArray(&page, v)
+		case bool:
+			bits = append(bits, v)
+		}
+	}
+	if column.typ == parquetBoolean {
+		page.Write(packBits(bits))
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(page.Bytes()); err != nil {
+		return parquetColumnChunk{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return parquetColumnChunk{}, err
+	}
+
+	header := new(thriftWriter)
+	header.i32(1, parquetPageTypeData)
+	header.i32(2, int32(page.Len()))
+	header.i32(3, int32(compressed.Len()))
+	header.beginStruct(5)
+	header.i32(1, int32(len(p.rows)))
+	header.i32(2, parquetEncodingPlain)
+	header.i32(3, parquetEncodingRLE)
+	header.i32(4, parquetEncodingRLE)
+	header.endStruct()
+	header.stop()
+
+	chunk := parquetColumnChunk{
+		offset:           p.offset,
+		numValues:        int64(len(p.rows)),
+		uncompressedSize: int64(header.buf.Len() + page.Len()),
+		compressedSize:   int64(header.buf.Len() + compressed.Len()),
+	}
+	if err := p.write(header.buf.Bytes()); err != nil {
+		return chunk, err
+	}
+	return chunk, p.write(compressed.Bytes())
+}
+
+// fileMetadata encodes the FileMetaData structure.
+func (p *parquetWriter) fileMetadata() []byte {
+	t := new(thriftWriter)
+	t.i32(1, parquetFormatVersion)
+
+	t.beginList(2, thriftStruct, len(parquetSchema)+1)
+	t.beginListStruct()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(parquetSchema)))
+	t.endStruct()
+	for _, column := range parquetSchema {
+		t.beginListStruct()
+		t.i32(1, column.typ)
+		t.i32(3, column.repetition)
+		t.binary(4, column.name)
+		if column.utf8 {
+			t.i32(6, parquetConvertedUTF8)
+			t.beginStruct(10)
+			t.beginStruct(parquetLogicalString)
+			t.endStruct()
+			t.endStruct()
+		}
+		if column.timestamp {
+			t.beginStruct(10)
+			t.beginStruct(parquetLogicalTime)
+			t.bool(1, true)
+			t.beginStruct(2)
+			t.beginStruct(parquetTimeUnitNanos)
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, p.numRows)
+
+	t.beginList(4, thriftStruct, len(p.rowGroups))
+	for _, group := range p.rowGroups {
+		t.beginListStruct()
+		t.beginList(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			column := parquetSchema[i]
+			t.beginListStruct()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			t.i32(1, column.typ)
+			encodings := []int32{parquetEncodingPlain, parquetEncodingRLE}
+			t.beginList(2, thriftI32, len(encodings))
+			for _, encoding := range encodings {
+				t.listI32(encoding)
+			}
+			t.beginList(3, thriftBinary, 1)
+			t.listBinary(column.name)
+			t.i32(4, parquetCodecGzip)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.totalSize)
+		t.i64(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.binary(6, parquetCreatedBy)
+	t.stop()
+	return t.buf.Bytes()
+}
+
+func writePlainByteArray(buf *bytes.Buffer, s string) {
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(s)))
+	buf.Write(length)
+	buf.WriteString(s)
+}
+
+// encodeDefinitionLevels encodes the definition levels of an optional column
+// (true if the value is set) as RLE runs with a bit width of 1.
+func encodeDefinitionLevels(levels []bool) []byte {
+	var buf []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		buf = appendUvarint(buf, uint64(j-i)<<1)
+		if levels[i] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		i = j
+	}
+	return buf
+}
+
+// packBits encodes booleans with the PLAIN encoding: one bit per value,
+// least significant bit first.
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// Thrift compact protocol types.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol.
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField int16
+	stack     []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	delta := id - t.lastField
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.lastField = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(appendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.field(id, thriftBoolTrue)
+	} else {
+		t.field(id, thriftBoolFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.listBinary(v)
+}
+
+func (t *thriftWriter) beginList(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.buf.Write(appendUvarint(nil, uint64(size)))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) listBinary(v string) {
+	t.buf.Write(appendUvarint(nil, uint64(len(v))))
+	t.buf.WriteString(v)
+}
+
+// beginStruct begins a struct field.
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginListStruct()
+}
+
+// beginListStruct begins a struct list element.
+func (t *thriftWriter) beginListStruct() {
+	t.stack = append(t.stack, t.lastField)
+	t.lastField = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastField = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop ends the top-level struct.
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
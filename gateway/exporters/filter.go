@@ -0,0 +1,280 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/maintenance"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// Filter selects the notifications that are sent to an exporter. A
+// notification is exported if its target and at least one of its update or
+// delete paths pass the filter. Paths pass if they are below an include path
+// (or there are no include paths) and aren't below an exclude path. Targets
+// pass if they match an include target pattern (or there are none) and don't
+// match an exclude target pattern. While a target is in a maintenance window
+// its paths must also not be below a maintenance exclude path.
+type Filter struct {
+	includePaths     *pathTrie
+	excludePaths     *pathTrie
+	includeTargets   []string
+	excludeTargets   []string
+	maintenancePaths *pathTrie
+	windows          *maintenance.Windows
+	now              func() time.Time
+}
+
+// NewFilter compiles the filter configuration. The maintenance exclude paths
+// are applied to the targets in the maintenance windows. A nil Filter is
+// returned if the configuration is empty.
+func NewFilter(config configuration.ExporterFilter, windows *maintenance.Windows) (*Filter, error) {
+	if len(config.IncludePaths) == 0 && len(config.ExcludePaths) == 0 &&
+		len(config.IncludeTargets) == 0 && len(config.ExcludeTargets) == 0 &&
+		len(config.MaintenanceExcludePaths) == 0 {
+		return nil, nil
+	}
+	f := &Filter{
+		includeTargets: config.IncludeTargets,
+		excludeTargets: config.ExcludeTargets,
+		windows:        windows,
+		now:            time.Now,
+	}
+	for _, pattern := range append(append([]string{}, config.IncludeTargets...), config.ExcludeTargets...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid target pattern '%s': %v", pattern, err)
+		}
+	}
+	var err error
+	if f.includePaths, err = newPathTrie(config.IncludePaths); err != nil {
+		return nil, err
+	}
+	if f.excludePaths, err = newPathTrie(config.ExcludePaths); err != nil {
+		return nil, err
+	}
+	if f.maintenancePaths, err = newPathTrie(config.MaintenanceExcludePaths); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Wrap returns an Export function that only calls export with the
+// notifications that pass the filter.
+func (f *Filter) Wrap(name string, export func(leaf *ctree.Leaf)) func(leaf *ctree.Leaf) {
+	if f == nil {
+		return export
+	}
+	filtered := stats.Registry.Counter("gnmigateway.exporters.filtered", map[string]string{"exporter": name})
+	return func(leaf *ctree.Leaf) {
+		notification, ok := leaf.Value().(*gnmipb.Notification)
+		if ok && !f.Match(notification) {
+			filtered.Increment()
+			return
+		}
+		export(leaf)
+	}
+}
+
+// Match returns true if the notification passes the filter.
+func (f *Filter) Match(notification *gnmipb.Notification) bool {
+	target := notification.GetPrefix().GetTarget()
+	if len(f.includeTargets) > 0 && !matchTarget(f.includeTargets, target) {
+		return false
+	}
+	if matchTarget(f.excludeTargets, target) {
+		return false
+	}
+	inMaintenance := f.maintenancePaths != nil && f.windows.Active(target, f.now()) != ""
+	if f.includePaths == nil && f.excludePaths == nil && !inMaintenance {
+		return true
+	}
+
+	prefix := notification.GetPrefix().GetElem()
+	for _, update := range notification.Update {
+		if f.matchPath(prefix, update.GetPath().GetElem(), inMaintenance) {
+			return true
+		}
+	}
+	for _, p := range notification.Delete {
+		if f.matchPath(prefix, p.GetElem(), inMaintenance) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) matchPath(prefix []*gnmipb.PathElem, elems []*gnmipb.PathElem, inMaintenance bool) bool {
+	return utils.MatchJoinedPath(prefix, elems, func(elems []*gnmipb.PathElem) bool {
+		return f.matchElems(elems, inMaintenance)
+	})
+}
+
+func (f *Filter) matchElems(elems []*gnmipb.PathElem, inMaintenance bool) bool {
+	if f.includePaths != nil && !f.includePaths.match(elems) {
+		return false
+	}
+	if inMaintenance && f.maintenancePaths.match(elems) {
+		return false
+	}
+	return f.excludePaths == nil || !f.excludePaths.match(elems)
+}
+
+func matchTarget(patterns []string, target string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// pathTrie is a compiled set of path prefixes. Elem names and key values may
+// be "*" to match any value and keys that aren't in a pattern match any key
+// value, e.g. "/interfaces/interface[name=*]/state/counters" and
+// "/interfaces/interface/state/counters" are equivalent.
+type pathTrie struct {
+	root *pathTrieNode
+}
+
+type pathTrieNode struct {
+	// children is indexed by elem name (or "*").
+	children map[string][]*pathTrieEdge
+	// terminal is true if a pattern ends at this node.
+	terminal bool
+}
+
+type pathTrieEdge struct {
+	// keys contains the key values that an elem must have to follow this
+	// edge. It's nil if there are no key constraints.
+	keys    map[string]string
+	keysStr string
+	node    *pathTrieNode
+}
+
+// newPathTrie compiles the XPath patterns. A nil trie is returned if there
+// are no patterns.
+func newPathTrie(patterns []string) (*pathTrie, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	t := &pathTrie{root: newPathTrieNode()}
+	for _, pattern := range patterns {
+		p, err := utils.WildcardXPathToGNMIPath(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path filter '%s': %v", pattern, err)
+		}
+		t.insert(p.Elem)
+	}
+	return t, nil
+}
+
+func newPathTrieNode() *pathTrieNode {
+	return &pathTrieNode{children: make(map[string][]*pathTrieEdge)}
+}
+
+func (t *pathTrie) insert(elems []*gnmipb.PathElem) {
+	node := t.root
+	for _, elem := range elems {
+		keys := constrainedKeys(elem.Key)
+		keysStr := keysString(keys)
+		var next *pathTrieNode
+		for _, edge := range node.children[elem.Name] {
+			if edge.keysStr == keysStr {
+				next = edge.node
+				break
+			}
+		}
+		if next == nil {
+			next = newPathTrieNode()
+			node.children[elem.Name] = append(node.children[elem.Name], &pathTrieEdge{
+				keys:    keys,
+				keysStr: keysStr,
+				node:    next,
+			})
+		}
+		node = next
+	}
+	node.terminal = true
+}
+
+// match returns true if a pattern in the trie is a prefix of the path.
+func (t *pathTrie) match(elems []*gnmipb.PathElem) bool {
+	return t.root.match(elems)
+}
+
+func (n *pathTrieNode) match(elems []*gnmipb.PathElem) bool {
+	if n.terminal {
+		return true
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	elem := elems[0]
+	for _, name := range [2]string{elem.Name, "*"} {
+		for _, edge := range n.children[name] {
+			if matchKeys(edge.keys, elem.Key) && edge.node.match(elems[1:]) {
+				return true
+			}
+		}
+		if elem.Name == "*" {
+			break
+		}
+	}
+	return false
+}
+
+// constrainedKeys returns the keys that aren't wildcards.
+func constrainedKeys(keys map[string]string) map[string]string {
+	var constrained map[string]string
+	for k, v := range keys {
+		if v == "*" {
+			continue
+		}
+		if constrained == nil {
+			constrained = make(map[string]string)
+		}
+		constrained[k] = v
+	}
+	return constrained
+}
+
+func keysString(keys map[string]string) string {
+	pairs := make([]string, 0, len(keys))
+	for k, v := range keys {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func matchKeys(constraints map[string]string, keys map[string]string) bool {
+	for k, v := range constraints {
+		if keys[k] != v {
+			return false
+		}
+	}
+	return true
+}
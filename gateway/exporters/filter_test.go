@@ -0,0 +1,164 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/maintenance"
+)
+
+func notificationFor(t *testing.T, target string, p string) *gnmipb.Notification {
+	path, err := xpath.ToGNMIPath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: target},
+		Update: []*gnmipb.Update{{Path: path}},
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	filter, err := NewFilter(configuration.ExporterFilter{
+		IncludePaths: []string{
+			"/interfaces/interface/state/counters",
+			"/network-instances/network-instance[name=default]/protocols/protocol/bgp",
+			"/system/*/state",
+		},
+		ExcludePaths:   []string{"/interfaces/interface[name=mgmt0]"},
+		IncludeTargets: []string{"core-*", "edge1"},
+		ExcludeTargets: []string{"core-lab*"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		path   string
+		match  bool
+	}{
+		{"core-1", "/interfaces/interface[name=eth0]/state/counters/in-octets", true},
+		{"edge1", "/interfaces/interface[name=eth0]/state/counters", true},
+		{"core-1", "/interfaces/interface[name=eth0]/state/oper-status", false},
+		{"core-1", "/interfaces/interface[name=mgmt0]/state/counters/in-octets", false},
+		{"core-1", "/network-instances/network-instance[name=default]/protocols/protocol[name=BGP]/bgp/neighbors", true},
+		{"core-1", "/network-instances/network-instance[name=vrf1]/protocols/protocol[name=BGP]/bgp/neighbors", false},
+		{"core-1", "/system/cpus/state/total", true},
+		{"core-1", "/system/cpus/config", false},
+		{"edge2", "/interfaces/interface[name=eth0]/state/counters", false},
+		{"core-lab1", "/interfaces/interface[name=eth0]/state/counters", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.match, filter.Match(notificationFor(t, test.target, test.path)), "%s %s", test.target, test.path)
+	}
+}
+
+func TestFilter_MatchPrefix(t *testing.T) {
+	filter, err := NewFilter(configuration.ExporterFilter{IncludePaths: []string{"/interfaces/interface/state"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a", Elem: []*gnmipb.PathElem{{Name: "interfaces"}}},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{{Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "state"}}}},
+	}
+	assert.True(t, filter.Match(notification))
+}
+
+func BenchmarkFilter_Match(b *testing.B) {
+	filter, err := NewFilter(configuration.ExporterFilter{
+		IncludePaths: []string{"/interfaces/interface/state/counters"},
+		ExcludePaths: []string{"/interfaces/interface[name=mgmt0]"},
+	}, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	notification := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a", Elem: []*gnmipb.PathElem{{Name: "interfaces"}}},
+		Update: []*gnmipb.Update{{Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{
+			{Name: "interface", Key: map[string]string{"name": "eth0"}},
+			{Name: "state"},
+			{Name: "counters"},
+			{Name: "in-octets"},
+		}}}},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.Match(notification)
+	}
+}
+
+func TestFilter_Wrap(t *testing.T) {
+	var exported int
+	export := func(leaf *ctree.Leaf) { exported++ }
+
+	var nilFilter *Filter
+	nilFilter.Wrap("test", export)(ctree.DetachedLeaf(notificationFor(t, "a", "/b")))
+	assert.Equal(t, 1, exported)
+
+	filter, err := NewFilter(configuration.ExporterFilter{ExcludeTargets: []string{"a"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := filter.Wrap("test", export)
+	wrapped(ctree.DetachedLeaf(notificationFor(t, "a", "/b")))
+	wrapped(ctree.DetachedLeaf(notificationFor(t, "c", "/b")))
+	assert.Equal(t, 2, exported)
+}
+
+func TestNewFilter_Empty(t *testing.T) {
+	filter, err := NewFilter(configuration.ExporterFilter{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, filter)
+}
+
+func TestFilter_MatchMaintenance(t *testing.T) {
+	windows, err := maintenance.New([]configuration.MaintenanceWindow{{
+		Name:     "weekly",
+		Targets:  []string{"core-*"},
+		Schedule: "0 2 * * 6",
+		Duration: time.Hour,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := NewFilter(configuration.ExporterFilter{
+		MaintenanceExcludePaths: []string{"/interfaces/interface/state/oper-status"},
+	}, windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operStatus := "/interfaces/interface[name=eth0]/state/oper-status"
+	counters := "/interfaces/interface[name=eth0]/state/counters/in-octets"
+	filter.now = func() time.Time { return time.Date(2021, 1, 2, 2, 30, 0, 0, time.UTC) }
+	assert.False(t, filter.Match(notificationFor(t, "core-1", operStatus)))
+	assert.True(t, filter.Match(notificationFor(t, "core-1", counters)))
+	assert.True(t, filter.Match(notificationFor(t, "edge-1", operStatus)))
+
+	filter.now = func() time.Time { return time.Date(2021, 1, 2, 3, 30, 0, 0, time.UTC) }
+	assert.True(t, filter.Match(notificationFor(t, "core-1", operStatus)))
+}
@@ -22,15 +22,15 @@ import (
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 	"github.com/openconfig/gnmi-gateway/gateway/utils"
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/ctree"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 )
 
 const Name = "influxdb"
 
-var _ exporters.Exporter = new(InfluxDBExporter)
+var _ exporters.LabelExporter = new(InfluxDBExporter)
 
 type Point struct {
 	Tags        map[string]string
@@ -51,9 +51,16 @@ func NewInfluxDBExporter(config *configuration.GatewayConfig) exporters.Exporter
 }
 
 type InfluxDBExporter struct {
-	cache  *cache.Cache
+	cache  *shardedcache.Cache
 	config *configuration.GatewayConfig
 	client influxdb2.Client
+	labels func(target string) map[string]string
+}
+
+// SetLabels implements exporters.LabelExporter. The inventory labels are
+// added to the tags of every point.
+func (e *InfluxDBExporter) SetLabels(labels func(target string) map[string]string) {
+	e.labels = labels
 }
 
 func (e *InfluxDBExporter) Name() string {
@@ -100,6 +107,11 @@ func (e *InfluxDBExporter) Export(leaf *ctree.Leaf) {
 			if target != "" {
 				keys["target"] = target
 			}
+			if e.labels != nil {
+				for name, value := range e.labels(target) {
+					keys[name] = value
+				}
+			}
 
 			point.Measurement = pathToMetricName(path)
 			point.Tags = keys
@@ -132,7 +144,7 @@ func (e *InfluxDBExporter) Export(leaf *ctree.Leaf) {
 	writer.WritePoint(p)
 }
 
-func (e *InfluxDBExporter) Start(cache *cache.Cache) error {
+func (e *InfluxDBExporter) Start(cache *shardedcache.Cache) error {
 	_ = cache
 	e.config.Log.Info().Msg("Starting InfluxDBv2 exporter.")
 
@@ -162,6 +174,13 @@ func (e *InfluxDBExporter) Start(cache *cache.Cache) error {
 	return nil
 }
 
+// Stop flushes buffered points and closes the InfluxDB client.
+func (e *InfluxDBExporter) Stop() {
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
 func pathToMetricName(path string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(path, "/", "_"), "-", "_")
 }
@@ -20,7 +20,6 @@ import (
 	"errors"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/ctree"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/rs/zerolog"
@@ -28,12 +27,14 @@ import (
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 	"github.com/openconfig/gnmi-gateway/gateway/utils"
 )
 
 const Name = "kafka"
 
-var _ exporters.Exporter = new(KafkaExporter)
+var _ exporters.LabelExporter = new(KafkaExporter)
+var _ exporters.ConcurrentExporter = new(KafkaExporter)
 
 func init() {
 	exporters.Register(Name, NewKafkaExporter)
@@ -47,14 +48,25 @@ func NewKafkaExporter(config *configuration.GatewayConfig) exporters.Exporter {
 
 type KafkaExporter struct {
 	config *configuration.GatewayConfig
-	cache  *cache.Cache
+	cache  *shardedcache.Cache
+	labels func(target string) map[string]string
 	writer *kafka.Writer
 }
 
+// SetLabels implements exporters.LabelExporter. The inventory labels are
+// added to the headers of every message.
+func (e *KafkaExporter) SetLabels(labels func(target string) map[string]string) {
+	e.labels = labels
+}
+
 func (e *KafkaExporter) Name() string {
 	return Name
 }
 
+// ConcurrentExport implements exporters.ConcurrentExporter.
+// kafka.Writer is safe for concurrent use.
+func (e *KafkaExporter) ConcurrentExport() {}
+
 func (e *KafkaExporter) Export(leaf *ctree.Leaf) {
 	notification := leaf.Value().(*gnmipb.Notification)
 
@@ -64,18 +76,22 @@ func (e *KafkaExporter) Export(leaf *ctree.Leaf) {
 		return
 	}
 
-	err = e.writer.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:   []byte(utils.PathToXPath(notification.Prefix)),
-			Value: data,
-		},
-	)
+	message := kafka.Message{
+		Key:   []byte(utils.PathToXPath(notification.Prefix)),
+		Value: data,
+	}
+	if e.labels != nil {
+		for name, value := range e.labels(notification.GetPrefix().GetTarget()) {
+			message.Headers = append(message.Headers, kafka.Header{Key: name, Value: []byte(value)})
+		}
+	}
+	err = e.writer.WriteMessages(context.Background(), message)
 	if err != nil {
 		e.config.Log.Warn().Msgf("failed to write message to Kafka: %s", err)
 	}
 }
 
-func (e *KafkaExporter) Start(cache *cache.Cache) error {
+func (e *KafkaExporter) Start(cache *shardedcache.Cache) error {
 	e.config.Log.Info().Msg("Starting Kafka exporter.")
 
 	if e.config.Exporters.KafkaTopic == "" {
@@ -110,6 +126,16 @@ func (e *KafkaExporter) Start(cache *cache.Cache) error {
 	return nil
 }
 
+// Stop flushes buffered messages and closes the Kafka writer.
+func (e *KafkaExporter) Stop() {
+	if e.writer == nil {
+		return
+	}
+	if err := e.writer.Close(); err != nil {
+		e.config.Log.Warn().Msgf("failed to close Kafka writer: %s", err)
+	}
+}
+
 type kafkaLogger struct {
 	config *configuration.GatewayConfig
 	level  zerolog.Level
@@ -0,0 +1,228 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kinesis provides an exporter that writes gNMI notifications to an
+// AWS Kinesis data stream. Notifications are written in batches with
+// PutRecords as serialized gnmi.Notification messages with the target name as
+// the partition key, so that the records for each target are in the same
+// shard. Records that fail are retried.
+//
+// Credentials are found in the same order as the AWS SDKs' default
+// credential chain; see aws.DefaultCredentials.
+package kinesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/aws"
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+const Name = "kinesis"
+
+const (
+	// maxBatchSize is the max number of records in a PutRecords request.
+	maxBatchSize = 500
+	maxAttempts  = 5
+	// defaultPartitionKey is used for notifications without a target.
+	defaultPartitionKey = "gnmi-gateway"
+)
+
+var _ exporters.Exporter = new(KinesisExporter)
+
+func init() {
+	exporters.Register(Name, NewKinesisExporter)
+}
+
+func NewKinesisExporter(config *configuration.GatewayConfig) exporters.Exporter {
+	return &KinesisExporter{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type KinesisExporter struct {
+	config      *configuration.GatewayConfig
+	cache       *shardedcache.Cache
+	client      *http.Client
+	credentials aws.CredentialsProvider
+	batcher     *exporters.Batcher
+	endpoint    string
+	region      string
+}
+
+type putRecordsEntry struct {
+	// Data is base64 encoded by encoding/json.
+	Data         []byte `json:"Data"`
+	PartitionKey string `json:"PartitionKey"`
+}
+
+type putRecordsRequest struct {
+	Records    []putRecordsEntry `json:"Records"`
+	StreamName string            `json:"StreamName"`
+}
+
+type putRecordsResponse struct {
+	FailedRecordCount int `json:"FailedRecordCount"`
+	Records           []struct {
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	} `json:"Records"`
+}
+
+func (e *KinesisExporter) Name() string {
+	return Name
+}
+
+func (e *KinesisExporter) Export(leaf *ctree.Leaf) {
+	notification := leaf.Value().(*gnmipb.Notification)
+	data, err := proto.Marshal(notification)
+	if err != nil {
+		e.config.Log.Warn().Msgf("failed to marshal message for Kinesis: %s", err)
+		return
+	}
+	partitionKey := notification.GetPrefix().GetTarget()
+	if partitionKey == "" {
+		partitionKey = defaultPartitionKey
+	}
+	e.batcher.Add(putRecordsEntry{Data: data, PartitionKey: partitionKey})
+}
+
+func (e *KinesisExporter) Start(cache *shardedcache.Cache) error {
+	e.config.Log.Info().Msg("Starting Kinesis exporter.")
+	if e.config.Exporters.KinesisStream == "" {
+		return errors.New("configuration option for Kinesis Stream is not set")
+	}
+	e.region = aws.Region(e.config.Exporters.KinesisRegion)
+	if e.region == "" {
+		return errors.New("configuration option for Kinesis Region is not set and AWS_REGION is empty")
+	}
+	e.endpoint = e.config.Exporters.KinesisEndpoint
+	if e.endpoint == "" {
+		e.endpoint = "https://kinesis." + e.region + ".amazonaws.com/"
+	}
+	if e.credentials == nil {
+		e.credentials = aws.NewDefaultCredentials(&http.Client{Timeout: 5 * time.Second}, e.region)
+	}
+
+	batchSize := e.config.Exporters.KinesisBatchSize
+	if batchSize <= 0 || batchSize > maxBatchSize {
+		batchSize = maxBatchSize
+	}
+	batchTimeout := e.config.Exporters.KinesisBatchTimeout
+	if batchTimeout <= 0 {
+		batchTimeout = time.Second
+	}
+	e.cache = cache
+	e.batcher = exporters.NewBatcher(batchSize, batchTimeout, e.putRecords)
+	return nil
+}
+
+// Stop sends the remaining buffered records.
+func (e *KinesisExporter) Stop() {
+	if e.batcher != nil {
+		e.batcher.Close()
+	}
+}
+
+// putRecords writes a batch of records and retries the records that fail.
+func (e *KinesisExporter) putRecords(batch []interface{}) {
+	records := make([]putRecordsEntry, 0, len(batch))
+	for _, record := range batch {
+		records = append(records, record.(putRecordsEntry))
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts && len(records) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * time.Second)
+		}
+		var failed []putRecordsEntry
+		failed, err = e.send(records)
+		if err != nil {
+			stats.Registry.Counter("gnmigateway.exporters.kinesis.put_errors", stats.NoTags).Increment()
+			continue
+		}
+		stats.Registry.Counter("gnmigateway.exporters.kinesis.published", stats.NoTags).Add(int64(len(records) - len(failed)))
+		if len(failed) > 0 {
+			err = fmt.Errorf("%d records failed", len(failed))
+		}
+		records = failed
+	}
+	if len(records) > 0 {
+		stats.Registry.Counter("gnmigateway.exporters.kinesis.dropped", stats.NoTags).Add(int64(len(records)))
+		e.config.Log.Warn().Msgf("failed to write %d records to Kinesis: %s", len(records), err)
+	}
+}
+
+// send calls PutRecords and returns the records that failed.
+func (e *KinesisExporter) send(records []putRecordsEntry) ([]putRecordsEntry, error) {
+	creds, err := e.credentials.Retrieve()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get AWS credentials: %v", err)
+	}
+	body, err := json.Marshal(putRecordsRequest{Records: records, StreamName: e.config.Exporters.KinesisStream})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecords")
+	aws.SignV4(req, body, creds, e.region, "kinesis", time.Now())
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("PutRecords failed with %s: %s", resp.Status, respBody)
+	}
+
+	var result putRecordsResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("unable to parse PutRecords response: %v", err)
+	}
+	if result.FailedRecordCount == 0 {
+		return nil, nil
+	}
+	var failed []putRecordsEntry
+	for i, record := range result.Records {
+		if record.ErrorCode != "" && i < len(records) {
+			failed = append(failed, records[i])
+		}
+	}
+	return failed, nil
+}
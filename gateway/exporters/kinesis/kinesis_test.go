@@ -0,0 +1,80 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kinesis
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/aws"
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+type staticCredentials aws.Credentials
+
+func (s *staticCredentials) Retrieve() (*aws.Credentials, error) {
+	creds := aws.Credentials(*s)
+	return &creds, nil
+}
+
+func TestKinesisExporter_PutRecords(t *testing.T) {
+	assertion := assert.New(t)
+
+	var requests []putRecordsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal("Kinesis_20131202.PutRecords", r.Header.Get("X-Amz-Target"))
+		assertion.True(strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assertion.Equal("token", r.Header.Get("X-Amz-Security-Token"))
+		var req putRecordsRequest
+		assertion.NoError(json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		if len(requests) == 1 {
+			// Fail the second record of the first request.
+			_, _ = w.Write([]byte(`{"FailedRecordCount":1,"Records":[{"SequenceNumber":"1"},{"ErrorCode":"ProvisionedThroughputExceededException"}]}`))
+		} else {
+			_, _ = w.Write([]byte(`{"FailedRecordCount":0,"Records":[{"SequenceNumber":"2"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	config := &configuration.GatewayConfig{
+		Exporters: &configuration.ExportersConfig{
+			KinesisEndpoint: server.URL,
+			KinesisRegion:   "us-east-1",
+			KinesisStream:   "gnmi",
+		},
+		Log: configuration.NewDefaultGatewayConfig().Log,
+	}
+	e := NewKinesisExporter(config).(*KinesisExporter)
+	e.credentials = &staticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}
+	assertion.NoError(e.Start(nil))
+
+	e.putRecords([]interface{}{
+		putRecordsEntry{Data: []byte("a"), PartitionKey: "dev1"},
+		putRecordsEntry{Data: []byte("b"), PartitionKey: "dev2"},
+	})
+
+	if assertion.Len(requests, 2) {
+		assertion.Equal("gnmi", requests[0].StreamName)
+		assertion.Len(requests[0].Records, 2)
+		assertion.Equal([]putRecordsEntry{{Data: []byte("b"), PartitionKey: "dev2"}}, requests[1].Records)
+	}
+}
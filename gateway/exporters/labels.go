@@ -0,0 +1,67 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"errors"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Labeler provides the inventory labels of targets that are passed to
+// LabelExporters.
+type Labeler interface {
+	// Start loads the labels. If Start returns an error the gateway will
+	// fail to start with an error.
+	Start() error
+	// Stop is called when the gateway is stopped.
+	Stop()
+	// Labels returns the labels of target. The returned map must not be
+	// modified.
+	Labels(target string) map[string]string
+}
+
+// newLabeler is set by the inventory package when it's included in the
+// binary.
+var newLabeler func(config *configuration.GatewayConfig) Labeler
+
+// RegisterLabeler sets the function that creates the Labeler for the
+// inventory configuration. new must return nil if no inventory is
+// configured.
+func RegisterLabeler(new func(config *configuration.GatewayConfig) Labeler) {
+	newLabeler = new
+}
+
+// ValidateLabeler returns an error if InventoryFile or InventoryNetBox is set
+// but no Labeler is registered.
+func ValidateLabeler(config *configuration.GatewayConfig) error {
+	if (config.InventoryFile != "" || config.InventoryNetBox) && newLabeler == nil {
+		return errors.New("the inventory package isn't included in this build")
+	}
+	return nil
+}
+
+// NewLabeler returns the Labeler for the inventory configuration or nil if no
+// inventory is configured.
+func NewLabeler(config *configuration.GatewayConfig) (Labeler, error) {
+	if err := ValidateLabeler(config); err != nil {
+		return nil, err
+	}
+	if newLabeler == nil {
+		return nil, nil
+	}
+	return newLabeler(config), nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+type staticLabeler map[string]map[string]string
+
+func (s staticLabeler) Start() error                           { return nil }
+func (s staticLabeler) Stop()                                  {}
+func (s staticLabeler) Labels(target string) map[string]string { return s[target] }
+
+func TestNewLabeler(t *testing.T) {
+	assertion := assert.New(t)
+	defer RegisterLabeler(newLabeler)
+
+	RegisterLabeler(nil)
+	labeler, err := NewLabeler(&configuration.GatewayConfig{})
+	assertion.NoError(err)
+	assertion.Nil(labeler)
+	_, err = NewLabeler(&configuration.GatewayConfig{InventoryFile: "inventory.json"})
+	assertion.Error(err, "inventory configured without a registered Labeler")
+	assertion.Error(ValidateLabeler(&configuration.GatewayConfig{InventoryNetBox: true}))
+
+	RegisterLabeler(func(config *configuration.GatewayConfig) Labeler {
+		return staticLabeler{"router1": {"site": "ams1"}}
+	})
+	assertion.NoError(ValidateLabeler(&configuration.GatewayConfig{InventoryFile: "inventory.json"}))
+	labeler, err = NewLabeler(&configuration.GatewayConfig{InventoryFile: "inventory.json"})
+	if assertion.NoError(err) && assertion.NotNil(labeler) {
+		assertion.Equal("ams1", labeler.Labels("router1")["site"])
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nats
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPort = "4222"
+
+var errConnectionClosed = errors.New("NATS connection closed")
+
+// conn is a minimal NATS client connection that can publish messages and
+// receive JetStream publish acknowledgements on a per-connection inbox.
+type conn struct {
+	netConn net.Conn
+	inbox   string
+
+	writeMutex sync.Mutex
+	writer     *bufio.Writer
+
+	mutex   sync.Mutex
+	nextID  uint64
+	pending map[string]chan error
+	err     error
+	closed  chan struct{}
+}
+
+type connectOptions struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	Protocol int    `json:"protocol"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// pubAck is the JetStream response to a publish.
+type pubAck struct {
+	Stream string `json:"stream"`
+	Seq    uint64 `json:"seq"`
+	Error  *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// dial connects to the NATS server at the nats:// or tls:// URL. Credentials
+// may be provided in the URL as user:password or as a single token.
+func dial(rawURL string, timeout time.Duration) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL '%s': %v", rawURL, err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var netConn net.Conn
+	switch u.Scheme {
+	case "nats":
+		netConn, err = dialer.Dial("tcp", host)
+	case "tls":
+		netConn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported NATS URL scheme '%s'", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := handshake(netConn, u, timeout)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func handshake(netConn net.Conn, u *url.URL, timeout time.Duration) (*conn, error) {
+	_ = netConn.SetDeadline(time.Now().Add(timeout))
+	reader := bufio.NewReader(netConn)
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return nil, fmt.Errorf("unexpected NATS greeting: %s", line)
+	}
+
+	options := connectOptions{Name: "gnmi-gateway", Lang: "go", Version: "1.0.0", Protocol: 1}
+	if u.User != nil {
+		if password, set := u.User.Password(); set {
+			options.User = u.User.Username()
+			options.Pass = password
+		} else {
+			options.Token = u.User.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	inboxID := make([]byte, 8)
+	_, _ = rand.Read(inboxID)
+	c := &conn{
+		netConn: netConn,
+		inbox:   "_INBOX." + hex.EncodeToString(inboxID),
+		writer:  bufio.NewWriter(netConn),
+		pending: make(map[string]chan error),
+		closed:  make(chan struct{}),
+	}
+	_, _ = fmt.Fprintf(c.writer, "CONNECT %s\r\nPING\r\nSUB %s.* 1\r\n", connect, c.inbox)
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	// The server answers the PING with PONG once CONNECT has been accepted.
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return nil, fmt.Errorf("NATS connect failed: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+	_ = netConn.SetDeadline(time.Time{})
+	go c.readLoop(reader)
+	return c, nil
+}
+
+// publish sends a message to the subject with a reply inbox. The returned
+// channel receives the result of the JetStream acknowledgement sent to the
+// returned reply subject.
+func (c *conn) publish(subject string, data []byte) (string, chan error, error) {
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return "", nil, c.err
+	}
+	c.nextID++
+	reply := c.inbox + "." + strconv.FormatUint(c.nextID, 10)
+	ack := make(chan error, 1)
+	c.pending[reply] = ack
+	c.mutex.Unlock()
+
+	c.writeMutex.Lock()
+	_, _ = fmt.Fprintf(c.writer, "PUB %s %s %d\r\n", subject, reply, len(data))
+	_, _ = c.writer.Write(data)
+	_, _ = c.writer.WriteString("\r\n")
+	err := c.writer.Flush()
+	c.writeMutex.Unlock()
+	if err != nil {
+		c.close(err)
+		return "", nil, err
+	}
+	return reply, ack, nil
+}
+
+// cancel stops waiting for the acknowledgement sent to reply.
+func (c *conn) cancel(reply string) {
+	c.mutex.Lock()
+	delete(c.pending, reply)
+	c.mutex.Unlock()
+}
+
+func (c *conn) readLoop(reader *bufio.Reader) {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				c.close(fmt.Errorf("malformed NATS message: %s", line))
+				return
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				c.close(fmt.Errorf("malformed NATS message: %s", line))
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				c.close(err)
+				return
+			}
+			c.resolve(fields[1], payload[:size])
+		case line == "PING":
+			c.writeMutex.Lock()
+			_, _ = c.writer.WriteString("PONG\r\n")
+			err := c.writer.Flush()
+			c.writeMutex.Unlock()
+			if err != nil {
+				c.close(err)
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			c.close(fmt.Errorf("NATS server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+			return
+		}
+	}
+}
+
+func (c *conn) resolve(reply string, payload []byte) {
+	c.mutex.Lock()
+	ack, exists := c.pending[reply]
+	delete(c.pending, reply)
+	c.mutex.Unlock()
+	if !exists {
+		return
+	}
+
+	var result pubAck
+	if err := json.Unmarshal(payload, &result); err != nil {
+		ack <- fmt.Errorf("invalid JetStream acknowledgement: %v", err)
+	} else if result.Error != nil {
+		ack <- fmt.Errorf("JetStream error %d: %s", result.Error.Code, result.Error.Description)
+	} else if result.Stream == "" {
+		ack <- errors.New("JetStream acknowledgement is missing the stream name")
+	} else {
+		ack <- nil
+	}
+}
+
+// close closes the connection and fails every pending publish with err.
+func (c *conn) close(err error) {
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return
+	}
+	if err == nil {
+		err = errConnectionClosed
+	}
+	c.err = err
+	pending := c.pending
+	c.pending = make(map[string]chan error)
+	close(c.closed)
+	c.mutex.Unlock()
+
+	_ = c.netConn.Close()
+	for _, ack := range pending {
+		ack <- err
+	}
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
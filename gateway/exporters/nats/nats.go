@@ -0,0 +1,296 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nats provides an exporter that publishes gNMI notifications to NATS
+// JetStream. Each notification is published as a serialized
+// gnmi.Notification to a subject rendered from the NATSSubjectTemplate and is
+// retried until it is acknowledged by JetStream (at-least-once delivery). A
+// JetStream stream must be configured to capture the published subjects.
+//
+// The subject template is a Go text/template with the fields:
+//		.Target - the target name from the notification prefix.
+//		.Origin - the origin from the notification prefix.
+//		.Path   - the path element names, separated by ".".
+// Characters that are not valid in a NATS subject token ('.', '*', '>', and
+// whitespace) are replaced with '_' in each field (or element of .Path).
+package nats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+const Name = "nats"
+
+const (
+	DefaultSubjectTemplate = "gnmi.{{.Target}}.{{.Path}}"
+	defaultAckTimeout      = 5 * time.Second
+	// maxInFlight is the max number of publishes waiting for acknowledgement.
+	maxInFlight = 256
+	// maxAttempts is the number of times a message is published before it is
+	// dropped.
+	maxAttempts = 5
+	queueSize   = 10000
+)
+
+var _ exporters.ConcurrentExporter = new(NATSExporter)
+
+func init() {
+	exporters.Register(Name, NewNATSExporter)
+}
+
+func NewNATSExporter(config *configuration.GatewayConfig) exporters.Exporter {
+	return &NATSExporter{
+		config:   config,
+		inFlight: make(chan struct{}, maxInFlight),
+		queue:    make(chan *gnmipb.Notification, queueSize),
+		stop:     make(chan struct{}),
+	}
+}
+
+type NATSExporter struct {
+	config   *configuration.GatewayConfig
+	cache    *shardedcache.Cache
+	subject  *template.Template
+	inFlight chan struct{}
+	queue    chan *gnmipb.Notification
+
+	mutex sync.Mutex
+	conn  *conn
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// subjectFields are the fields available to the subject template.
+type subjectFields struct {
+	Target string
+	Origin string
+	Path   string
+}
+
+func (e *NATSExporter) Name() string {
+	return Name
+}
+
+// ConcurrentExport implements exporters.ConcurrentExporter.
+// Export only queues the notification.
+func (e *NATSExporter) ConcurrentExport() {}
+
+// Export queues the notification to be published. Export blocks if the queue
+// is full so that messages aren't lost while JetStream is unavailable.
+func (e *NATSExporter) Export(leaf *ctree.Leaf) {
+	select {
+	case e.queue <- leaf.Value().(*gnmipb.Notification):
+	case <-e.stop:
+	}
+}
+
+func (e *NATSExporter) Start(cache *shardedcache.Cache) error {
+	e.config.Log.Info().Msg("Starting NATS JetStream exporter.")
+	if e.config.Exporters.NATSURL == "" {
+		return errors.New("configuration option for NATS URL is not set")
+	}
+	subjectTemplate := e.config.Exporters.NATSSubjectTemplate
+	if subjectTemplate == "" {
+		subjectTemplate = DefaultSubjectTemplate
+	}
+	subject, err := parseSubjectTemplate(subjectTemplate)
+	if err != nil {
+		return err
+	}
+	e.subject = subject
+	e.cache = cache
+	go e.run()
+	return nil
+}
+
+// Stop stops publishing queued notifications and closes the NATS connection.
+func (e *NATSExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		e.mutex.Lock()
+		if e.conn != nil {
+			e.conn.close(errors.New("exporter stopped"))
+			e.conn = nil
+		}
+		e.mutex.Unlock()
+	})
+}
+
+func (e *NATSExporter) run() {
+	for {
+		var notification *gnmipb.Notification
+		select {
+		case notification = <-e.queue:
+		case <-e.stop:
+			return
+		}
+		subject, err := e.renderSubject(notification)
+		if err != nil {
+			e.config.Log.Warn().Msgf("failed to render NATS subject: %s", err)
+			continue
+		}
+		data, err := proto.Marshal(notification)
+		if err != nil {
+			e.config.Log.Warn().Msgf("failed to marshal message for NATS: %s", err)
+			continue
+		}
+		e.inFlight <- struct{}{}
+		go func() {
+			defer func() { <-e.inFlight }()
+			e.publish(subject, data)
+		}()
+	}
+}
+
+// publish publishes the message until it is acknowledged by JetStream or
+// until maxAttempts is reached.
+func (e *NATSExporter) publish(subject string, data []byte) {
+	ackTimeout := e.config.Exporters.NATSAckTimeout
+	if ackTimeout <= 0 {
+		ackTimeout = defaultAckTimeout
+	}
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-e.stop:
+				return
+			}
+		}
+		var c *conn
+		c, err = e.connection(ackTimeout)
+		if err != nil {
+			continue
+		}
+		var reply string
+		var ack chan error
+		reply, ack, err = c.publish(subject, data)
+		if err != nil {
+			e.reset(c)
+			continue
+		}
+		select {
+		case err = <-ack:
+		case <-time.After(ackTimeout):
+			c.cancel(reply)
+			err = fmt.Errorf("timed out waiting for JetStream acknowledgement on '%s'", subject)
+		}
+		if err == nil {
+			stats.Registry.Counter("gnmigateway.exporters.nats.published", stats.NoTags).Increment()
+			return
+		}
+		stats.Registry.Counter("gnmigateway.exporters.nats.publish_errors", stats.NoTags).Increment()
+	}
+	stats.Registry.Counter("gnmigateway.exporters.nats.dropped", stats.NoTags).Increment()
+	e.config.Log.Warn().Msgf("failed to publish message to NATS subject '%s' after %d attempts: %s", subject, maxAttempts, err)
+}
+
+// connection returns the current NATS connection, connecting if necessary.
+func (e *NATSExporter) connection(timeout time.Duration) (*conn, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.conn != nil {
+		select {
+		case <-e.conn.closed:
+			e.config.Log.Warn().Msgf("NATS connection lost: %s", e.conn.err)
+			e.conn = nil
+		default:
+			return e.conn, nil
+		}
+	}
+	c, err := dial(e.config.Exporters.NATSURL, timeout)
+	if err != nil {
+		e.config.Log.Error().Err(err).Msgf("Unable to connect to NATS: %v", err)
+		return nil, err
+	}
+	e.conn = c
+	return c, nil
+}
+
+// reset closes c and clears it if it's still the current connection.
+func (e *NATSExporter) reset(c *conn) {
+	c.close(nil)
+	e.mutex.Lock()
+	if e.conn == c {
+		e.conn = nil
+	}
+	e.mutex.Unlock()
+}
+
+func (e *NATSExporter) renderSubject(notification *gnmipb.Notification) (string, error) {
+	prefix := notification.GetPrefix()
+	var elems []string
+	for _, elem := range prefix.GetElem() {
+		elems = append(elems, subjectToken(elem.Name))
+	}
+	var path *gnmipb.Path
+	if len(notification.Update) > 0 {
+		path = notification.Update[0].GetPath()
+	} else if len(notification.Delete) > 0 {
+		path = notification.Delete[0]
+	}
+	for _, elem := range path.GetElem() {
+		elems = append(elems, subjectToken(elem.Name))
+	}
+
+	var b bytes.Buffer
+	err := e.subject.Execute(&b, subjectFields{
+		Target: subjectToken(prefix.GetTarget()),
+		Origin: subjectToken(prefix.GetOrigin()),
+		Path:   strings.Join(elems, "."),
+	})
+	if err != nil {
+		return "", err
+	}
+	subject := strings.Trim(b.String(), ".")
+	for strings.Contains(subject, "..") {
+		subject = strings.ReplaceAll(subject, "..", ".")
+	}
+	if subject == "" {
+		return "", errors.New("subject is empty")
+	}
+	return subject, nil
+}
+
+func parseSubjectTemplate(text string) (*template.Template, error) {
+	subject, err := template.New("subject").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS subject template: %v", err)
+	}
+	return subject, nil
+}
+
+var subjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_", "\r", "_", "\n", "_")
+
+func subjectToken(s string) string {
+	return subjectReplacer.Replace(s)
+}
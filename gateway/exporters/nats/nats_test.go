@@ -0,0 +1,146 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+type publishedMessage struct {
+	subject string
+	data    []byte
+}
+
+// fakeJetStream is a NATS server that acknowledges every publish with ack.
+func fakeJetStream(t *testing.T, ack string) (string, chan publishedMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := make(chan publishedMessage, 10)
+	go func() {
+		defer listener.Close()
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		reader := bufio.NewReader(c)
+		_, _ = io.WriteString(c, "INFO {\"server_id\":\"test\"}\r\n")
+		for {
+			line, err := readLine(reader)
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "PING":
+				_, _ = io.WriteString(c, "PONG\r\n")
+			case "PUB":
+				size, _ := strconv.Atoi(fields[3])
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(reader, payload); err != nil {
+					return
+				}
+				published <- publishedMessage{subject: fields[1], data: payload[:size]}
+				_, _ = fmt.Fprintf(c, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+			}
+		}
+	}()
+	return "nats://" + listener.Addr().String(), published
+}
+
+func TestNATSExporter_Publish(t *testing.T) {
+	assertion := assert.New(t)
+	natsURL, published := fakeJetStream(t, `{"stream":"GNMI","seq":1}`)
+
+	config := &configuration.GatewayConfig{
+		Exporters: &configuration.ExportersConfig{NATSURL: natsURL},
+		Log:       configuration.NewDefaultGatewayConfig().Log,
+	}
+	e := NewNATSExporter(config).(*NATSExporter)
+	assertion.NoError(e.Start(nil))
+
+	notification := &pb.Notification{
+		Prefix: &pb.Path{Target: "dev1.example.com"},
+		Update: []*pb.Update{{
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "system"}, {Name: "state"}, {Name: "hostname"}}},
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "dev1"}},
+		}},
+	}
+	e.Export(ctree.DetachedLeaf(notification))
+
+	select {
+	case msg := <-published:
+		assertion.Equal("gnmi.dev1_example_com.system.state.hostname", msg.subject)
+		received := new(pb.Notification)
+		assertion.NoError(proto.Unmarshal(msg.data, received))
+		assertion.True(proto.Equal(notification, received))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for publish")
+	}
+}
+
+func TestConn_PublishError(t *testing.T) {
+	natsURL, _ := fakeJetStream(t, `{"error":{"code":503,"description":"no stream"}}`)
+	c, err := dial(natsURL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close(nil)
+
+	_, ack, err := c.publish("gnmi.dev1", []byte("data"))
+	if assert.NoError(t, err) {
+		assert.EqualError(t, <-ack, "JetStream error 503: no stream")
+	}
+}
+
+func TestNATSExporter_RenderSubject(t *testing.T) {
+	config := &configuration.GatewayConfig{
+		Exporters: &configuration.ExportersConfig{
+			NATSURL:             "nats://localhost",
+			NATSSubjectTemplate: "telemetry.{{.Origin}}.{{.Target}}.{{.Path}}",
+		},
+		Log: configuration.NewDefaultGatewayConfig().Log,
+	}
+	e := NewNATSExporter(config).(*NATSExporter)
+	var err error
+	e.subject, err = parseSubjectTemplate(config.Exporters.NATSSubjectTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := e.renderSubject(&pb.Notification{
+		Prefix: &pb.Path{Target: "dev1", Elem: []*pb.PathElem{{Name: "interfaces"}}},
+		Delete: []*pb.Path{{Elem: []*pb.PathElem{{Name: "interface", Key: map[string]string{"name": "eth0"}}}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "telemetry.dev1.interfaces.interface", subject)
+}
@@ -0,0 +1,396 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp provides an exporter that converts numeric gNMI updates into
+// OpenTelemetry metrics and pushes them to an OTLP/HTTP collector endpoint
+// (e.g. http://collector:4318/v1/metrics).
+//
+// Each target is exported as an OTLP resource with the gnmi.target (and
+// gnmi.origin, if set) resource attributes. Metric names are the path
+// element names joined by "/" and the path keys become data point attributes.
+// If an OpenConfigDirectory is configured, counter32 and counter64 leaves are
+// exported as monotonic cumulative sums and all other leaves as gauges.
+package otlp
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/openconfig"
+	"github.com/openconfig/gnmi-gateway/gateway/otlp"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+const Name = "otlp"
+
+const (
+	// otlpTemporalityCumulative is the OTLP AGGREGATION_TEMPORALITY_CUMULATIVE value.
+	otlpTemporalityCumulative = 2
+	defaultInterval           = 10 * time.Second
+)
+
+var _ exporters.LabelExporter = new(OTLPExporter)
+var _ exporters.ConcurrentExporter = new(OTLPExporter)
+
+func init() {
+	exporters.Register(Name, NewOTLPExporter)
+}
+
+func NewOTLPExporter(config *configuration.GatewayConfig) exporters.Exporter {
+	return &OTLPExporter{
+		config:     config,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		series:     make(map[string]*series),
+		typeLookup: new(openconfig.TypeLookup),
+		stop:       make(chan struct{}),
+	}
+}
+
+type OTLPExporter struct {
+	cache      *shardedcache.Cache
+	config     *configuration.GatewayConfig
+	client     *http.Client
+	labels     func(target string) map[string]string
+	typeLookup *openconfig.TypeLookup
+
+	mutex  sync.Mutex
+	series map[string]*series
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// series is the latest value of a single metric stream.
+type series struct {
+	target     string
+	origin     string
+	name       string
+	attributes map[string]string
+	sum        bool
+	start      int64
+	timestamp  int64
+	value      float64
+	dirty      bool
+}
+
+func (e *OTLPExporter) Name() string {
+	return Name
+}
+
+// ConcurrentExport implements exporters.ConcurrentExporter.
+// The series are updated under the mutex.
+func (e *OTLPExporter) ConcurrentExport() {}
+
+// SetLabels implements exporters.LabelExporter. The inventory labels are
+// added to the attributes of every resource.
+func (e *OTLPExporter) SetLabels(labels func(target string) map[string]string) {
+	e.labels = labels
+}
+
+func (e *OTLPExporter) Export(leaf *ctree.Leaf) {
+	notification := leaf.Value().(*gnmipb.Notification)
+	prefix := notification.GetPrefix()
+	timestamp := notification.GetTimestamp()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, update := range notification.Update {
+		value, isNumber := utils.GetNumberValues(update.Val)
+		if !isNumber {
+			continue
+		}
+		name, attributes := metricNameAndAttributes(prefix, update.GetPath())
+		if name == "" {
+			continue
+		}
+		key := seriesKey(prefix.GetTarget(), prefix.GetOrigin(), name, attributes)
+		s, exists := e.series[key]
+		if !exists {
+			s = &series{
+				target:     prefix.GetTarget(),
+				origin:     prefix.GetOrigin(),
+				name:       name,
+				attributes: attributes,
+				sum:        e.isCounter(prefix, update.GetPath()),
+				start:      timestamp,
+			}
+			e.series[key] = s
+		}
+		s.timestamp = timestamp
+		s.value = value
+		s.dirty = true
+	}
+}
+
+func (e *OTLPExporter) Start(cache *shardedcache.Cache) error {
+	e.config.Log.Info().Msg("Starting OTLP exporter.")
+	_, err := url.ParseRequestURI(e.config.Exporters.OTLPEndpoint)
+	if err != nil {
+		return errors.New("value is not set or invalid for OTLPEndpoint configuration")
+	}
+	if e.config.OpenConfigDirectory != "" {
+		err := e.typeLookup.LoadAllModules(e.config.OpenConfigDirectory)
+		if err != nil {
+			e.config.Log.Error().Err(err).Msgf("Unable to load OpenConfig modules in %s: %v", e.config.OpenConfigDirectory, err)
+			return err
+		}
+	}
+	e.cache = cache
+	go e.run()
+	return nil
+}
+
+func (e *OTLPExporter) run() {
+	interval := e.config.Exporters.OTLPInterval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.push()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the push loop and pushes the remaining updated series.
+func (e *OTLPExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+		e.push()
+	})
+}
+
+func (e *OTLPExporter) push() {
+	request := e.collect()
+	if request == nil {
+		return
+	}
+	err := otlp.Post(e.client, e.config.Exporters.OTLPEndpoint, request)
+	if err != nil {
+		stats.Registry.Counter("gnmigateway.exporters.otlp.export_errors", stats.NoTags).Increment()
+		e.config.Log.Error().Err(err).Msgf("Unable to export metrics to OTLP endpoint %s: %v", e.config.Exporters.OTLPEndpoint, err)
+	}
+}
+
+// collect encodes every series that has been updated since the last push.
+// It returns nil if there is nothing to send.
+func (e *OTLPExporter) collect() *otlpRequest {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// Group the updated series by resource (target and origin) and by metric.
+	var resourceKeys []string
+	resources := make(map[string]map[string][]*series)
+	var count int64
+	for _, s := range e.series {
+		if !s.dirty {
+			continue
+		}
+		s.dirty = false
+		count++
+		resourceKey := s.target + "\x00" + s.origin
+		if _, exists := resources[resourceKey]; !exists {
+			resourceKeys = append(resourceKeys, resourceKey)
+			resources[resourceKey] = make(map[string][]*series)
+		}
+		resources[resourceKey][s.name] = append(resources[resourceKey][s.name], s)
+	}
+	if count == 0 {
+		return nil
+	}
+	sort.Strings(resourceKeys)
+
+	request := new(otlpRequest)
+	for _, resourceKey := range resourceKeys {
+		var names []string
+		for name := range resources[resourceKey] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var metrics []otlpMetric
+		for _, name := range names {
+			metrics = append(metrics, encodeMetric(name, resources[resourceKey][name]))
+		}
+		first := resources[resourceKey][names[0]][0]
+		attributes := []otlp.KeyValue{otlp.Attribute("gnmi.target", first.target)}
+		if first.origin != "" {
+			attributes = append(attributes, otlp.Attribute("gnmi.origin", first.origin))
+		}
+		if e.labels != nil {
+			labels := e.labels(first.target)
+			var labelNames []string
+			for name := range labels {
+				labelNames = append(labelNames, name)
+			}
+			sort.Strings(labelNames)
+			for _, name := range labelNames {
+				attributes = append(attributes, otlp.Attribute(name, labels[name]))
+			}
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpResourceMetrics{
+			Resource:     otlp.Resource{Attributes: attributes},
+			ScopeMetrics: []otlpScopeMetrics{{Scope: otlp.DefaultScope, Metrics: metrics}},
+		})
+	}
+	stats.Registry.Counter("gnmigateway.exporters.otlp.points_sent", stats.NoTags).Add(count)
+	return request
+}
+
+func (e *OTLPExporter) isCounter(prefix *gnmipb.Path, path *gnmipb.Path) bool {
+	elems := pathElemNames(prefix, path)
+	if len(elems) == 0 {
+		return false
+	}
+	switch e.typeLookup.GetTypeByPath(elems) {
+	case "counter32", "counter64":
+		return true
+	}
+	return false
+}
+
+func encodeMetric(name string, points []*series) otlpMetric {
+	metric := otlpMetric{Name: name}
+	if points[0].sum {
+		metric.Sum = &otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+	} else {
+		metric.Gauge = new(otlpGauge)
+	}
+	for _, s := range points {
+		point := otlpDataPoint{
+			TimeUnixNano: otlp.UnixNano(time.Unix(0, s.timestamp)),
+			AsDouble:     s.value,
+		}
+		for _, key := range sortedKeys(s.attributes) {
+			point.Attributes = append(point.Attributes, otlp.Attribute(key, s.attributes[key]))
+		}
+		if metric.Sum != nil {
+			point.StartTimeUnixNano = otlp.UnixNano(time.Unix(0, s.start))
+			metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+		} else {
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+		}
+	}
+	return metric
+}
+
+// metricNameAndAttributes returns the metric name for a prefix and path and
+// the path keys as data point attributes. Keys that appear on more than one
+// elem are qualified with the elem name, e.g. "interface/name".
+func metricNameAndAttributes(prefix *gnmipb.Path, path *gnmipb.Path) (string, map[string]string) {
+	attributes := make(map[string]string)
+	for _, p := range []*gnmipb.Path{prefix, path} {
+		for _, elem := range p.GetElem() {
+			for key, value := range elem.GetKey() {
+				if _, exists := attributes[key]; exists {
+					attributes[elem.Name+"/"+key] = value
+				} else {
+					attributes[key] = value
+				}
+			}
+		}
+	}
+	return strings.Join(pathElemNames(prefix, path), "/"), attributes
+}
+
+func pathElemNames(prefix *gnmipb.Path, path *gnmipb.Path) []string {
+	var elems []string
+	for _, p := range []*gnmipb.Path{prefix, path} {
+		for _, elem := range p.GetElem() {
+			elems = append(elems, elem.Name)
+		}
+	}
+	return elems
+}
+
+func seriesKey(target, origin, name string, attributes map[string]string) string {
+	var b strings.Builder
+	b.WriteString(target)
+	b.WriteByte(0)
+	b.WriteString(origin)
+	b.WriteByte(0)
+	b.WriteString(name)
+	for _, key := range sortedKeys(attributes) {
+		b.WriteByte(0)
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(attributes[key])
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OTLP JSON encoding of an ExportMetricsServiceRequest.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlp.Resource      `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlp.Scope   `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlp.KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
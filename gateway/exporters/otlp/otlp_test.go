@@ -0,0 +1,132 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestOTLPExporter_Push(t *testing.T) {
+	assertion := assert.New(t)
+
+	received := make(chan *otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := new(otlpRequest)
+		assertion.NoError(json.NewDecoder(r.Body).Decode(req))
+		received <- req
+	}))
+	defer collector.Close()
+
+	config := &configuration.GatewayConfig{
+		Exporters: &configuration.ExportersConfig{OTLPEndpoint: collector.URL},
+		Log:       configuration.NewDefaultGatewayConfig().Log,
+	}
+	e := NewOTLPExporter(config).(*OTLPExporter)
+
+	e.Export(ctree.DetachedLeaf(&pb.Notification{
+		Timestamp: 1000,
+		Prefix:    &pb.Path{Target: "dev1", Origin: "openconfig"},
+		Update: []*pb.Update{
+			{
+				Path: &pb.Path{Elem: []*pb.PathElem{
+					{Name: "interfaces"},
+					{Name: "interface", Key: map[string]string{"name": "eth0"}},
+					{Name: "state"},
+					{Name: "counters"},
+					{Name: "in-octets"},
+				}},
+				Val: &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}},
+			},
+			{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "system"}, {Name: "hostname"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "dev1"}},
+			},
+		},
+	}))
+	e.push()
+
+	req := <-received
+	if !assertion.Len(req.ResourceMetrics, 1) {
+		return
+	}
+	resource := req.ResourceMetrics[0]
+	assertion.Equal("gnmi.target", resource.Resource.Attributes[0].Key)
+	assertion.Equal("dev1", *resource.Resource.Attributes[0].Value.StringValue)
+	assertion.Equal("gnmi.origin", resource.Resource.Attributes[1].Key)
+
+	metrics := resource.ScopeMetrics[0].Metrics
+	if assertion.Len(metrics, 1) {
+		assertion.Equal("interfaces/interface/state/counters/in-octets", metrics[0].Name)
+		if assertion.NotNil(metrics[0].Gauge) && assertion.Len(metrics[0].Gauge.DataPoints, 1) {
+			point := metrics[0].Gauge.DataPoints[0]
+			assertion.Equal(float64(42), point.AsDouble)
+			assertion.Equal("1000", point.TimeUnixNano)
+			assertion.Equal("name", point.Attributes[0].Key)
+			assertion.Equal("eth0", *point.Attributes[0].Value.StringValue)
+		}
+	}
+
+	// Nothing has been updated since the last push.
+	assertion.Nil(e.collect())
+}
+
+func TestOTLPExporter_Labels(t *testing.T) {
+	assertion := assert.New(t)
+
+	e := NewOTLPExporter(configuration.NewDefaultGatewayConfig()).(*OTLPExporter)
+	e.SetLabels(func(target string) map[string]string {
+		return map[string]string{"site": "ams1", "role": "spine"}
+	})
+	e.Export(ctree.DetachedLeaf(&pb.Notification{
+		Timestamp: 1000,
+		Prefix:    &pb.Path{Target: "dev1"},
+		Update: []*pb.Update{{
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "system"}, {Name: "memory"}, {Name: "used"}}},
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}},
+		}},
+	}))
+
+	req := e.collect()
+	if !assertion.NotNil(req) || !assertion.Len(req.ResourceMetrics, 1) {
+		return
+	}
+	attributes := req.ResourceMetrics[0].Resource.Attributes
+	if assertion.Len(attributes, 3) {
+		assertion.Equal("gnmi.target", attributes[0].Key)
+		assertion.Equal("role", attributes[1].Key)
+		assertion.Equal("spine", *attributes[1].Value.StringValue)
+		assertion.Equal("site", attributes[2].Key)
+		assertion.Equal("ams1", *attributes[2].Value.StringValue)
+	}
+}
+
+func TestMetricNameAndAttributes(t *testing.T) {
+	name, attributes := metricNameAndAttributes(
+		&pb.Path{Elem: []*pb.PathElem{{Name: "network-instances"}, {Name: "network-instance", Key: map[string]string{"name": "default"}}}},
+		&pb.Path{Elem: []*pb.PathElem{{Name: "protocols"}, {Name: "protocol", Key: map[string]string{"name": "BGP"}}, {Name: "enabled"}}},
+	)
+	assert.Equal(t, "network-instances/network-instance/protocols/protocol/enabled", name)
+	assert.Equal(t, map[string]string{"name": "default", "protocol/name": "BGP"}, attributes)
+}
@@ -21,7 +21,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/ctree"
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 	prom "github.com/prometheus/client_golang/prometheus"
@@ -30,13 +29,15 @@ import (
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/maintenance"
 	"github.com/openconfig/gnmi-gateway/gateway/openconfig"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 	"github.com/openconfig/gnmi-gateway/gateway/utils"
 )
 
 const Name = "prometheus"
 
-var _ exporters.Exporter = new(PrometheusExporter)
+var _ exporters.LabelExporter = new(PrometheusExporter)
 
 func init() {
 	exporters.Register(Name, NewPrometheusExporter)
@@ -47,15 +48,22 @@ func NewPrometheusExporter(config *configuration.GatewayConfig) exporters.Export
 		config:     config,
 		deltaCalc:  NewDeltaCalculator(),
 		metrics:    make(map[Hash]prom.Metric),
+		server:     &http.Server{Addr: ":59100"},
 		typeLookup: new(openconfig.TypeLookup),
 	}
 }
 
 type PrometheusExporter struct {
-	config     *configuration.GatewayConfig
-	cache      *cache.Cache
-	deltaCalc  *DeltaCalculator
+	config    *configuration.GatewayConfig
+	cache     *shardedcache.Cache
+	deltaCalc *DeltaCalculator
+	// filter is only used to collect the metrics from a snapshot; the
+	// exported updates are filtered by the gateway.
+	filter     *exporters.Filter
+	labels     func(target string) map[string]string
 	metrics    map[Hash]prom.Metric
+	server     *http.Server
+	snapshot   bool
 	typeLookup *openconfig.TypeLookup
 }
 
@@ -63,7 +71,17 @@ func (e *PrometheusExporter) Name() string {
 	return Name
 }
 
+// SetLabels implements exporters.LabelExporter. The inventory labels are
+// added to the labels of every metric.
+func (e *PrometheusExporter) SetLabels(labels func(target string) map[string]string) {
+	e.labels = labels
+}
+
 func (e *PrometheusExporter) Export(leaf *ctree.Leaf) {
+	if e.snapshot {
+		// the metrics are collected from the cache for each scrape
+		return
+	}
 	notification := leaf.Value().(*gnmipb.Notification)
 	for _, update := range notification.Update {
 		value, isNumber := utils.GetNumberValues(update.Val)
@@ -71,6 +89,11 @@ func (e *PrometheusExporter) Export(leaf *ctree.Leaf) {
 			continue
 		}
 		metricName, labels := UpdateToMetricNameAndLabels(notification.GetPrefix(), update)
+		if e.labels != nil {
+			for name, value := range e.labels(notification.GetPrefix().GetTarget()) {
+				labels[strings.ReplaceAll(name, "-", "_")] = value
+			}
+		}
 		metricHash := NewStringMapHash(metricName, labels)
 
 		metric, exists := e.metrics[metricHash]
@@ -109,7 +132,7 @@ func (e *PrometheusExporter) Export(leaf *ctree.Leaf) {
 	}
 }
 
-func (e *PrometheusExporter) Start(cache *cache.Cache) error {
+func (e *PrometheusExporter) Start(cache *shardedcache.Cache) error {
 	e.config.Log.Info().Msg("Starting Prometheus exporter.")
 	if e.config.OpenConfigDirectory == "" {
 		return errors.New("value is not set for OpenConfigDirectory configuration")
@@ -120,17 +143,47 @@ func (e *PrometheusExporter) Start(cache *cache.Cache) error {
 		e.config.Log.Error().Err(err).Msgf("Unable to load OpenConfig modules in %s: %v", e.config.OpenConfigDirectory, err)
 		return err
 	}
+	if e.config.Exporters != nil && e.config.Exporters.PrometheusSnapshot {
+		windows, err := maintenance.New(e.config.MaintenanceWindows)
+		if err != nil {
+			return err
+		}
+		e.filter, err = exporters.NewFilter(e.config.Exporters.Filters[Name], windows)
+		if err != nil {
+			return err
+		}
+		e.snapshot = true
+		registry := prom.NewRegistry()
+		registry.MustRegister(e)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(prom.Gatherers{prom.DefaultGatherer, registry}, promhttp.HandlerOpts{
+			ErrorHandling: promhttp.ContinueOnError,
+		}))
+		e.server.Handler = mux
+	}
 	go e.runHttpServer()
 	return nil
 }
 
+// Stop stops the Prometheus HTTP server.
+func (e *PrometheusExporter) Stop() {
+	if err := e.server.Close(); err != nil {
+		e.config.Log.Error().Err(err).Msgf("Unable to stop Prometheus HTTP server: %v", err)
+	}
+}
+
 func (e *PrometheusExporter) runHttpServer() {
 	var errCount = 0
 	var lastError error
+	if e.server.Handler == nil {
+		http.Handle("/metrics", promhttp.Handler())
+	}
 	for {
 		e.config.Log.Info().Msg("Starting Prometheus HTTP server.")
-		http.Handle("/metrics", promhttp.Handler())
-		err := http.ListenAndServe(":59100", nil)
+		err := e.server.ListenAndServe()
+		if err == http.ErrServerClosed {
+			return
+		}
 		if err != nil {
 			e.config.Log.Error().Err(err).Msgf("Prometheus HTTP server stopped with an error: %v", err)
 			if err.Error() == lastError.Error() {
@@ -17,7 +17,6 @@ package prometheus
 
 import (
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/ctree"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -29,6 +28,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 )
 
 var _ exporters.Exporter = new(PrometheusExporter)
@@ -77,7 +77,7 @@ func TestPrometheusExporter_Export(t *testing.T) {
 
 	e := &PrometheusExporter{
 		config:    &configuration.GatewayConfig{},
-		cache:     cache.New(nil),
+		cache:     shardedcache.New(1, nil),
 		deltaCalc: calc,
 		metrics: map[Hash]prom.Metric{
 			metricHash: promauto.NewCounter(prom.CounterOpts{
@@ -0,0 +1,100 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"sort"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+var _ prom.Collector = new(PrometheusExporter)
+
+// Describe implements prometheus.Collector for the PrometheusSnapshot mode.
+// No descriptors are sent because the metrics are only known once the cache
+// is read.
+func (e *PrometheusExporter) Describe(chan<- *prom.Desc) {}
+
+// Collect implements prometheus.Collector for the PrometheusSnapshot mode. It
+// sends a metric for each numeric leaf in a snapshot of the cache that passes
+// the exporter's filter. Counters are sent with the values reported by the
+// targets. Leaves whose metric has different label names than an earlier leaf
+// with the same metric name are skipped because Prometheus rejects them.
+func (e *PrometheusExporter) Collect(ch chan<- prom.Metric) {
+	snapshot, err := e.cache.Snapshot("*", nil)
+	if err != nil {
+		e.config.Log.Error().Msgf("Unable to read the cache for Prometheus: %v", err)
+		return
+	}
+	collected := make(map[Hash]bool)
+	labelNames := make(map[string]string)
+	for _, notification := range snapshot {
+		if e.filter != nil && !e.filter.Match(notification) {
+			continue
+		}
+		for _, update := range notification.Update {
+			value, isNumber := utils.GetNumberValues(update.Val)
+			if !isNumber || len(update.GetPath().GetElem()) == 0 {
+				continue
+			}
+			metricName, labels := UpdateToMetricNameAndLabels(notification.GetPrefix(), update)
+			if e.labels != nil {
+				for name, value := range e.labels(notification.GetPrefix().GetTarget()) {
+					labels[strings.ReplaceAll(name, "-", "_")] = value
+				}
+			}
+			metricHash := NewStringMapHash(metricName, labels)
+			if collected[metricHash] {
+				continue
+			}
+			names := sortedLabelNames(labels)
+			if existing, exists := labelNames[metricName]; exists && existing != names {
+				continue
+			}
+
+			valueType := prom.GaugeValue
+			if e.typeLookup != nil {
+				var path []string
+				for _, elem := range update.Path.Elem {
+					path = append(path, elem.Name)
+				}
+				if e.typeLookup.GetTypeByPath(path) == "counter64" {
+					valueType = prom.CounterValue
+				}
+			}
+			metric, err := prom.NewConstMetric(prom.NewDesc(metricName, "", nil, labels), valueType, value)
+			if err != nil {
+				continue
+			}
+			collected[metricHash] = true
+			labelNames[metricName] = names
+			ch <- metric
+		}
+	}
+}
+
+// sortedLabelNames returns the names of labels joined in sorted order.
+func sortedLabelNames(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+func TestPrometheusExporter_Collect(t *testing.T) {
+	assertion := assert.New(t)
+
+	c := shardedcache.New(2, []string{"a", "b"})
+	for _, n := range []*pb.Notification{
+		{
+			Prefix: &pb.Path{Target: "a"},
+			Update: []*pb.Update{{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "in-octets"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}},
+			}},
+		},
+		{
+			Prefix: &pb.Path{Target: "a"},
+			Update: []*pb.Update{{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "description"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "not a number"}},
+			}},
+		},
+		{
+			Prefix: &pb.Path{Target: "b"},
+			Update: []*pb.Update{{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "in-octets"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 7}},
+			}},
+		},
+	} {
+		assertion.NoError(c.GnmiUpdate(n))
+	}
+
+	filter, err := exporters.NewFilter(configuration.ExporterFilter{ExcludeTargets: []string{"b"}}, nil)
+	assertion.NoError(err)
+	e := &PrometheusExporter{
+		config:   &configuration.GatewayConfig{},
+		cache:    c,
+		filter:   filter,
+		labels:   func(target string) map[string]string { return map[string]string{"site": "ams1"} },
+		snapshot: true,
+	}
+	registry := prom.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	assertion.NoError(err)
+	if assertion.Len(families, 1) {
+		assertion.Equal("interfaces_interface_in_octets", families[0].GetName())
+		if assertion.Len(families[0].GetMetric(), 1) {
+			metric := families[0].GetMetric()[0]
+			assertion.Equal(float64(42), metric.GetGauge().GetValue())
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assertion.Equal(map[string]string{"target": "a", "interfaces_interface_name": "eth0", "site": "ams1"}, labels)
+		}
+	}
+
+	// the metrics are read from the cache on each scrape and the exported
+	// updates are ignored
+	update := &pb.Notification{
+		Timestamp: 1,
+		Prefix:    &pb.Path{Target: "a"},
+		Update: []*pb.Update{{
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}, {Name: "in-octets"}}},
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 43}},
+		}},
+	}
+	assertion.NoError(c.GnmiUpdate(update))
+	assertion.NotPanics(func() {
+		e.Export(ctree.DetachedLeaf(update))
+	})
+	families, err = registry.Gather()
+	assertion.NoError(err)
+	if assertion.Len(families, 1) && assertion.Len(families[0].GetMetric(), 1) {
+		assertion.Equal(float64(43), families[0].GetMetric()[0].GetGauge().GetValue())
+	}
+}
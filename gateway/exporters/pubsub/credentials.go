@@ -0,0 +1,256 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pubsubScope     = "https://www.googleapis.com/auth/pubsub"
+	googleTokenURL  = "https://oauth2.googleapis.com/token"
+	metadataHost    = "metadata.google.internal"
+	jwtBearerGrant  = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	tokenExpirySkew = time.Minute
+)
+
+// tokenSource returns OAuth2 access tokens for Google APIs.
+type tokenSource interface {
+	Token() (string, error)
+}
+
+// credentialsFile is the format of service account keys and of the
+// application default credentials written by "gcloud auth
+// application-default login".
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// defaultTokenSource finds credentials the same way as the Google Cloud SDKs
+// (application default credentials):
+//		1. The JSON file named by the GOOGLE_APPLICATION_CREDENTIALS variable.
+//		2. The gcloud application default credentials file.
+//		3. The GCE/GKE metadata server.
+func defaultTokenSource(client *http.Client) (tokenSource, error) {
+	if filename := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); filename != "" {
+		return fileTokenSource(client, filename)
+	}
+	if filename := gcloudCredentialsFile(); filename != "" {
+		if _, err := os.Stat(filename); err == nil {
+			return fileTokenSource(client, filename)
+		}
+	}
+	return &cachedTokenSource{fetch: func() (*tokenResponse, error) {
+		return metadataToken(client)
+	}}, nil
+}
+
+func gcloudCredentialsFile() string {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+func fileTokenSource(client *http.Client, filename string) (tokenSource, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Google credentials: %v", err)
+	}
+	var creds credentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("unable to parse Google credentials file '%s': %v", filename, err)
+	}
+
+	switch creds.Type {
+	case "service_account":
+		key, err := parsePrivateKey(creds.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in Google credentials file '%s': %v", filename, err)
+		}
+		tokenURI := creds.TokenURI
+		if tokenURI == "" {
+			tokenURI = googleTokenURL
+		}
+		return &cachedTokenSource{fetch: func() (*tokenResponse, error) {
+			assertion, err := signJWT(key, creds.PrivateKeyID, creds.ClientEmail, tokenURI, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return postToken(client, tokenURI, url.Values{
+				"grant_type": {jwtBearerGrant},
+				"assertion":  {assertion},
+			})
+		}}, nil
+	case "authorized_user":
+		return &cachedTokenSource{fetch: func() (*tokenResponse, error) {
+			return postToken(client, googleTokenURL, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {creds.ClientID},
+				"client_secret": {creds.ClientSecret},
+				"refresh_token": {creds.RefreshToken},
+			})
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Google credentials type '%s' in '%s'", creds.Type, filename)
+	}
+}
+
+// cachedTokenSource caches an access token until shortly before it expires.
+type cachedTokenSource struct {
+	fetch func() (*tokenResponse, error)
+
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (s *cachedTokenSource) Token() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+	resp, err := s.fetch()
+	if err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", errors.New("token response is missing the access token")
+	}
+	s.token = resp.AccessToken
+	s.expiry = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - tokenExpirySkew)
+	return s.token, nil
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// signJWT returns a signed JWT assertion for the OAuth2 JWT bearer grant.
+func signJWT(key *rsa.PrivateKey, keyID, email, audience string, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   email,
+		"scope": pubsubScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func postToken(client *http.Client, tokenURL string, form url.Values) (*tokenResponse, error) {
+	resp, err := client.PostForm(tokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTokenResponse(resp)
+}
+
+func metadataToken(client *http.Client) (*tokenResponse, error) {
+	host := metadataHost
+	if env := os.Getenv("GCE_METADATA_HOST"); env != "" {
+		host = env
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token?scopes="+url.QueryEscape(pubsubScope), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no Google credentials found and the metadata server is unavailable: %v", err)
+	}
+	return decodeTokenResponse(resp)
+}
+
+func decodeTokenResponse(resp *http.Response) (*tokenResponse, error) {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	token := new(tokenResponse)
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("unable to parse token response: %v", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pubsub provides an exporter that publishes gNMI notifications to a
+// Google Cloud Pub/Sub topic. Notifications are published in batches as
+// serialized gnmi.Notification messages with the target name as the ordering
+// key and as the "target" attribute. Subscriptions must have message ordering
+// enabled to receive the messages for each target in order.
+//
+// Credentials are found the same way as the Google Cloud SDKs (application
+// default credentials): the GOOGLE_APPLICATION_CREDENTIALS service account
+// file, the gcloud application default credentials, or the GCE/GKE metadata
+// server.
+package pubsub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+const Name = "pubsub"
+
+const (
+	DefaultEndpoint = "https://pubsub.googleapis.com"
+	// maxBatchSize is the max number of messages in a Pub/Sub publish request.
+	maxBatchSize = 1000
+	maxAttempts  = 5
+)
+
+var _ exporters.Exporter = new(PubSubExporter)
+
+func init() {
+	exporters.Register(Name, NewPubSubExporter)
+}
+
+func NewPubSubExporter(config *configuration.GatewayConfig) exporters.Exporter {
+	return &PubSubExporter{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type PubSubExporter struct {
+	config  *configuration.GatewayConfig
+	cache   *shardedcache.Cache
+	client  *http.Client
+	tokens  tokenSource
+	batcher *exporters.Batcher
+	url     string
+}
+
+type pubsubMessage struct {
+	Data        string            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+type publishRequest struct {
+	Messages []interface{} `json:"messages"`
+}
+
+func (e *PubSubExporter) Name() string {
+	return Name
+}
+
+func (e *PubSubExporter) Export(leaf *ctree.Leaf) {
+	notification := leaf.Value().(*gnmipb.Notification)
+	data, err := proto.Marshal(notification)
+	if err != nil {
+		e.config.Log.Warn().Msgf("failed to marshal message for Pub/Sub: %s", err)
+		return
+	}
+	message := pubsubMessage{Data: base64.StdEncoding.EncodeToString(data)}
+	if target := notification.GetPrefix().GetTarget(); target != "" {
+		message.Attributes = map[string]string{"target": target}
+		message.OrderingKey = target
+	}
+	e.batcher.Add(message)
+}
+
+func (e *PubSubExporter) Start(cache *shardedcache.Cache) error {
+	e.config.Log.Info().Msg("Starting Pub/Sub exporter.")
+	if e.config.Exporters.PubSubProject == "" {
+		return errors.New("configuration option for Pub/Sub Project is not set")
+	}
+	if e.config.Exporters.PubSubTopic == "" {
+		return errors.New("configuration option for Pub/Sub Topic is not set")
+	}
+	if e.tokens == nil {
+		tokens, err := defaultTokenSource(e.client)
+		if err != nil {
+			return err
+		}
+		e.tokens = tokens
+	}
+
+	endpoint := e.config.Exporters.PubSubEndpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	e.url = fmt.Sprintf("%s/v1/projects/%s/topics/%s:publish", strings.TrimRight(endpoint, "/"),
+		e.config.Exporters.PubSubProject, e.config.Exporters.PubSubTopic)
+
+	batchSize := e.config.Exporters.PubSubBatchSize
+	if batchSize <= 0 || batchSize > maxBatchSize {
+		batchSize = maxBatchSize
+	}
+	batchTimeout := e.config.Exporters.PubSubBatchTimeout
+	if batchTimeout <= 0 {
+		batchTimeout = time.Second
+	}
+	e.cache = cache
+	e.batcher = exporters.NewBatcher(batchSize, batchTimeout, e.publish)
+	return nil
+}
+
+// Stop publishes the remaining buffered messages.
+func (e *PubSubExporter) Stop() {
+	if e.batcher != nil {
+		e.batcher.Close()
+	}
+}
+
+// publish sends a batch of messages, retrying on errors that the Pub/Sub API
+// documents as retryable.
+func (e *PubSubExporter) publish(batch []interface{}) {
+	body, err := json.Marshal(publishRequest{Messages: batch})
+	if err != nil {
+		e.config.Log.Warn().Msgf("failed to encode Pub/Sub publish request: %s", err)
+		return
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * time.Second)
+		}
+		var retryable bool
+		retryable, err = e.send(body)
+		if err == nil {
+			stats.Registry.Counter("gnmigateway.exporters.pubsub.published", stats.NoTags).Add(int64(len(batch)))
+			return
+		}
+		stats.Registry.Counter("gnmigateway.exporters.pubsub.publish_errors", stats.NoTags).Increment()
+		if !retryable {
+			break
+		}
+	}
+	stats.Registry.Counter("gnmigateway.exporters.pubsub.dropped", stats.NoTags).Add(int64(len(batch)))
+	e.config.Log.Warn().Msgf("failed to publish %d messages to Pub/Sub: %s", len(batch), err)
+}
+
+func (e *PubSubExporter) send(body []byte) (bool, error) {
+	token, err := e.tokens.Token()
+	if err != nil {
+		return true, fmt.Errorf("unable to get Google access token: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("publish failed with %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+type staticToken string
+
+func (s staticToken) Token() (string, error) {
+	return string(s), nil
+}
+
+func TestPubSubExporter_Publish(t *testing.T) {
+	assertion := assert.New(t)
+
+	received := make(chan map[string][]pubsubMessage, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal("/v1/projects/project/topics/topic:publish", r.URL.Path)
+		assertion.Equal("Bearer token", r.Header.Get("Authorization"))
+		req := make(map[string][]pubsubMessage)
+		assertion.NoError(json.NewDecoder(r.Body).Decode(&req))
+		received <- req
+		_, _ = w.Write([]byte(`{"messageIds":["1","2"]}`))
+	}))
+	defer server.Close()
+
+	config := &configuration.GatewayConfig{
+		Exporters: &configuration.ExportersConfig{
+			PubSubBatchSize: 2,
+			PubSubEndpoint:  server.URL,
+			PubSubProject:   "project",
+			PubSubTopic:     "topic",
+		},
+		Log: configuration.NewDefaultGatewayConfig().Log,
+	}
+	e := NewPubSubExporter(config).(*PubSubExporter)
+	e.tokens = staticToken("token")
+	assertion.NoError(e.Start(nil))
+
+	notification := &pb.Notification{Prefix: &pb.Path{Target: "dev1"}, Timestamp: 1}
+	e.Export(ctree.DetachedLeaf(notification))
+	e.Export(ctree.DetachedLeaf(&pb.Notification{Prefix: &pb.Path{Target: "dev2"}, Timestamp: 2}))
+
+	select {
+	case req := <-received:
+		messages := req["messages"]
+		if assertion.Len(messages, 2) {
+			assertion.Equal("dev1", messages[0].OrderingKey)
+			assertion.Equal("dev1", messages[0].Attributes["target"])
+			assertion.Equal("dev2", messages[1].OrderingKey)
+
+			data, err := base64.StdEncoding.DecodeString(messages[0].Data)
+			assertion.NoError(err)
+			decoded := new(pb.Notification)
+			assertion.NoError(proto.Unmarshal(data, decoded))
+			assertion.True(proto.Equal(notification, decoded))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for publish")
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakedevice provides synthetic gNMI targets for tests and
+// benchmarks. A Device serves the gNMI Subscribe RPC on a local port with a
+// self-signed TLS certificate and streams interface counter updates at a
+// configured rate:
+//		/interfaces/interface[name=eth<n>]/state/counters/in-octets
+// The notification timestamps are the time each notification is sent so
+// that the latency of the gateway can be measured by its clients.
+package fakedevice
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// tickInterval is how often a streaming Device sends the updates that are
+// due.
+const tickInterval = 10 * time.Millisecond
+
+// Config is the configuration of a Device.
+type Config struct {
+	// Name is the target name used in the notification prefixes.
+	Name string
+	// Leaves is the number of leaves the device has. Each leaf is updated in
+	// turn.
+	Leaves int
+	// UpdateRate is the number of updates sent per second to each streaming
+	// subscription. Zero only sends the initial updates.
+	UpdateRate float64
+	// BatchSize is the maximum number of updates in each notification. The
+	// default is 1.
+	BatchSize int
+}
+
+// Device is a synthetic gNMI target.
+type Device struct {
+	gnmi.UnimplementedGNMIServer
+
+	config   Config
+	server   *grpc.Server
+	listener net.Listener
+	// sent is the number of updates sent to all subscriptions.
+	sent uint64
+}
+
+// New starts a Device listening on a random port of 127.0.0.1.
+func New(config Config) (*Device, error) {
+	if config.Leaves <= 0 {
+		return nil, fmt.Errorf("device %s must have at least one leaf", config.Name)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	cert, err := SelfSignedCertificate()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{
+		config:   config,
+		server:   grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(&cert))),
+		listener: listener,
+	}
+	gnmi.RegisterGNMIServer(d.server, d)
+	go d.server.Serve(listener)
+	return d, nil
+}
+
+// Address returns the host:port address the device is listening on.
+func (d *Device) Address() string {
+	return d.listener.Addr().String()
+}
+
+// Sent returns the number of updates sent to all subscriptions.
+func (d *Device) Sent() uint64 {
+	return atomic.LoadUint64(&d.sent)
+}
+
+// Stop stops the device and ends its subscriptions.
+func (d *Device) Stop() {
+	d.server.Stop()
+}
+
+// Subscribe implements the gNMI Subscribe RPC. The paths of the subscription
+// are ignored: all of the leaves are sent.
+func (d *Device) Subscribe(stream gnmi.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument, "request must contain a subscription")
+	}
+
+	var counter uint64
+	if err := d.send(stream, 0, d.config.Leaves, &counter); err != nil {
+		return err
+	}
+	err = stream.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	if err != nil {
+		return err
+	}
+	switch list.GetMode() {
+	case gnmi.SubscriptionList_ONCE:
+		return nil
+	case gnmi.SubscriptionList_STREAM:
+	default:
+		return status.Errorf(codes.Unimplemented, "subscription mode %v is not supported", list.GetMode())
+	}
+	if d.config.UpdateRate <= 0 {
+		<-stream.Context().Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	start := time.Now()
+	var sent, leaf int
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case now := <-ticker.C:
+			due := int(now.Sub(start).Seconds()*d.config.UpdateRate) - sent
+			if due <= 0 {
+				continue
+			}
+			if err := d.send(stream, leaf, due, &counter); err != nil {
+				return err
+			}
+			sent += due
+			leaf = (leaf + due) % d.config.Leaves
+		}
+	}
+}
+
+// send sends count updates starting at the leaf with index first in
+// notifications of up to BatchSize updates.
+func (d *Device) send(stream gnmi.GNMI_SubscribeServer, first int, count int, counter *uint64) error {
+	for count > 0 {
+		batch := d.config.BatchSize
+		if batch > count {
+			batch = count
+		}
+		updates := make([]*gnmi.Update, batch)
+		for i := range updates {
+			*counter++
+			updates[i] = &gnmi.Update{
+				Path: leafPath((first + i) % d.config.Leaves),
+				Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: *counter}},
+			}
+		}
+		err := stream.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: &gnmi.Notification{
+			Timestamp: time.Now().UnixNano(),
+			Prefix:    &gnmi.Path{Target: d.config.Name},
+			Update:    updates,
+		}}})
+		if err != nil {
+			return err
+		}
+		atomic.AddUint64(&d.sent, uint64(batch))
+		first += batch
+		count -= batch
+	}
+	return nil
+}
+
+// leafPath returns the path of the leaf with index i.
+func leafPath(i int) *gnmi.Path {
+	return &gnmi.Path{Elem: []*gnmi.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "eth" + strconv.Itoa(i)}},
+		{Name: "state"},
+		{Name: "counters"},
+		{Name: "in-octets"},
+	}}
+}
+
+// SelfSignedCertificate generates a self-signed TLS certificate for
+// localhost and 127.0.0.1.
+func SelfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fakedevice"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakedevice
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func subscribe(t *testing.T, ctx context.Context, d *Device, mode gnmi.SubscriptionList_Mode) gnmi.GNMI_SubscribeClient {
+	conn, err := grpc.DialContext(ctx, d.Address(),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := gnmi.NewGNMIClient(conn).Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&gnmi.SubscribeRequest{Request: &gnmi.SubscribeRequest_Subscribe{
+		Subscribe: &gnmi.SubscriptionList{Mode: mode},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+func TestDevice_once(t *testing.T) {
+	assertion := assert.New(t)
+	d, err := New(Config{Name: "router1", Leaves: 5, BatchSize: 2})
+	assertion.NoError(err)
+	defer d.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := subscribe(t, ctx, d, gnmi.SubscriptionList_ONCE)
+
+	var notifications, updates int
+	for {
+		resp, err := stream.Recv()
+		if !assertion.NoError(err) {
+			return
+		}
+		if resp.GetSyncResponse() {
+			break
+		}
+		notifications++
+		updates += len(resp.GetUpdate().GetUpdate())
+		assertion.Equal("router1", resp.GetUpdate().GetPrefix().GetTarget())
+	}
+	assertion.Equal(3, notifications)
+	assertion.Equal(5, updates)
+	assertion.Equal(uint64(5), d.Sent())
+
+	_, err = New(Config{Name: "router2"})
+	assertion.Error(err)
+}
+
+func TestDevice_stream(t *testing.T) {
+	assertion := assert.New(t)
+	d, err := New(Config{Name: "router1", Leaves: 10, UpdateRate: 1000, BatchSize: 10})
+	assertion.NoError(err)
+	defer d.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := subscribe(t, ctx, d, gnmi.SubscriptionList_STREAM)
+
+	var updates int
+	for updates < 110 {
+		resp, err := stream.Recv()
+		if !assertion.NoError(err) {
+			return
+		}
+		updates += len(resp.GetUpdate().GetUpdate())
+	}
+	assertion.True(d.Sent() >= 110)
+}
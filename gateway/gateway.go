@@ -60,11 +60,11 @@ package gateway
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	_ "net/http/pprof"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -72,22 +72,34 @@ import (
 	"time"
 
 	"github.com/Netflix/spectator-go"
+	"github.com/cespare/xxhash/v2"
 	"github.com/go-zookeeper/zk"
 	"github.com/openconfig/gnmi/ctree"
 	"github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/openconfig/gnmi-gateway/gateway/admin"
 	"github.com/openconfig/gnmi-gateway/gateway/clustering"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	_ "github.com/openconfig/gnmi-gateway/gateway/encoding/zstd"
+	"github.com/openconfig/gnmi-gateway/gateway/events"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
 	"github.com/openconfig/gnmi-gateway/gateway/loaders"
 	"github.com/openconfig/gnmi-gateway/gateway/loaders/cluster"
+	"github.com/openconfig/gnmi-gateway/gateway/locking"
+	"github.com/openconfig/gnmi-gateway/gateway/maintenance"
+	"github.com/openconfig/gnmi-gateway/gateway/rates"
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+	"github.com/openconfig/gnmi-gateway/gateway/retention"
+	"github.com/openconfig/gnmi-gateway/gateway/secrets"
 	"github.com/openconfig/gnmi-gateway/gateway/server"
 	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/tracing"
 )
 
 var (
@@ -103,18 +115,47 @@ var (
 	PProf        bool
 )
 
+// Gateway connects to gNMI targets and relays the received notifications to
+// exporters and to downstream gNMI clients. A Gateway can be embedded in
+// other programs: create it with NewGateway, run it with Start or
+// StartGateway, and stop it with Stop.
 type Gateway struct {
-	clientLock       sync.Mutex
+	admin            *admin.Server
+	clientLock       sync.RWMutex
 	clients          []*CacheClient
 	cluster          clustering.ClusterMember
 	config           *configuration.GatewayConfig
 	connMgr          connections.ConnectionManager
+	exporters        []exporters.Exporter
+	gnmiServer       gnmiServer
+	gnmiServerLock   sync.Mutex
+	grpcServers      []*grpc.Server
+	inventory        exporters.Labeler
+	loaders          []loaders.TargetLoader
+	elections        []*locking.Election
+	newLock          locking.LockFactory
+	redis            *redis.Client
+	retention        *retention.Buffer
+	tunnel           *tunnelServer
+	stop             chan struct{}
+	stopOnce         sync.Once
 	zkConn           *zk.Conn
 	zkEventListeners []chan<- zk.Event
 }
 
+// gnmiServer is the gNMI server started by StartGNMIServer: a server.Server,
+// or a server.TenantServer if tenants are configured.
+type gnmiServer interface {
+	gnmi.GNMIServer
+	// Clients returns the connected Subscribe clients.
+	Clients() []server.ClientInfo
+	// Update passes an update of the gateway's cache to the server.
+	Update(leaf *ctree.Leaf)
+}
+
 type CacheClient struct {
-	buffer      chan *ctree.Leaf
+	// buffers has one buffer for each worker.
+	buffers     []chan *ctree.Leaf
 	bufferGauge *spectator.Gauge
 	name        string
 	send        func(leaf *ctree.Leaf)
@@ -124,17 +165,32 @@ type CacheClient struct {
 // NewCacheClient creates a new cache client instance and starts the associated
 // goroutines.
 func NewCacheClient(name string, newClient func(leaf *ctree.Leaf), external bool, size uint64) *CacheClient {
+	return NewCacheClientWorkers(name, newClient, external, size, 1)
+}
+
+// NewCacheClientWorkers creates a new cache client instance that calls
+// newClient from the specified number of worker goroutines, each with a
+// buffer of the specified size. The leaves of a target are always sent to the
+// same worker so that they are delivered in order. newClient must be safe for
+// concurrent use if there is more than one worker.
+func NewCacheClientWorkers(name string, newClient func(leaf *ctree.Leaf), external bool, size uint64, workers int) *CacheClient {
+	if workers < 1 {
+		workers = 1
+	}
 	metricTags := map[string]string{
 		"gnmigateway.transition_buffer_name": name,
 	}
 	c := &CacheClient{
-		buffer:      make(chan *ctree.Leaf, size),
+		buffers:     make([]chan *ctree.Leaf, workers),
 		bufferGauge: stats.Registry.Gauge("gnmigateway.transition_buffer_size", metricTags),
 		name:        name,
 		send:        newClient,
 		External:    external,
 	}
-	go c.run()
+	for i := range c.buffers {
+		c.buffers[i] = make(chan *ctree.Leaf, size)
+		go c.run(c.buffers[i])
+	}
 	go c.metrics()
 	return c
 }
@@ -142,18 +198,34 @@ func NewCacheClient(name string, newClient func(leaf *ctree.Leaf), external bool
 func (c *CacheClient) metrics() {
 	for {
 		time.Sleep(30 * time.Second)
-		c.bufferGauge.Set(float64(len(c.buffer)))
+		var size int
+		for _, buffer := range c.buffers {
+			size += len(buffer)
+		}
+		c.bufferGauge.Set(float64(size))
 	}
 }
 
-func (c *CacheClient) run() {
-	for l := range c.buffer {
+func (c *CacheClient) run(buffer <-chan *ctree.Leaf) {
+	for l := range buffer {
 		c.send(l)
 	}
 }
 
 func (c *CacheClient) Send(leaf *ctree.Leaf) {
-	c.buffer <- leaf
+	c.buffers[c.worker(leaf)] <- leaf
+}
+
+// worker returns the index of the worker for the target of the leaf.
+func (c *CacheClient) worker(leaf *ctree.Leaf) int {
+	if len(c.buffers) == 1 {
+		return 0
+	}
+	notification, ok := leaf.Value().(*gnmi.Notification)
+	if !ok {
+		return 0
+	}
+	return int(xxhash.Sum64String(notification.GetPrefix().GetTarget()) % uint64(len(c.buffers)))
 }
 
 // StartOpts is passed to StartGateway() and is used to set the running configuration
@@ -162,6 +234,25 @@ type StartOpts struct {
 	TargetLoaders []loaders.TargetLoader
 	// Exporters to run
 	Exporters []exporters.Exporter
+	// Hooks are called on gateway events.
+	Hooks Hooks
+	// Middlewares are added to the pipeline for notifications received from
+	// targets after the middlewares enabled in the configuration.
+	Middlewares []connections.Middleware
+}
+
+// Hooks are optional callbacks for programs that embed the gateway.
+type Hooks struct {
+	// OnStarted is called once all of the components of the gateway have
+	// been started.
+	OnStarted func(g *Gateway)
+	// OnStopped is called when the gateway stops with the error that caused
+	// it to stop, or nil if Stop was called.
+	OnStopped func(err error)
+	// OnNotification is called for every notification inserted into the
+	// cache. Like other cache clients OnNotification needs to complete very
+	// quickly to prevent blocking upstream.
+	OnNotification func(notification *gnmi.Notification)
 }
 
 // NewGateway returns an new Gateway instance.
@@ -169,14 +260,53 @@ func NewGateway(config *configuration.GatewayConfig) *Gateway {
 	return &Gateway{
 		clients: []*CacheClient{},
 		config:  config,
+		stop:    make(chan struct{}),
 	}
 }
 
+// Start runs the gateway in the background. The returned channel receives the
+// result of StartGateway once the gateway stops.
+func (g *Gateway) Start(opts *StartOpts) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- g.StartGateway(opts)
+	}()
+	return result
+}
+
+// Stop stops a running gateway: the target loaders are stopped, the gNMI and
+// admin servers are stopped, all targets are disconnected, and the exporters
+// are stopped. StartGateway returns nil once the gateway has stopped. Stop may
+// be called more than once.
+func (g *Gateway) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stop)
+	})
+}
+
+// Config returns the gateway configuration.
+func (g *Gateway) Config() *configuration.GatewayConfig {
+	return g.config
+}
+
+// ConnectionManager returns the gateway's connection manager. The connection
+// manager is only available after the gateway has been started.
+func (g *Gateway) ConnectionManager() connections.ConnectionManager {
+	return g.connMgr
+}
+
 // Client functions need to complete very quickly to prevent blocking upstream.
 func (g *Gateway) AddClient(name string, newClient func(leaf *ctree.Leaf), external bool) {
+	g.addClient(name, newClient, external, 1)
+}
+
+// addClient adds a client that is called from the specified number of
+// workers. newClient must be safe for concurrent use if workers is greater
+// than one.
+func (g *Gateway) addClient(name string, newClient func(leaf *ctree.Leaf), external bool, workers int) {
 	g.clientLock.Lock()
 	defer g.clientLock.Unlock()
-	g.clients = append(g.clients, NewCacheClient(name, newClient, external, g.config.GatewayTransitionBufferSize))
+	g.clients = append(g.clients, NewCacheClientWorkers(name, newClient, external, g.config.GatewayTransitionBufferSize, workers))
 }
 
 // StartGateway starts up all of the loaders and exporters provided by StartOpts. This is the
@@ -194,14 +324,30 @@ func (g *Gateway) StartGateway(opts *StartOpts) error {
 	}
 
 	// The finished channel to listens for errors from child goroutines.
-	// The first error will cause this function to return.
+	// The first error will cause this function to return; later errors are
+	// dropped so that the reporting goroutines don't block.
 	finished := make(chan error, 1)
+	report := func(err error) {
+		select {
+		case finished <- err:
+		default:
+		}
+	}
+
+	// Anything that was started is stopped again if the gateway fails to
+	// start so that the servers and goroutines aren't left running.
+	var running bool
+	defer func() {
+		if !running {
+			g.shutdown()
+		}
+	}()
 
 	var err error
 	var clusterMember string
-	if g.config.ZookeeperHosts != nil && len(g.config.ZookeeperHosts) > 0 {
+	if len(g.config.ZookeeperHosts) > 0 || g.config.RedisAddress != "" {
 		if !g.config.EnableGNMIServer {
-			return errors.New("gNMI server is required for clustering: Set -EnableGNMIServer or disable clustering by removing -ZookeeperHosts")
+			return errors.New("gNMI server is required for clustering: Set -EnableGNMIServer or disable clustering by removing -ZookeeperHosts or -RedisAddress")
 		}
 
 		if g.config.ServerAddress == "" {
@@ -217,24 +363,88 @@ func (g *Gateway) StartGateway(opts *StartOpts) error {
 
 		clusterMember = g.config.ServerAddress + ":" + strconv.Itoa(g.config.ServerPort)
 
-		g.zkConn, err = g.ConnectToZookeeper()
-		if err != nil {
-			g.config.Log.Error().Msgf("Unable to connect to Zookeeper: %v", err)
-			return err
+		if g.config.RedisAddress != "" {
+			g.redis, err = g.ConnectToRedis()
+			if err != nil {
+				return err
+			}
+			g.newLock = func(id string, member string) locking.DistributedLocker {
+				return locking.NewRedisNonBlockingLock(g.redis, id, member, g.config.RedisTTL)
+			}
+			g.cluster = clustering.NewRedisClusterMember(g.config, g.redis, clusterMember)
+		} else {
+			g.zkConn, err = g.ConnectToZookeeper()
+			if err != nil {
+				g.config.Log.Error().Msgf("Unable to connect to Zookeeper: %v", err)
+				return err
+			}
+			g.cluster = clustering.NewZookeeperClusterMember(g.config, g.zkConn, clusterMember)
 		}
-		g.cluster = clustering.NewZookeeperClusterMember(g.config, g.zkConn, clusterMember)
 		g.config.Log.Info().Msg("Clustering is enabled.")
 	} else {
 		g.config.Log.Info().Msg("Clustering is NOT enabled. No locking or cluster coordination will happen.")
 	}
 
+	if err := connections.ValidateTimestampPolicy(g.config.TimestampPolicy); err != nil {
+		return err
+	}
+	if err := connections.ValidateCompression(g.config.TargetCompression); err != nil {
+		return err
+	}
+	if err := connections.ValidateSchemaValidation(g.config.SchemaValidation); err != nil {
+		return err
+	}
+
+	if err := secrets.Enable(g.config); err != nil {
+		return err
+	}
+
+	if g.config.TracingOTLPEndpoint != "" {
+		if err := tracing.Enable(g.config); err != nil {
+			return err
+		}
+	}
+
+	if len(g.config.EventSinks) > 0 {
+		if err := events.Enable(g.config); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range g.config.Exporters.Enabled {
+		exporter := exporters.New(name, g.config)
+		if exporter == nil {
+			return fmt.Errorf("no registered exporter: '%s'", name)
+		}
+		opts.Exporters = append(opts.Exporters, exporter)
+	}
+	// The exporter stages are built before anything is started so that an
+	// invalid exporter configuration doesn't start the gateway.
+	stages, err := g.newExporterStages(opts.Exporters)
+	if err != nil {
+		return err
+	}
+	g.inventory, err = exporters.NewLabeler(g.config)
+	if err != nil {
+		return err
+	}
+	if g.inventory != nil {
+		if err := g.inventory.Start(); err != nil {
+			return fmt.Errorf("unable to load inventory: %v", err)
+		}
+	}
+
 	connZKEventChan := make(chan zk.Event, 1)
 	g.zkEventListeners = append(g.zkEventListeners, connZKEventChan)
-	g.connMgr, err = connections.NewZookeeperConnectionManagerDefault(g.config, g.zkConn, connZKEventChan)
+	connMgr, err := connections.NewZookeeperConnectionManagerDefault(g.config, g.zkConn, connZKEventChan)
 	if err != nil {
-		g.config.Log.Error().Msgf("Unable to create connection manager: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("unable to create connection manager: %v", err)
 	}
+	connMgr.Use(opts.Middlewares...)
+	if g.newLock != nil {
+		connMgr.SetLockFactory(g.newLock)
+	}
+	g.connMgr = connMgr
 	g.config.Log.Info().Msg("Starting connection manager.")
 	if err := g.connMgr.Start(); err != nil {
 		g.config.Log.Error().Msgf("Unable to start connection manager: %v", err)
@@ -242,6 +452,34 @@ func (g *Gateway) StartGateway(opts *StartOpts) error {
 	}
 	g.connMgr.Cache().SetClient(g.sendUpdateToClients)
 
+	if opts.Hooks.OnNotification != nil {
+		g.AddClient("hooks", func(leaf *ctree.Leaf) {
+			if notification, ok := leaf.Value().(*gnmi.Notification); ok {
+				opts.Hooks.OnNotification(notification)
+			}
+		}, false)
+	}
+
+	if g.config.RetentionDuration > 0 {
+		g.retention = retention.NewBuffer(g.config.RetentionDuration, g.config.RetentionSize)
+		g.AddClient("retention", g.retention.Update, false)
+		g.config.Log.Info().Msgf("Retaining up to %s of notifications per target.", g.config.RetentionDuration)
+		if g.cluster != nil {
+			go g.backfillRetention(clusterMember)
+		}
+	}
+
+	if g.config.EnableAdminServer {
+		g.admin = admin.NewServer(g.config)
+		g.registerAdminHandlers(g.admin)
+		go func() {
+			if err := g.admin.Start(); err != nil {
+				g.config.Log.Error().Msgf("Unable to start admin server: %v", err)
+				report(err)
+			}
+		}()
+	}
+
 	if g.config.EnableGNMIServer {
 		if g.config.ServerListenAddress == "" {
 			return fmt.Errorf("ServerListenAddress can't be empty with -EnableGNMIServer")
@@ -251,12 +489,38 @@ func (g *Gateway) StartGateway(opts *StartOpts) error {
 			return fmt.Errorf("ServerListenPort can't be empty with -EnableGNMIServer")
 		}
 
-		g.config.Log.Info().Msgf("Starting gNMI server on 0.0.0.0:%d.", g.config.ServerListenPort)
+		g.config.Log.Info().Msgf("Starting gNMI server on %s.", net.JoinHostPort(g.config.ServerListenAddress, strconv.Itoa(g.config.ServerListenPort)))
 		go func() {
 			stats.Registry.Counter("gnmigateway.server.started", stats.NoTags).Increment()
 			if err := g.StartGNMIServer(); err != nil {
 				g.config.Log.Error().Msgf("Unable to start gNMI server: %v", err)
-				finished <- err
+				report(err)
+			}
+		}()
+	}
+
+	if g.config.TunnelListener.Address != "" {
+		ts, err := newTunnelServer(g.config)
+		if err != nil {
+			return err
+		}
+		// The dialer is registered before the target loaders are started so
+		// that targets with UseTunnel aren't rejected.
+		g.tunnel = ts
+		connections.RegisterTunnelDialer(ts)
+		go func() {
+			if err := g.startTunnelServer(ts); err != nil {
+				g.config.Log.Error().Msgf("Unable to start tunnel server: %v", err)
+				report(err)
+			}
+		}()
+	}
+
+	if g.config.DialOutListener.Address != "" {
+		go func() {
+			if err := g.StartDialOutServer(); err != nil {
+				g.config.Log.Error().Msgf("Unable to start dial-out server: %v", err)
+				report(err)
 			}
 		}()
 	}
@@ -267,7 +531,7 @@ func (g *Gateway) StartGateway(opts *StartOpts) error {
 			//       connect before the gNMI server is up if we register too early.
 			err := g.cluster.Register()
 			if err != nil {
-				finished <- fmt.Errorf("unable to register this cluster member '%s': %v", clusterMember, err)
+				report(fmt.Errorf("unable to register this cluster member '%s': %v", clusterMember, err))
 			} else {
 				g.config.Log.Info().Msgf("Registered this cluster member as '%s'", clusterMember)
 			}
@@ -282,51 +546,256 @@ func (g *Gateway) StartGateway(opts *StartOpts) error {
 		}
 		opts.TargetLoaders = append(opts.TargetLoaders, loader)
 	}
-
-	for _, name := range g.config.Exporters.Enabled {
-		exporter := exporters.New(name, g.config)
-		if exporter == nil {
-			return fmt.Errorf("no registered exporter: '%s'", name)
+	for _, loader := range opts.TargetLoaders {
+		if reporter, ok := loader.(loaders.StatusReporter); ok {
+			reporter.SetStatusSource(g.connMgr.Targets)
+		}
+		if reader, ok := loader.(loaders.CacheReader); ok {
+			reader.SetCache(g.connMgr.Cache())
 		}
-		opts.Exporters = append(opts.Exporters, exporter)
 	}
 
+	g.loaders = opts.TargetLoaders
+	g.exporters = opts.Exporters
 	for _, loader := range opts.TargetLoaders {
 		go func(loader loaders.TargetLoader) {
 			err := loader.Start()
 			if err != nil {
 				g.config.Log.Error().Msgf("Unable to start target loader %T: %v", loader, err)
-				finished <- err
+				report(err)
+				return
 			}
 			stats.Registry.Counter("gnmigateway.loaders.started", stats.NoTags).Increment()
 			err = loader.WatchConfiguration(g.connMgr.TargetControlChan())
 			if err != nil {
-				finished <- fmt.Errorf("error during target loader %T watch: %v", loader, err)
+				report(fmt.Errorf("error during target loader %T watch: %v", loader, err))
 			}
 		}(loader)
 	}
 
-	for _, exporter := range opts.Exporters {
-		go func(exporter exporters.Exporter) {
+	for i, exporter := range opts.Exporters {
+		if labelExporter, ok := exporter.(exporters.LabelExporter); ok && g.inventory != nil {
+			labelExporter.SetLabels(g.inventory.Labels)
+		}
+		var election *locking.Election
+		if leaderExporter, ok := exporter.(exporters.LeaderExporter); ok {
+			election = g.newExporterElection(leaderExporter)
+			g.elections = append(g.elections, election)
+		}
+		go func(exporter exporters.Exporter, stages exporterStages) {
 			err := exporter.Start(g.connMgr.Cache())
 			if err != nil {
 				err = fmt.Errorf("unable to start exporter '%s': %v", exporter.Name(), err)
 				g.config.Log.Error().Msg(err.Error())
-				finished <- err
+				report(err)
+				return
+			}
+			export := stages.filter.Wrap(exporter.Name(), stages.calculator.Wrap(stages.aggregator.Wrap(exporter.Name(), exporter.Export)))
+			var notify func(target string, synced bool)
+			if syncExporter, ok := exporter.(exporters.SyncExporter); ok {
+				notify = syncExporter.TargetSynced
 			}
-			g.AddClient(exporter.Name(), exporter.Export, true)
+			// The barrier is outside of the filter so that it sees the
+			// synced meta leaves of all targets.
+			export = stages.barrier.Wrap(exporter.Name(), export, notify)
+			if election != nil {
+				go election.Run()
+				export = leaderOnly(election, export)
+			}
+			workers := 1
+			if _, ok := exporter.(exporters.ConcurrentExporter); ok {
+				workers = g.config.FanoutWorkers
+			}
+			g.addClient(exporter.Name(), export, true, workers)
 			stats.Registry.Counter("gnmigateway.exporters.started", stats.NoTags).Increment()
-		}(exporter)
+		}(exporter, stages[i])
 	}
 
 	stats.Registry.Counter("gnmigateway.started", stats.NoTags).Increment()
-	err = <-finished
+	if opts.Hooks.OnStarted != nil {
+		opts.Hooks.OnStarted(g)
+	}
+
+	running = true
+	select {
+	case err = <-finished:
+	case <-g.stop:
+		err = nil
+	}
+	g.shutdown()
 	stats.Registry.Counter("gnmigateway.stopped", stats.NoTags).Increment()
+	if opts.Hooks.OnStopped != nil {
+		opts.Hooks.OnStopped(err)
+	}
 	return err
 }
 
+// exporterStages are the stages that the notifications pass through before
+// they're exported.
+type exporterStages struct {
+	filter     *exporters.Filter
+	calculator *rates.Calculator
+	aggregator *exporters.Aggregator
+	barrier    *exporters.SyncBarrier
+}
+
+// newExporterStages builds the stages of each exporter from the gateway
+// configuration.
+func (g *Gateway) newExporterStages(exps []exporters.Exporter) ([]exporterStages, error) {
+	windows, err := maintenance.New(g.config.MaintenanceWindows)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance windows: %v", err)
+	}
+	stages := make([]exporterStages, len(exps))
+	for i, exporter := range exps {
+		stages[i].filter, err = exporters.NewFilter(g.config.Exporters.Filters[exporter.Name()], windows)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter for exporter '%s': %v", exporter.Name(), err)
+		}
+		stages[i].aggregator, err = exporters.NewAggregator(g.config.Exporters.Aggregations[exporter.Name()])
+		if err != nil {
+			return nil, fmt.Errorf("invalid aggregation for exporter '%s': %v", exporter.Name(), err)
+		}
+		stages[i].calculator, err = rates.New(g.config.CounterRates, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter rates for exporter '%s': %v", exporter.Name(), err)
+		}
+		stages[i].barrier, err = exporters.NewSyncBarrier(g.config.Exporters.SyncBarriers[exporter.Name()], g.targetSynced)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sync barrier for exporter '%s': %v", exporter.Name(), err)
+		}
+	}
+	return stages, nil
+}
+
+// retentionBackfillTimeout bounds the time spent backfilling the retention
+// buffer from the other cluster members.
+const retentionBackfillTimeout = time.Minute
+
+// backfillRetention loads the notifications retained by another cluster
+// member into the retention buffer so that the history of the targets that
+// fail over to this member isn't lost. The members are tried in turn until one
+// of them returns its history, for at most retentionBackfillTimeout. The
+// members are dialed with ClientTLSConfig so that they can authenticate this
+// member by its client certificate.
+func (g *Gateway) backfillRetention(self string) {
+	if g.config.ClientTLSConfig == nil {
+		g.config.Log.Warn().Msg("ClientTLSConfig isn't set; not backfilling the retention buffer from the other cluster members.")
+		return
+	}
+	members, err := g.cluster.MemberList()
+	if err != nil {
+		g.config.Log.Warn().Msgf("Unable to list the cluster members to backfill the retention buffer: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), retentionBackfillTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-g.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	end := time.Now()
+	start := end.Add(-g.config.RetentionDuration)
+	for _, member := range members {
+		if string(member) == self {
+			continue
+		}
+		notifications, err := retention.Fetch(ctx, string(member), g.config.ClientTLSConfig, start.UnixNano(), end.UnixNano())
+		if err != nil {
+			g.config.Log.Warn().Msgf("Unable to backfill the retention buffer from cluster member '%s': %v", member, err)
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		added := g.retention.Backfill(notifications)
+		g.config.Log.Info().Msgf("Backfilled %d notifications into the retention buffer from cluster member '%s'.", added, member)
+		return
+	}
+}
+
+// shutdown stops the loaders and servers, disconnects from all targets, stops
+// the exporters, and closes the Zookeeper connection so that other cluster
+// members can take over the targets.
+func (g *Gateway) shutdown() {
+	g.config.Log.Info().Msg("Stopping GNMI Gateway.")
+	g.Stop()
+
+	for _, loader := range g.loaders {
+		loader.Stop()
+	}
+
+	g.gnmiServerLock.Lock()
+	for _, srv := range g.grpcServers {
+		srv.Stop()
+	}
+	g.grpcServers = nil
+	g.gnmiServerLock.Unlock()
+
+	if g.admin != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := g.admin.Shutdown(ctx); err != nil {
+			g.config.Log.Error().Msgf("Error stopping admin server: %v", err)
+		}
+		cancel()
+	}
+
+	if g.connMgr != nil {
+		g.connMgr.Stop()
+	}
+
+	for _, exporter := range g.exporters {
+		exporter.Stop()
+	}
+	// Leadership is released after the exporters are stopped so that a new
+	// leader doesn't start exporting while the old one is still flushing.
+	for _, election := range g.elections {
+		election.Stop()
+	}
+
+	if g.inventory != nil {
+		g.inventory.Stop()
+	}
+
+	if g.tunnel != nil {
+		connections.RegisterTunnelDialer(nil)
+	}
+
+	if g.zkConn != nil {
+		g.zkConn.Close()
+	}
+	if g.redis != nil {
+		_ = g.redis.Close()
+	}
+
+	events.Disable()
+	tracing.Disable()
+}
+
+// targetSynced returns true if the target's synced meta leaf in the cache is
+// true.
+func (g *Gateway) targetSynced(target string) bool {
+	var synced bool
+	_ = g.connMgr.Cache().Query(target, []string{"meta", "gateway", "synced"}, func(_ []string, _ *ctree.Leaf, val interface{}) error {
+		if notification, ok := val.(*gnmi.Notification); ok && len(notification.Update) > 0 {
+			synced = notification.Update[0].GetVal().GetBoolVal()
+		}
+		return nil
+	})
+	return synced
+}
+
 func (g *Gateway) sendUpdateToClients(leaf *ctree.Leaf) {
-	for _, client := range g.clients {
+	// Clients are only appended, so the slice read under the lock stays valid
+	// while new clients are added.
+	g.clientLock.RLock()
+	clients := g.clients
+	g.clientLock.RUnlock()
+	for _, client := range clients {
 		if client.External {
 			notification := leaf.Value().(*gnmi.Notification)
 			target := notification.GetPrefix().GetTarget()
@@ -347,24 +816,14 @@ func (g *Gateway) sendUpdateToClients(leaf *ctree.Leaf) {
 //}
 
 // StartGNMIServer will start the gNMI server that serves the Subscribe
-// interface to downstream gNMI clients.
+// interface to downstream gNMI clients. The server listens on
+// ServerListenAddress:ServerListenPort and on any additional ServerListeners.
 func (g *Gateway) StartGNMIServer() error {
-	if g.config.ServerTLSCreds == nil {
-		if g.config.ServerTLSCert == "" || g.config.ServerTLSKey == "" {
-			return fmt.Errorf("no TLS creds: you must specify a ServerTLSCert and ServerTLSKey")
-		}
-
-		// Initialize TLS credentials.
-		creds, err := credentials.NewServerTLSFromFile(g.config.ServerTLSCert, g.config.ServerTLSKey)
-		if err != nil {
-			return fmt.Errorf("failed to generate credentials: %v", err)
-		}
-		g.config.ServerTLSCreds = creds
+	listeners, err := g.serverListeners()
+	if err != nil {
+		return err
 	}
 
-	// Create a grpc Server.
-	srv := grpc.NewServer(grpc.Creds(g.config.ServerTLSCreds))
-	reflection.Register(srv)
 	// Initialize gNMI Proxy Subscribe server.
 	gnmiServerOpts := &server.GNMIServerOpts{
 		Config:  g.config,
@@ -372,26 +831,121 @@ func (g *Gateway) StartGNMIServer() error {
 		Cluster: g.cluster,
 		ConnMgr: g.connMgr,
 	}
-	subscribeSrv, err := server.NewServer(gnmiServerOpts)
+	if g.retention != nil {
+		gnmiServerOpts.History = g.retention
+	}
+	decider, err := server.NewPolicyDecider(g.config)
 	if err != nil {
-		return fmt.Errorf("Could not instantiate gNMI server: %v", err)
+		return fmt.Errorf("Could not create the policy decision point: %v", err)
+	}
+	var subscribeSrv gnmiServer
+	if len(g.config.Tenants) > 0 {
+		if decider != nil {
+			return fmt.Errorf("Tenants can't be combined with PolicyURL or PolicyRegoFile")
+		}
+		subscribeSrv, err = server.NewTenantServer(gnmiServerOpts, g.config.Tenants, g.connMgr.TargetTenant)
+		if err != nil {
+			return fmt.Errorf("Could not instantiate gNMI server: %v", err)
+		}
+	} else {
+		srv, err := server.NewServer(gnmiServerOpts)
+		if err != nil {
+			return fmt.Errorf("Could not instantiate gNMI server: %v", err)
+		}
+		if decider != nil {
+			srv.SetACL(server.NewPolicyACL(decider, g.config.PolicyTimeout, g.config.Log))
+		}
+		subscribeSrv = srv
+	}
+	g.gnmiServerLock.Lock()
+	g.gnmiServer = subscribeSrv
+	g.gnmiServerLock.Unlock()
+
+	var accessLogOpts []grpc.ServerOption
+	if g.config.ServerAccessLog != "" {
+		accessLog, err := server.NewAccessLog(g.config.ServerAccessLog, g.config.Log)
+		if err != nil {
+			return err
+		}
+		defer accessLog.Close()
+		accessLogOpts = append(accessLogOpts,
+			grpc.StreamInterceptor(accessLog.StreamInterceptor()),
+			grpc.UnaryInterceptor(accessLog.UnaryInterceptor()),
+		)
 	}
-	gnmi.RegisterGNMIServer(srv, subscribeSrv)
+
+	for _, l := range listeners {
+		// Create a grpc Server for each listener since the transport
+		// credentials are set per server.
+		opts := append(g.serverOptions(), accessLogOpts...)
+		if l.creds != nil {
+			opts = append(opts, grpc.Creds(l.creds))
+		}
+		if l.idleHeartbeat > 0 {
+			opts = append(opts, grpc.ChainStreamInterceptor(server.IdleHeartbeatInterceptor(l.idleHeartbeat, l.idleHeartbeatMessage)))
+		}
+		srv := grpc.NewServer(opts...)
+		g.gnmiServerLock.Lock()
+		g.grpcServers = append(g.grpcServers, srv)
+		g.gnmiServerLock.Unlock()
+		g.registerServices(srv, subscribeSrv)
+		// Register listening port and start serving.
+		lis, err := l.listen()
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", l.address, err)
+		}
+		g.config.Log.Info().Msgf("gNMI server listening on %s (%s).", l.address, l.description)
+		go func() {
+			err := srv.Serve(lis) // blocks
+			if err != nil {
+				g.config.Log.Error().Msgf("Error running gNMI server: %v", err)
+			}
+		}()
+		defer srv.Stop()
+	}
+
 	// Forward streaming updates to clients.
-	g.AddClient("gnmi_server", subscribeSrv.Update, false)
-	// Register listening port and start serving.
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", g.config.ServerListenPort))
-	if err != nil {
-		return fmt.Errorf("failed to listen: %v", err)
+	g.addClient("gnmi_server", subscribeSrv.Update, false, g.config.FanoutWorkers)
+	<-g.stop
+	return nil
+}
+
+// registerServices registers the gNMI service and, if ServerReflection is
+// enabled, the gRPC reflection service on srv.
+func (g *Gateway) registerServices(srv *grpc.Server, gnmiServer gnmi.GNMIServer) {
+	gnmi.RegisterGNMIServer(srv, gnmiServer)
+	if g.config.ServerReflection {
+		reflection.Register(srv)
 	}
-	go func() {
-		err := srv.Serve(lis) // blocks
-		g.config.Log.Error().Msgf("Error running gNMI server: %v", err)
-	}()
-	defer srv.Stop()
-	ctx := context.Background()
-	<-ctx.Done()
-	return ctx.Err()
+}
+
+// serverOptions returns the gRPC server tuning options set in the configuration.
+func (g *Gateway) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if g.config.ServerMaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(g.config.ServerMaxConcurrentStreams))
+	}
+	if g.config.ServerMaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(g.config.ServerMaxRecvMsgSize))
+	}
+	if g.config.ServerMaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(g.config.ServerMaxSendMsgSize))
+	}
+	if g.config.ServerKeepaliveMinTime > 0 || g.config.ServerKeepalivePermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             g.config.ServerKeepaliveMinTime,
+			PermitWithoutStream: g.config.ServerKeepalivePermitWithoutStream,
+		}))
+	}
+	// Zero values in ServerParameters use the gRPC defaults.
+	opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+		MaxConnectionIdle:     g.config.ServerMaxConnectionIdle,
+		MaxConnectionAge:      g.config.ServerMaxConnectionAge,
+		MaxConnectionAgeGrace: g.config.ServerMaxConnectionAgeGrace,
+		Time:                  g.config.ServerKeepaliveTime,
+		Timeout:               g.config.ServerKeepaliveTimeout,
+	}))
+	return opts
 }
 
 type ZKLogger struct {
@@ -437,6 +991,35 @@ func (g *Gateway) zookeeperEventHandler(zkEventChan <-chan zk.Event) {
 	}
 }
 
+// defaultRedisTTL is used if RedisTTL isn't set.
+const defaultRedisTTL = 10 * time.Second
+
+// ConnectToRedis connects to the Redis server used for clustering. RedisTTL
+// is set to defaultRedisTTL if it isn't set.
+func (g *Gateway) ConnectToRedis() (*redis.Client, error) {
+	if g.config.RedisTTL <= 0 {
+		g.config.RedisTTL = defaultRedisTTL
+	}
+	opts := redis.Options{
+		Address:  g.config.RedisAddress,
+		Password: g.config.RedisPassword,
+		DB:       g.config.RedisDB,
+		// leave time to renew the locks before they expire
+		Timeout: g.config.RedisTTL / 3,
+	}
+	if g.config.RedisTLS {
+		opts.TLS = &tls.Config{}
+	}
+	g.config.Log.Info().Msgf("Connecting to Redis at %s.", g.config.RedisAddress)
+	client := redis.NewClient(opts)
+	if err := client.Ping(); err != nil {
+		g.config.Log.Error().Msgf("Unable to connect to Redis: %v", err)
+		return nil, err
+	}
+	g.config.Log.Info().Msg("Redis connected.")
+	return client, nil
+}
+
 // Get the first non-loopback IP address from the local system.
 func getLocalIP() (string, error) {
 	addresses, err := net.InterfaceAddrs()
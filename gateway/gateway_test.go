@@ -18,9 +18,18 @@ package gateway
 import (
 	"fmt"
 	"os"
+	"sync"
+	"testing"
 	"time"
 
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters"
 	"github.com/openconfig/gnmi-gateway/gateway/exporters/prometheus"
 	"github.com/openconfig/gnmi-gateway/gateway/loaders"
@@ -58,3 +67,174 @@ func Example() {
 		os.Exit(1)
 	}
 }
+
+// An example of embedding the gateway in another program with a custom
+// exporter and lifecycle hooks.
+func ExampleGateway_Start() {
+	config := configuration.NewDefaultGatewayConfig()
+	config.TargetLoaders.Enabled = []string{"json"}
+	config.TargetLoaders.JSONFile = "targets.json"
+
+	gateway := NewGateway(config)
+	result := gateway.Start(&StartOpts{
+		Exporters: []exporters.Exporter{prometheus.NewPrometheusExporter(config)},
+		Hooks: Hooks{
+			OnStarted: func(g *Gateway) {
+				fmt.Println("Gateway started")
+			},
+			OnNotification: func(notification *gnmi.Notification) {
+				// Handle notifications without writing an Exporter.
+			},
+		},
+	})
+
+	// Run until some condition in the embedding program is met.
+	time.Sleep(time.Minute)
+	gateway.Stop()
+	if err := <-result; err != nil {
+		fmt.Printf("Gateway exited with an error: %v", err)
+	}
+}
+
+// staticLoader sends a single configuration and waits to be stopped.
+type staticLoader struct {
+	config   *targetpb.Configuration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *staticLoader) GetConfiguration() (*targetpb.Configuration, error) {
+	return l.config, nil
+}
+
+func (l *staticLoader) Start() error {
+	return nil
+}
+
+func (l *staticLoader) WatchConfiguration(targetChan chan<- *connections.TargetConnectionControl) error {
+	targetChan <- &connections.TargetConnectionControl{Insert: l.config}
+	<-l.stop
+	return nil
+}
+
+func (l *staticLoader) Stop() {
+	l.stopOnce.Do(func() { close(l.stop) })
+}
+
+func TestGateway_StartStop(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.Log = zerolog.Nop()
+	config.TargetDialTimeout = 100 * time.Millisecond
+	config.TargetLimit = 10
+
+	loader := &staticLoader{
+		config: &targetpb.Configuration{
+			Request: map[string]*gnmi.SubscribeRequest{
+				"default": {
+					Request: &gnmi.SubscribeRequest_Subscribe{
+						Subscribe: &gnmi.SubscriptionList{
+							Subscription: []*gnmi.Subscription{{Path: &gnmi.Path{}}},
+						},
+					},
+				},
+			},
+			Target: map[string]*targetpb.Target{
+				"router": {Addresses: []string{"127.0.0.1:1"}, Request: "default"},
+			},
+		},
+		stop: make(chan struct{}),
+	}
+
+	gateway := NewGateway(config)
+	started := make(chan struct{})
+	result := gateway.Start(&StartOpts{
+		TargetLoaders: []loaders.TargetLoader{loader},
+		Hooks: Hooks{
+			OnStarted: func(g *Gateway) { close(started) },
+		},
+	})
+	<-started
+	assertion.Eventually(func() bool {
+		return len(gateway.ConnectionManager().Targets()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	gateway.Stop()
+	select {
+	case err := <-result:
+		assertion.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("gateway didn't stop")
+	}
+
+	assertion.Empty(gateway.ConnectionManager().Targets())
+	select {
+	case <-loader.stop:
+	default:
+		t.Error("target loader wasn't stopped")
+	}
+}
+
+func TestCacheClientWorkers(t *testing.T) {
+	const targets, updates = 10, 100
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(targets * updates)
+	last := make(map[string]int64)
+	outOfOrder := 0
+	client := NewCacheClientWorkers("test", func(leaf *ctree.Leaf) {
+		notification := leaf.Value().(*gnmi.Notification)
+		mutex.Lock()
+		target := notification.GetPrefix().GetTarget()
+		if notification.Timestamp <= last[target] {
+			outOfOrder++
+		}
+		last[target] = notification.Timestamp
+		mutex.Unlock()
+		wg.Done()
+	}, false, 10, 4)
+	assert.Len(t, client.buffers, 4)
+
+	for i := 1; i <= updates; i++ {
+		for j := 0; j < targets; j++ {
+			client.Send(ctree.DetachedLeaf(&gnmi.Notification{
+				Timestamp: int64(i),
+				Prefix:    &gnmi.Path{Target: fmt.Sprintf("target%d", j)},
+			}))
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, 0, outOfOrder)
+	assert.Len(t, last, targets)
+}
+
+func TestGateway_addClient_whileSending(t *testing.T) {
+	const clients, updates = 10, 100
+	g := NewGateway(&configuration.GatewayConfig{GatewayTransitionBufferSize: 10})
+	var wg sync.WaitGroup
+	wg.Add(updates)
+	g.addClient("first", func(leaf *ctree.Leaf) {
+		wg.Done()
+	}, false, 1)
+
+	// Clients may be added while updates are sent to the existing clients.
+	added := make(chan struct{})
+	go func() {
+		for i := 0; i < clients; i++ {
+			g.addClient(fmt.Sprintf("client%d", i), func(leaf *ctree.Leaf) {}, false, 1)
+		}
+		close(added)
+	}()
+	for i := 0; i < updates; i++ {
+		g.sendUpdateToClients(ctree.DetachedLeaf(&gnmi.Notification{
+			Timestamp: int64(i),
+			Prefix:    &gnmi.Path{Target: "a"},
+		}))
+	}
+	<-added
+	wg.Wait()
+	g.clientLock.RLock()
+	assert.Len(t, g.clients, clients+1)
+	g.clientLock.RUnlock()
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inventory loads labels for targets (e.g. site, role, and region)
+// from an external inventory so that exporters can add them to their outputs
+// and downstream systems don't each need their own enrichment join.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+)
+
+const defaultReloadInterval = 5 * time.Minute
+
+func init() {
+	exporters.RegisterLabeler(func(config *configuration.GatewayConfig) exporters.Labeler {
+		// A nil *Inventory would be a non-nil Labeler.
+		if inventory := New(config); inventory != nil {
+			return inventory
+		}
+		return nil
+	})
+}
+
+// Source loads the labels of targets keyed by target name.
+type Source interface {
+	Load() (map[string]map[string]string, error)
+}
+
+// Inventory holds the labels of targets loaded from the configured sources.
+// The labels are reloaded periodically once Start is called.
+type Inventory struct {
+	config   *configuration.GatewayConfig
+	sources  []Source
+	interval time.Duration
+
+	mutex  sync.RWMutex
+	labels map[string]map[string]string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns an Inventory for the InventoryFile and InventoryNetBox
+// configuration. New returns nil if no inventory source is configured.
+func New(config *configuration.GatewayConfig) *Inventory {
+	var sources []Source
+	if config.InventoryFile != "" {
+		sources = append(sources, &FileSource{File: config.InventoryFile})
+	}
+	if config.InventoryNetBox {
+		sources = append(sources, NewNetBoxSource(config))
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return NewInventory(config, sources...)
+}
+
+// NewInventory returns an Inventory that loads labels from sources. Labels
+// from later sources override the labels with the same name from earlier
+// sources.
+func NewInventory(config *configuration.GatewayConfig, sources ...Source) *Inventory {
+	interval := config.InventoryReloadInterval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	return &Inventory{
+		config:   config,
+		sources:  sources,
+		interval: interval,
+		labels:   make(map[string]map[string]string),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start loads the labels and then reloads them every InventoryReloadInterval
+// until Stop is called. An error is returned if the labels can't be loaded.
+func (i *Inventory) Start() error {
+	if err := i.Reload(); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(i.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := i.Reload(); err != nil {
+					i.config.Log.Error().Msgf("Unable to reload inventory: %v", err)
+				}
+			case <-i.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops reloading the labels.
+func (i *Inventory) Stop() {
+	i.stopOnce.Do(func() { close(i.stop) })
+}
+
+// Reload loads the labels from every source. The current labels are kept if
+// any of the sources fail.
+func (i *Inventory) Reload() error {
+	labels := make(map[string]map[string]string)
+	for _, source := range i.sources {
+		loaded, err := source.Load()
+		if err != nil {
+			return err
+		}
+		for target, targetLabels := range loaded {
+			if labels[target] == nil {
+				labels[target] = make(map[string]string)
+			}
+			for name, value := range targetLabels {
+				labels[target][name] = value
+			}
+		}
+	}
+	i.mutex.Lock()
+	i.labels = labels
+	i.mutex.Unlock()
+	return nil
+}
+
+// Labels returns the labels of target. If InventoryLabels is set only those
+// labels are returned and every one of them is included, empty if the target
+// doesn't have it, so that all outputs have the same set of labels. Labels
+// returns nil if the Inventory is nil. The returned map must not be modified.
+func (i *Inventory) Labels(target string) map[string]string {
+	if i == nil {
+		return nil
+	}
+	i.mutex.RLock()
+	targetLabels := i.labels[target]
+	i.mutex.RUnlock()
+	if len(i.config.InventoryLabels) == 0 {
+		return targetLabels
+	}
+	labels := make(map[string]string, len(i.config.InventoryLabels))
+	for _, name := range i.config.InventoryLabels {
+		labels[name] = targetLabels[name]
+	}
+	return labels
+}
+
+// FileSource loads labels from a JSON file that maps target names to labels:
+//		{"router1": {"site": "ams1", "role": "spine", "region": "eu"}}
+type FileSource struct {
+	File string
+}
+
+// Load implements Source.
+func (f *FileSource) Load() (map[string]map[string]string, error) {
+	data, err := ioutil.ReadFile(f.File)
+	if err != nil {
+		return nil, fmt.Errorf("could not read inventory file %q: %v", f.File, err)
+	}
+	labels := make(map[string]map[string]string)
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("could not parse inventory file %q: %v", f.File, err)
+	}
+	return labels, nil
+}
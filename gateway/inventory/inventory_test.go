@@ -0,0 +1,63 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+type staticSource map[string]map[string]string
+
+func (s staticSource) Load() (map[string]map[string]string, error) {
+	return s, nil
+}
+
+func TestInventory_Labels(t *testing.T) {
+	assertion := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "inventory")
+	assertion.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "inventory.json")
+	assertion.NoError(ioutil.WriteFile(file, []byte(`{"a": {"site": "ams1", "role": "spine"}}`), 0644))
+
+	config := &configuration.GatewayConfig{}
+	inventory := NewInventory(config, &FileSource{File: file}, staticSource{"a": {"role": "leaf"}, "b": {"region": "eu"}})
+	assertion.NoError(inventory.Reload())
+
+	assertion.Equal(map[string]string{"site": "ams1", "role": "leaf"}, inventory.Labels("a"))
+	assertion.Equal(map[string]string{"region": "eu"}, inventory.Labels("b"))
+	assertion.Nil(inventory.Labels("c"))
+
+	config.InventoryLabels = []string{"site", "region"}
+	assertion.Equal(map[string]string{"site": "ams1", "region": ""}, inventory.Labels("a"))
+	assertion.Equal(map[string]string{"site": "", "region": ""}, inventory.Labels("c"))
+
+	var missing *Inventory
+	assertion.Nil(missing.Labels("a"))
+}
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(&configuration.GatewayConfig{}))
+	assert.NotNil(t, New(&configuration.GatewayConfig{InventoryFile: "inventory.json"}))
+}
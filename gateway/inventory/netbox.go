@@ -0,0 +1,87 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/netbox-community/go-netbox/netbox"
+	"github.com/netbox-community/go-netbox/netbox/client"
+	"github.com/netbox-community/go-netbox/netbox/client/dcim"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// NetBoxSource loads the site, role, and region labels of the devices in
+// NetBox. The NetBox host, API key, and include tag of the NetBox target
+// loader are used.
+type NetBoxSource struct {
+	config *configuration.GatewayConfig
+	client *client.NetBoxAPI
+}
+
+// NewNetBoxSource returns a NetBoxSource for the NetBox instance configured
+// for the NetBox target loader.
+func NewNetBoxSource(config *configuration.GatewayConfig) *NetBoxSource {
+	return &NetBoxSource{
+		config: config,
+		client: netbox.NewNetboxWithAPIKey(config.TargetLoaders.NetBoxHost, config.TargetLoaders.NetBoxAPIKey),
+	}
+}
+
+// Load implements Source.
+func (n *NetBoxSource) Load() (map[string]map[string]string, error) {
+	sites, err := n.client.Dcim.DcimSitesList(&dcim.DcimSitesListParams{Context: context.Background()}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list sites in NetBox: %v", err)
+	}
+	regions := make(map[int64]string)
+	for _, site := range sites.GetPayload().Results {
+		if site.Region != nil && site.Region.Name != nil {
+			regions[site.ID] = *site.Region.Name
+		}
+	}
+
+	params := &dcim.DcimDevicesListParams{Context: context.Background()}
+	if n.config.TargetLoaders.NetBoxIncludeTag != "" {
+		params.Tag = &n.config.TargetLoaders.NetBoxIncludeTag
+	}
+	devices, err := n.client.Dcim.DcimDevicesList(params, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list devices in NetBox: %v", err)
+	}
+	labels := make(map[string]map[string]string)
+	for _, device := range devices.GetPayload().Results {
+		if device.Name == nil {
+			continue
+		}
+		deviceLabels := make(map[string]string)
+		if device.Site != nil {
+			if device.Site.Name != nil {
+				deviceLabels["site"] = *device.Site.Name
+			}
+			if region, exists := regions[device.Site.ID]; exists {
+				deviceLabels["region"] = region
+			}
+		}
+		if device.DeviceRole != nil && device.DeviceRole.Name != nil {
+			deviceLabels["role"] = *device.DeviceRole.Name
+		}
+		labels[*device.Name] = deviceLabels
+	}
+	return labels, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// serverListener is an address the gNMI server listens on.
+type serverListener struct {
+	network     string
+	address     string
+	creds       credentials.TransportCredentials
+	description string
+	// mode is the file mode of Unix domain sockets.
+	mode os.FileMode
+	// idleHeartbeat is the idle heartbeat interval of the listener's
+	// Subscribe RPCs; zero disables idle heartbeats.
+	idleHeartbeat        time.Duration
+	idleHeartbeatMessage string
+}
+
+// serverListeners returns the primary gNMI server listener followed by any
+// additional listeners in ServerListeners.
+func (g *Gateway) serverListeners() ([]serverListener, error) {
+	if g.config.ServerTLSCreds == nil {
+		if g.config.ServerTLSCert == "" || g.config.ServerTLSKey == "" {
+			return nil, fmt.Errorf("no TLS creds: you must specify a ServerTLSCert and ServerTLSKey")
+		}
+
+		// Initialize TLS credentials.
+		creds, err := credentials.NewServerTLSFromFile(g.config.ServerTLSCert, g.config.ServerTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate credentials: %v", err)
+		}
+		g.config.ServerTLSCreds = creds
+	}
+
+	listeners := []serverListener{{
+		network:     "tcp",
+		address:     net.JoinHostPort(g.config.ServerListenAddress, strconv.Itoa(g.config.ServerListenPort)),
+		creds:       g.config.ServerTLSCreds,
+		description: "TLS",
+
+		idleHeartbeat:        g.config.ServerIdleHeartbeat,
+		idleHeartbeatMessage: g.config.ServerIdleHeartbeatMessage,
+	}}
+	for i, config := range g.config.ServerListeners {
+		l, err := g.newServerListener(config)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server listener %d: %v", i, err)
+		}
+		listeners = append(listeners, l)
+	}
+	if g.config.ServerSocketPath != "" {
+		var mode uint64
+		if g.config.ServerSocketMode != "" {
+			var err error
+			mode, err = strconv.ParseUint(g.config.ServerSocketMode, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ServerSocketMode '%s': %v", g.config.ServerSocketMode, err)
+			}
+		}
+		listeners = append(listeners, serverListener{
+			network:     "unix",
+			address:     g.config.ServerSocketPath,
+			description: "Unix socket",
+			mode:        os.FileMode(mode),
+
+			idleHeartbeat:        g.config.ServerIdleHeartbeat,
+			idleHeartbeatMessage: g.config.ServerIdleHeartbeatMessage,
+		})
+	}
+	return listeners, nil
+}
+
+// listen creates the network listener. Stale Unix domain socket files are
+// removed before listening and the socket's file mode is set afterwards.
+func (l serverListener) listen() (net.Listener, error) {
+	if l.network != "unix" {
+		return net.Listen(l.network, l.address)
+	}
+	if err := os.Remove(l.address); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to remove existing socket: %v", err)
+	}
+	lis, err := net.Listen(l.network, l.address)
+	if err != nil {
+		return nil, err
+	}
+	if l.mode != 0 {
+		if err := os.Chmod(l.address, l.mode); err != nil {
+			_ = lis.Close()
+			return nil, fmt.Errorf("unable to set socket permissions: %v", err)
+		}
+	}
+	return lis, nil
+}
+
+// newServerListener creates a serverListener from the configuration. Insecure
+// listeners on non-loopback addresses are allowed but logged as a warning.
+func (g *Gateway) newServerListener(config configuration.ServerListener) (serverListener, error) {
+	l := serverListener{
+		network:              "tcp",
+		address:              config.Address,
+		idleHeartbeat:        config.IdleHeartbeat,
+		idleHeartbeatMessage: config.IdleHeartbeatMessage,
+	}
+	if config.Address == "" {
+		return l, errors.New("address is required")
+	}
+	host, _, err := net.SplitHostPort(config.Address)
+	if err != nil {
+		return l, fmt.Errorf("invalid address '%s': %v", config.Address, err)
+	}
+
+	if config.Insecure {
+		ip := net.ParseIP(host)
+		if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			g.config.Log.Warn().Msgf("gNMI server listener on %s does not use TLS and is not restricted to localhost", config.Address)
+		}
+		l.description = "insecure"
+		return l, nil
+	}
+
+	certFile, keyFile := config.TLSCert, config.TLSKey
+	if certFile == "" && keyFile == "" {
+		certFile, keyFile = g.config.ServerTLSCert, g.config.ServerTLSKey
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return l, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	l.description = "TLS"
+
+	if config.ClientCA != "" {
+		pem, err := ioutil.ReadFile(config.ClientCA)
+		if err != nil {
+			return l, fmt.Errorf("failed to read client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return l, fmt.Errorf("no certificates found in client CA file '%s'", config.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		l.description = "mTLS"
+	}
+	l.creds = credentials.NewTLS(tlsConfig)
+	return l, nil
+}
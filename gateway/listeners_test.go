@@ -0,0 +1,133 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestGateway_newServerListener(t *testing.T) {
+	assertion := assert.New(t)
+	g := NewGateway(configuration.NewDefaultGatewayConfig())
+
+	l, err := g.newServerListener(configuration.ServerListener{Address: "127.0.0.1:9340", Insecure: true})
+	assertion.NoError(err)
+	assertion.Equal("tcp", l.network)
+	assertion.Equal("127.0.0.1:9340", l.address)
+	assertion.Nil(l.creds)
+	assertion.Zero(l.idleHeartbeat)
+
+	l, err = g.newServerListener(configuration.ServerListener{Address: "127.0.0.1:9340", Insecure: true, IdleHeartbeat: time.Minute, IdleHeartbeatMessage: "sync"})
+	assertion.NoError(err)
+	assertion.Equal(time.Minute, l.idleHeartbeat)
+	assertion.Equal("sync", l.idleHeartbeatMessage)
+
+	_, err = g.newServerListener(configuration.ServerListener{Insecure: true})
+	assertion.Error(err)
+
+	_, err = g.newServerListener(configuration.ServerListener{Address: "9340", Insecure: true})
+	assertion.Error(err)
+
+	_, err = g.newServerListener(configuration.ServerListener{Address: "[::1]:9340", TLSCert: "missing.crt", TLSKey: "missing.key"})
+	assertion.Error(err)
+}
+
+func TestServerListener_listen(t *testing.T) {
+	assertion := assert.New(t)
+	dir, err := ioutil.TempDir("", "gnmi-gateway")
+	assertion.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gnmi.sock")
+	// A stale socket file is replaced.
+	assertion.NoError(ioutil.WriteFile(path, nil, 0600))
+
+	l := serverListener{network: "unix", address: path, mode: 0600}
+	lis, err := l.listen()
+	assertion.NoError(err)
+	defer lis.Close()
+
+	info, err := os.Stat(path)
+	assertion.NoError(err)
+	assertion.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+// listServices lists the services of a gNMI server registered with
+// registerServices using the reflection service.
+func listServices(t *testing.T, config *configuration.GatewayConfig) ([]string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	NewGateway(config).registerServices(srv, &gnmi.UnimplementedGNMIServer{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	return services, nil
+}
+
+func TestGateway_registerServices(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	_, err := listServices(t, config)
+	assertion.Error(err, "reflection is disabled by default")
+
+	config.ServerReflection = true
+	services, err := listServices(t, config)
+	assertion.NoError(err)
+	assertion.Contains(services, "gnmi.gNMI")
+}
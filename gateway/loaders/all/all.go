@@ -13,10 +13,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package all imports the included target loaders so that they register
+// themselves. The json and simple loaders are always included; the others
+// can be left out of the binary with no_loader_<name> build tags, or
+// selected with with_loader_<name> tags when building with the minimal tag
+// (see the exporters/all package).
 package all
 
 import (
 	_ "github.com/openconfig/gnmi-gateway/gateway/loaders/json"
-	_ "github.com/openconfig/gnmi-gateway/gateway/loaders/netbox"
 	_ "github.com/openconfig/gnmi-gateway/gateway/loaders/simple"
 )
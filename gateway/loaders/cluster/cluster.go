@@ -17,6 +17,7 @@ package cluster
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/openconfig/gnmi/proto/target"
@@ -29,18 +30,21 @@ import (
 // ClusterTargetLoader is used internally to connect/disconnect from other
 // cluster members if clustering is enabled.
 type ClusterTargetLoader struct {
-	config  *configuration.GatewayConfig
-	cluster clustering.ClusterMember
+	config   *configuration.GatewayConfig
+	cluster  clustering.ClusterMember
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func NewClusterTargetLoader(config *configuration.GatewayConfig, cluster clustering.ClusterMember) *ClusterTargetLoader {
 	return &ClusterTargetLoader{
 		config:  config,
 		cluster: cluster,
+		stop:    make(chan struct{}),
 	}
 }
 
-func (c ClusterTargetLoader) GetConfiguration() (*target.Configuration, error) {
+func (c *ClusterTargetLoader) GetConfiguration() (*target.Configuration, error) {
 	memberList, err := c.cluster.MemberList()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get member list to generate target configuration: %v", err)
@@ -79,15 +83,15 @@ func (c ClusterTargetLoader) GetConfiguration() (*target.Configuration, error) {
 	return targetConfig, nil
 }
 
-func (c ClusterTargetLoader) Start() error {
+func (c *ClusterTargetLoader) Start() error {
 	return nil // nothing to start
 }
 
-func (c ClusterTargetLoader) WatchConfiguration(configChan chan<- *connections.TargetConnectionControl) error {
+func (c *ClusterTargetLoader) WatchConfiguration(configChan chan<- *connections.TargetConnectionControl) error {
 	err := c.cluster.MemberListCallback(func(add clustering.MemberID, remove clustering.MemberID) {
 		if add != "" {
 			c.config.Log.Info().Msgf("Cluster Loader: Add Member: %s", add)
-			configChan <- &connections.TargetConnectionControl{
+			c.send(configChan, &connections.TargetConnectionControl{
 				Insert: &target.Configuration{
 					Request: map[string]*gnmi.SubscribeRequest{
 						"all": {
@@ -119,14 +123,28 @@ func (c ClusterTargetLoader) WatchConfiguration(configChan chan<- *connections.T
 						},
 					},
 				},
-			}
+			})
 		}
 		if remove != "" {
 			c.config.Log.Info().Msgf("Cluster Loader: Remove Member: %s", remove)
-			configChan <- &connections.TargetConnectionControl{
+			c.send(configChan, &connections.TargetConnectionControl{
 				Remove: []string{string(remove)},
-			}
+			})
 		}
 	})
 	return err
 }
+
+// send passes the control message to the connection manager unless the
+// loader has been stopped.
+func (c *ClusterTargetLoader) send(configChan chan<- *connections.TargetConnectionControl, msg *connections.TargetConnectionControl) {
+	select {
+	case configChan <- msg:
+	case <-c.stop:
+	}
+}
+
+// Stop stops sending cluster member changes to the connection manager.
+func (c *ClusterTargetLoader) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
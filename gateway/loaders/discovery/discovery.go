@@ -0,0 +1,457 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package discovery provides an experimental TargetLoader that discovers new
+// targets from the LLDP neighbor tables of targets that are already
+// connected. Here is an example discovery file:
+//  ---
+//  seeds:
+//    - core-*
+//  host_pattern: "*.example.net"
+//  allow:
+//    - "edge*.example.net"
+//  max_targets: 500
+//  target:
+//    addresses:
+//      - "{host}:9339"
+//    credentials:
+//      username: myusername
+//      password: mypassword
+//    meta: {}
+//    paths:
+//      - /interfaces/interface[name=*]/state/counters
+//      - /lldp/interfaces/interface[name=*]/neighbors
+//
+// The neighbors of the seed targets, which are configured by another loader,
+// are read from the OpenConfig LLDP model in the gateway's cache
+// (/lldp/interfaces/interface/neighbors/neighbor/state/system-name), so the
+// seeds must be subscribed to it. Neighbors whose system name matches
+// host_pattern are proposed as targets and the proposed targets that match
+// the allow list are added with the target template. In the addresses and
+// meta values {host} is replaced with the neighbor's system name and {address}
+// with its LLDP management address. Discovered targets that subscribe to the
+// LLDP neighbors are crawled in turn.
+//
+// Proposed targets that aren't allowed are logged once and counted in
+// gnmigateway.loaders.discovery.proposed so that the allow list can be
+// extended. Discovered targets are kept until the gateway restarts or they no
+// longer match the discovery file.
+package discovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/path"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/openconfig/gnmi/target"
+	"github.com/openconfig/gnmi/value"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/loaders"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+const Name = "discovery"
+
+// AddressPlaceholder is replaced with the LLDP management address of the
+// neighbor in the addresses and meta values of the target template.
+const AddressPlaceholder = "{address}"
+
+// requestName is the name of the subscription request of discovered targets.
+const requestName = "discovery"
+
+// neighborsQuery matches the state leaves of the LLDP neighbors of all
+// interfaces in the cache.
+var neighborsQuery = []string{"lldp", "interfaces", "interface", "*", "neighbors", "neighbor", "*", "state"}
+
+var _ loaders.TargetLoader = new(DiscoveryTargetLoader)
+var _ loaders.StatusReporter = new(DiscoveryTargetLoader)
+var _ loaders.CacheReader = new(DiscoveryTargetLoader)
+
+// Config is the content of the discovery file.
+type Config struct {
+	// Seeds are shell patterns matched against the names of configured
+	// targets whose neighbors are discovered.
+	Seeds []string `yaml:"seeds"`
+	// HostPattern is a shell pattern that the system name of a neighbor
+	// must match to be proposed as a target. The default is "*".
+	HostPattern string `yaml:"host_pattern"`
+	// Allow are shell patterns matched against the names of proposed
+	// targets. Only the targets that match are added.
+	Allow []string `yaml:"allow"`
+	// MaxTargets is the maximum number of targets that are discovered.
+	// Zero is unlimited.
+	MaxTargets int            `yaml:"max_targets"`
+	Target     TargetTemplate `yaml:"target"`
+}
+
+// TargetTemplate is the configuration of discovered targets.
+type TargetTemplate struct {
+	Addresses   []string          `yaml:"addresses"`
+	Credentials CredentialsConfig `yaml:"credentials"`
+	Meta        map[string]string `yaml:"meta"`
+	Paths       []string          `yaml:"paths"`
+}
+
+type CredentialsConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Neighbor is an LLDP neighbor of a target.
+type Neighbor struct {
+	// Target is the target the neighbor was seen by.
+	Target string
+	// Name is the system name of the neighbor.
+	Name string
+	// Address is the management address of the neighbor, if known.
+	Address string
+}
+
+type DiscoveryTargetLoader struct {
+	config   *configuration.GatewayConfig
+	file     string
+	interval time.Duration
+	cache    *shardedcache.Cache
+	status   func() []connections.TargetStatus
+
+	mutex sync.Mutex
+	// discovered are the neighbors that were added as targets by name.
+	discovered map[string]Neighbor
+	// proposed are the names of the proposed targets that weren't allowed.
+	proposed map[string]bool
+	last     *targetpb.Configuration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func init() {
+	loaders.Register(Name, NewDiscoveryTargetLoader)
+}
+
+func NewDiscoveryTargetLoader(config *configuration.GatewayConfig) loaders.TargetLoader {
+	return &DiscoveryTargetLoader{
+		config:     config,
+		file:       config.TargetLoaders.DiscoveryFile,
+		interval:   config.TargetLoaders.DiscoveryInterval,
+		discovered: make(map[string]Neighbor),
+		proposed:   make(map[string]bool),
+		stop:       make(chan struct{}),
+	}
+}
+
+// SetCache sets the cache that the LLDP neighbors are read from.
+func (m *DiscoveryTargetLoader) SetCache(cache *shardedcache.Cache) {
+	m.cache = cache
+}
+
+// SetStatusSource sets the function used to get the names of the configured
+// targets so that they aren't discovered again.
+func (m *DiscoveryTargetLoader) SetStatusSource(status func() []connections.TargetStatus) {
+	m.status = status
+}
+
+// readConfig reads and validates the discovery file.
+func (m *DiscoveryTargetLoader) readConfig() (*Config, error) {
+	data, err := ioutil.ReadFile(m.file)
+	if err != nil {
+		return nil, fmt.Errorf("could not open discovery file %q: %v", m.file, err)
+	}
+	config := new(Config)
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse discovery file %q: %v", m.file, err)
+	}
+	if config.HostPattern == "" {
+		config.HostPattern = "*"
+	}
+	if len(config.Seeds) == 0 {
+		return nil, fmt.Errorf("discovery file %q has no seeds", m.file)
+	}
+	if len(config.Target.Addresses) == 0 {
+		return nil, fmt.Errorf("discovery file %q has no target addresses", m.file)
+	}
+	for _, pattern := range append(append([]string{config.HostPattern}, config.Seeds...), config.Allow...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s' in discovery file %q: %v", pattern, m.file, err)
+		}
+	}
+	return config, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Neighbors returns the LLDP neighbors in the cache of the targets for which
+// seed returns true, ordered by target and name.
+func Neighbors(cache *shardedcache.Cache, seed func(target string) bool) ([]Neighbor, error) {
+	type key struct{ target, iface, id string }
+	found := make(map[key]*Neighbor)
+	err := cache.Query("*", neighborsQuery, func(_ []string, _ *ctree.Leaf, val interface{}) error {
+		n, ok := val.(*gnmi.Notification)
+		if !ok || !seed(n.GetPrefix().GetTarget()) {
+			return nil
+		}
+		for _, u := range n.GetUpdate() {
+			p := append(path.ToStrings(n.GetPrefix(), true), path.ToStrings(u.GetPath(), false)...)
+			// target, lldp, interfaces, interface, <name>, neighbors, neighbor, <id>, state, <leaf>
+			if len(p) != 10 {
+				continue
+			}
+			scalar, err := value.ToScalar(u.GetVal())
+			if err != nil || scalar == nil {
+				continue
+			}
+			k := key{target: p[0], iface: p[4], id: p[7]}
+			neighbor, exists := found[k]
+			if !exists {
+				neighbor = &Neighbor{Target: p[0]}
+				found[k] = neighbor
+			}
+			switch p[9] {
+			case "system-name":
+				neighbor.Name = strings.TrimSpace(fmt.Sprint(scalar))
+			case "management-address":
+				neighbor.Address = strings.TrimSpace(fmt.Sprint(scalar))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors := make([]Neighbor, 0, len(found))
+	for _, neighbor := range found {
+		if neighbor.Name != "" {
+			neighbors = append(neighbors, *neighbor)
+		}
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Target != neighbors[j].Target {
+			return neighbors[i].Target < neighbors[j].Target
+		}
+		return neighbors[i].Name < neighbors[j].Name
+	})
+	return neighbors, nil
+}
+
+// crawl adds the allowed neighbors of the seeds and of the discovered targets
+// to the discovered targets, and drops discovered targets that are no longer
+// allowed.
+func (m *DiscoveryTargetLoader) crawl(config *Config) error {
+	configured := make(map[string]bool)
+	if m.status != nil {
+		for _, status := range m.status() {
+			configured[status.Name] = true
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for name := range m.discovered {
+		if !matchAny([]string{config.HostPattern}, name) || !matchAny(config.Allow, name) {
+			m.config.Log.Info().Msgf("Discovered target %s is no longer allowed.", name)
+			delete(m.discovered, name)
+		}
+	}
+
+	neighbors, err := Neighbors(m.cache, func(target string) bool {
+		_, discovered := m.discovered[target]
+		return discovered || matchAny(config.Seeds, target)
+	})
+	if err != nil {
+		return err
+	}
+	for _, neighbor := range neighbors {
+		if existing, discovered := m.discovered[neighbor.Name]; discovered {
+			// the management address may have been learned since
+			if existing.Address == "" && neighbor.Address != "" {
+				m.discovered[neighbor.Name] = neighbor
+			}
+			continue
+		}
+		if configured[neighbor.Name] || !matchAny([]string{config.HostPattern}, neighbor.Name) {
+			continue
+		}
+		if !matchAny(config.Allow, neighbor.Name) {
+			if !m.proposed[neighbor.Name] {
+				m.proposed[neighbor.Name] = true
+				m.config.Log.Info().Msgf("Proposed target %s (neighbor of %s) is not in the discovery allow list.", neighbor.Name, neighbor.Target)
+				stats.Registry.Counter("gnmigateway.loaders.discovery.proposed", stats.NoTags).Increment()
+			}
+			continue
+		}
+		if config.MaxTargets > 0 && len(m.discovered) >= config.MaxTargets {
+			m.config.Log.Warn().Msgf("Not adding discovered target %s: the limit of %d discovered targets was reached.", neighbor.Name, config.MaxTargets)
+			continue
+		}
+		m.config.Log.Info().Msgf("Discovered target %s (neighbor of %s).", neighbor.Name, neighbor.Target)
+		stats.Registry.Counter("gnmigateway.loaders.discovery.added", stats.NoTags).Increment()
+		m.discovered[neighbor.Name] = neighbor
+	}
+	return nil
+}
+
+// render returns the target configuration of a discovered neighbor or false
+// if the template needs the neighbor's management address and it isn't known.
+func (t TargetTemplate) render(neighbor Neighbor) (*targetpb.Target, bool) {
+	replacer := strings.NewReplacer(loaders.HostPlaceholder, neighbor.Name, AddressPlaceholder, neighbor.Address)
+	needsAddress := false
+	addresses := make([]string, len(t.Addresses))
+	for i, address := range t.Addresses {
+		needsAddress = needsAddress || strings.Contains(address, AddressPlaceholder)
+		addresses[i] = replacer.Replace(address)
+	}
+	var meta map[string]string
+	if t.Meta != nil {
+		meta = make(map[string]string, len(t.Meta))
+		for k, v := range t.Meta {
+			needsAddress = needsAddress || strings.Contains(v, AddressPlaceholder)
+			meta[k] = replacer.Replace(v)
+		}
+	}
+	if needsAddress && neighbor.Address == "" {
+		return nil, false
+	}
+	return &targetpb.Target{
+		Addresses: addresses,
+		Request:   requestName,
+		Meta:      meta,
+		Credentials: &targetpb.Credentials{
+			Username: t.Credentials.Username,
+			Password: t.Credentials.Password,
+		},
+	}, true
+}
+
+func subscribeRequest(paths []string) (*gnmi.SubscribeRequest, error) {
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	var subs []*gnmi.Subscription
+	for _, x := range paths {
+		p, err := xpath.ToGNMIPath(x)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse XPath: %s: %v", x, err)
+		}
+		subs = append(subs, &gnmi.Subscription{Path: p})
+	}
+	return &gnmi.SubscribeRequest{
+		Request: &gnmi.SubscribeRequest_Subscribe{
+			Subscribe: &gnmi.SubscriptionList{
+				Prefix:       &gnmi.Path{},
+				Subscription: subs,
+			},
+		},
+	}, nil
+}
+
+// configuration returns the configuration of the discovered targets.
+func (m *DiscoveryTargetLoader) configuration(config *Config) (*targetpb.Configuration, error) {
+	request, err := subscribeRequest(config.Target.Paths)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target paths in discovery file %q: %v", m.file, err)
+	}
+	configs := &targetpb.Configuration{
+		Target:  make(map[string]*targetpb.Target),
+		Request: map[string]*gnmi.SubscribeRequest{requestName: request},
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for name, neighbor := range m.discovered {
+		if t, ok := config.Target.render(neighbor); ok {
+			configs.Target[name] = t
+		}
+	}
+	if err := target.Validate(configs); err != nil {
+		return nil, fmt.Errorf("configuration of discovered targets is invalid: %v", err)
+	}
+	return configs, nil
+}
+
+// GetConfiguration discovers new targets and returns the configuration of
+// all discovered targets.
+func (m *DiscoveryTargetLoader) GetConfiguration() (*targetpb.Configuration, error) {
+	config, err := m.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	if m.cache != nil {
+		if err := m.crawl(config); err != nil {
+			return nil, fmt.Errorf("unable to read LLDP neighbors: %v", err)
+		}
+	}
+	return m.configuration(config)
+}
+
+func (m *DiscoveryTargetLoader) Start() error {
+	_, err := m.readConfig() // make sure there are no errors at startup
+	return err
+}
+
+func (m *DiscoveryTargetLoader) WatchConfiguration(targetChan chan<- *connections.TargetConnectionControl) error {
+	for {
+		targetConfig, err := m.GetConfiguration()
+		if err != nil {
+			m.config.Log.Error().Err(err).Msgf("Unable to discover targets.")
+		} else if !proto.Equal(targetConfig, m.last) {
+			controlMsg := new(connections.TargetConnectionControl)
+			if m.last != nil {
+				for targetName := range m.last.Target {
+					if _, exists := targetConfig.Target[targetName]; !exists {
+						controlMsg.Remove = append(controlMsg.Remove, targetName)
+					}
+				}
+			}
+			controlMsg.Insert = targetConfig
+			m.last = targetConfig
+
+			select {
+			case targetChan <- controlMsg:
+			case <-m.stop:
+				return nil
+			}
+		}
+		select {
+		case <-time.After(m.interval):
+		case <-m.stop:
+			return nil
+		}
+	}
+}
+
+// Stop stops discovering targets.
+func (m *DiscoveryTargetLoader) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
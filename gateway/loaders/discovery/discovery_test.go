@@ -0,0 +1,177 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+const testDiscoveryFile = `
+---
+seeds:
+  - core*
+host_pattern: "*.example.net"
+allow:
+  - "edge*.example.net"
+max_targets: 2
+target:
+  addresses:
+    - "{address}:9339"
+  meta:
+    Site: "{host}"
+  paths:
+    - /lldp/interfaces/interface[name=*]/neighbors
+`
+
+var timestamp int64
+
+func addNeighbor(t *testing.T, c *shardedcache.Cache, target, iface, name, address string) {
+	leaf := func(name, value string) *gnmi.Update {
+		return &gnmi.Update{
+			Path: &gnmi.Path{Elem: []*gnmi.PathElem{
+				{Name: "lldp"},
+				{Name: "interfaces"},
+				{Name: "interface", Key: map[string]string{"name": iface}},
+				{Name: "neighbors"},
+				{Name: "neighbor", Key: map[string]string{"id": "1"}},
+				{Name: "state"},
+				{Name: name},
+			}},
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: value}},
+		}
+	}
+	updates := []*gnmi.Update{leaf("system-name", name)}
+	if address != "" {
+		updates = append(updates, leaf("management-address", address))
+	}
+	timestamp++
+	err := c.GnmiUpdate(&gnmi.Notification{
+		Timestamp: timestamp,
+		Prefix:    &gnmi.Path{Target: target},
+		Update:    updates,
+	})
+	if err != nil {
+		t.Fatalf("GnmiUpdate: %v", err)
+	}
+}
+
+func newTestLoader(t *testing.T) (*DiscoveryTargetLoader, *shardedcache.Cache, func()) {
+	file, err := ioutil.TempFile("", "discovery*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString(testDiscoveryFile)
+	_ = file.Close()
+
+	config := &configuration.GatewayConfig{
+		Log: zerolog.Nop(),
+		TargetLoaders: &configuration.TargetLoadersConfig{
+			DiscoveryFile: file.Name(),
+		},
+	}
+	loader := NewDiscoveryTargetLoader(config).(*DiscoveryTargetLoader)
+	if err := loader.Start(); err != nil {
+		_ = os.Remove(file.Name())
+		t.Fatal(err)
+	}
+	c := shardedcache.New(2, []string{"core1", "edge1.example.net", "edge2.example.net"})
+	loader.SetCache(c)
+	loader.SetStatusSource(func() []connections.TargetStatus {
+		return []connections.TargetStatus{{Name: "core1"}, {Name: "core2.example.net"}}
+	})
+	return loader, c, func() { _ = os.Remove(file.Name()) }
+}
+
+func TestNeighbors(t *testing.T) {
+	assertion := assert.New(t)
+	_, c, cleanup := newTestLoader(t)
+	defer cleanup()
+
+	addNeighbor(t, c, "core1", "eth1", "edge2.example.net", "")
+	addNeighbor(t, c, "core1", "eth0", "edge1.example.net", "192.0.2.1")
+	addNeighbor(t, c, "edge1.example.net", "eth0", "core1", "")
+
+	neighbors, err := Neighbors(c, func(target string) bool { return target == "core1" })
+	assertion.NoError(err)
+	assertion.Equal([]Neighbor{
+		{Target: "core1", Name: "edge1.example.net", Address: "192.0.2.1"},
+		{Target: "core1", Name: "edge2.example.net"},
+	}, neighbors)
+}
+
+func TestDiscoveryTargetLoader_GetConfiguration(t *testing.T) {
+	assertion := assert.New(t)
+	loader, c, cleanup := newTestLoader(t)
+	defer cleanup()
+
+	addNeighbor(t, c, "core1", "eth0", "edge1.example.net", "192.0.2.1")
+	addNeighbor(t, c, "core1", "eth1", "core2.example.net", "192.0.2.2")
+	addNeighbor(t, c, "core1", "eth2", "dist1.example.net", "192.0.2.3")
+	addNeighbor(t, c, "core1", "eth3", "server1.lab", "192.0.2.4")
+
+	configs, err := loader.GetConfiguration()
+	if !assertion.NoError(err) {
+		return
+	}
+	// core2 is already configured, dist1 isn't allowed, and server1 doesn't
+	// match the host pattern.
+	assertion.Len(configs.Target, 1)
+	edge1 := configs.Target["edge1.example.net"]
+	if assertion.NotNil(edge1) {
+		assertion.Equal([]string{"192.0.2.1:9339"}, edge1.GetAddresses())
+		assertion.Equal(map[string]string{"Site": "edge1.example.net"}, edge1.GetMeta())
+		assertion.Equal(requestName, edge1.GetRequest())
+	}
+	assertion.NotNil(configs.Request[requestName])
+	assertion.Equal(map[string]bool{"dist1.example.net": true}, loader.proposed)
+
+	// The neighbors of discovered targets are crawled too. edge2 is only
+	// added once its management address is known.
+	addNeighbor(t, c, "edge1.example.net", "eth0", "edge2.example.net", "")
+	configs, err = loader.GetConfiguration()
+	if !assertion.NoError(err) {
+		return
+	}
+	assertion.Len(configs.Target, 1)
+	assertion.Contains(loader.discovered, "edge2.example.net")
+
+	addNeighbor(t, c, "edge1.example.net", "eth0", "edge2.example.net", "192.0.2.5")
+	configs, err = loader.GetConfiguration()
+	if !assertion.NoError(err) {
+		return
+	}
+	assertion.Len(configs.Target, 2)
+	assertion.Equal([]string{"192.0.2.5:9339"}, configs.Target["edge2.example.net"].GetAddresses())
+
+	// No more targets are added once max_targets is reached.
+	addNeighbor(t, c, "edge2.example.net", "eth0", "edge3.example.net", "192.0.2.6")
+	configs, err = loader.GetConfiguration()
+	if !assertion.NoError(err) {
+		return
+	}
+	assertion.Len(configs.Target, 2)
+	assertion.NotContains(configs.Target, "edge3.example.net")
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loaders
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+)
+
+// HostPlaceholder is replaced with the expanded target name in the
+// addresses and meta values of a target template.
+const HostPlaceholder = "{host}"
+
+// maxExpandedHosts limits the number of targets a single pattern expands to
+// so that a typo can't create millions of targets.
+const maxExpandedHosts = 100000
+
+// ExpandHosts expands a hostname pattern into the list of hostnames. Each
+// bracketed part of the pattern is a comma-separated list of values or
+// numeric ranges and is replaced by each value in turn, e.g. edge[01-03].site1
+// expands to edge01.site1, edge02.site1 and edge03.site1, and
+// spine[1,3].pod[a,b] to spine1.poda, spine1.podb, spine3.poda and
+// spine3.podb. Numbers in a range are zero-padded to the width of the start
+// of the range if it has a leading zero. A pattern without brackets expands
+// to itself.
+func ExpandHosts(pattern string) ([]string, error) {
+	hosts := []string{""}
+	rest := pattern
+	for rest != "" {
+		open := strings.IndexAny(rest, "[]")
+		if open == -1 {
+			hosts = appendSuffix(hosts, rest)
+			break
+		}
+		if rest[open] == ']' {
+			return nil, fmt.Errorf("unbalanced ']' in host pattern '%s'", pattern)
+		}
+		hosts = appendSuffix(hosts, rest[:open])
+		rest = rest[open+1:]
+		end := strings.IndexAny(rest, "[]")
+		if end == -1 || rest[end] == '[' {
+			return nil, fmt.Errorf("unterminated '[' in host pattern '%s'", pattern)
+		}
+		values, err := expandList(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid host pattern '%s': %v", pattern, err)
+		}
+		if len(hosts)*len(values) > maxExpandedHosts {
+			return nil, fmt.Errorf("host pattern '%s' expands to more than %d hosts", pattern, maxExpandedHosts)
+		}
+		expanded := make([]string, 0, len(hosts)*len(values))
+		for _, host := range hosts {
+			for _, value := range values {
+				expanded = append(expanded, host+value)
+			}
+		}
+		hosts = expanded
+		rest = rest[end+1:]
+	}
+	return hosts, nil
+}
+
+func appendSuffix(hosts []string, suffix string) []string {
+	for i := range hosts {
+		hosts[i] += suffix
+	}
+	return hosts
+}
+
+// expandList expands a comma-separated list of values and numeric ranges.
+func expandList(list string) ([]string, error) {
+	var values []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty value in '[%s]'", list)
+		}
+		dash := strings.Index(item, "-")
+		if dash == -1 {
+			values = append(values, item)
+			continue
+		}
+		start, err := strconv.Atoi(item[:dash])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range '%s'", item)
+		}
+		end, err := strconv.Atoi(item[dash+1:])
+		if err != nil || end < start || start < 0 {
+			return nil, fmt.Errorf("invalid range '%s'", item)
+		}
+		if end-start >= maxExpandedHosts {
+			return nil, fmt.Errorf("range '%s' is too large", item)
+		}
+		width := 0
+		if item[0] == '0' && dash > 1 {
+			width = dash
+		}
+		for i := start; i <= end; i++ {
+			values = append(values, fmt.Sprintf("%0*d", width, i))
+		}
+	}
+	return values, nil
+}
+
+// ExpandTargets replaces the targets of the configuration whose names are
+// host patterns with a target for each expanded hostname. The expanded
+// targets share the template's request and credentials; HostPlaceholder in
+// the template's addresses and meta values is replaced with the hostname.
+func ExpandTargets(config *targetpb.Configuration) error {
+	var patterns []string
+	for name := range config.GetTarget() {
+		if strings.ContainsAny(name, "[]") {
+			patterns = append(patterns, name)
+		}
+	}
+	sort.Strings(patterns)
+	for _, name := range patterns {
+		template := config.Target[name]
+		hosts, err := ExpandHosts(name)
+		if err != nil {
+			return err
+		}
+		delete(config.Target, name)
+		for _, host := range hosts {
+			if _, exists := config.Target[host]; exists {
+				return fmt.Errorf("target '%s' from pattern '%s' is already defined", host, name)
+			}
+			config.Target[host] = expandTarget(template, host)
+		}
+	}
+	return nil
+}
+
+// expandTarget returns a copy of the template for the host.
+func expandTarget(template *targetpb.Target, host string) *targetpb.Target {
+	t := proto.Clone(template).(*targetpb.Target)
+	for i, address := range t.Addresses {
+		t.Addresses[i] = strings.ReplaceAll(address, HostPlaceholder, host)
+	}
+	for key, value := range t.Meta {
+		t.Meta[key] = strings.ReplaceAll(value, HostPlaceholder, host)
+	}
+	return t
+}
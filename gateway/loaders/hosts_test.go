@@ -0,0 +1,80 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loaders
+
+import (
+	"testing"
+
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandHosts(t *testing.T) {
+	tests := []struct {
+		pattern string
+		hosts   []string
+		err     string
+	}{
+		{pattern: "router1", hosts: []string{"router1"}},
+		{pattern: "edge[01-03].site1", hosts: []string{"edge01.site1", "edge02.site1", "edge03.site1"}},
+		{pattern: "edge[8-11]", hosts: []string{"edge8", "edge9", "edge10", "edge11"}},
+		{pattern: "spine[1,3].pod[a,b]", hosts: []string{"spine1.poda", "spine1.podb", "spine3.poda", "spine3.podb"}},
+		{pattern: "leaf[1-2,7]", hosts: []string{"leaf1", "leaf2", "leaf7"}},
+		{pattern: "edge[1-3", err: "unterminated '[' in host pattern 'edge[1-3'"},
+		{pattern: "edge1]", err: "unbalanced ']' in host pattern 'edge1]'"},
+		{pattern: "edge[[1]]", err: "unterminated '[' in host pattern 'edge[[1]]'"},
+		{pattern: "edge[3-1]", err: "invalid host pattern 'edge[3-1]': invalid range '3-1'"},
+		{pattern: "edge[]", err: "invalid host pattern 'edge[]': empty value in '[]'"},
+		{pattern: "edge[0-99999][0-9]", err: "host pattern 'edge[0-99999][0-9]' expands to more than 100000 hosts"},
+	}
+	for _, test := range tests {
+		hosts, err := ExpandHosts(test.pattern)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.pattern)
+			continue
+		}
+		assert.NoError(t, err, test.pattern)
+		assert.Equal(t, test.hosts, hosts, test.pattern)
+	}
+}
+
+func TestExpandTargets(t *testing.T) {
+	assertion := assert.New(t)
+	config := &targetpb.Configuration{
+		Target: map[string]*targetpb.Target{
+			"edge[1-2].site1": {
+				Addresses:   []string{"{host}:9339"},
+				Request:     "edge",
+				Credentials: &targetpb.Credentials{Username: "user"},
+				Meta:        map[string]string{"Site": "site1", "Name": "{host}"},
+			},
+			"core1": {Addresses: []string{"core1:9339"}},
+		},
+	}
+	assertion.NoError(ExpandTargets(config))
+	assertion.Len(config.Target, 3)
+	edge := config.Target["edge2.site1"]
+	if assertion.NotNil(edge) {
+		assertion.Equal([]string{"edge2.site1:9339"}, edge.Addresses)
+		assertion.Equal("edge", edge.Request)
+		assertion.Equal("user", edge.Credentials.Username)
+		assertion.Equal(map[string]string{"Site": "site1", "Name": "edge2.site1"}, edge.Meta)
+	}
+	assertion.Equal([]string{"edge1.site1:9339"}, config.Target["edge1.site1"].Addresses)
+
+	config.Target["core[1-2]"] = &targetpb.Target{Addresses: []string{"{host}:9339"}}
+	assertion.EqualError(ExpandTargets(config), "target 'core1' from pattern 'core[1-2]' is already defined")
+}
@@ -18,6 +18,7 @@ package json
 import (
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/jsonpb"
@@ -36,6 +37,8 @@ type JSONFileTargetLoader struct {
 	file     string
 	interval time.Duration
 	last     *targetpb.Configuration
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func init() {
@@ -47,6 +50,7 @@ func NewJSONFileTargetLoader(config *configuration.GatewayConfig) loaders.Target
 		config:   config,
 		file:     config.TargetLoaders.JSONFile,
 		interval: config.TargetLoaders.JSONFileReloadInterval,
+		stop:     make(chan struct{}),
 	}
 }
 
@@ -64,6 +68,9 @@ func (m *JSONFileTargetLoader) GetConfiguration() (*targetpb.Configuration, erro
 	if err := jsonpb.Unmarshal(f, configs); err != nil {
 		return nil, fmt.Errorf("could not parse configuration from %q: %v", m.file, err)
 	}
+	if err := loaders.ExpandTargets(configs); err != nil {
+		return nil, fmt.Errorf("configuration in %q is invalid: %v", m.file, err)
+	}
 	if err := target.Validate(configs); err != nil {
 		return nil, fmt.Errorf("configuration in %q is invalid: %v", m.file, err)
 	}
@@ -92,12 +99,25 @@ func (m *JSONFileTargetLoader) WatchConfiguration(targetChan chan<- *connections
 			controlMsg.Insert = targetConfig
 			m.last = targetConfig
 
-			targetChan <- controlMsg
+			select {
+			case targetChan <- controlMsg:
+			case <-m.stop:
+				return nil
+			}
+		}
+		select {
+		case <-time.After(m.interval):
+		case <-m.stop:
+			return nil
 		}
-		time.Sleep(m.interval)
 	}
 }
 
+// Stop stops watching the configuration.
+func (m *JSONFileTargetLoader) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
 //func WriteTargetConfiguration(config *gateway.GatewayConfig, targets *targetpb.Configuration, file string) error {
 //	f, err := os.Create(file)
 //	if err != nil {
@@ -0,0 +1,465 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubernetes provides a TargetLoader for loading targets from
+// GNMITarget custom resources in Kubernetes.
+//
+// The loader lists the GNMITarget resources (see crd.yaml) and watches them
+// for changes, sending the complete configuration to the connection manager
+// each time a resource is added, modified, or deleted. The connection state
+// of each target is written back to the status of its resource every
+// KubernetesStatusInterval.
+//
+// The gateway's service account token and CA are used when the gateway runs
+// in a pod. KubernetesAPIServer can be set to reach the API some other way,
+// e.g. through `kubectl proxy`.
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	targetpb "github.com/openconfig/gnmi/proto/target"
+	"github.com/openconfig/gnmi/target"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/loaders"
+)
+
+const Name = "kubernetes"
+
+const (
+	// Group, Version, and Resource identify the GNMITarget custom resource.
+	Group    = "gnmigateway.openconfig.net"
+	Version  = "v1alpha1"
+	Resource = "gnmitargets"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+var _ loaders.TargetLoader = new(KubernetesTargetLoader)
+var _ loaders.StatusReporter = new(KubernetesTargetLoader)
+
+type KubernetesTargetLoader struct {
+	config   *configuration.GatewayConfig
+	client   *http.Client
+	server   string
+	token    string
+	interval time.Duration
+	status   func() []connections.TargetStatus
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex sync.Mutex
+	// resources maps target names to the resource they were loaded from.
+	resources map[string]resourceKey
+	// reported is the last status written to each resource.
+	reported map[resourceKey]targetStatus
+	last     *targetpb.Configuration
+}
+
+func init() {
+	loaders.Register(Name, NewKubernetesTargetLoader)
+}
+
+func NewKubernetesTargetLoader(config *configuration.GatewayConfig) loaders.TargetLoader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KubernetesTargetLoader{
+		config:    config,
+		interval:  config.TargetLoaders.KubernetesReloadInterval,
+		ctx:       ctx,
+		cancel:    cancel,
+		resources: make(map[string]resourceKey),
+		reported:  make(map[resourceKey]targetStatus),
+	}
+}
+
+// gnmiTarget is a GNMITarget custom resource.
+type gnmiTarget struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		// Name is the target name, defaults to the resource name.
+		Name        string   `json:"name"`
+		Addresses   []string `json:"addresses"`
+		Credentials *struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"credentials"`
+		// Paths are the XPaths to subscribe to, defaults to the root.
+		Paths []string          `json:"paths"`
+		Meta  map[string]string `json:"meta"`
+	} `json:"spec"`
+}
+
+type gnmiTargetList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []gnmiTarget `json:"items"`
+}
+
+type watchEvent struct {
+	Type string `json:"type"`
+}
+
+type resourceKey struct {
+	namespace string
+	name      string
+}
+
+// targetStatus is the status subresource of a GNMITarget.
+type targetStatus struct {
+	Address     string `json:"address,omitempty"`
+	Connected   bool   `json:"connected"`
+	Quarantined bool   `json:"quarantined"`
+	Synced      bool   `json:"synced"`
+}
+
+// SetStatusSource sets the function used to get the connection status of the
+// targets written to the GNMITarget resources.
+func (m *KubernetesTargetLoader) SetStatusSource(status func() []connections.TargetStatus) {
+	m.status = status
+}
+
+func (m *KubernetesTargetLoader) GetConfiguration() (*targetpb.Configuration, error) {
+	configs, _, err := m.list()
+	return configs, err
+}
+
+// list returns the configuration of the GNMITarget resources and the resource
+// version to watch for changes from.
+func (m *KubernetesTargetLoader) list() (*targetpb.Configuration, string, error) {
+	var list gnmiTargetList
+	if err := m.do(http.MethodGet, m.resourcePath("", ""), nil, &list); err != nil {
+		return nil, "", fmt.Errorf("unable to list %s: %v", Resource, err)
+	}
+
+	configs := &targetpb.Configuration{
+		Target:  make(map[string]*targetpb.Target),
+		Request: make(map[string]*gnmi.SubscribeRequest),
+	}
+	resources := make(map[string]resourceKey)
+	for _, item := range list.Items {
+		key := resourceKey{namespace: item.Metadata.Namespace, name: item.Metadata.Name}
+		name := item.Spec.Name
+		if name == "" {
+			name = item.Metadata.Name
+		}
+		if existing, exists := resources[name]; exists {
+			m.config.Log.Error().Msgf("GNMITarget %s/%s: target %s is already defined by %s/%s.", key.namespace, key.name, name, existing.namespace, existing.name)
+			continue
+		}
+		request, err := subscribeRequest(item.Spec.Paths)
+		if err != nil {
+			m.config.Log.Error().Msgf("GNMITarget %s/%s: %v", key.namespace, key.name, err)
+			continue
+		}
+
+		t := &targetpb.Target{
+			Addresses: item.Spec.Addresses,
+			Request:   name,
+			Meta:      item.Spec.Meta,
+		}
+		if item.Spec.Credentials != nil {
+			t.Credentials = &targetpb.Credentials{
+				Username: item.Spec.Credentials.Username,
+				Password: item.Spec.Credentials.Password,
+			}
+		}
+		configs.Target[name] = t
+		configs.Request[name] = request
+		resources[name] = key
+	}
+
+	if err := target.Validate(configs); err != nil {
+		return nil, "", fmt.Errorf("configuration from Kubernetes loader is invalid: %v", err)
+	}
+
+	m.mutex.Lock()
+	m.resources = resources
+	m.mutex.Unlock()
+	return configs, list.Metadata.ResourceVersion, nil
+}
+
+func subscribeRequest(paths []string) (*gnmi.SubscribeRequest, error) {
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	var subs []*gnmi.Subscription
+	for _, x := range paths {
+		path, err := xpath.ToGNMIPath(x)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse XPath: %s: %v", x, err)
+		}
+		subs = append(subs, &gnmi.Subscription{Path: path})
+	}
+	return &gnmi.SubscribeRequest{
+		Request: &gnmi.SubscribeRequest_Subscribe{
+			Subscribe: &gnmi.SubscriptionList{
+				Prefix:       &gnmi.Path{},
+				Subscription: subs,
+			},
+		},
+	}, nil
+}
+
+func (m *KubernetesTargetLoader) Start() error {
+	loaderConfig := m.config.TargetLoaders
+	m.server = loaderConfig.KubernetesAPIServer
+	tlsConfig := new(tls.Config)
+	if m.server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return fmt.Errorf("not running in a Kubernetes pod and KubernetesAPIServer is not set")
+		}
+		m.server = "https://" + net.JoinHostPort(host, port)
+		token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+		if err != nil {
+			return fmt.Errorf("unable to read the service account token: %v", err)
+		}
+		m.token = strings.TrimSpace(string(token))
+		ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+		if err != nil {
+			return fmt.Errorf("unable to read the service account CA: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("no certificates found in the service account CA")
+		}
+	}
+	m.client = &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}}
+
+	_, err := m.GetConfiguration() // make sure there are no errors at startup
+	if err == nil && m.status != nil && loaderConfig.KubernetesStatusInterval > 0 {
+		go m.reportStatus(loaderConfig.KubernetesStatusInterval)
+	}
+	return err
+}
+
+func (m *KubernetesTargetLoader) WatchConfiguration(targetChan chan<- *connections.TargetConnectionControl) error {
+	for {
+		targetConfig, resourceVersion, err := m.list()
+		if err != nil {
+			m.config.Log.Error().Err(err).Msgf("Unable to get target configuration.")
+		} else {
+			if !proto.Equal(targetConfig, m.last) {
+				controlMsg := new(connections.TargetConnectionControl)
+				if m.last != nil {
+					for targetName := range m.last.Target {
+						_, exists := targetConfig.Target[targetName]
+						if !exists {
+							controlMsg.Remove = append(controlMsg.Remove, targetName)
+						}
+					}
+				}
+				controlMsg.Insert = targetConfig
+				m.last = targetConfig
+
+				select {
+				case targetChan <- controlMsg:
+				case <-m.ctx.Done():
+					return nil
+				}
+			}
+
+			// Block until a resource changes or the watch times out.
+			err = m.watch(resourceVersion)
+			if err == nil {
+				continue
+			}
+			if m.ctx.Err() == nil {
+				m.config.Log.Error().Err(err).Msgf("Unable to watch %s.", Resource)
+			}
+		}
+		select {
+		case <-time.After(m.interval):
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// watch returns nil after the first change to a GNMITarget after
+// resourceVersion, or once the server ends the watch after interval.
+func (m *KubernetesTargetLoader) watch(resourceVersion string) error {
+	query := url.Values{
+		"watch":           {"true"},
+		"resourceVersion": {resourceVersion},
+		"timeoutSeconds":  {fmt.Sprint(int(m.interval.Seconds()))},
+	}
+	req, err := m.newRequest(http.MethodGet, m.resourcePath("", "")+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	// Any event, including the ERROR event sent if resourceVersion is too
+	// old, is handled by listing the resources again.
+	var event watchEvent
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (m *KubernetesTargetLoader) reportStatus(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.updateStatus()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// updateStatus writes the connection status of each target to the status of
+// its GNMITarget if it has changed since the last update.
+func (m *KubernetesTargetLoader) updateStatus() {
+	m.mutex.Lock()
+	resources := m.resources
+	m.mutex.Unlock()
+
+	statuses := m.status()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	for _, s := range statuses {
+		key, exists := resources[s.Name]
+		if !exists {
+			continue
+		}
+		status := targetStatus{Address: s.Address, Connected: s.Connected, Quarantined: s.Quarantined, Synced: s.Synced}
+		m.mutex.Lock()
+		reported, exists := m.reported[key]
+		m.mutex.Unlock()
+		if exists && reported == status {
+			continue
+		}
+
+		patch := map[string]targetStatus{"status": status}
+		if err := m.do(http.MethodPatch, m.resourcePath(key.namespace, key.name)+"/status", patch, nil); err != nil {
+			m.config.Log.Error().Msgf("GNMITarget %s/%s: unable to update status: %v", key.namespace, key.name, err)
+			continue
+		}
+		m.mutex.Lock()
+		m.reported[key] = status
+		m.mutex.Unlock()
+	}
+}
+
+// resourcePath returns the API path of the GNMITarget resources in
+// KubernetesNamespace (or all namespaces) or the named resource.
+func (m *KubernetesTargetLoader) resourcePath(namespace string, name string) string {
+	if name == "" {
+		namespace = m.config.TargetLoaders.KubernetesNamespace
+	}
+	path := "/apis/" + Group + "/" + Version
+	if namespace != "" {
+		path += "/namespaces/" + url.PathEscape(namespace)
+	}
+	path += "/" + Resource
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func (m *KubernetesTargetLoader) newRequest(method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(m.server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(m.ctx)
+	req.Header.Set("Accept", "application/json")
+	if m.token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.token)
+	}
+	return req, nil
+}
+
+// do sends a request with a JSON body (a merge patch for PATCH requests) and
+// decodes the JSON response into out, if it isn't nil.
+func (m *KubernetesTargetLoader) do(method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := m.newRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// Stop stops watching the configuration and updating the status.
+func (m *KubernetesTargetLoader) Stop() {
+	m.cancel()
+}
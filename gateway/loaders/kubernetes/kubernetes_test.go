@@ -0,0 +1,129 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+const testList = `{
+  "metadata": {"resourceVersion": "42"},
+  "items": [
+    {
+      "metadata": {"name": "router-1", "namespace": "net"},
+      "spec": {
+        "addresses": ["router-1:9339"],
+        "credentials": {"username": "u", "password": "p"},
+        "paths": ["/interfaces", "/system"],
+        "meta": {"NoTLS": "yes"}
+      }
+    },
+    {
+      "metadata": {"name": "router-2", "namespace": "net"},
+      "spec": {"name": "router-1", "addresses": ["router-2:9339"]}
+    },
+    {
+      "metadata": {"name": "router-3", "namespace": "lab"},
+      "spec": {"addresses": ["router-3:9339"]}
+    }
+  ]
+}`
+
+func newTestLoader(t *testing.T, handler http.HandlerFunc) (*KubernetesTargetLoader, func()) {
+	server := httptest.NewServer(handler)
+	config := &configuration.GatewayConfig{
+		Log: zerolog.Nop(),
+		TargetLoaders: &configuration.TargetLoadersConfig{
+			KubernetesAPIServer: server.URL,
+		},
+	}
+	loader := NewKubernetesTargetLoader(config).(*KubernetesTargetLoader)
+	if err := loader.Start(); err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return loader, server.Close
+}
+
+func TestKubernetesTargetLoader_GetConfiguration(t *testing.T) {
+	assertion := assert.New(t)
+	loader, closeServer := newTestLoader(t, func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal("/apis/gnmigateway.openconfig.net/v1alpha1/gnmitargets", r.URL.Path)
+		_, _ = w.Write([]byte(testList))
+	})
+	defer closeServer()
+
+	configs, err := loader.GetConfiguration()
+	if !assertion.NoError(err) {
+		return
+	}
+	// router-2 is dropped because it uses the name of router-1.
+	assertion.Len(configs.Target, 2)
+	router := configs.Target["router-1"]
+	if assertion.NotNil(router) {
+		assertion.Equal([]string{"router-1:9339"}, router.Addresses)
+		assertion.Equal("u", router.Credentials.Username)
+		assertion.Equal("yes", router.Meta["NoTLS"])
+		assertion.Len(configs.Request[router.Request].GetSubscribe().GetSubscription(), 2)
+	}
+	assertion.Len(configs.Request[configs.Target["router-3"].Request].GetSubscribe().GetSubscription(), 1)
+	assertion.Equal(resourceKey{namespace: "lab", name: "router-3"}, loader.resources["router-3"])
+}
+
+func TestKubernetesTargetLoader_updateStatus(t *testing.T) {
+	assertion := assert.New(t)
+	patches := make(map[string]targetStatus)
+	loader, closeServer := newTestLoader(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			_, _ = w.Write([]byte(testList))
+			return
+		}
+		assertion.Equal("application/merge-patch+json", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		var patch map[string]targetStatus
+		assertion.NoError(json.Unmarshal(body, &patch))
+		patches[r.URL.Path] = patch["status"]
+		_, _ = w.Write([]byte("{}"))
+	})
+	defer closeServer()
+
+	loader.SetStatusSource(func() []connections.TargetStatus {
+		return []connections.TargetStatus{
+			{Name: "router-1", Address: "router-1:9339", Connected: true, Synced: true},
+			{Name: "router-3"},
+			{Name: "unknown"},
+		}
+	})
+	loader.updateStatus()
+	assertion.Len(patches, 2)
+	assertion.Equal(targetStatus{Address: "router-1:9339", Connected: true, Synced: true},
+		patches["/apis/gnmigateway.openconfig.net/v1alpha1/namespaces/net/gnmitargets/router-1/status"])
+
+	// Unchanged status isn't written again.
+	patches = make(map[string]targetStatus)
+	loader.updateStatus()
+	assertion.Empty(patches)
+}
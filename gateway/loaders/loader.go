@@ -22,6 +22,7 @@ import (
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 )
 
 var Registry = make(map[string]func(config *configuration.GatewayConfig) TargetLoader)
@@ -37,7 +38,27 @@ type TargetLoader interface {
 	Start() error
 	// Start watching the configuration for changes and send the entire
 	// configuration to the supplied channel when a change is detected.
+	// WatchConfiguration should return nil once Stop is called.
 	WatchConfiguration(chan<- *connections.TargetConnectionControl) error
+	// Stop watching the configuration. Stop will be called once by the
+	// gateway when it stops.
+	Stop()
+}
+
+// StatusReporter may be implemented by a TargetLoader that reports the
+// connection status of its targets back to where they were loaded from.
+type StatusReporter interface {
+	// SetStatusSource is called by the gateway before Start with a function
+	// that returns the connection status of each configured target.
+	SetStatusSource(func() []connections.TargetStatus)
+}
+
+// CacheReader may be implemented by a TargetLoader that reads the data
+// received from the connected targets, e.g. to discover new targets.
+type CacheReader interface {
+	// SetCache is called by the gateway before Start with the gateway's
+	// cache.
+	SetCache(*shardedcache.Cache)
 }
 
 func Register(name string, new func(config *configuration.GatewayConfig) TargetLoader) {
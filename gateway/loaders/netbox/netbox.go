@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/gnxi/utils/xpath"
@@ -47,6 +48,8 @@ type NetBoxTargetLoader struct {
 	client   *client.NetBoxAPI
 	host     string
 	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func init() {
@@ -59,6 +62,7 @@ func NewNetBoxTargetLoader(config *configuration.GatewayConfig) loaders.TargetLo
 		apiKey:   config.TargetLoaders.NetBoxAPIKey,
 		host:     config.TargetLoaders.NetBoxHost,
 		interval: config.TargetLoaders.NetBoxReloadInterval,
+		stop:     make(chan struct{}),
 	}
 }
 
@@ -158,8 +162,21 @@ func (m *NetBoxTargetLoader) WatchConfiguration(targetChan chan<- *connections.T
 			controlMsg.Insert = targetConfig
 			m.last = targetConfig
 
-			targetChan <- controlMsg
+			select {
+			case targetChan <- controlMsg:
+			case <-m.stop:
+				return nil
+			}
+		}
+		select {
+		case <-time.After(m.interval):
+		case <-m.stop:
+			return nil
 		}
-		time.Sleep(m.interval)
 	}
 }
+
+// Stop stops watching the configuration.
+func (m *NetBoxTargetLoader) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
@@ -27,7 +27,7 @@
 //      meta: {}
 //  request:
 //    my-request:
-//      target: *
+//      target: "*"
 //      paths:
 //        - /components
 //        - /interfaces/interface[name=*]/state/counters
@@ -35,12 +35,53 @@
 //
 // Paths can be further disambiguated with a gNMI Origin prefix, for example:
 //  openconfig-interfaces:/interfaces/interface[name=*]/state/counters
+//
+// Subscription profiles and target groups can be used to share subscription
+// and connection settings between many targets. A profile is a named set of
+// paths with optional subscription modes and sample intervals. A group
+// references a profile and contains the credentials and meta fields shared by
+// the group's members. Credentials and meta fields set on a connection take
+// precedence over those of the group. Changes to a profile or group are applied
+// to all of the member targets when the file is reloaded:
+//  ---
+//  profile:
+//    core-routers:
+//      target: "*"
+//      mode: stream
+//      paths:
+//        - path: /interfaces/interface[name=*]/state/counters
+//          mode: sample
+//          sample_interval: 10s
+//        - path: /network-instances/network-instance[name=*]/protocols/protocol[name=*]/bgp
+//          mode: on_change
+//  group:
+//    core:
+//      profile: core-routers
+//      credentials:
+//        username: myusername
+//        password: mypassword
+//  connection:
+//    core-router-1:
+//      addresses:
+//        - core-router-1.test.example.net:9339
+//      group: core
+//
+// A connection name can be a host pattern that expands into one target per
+// host, e.g. edge[01-48].site1 for edge01.site1 to edge48.site1. The targets
+// share the connection's settings and {host} in the addresses and meta fields
+// is replaced with each target's name (see loaders.ExpandHosts):
+//  connection:
+//    edge[01-48].site1:
+//      addresses:
+//        - "{host}:9339"
+//      group: edge
 package simple
 
 import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/gnxi/utils/xpath"
@@ -58,16 +99,43 @@ var _ loaders.TargetLoader = new(SimpleTargetLoader)
 
 type TargetConfig struct {
 	Connection map[string]ConnectionConfig `yaml:"connection"`
+	Group      map[string]GroupConfig      `yaml:"group"`
+	Profile    map[string]ProfileConfig    `yaml:"profile"`
 	Request    map[string]RequestConfig    `yaml:"request"`
 }
 
 type ConnectionConfig struct {
 	Addresses   []string          `yaml:"addresses"`
+	Group       string            `yaml:"group"`
+	Profile     string            `yaml:"profile"`
 	Request     string            `yaml:"request"`
 	Meta        map[string]string `yaml:"meta"`
 	Credentials CredentialsConfig `yaml:"credentials"`
 }
 
+// GroupConfig contains the settings shared by all connections in the group.
+type GroupConfig struct {
+	Profile     string            `yaml:"profile"`
+	Meta        map[string]string `yaml:"meta"`
+	Credentials CredentialsConfig `yaml:"credentials"`
+}
+
+// ProfileConfig is a named subscription that may be shared by many connections.
+type ProfileConfig struct {
+	Target string              `yaml:"target"`
+	Mode   string              `yaml:"mode"`
+	Paths  []ProfilePathConfig `yaml:"paths"`
+}
+
+// ProfilePathConfig is a single subscription path in a ProfileConfig.
+type ProfilePathConfig struct {
+	Path              string        `yaml:"path"`
+	Mode              string        `yaml:"mode"`
+	SampleInterval    time.Duration `yaml:"sample_interval"`
+	SuppressRedundant bool          `yaml:"suppress_redundant"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+}
+
 type RequestConfig struct {
 	Target string   `yaml:"target"`
 	Paths  []string `yaml:"paths"`
@@ -83,6 +151,8 @@ type SimpleTargetLoader struct {
 	file     string
 	interval time.Duration
 	last     *targetpb.Configuration
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func init() {
@@ -94,6 +164,7 @@ func NewSimpleTargetLoader(config *configuration.GatewayConfig) loaders.TargetLo
 		config:   config,
 		file:     config.TargetLoaders.SimpleFile,
 		interval: config.TargetLoaders.SimpleFileReloadInterval,
+		stop:     make(chan struct{}),
 	}
 }
 
@@ -108,6 +179,9 @@ func (m *SimpleTargetLoader) GetConfiguration() (*targetpb.Configuration, error)
 		return nil, err
 	}
 
+	if err := loaders.ExpandTargets(configs); err != nil {
+		return nil, fmt.Errorf("configuration in %q is invalid: %v", m.file, err)
+	}
 	if err := target.Validate(configs); err != nil {
 		return nil, fmt.Errorf("configuration in %q is invalid: %v", m.file, err)
 	}
@@ -127,20 +201,9 @@ func (m *SimpleTargetLoader) yamlToTargets(data *[]byte) (*targetpb.Configuratio
 	for requestName, request := range simpleConfig.Request {
 		var subs []*gnmi.Subscription
 		for _, x := range request.Paths {
-
-			var origin = ""
-			split := strings.Split(x, ":")
-			if len(split) > 1 {
-				origin = split[0]
-				x = split[1]
-			}
-
-			path, err := xpath.ToGNMIPath(x)
+			path, err := parsePath(x)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse simple config XPath: %s: %v", x, err)
-			}
-			if origin != "" {
-				path.Origin = origin
+				return nil, err
 			}
 			subs = append(subs, &gnmi.Subscription{Path: path})
 		}
@@ -156,20 +219,132 @@ func (m *SimpleTargetLoader) yamlToTargets(data *[]byte) (*targetpb.Configuratio
 		}
 	}
 
+	for profileName, profile := range simpleConfig.Profile {
+		if _, exists := configs.Request[profileName]; exists {
+			return nil, fmt.Errorf("profile '%s' has the same name as a request", profileName)
+		}
+		request, err := profileToRequest(profile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile '%s': %v", profileName, err)
+		}
+		configs.Request[profileName] = request
+	}
+
 	for connName, conn := range simpleConfig.Connection {
+		var group GroupConfig
+		if conn.Group != "" {
+			var exists bool
+			group, exists = simpleConfig.Group[conn.Group]
+			if !exists {
+				return nil, fmt.Errorf("connection '%s' references unknown group '%s'", connName, conn.Group)
+			}
+		}
+
+		request := conn.Request
+		if request == "" {
+			request = conn.Profile
+		}
+		if request == "" {
+			request = group.Profile
+		}
+		if _, exists := configs.Request[request]; request != "" && !exists {
+			return nil, fmt.Errorf("connection '%s' references unknown profile or request '%s'", connName, request)
+		}
+
+		credentials := conn.Credentials
+		if credentials.Username == "" && credentials.Password == "" {
+			credentials = group.Credentials
+		}
+
+		var meta map[string]string
+		if group.Meta != nil || conn.Meta != nil {
+			meta = make(map[string]string)
+			for k, v := range group.Meta {
+				meta[k] = v
+			}
+			for k, v := range conn.Meta {
+				meta[k] = v
+			}
+		}
+
 		configs.Target[connName] = &targetpb.Target{
 			Addresses: conn.Addresses,
-			Request:   conn.Request,
-			Meta:      conn.Meta,
+			Request:   request,
+			Meta:      meta,
 			Credentials: &targetpb.Credentials{
-				Username: conn.Credentials.Username,
-				Password: conn.Credentials.Password,
+				Username: credentials.Username,
+				Password: credentials.Password,
 			},
 		}
 	}
 	return configs, nil
 }
 
+// parsePath parses an XPath with an optional gNMI Origin prefix.
+func parsePath(x string) (*gnmi.Path, error) {
+	var origin = ""
+	split := strings.Split(x, ":")
+	if len(split) > 1 {
+		origin = split[0]
+		x = split[1]
+	}
+
+	path, err := xpath.ToGNMIPath(x)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse simple config XPath: %s: %v", x, err)
+	}
+	if origin != "" {
+		path.Origin = origin
+	}
+	return path, nil
+}
+
+// profileToRequest converts a ProfileConfig into a SubscribeRequest.
+func profileToRequest(profile ProfileConfig) (*gnmi.SubscribeRequest, error) {
+	listMode := gnmi.SubscriptionList_STREAM
+	if profile.Mode != "" {
+		mode, exists := gnmi.SubscriptionList_Mode_value[strings.ToUpper(profile.Mode)]
+		if !exists {
+			return nil, fmt.Errorf("unknown subscription list mode '%s'", profile.Mode)
+		}
+		listMode = gnmi.SubscriptionList_Mode(mode)
+	}
+
+	var subs []*gnmi.Subscription
+	for _, p := range profile.Paths {
+		path, err := parsePath(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		sub := &gnmi.Subscription{
+			Path:              path,
+			SampleInterval:    uint64(p.SampleInterval.Nanoseconds()),
+			SuppressRedundant: p.SuppressRedundant,
+			HeartbeatInterval: uint64(p.HeartbeatInterval.Nanoseconds()),
+		}
+		if p.Mode != "" {
+			mode, exists := gnmi.SubscriptionMode_value[strings.ToUpper(p.Mode)]
+			if !exists {
+				return nil, fmt.Errorf("unknown subscription mode '%s' for path '%s'", p.Mode, p.Path)
+			}
+			sub.Mode = gnmi.SubscriptionMode(mode)
+		}
+		subs = append(subs, sub)
+	}
+
+	return &gnmi.SubscribeRequest{
+		Request: &gnmi.SubscribeRequest_Subscribe{
+			Subscribe: &gnmi.SubscriptionList{
+				Prefix: &gnmi.Path{
+					Target: profile.Target,
+				},
+				Mode:         listMode,
+				Subscription: subs,
+			},
+		},
+	}, nil
+}
+
 func (m *SimpleTargetLoader) Start() error {
 	_, err := m.GetConfiguration() // make sure there are no errors at startup
 	return err
@@ -193,8 +368,21 @@ func (m *SimpleTargetLoader) WatchConfiguration(targetChan chan<- *connections.T
 			controlMsg.Insert = targetConfig
 			m.last = targetConfig
 
-			targetChan <- controlMsg
+			select {
+			case targetChan <- controlMsg:
+			case <-m.stop:
+				return nil
+			}
+		}
+		select {
+		case <-time.After(m.interval):
+		case <-m.stop:
+			return nil
 		}
-		time.Sleep(m.interval)
 	}
 }
+
+// Stop stops watching the configuration.
+func (m *SimpleTargetLoader) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
@@ -16,8 +16,12 @@
 package simple
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
@@ -65,3 +69,155 @@ func TestSimpleTargetLoader_yamlToTargets(t *testing.T) {
 	assertion.Equal("mypassword", targetConfig.Target["my-router"].GetCredentials().Password)
 
 }
+
+const TestProfileData = `
+---
+profile:
+  core-routers:
+    target: "*"
+    paths:
+      - path: /interfaces/interface[name=*]/state/counters
+        mode: sample
+        sample_interval: 10s
+      - path: /network-instances
+        mode: on_change
+group:
+  core:
+    profile: core-routers
+    credentials:
+      username: groupusername
+      password: grouppassword
+    meta:
+      NoTLSVerify: "yes"
+connection:
+  core-router-1:
+    addresses:
+      - core-router-1.test.example.net:9339
+    group: core
+  core-router-2:
+    addresses:
+      - core-router-2.test.example.net:9339
+    group: core
+    credentials:
+      username: myusername
+      password: mypassword
+    meta:
+      NoLock: "yes"
+`
+
+func TestSimpleTargetLoader_yamlToTargetsProfiles(t *testing.T) {
+	assertion := assert.New(t)
+
+	loader := &SimpleTargetLoader{
+		config: &configuration.GatewayConfig{},
+	}
+	data := []byte(TestProfileData)
+	targetConfig, err := loader.yamlToTargets(&data)
+	assertion.NoError(err)
+
+	request := targetConfig.Request["core-routers"]
+	assertion.NotNil(request)
+	assertion.Equal(gnmi.SubscriptionList_STREAM, request.GetSubscribe().GetMode())
+	assertion.Len(request.GetSubscribe().GetSubscription(), 2)
+	assertion.Equal(gnmi.SubscriptionMode_SAMPLE, request.GetSubscribe().GetSubscription()[0].Mode)
+	assertion.Equal(uint64(10*time.Second), request.GetSubscribe().GetSubscription()[0].SampleInterval)
+	assertion.Equal(gnmi.SubscriptionMode_ON_CHANGE, request.GetSubscribe().GetSubscription()[1].Mode)
+
+	router1 := targetConfig.Target["core-router-1"]
+	assertion.Equal("core-routers", router1.GetRequest())
+	assertion.Equal("groupusername", router1.GetCredentials().Username)
+	assertion.Equal(map[string]string{"NoTLSVerify": "yes"}, router1.GetMeta())
+
+	router2 := targetConfig.Target["core-router-2"]
+	assertion.Equal("core-routers", router2.GetRequest())
+	assertion.Equal("myusername", router2.GetCredentials().Username)
+	assertion.Equal(map[string]string{"NoTLSVerify": "yes", "NoLock": "yes"}, router2.GetMeta())
+}
+
+func TestSimpleTargetLoader_yamlToTargetsUnknownGroup(t *testing.T) {
+	loader := &SimpleTargetLoader{
+		config: &configuration.GatewayConfig{},
+	}
+	data := []byte(`
+connection:
+  my-router:
+    addresses:
+      - my-router.test.example.net:9339
+    group: missing
+`)
+	_, err := loader.yamlToTargets(&data)
+	assert.Error(t, err)
+}
+
+func TestSimpleTargetLoader_yamlToTargetsUnknownProfile(t *testing.T) {
+	loader := &SimpleTargetLoader{
+		config: &configuration.GatewayConfig{},
+	}
+	for _, data := range []string{`
+connection:
+  my-router:
+    addresses:
+      - my-router.test.example.net:9339
+    profile: missing
+`, `
+group:
+  core:
+    profile: missing
+connection:
+  my-router:
+    addresses:
+      - my-router.test.example.net:9339
+    group: core
+`} {
+		data := []byte(data)
+		_, err := loader.yamlToTargets(&data)
+		assert.Error(t, err)
+	}
+}
+
+const TestTemplateData = `
+---
+group:
+  edge:
+    credentials:
+      username: myusername
+      password: mypassword
+request:
+  my-request:
+    target: "*"
+    paths:
+      - /interfaces
+connection:
+  edge[01-48].site1:
+    addresses:
+      - "{host}:9339"
+    request: my-request
+    group: edge
+`
+
+func TestSimpleTargetLoader_GetConfigurationTemplates(t *testing.T) {
+	assertion := assert.New(t)
+
+	file, err := ioutil.TempFile("", "simple-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(TestTemplateData)
+	assertion.NoError(err)
+	assertion.NoError(file.Close())
+
+	loader := &SimpleTargetLoader{
+		config: &configuration.GatewayConfig{},
+		file:   file.Name(),
+	}
+	targetConfig, err := loader.GetConfiguration()
+	assertion.NoError(err)
+	assertion.Len(targetConfig.Target, 48)
+	edge := targetConfig.Target["edge07.site1"]
+	if assertion.NotNil(edge) {
+		assertion.Equal([]string{"edge07.site1:9339"}, edge.GetAddresses())
+		assertion.Equal("my-request", edge.GetRequest())
+		assertion.Equal("myusername", edge.GetCredentials().Username)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locking
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Election elects a single leader among the cluster members that campaign
+// with a DistributedLocker for the same ID. The member holding the lock is
+// the leader. The other members retry every interval so a new leader is
+// elected once the leader's lock is released or, for Zookeeper locks, its
+// session expires.
+type Election struct {
+	lock     DistributedLocker
+	interval time.Duration
+	notify   func(leader bool)
+
+	// lockMutex serializes campaigning with Stop.
+	lockMutex sync.Mutex
+	stopped   bool
+	mutex     sync.Mutex
+	leader    bool
+	stop      chan struct{}
+}
+
+// NewElection creates an Election that campaigns with lock every interval.
+// notify, if not nil, is called each time this member becomes or stops being
+// the leader.
+func NewElection(lock DistributedLocker, interval time.Duration, notify func(leader bool)) *Election {
+	return &Election{
+		lock:     lock,
+		interval: interval,
+		notify:   notify,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Leader returns true if this member is currently the leader.
+func (e *Election) Leader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leader
+}
+
+// Run campaigns for leadership until Stop is called.
+func (e *Election) Run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.campaign()
+		select {
+		case <-ticker.C:
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// campaign tries to acquire the lock if it isn't held and updates the
+// leadership.
+func (e *Election) campaign() {
+	e.lockMutex.Lock()
+	defer e.lockMutex.Unlock()
+	if e.stopped {
+		return
+	}
+	acquired := e.lock.LockAcquired()
+	if !acquired {
+		var err error
+		acquired, err = e.lock.Try()
+		if err != nil {
+			log.Error().Msgf("Election %s: unable to acquire leadership: %v", e.lock.ID(), err)
+		}
+	}
+	e.setLeader(acquired)
+}
+
+func (e *Election) setLeader(leader bool) {
+	e.mutex.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mutex.Unlock()
+	if !changed {
+		return
+	}
+	if leader {
+		log.Info().Msgf("Election %s: elected leader.", e.lock.ID())
+	} else {
+		log.Info().Msgf("Election %s: no longer the leader.", e.lock.ID())
+	}
+	if e.notify != nil {
+		e.notify(leader)
+	}
+}
+
+// Stop stops campaigning and releases the leadership, if held.
+func (e *Election) Stop() {
+	e.lockMutex.Lock()
+	defer e.lockMutex.Unlock()
+	if e.stopped {
+		return
+	}
+	e.stopped = true
+	close(e.stop)
+	if e.lock.LockAcquired() {
+		if err := e.lock.Unlock(); err != nil {
+			log.Error().Msgf("Election %s: unable to release leadership: %v", e.lock.ID(), err)
+		}
+	}
+	e.setLeader(false)
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locking_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/locking"
+)
+
+func TestElection_Failover(t *testing.T) {
+	assertion := assert.New(t)
+
+	firstNotify := make(chan bool, 2)
+	first := locking.NewElection(locking.NewNonBlockingLock("test-election", "a"), 10*time.Millisecond, func(leader bool) {
+		firstNotify <- leader
+	})
+	go first.Run()
+	assertion.True(<-firstNotify)
+	assertion.True(first.Leader())
+
+	secondNotify := make(chan bool, 1)
+	second := locking.NewElection(locking.NewNonBlockingLock("test-election", "b"), 10*time.Millisecond, func(leader bool) {
+		secondNotify <- leader
+	})
+	go second.Run()
+	defer second.Stop()
+	time.Sleep(50 * time.Millisecond)
+	assertion.False(second.Leader())
+
+	first.Stop()
+	assertion.False(<-firstNotify)
+	select {
+	case leader := <-secondNotify:
+		assertion.True(leader)
+	case <-time.After(time.Second):
+		t.Fatal("second member was not elected after the leader stopped")
+	}
+}
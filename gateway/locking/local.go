@@ -24,6 +24,12 @@ import (
 // is to say, this implementation of DistributedLocker isn't actually distributed.
 var registry sync.Map
 
+// tokens are the last fencing tokens of each lock ID.
+var tokens = struct {
+	sync.Mutex
+	last map[string]uint64
+}{last: make(map[string]uint64)}
+
 // NonBlockingLock is an implementation of DistributedLocker that does NOT support multiple processes. NonBlockingLock
 // is used for testing and documenting a reference implementation of DistributedLocker. Do not use this in production
 // unless you have a clear understanding of how this works.
@@ -31,6 +37,7 @@ type NonBlockingLock struct {
 	acquired bool
 	id       string
 	member   string
+	token    uint64
 }
 
 func NewNonBlockingLock(id string, member string) DistributedLocker {
@@ -66,14 +73,27 @@ func (l *NonBlockingLock) Try() (bool, error) {
 		return false, nil
 	}
 	l.acquired = true
+	tokens.Lock()
+	tokens.last[l.id]++
+	l.token = tokens.last[l.id]
+	tokens.Unlock()
 	return true, nil
 }
 
+// Token implements DistributedLocker.
+func (l *NonBlockingLock) Token() uint64 {
+	if !l.acquired {
+		return 0
+	}
+	return l.token
+}
+
 func (l *NonBlockingLock) Unlock() error {
 	if !l.acquired {
 		return fmt.Errorf("unable to unlock: lock is not yet acquired for id '%s'", l.id)
 	}
 	registry.Delete(l.id)
 	l.acquired = false
+	l.token = 0
 	return nil
 }
@@ -37,3 +37,24 @@ func TestNonBlockingLock_Try(t *testing.T) {
 
 	assertion.NoError(lock.Unlock())
 }
+
+func TestNonBlockingLock_Token(t *testing.T) {
+	assertion := assert.New(t)
+
+	lock := locking.NewNonBlockingLock("test-id-token", "127.0.0.1:0")
+	assertion.Equal(uint64(0), lock.Token())
+	acquired, err := lock.Try()
+	assertion.True(acquired)
+	assertion.NoError(err)
+	first := lock.Token()
+	assertion.NotEqual(uint64(0), first)
+	assertion.NoError(lock.Unlock())
+	assertion.Equal(uint64(0), lock.Token())
+
+	other := locking.NewNonBlockingLock("test-id-token", "127.0.0.2:0")
+	acquired, err = other.Try()
+	assertion.True(acquired)
+	assertion.NoError(err)
+	assertion.True(other.Token() > first)
+	assertion.NoError(other.Unlock())
+}
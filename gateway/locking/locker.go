@@ -25,9 +25,26 @@ type DistributedLocker interface {
 	Try() (bool, error)
 	// Unlock the lock.
 	Unlock() error
+	// Token returns the fencing token of the current acquisition of the lock,
+	// or zero if the lock isn't acquired. Each acquisition of a lock ID
+	// returns a greater token than the previous ones, so the holder with the
+	// greatest token is the current one and updates from a previous holder
+	// that still believes it holds the lock can be rejected.
+	Token() uint64
 	// ID returns the ID or lock path for this lock.
 	ID() string
 	// GetMember gets the member that currently has the lock for the provided ID, if it's currently
 	// locked, otherwise return an empty string.
 	GetMember(id string) (string, error)
 }
+
+// LockFactory creates a DistributedLocker for the lock ID, held by member.
+type LockFactory func(id string, member string) DistributedLocker
+
+// LossNotifier is implemented by DistributedLockers that can lose an acquired
+// lock without Unlock being called, e.g. when the lock expires before it's
+// renewed.
+type LossNotifier interface {
+	// OnLost sets the function called when an acquired lock is lost.
+	OnLost(lost func())
+}
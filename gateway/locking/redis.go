@@ -0,0 +1,203 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locking
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+)
+
+var _ DistributedLocker = new(RedisNonBlockingLock)
+var _ LossNotifier = new(RedisNonBlockingLock)
+
+// RedisNonBlockingLock is a DistributedLocker backed by a single Redis
+// instance. The lock is a key holding the member name that expires after the
+// TTL unless it's renewed. The lock is renewed every third of the TTL while
+// it's acquired and is lost if it can't be renewed before it expires.
+type RedisNonBlockingLock struct {
+	client *redis.Client
+	id     string
+	key    string
+	member string
+	ttl    time.Duration
+
+	mutex    sync.Mutex
+	acquired bool
+	token    uint64
+	stop     chan struct{}
+	onLost   func()
+}
+
+// NewRedisNonBlockingLock creates a new lock instance for the ID using the
+// Redis client. A lock instance starts unlocked until Try() is called.
+func NewRedisNonBlockingLock(client *redis.Client, id string, member string, ttl time.Duration) DistributedLocker {
+	return &RedisNonBlockingLock{
+		client: client,
+		id:     id,
+		key:    redis.KeyFromPath(id),
+		member: member,
+		ttl:    ttl,
+	}
+}
+
+// LockAcquired implements DistributedLocker.
+func (l *RedisNonBlockingLock) LockAcquired() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.acquired
+}
+
+// Try implements DistributedLocker. The fencing token is taken from a
+// counter key that is incremented each time the lock is acquired.
+func (l *RedisNonBlockingLock) Try() (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.acquired {
+		return true, fmt.Errorf("deadlock: lock for id '%s' is already acquired", l.id)
+	}
+	reply, err := l.client.Do("SET", l.key, l.member, "NX", "PX", milliseconds(l.ttl))
+	if err != nil {
+		return false, fmt.Errorf("unable to acquire lock: %v", err)
+	}
+	if reply == nil {
+		return false, nil
+	}
+	reply, err = l.client.Do("INCR", l.key+":token")
+	if err == nil {
+		var token int64
+		token, err = redis.Int(reply)
+		l.token = uint64(token)
+	}
+	if err != nil {
+		if _, delErr := l.client.Do("EVAL", redis.CompareAndDeleteScript, "1", l.key, l.member); delErr != nil {
+			return false, fmt.Errorf("unable to remove lock after fencing token error: %v: %v", delErr, err)
+		}
+		return false, fmt.Errorf("unable to get fencing token: %v", err)
+	}
+	l.acquired = true
+	l.stop = make(chan struct{})
+	go l.renew(l.stop)
+	return true, nil
+}
+
+// renew extends the TTL of the lock until stop is closed or the lock is lost.
+func (l *RedisNonBlockingLock) renew(stop chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		reply, err := l.client.Do("EVAL", redis.CompareAndExpireScript, "1", l.key, l.member, milliseconds(l.ttl))
+		if err == nil {
+			var held int64
+			held, err = redis.Int(reply)
+			if err == nil && held == 1 {
+				renewed = time.Now()
+				continue
+			}
+			if err == nil {
+				err = fmt.Errorf("lock is held by another member")
+			}
+		} else if time.Since(renewed) < l.ttl {
+			log.Warn().Msgf("Lock %s: unable to renew lock: %v", l.id, err)
+			continue
+		}
+		l.lost(stop, err)
+		return
+	}
+}
+
+// lost releases the lock state after the lock was lost and calls the lost
+// function.
+func (l *RedisNonBlockingLock) lost(stop chan struct{}, err error) {
+	l.mutex.Lock()
+	if l.stop != stop {
+		// unlocked in the meantime
+		l.mutex.Unlock()
+		return
+	}
+	l.acquired = false
+	l.token = 0
+	l.stop = nil
+	onLost := l.onLost
+	l.mutex.Unlock()
+	log.Error().Msgf("Lock %s: lock lost: %v", l.id, err)
+	if onLost != nil {
+		onLost()
+	}
+}
+
+// OnLost implements LossNotifier.
+func (l *RedisNonBlockingLock) OnLost(lost func()) {
+	l.mutex.Lock()
+	l.onLost = lost
+	l.mutex.Unlock()
+}
+
+// Unlock releases an acquired lock. If the lock is not currently acquired by
+// this lock instance then ErrNotLocked is returned.
+func (l *RedisNonBlockingLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.acquired {
+		return ErrNotLocked
+	}
+	close(l.stop)
+	l.stop = nil
+	l.acquired = false
+	l.token = 0
+	if _, err := l.client.Do("EVAL", redis.CompareAndDeleteScript, "1", l.key, l.member); err != nil {
+		// the lock expires after the TTL
+		return fmt.Errorf("unable to release lock gracefully: %v", err)
+	}
+	return nil
+}
+
+// Token implements DistributedLocker.
+func (l *RedisNonBlockingLock) Token() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.token
+}
+
+// ID implements DistributedLocker.
+func (l *RedisNonBlockingLock) ID() string {
+	return l.id
+}
+
+// GetMember implements DistributedLocker.
+func (l *RedisNonBlockingLock) GetMember(id string) (string, error) {
+	reply, err := l.client.Do("GET", redis.KeyFromPath(id))
+	if err != nil {
+		return "", fmt.Errorf("unable to get lock member: %v", err)
+	}
+	member, _ := reply.(string)
+	return member, nil
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locking_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/locking"
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+	"github.com/openconfig/gnmi-gateway/gateway/redis/redistest"
+)
+
+func TestRedisNonBlockingLock(t *testing.T) {
+	assertion := assert.New(t)
+
+	server, err := redistest.NewServer()
+	if !assertion.NoError(err) {
+		return
+	}
+	defer server.Close()
+	client := redis.NewClient(redis.Options{Address: server.Address(), Timeout: time.Second})
+	defer client.Close()
+
+	lock := locking.NewRedisNonBlockingLock(client, "/gnmi/gateway/target/a", "127.0.0.1:1", time.Minute)
+	other := locking.NewRedisNonBlockingLock(client, "/gnmi/gateway/target/a", "127.0.0.2:1", time.Minute)
+	assertion.Equal(uint64(0), lock.Token())
+	assertion.Equal(locking.ErrNotLocked, lock.Unlock())
+
+	acquired, err := lock.Try()
+	assertion.True(acquired)
+	assertion.NoError(err)
+	assertion.True(lock.LockAcquired())
+	first := lock.Token()
+	assertion.NotEqual(uint64(0), first)
+
+	acquired, err = lock.Try()
+	assertion.True(acquired)
+	assertion.Errorf(err, "should have a deadlock error")
+
+	acquired, err = other.Try()
+	assertion.False(acquired)
+	assertion.NoError(err)
+
+	member, err := other.GetMember("/gnmi/gateway/target/a")
+	assertion.NoError(err)
+	assertion.Equal("127.0.0.1:1", member)
+
+	assertion.NoError(lock.Unlock())
+	assertion.False(lock.LockAcquired())
+	assertion.Equal(uint64(0), lock.Token())
+	member, err = other.GetMember("/gnmi/gateway/target/a")
+	assertion.NoError(err)
+	assertion.Equal("", member)
+
+	acquired, err = other.Try()
+	assertion.True(acquired)
+	assertion.NoError(err)
+	assertion.True(other.Token() > first)
+	assertion.NoError(other.Unlock())
+}
+
+func TestRedisNonBlockingLock_lost(t *testing.T) {
+	assertion := assert.New(t)
+
+	server, err := redistest.NewServer()
+	if !assertion.NoError(err) {
+		return
+	}
+	defer server.Close()
+	client := redis.NewClient(redis.Options{Address: server.Address(), Timeout: time.Second})
+	defer client.Close()
+
+	lock := locking.NewRedisNonBlockingLock(client, "/gnmi/gateway/target/a", "127.0.0.1:1", 150*time.Millisecond)
+	lost := make(chan struct{})
+	lock.(locking.LossNotifier).OnLost(func() {
+		close(lost)
+	})
+	acquired, err := lock.Try()
+	assertion.True(acquired)
+	assertion.NoError(err)
+
+	// the lock is renewed before it expires
+	time.Sleep(400 * time.Millisecond)
+	assertion.True(lock.LockAcquired())
+	value, exists := server.Get("gnmi:gateway:target:a")
+	assertion.True(exists)
+	assertion.Equal("127.0.0.1:1", value)
+
+	// another member took over the lock after it expired
+	server.Set("gnmi:gateway:target:a", "127.0.0.2:1")
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		assertion.Fail("lock wasn't lost")
+	}
+	assertion.False(lock.LockAcquired())
+	assertion.Equal(uint64(0), lock.Token())
+	assertion.Equal(locking.ErrNotLocked, lock.Unlock())
+	value, _ = server.Get("gnmi:gateway:target:a")
+	assertion.Equal("127.0.0.2:1", value)
+}
@@ -57,15 +57,22 @@ import (
 
 var _ DistributedLocker = new(ZookeeperNonBlockingLock)
 
+// ErrNotLocked is returned by Unlock if the lock isn't acquired. It's the
+// same error as zk.ErrNotLocked.
+var ErrNotLocked = zk.ErrNotLocked
+
 type ZookeeperNonBlockingLock struct {
 	acquired bool
 	conn     *zk.Conn
 	// The member that is holding the lock. This is usually the address and port where the cluster member is reachable.
-	member      string
-	id          string
-	acl         []zk.ACL
-	lockPath    string
-	seq         int
+	member   string
+	id       string
+	acl      []zk.ACL
+	lockPath string
+	seq      int
+	// sessionID is the Zookeeper session that the lock node was created in.
+	// The node is removed by Zookeeper when the session expires.
+	sessionID   int64
 	unlockMutex sync.Mutex
 }
 
@@ -83,6 +90,8 @@ func NewZookeeperNonBlockingLock(conn *zk.Conn, id string, member string, acl []
 }
 
 func (l *ZookeeperNonBlockingLock) LockAcquired() bool {
+	l.unlockMutex.Lock()
+	defer l.unlockMutex.Unlock()
 	return l.acquired
 }
 
@@ -106,6 +115,27 @@ func (l *ZookeeperNonBlockingLock) GetMember(id string) (string, error) {
 	return GetMember(l.conn, id)
 }
 
+// Token implements DistributedLocker. The token is derived from the sequence
+// number of the lock node, which Zookeeper increases for every node created
+// below the lock path. Token returns zero once the session that holds the lock
+// node has expired, even before watchState notices and unlocks the lock.
+func (l *ZookeeperNonBlockingLock) Token() uint64 {
+	l.unlockMutex.Lock()
+	defer l.unlockMutex.Unlock()
+	if l.lockPath == "" || !l.hasSession() {
+		return 0
+	}
+	return uint64(l.seq) + 1
+}
+
+// hasSession returns true if the session that the lock node was created in
+// hasn't expired. A disconnected client keeps its session, and Zookeeper
+// keeps the lock node, until the session times out, so the lock is only lost
+// once the session is reported expired or replaced by a new one.
+func (l *ZookeeperNonBlockingLock) hasSession() bool {
+	return l.conn.State() != zk.StateExpired && l.conn.SessionID() == l.sessionID
+}
+
 func (l *ZookeeperNonBlockingLock) ID() string {
 	return l.id
 }
@@ -119,11 +149,12 @@ func (l *ZookeeperNonBlockingLock) Try() (bool, error) {
 	var err error
 	currentState := l.conn.State()
 	if currentState == zk.StateConnected || currentState == zk.StateHasSession {
-		l.acquired, err = l.try()
-		if l.acquired {
+		var acquired bool
+		acquired, err = l.try()
+		if acquired && err == nil {
 			go l.watchState()
 		}
-		return l.acquired, err
+		return acquired, err
 	}
 	return false, fmt.Errorf("not connected to Zookeeper")
 }
@@ -132,7 +163,7 @@ func (l *ZookeeperNonBlockingLock) Try() (bool, error) {
 // is not acquired or an error occurs. If this instance already has the lock
 // then ErrDeadlock is returned.
 func (l *ZookeeperNonBlockingLock) try() (bool, error) {
-	if l.lockPath != "" {
+	if l.LockAcquired() {
 		return true, zk.ErrDeadlock
 	}
 
@@ -181,8 +212,12 @@ func (l *ZookeeperNonBlockingLock) try() (bool, error) {
 		return false, nil
 	}
 	// Acquired the lock
+	l.unlockMutex.Lock()
 	l.seq = seq
 	l.lockPath = path
+	l.sessionID = l.conn.SessionID()
+	l.acquired = true
+	l.unlockMutex.Unlock()
 	return true, nil
 }
 
@@ -237,12 +272,10 @@ func (l *ZookeeperNonBlockingLock) lowestSeqChild(path string) (int, string, err
 }
 
 func (l *ZookeeperNonBlockingLock) watchState() {
-	currentState := l.conn.State()
-	for l.LockAcquired() && (currentState == zk.StateConnected || currentState == zk.StateHasSession) {
+	for l.LockAcquired() && l.sessionHeld() {
 		time.Sleep(500 * time.Millisecond)
-		currentState = l.conn.State()
 	}
-	// zk is disconnected
+	// the zk session expired
 	if l.LockAcquired() {
 		err := l.Unlock()
 		if err != nil {
@@ -251,6 +284,14 @@ func (l *ZookeeperNonBlockingLock) watchState() {
 	}
 }
 
+// sessionHeld returns true if the session that the lock node was created in
+// hasn't expired.
+func (l *ZookeeperNonBlockingLock) sessionHeld() bool {
+	l.unlockMutex.Lock()
+	defer l.unlockMutex.Unlock()
+	return l.hasSession()
+}
+
 // Unlock releases an acquired lock. If the lock is not currently acquired by
 // this Lock instance than ErrNotLocked is returned.
 // This should only be called if we're still connected.
@@ -260,14 +301,17 @@ func (l *ZookeeperNonBlockingLock) Unlock() error {
 	if l.lockPath == "" {
 		return zk.ErrNotLocked
 	}
-	if err := l.conn.Delete(l.lockPath, -1); err != nil {
-		return fmt.Errorf("unable to release lock gracefully: %s", err)
-	}
-
-	// reset the lock internals
+	// The lock internals are reset before the node is deleted so that the
+	// lock isn't reported as held if the delete fails. Zookeeper removes the
+	// node when the session expires.
+	lockPath := l.lockPath
 	l.lockPath = ""
 	l.seq = 0
+	l.sessionID = 0
 	l.acquired = false
+	if err := l.conn.Delete(lockPath, -1); err != nil {
+		return fmt.Errorf("unable to release lock gracefully: %s", err)
+	}
 	log.Info().Msg("Cluster lock released.")
 	return nil
 }
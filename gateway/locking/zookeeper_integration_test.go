@@ -123,6 +123,27 @@ func TestZookeeperNonBlockingLock_GetMember(t *testing.T) {
 	conn.Close()
 }
 
+func TestZookeeperNonBlockingLock_closed(t *testing.T) {
+	assertion := assert.New(t)
+
+	conn, err := connectToZK()
+	assertion.NoError(err)
+
+	lock := locking.NewZookeeperNonBlockingLock(conn, ZookeeperIntegrationTestID, ZookeeperIntegrationTestMember, zk.WorldACL(zk.PermAll))
+	acquired, err := lock.Try()
+	assertion.NoError(err)
+	assertion.True(acquired)
+	assertion.NotZero(lock.Token())
+
+	// Closing the connection ends the session and Zookeeper removes the lock
+	// node. The lock is released even though the node can't be deleted.
+	conn.Close()
+	assertion.Error(lock.Unlock())
+	assertion.False(lock.LockAcquired())
+	assertion.Zero(lock.Token())
+	assertion.Equal(locking.ErrNotLocked, lock.Unlock())
+}
+
 func strReverse(in string) string {
 	out := ""
 	for i := range in {
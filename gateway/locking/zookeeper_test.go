@@ -0,0 +1,79 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+func TestZookeeperNonBlockingLock_sessionExpired(t *testing.T) {
+	assertion := assert.New(t)
+
+	conn, _, err := zk.Connect([]string{"127.0.0.1:1"}, time.Second, zk.WithLogger(nopLogger{}))
+	if !assertion.NoError(err) {
+		return
+	}
+	// The connection doesn't have the session that the lock node was created
+	// in, as it would after the session expired and a new one was created.
+	conn.Close()
+	lock := &ZookeeperNonBlockingLock{
+		conn:      conn,
+		id:        "/test-id-12345",
+		acquired:  true,
+		lockPath:  "/test-id-12345/_c_0123456789abcdef-lock:0000000004",
+		seq:       4,
+		sessionID: 1,
+	}
+	assertion.Zero(lock.Token(), "the token should be zero once the session is gone")
+	assertion.False(lock.sessionHeld())
+
+	// The lock is released even though the node can't be deleted.
+	assertion.Error(lock.Unlock())
+	assertion.False(lock.LockAcquired())
+	assertion.Zero(lock.Token())
+	assertion.Equal(ErrNotLocked, lock.Unlock())
+}
+
+func TestZookeeperNonBlockingLock_disconnected(t *testing.T) {
+	assertion := assert.New(t)
+
+	conn, _, err := zk.Connect([]string{"127.0.0.1:1"}, time.Second, zk.WithLogger(nopLogger{}))
+	if !assertion.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	// The connection is down but the session hasn't expired, so the lock node
+	// still exists.
+	lock := &ZookeeperNonBlockingLock{
+		conn:      conn,
+		id:        "/test-id-12345",
+		acquired:  true,
+		lockPath:  "/test-id-12345/_c_0123456789abcdef-lock:0000000004",
+		seq:       4,
+		sessionID: conn.SessionID(),
+	}
+	assertion.NotEqual(zk.StateExpired, conn.State())
+	assertion.Equal(uint64(5), lock.Token(), "the token should be kept while the session is alive")
+	assertion.True(lock.sessionHeld())
+}
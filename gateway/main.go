@@ -28,14 +28,59 @@ import (
 
 	"github.com/kelseyhightower/envconfig"
 
+	_ "github.com/openconfig/gnmi-gateway/gateway/adapters/all"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	_ "github.com/openconfig/gnmi-gateway/gateway/events/all"
 	_ "github.com/openconfig/gnmi-gateway/gateway/exporters/all"
+	_ "github.com/openconfig/gnmi-gateway/gateway/inventory/all"
 	_ "github.com/openconfig/gnmi-gateway/gateway/loaders/all"
+	_ "github.com/openconfig/gnmi-gateway/gateway/policy/all"
 )
 
 // Main is the entry point for the command-line and it's a good example of how to call StartGateway but
 // other than that you probably don't need Main for anything.
+//
+// If the first argument is "validate-config" the configuration is loaded and
+// validated and Main exits with a non-zero status if any problems are found.
+// If the first argument is "bench" a benchmark is run with synthetic targets
+// (see RunBench). If the first argument is "encrypt-secret" a credential is
+// encrypted for a target configuration (see RunEncryptSecret). If the first
+// argument is "admin" a command is sent to the admin API of a running gateway
+// (see RunAdmin).
+//
+// Under systemd with Type=notify Main reports when the gateway is ready and
+// pings the watchdog if WatchdogSec is set. When started by the Windows
+// Service Control Manager Main runs the gateway as a Windows service.
 func Main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := RunBench(os.Args[2:], os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := RunAdmin(os.Args[2:], os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "encrypt-secret" {
+		if err := RunEncryptSecret(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	validateOnly := len(os.Args) > 1 && os.Args[1] == "validate-config"
+	if validateOnly {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	config := configuration.NewDefaultGatewayConfig()
 	err := ParseArgs(config)
 	if err != nil {
@@ -43,24 +88,40 @@ func Main() {
 		os.Exit(1)
 	}
 
+	if validateOnly {
+		if err := ValidateConfig(config); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid.")
+		os.Exit(0)
+	}
+
 	if PrintVersion {
 		fmt.Println(fmt.Sprintf("gnmi-gateway version %s (Built %s)", Version, Buildtime))
 		os.Exit(0)
 	}
 
-	var deferred []func()
+	gateway := NewGateway(config)
+	systemd := newSystemdNotifier()
+
+	// The first signal stops the gateway gracefully and the second one
+	// exits immediately, e.g. if an exporter hangs while flushing.
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		config.Log.Info().Msg("Ctrl^C pressed.")
-		for _, deferredFunc := range deferred {
-			deferredFunc()
+		config.Log.Info().Msg("Received a shutdown signal; stopping the gateway.")
+		if err := systemd.notify("STOPPING=1"); err != nil {
+			config.Log.Warn().Msgf("Unable to notify systemd: %v", err)
 		}
-		config.Log.Info().Msg("Exit.")
-		os.Exit(0)
+		gateway.Stop()
+		<-c
+		config.Log.Info().Msg("Received a second shutdown signal; exiting.")
+		os.Exit(1)
 	}()
 
+	var deferred []func()
 	debugCleanup, err := SetupDebugging(config)
 	if err != nil {
 		config.Log.Error().Err(err).Msgf("Unable to setup debugging: %v", err)
@@ -72,13 +133,27 @@ func Main() {
 	}
 
 	opts := new(StartOpts)
+	opts.Hooks.OnStarted = func(*Gateway) {
+		if err := systemd.notify("READY=1"); err != nil {
+			config.Log.Warn().Msgf("Unable to notify systemd: %v", err)
+		}
+	}
+	go systemd.runWatchdog(gateway.stop, func(err error) {
+		config.Log.Warn().Msgf("Unable to ping the systemd watchdog: %v", err)
+	})
 
-	gateway := NewGateway(config)
-	err = gateway.StartGateway(opts) // run forever (or until an error happens)
+	isService, err := runWindowsService(gateway, opts)
+	if !isService {
+		err = gateway.StartGateway(opts) // run until an error happens or the gateway is stopped
+	}
+	for _, deferredFunc := range deferred {
+		deferredFunc()
+	}
 	if err != nil {
 		config.Log.Error().Msgf("Gateway exited with an error: %v", err)
 		os.Exit(1)
 	}
+	config.Log.Info().Msg("Exit.")
 }
 
 // ParseArgs will parse all of the command-line parameters and configured the associated attributes on the
@@ -91,39 +166,152 @@ func ParseArgs(config *configuration.GatewayConfig) error {
 	flag.BoolVar(&PrintVersion, "version", false, "Print version and exit")
 
 	// Configuration Parameters
-	configFile := flag.String("ConfigFile", "", "Path of the gateway configuration JSON file.")
+	flag.BoolVar(&config.AdminDiagnostics, "AdminDiagnostics", false, "Expose pprof profiles, runtime metrics, and a connection dump on the admin HTTP server")
+	flag.StringVar(&config.AdminListenAddress, "AdminListenAddress", "127.0.0.1:6160", "The address and port the admin HTTP server will listen on")
+	flag.StringVar(&config.AdminToken, "AdminToken", "", "Bearer token required for admin HTTP server requests")
+	flag.IntVar(&config.CacheShards, "CacheShards", 1, "Number of cache shards that targets are spread across to reduce lock contention")
+	flag.StringVar(&config.CaptureDirectory, "CaptureDirectory", "", "Directory that admin API capture files are created in (empty disables captures)")
+	configFile := flag.String("ConfigFile", "", "Path of the gateway configuration JSON or YAML (.yaml, .yml) file.")
+	clusterMemberCommonNames := flag.String("ClusterMemberCommonNames", "", "Comma-separated list of the common names of the TLS client certificates of the other cluster members; clients with these certificates bypass the server ACL")
+	flag.DurationVar(&config.ClockSkewThreshold, "ClockSkewThreshold", 0, "Warn when the average difference between the receive time and notification timestamps of a target exceeds this duration (0 disables the check)")
+	flag.DurationVar(&config.DefaultLeafTTL, "DefaultLeafTTL", 0, "Delete cached leaves that haven't been updated within this time (0 disables expiry)")
+	flag.StringVar(&config.DialOutListener.ClientCA, "DialOutClientCA", "", "Path to a PEM-encoded CA bundle used to verify dial-out client certificates")
+	flag.StringVar(&config.DialOutListener.Address, "DialOutListenAddress", "", "Address (host:port) to accept dial-out connections from targets using the dialout protocol on (empty disables dial-out)")
+	flag.BoolVar(&config.EnableAdminServer, "EnableAdminServer", false, "Enable the admin HTTP server")
 	flag.BoolVar(&config.EnableGNMIServer, "EnableGNMIServer", false, "Enable the gNMI server")
 	exporters := flag.String("Exporters", "", "Comma-separated list of Exporters to enable.")
+	exporterElasticsearchAddresses := flag.String("ExporterElasticsearchAddresses", "", "Comma-separated list of Elasticsearch or OpenSearch URLs for the Elasticsearch Exporter")
+	flag.DurationVar(&config.Exporters.ElasticsearchFlushInterval, "ExporterElasticsearchFlushInterval", 1*time.Second, "Interval between Elasticsearch bulk requests for changed documents")
+	flag.StringVar(&config.Exporters.ElasticsearchPassword, "ExporterElasticsearchPassword", "", "Password for Elasticsearch basic authentication")
+	flag.StringVar(&config.Exporters.ElasticsearchUsername, "ExporterElasticsearchUsername", "", "Username for Elasticsearch basic authentication")
+	flag.StringVar(&config.Exporters.FileDirectory, "ExporterFileDirectory", "", "Directory the file Exporter writes files partitioned by target and hour to")
+	flag.StringVar(&config.Exporters.FileFormat, "ExporterFileFormat", "jsonl", "Format of the files written by the file Exporter: jsonl, jsonl.gz, or parquet")
 	flag.Int64Var(&config.Exporters.KafkaBatchBytes, "ExporterKafkaBatchBytes", 1048576, "Max bytes that will be buffered before flushing messages to a Kafka partition")
 	flag.IntVar(&config.Exporters.KafkaBatchSize, "ExporterKafkaBatchSize", 10000, "Max number of messages that will be buffered before flushing messages to a Kafka partition")
 	flag.DurationVar(&config.Exporters.KafkaBatchTimeout, "ExporterKafkaBatchTimeout", 1*time.Second, "Max seconds between flushing messages to a Kafka partition")
 	exporterKafkaBrokers := flag.String("ExporterKafkaBrokers", "", "Comma-separated list of Kafka broker addresses and ports for the Kafka Exporter to connect to")
 	flag.BoolVar(&config.Exporters.KafkaLogging, "ExporterKafkaLogging", false, "Enables info level logging from the Kafka writer. Error level logging is always enabled")
 	flag.StringVar(&config.Exporters.KafkaTopic, "ExporterKafkaTopic", "", "Kafka topic to send exported gNMI messages to.")
+	flag.IntVar(&config.Exporters.KinesisBatchSize, "ExporterKinesisBatchSize", 500, "Max number of records that will be buffered before writing them to Kinesis (max 500)")
+	flag.DurationVar(&config.Exporters.KinesisBatchTimeout, "ExporterKinesisBatchTimeout", 1*time.Second, "Max time between writes of buffered records to Kinesis")
+	flag.StringVar(&config.Exporters.KinesisEndpoint, "ExporterKinesisEndpoint", "", "Overrides the Kinesis API endpoint for the region")
+	flag.StringVar(&config.Exporters.KinesisRegion, "ExporterKinesisRegion", "", "AWS region of the Kinesis stream (default is $AWS_REGION)")
+	flag.StringVar(&config.Exporters.KinesisStream, "ExporterKinesisStream", "", "Kinesis data stream to write exported gNMI messages to")
+	flag.DurationVar(&config.Exporters.LeaderElectionInterval, "ExporterLeaderElectionInterval", 5*time.Second, "Interval at which cluster members campaign for leadership of singleton exporters")
 
 	flag.StringVar(&config.Exporters.InfluxDBTarget, "ExportersInfluxDBTarget", "http://localhost:8086", "InfluxDB target URL (default is http://localhost:8086")
 	flag.StringVar(&config.Exporters.InfluxDBToken, "ExportersInfluxDBToken", "", "Sets the InfluxDB authentication token")
 	flag.StringVar(&config.Exporters.InfluxDBOrg, "ExportersInfluxDBOrg", "", "Sets the InfluxDB organization name")
 	flag.StringVar(&config.Exporters.InfluxDBBucket, "ExportersInfluxDBBucket", "", "Sets the InfluxDB bucket name")
 	flag.UintVar(&config.Exporters.InfluxDBBatchSize, "ExportersInfluxDBBatchSize", 20, "Sets the writer batch size for InfluxDB records (default is 20")
+	flag.DurationVar(&config.Exporters.NATSAckTimeout, "ExporterNATSAckTimeout", 5*time.Second, "Max time to wait for a JetStream publish acknowledgement before publishing again")
+	flag.StringVar(&config.Exporters.NATSSubjectTemplate, "ExporterNATSSubjectTemplate", "gnmi.{{.Target}}.{{.Path}}", "Go template for the NATS subject of each notification (fields: .Target, .Origin, .Path)")
+	flag.StringVar(&config.Exporters.NATSURL, "ExporterNATSURL", "", "nats:// or tls:// URL of the NATS server for the NATS JetStream Exporter to publish to")
+	flag.StringVar(&config.Exporters.OTLPEndpoint, "ExporterOTLPEndpoint", "", "OTLP/HTTP metrics endpoint for the OTLP Exporter to push metrics to (e.g. http://localhost:4318/v1/metrics)")
+	flag.DurationVar(&config.Exporters.OTLPInterval, "ExporterOTLPInterval", 10*time.Second, "Interval between pushes of updated metrics to the OTLP endpoint")
+	flag.BoolVar(&config.Exporters.PrometheusSnapshot, "ExporterPrometheusSnapshot", false, "Serve the Prometheus metrics from a snapshot of the cache taken for each scrape")
+	flag.IntVar(&config.Exporters.PubSubBatchSize, "ExporterPubSubBatchSize", 1000, "Max number of messages that will be buffered before publishing them to Pub/Sub (max 1000)")
+	flag.DurationVar(&config.Exporters.PubSubBatchTimeout, "ExporterPubSubBatchTimeout", 1*time.Second, "Max time between publishes of buffered messages to Pub/Sub")
+	flag.StringVar(&config.Exporters.PubSubEndpoint, "ExporterPubSubEndpoint", "https://pubsub.googleapis.com", "Pub/Sub API endpoint (use a regional endpoint for ordered delivery)")
+	flag.StringVar(&config.Exporters.PubSubProject, "ExporterPubSubProject", "", "Google Cloud project of the Pub/Sub topic")
+	flag.StringVar(&config.Exporters.PubSubTopic, "ExporterPubSubTopic", "", "Pub/Sub topic to publish exported gNMI messages to")
 
+	flag.IntVar(&config.EventBufferSize, "EventBufferSize", 1000, "Number of target events buffered for each event sink")
+	eventKafkaBrokers := flag.String("EventKafkaBrokers", "", "Comma-separated list of Kafka broker addresses and ports for the kafka event sink")
+	flag.StringVar(&config.EventKafkaTopic, "EventKafkaTopic", "", "Kafka topic the kafka event sink writes target events to")
+	eventSinks := flag.String("EventSinks", "", "Comma-separated list of event sinks (log, webhook, kafka) that target connection state changes are published to")
+	flag.StringVar(&config.EventWebhookAuthHeader, "EventWebhookAuthHeader", "", "Authorization header value for the webhook event sink requests (e.g. 'Bearer <token>')")
+	flag.IntVar(&config.EventWebhookRetries, "EventWebhookRetries", 3, "Number of retries of a webhook event after a connection error or a 429 or 5xx response")
+	flag.DurationVar(&config.EventWebhookRetryBackoff, "EventWebhookRetryBackoff", 1*time.Second, "Time to wait before the first retry of a webhook event; doubles with each retry")
+	flag.StringVar(&config.EventWebhookTemplate, "EventWebhookTemplate", "", "Go template for the JSON body of webhook event requests (empty posts the event as JSON)")
+	eventWebhookTypes := flag.String("EventWebhookTypes", "", "Comma-separated list of event types posted by the webhook event sink (empty posts all types)")
+	flag.StringVar(&config.EventWebhookURL, "EventWebhookURL", "", "URL the webhook event sink posts target events to")
 	flag.Uint64Var(&config.GatewayTransitionBufferSize, "GatewayTransitionBufferSize", 100000, "Tunes the size of the buffer between targets and exporters/clients")
+	flag.IntVar(&config.FanoutWorkers, "FanoutWorkers", 1, "Number of workers delivering updates to gNMI subscriptions and concurrent exporters; each target's updates stay in order")
+	flag.BoolVar(&config.FailoverDeduplication, "FailoverDeduplication", false, "Only export the leaves that changed when a target's initial sync follows a cluster failover")
+	flag.StringVar(&config.FederationID, "FederationID", "", "ID of this gateway in a federation of gateways, used to detect loops (defaults to the hostname)")
+	flag.StringVar(&config.InventoryFile, "InventoryFile", "", "JSON file that maps target names to inventory labels for exporter outputs")
+	inventoryLabels := flag.String("InventoryLabels", "", "Comma-separated list of inventory labels to add to exporter outputs")
+	flag.BoolVar(&config.InventoryNetBox, "InventoryNetBox", false, "Load inventory labels (site, role, region) from the NetBox target loader's NetBox instance")
+	flag.DurationVar(&config.InventoryReloadInterval, "InventoryReloadInterval", 5*time.Minute, "Interval between reloads of the inventory labels")
+	flag.DurationVar(&config.LeafTTLSweepInterval, "LeafTTLSweepInterval", 1*time.Minute, "Interval between checks for expired cache leaves")
 	flag.BoolVar(&config.LogCaller, "LogCaller", false, "Include the file and line number with each log message")
+	middlewares := flag.String("Middlewares", "", "Comma-separated list of Middlewares that notifications received from targets pass through")
+	flag.BoolVar(&config.NormalizeJSON, "NormalizeJSON", false, "Decode JSON and JSON_IETF encoded target values into an update for each leaf")
 	flag.StringVar(&config.OpenConfigDirectory, "OpenConfigDirectory", "", "Directory (required to enable Prometheus exporter)")
+	flag.StringVar(&config.PolicyQuery, "PolicyQuery", "", "Decision evaluated in the PolicyRegoFile policy (default data.gnmi.allow)")
+	flag.StringVar(&config.PolicyRegoFile, "PolicyRegoFile", "", "Rego policy file authorizing the targets and paths gNMI clients subscribe to")
+	flag.DurationVar(&config.PolicyTimeout, "PolicyTimeout", 1*time.Second, "Maximum time to wait for a policy decision (0 disables the timeout)")
+	flag.StringVar(&config.PolicyURL, "PolicyURL", "", "URL of the Open Policy Agent document authorizing the targets and paths gNMI clients subscribe to")
+	flag.StringVar(&config.RedisAddress, "RedisAddress", "", "Address (host:port) of a Redis server to use for clustering instead of Zookeeper")
+	flag.IntVar(&config.RedisDB, "RedisDB", 0, "Redis database used for clustering")
+	flag.StringVar(&config.RedisPassword, "RedisPassword", "", "Password for the Redis server")
+	flag.BoolVar(&config.RedisTLS, "RedisTLS", false, "Connect to the Redis server with TLS")
+	flag.DurationVar(&config.RedisTTL, "RedisTTL", 10*time.Second, "Expiry time of the Redis target locks and cluster member registration. Failover time is up to RedisTTL")
+	flag.DurationVar(&config.RetentionDuration, "RetentionDuration", 0, "Amount of time notifications are retained in memory for each target to serve gNMI history requests (0 disables retention)")
+	flag.IntVar(&config.RetentionSize, "RetentionSize", 10000, "Maximum number of notifications retained in memory for each target")
+	flag.StringVar(&config.SchemaValidation, "SchemaValidation", "", "Validate target updates against the OpenConfig models in OpenConfigDirectory: flag or drop (empty disables validation)")
+	flag.StringVar(&config.SecretKeyFile, "SecretKeyFile", "", "File containing the key that decrypts target credentials encrypted with the local key provider")
+	flag.StringVar(&config.SecretKMSEndpoint, "SecretKMSEndpoint", "", "Overrides the AWS KMS API endpoint for SecretKMSRegion")
+	flag.StringVar(&config.SecretKMSKeyID, "SecretKMSKeyID", "", "ID, ARN, or alias of the AWS KMS key that target credentials are encrypted with")
+	flag.StringVar(&config.SecretKMSRegion, "SecretKMSRegion", "", "AWS region of the KMS key that decrypts target credentials (default is $AWS_REGION)")
 	flag.StringVar(&config.ServerAddress, "ServerAddress", "", "The IP address where other cluster members can reach the gNMI server. The first assigned IP address is used if the parameter is not provided")
 	flag.IntVar(&config.ServerPort, "ServerPort", 0, "The TCP port where other cluster members can reach the gNMI server. ServerListenPort is used if the parameter is not provided")
-	flag.StringVar(&config.ServerListenAddress, "ServerListenAddress", "0.0.0.0", "The interface IP address the gNMI server will listen on")
+	flag.StringVar(&config.ServerAccessLog, "ServerAccessLog", "", "Access log of the gNMI server RPCs: a file path, stdout, stderr, or log for the gateway logger (empty disables the access log)")
+	flag.DurationVar(&config.ServerIdleHeartbeat, "ServerIdleHeartbeat", 0, "Time a streaming gNMI subscription may be idle before a heartbeat message is sent to the client (0 disables idle heartbeats)")
+	flag.StringVar(&config.ServerIdleHeartbeatMessage, "ServerIdleHeartbeatMessage", "notification", "Heartbeat message sent to idle gNMI clients: notification or sync")
+	flag.DurationVar(&config.ServerKeepaliveMinTime, "ServerKeepaliveMinTime", 0, "Minimum time between client keepalive pings; clients that ping more often are disconnected (0 uses the gRPC default)")
+	flag.BoolVar(&config.ServerKeepalivePermitWithoutStream, "ServerKeepalivePermitWithoutStream", false, "Allow clients to send keepalive pings without active streams")
+	flag.DurationVar(&config.ServerKeepaliveTime, "ServerKeepaliveTime", 0, "Time after which the gNMI server pings an idle client connection (0 uses the gRPC default)")
+	flag.DurationVar(&config.ServerKeepaliveTimeout, "ServerKeepaliveTimeout", 0, "Time to wait for a keepalive ping response before closing the connection (0 uses the gRPC default)")
+	serverMaxConcurrentStreams := flag.Uint("ServerMaxConcurrentStreams", 0, "Maximum number of concurrent streams for each gNMI client connection (0 is unlimited)")
+	flag.DurationVar(&config.ServerMaxConnectionAge, "ServerMaxConnectionAge", 0, "Maximum age of a gNMI client connection before it's gracefully closed (0 is unlimited)")
+	flag.DurationVar(&config.ServerMaxConnectionAgeGrace, "ServerMaxConnectionAgeGrace", 0, "Time allowed for RPCs to complete after ServerMaxConnectionAge before the connection is closed (0 is unlimited)")
+	flag.DurationVar(&config.ServerMaxConnectionIdle, "ServerMaxConnectionIdle", 0, "Time a gNMI client connection may be idle before it's closed (0 is unlimited)")
+	flag.IntVar(&config.ServerMaxRecvMsgSize, "ServerMaxRecvMsgSize", 0, "Maximum message size in bytes the gNMI server can receive (0 uses the gRPC default of 4MB)")
+	flag.IntVar(&config.ServerMaxSendMsgSize, "ServerMaxSendMsgSize", 0, "Maximum message size in bytes the gNMI server can send (0 is unlimited)")
+	flag.IntVar(&config.ServerClientQueueLimit, "ServerClientQueueLimit", 0, "Maximum number of streaming updates queued for a single gNMI client (0 disables the limit)")
+	flag.StringVar(&config.ServerSlowConsumerPolicy, "ServerSlowConsumerPolicy", "coalesce", "Action when a gNMI client's queue is full: coalesce, drop-oldest, or disconnect")
+	flag.StringVar(&config.ServerListenAddress, "ServerListenAddress", "0.0.0.0", "The interface IP address the gNMI server will listen on (e.g. 0.0.0.0, ::, or 127.0.0.1)")
 	flag.IntVar(&config.ServerListenPort, "ServerListenPort", 9339, "TCP port to run the gNMI server on")
+	flag.BoolVar(&config.ServerReflection, "ServerReflection", false, "Register the gRPC reflection service on the gNMI server for tools like grpcurl")
+	flag.StringVar(&config.ServerSocketMode, "ServerSocketMode", "0660", "Octal file mode of the gNMI server Unix domain socket")
+	flag.StringVar(&config.ServerSocketPath, "ServerSocketPath", "", "Path of a Unix domain socket the gNMI server will listen on without TLS (empty disables the socket)")
 	flag.StringVar(&config.ServerTLSCert, "ServerTLSCert", "", "File containing the gNMI server TLS certificate (required to enable the gNMI server)")
 	flag.StringVar(&config.ServerTLSKey, "ServerTLSKey", "", "File containing the gNMI server TLS key (required to enable the gNMI server)")
 	flag.StringVar(&config.TargetLoaders.SimpleFile, "SimpleFile", "", "Simple YAML file containing the target configurations")
 	flag.DurationVar(&config.TargetLoaders.SimpleFileReloadInterval, "SimpleFileReloadInterval", 30*time.Second, "Interval to reload the simple YAML file containing the target configurations")
 	flag.StringVar(&config.StatsSpectatorURI, "StatsSpectatorURI", "", "URI for Atlas server to send Spectator metrics to (required to enable sending internal gateway stats to Atlas)")
 	targetLoaders := flag.String("TargetLoaders", "", "Comma-separated list of Target Loaders to enable.")
+	flag.StringVar(&config.TargetLoaders.DiscoveryFile, "TargetDiscoveryFile", "", "YAML file configuring the seeds, allow list, and target template of the discovery loader")
+	flag.DurationVar(&config.TargetLoaders.DiscoveryInterval, "TargetDiscoveryInterval", 1*time.Minute, "Interval to discover new targets from the LLDP neighbors of connected targets")
 	flag.StringVar(&config.TargetLoaders.JSONFile, "TargetJSONFile", "", "JSON file containing the target configurations")
 	flag.DurationVar(&config.TargetLoaders.JSONFileReloadInterval, "TargetJSONFileReloadInterval", 30*time.Second, "Interval to reload the JSON file containing the target configurations")
+	flag.StringVar(&config.TargetLoaders.KubernetesAPIServer, "TargetKubernetesAPIServer", "", "URL of the Kubernetes API server (empty uses the in-cluster service account)")
+	flag.StringVar(&config.TargetLoaders.KubernetesNamespace, "TargetKubernetesNamespace", "", "Namespace to load GNMITarget resources from (empty loads from all namespaces)")
+	flag.DurationVar(&config.TargetLoaders.KubernetesReloadInterval, "TargetKubernetesReloadInterval", 5*time.Minute, "Interval to list the GNMITarget resources again if no changes are seen")
+	flag.DurationVar(&config.TargetLoaders.KubernetesStatusInterval, "TargetKubernetesStatusInterval", 30*time.Second, "Interval to write target connection status to the GNMITarget resources (0 disables status updates)")
+	flag.BoolVar(&config.TargetCapabilitiesProbe, "TargetCapabilitiesProbe", false, "Send a Capabilities request to targets before subscribing and select a supported encoding")
+	flag.StringVar(&config.TargetCompression, "TargetCompression", "", "gRPC compression for target connections: gzip or zstd (empty disables compression)")
+	flag.StringVar(&config.TargetAddressFamily, "TargetAddressFamily", "", "Preferred address family for dual-stack targets: ipv4 or ipv6 (empty prefers the family of the first address)")
+	flag.DurationVar(&config.TargetAuthFailureBackoff, "TargetAuthFailureBackoff", 1*time.Minute, "Time to wait before reconnecting after a target authentication failure; doubles with each consecutive failure")
+	flag.IntVar(&config.TargetAuthFailureLimit, "TargetAuthFailureLimit", 3, "Consecutive authentication failures after which a target is quarantined (0 disables quarantine)")
+	flag.BoolVar(&config.TargetAuthFailureStop, "TargetAuthFailureStop", false, "Stop connecting to quarantined targets until their configuration changes")
 	flag.DurationVar(&config.TargetDialTimeout, "TargetDialTimeout", 10*time.Second, "Dial timeout time")
+	flag.BoolVar(&config.TargetDisabledFlush, "TargetDisabledFlush", false, "Remove the cached data of targets when they are disabled instead of keeping it")
+	flag.IntVar(&config.TargetErrorHistory, "TargetErrorHistory", 10, "Number of errors kept for each target for the /targets/errors admin endpoint")
+	flag.DurationVar(&config.TargetHappyEyeballsDelay, "TargetHappyEyeballsDelay", 300*time.Millisecond, "Time to wait for a connection to a dual-stack target before trying the other address family in parallel (0 tries addresses one after the other)")
+	flag.BoolVar(&config.TimestampFixUnits, "TimestampFixUnits", false, "Convert notification timestamps that appear to be in seconds, milliseconds, or microseconds to nanoseconds")
+	flag.DurationVar(&config.TimestampMaxFuture, "TimestampMaxFuture", 0, "Maximum time a notification timestamp may be ahead of the receive time (0 disables the check)")
+	flag.DurationVar(&config.TimestampMaxPast, "TimestampMaxPast", 0, "Maximum time a notification timestamp may be behind the receive time (0 disables the check)")
+	flag.StringVar(&config.TimestampPolicy, "TimestampPolicy", "", "Action for out-of-range notification timestamps: reject, clamp, or overwrite (empty disables timestamp checks)")
 	flag.IntVar(&config.TargetLimit, "TargetLimit", 100, "Maximum number of targets that this instance will connect to at once")
+	flag.StringVar(&config.TargetLocalAddress, "TargetLocalAddress", "", "Local IP address target connections are made from")
+	flag.StringVar(&config.TargetLocalInterface, "TargetLocalInterface", "", "Network interface or VRF device target connections are bound to (Linux only)")
+	flag.DurationVar(&config.TargetMetadataInterval, "TargetMetadataInterval", 0, "Interval between updates of the per-target meta leaves below /meta (0 disables meta leaves)")
+	flag.StringVar(&config.TargetNetworkNamespace, "TargetNetworkNamespace", "", "Name or path of the network namespace target connections are made in (Linux only)")
+	flag.DurationVar(&config.TargetSubscribeRefresh, "TargetSubscribeRefresh", 0, "Time a target subscription is kept open before the target is reconnected (0 keeps subscriptions open)")
+	flag.DurationVar(&config.TargetSyncTimeout, "TargetSyncTimeout", 0, "Time a target is given to send its initial sync_response before it's reconnected (0 waits indefinitely)")
 	flag.StringVar(&config.TargetLoaders.NetBoxAPIKey, "TargetNetBoxAPIKey", "", "API Key for NetBox target loader")
 	flag.IntVar(&config.TargetLoaders.NetBoxDeviceGNMIPort, "TargetNetBoxDeviceGNMIPort", 0, "The port that the gNMI is served from on devices loaded from NetBox ")
 	flag.StringVar(&config.TargetLoaders.NetBoxDeviceUsername, "TargetNetBoxDeviceUsername", "", "The port that the gNMI is served from on devices loaded from NetBox ")
@@ -132,15 +320,27 @@ func ParseArgs(config *configuration.GatewayConfig) error {
 	flag.StringVar(&config.TargetLoaders.NetBoxIncludeTag, "TargetNetBoxIncludeTag", "", "A tag to filter devices loaded from NetBox")
 	flag.DurationVar(&config.TargetLoaders.NetBoxReloadInterval, "TargetNetBoxReloadInterval", 3*time.Minute, "The frequency at which to check NetBox for new or changed devices.")
 	netboxSubscribePaths := flag.String("TargetNetBoxSubscribePaths", "", "Comma separated (no spaces) list of paths to subscribe to for devices loaded from NetBox")
+	flag.StringVar(&config.TracingOTLPEndpoint, "TracingOTLPEndpoint", "", "OTLP/HTTP traces URL of an OpenTelemetry collector to export traces to (empty disables tracing)")
+	flag.Float64Var(&config.TracingSampleRatio, "TracingSampleRatio", 0.01, "Fraction of traces to export, between 0 and 1")
+	flag.StringVar(&config.TunnelListener.ClientCA, "TunnelClientCA", "", "Path to a PEM-encoded CA bundle used to verify tunnel client certificates")
+	flag.StringVar(&config.TunnelListener.Address, "TunnelListenAddress", "", "Address (host:port) of the gRPC tunnel server that targets behind NAT register with (empty disables the tunnel server)")
 	zkHosts := flag.String("ZookeeperHosts", "", "Comma separated (no spaces) list of zookeeper hosts including port")
 	flag.StringVar(&config.ZookeeperPrefix, "ZookeeperPrefix", "/gnmi/gateway/", "Prefix for the lock path in Zookeeper")
 	flag.DurationVar(&config.ZookeeperTimeout, "ZookeeperTimeout", 1*time.Second, "Zookeeper timeout time. Minimum is 1 second. Failover time is (ZookeeperTimeout * 2)")
 
 	flag.Parse()
+	config.ClusterMemberCommonNames = cleanSplit(*clusterMemberCommonNames)
+	config.EventKafkaBrokers = cleanSplit(*eventKafkaBrokers)
+	config.EventSinks = cleanSplit(*eventSinks)
+	config.EventWebhookTypes = cleanSplit(*eventWebhookTypes)
 	config.Exporters.Enabled = cleanSplit(*exporters)
+	config.InventoryLabels = cleanSplit(*inventoryLabels)
+	config.Middlewares = cleanSplit(*middlewares)
+	config.Exporters.ElasticsearchAddresses = cleanSplit(*exporterElasticsearchAddresses)
 	config.Exporters.KafkaBrokers = cleanSplit(*exporterKafkaBrokers)
 	config.TargetLoaders.Enabled = cleanSplit(*targetLoaders)
 	config.TargetLoaders.NetBoxSubscribePaths = cleanSplit(*netboxSubscribePaths)
+	config.ServerMaxConcurrentStreams = uint32(*serverMaxConcurrentStreams)
 	config.ZookeeperHosts = cleanSplit(*zkHosts)
 
 	if *configFile != "" {
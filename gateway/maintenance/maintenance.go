@@ -0,0 +1,139 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package maintenance evaluates the scheduled maintenance windows of targets.
+package maintenance
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Windows are the maintenance windows of the targets. The active window of a
+// target is computed once per minute.
+type Windows struct {
+	windows []window
+
+	mutex  sync.Mutex
+	active map[string]activeWindow
+}
+
+type window struct {
+	name     string
+	targets  []string
+	schedule *schedule
+	duration time.Duration
+	location *time.Location
+}
+
+type activeWindow struct {
+	minute int64
+	name   string
+}
+
+// New parses the maintenance windows. nil Windows are returned if there are
+// no windows.
+func New(config []configuration.MaintenanceWindow) (*Windows, error) {
+	if len(config) == 0 {
+		return nil, nil
+	}
+	w := &Windows{active: make(map[string]activeWindow)}
+	for _, c := range config {
+		s, err := parseSchedule(c.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window '%s': %v", c.Name, err)
+		}
+		for _, pattern := range c.Targets {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("maintenance window '%s': invalid target pattern '%s': %v", c.Name, pattern, err)
+			}
+		}
+		location := time.UTC
+		if c.Timezone != "" {
+			if location, err = time.LoadLocation(c.Timezone); err != nil {
+				return nil, fmt.Errorf("maintenance window '%s': %v", c.Name, err)
+			}
+		}
+		if c.Duration <= 0 {
+			return nil, fmt.Errorf("maintenance window '%s': duration must be greater than 0", c.Name)
+		}
+		w.windows = append(w.windows, window{
+			name:     c.Name,
+			targets:  c.Targets,
+			schedule: s,
+			duration: c.Duration,
+			location: location,
+		})
+	}
+	return w, nil
+}
+
+// Active returns the name of the maintenance window that the target is in at
+// now, or an empty string if the target isn't in a maintenance window. Active
+// returns an empty string for nil Windows.
+func (w *Windows) Active(target string, now time.Time) string {
+	if w == nil {
+		return ""
+	}
+	minute := now.Unix() / 60
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if a, exists := w.active[target]; exists && a.minute == minute {
+		return a.name
+	}
+	var name string
+	for i := range w.windows {
+		if w.windows[i].matchTarget(target) && w.windows[i].activeAt(now) {
+			name = w.windows[i].name
+			break
+		}
+	}
+	w.active[target] = activeWindow{minute: minute, name: name}
+	return name
+}
+
+// Forget drops the cached state of a removed target.
+func (w *Windows) Forget(target string) {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	delete(w.active, target)
+	w.mutex.Unlock()
+}
+
+func (w *window) matchTarget(target string) bool {
+	for _, pattern := range w.targets {
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// activeAt returns true if the window started within duration before now.
+func (w *window) activeAt(now time.Time) bool {
+	start := now.In(w.location).Truncate(time.Minute)
+	for ; now.Sub(start) < w.duration; start = start.Add(-time.Minute) {
+		if w.schedule.match(start) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestParseSchedule(t *testing.T) {
+	assertion := assert.New(t)
+
+	s, err := parseSchedule("*/15 2-4 * * 6,7")
+	if !assertion.NoError(err) {
+		return
+	}
+	// Saturday 2021-01-02 and Sunday 2021-01-03.
+	assertion.True(s.match(time.Date(2021, 1, 2, 2, 30, 0, 0, time.UTC)))
+	assertion.True(s.match(time.Date(2021, 1, 3, 4, 45, 0, 0, time.UTC)))
+	assertion.False(s.match(time.Date(2021, 1, 2, 2, 31, 0, 0, time.UTC)))
+	assertion.False(s.match(time.Date(2021, 1, 2, 5, 0, 0, 0, time.UTC)))
+	assertion.False(s.match(time.Date(2021, 1, 4, 2, 30, 0, 0, time.UTC)))
+
+	// Day of month or day of week if both are restricted.
+	s, err = parseSchedule("0 0 1 * 1")
+	if assertion.NoError(err) {
+		assertion.True(s.match(time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)))
+		assertion.True(s.match(time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)))
+		assertion.False(s.match(time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC)))
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := parseSchedule(expr)
+		assertion.Error(err, expr)
+	}
+}
+
+func TestWindows_Active(t *testing.T) {
+	assertion := assert.New(t)
+
+	windows, err := New([]configuration.MaintenanceWindow{{
+		Name:     "saturday",
+		Targets:  []string{"dc1-*"},
+		Schedule: "0 23 * * 6",
+		Duration: 2 * time.Hour,
+	}})
+	if !assertion.NoError(err) {
+		return
+	}
+	saturday := time.Date(2021, 1, 2, 23, 0, 0, 0, time.UTC)
+	assertion.Equal("saturday", windows.Active("dc1-router", saturday))
+	assertion.Equal("saturday", windows.Active("dc1-router", saturday.Add(119*time.Minute)))
+	assertion.Equal("", windows.Active("dc1-router", saturday.Add(2*time.Hour)))
+	assertion.Equal("", windows.Active("dc1-router", saturday.Add(-time.Minute)))
+	assertion.Equal("", windows.Active("dc2-router", saturday))
+
+	windows, err = New(nil)
+	assertion.NoError(err)
+	assertion.Equal("", windows.Active("dc1-router", saturday))
+}
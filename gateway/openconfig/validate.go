@@ -0,0 +1,156 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openconfig
+
+import (
+	"errors"
+	"fmt"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+)
+
+// ErrUnknownPath is returned by Validate for paths that aren't in the schema.
+var ErrUnknownPath = errors.New("path is not in the schema")
+
+// TypeMismatchError is returned by Validate for values that don't match the
+// YANG type of the leaf.
+type TypeMismatchError struct {
+	Type  string
+	Value string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("%s value is not valid for YANG type %s", e.Value, e.Type)
+}
+
+// GetEntryByPath returns the YANG entry for the given OpenConfig path, or nil
+// if the path is not in the schema.
+func (t *TypeLookup) GetEntryByPath(path []string) *yang.Entry {
+	if len(path) == 0 {
+		return nil
+	}
+	entry, exists := t.treeRoot[path[0]]
+	for _, elem := range path[1:] {
+		if !exists {
+			return nil
+		}
+		entry, exists = entry.Dir[elem]
+	}
+	if !exists {
+		return nil
+	}
+	return entry
+}
+
+// Validate checks that path exists in the schema and that val is valid for
+// the type of the leaf. JSON encoded values are only checked for a valid path.
+func (t *TypeLookup) Validate(path []string, val *gnmipb.TypedValue) error {
+	entry := t.GetEntryByPath(path)
+	if entry == nil {
+		return ErrUnknownPath
+	}
+	switch val.GetValue().(type) {
+	case *gnmipb.TypedValue_JsonVal, *gnmipb.TypedValue_JsonIetfVal, *gnmipb.TypedValue_AnyVal, *gnmipb.TypedValue_ProtoBytes:
+		return nil
+	}
+	if entry.Type == nil {
+		return &TypeMismatchError{Type: "container", Value: valueKind(val)}
+	}
+	if leafList, ok := val.GetValue().(*gnmipb.TypedValue_LeaflistVal); ok {
+		for _, element := range leafList.LeaflistVal.GetElement() {
+			if !validValue(entry.Type, element) {
+				return &TypeMismatchError{Type: entry.Type.Name, Value: valueKind(element)}
+			}
+		}
+		return nil
+	}
+	if !validValue(entry.Type, val) {
+		return &TypeMismatchError{Type: entry.Type.Name, Value: valueKind(val)}
+	}
+	return nil
+}
+
+// validValue returns true if val can hold a value of the YANG type. Only the
+// kind of value is checked; ranges, patterns, and enum values are not.
+func validValue(typ *yang.YangType, val *gnmipb.TypedValue) bool {
+	switch typ.Kind {
+	case yang.Yunion:
+		for _, member := range typ.Type {
+			if validValue(member, val) {
+				return true
+			}
+		}
+		return false
+	case yang.Yleafref, yang.Yempty, yang.Ynone:
+		return true
+	case yang.Yint8, yang.Yint16, yang.Yint32, yang.Yint64:
+		switch val.GetValue().(type) {
+		case *gnmipb.TypedValue_IntVal, *gnmipb.TypedValue_UintVal:
+			return true
+		}
+	case yang.Yuint8, yang.Yuint16, yang.Yuint32, yang.Yuint64:
+		switch v := val.GetValue().(type) {
+		case *gnmipb.TypedValue_UintVal:
+			return true
+		case *gnmipb.TypedValue_IntVal:
+			return v.IntVal >= 0
+		}
+	case yang.Ybool:
+		_, ok := val.GetValue().(*gnmipb.TypedValue_BoolVal)
+		return ok
+	case yang.Ystring, yang.Yenum, yang.Yidentityref, yang.Ybits:
+		_, ok := val.GetValue().(*gnmipb.TypedValue_StringVal)
+		return ok
+	case yang.Ydecimal64:
+		switch val.GetValue().(type) {
+		case *gnmipb.TypedValue_DecimalVal, *gnmipb.TypedValue_FloatVal, *gnmipb.TypedValue_DoubleVal:
+			return true
+		}
+	case yang.Ybinary:
+		switch val.GetValue().(type) {
+		case *gnmipb.TypedValue_BytesVal, *gnmipb.TypedValue_StringVal:
+			return true
+		}
+	default:
+		return true
+	}
+	return false
+}
+
+// valueKind returns a short name for the kind of value in val.
+func valueKind(val *gnmipb.TypedValue) string {
+	switch val.GetValue().(type) {
+	case *gnmipb.TypedValue_StringVal:
+		return "string"
+	case *gnmipb.TypedValue_IntVal:
+		return "int"
+	case *gnmipb.TypedValue_UintVal:
+		return "uint"
+	case *gnmipb.TypedValue_BoolVal:
+		return "bool"
+	case *gnmipb.TypedValue_BytesVal:
+		return "bytes"
+	case *gnmipb.TypedValue_FloatVal, *gnmipb.TypedValue_DoubleVal:
+		return "float"
+	case *gnmipb.TypedValue_DecimalVal:
+		return "decimal"
+	case *gnmipb.TypedValue_LeaflistVal:
+		return "leaf-list"
+	default:
+		return fmt.Sprintf("%T", val.GetValue())
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openconfig
+
+import (
+	"testing"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeLookup_Validate(t *testing.T) {
+	assertion := assert.New(t)
+
+	counters := &yang.Entry{Name: "counters", Dir: map[string]*yang.Entry{
+		"in-octets": {Name: "in-octets", Type: &yang.YangType{Name: "counter64", Kind: yang.Yuint64}},
+	}}
+	state := &yang.Entry{Name: "state", Dir: map[string]*yang.Entry{
+		"counters": counters,
+		"enabled":  {Name: "enabled", Type: &yang.YangType{Name: "boolean", Kind: yang.Ybool}},
+		"mtu": {Name: "mtu", Type: &yang.YangType{Name: "union", Kind: yang.Yunion, Type: []*yang.YangType{
+			{Name: "uint16", Kind: yang.Yuint16},
+			{Name: "string", Kind: yang.Ystring},
+		}}},
+	}}
+	lookup := &TypeLookup{treeRoot: map[string]*yang.Entry{
+		"interfaces": {Name: "interfaces", Dir: map[string]*yang.Entry{
+			"interface": {Name: "interface", Dir: map[string]*yang.Entry{"state": state}},
+		}},
+	}}
+
+	path := func(elems ...string) []string {
+		return append([]string{"interfaces", "interface", "state"}, elems...)
+	}
+	uintVal := &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: 1}}
+	stringVal := &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "1"}}
+	jsonVal := &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte("{}")}}
+
+	assertion.NoError(lookup.Validate(path("counters", "in-octets"), uintVal))
+	assertion.NoError(lookup.Validate(path("counters", "in-octets"), &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: 1}}))
+	assertion.Error(lookup.Validate(path("counters", "in-octets"), &gnmipb.TypedValue{Value: &gnmipb.TypedValue_IntVal{IntVal: -1}}))
+	assertion.IsType(&TypeMismatchError{}, lookup.Validate(path("counters", "in-octets"), stringVal))
+	assertion.Error(lookup.Validate(path("enabled"), stringVal))
+	assertion.NoError(lookup.Validate(path("mtu"), uintVal))
+	assertion.NoError(lookup.Validate(path("mtu"), stringVal))
+	assertion.NoError(lookup.Validate(path("counters"), jsonVal))
+	assertion.Error(lookup.Validate(path("counters"), uintVal))
+	assertion.Equal(ErrUnknownPath, lookup.Validate(path("counters", "in-bananas"), uintVal))
+	assertion.Equal(ErrUnknownPath, lookup.Validate([]string{"system"}, uintVal))
+}
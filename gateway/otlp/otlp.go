@@ -0,0 +1,111 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp contains the OTLP/HTTP JSON encoding types shared by the
+// trace and metric exporters, so that telemetry can be sent to an
+// OpenTelemetry collector without the OpenTelemetry SDK.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Resource is an OTLP resource.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// Scope is an OTLP instrumentation scope.
+type Scope struct {
+	Name string `json:"name"`
+}
+
+// DefaultScope is the instrumentation scope of the gateway.
+var DefaultScope = Scope{Name: "github.com/openconfig/gnmi-gateway"}
+
+// KeyValue is an OTLP attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is an OTLP attribute value. Exactly one field is set.
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// Attribute returns an attribute for a string, bool, integer, float, or
+// time.Duration (as nanoseconds) value. Other values are formatted as
+// strings.
+func Attribute(key string, value interface{}) KeyValue {
+	var v AnyValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case bool:
+		v.BoolValue = &value
+	case int:
+		i := strconv.FormatInt(int64(value), 10)
+		v.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(value, 10)
+		v.IntValue = &i
+	case uint64:
+		i := strconv.FormatUint(value, 10)
+		v.IntValue = &i
+	case float64:
+		v.DoubleValue = &value
+	case time.Duration:
+		i := strconv.FormatInt(value.Nanoseconds(), 10)
+		v.IntValue = &i
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return KeyValue{Key: key, Value: v}
+}
+
+// UnixNano formats a time as OTLP JSON nanoseconds.
+func UnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// Post sends an OTLP JSON request to the endpoint.
+func Post(client *http.Client, endpoint string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
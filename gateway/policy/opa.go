@@ -0,0 +1,100 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+// OPADecider queries an Open Policy Agent server with the REST Data API
+// (POST /v1/data/<path>). The decision is the boolean result of the
+// document, or its "allow" field if the result is an object. Requests are
+// denied if the document is undefined.
+type OPADecider struct {
+	url    string
+	client *http.Client
+}
+
+var _ server.PolicyDecider = &OPADecider{}
+
+// NewOPADecider creates an OPADecider for the document at rawURL, e.g.
+// http://localhost:8181/v1/data/gnmi/allow.
+func NewOPADecider(rawURL string) (*OPADecider, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PolicyURL '%s': %v", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid PolicyURL '%s': scheme must be http or https", rawURL)
+	}
+	return &OPADecider{url: rawURL, client: &http.Client{}}, nil
+}
+
+// Allow implements server.PolicyDecider.
+func (d *OPADecider) Allow(ctx context.Context, input *server.PolicyInput) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return false, fmt.Errorf("OPA returned %s", resp.Status)
+	}
+
+	var decision struct {
+		Result interface{} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, fmt.Errorf("invalid OPA response: %v", err)
+	}
+	return decisionResult(decision.Result)
+}
+
+// decisionResult returns the decision for a policy result: a boolean or an
+// object with an "allow" boolean. An undefined (nil) result denies the
+// request.
+func decisionResult(result interface{}) (bool, error) {
+	switch v := result.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case map[string]interface{}:
+		if allow, ok := v["allow"].(bool); ok {
+			return allow, nil
+		}
+	}
+	return false, fmt.Errorf("policy result %v is not a boolean", result)
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+func TestOPADecider_Allow(t *testing.T) {
+	assertion := assert.New(t)
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input server.PolicyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Input.Target {
+		case "router1":
+			_, _ = w.Write([]byte(`{"result": true}`))
+		case "router2":
+			_, _ = w.Write([]byte(`{"result": {"allow": req.Input.Principal == "alice"}}`))
+		case "router3":
+			_, _ = w.Write([]byte(`{"result": {"allow": true}}`))
+		case "undefined":
+			_, _ = w.Write([]byte(`{}`))
+		case "invalid":
+			_, _ = w.Write([]byte(`{"result": "yes"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer opa.Close()
+
+	d, err := NewOPADecider(opa.URL + "/v1/data/gnmi/allow")
+	assertion.NoError(err)
+	ctx := context.Background()
+
+	allowed, err := d.Allow(ctx, &server.PolicyInput{Principal: "alice", Target: "router1"})
+	assertion.NoError(err)
+	assertion.True(allowed)
+	allowed, err = d.Allow(ctx, &server.PolicyInput{Principal: "alice", Target: "router3", Paths: []string{"/interfaces"}})
+	assertion.NoError(err)
+	assertion.True(allowed)
+	allowed, err = d.Allow(ctx, &server.PolicyInput{Principal: "alice", Target: "undefined"})
+	assertion.NoError(err)
+	assertion.False(allowed)
+	_, err = d.Allow(ctx, &server.PolicyInput{Target: "invalid"})
+	assertion.Error(err)
+	_, err = d.Allow(ctx, &server.PolicyInput{Target: "error"})
+	assertion.Error(err)
+
+	_, err = NewOPADecider("localhost:8181")
+	assertion.Error(err)
+}
+
+func TestNewDecider(t *testing.T) {
+	assertion := assert.New(t)
+
+	d, err := NewDecider(&configuration.GatewayConfig{})
+	assertion.NoError(err)
+	assertion.Nil(d)
+
+	d, err = NewDecider(&configuration.GatewayConfig{PolicyURL: "http://localhost:8181/v1/data/gnmi/allow"})
+	assertion.NoError(err)
+	assertion.IsType(&OPADecider{}, d)
+
+	_, err = NewDecider(&configuration.GatewayConfig{PolicyURL: "http://localhost:8181", PolicyRegoFile: "policy.rego"})
+	assertion.Error(err)
+}
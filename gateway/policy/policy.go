@@ -0,0 +1,67 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy provides Open Policy Agent decision points for the gNMI
+// server's PolicyACL: an external OPA server queried with the REST Data API,
+// or a Rego policy file evaluated in the gateway.
+//
+// The policy input is a server.PolicyInput, e.g.:
+//		{"principal": "noc-dashboard", "peer": "192.0.2.10:51234",
+//		 "target": "router1", "paths": ["/interfaces/interface/state"]}
+// and the policy decision must be a boolean, e.g.:
+//		package gnmi
+//
+//		default allow = false
+//
+//		allow {
+//			input.principal == "noc-dashboard"
+//			startswith(input.paths[_], "/interfaces")
+//		}
+package policy
+
+import (
+	"errors"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+// DefaultQuery is the query of the Rego policy decision if PolicyQuery isn't
+// set.
+const DefaultQuery = "data.gnmi.allow"
+
+func init() {
+	server.RegisterPolicyDecider(NewDecider)
+}
+
+// NewDecider returns the decision point set in the configuration: an OPA
+// server if PolicyURL is set or the Rego policy in PolicyRegoFile. It returns
+// nil if neither is set.
+func NewDecider(config *configuration.GatewayConfig) (server.PolicyDecider, error) {
+	switch {
+	case config.PolicyURL != "" && config.PolicyRegoFile != "":
+		return nil, errors.New("PolicyURL and PolicyRegoFile can't both be set")
+	case config.PolicyURL != "":
+		return NewOPADecider(config.PolicyURL)
+	case config.PolicyRegoFile != "":
+		query := config.PolicyQuery
+		if query == "" {
+			query = DefaultQuery
+		}
+		return NewRegoDecider(config.PolicyRegoFile, query, config.Log)
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/rs/zerolog"
+
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+// regoReloadInterval is how often the Rego policy file is checked for
+// changes.
+const regoReloadInterval = 5 * time.Second
+
+// RegoDecider evaluates a Rego policy file in the gateway. The file is
+// reloaded when it changes so that the policy can be updated without
+// restarting the gateway. If a changed file fails to compile the error is
+// logged and the previous policy is kept.
+type RegoDecider struct {
+	path  string
+	query string
+	log   zerolog.Logger
+
+	mutex     sync.Mutex
+	prepared  rego.PreparedEvalQuery
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+var _ server.PolicyDecider = &RegoDecider{}
+
+// NewRegoDecider creates a RegoDecider for the policy file at path. query
+// is the decision evaluated for each request, e.g. "data.gnmi.allow".
+// Errors reloading the file are logged to log.
+func NewRegoDecider(path string, query string, log zerolog.Logger) (*RegoDecider, error) {
+	d := &RegoDecider{path: filepath.Clean(path), query: query, log: log}
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Rego policy: %v", err)
+	}
+	if err := d.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load compiles the policy file.
+func (d *RegoDecider) load(modTime time.Time) error {
+	module, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return fmt.Errorf("unable to read Rego policy: %v", err)
+	}
+	prepared, err := rego.New(
+		rego.Query(d.query),
+		rego.Module(d.path, string(module)),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to compile Rego policy %s: %v", d.path, err)
+	}
+	d.prepared = prepared
+	d.modTime = modTime
+	return nil
+}
+
+// reload reloads the policy file if it changed since it was last loaded.
+func (d *RegoDecider) reload() {
+	now := time.Now()
+	if now.Sub(d.lastCheck) < regoReloadInterval {
+		return
+	}
+	d.lastCheck = now
+	info, err := os.Stat(d.path)
+	if err != nil {
+		d.log.Error().Msgf("Unable to reload Rego policy: %v", err)
+		return
+	}
+	if info.ModTime().Equal(d.modTime) {
+		return
+	}
+	if err := d.load(info.ModTime()); err != nil {
+		// Don't retry until the file changes again.
+		d.modTime = info.ModTime()
+		d.log.Error().Msgf("%v; keeping the previous policy", err)
+		return
+	}
+	d.log.Info().Msgf("Reloaded Rego policy %s.", d.path)
+}
+
+// Allow implements server.PolicyDecider.
+func (d *RegoDecider) Allow(ctx context.Context, input *server.PolicyInput) (bool, error) {
+	d.mutex.Lock()
+	d.reload()
+	prepared := d.prepared
+	d.mutex.Unlock()
+
+	results, err := prepared.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return false, err
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		// the decision is undefined
+		return false, nil
+	}
+	return decisionResult(results[0].Expressions[0].Value)
+}
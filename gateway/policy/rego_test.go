@@ -0,0 +1,82 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+const testPolicy = `package gnmi
+
+default allow = false
+
+allow {
+	input.principal == "alice"
+	startswith(input.paths[_], "/interfaces")
+}
+`
+
+func TestRegoDecider_Allow(t *testing.T) {
+	assertion := assert.New(t)
+	dir, err := ioutil.TempDir("", "gnmi-gateway")
+	assertion.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gnmi.rego")
+	assertion.NoError(ioutil.WriteFile(path, []byte(testPolicy), 0600))
+
+	d, err := NewRegoDecider(path, DefaultQuery, zerolog.Nop())
+	assertion.NoError(err)
+	ctx := context.Background()
+
+	allowed, err := d.Allow(ctx, &server.PolicyInput{Principal: "alice", Target: "router1", Paths: []string{"/interfaces/interface"}})
+	assertion.NoError(err)
+	assertion.True(allowed)
+	allowed, err = d.Allow(ctx, &server.PolicyInput{Principal: "alice", Target: "router1", Paths: []string{"/system"}})
+	assertion.NoError(err)
+	assertion.False(allowed)
+	allowed, err = d.Allow(ctx, &server.PolicyInput{Principal: "bob", Target: "router1", Paths: []string{"/interfaces"}})
+	assertion.NoError(err)
+	assertion.False(allowed)
+
+	// A policy that doesn't compile keeps the previous policy.
+	assertion.NoError(ioutil.WriteFile(path, []byte("package gnmi\nallow {"), 0600))
+	d.lastCheck = time.Time{}
+	d.modTime = time.Time{}
+	allowed, err = d.Allow(ctx, &server.PolicyInput{Principal: "alice", Target: "router1", Paths: []string{"/interfaces"}})
+	assertion.NoError(err)
+	assertion.True(allowed)
+
+	// A changed policy is reloaded.
+	assertion.NoError(ioutil.WriteFile(path, []byte("package gnmi\nallow = true\n"), 0600))
+	d.lastCheck = time.Time{}
+	d.modTime = time.Time{}
+	allowed, err = d.Allow(ctx, &server.PolicyInput{Principal: "bob", Target: "router1"})
+	assertion.NoError(err)
+	assertion.True(allowed)
+
+	_, err = NewRegoDecider(filepath.Join(dir, "missing.rego"), DefaultQuery, zerolog.Nop())
+	assertion.Error(err)
+}
@@ -0,0 +1,256 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rates computes the deltas and per-second rates of monotonic
+// counters so that consumers that want rates don't need to compute them from
+// the raw counter values.
+//
+// Deltas and rates are published as derived leaves next to the counter, with
+// the name of the counter leaf plus a suffix, e.g. in-octets-rate. Counters
+// that decrease are treated as 32-bit or 64-bit wraps if the previous value
+// was in the upper half of the 32-bit or 64-bit range, otherwise as resets to
+// zero, in which case the delta is the new value.
+package rates
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gnxi/utils/xpath"
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// DefaultRateSuffix is used if neither suffix of a CounterRate is set.
+const DefaultRateSuffix = "-rate"
+
+// Calculator computes the deltas and rates of the counters that match its
+// rules. A Calculator keeps the previous sample of each counter so a separate
+// Calculator must be used for each stream of notifications. The samples of a
+// target are dropped when the target or the counter paths are deleted.
+type Calculator struct {
+	rules []rule
+
+	mutex   sync.Mutex
+	samples map[string]map[string]sample // target -> counter -> sample
+}
+
+type rule struct {
+	path        []*gnmipb.PathElem
+	deltaSuffix string
+	rateSuffix  string
+}
+
+type sample struct {
+	value     uint64
+	timestamp int64
+}
+
+// Rules are parsed CounterRates that Calculators can be created from without
+// parsing the configuration again.
+type Rules struct {
+	rules []rule
+}
+
+// New creates a Calculator from the CounterRates that have ExportersOnly set
+// to exportersOnly. A nil Calculator is returned if there are no such rules.
+func New(counterRates []configuration.CounterRate, exportersOnly bool) (*Calculator, error) {
+	r, err := Parse(counterRates, exportersOnly)
+	if err != nil {
+		return nil, err
+	}
+	return r.NewCalculator(), nil
+}
+
+// Parse parses the CounterRates that have ExportersOnly set to exportersOnly.
+// nil Rules are returned if there are no such rules.
+func Parse(counterRates []configuration.CounterRate, exportersOnly bool) (*Rules, error) {
+	c := new(Rules)
+	for _, counterRate := range counterRates {
+		if counterRate.ExportersOnly != exportersOnly {
+			continue
+		}
+		p, err := xpath.ToGNMIPath(counterRate.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter rate path '%s': %v", counterRate.Path, err)
+		}
+		r := rule{path: p.Elem, deltaSuffix: counterRate.DeltaSuffix, rateSuffix: counterRate.RateSuffix}
+		if r.deltaSuffix == "" && r.rateSuffix == "" {
+			r.rateSuffix = DefaultRateSuffix
+		}
+		c.rules = append(c.rules, r)
+	}
+	if len(c.rules) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// NewCalculator creates a Calculator with the rules. A nil Calculator is
+// returned for nil Rules.
+func (r *Rules) NewCalculator() *Calculator {
+	if r == nil {
+		return nil
+	}
+	return &Calculator{rules: r.rules, samples: make(map[string]map[string]sample)}
+}
+
+// Derive returns the delta and rate updates for the counters in the
+// notification. Deltas are uint values and rates are double values. No
+// updates are returned for the first sample of a counter.
+func (c *Calculator) Derive(notification *gnmipb.Notification) []*gnmipb.Update {
+	prefix := notification.GetPrefix()
+	var derived []*gnmipb.Update
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	target := prefix.GetTarget()
+	if utils.IsTargetDelete(notification) {
+		delete(c.samples, target)
+		return nil
+	}
+	samples := c.samples[target]
+	for _, p := range notification.Delete {
+		key := sampleKey(prefix, p)
+		for existing := range samples {
+			if existing == key || strings.HasPrefix(existing, key+"/") {
+				delete(samples, existing)
+			}
+		}
+	}
+	for _, update := range notification.Update {
+		value, ok := counterValue(update.GetVal())
+		if !ok {
+			continue
+		}
+		r := c.match(prefix, update.GetPath())
+		if r == nil {
+			continue
+		}
+		key := sampleKey(prefix, update.GetPath())
+		if samples == nil {
+			samples = make(map[string]sample)
+			c.samples[target] = samples
+		}
+		previous, exists := samples[key]
+		samples[key] = sample{value: value, timestamp: notification.GetTimestamp()}
+		if !exists || notification.GetTimestamp() <= previous.timestamp {
+			continue
+		}
+
+		delta := counterDelta(previous.value, value)
+		if r.deltaSuffix != "" {
+			derived = append(derived, &gnmipb.Update{
+				Path: derivedPath(update.GetPath(), r.deltaSuffix),
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: delta}},
+			})
+		}
+		if r.rateSuffix != "" {
+			seconds := float64(notification.GetTimestamp()-previous.timestamp) / 1e9
+			derived = append(derived, &gnmipb.Update{
+				Path: derivedPath(update.GetPath(), r.rateSuffix),
+				Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_DoubleVal{DoubleVal: float64(delta) / seconds}},
+			})
+		}
+	}
+	return derived
+}
+
+// Wrap returns an Export function that adds the derived updates to the
+// notifications passed to export.
+func (c *Calculator) Wrap(export func(leaf *ctree.Leaf)) func(leaf *ctree.Leaf) {
+	if c == nil {
+		return export
+	}
+	return func(leaf *ctree.Leaf) {
+		notification, ok := leaf.Value().(*gnmipb.Notification)
+		if !ok {
+			export(leaf)
+			return
+		}
+		derived := c.Derive(notification)
+		if len(derived) == 0 {
+			export(leaf)
+			return
+		}
+		// The cached notification is shared with the other cache clients so
+		// the updates are copied instead of appended to.
+		withDerived := utils.ShallowCopyNotification(notification)
+		withDerived.Update = append(notification.Update[:len(notification.Update):len(notification.Update)], derived...)
+		export(ctree.DetachedLeaf(withDerived))
+	}
+}
+
+func (c *Calculator) match(prefix *gnmipb.Path, path *gnmipb.Path) *rule {
+	var matched *rule
+	utils.MatchJoinedPath(prefix.GetElem(), path.GetElem(), func(elems []*gnmipb.PathElem) bool {
+		for i := range c.rules {
+			if utils.MatchPathPrefix(elems, c.rules[i].path) {
+				matched = &c.rules[i]
+				return true
+			}
+		}
+		return false
+	})
+	return matched
+}
+
+// counterDelta returns the increase from previous to current, accounting for
+// counter wraps and resets.
+func counterDelta(previous, current uint64) uint64 {
+	if current >= previous {
+		return current - previous
+	}
+	switch {
+	case previous <= math.MaxUint32 && previous > math.MaxUint32/2:
+		return math.MaxUint32 - previous + current + 1
+	case previous > math.MaxUint64/2:
+		return math.MaxUint64 - previous + current + 1
+	default:
+		return current
+	}
+}
+
+func counterValue(tv *gnmipb.TypedValue) (uint64, bool) {
+	switch v := tv.GetValue().(type) {
+	case *gnmipb.TypedValue_UintVal:
+		return v.UintVal, true
+	case *gnmipb.TypedValue_IntVal:
+		if v.IntVal >= 0 {
+			return uint64(v.IntVal), true
+		}
+	}
+	return 0, false
+}
+
+func derivedPath(path *gnmipb.Path, suffix string) *gnmipb.Path {
+	derived := proto.Clone(path).(*gnmipb.Path)
+	if len(derived.Elem) > 0 {
+		last := derived.Elem[len(derived.Elem)-1]
+		last.Name += suffix
+	}
+	return derived
+}
+
+func sampleKey(prefix *gnmipb.Path, path *gnmipb.Path) string {
+	return prefix.GetOrigin() + ":" +
+		utils.PathToXPath(&gnmipb.Path{Elem: prefix.GetElem()}) + utils.PathToXPath(&gnmipb.Path{Elem: path.GetElem()})
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rates
+
+import (
+	"math"
+	"testing"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func counter(timestamp int64, value uint64) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Timestamp: timestamp,
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{
+				{Name: "interfaces"},
+				{Name: "interface", Key: map[string]string{"name": "eth0"}},
+				{Name: "state"},
+				{Name: "counters"},
+				{Name: "in-octets"},
+			}},
+			Val: &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: value}},
+		}},
+	}
+}
+
+func TestCalculator_Derive(t *testing.T) {
+	assertion := assert.New(t)
+	calculator, err := New([]configuration.CounterRate{
+		{Path: "/interfaces/interface/state/counters", DeltaSuffix: "-delta", RateSuffix: "-rate"},
+		{Path: "/system", ExportersOnly: true},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion.Empty(calculator.Derive(counter(1e9, 1000)))
+	derived := calculator.Derive(counter(3e9, 3000))
+	if assertion.Len(derived, 2) {
+		assertion.Equal("in-octets-delta", derived[0].Path.Elem[4].Name)
+		assertion.Equal("eth0", derived[0].Path.Elem[1].Key["name"])
+		assertion.Equal(uint64(2000), derived[0].Val.GetUintVal())
+		assertion.Equal("in-octets-rate", derived[1].Path.Elem[4].Name)
+		assertion.Equal(float64(1000), derived[1].Val.GetDoubleVal())
+	}
+
+	// Samples with old timestamps are ignored.
+	assertion.Empty(calculator.Derive(counter(2e9, 4000)))
+}
+
+func TestCalculator_Derive_Delete(t *testing.T) {
+	calculator, err := New([]configuration.CounterRate{{Path: "/interfaces"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calculator.Derive(counter(1e9, 10))
+	assert.Len(t, calculator.samples["a"], 1)
+	calculator.Derive(&gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a"},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": "eth0"}},
+		}}},
+	})
+	assert.Empty(t, calculator.samples["a"])
+
+	calculator.Derive(counter(2e9, 20))
+	calculator.Derive(&gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "a"},
+		Delete: []*gnmipb.Path{{Elem: []*gnmipb.PathElem{{Name: "*"}}}},
+	})
+	assert.Empty(t, calculator.samples)
+}
+
+func TestCounterDelta(t *testing.T) {
+	assert.Equal(t, uint64(10), counterDelta(10, 20))
+	assert.Equal(t, uint64(20), counterDelta(math.MaxUint32-9, 10), "32-bit wrap")
+	assert.Equal(t, uint64(20), counterDelta(math.MaxUint64-9, 10), "64-bit wrap")
+	assert.Equal(t, uint64(5), counterDelta(1000, 5), "reset")
+}
+
+func TestNew(t *testing.T) {
+	calculator, err := New([]configuration.CounterRate{{Path: "/a", ExportersOnly: true}}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, calculator)
+
+	calculator, err = New([]configuration.CounterRate{{Path: "/a", ExportersOnly: true}}, true)
+	if assert.NoError(t, err) && assert.NotNil(t, calculator) {
+		assert.Equal(t, DefaultRateSuffix, calculator.rules[0].rateSuffix)
+	}
+}
+
+func TestCalculator_Wrap(t *testing.T) {
+	calculator, err := New([]configuration.CounterRate{{Path: "/interfaces"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []*gnmipb.Notification
+	export := calculator.Wrap(func(leaf *ctree.Leaf) {
+		exported = append(exported, leaf.Value().(*gnmipb.Notification))
+	})
+
+	first := counter(1e9, 10)
+	second := counter(2e9, 20)
+	export(ctree.DetachedLeaf(first))
+	export(ctree.DetachedLeaf(second))
+	if assert.Len(t, exported, 2) {
+		assert.Equal(t, first, exported[0])
+		assert.Len(t, exported[1].Update, 2)
+	}
+	// the cached notification is shared and must not be modified
+	assert.Len(t, second.Update, 1)
+}
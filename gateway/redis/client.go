@@ -0,0 +1,264 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis is a minimal Redis client for the commands used by the Redis
+// locking and clustering backends. It speaks RESP2 over a single connection
+// that is re-established after network errors.
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scripts used to modify a key only while it holds the expected value, so
+// that a lock is never renewed or released by a member that no longer holds
+// it.
+const (
+	// CompareAndExpireScript sets the TTL of KEYS[1] to ARGV[2] milliseconds
+	// if its value is ARGV[1]. Returns 1 if the TTL was set, otherwise 0.
+	CompareAndExpireScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	// CompareAndDeleteScript deletes KEYS[1] if its value is ARGV[1].
+	// Returns 1 if the key was deleted, otherwise 0.
+	CompareAndDeleteScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// Error is an error reply from the Redis server.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Options are the connection options of a Client.
+type Options struct {
+	// Address is the host:port of the Redis server.
+	Address string
+	// Password is sent with AUTH after connecting, if set.
+	Password string
+	// DB is the database selected after connecting.
+	DB int
+	// TLS enables TLS with the config, if set.
+	TLS *tls.Config
+	// Timeout is the dial and I/O timeout of each command.
+	Timeout time.Duration
+}
+
+// Client is a Redis client. Commands are sent one at a time over a single
+// connection. It's safe for concurrent use.
+type Client struct {
+	opts Options
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewClient creates a Client. The connection is made with the first command.
+func NewClient(opts Options) *Client {
+	return &Client{opts: opts}
+}
+
+// Do sends the command and returns the reply: a string for simple and bulk
+// strings, an int64 for integers, a []interface{} for arrays, and nil for
+// null replies. Error replies are returned as an Error.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.do(args)
+	if err != nil {
+		if _, isReply := err.(Error); !isReply {
+			// the connection is in an unknown state
+			c.closeConn()
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Ping checks the connection to the server.
+func (c *Client) Ping() error {
+	_, err := c.Do("PING")
+	return err
+}
+
+// Close closes the connection. The next command reconnects.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closeConn()
+}
+
+func (c *Client) connect() error {
+	dialer := &net.Dialer{Timeout: c.opts.Timeout}
+	var conn net.Conn
+	var err error
+	if c.opts.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.opts.Address, c.opts.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", c.opts.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to Redis at %s: %v", c.opts.Address, err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	if c.opts.Password != "" {
+		if _, err := c.do([]string{"AUTH", c.opts.Password}); err != nil {
+			c.closeConn()
+			return fmt.Errorf("unable to authenticate to Redis: %v", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := c.do([]string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			c.closeConn()
+			return fmt.Errorf("unable to select Redis database %d: %v", c.opts.DB, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+	return err
+}
+
+func (c *Client) do(args []string) (interface{}, error) {
+	if c.opts.Timeout > 0 {
+		if err := c.conn.SetDeadline(time.Now().Add(c.opts.Timeout)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := c.conn.Write(AppendCommand(nil, args...)); err != nil {
+		return nil, err
+	}
+	return ReadReply(c.reader)
+}
+
+// KeyFromPath returns the Redis key for a Zookeeper-style path: the path
+// elements joined with colons (e.g. /gnmi/gateway/target/a is
+// gnmi:gateway:target:a).
+func KeyFromPath(path string) string {
+	return strings.Replace(strings.Trim(path, "/"), "/", ":", -1)
+}
+
+// AppendCommand appends the command encoded as a RESP array of bulk strings
+// to buf.
+func AppendCommand(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// ReadReply reads a RESP reply. Error replies are returned as an Error.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk string length '%s'", line[1:])
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length '%s'", line[1:])
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		array := make([]interface{}, length)
+		for i := range array {
+			// error replies within an array are returned as elements
+			array[i], err = ReadReply(r)
+			if err != nil {
+				if replyErr, ok := err.(Error); ok {
+					array[i] = replyErr
+					continue
+				}
+				return nil, err
+			}
+		}
+		return array, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type '%c'", line[0])
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("invalid line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// Int returns an integer reply.
+func Int(reply interface{}) (int64, error) {
+	i, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply %v, expected an integer", reply)
+	}
+	return i, nil
+}
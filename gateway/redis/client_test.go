@@ -0,0 +1,111 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+	"github.com/openconfig/gnmi-gateway/gateway/redis/redistest"
+)
+
+func TestReadReply(t *testing.T) {
+	assertion := assert.New(t)
+
+	read := func(s string) (interface{}, error) {
+		return redis.ReadReply(bufio.NewReader(strings.NewReader(s)))
+	}
+
+	reply, err := read("+OK\r\n")
+	assertion.NoError(err)
+	assertion.Equal("OK", reply)
+
+	reply, err = read(":42\r\n")
+	assertion.NoError(err)
+	assertion.Equal(int64(42), reply)
+
+	reply, err = read("$5\r\nhello\r\n")
+	assertion.NoError(err)
+	assertion.Equal("hello", reply)
+
+	reply, err = read("$-1\r\n")
+	assertion.NoError(err)
+	assertion.Nil(reply)
+
+	reply, err = read("*3\r\n$1\r\na\r\n:1\r\n-ERR b\r\n")
+	assertion.NoError(err)
+	assertion.Equal([]interface{}{"a", int64(1), redis.Error("ERR b")}, reply)
+
+	_, err = read("-ERR wrong type\r\n")
+	assertion.Equal(redis.Error("ERR wrong type"), err)
+
+	_, err = read("?\r\n")
+	assertion.Error(err)
+	_, err = read("+OK\n")
+	assertion.Error(err)
+}
+
+func TestAppendCommand(t *testing.T) {
+	assert.Equal(t, "*2\r\n$3\r\nGET\r\n$1\r\na\r\n", string(redis.AppendCommand(nil, "GET", "a")))
+}
+
+func TestKeyFromPath(t *testing.T) {
+	assert.Equal(t, "gnmi:gateway:target:a", redis.KeyFromPath("/gnmi/gateway/target/a"))
+}
+
+func TestClient_Do(t *testing.T) {
+	assertion := assert.New(t)
+
+	server, err := redistest.NewServer()
+	if !assertion.NoError(err) {
+		return
+	}
+	defer server.Close()
+
+	client := redis.NewClient(redis.Options{Address: server.Address(), Password: "secret", DB: 1, Timeout: time.Second})
+	defer client.Close()
+	assertion.NoError(client.Ping())
+
+	reply, err := client.Do("SET", "a", "1", "NX", "PX", "1000")
+	assertion.NoError(err)
+	assertion.Equal("OK", reply)
+	reply, err = client.Do("SET", "a", "2", "NX", "PX", "1000")
+	assertion.NoError(err)
+	assertion.Nil(reply)
+	reply, err = client.Do("GET", "a")
+	assertion.NoError(err)
+	assertion.Equal("1", reply)
+
+	_, err = client.Do("NOPE")
+	_, isReply := err.(redis.Error)
+	assertion.True(isReply)
+
+	// the connection is re-established after network errors
+	assertion.NoError(client.Close())
+	reply, err = client.Do("GET", "a")
+	assertion.NoError(err)
+	assertion.Equal("1", reply)
+
+	server.FastForward(time.Second)
+	reply, err = client.Do("GET", "a")
+	assertion.NoError(err)
+	assertion.Nil(reply)
+}
@@ -0,0 +1,294 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redistest provides an in-memory Redis server for tests. It supports
+// the subset of commands used by the gateway's Redis backends.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/redis"
+)
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// Server is an in-memory Redis server listening on a local port.
+type Server struct {
+	listener net.Listener
+
+	mutex  sync.Mutex
+	data   map[string]entry
+	offset time.Duration
+}
+
+// NewServer starts a Server. Close it once the test is done.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener, data: make(map[string]entry)}
+	go s.serve()
+	return s, nil
+}
+
+// Address returns the host:port the server listens on.
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// FastForward moves the server clock forward so that keys expire sooner.
+func (s *Server) FastForward(d time.Duration) {
+	s.mutex.Lock()
+	s.offset += d
+	s.mutex.Unlock()
+}
+
+// Get returns the value of the key, if it exists.
+func (s *Server) Get(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, exists := s.lookup(key)
+	return e.value, exists
+}
+
+// Set sets the key without a TTL.
+func (s *Server) Set(key string, value string) {
+	s.mutex.Lock()
+	s.data[key] = entry{value: value}
+	s.mutex.Unlock()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := redis.ReadReply(reader)
+		if err != nil {
+			return
+		}
+		array, _ := reply.([]interface{})
+		args := make([]string, len(array))
+		for i := range array {
+			args[i], _ = array[i].(string)
+		}
+		if _, err := conn.Write(s.execute(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// lookup returns the entry of the key if it hasn't expired. It must be called
+// with mutex held.
+func (s *Server) lookup(key string) (entry, bool) {
+	e, exists := s.data[key]
+	if exists && !e.expires.IsZero() && !s.now().Before(e.expires) {
+		delete(s.data, key)
+		return entry{}, false
+	}
+	return e, exists
+}
+
+func (s *Server) execute(args []string) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(args) == 0 {
+		return errorReply("ERR empty command")
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return []byte("+PONG\r\n")
+	case "AUTH", "SELECT":
+		return []byte("+OK\r\n")
+	case "GET":
+		if len(args) != 2 {
+			return errorReply("ERR wrong number of arguments for 'get' command")
+		}
+		e, exists := s.lookup(args[1])
+		if !exists {
+			return []byte("$-1\r\n")
+		}
+		return bulkReply(e.value)
+	case "SET":
+		return s.set(args)
+	case "DEL":
+		var deleted int64
+		for _, key := range args[1:] {
+			if _, exists := s.lookup(key); exists {
+				delete(s.data, key)
+				deleted++
+			}
+		}
+		return intReply(deleted)
+	case "INCR":
+		if len(args) != 2 {
+			return errorReply("ERR wrong number of arguments for 'incr' command")
+		}
+		e, _ := s.lookup(args[1])
+		i, err := strconv.ParseInt("0"+e.value, 10, 64)
+		if err != nil {
+			return errorReply("ERR value is not an integer or out of range")
+		}
+		e.value = strconv.FormatInt(i+1, 10)
+		s.data[args[1]] = e
+		return intReply(i + 1)
+	case "EVAL":
+		return s.eval(args)
+	case "SCAN":
+		return s.scan(args)
+	default:
+		return errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+}
+
+func (s *Server) set(args []string) []byte {
+	if len(args) < 3 {
+		return errorReply("ERR wrong number of arguments for 'set' command")
+	}
+	e := entry{value: args[2]}
+	var nx bool
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "PX":
+			if i+1 >= len(args) {
+				return errorReply("ERR syntax error")
+			}
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || ms <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			e.expires = s.now().Add(time.Duration(ms) * time.Millisecond)
+			i++
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+	if _, exists := s.lookup(args[1]); exists && nx {
+		return []byte("$-1\r\n")
+	}
+	s.data[args[1]] = e
+	return []byte("+OK\r\n")
+}
+
+// eval runs the scripts in the redis package.
+func (s *Server) eval(args []string) []byte {
+	if len(args) < 4 || args[2] != "1" {
+		return errorReply("ERR wrong number of arguments for 'eval' command")
+	}
+	key, scriptArgs := args[3], args[4:]
+	switch args[1] {
+	case redis.CompareAndExpireScript:
+		if len(scriptArgs) != 2 {
+			return errorReply("ERR wrong number of arguments")
+		}
+		e, exists := s.lookup(key)
+		if !exists || e.value != scriptArgs[0] {
+			return intReply(0)
+		}
+		ms, err := strconv.ParseInt(scriptArgs[1], 10, 64)
+		if err != nil {
+			return errorReply("ERR value is not an integer or out of range")
+		}
+		e.expires = s.now().Add(time.Duration(ms) * time.Millisecond)
+		s.data[key] = e
+		return intReply(1)
+	case redis.CompareAndDeleteScript:
+		if len(scriptArgs) != 1 {
+			return errorReply("ERR wrong number of arguments")
+		}
+		e, exists := s.lookup(key)
+		if !exists || e.value != scriptArgs[0] {
+			return intReply(0)
+		}
+		delete(s.data, key)
+		return intReply(1)
+	default:
+		return errorReply("ERR unknown script")
+	}
+}
+
+// scan returns all of the matching keys in one batch.
+func (s *Server) scan(args []string) []byte {
+	pattern := "*"
+	for i := 2; i+1 < len(args); i += 2 {
+		if strings.ToUpper(args[i]) == "MATCH" {
+			pattern = args[i+1]
+		}
+	}
+	var keys []string
+	for key := range s.data {
+		if _, exists := s.lookup(key); !exists {
+			continue
+		}
+		if matched, _ := path.Match(pattern, key); matched {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	reply := []byte("*2\r\n")
+	reply = append(reply, bulkReply("0")...)
+	reply = append(reply, '*')
+	reply = strconv.AppendInt(reply, int64(len(keys)), 10)
+	reply = append(reply, '\r', '\n')
+	for _, key := range keys {
+		reply = append(reply, bulkReply(key)...)
+	}
+	return reply
+}
+
+func bulkReply(s string) []byte {
+	return []byte("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func intReply(i int64) []byte {
+	return []byte(":" + strconv.FormatInt(i, 10) + "\r\n")
+}
+
+func errorReply(msg string) []byte {
+	return []byte("-" + msg + "\r\n")
+}
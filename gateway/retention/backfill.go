@@ -0,0 +1,163 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Backfill adds notifications retained by another gateway, in the order they
+// were retained there, in front of the notifications retained for their
+// targets. Only the notifications older than the retained notifications of a
+// target are added, so that the updates received since the gateway started
+// aren't duplicated. It returns the number of notifications added.
+//
+// The time the notifications were received by the other gateway isn't known,
+// so they are aged by their timestamps, limited to the maximum age. Later
+// notifications for a backfilled target that aren't newer than its last
+// backfilled notification are ignored because they were already backfilled,
+// e.g. when they are replicated again in the initial sync from other cluster
+// members.
+func (b *Buffer) Backfill(notifications []*gnmi.Notification) int {
+	now := b.now()
+	oldest := now.Add(-b.maxAge).UnixNano()
+
+	var targets []string
+	byTarget := make(map[string][]*gnmi.Notification)
+	for _, notification := range notifications {
+		target := notification.GetPrefix().GetTarget()
+		if target == "" {
+			continue
+		}
+		if _, exists := byTarget[target]; !exists {
+			targets = append(targets, target)
+		}
+		byTarget[target] = append(byTarget[target], notification)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	added := 0
+	for _, target := range targets {
+		// The backfilled notifications must be older than the retained ones
+		// and be received before them.
+		before := int64(math.MaxInt64)
+		latest := now.UnixNano()
+		existing := b.targets[target]
+		if existing != nil && existing.count > 0 {
+			for i := 0; i < existing.count; i++ {
+				if timestamp := existing.at(i).notification.GetTimestamp(); timestamp < before {
+					before = timestamp
+				}
+			}
+			latest = existing.at(0).received
+		}
+
+		r := &ring{max: b.maxSize}
+		for _, notification := range byTarget[target] {
+			if notification.GetTimestamp() >= before {
+				continue
+			}
+			received := notification.GetTimestamp()
+			switch {
+			case received < oldest:
+				received = oldest
+			case received > latest:
+				received = latest
+			}
+			// The ring is ordered by the receive time.
+			if r.count > 0 && received < r.at(r.count-1).received {
+				received = r.at(r.count - 1).received
+			}
+			r.push(entry{notification: notification, received: received})
+			if notification.GetTimestamp() > b.backfilled[target] {
+				b.backfilled[target] = notification.GetTimestamp()
+			}
+			added++
+		}
+		if r.count == 0 {
+			continue
+		}
+		if existing != nil {
+			for i := 0; i < existing.count; i++ {
+				r.push(existing.at(i))
+			}
+		}
+		b.targets[target] = r
+	}
+	return added
+}
+
+// Fetch requests the notifications with timestamps between start and end
+// (inclusive) retained by the gateway at address for all targets, using a
+// Subscribe request with the gNMI History extension.
+func Fetch(ctx context.Context, address string, tlsConfig *tls.Config, start int64, end int64) ([]*gnmi.Notification, error) {
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %v", address, err)
+	}
+	defer conn.Close()
+
+	stream, err := gnmi.NewGNMIClient(conn).Subscribe(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to subscribe to %s: %v", address, err)
+	}
+	err = stream.Send(&gnmi.SubscribeRequest{
+		Request: &gnmi.SubscribeRequest_Subscribe{
+			Subscribe: &gnmi.SubscriptionList{
+				Prefix:       &gnmi.Path{Target: "*"},
+				Subscription: []*gnmi.Subscription{{Path: &gnmi.Path{}}},
+				Mode:         gnmi.SubscriptionList_ONCE,
+			},
+		},
+		Extension: []*gnmi_ext.Extension{{
+			Ext: &gnmi_ext.Extension_History{
+				History: &gnmi_ext.History{
+					Request: &gnmi_ext.History_Range{Range: &gnmi_ext.TimeRange{Start: start, End: end}},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to send the history request to %s: %v", address, err)
+	}
+
+	var notifications []*gnmi.Notification
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			return notifications, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to receive the history from %s: %v", address, err)
+		}
+		switch r := response.Response.(type) {
+		case *gnmi.SubscribeResponse_Update:
+			notifications = append(notifications, r.Update)
+		case *gnmi.SubscribeResponse_SyncResponse:
+			return notifications, nil
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention provides a short-term in-memory buffer of recent
+// notifications for each target.
+//
+// The buffer is fed from the gateway cache so it contains notifications for
+// every target known to the gateway, including targets that are connected to
+// other cluster members. Buffered notifications are served to gNMI clients
+// that send Subscribe requests with the gNMI History extension, which allows
+// new subscribers to receive recent history.
+//
+// A gateway that joins a cluster only receives the notifications of the
+// targets from then on, so it backfills its buffer with the notifications
+// retained by another cluster member (see Fetch and Buffer.Backfill). A target
+// that fails over to it then keeps its history.
+package retention
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// DefaultSize is the number of notifications retained per target if a size
+// isn't provided.
+const DefaultSize = 10000
+
+// Buffer retains recent notifications for each target. Notifications that were
+// received longer ago than the maximum age are evicted, as are the oldest
+// notifications once a target has more than the maximum number of
+// notifications. Notifications are aged by the time they were added rather
+// than by their timestamps so that targets with skewed clocks are retained
+// for the same amount of time as other targets. Buffer is safe for concurrent
+// use.
+type Buffer struct {
+	maxAge  time.Duration
+	maxSize int
+	now     func() time.Time
+
+	mutex   sync.RWMutex
+	targets map[string]*ring
+	// backfilled is the timestamp of the last backfilled notification of
+	// each backfilled target.
+	backfilled map[string]int64
+}
+
+// NewBuffer creates a Buffer that retains up to maxSize notifications per
+// target that are no older than maxAge.
+func NewBuffer(maxAge time.Duration, maxSize int) *Buffer {
+	if maxSize <= 0 {
+		maxSize = DefaultSize
+	}
+	return &Buffer{
+		maxAge:     maxAge,
+		maxSize:    maxSize,
+		now:        time.Now,
+		targets:    make(map[string]*ring),
+		backfilled: make(map[string]int64),
+	}
+}
+
+// Update adds the notification contained in the leaf to the buffer. Update is
+// used as a gateway cache client. The buffered notifications for a target are
+// dropped when the target is removed from the cache.
+func (b *Buffer) Update(leaf *ctree.Leaf) {
+	notification, ok := leaf.Value().(*gnmi.Notification)
+	if !ok || notification == nil {
+		return
+	}
+	if utils.IsTargetDelete(notification) {
+		b.RemoveTarget(notification.GetPrefix().GetTarget())
+		return
+	}
+	b.Add(notification)
+}
+
+// Add inserts a notification into the buffer for the target in the notification prefix.
+func (b *Buffer) Add(notification *gnmi.Notification) {
+	target := notification.GetPrefix().GetTarget()
+	if target == "" {
+		return
+	}
+	now := b.now()
+	cutoff := now.Add(-b.maxAge).UnixNano()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if last, backfilled := b.backfilled[target]; backfilled && notification.Timestamp <= last {
+		return
+	}
+	r, exists := b.targets[target]
+	if !exists {
+		r = &ring{max: b.maxSize}
+		b.targets[target] = r
+	}
+	r.push(entry{notification: notification, received: now.UnixNano()})
+	for r.count > 0 && r.at(0).received < cutoff {
+		r.pop()
+	}
+}
+
+// Len returns the number of notifications buffered for the target.
+func (b *Buffer) Len(target string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	r, exists := b.targets[target]
+	if !exists {
+		return 0
+	}
+	return r.count
+}
+
+// RemoveTarget drops all buffered notifications for the target.
+func (b *Buffer) RemoveTarget(target string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.targets, target)
+	delete(b.backfilled, target)
+}
+
+// History calls fn for each buffered notification from target with a timestamp
+// between start and end (inclusive). Notifications are provided in the order
+// they were received. If target is "*" notifications for all targets are
+// provided, grouped by target. History implements server.HistoryProvider.
+func (b *Buffer) History(target string, start int64, end int64, fn func(*gnmi.Notification) error) error {
+	cutoff := b.now().Add(-b.maxAge).UnixNano()
+
+	var matched []*gnmi.Notification
+	b.mutex.RLock()
+	var targets []string
+	if target == "*" {
+		for name := range b.targets {
+			targets = append(targets, name)
+		}
+		sort.Strings(targets)
+	} else {
+		targets = []string{target}
+	}
+	for _, name := range targets {
+		r, exists := b.targets[name]
+		if !exists {
+			continue
+		}
+		for i := 0; i < r.count; i++ {
+			e := r.at(i)
+			if e.received >= cutoff && e.notification.Timestamp >= start && e.notification.Timestamp <= end {
+				matched = append(matched, e.notification)
+			}
+		}
+	}
+	b.mutex.RUnlock()
+
+	for _, n := range matched {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entry is a buffered notification and the time it was added in nanoseconds.
+type entry struct {
+	notification *gnmi.Notification
+	received     int64
+}
+
+// ring is a FIFO of entries that grows up to max entries and then
+// overwrites the oldest entry.
+type ring struct {
+	items []entry
+	head  int
+	count int
+	max   int
+}
+
+func (r *ring) push(n entry) {
+	if r.count == len(r.items) {
+		if len(r.items) >= r.max {
+			// Full: overwrite the oldest.
+			r.items[r.head] = n
+			r.head = (r.head + 1) % len(r.items)
+			return
+		}
+		r.grow()
+	}
+	r.items[(r.head+r.count)%len(r.items)] = n
+	r.count++
+}
+
+func (r *ring) pop() {
+	r.items[r.head] = entry{}
+	r.head = (r.head + 1) % len(r.items)
+	r.count--
+}
+
+func (r *ring) at(i int) entry {
+	return r.items[(r.head+i)%len(r.items)]
+}
+
+// grow doubles the capacity of the ring (up to max) and moves the entries to
+// the start of the new slice.
+func (r *ring) grow() {
+	size := len(r.items) * 2
+	if size == 0 {
+		size = 16
+	}
+	if size > r.max {
+		size = r.max
+	}
+	items := make([]entry, size)
+	for i := 0; i < r.count; i++ {
+		items[i] = r.at(i)
+	}
+	r.items = items
+	r.head = 0
+}
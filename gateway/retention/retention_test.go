@@ -0,0 +1,120 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func notification(target string, timestamp int64) *gnmi.Notification {
+	return &gnmi.Notification{Timestamp: timestamp, Prefix: &gnmi.Path{Target: target}}
+}
+
+func timestamps(t *testing.T, b *Buffer, target string, start int64, end int64) []int64 {
+	var result []int64
+	err := b.History(target, start, end, func(n *gnmi.Notification) error {
+		result = append(result, n.Timestamp)
+		return nil
+	})
+	assert.NoError(t, err)
+	return result
+}
+
+func TestBuffer_MaxSize(t *testing.T) {
+	assertion := assert.New(t)
+	b := NewBuffer(time.Hour, 20)
+
+	now := time.Now().UnixNano()
+	for i := int64(0); i < 50; i++ {
+		b.Add(notification("a", now+i))
+	}
+	b.Add(notification("b", now))
+
+	assertion.Equal(20, b.Len("a"))
+	assertion.Equal(1, b.Len("b"))
+	result := timestamps(t, b, "a", 0, math.MaxInt64)
+	assertion.Len(result, 20)
+	assertion.Equal(now+30, result[0])
+	assertion.Equal(now+49, result[19])
+	assertion.Equal([]int64{now + 40, now + 41}, timestamps(t, b, "a", now+40, now+41))
+	assertion.Len(timestamps(t, b, "*", 0, math.MaxInt64), 21)
+}
+
+func TestBuffer_MaxAge(t *testing.T) {
+	assertion := assert.New(t)
+	b := NewBuffer(time.Minute, 0)
+
+	// Notifications are aged by the time they were added, so the skewed
+	// timestamp of the last notification doesn't matter.
+	now := time.Now()
+	for i, received := range []time.Time{now.Add(-2 * time.Minute), now.Add(-30 * time.Second), now} {
+		b.now = func() time.Time { return received }
+		b.Add(notification("a", now.Add(-time.Duration(i)*time.Hour).UnixNano()))
+	}
+
+	assertion.Equal(2, b.Len("a"))
+	assertion.Len(timestamps(t, b, "a", 0, math.MaxInt64), 2)
+
+	b.now = func() time.Time { return now.Add(45 * time.Second) }
+	assertion.Len(timestamps(t, b, "a", 0, math.MaxInt64), 1)
+}
+
+func TestBuffer_UpdateTargetDelete(t *testing.T) {
+	assertion := assert.New(t)
+	b := NewBuffer(time.Minute, 0)
+
+	b.Update(ctree.DetachedLeaf(notification("a", time.Now().UnixNano())))
+	assertion.Equal(1, b.Len("a"))
+
+	b.Update(ctree.DetachedLeaf(&gnmi.Notification{
+		Timestamp: time.Now().UnixNano(),
+		Prefix:    &gnmi.Path{Target: "a"},
+		Delete:    []*gnmi.Path{{Elem: []*gnmi.PathElem{{Name: "*"}}}},
+	}))
+	assertion.Equal(0, b.Len("a"))
+}
+
+func TestBuffer_Backfill(t *testing.T) {
+	assertion := assert.New(t)
+	b := NewBuffer(time.Minute, 0)
+
+	now := time.Now().UnixNano()
+	b.Add(notification("a", now))
+	added := b.Backfill([]*gnmi.Notification{
+		notification("a", now-2),
+		notification("a", now),
+		notification("b", now-2),
+		notification("b", now-1),
+		notification("", now),
+	})
+
+	// The notifications of targets that already have retained notifications
+	// are added before them unless they aren't older.
+	assertion.Equal(3, added)
+	assertion.Equal([]int64{now - 2, now}, timestamps(t, b, "a", 0, math.MaxInt64))
+	assertion.Equal([]int64{now - 2, now - 1}, timestamps(t, b, "b", 0, math.MaxInt64))
+
+	// Notifications that were already backfilled are ignored.
+	b.Add(notification("b", now-1))
+	b.Add(notification("b", now))
+	assertion.Equal([]int64{now - 2, now - 1, now}, timestamps(t, b, "b", 0, math.MaxInt64))
+}
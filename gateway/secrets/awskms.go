@@ -0,0 +1,136 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/aws"
+)
+
+// AWSKMS is a KeyProvider that wraps data keys with a symmetric AWS KMS key.
+// Credentials are found in the same order as the AWS SDKs' default
+// credential chain; see aws.DefaultCredentials.
+type AWSKMS struct {
+	keyID       string
+	region      string
+	endpoint    string
+	client      *http.Client
+	credentials aws.CredentialsProvider
+}
+
+// NewAWSKMS creates an AWSKMS provider. keyID is the ID, ARN, or alias of the
+// KMS key used to wrap data keys; it isn't needed to unwrap them. The region
+// defaults to AWS_REGION and the endpoint to the regional KMS endpoint.
+func NewAWSKMS(keyID string, region string, endpoint string) (*AWSKMS, error) {
+	region = aws.Region(region)
+	if region == "" {
+		return nil, errors.New("configuration option for the KMS region is not set and AWS_REGION is empty")
+	}
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com/"
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &AWSKMS{
+		keyID:       keyID,
+		region:      region,
+		endpoint:    endpoint,
+		client:      client,
+		credentials: aws.NewDefaultCredentials(client, region),
+	}, nil
+}
+
+// Name implements KeyProvider.
+func (k *AWSKMS) Name() string {
+	return "awskms"
+}
+
+type kmsEncryptRequest struct {
+	KeyID     string `json:"KeyId"`
+	Plaintext []byte
+}
+
+type kmsEncryptResponse struct {
+	CiphertextBlob []byte
+}
+
+type kmsDecryptRequest struct {
+	CiphertextBlob []byte
+}
+
+type kmsDecryptResponse struct {
+	Plaintext []byte
+}
+
+// WrapKey implements KeyProvider with the KMS Encrypt action.
+func (k *AWSKMS) WrapKey(key []byte) ([]byte, error) {
+	if k.keyID == "" {
+		return nil, errors.New("a KMS key ID is required to encrypt")
+	}
+	var response kmsEncryptResponse
+	if err := k.do("Encrypt", kmsEncryptRequest{KeyID: k.keyID, Plaintext: key}, &response); err != nil {
+		return nil, err
+	}
+	return response.CiphertextBlob, nil
+}
+
+// UnwrapKey implements KeyProvider with the KMS Decrypt action.
+func (k *AWSKMS) UnwrapKey(wrapped []byte) ([]byte, error) {
+	var response kmsDecryptResponse
+	if err := k.do("Decrypt", kmsDecryptRequest{CiphertextBlob: wrapped}, &response); err != nil {
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
+
+// do sends a KMS JSON API request.
+func (k *AWSKMS) do(action string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	creds, err := k.credentials.Retrieve()
+	if err != nil {
+		return fmt.Errorf("unable to get AWS credentials: %v", err)
+	}
+	aws.SignV4(req, body, creds, k.region, "kms", time.Now())
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("KMS %s failed: %s: %s", action, resp.Status, respBody)
+	}
+	return json.Unmarshal(respBody, response)
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// LocalKey is a KeyProvider that wraps data keys with an AES-256 key read
+// from a file. The file contains the base64-encoded key; create one with
+// GenerateLocalKey. The key file must be protected like any other credential.
+type LocalKey struct {
+	key []byte
+}
+
+// NewLocalKey reads the key in the file.
+func NewLocalKey(file string) (*LocalKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret key file: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key file %s: %v", file, err)
+	}
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid secret key file %s: the key must be %d bytes", file, dataKeySize)
+	}
+	return &LocalKey{key: key}, nil
+}
+
+// GenerateLocalKey writes a new random key to the file. The file must not
+// exist.
+func GenerateLocalKey(file string) error {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Name implements KeyProvider.
+func (l *LocalKey) Name() string {
+	return "local"
+}
+
+// WrapKey implements KeyProvider. The wrapped key is the nonce followed by
+// the AES-GCM ciphertext of the key.
+func (l *LocalKey) WrapKey(key []byte) ([]byte, error) {
+	nonce, ciphertext, err := seal(l.key, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (l *LocalKey) UnwrapKey(wrapped []byte) ([]byte, error) {
+	aead, err := newGCM(l.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	return open(l.key, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():])
+}
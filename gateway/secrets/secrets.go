@@ -0,0 +1,221 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets encrypts and decrypts credentials in target configurations
+// with envelope encryption: each value is encrypted with its own AES-256-GCM
+// data key and the data key is encrypted (wrapped) by a KeyProvider, such as
+// a local key file or AWS KMS. Encrypted values are kept encrypted in the
+// target configuration and only decrypted in memory when they're used.
+//
+// Encrypted values have the form "encrypted:<provider>:<envelope>", where the
+// envelope is the base64-encoded JSON of the wrapped data key, the nonce, and
+// the ciphertext.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// Prefix is the prefix of encrypted values.
+const Prefix = "encrypted:"
+
+// dataKeySize is the size of the AES-256 data keys.
+const dataKeySize = 32
+
+// KeyProvider wraps and unwraps the data keys of encrypted values.
+type KeyProvider interface {
+	// Name is the name of the provider in encrypted values.
+	Name() string
+	// WrapKey encrypts a data key.
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key encrypted with WrapKey.
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// envelope is an encrypted value.
+type envelope struct {
+	Key   []byte `json:"key"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// IsEncrypted returns true if the value is an encrypted value.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encrypt encrypts the plaintext with a new data key wrapped by provider.
+func Encrypt(provider KeyProvider, plaintext string) (string, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	nonce, data, err := seal(key, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := provider.WrapKey(key)
+	if err != nil {
+		return "", fmt.Errorf("unable to wrap data key with %s: %v", provider.Name(), err)
+	}
+	encoded, err := json.Marshal(envelope{Key: wrapped, Nonce: nonce, Data: data})
+	if err != nil {
+		return "", err
+	}
+	return Prefix + provider.Name() + ":" + base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// DecryptWith decrypts an encrypted value with the providers. Values that
+// aren't encrypted are returned unchanged.
+func DecryptWith(providers []KeyProvider, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, Prefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("invalid encrypted value: missing provider")
+	}
+	var provider KeyProvider
+	for _, p := range providers {
+		if p.Name() == parts[0] {
+			provider = p
+			break
+		}
+	}
+	if provider == nil {
+		return "", fmt.Errorf("unable to decrypt value: the %s key provider isn't configured", parts[0])
+	}
+	encoded, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %v", err)
+	}
+	var e envelope
+	if err := json.Unmarshal(encoded, &e); err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %v", err)
+	}
+	key, err := provider.UnwrapKey(e.Key)
+	if err != nil {
+		return "", fmt.Errorf("unable to unwrap data key with %s: %v", provider.Name(), err)
+	}
+	plaintext, err := open(key, e.Nonce, e.Data)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt value: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// seal encrypts data with AES-GCM and a random nonce.
+func seal(key []byte, data []byte) (nonce []byte, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, data, nil), nil
+}
+
+// open decrypts data encrypted with seal.
+func open(key []byte, nonce []byte, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Providers returns the key providers configured in the GatewayConfig: the
+// local key in SecretKeyFile and AWS KMS if SecretKMSKeyID or
+// SecretKMSRegion is set.
+func Providers(config *configuration.GatewayConfig) ([]KeyProvider, error) {
+	var providers []KeyProvider
+	if config.SecretKeyFile != "" {
+		local, err := NewLocalKey(config.SecretKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, local)
+	}
+	if config.SecretKMSKeyID != "" || config.SecretKMSRegion != "" {
+		kms, err := NewAWSKMS(config.SecretKMSKeyID, config.SecretKMSRegion, config.SecretKMSEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, kms)
+	}
+	return providers, nil
+}
+
+var global = struct {
+	sync.RWMutex
+	providers []KeyProvider
+}{}
+
+// Enable sets the key providers used by Decrypt from the configuration.
+func Enable(config *configuration.GatewayConfig) error {
+	providers, err := Providers(config)
+	if err != nil {
+		return err
+	}
+	global.Lock()
+	global.providers = providers
+	global.Unlock()
+	for _, provider := range providers {
+		config.Log.Info().Msgf("Encrypted credentials are decrypted with %s.", provider.Name())
+	}
+	return nil
+}
+
+// Disable removes the key providers set with Enable.
+func Disable() {
+	global.Lock()
+	global.providers = nil
+	global.Unlock()
+}
+
+// Decrypt decrypts an encrypted value with the key providers set with
+// Enable. Values that aren't encrypted are returned unchanged.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	global.RLock()
+	providers := global.providers
+	global.RUnlock()
+	return DecryptWith(providers, value)
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/aws"
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func newLocalKey(t *testing.T, dir string) *LocalKey {
+	file := filepath.Join(dir, "secret.key")
+	if err := GenerateLocalKey(file); err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewLocalKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncrypt_local(t *testing.T) {
+	assertion := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := newLocalKey(t, dir)
+	encrypted, err := Encrypt(key, "hunter2")
+	assertion.NoError(err)
+	assertion.True(strings.HasPrefix(encrypted, "encrypted:local:"))
+	assertion.NotContains(encrypted, "hunter2")
+
+	decrypted, err := DecryptWith([]KeyProvider{key}, encrypted)
+	assertion.NoError(err)
+	assertion.Equal("hunter2", decrypted)
+
+	// each value has its own data key
+	again, err := Encrypt(key, "hunter2")
+	assertion.NoError(err)
+	assertion.NotEqual(encrypted, again)
+
+	plain, err := DecryptWith(nil, "hunter2")
+	assertion.NoError(err)
+	assertion.Equal("hunter2", plain)
+
+	_, err = DecryptWith(nil, encrypted)
+	assertion.Error(err)
+
+	other, err := NewLocalKey(filepath.Join(dir, "missing.key"))
+	assertion.Error(err)
+	assertion.Nil(other)
+	assertion.Error(GenerateLocalKey(filepath.Join(dir, "secret.key")), "existing key files aren't overwritten")
+	otherDir := filepath.Join(dir, "other")
+	assertion.NoError(os.Mkdir(otherDir, 0700))
+	other = newLocalKey(t, otherDir)
+	_, err = DecryptWith([]KeyProvider{other}, encrypted)
+	assertion.Error(err)
+
+	_, err = DecryptWith([]KeyProvider{key}, "encrypted:local:not-base64!")
+	assertion.Error(err)
+}
+
+func TestDecrypt(t *testing.T) {
+	assertion := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := newLocalKey(t, dir)
+	encrypted, err := Encrypt(key, "hunter2")
+	assertion.NoError(err)
+
+	_, err = Decrypt(encrypted)
+	assertion.Error(err)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.SecretKeyFile = filepath.Join(dir, "secret.key")
+	assertion.NoError(Enable(config))
+	defer Disable()
+	decrypted, err := Decrypt(encrypted)
+	assertion.NoError(err)
+	assertion.Equal("hunter2", decrypted)
+}
+
+type staticCredentials aws.Credentials
+
+func (s *staticCredentials) Retrieve() (*aws.Credentials, error) {
+	creds := aws.Credentials(*s)
+	return &creds, nil
+}
+
+func TestAWSKMS(t *testing.T) {
+	assertion := assert.New(t)
+
+	// wraps keys by reversing them
+	reverse := func(b []byte) []byte {
+		r := make([]byte, len(b))
+		for i := range b {
+			r[len(b)-1-i] = b[i]
+		}
+		return r
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.True(strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			var req kmsEncryptRequest
+			assertion.NoError(json.NewDecoder(r.Body).Decode(&req))
+			assertion.Equal("alias/gnmi", req.KeyID)
+			_ = json.NewEncoder(w).Encode(kmsEncryptResponse{CiphertextBlob: reverse(req.Plaintext)})
+		case "TrentService.Decrypt":
+			var req kmsDecryptRequest
+			assertion.NoError(json.NewDecoder(r.Body).Decode(&req))
+			_ = json.NewEncoder(w).Encode(kmsDecryptResponse{Plaintext: reverse(req.CiphertextBlob)})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	kms, err := NewAWSKMS("alias/gnmi", "us-east-1", server.URL)
+	if !assertion.NoError(err) {
+		return
+	}
+	kms.credentials = &staticCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+
+	encrypted, err := Encrypt(kms, "hunter2")
+	assertion.NoError(err)
+	assertion.True(strings.HasPrefix(encrypted, "encrypted:awskms:"))
+	decrypted, err := DecryptWith([]KeyProvider{kms}, encrypted)
+	assertion.NoError(err)
+	assertion.Equal("hunter2", decrypted)
+
+	kms.keyID = ""
+	_, err = Encrypt(kms, "hunter2")
+	assertion.Error(err)
+}
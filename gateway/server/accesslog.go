@@ -0,0 +1,213 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Access log destinations other than a file path.
+const (
+	// AccessLogGateway writes the access log with the gateway logger.
+	AccessLogGateway = "log"
+	AccessLogStdout  = "stdout"
+	AccessLogStderr  = "stderr"
+)
+
+// AccessLog logs each RPC of the northbound gNMI server with the peer
+// address, the authenticated principal, the subscription, the duration, the
+// number of messages sent and received, and the reason the RPC ended. Each
+// entry is a JSON object.
+type AccessLog struct {
+	log    zerolog.Logger
+	closer io.Closer
+}
+
+// NewAccessLog creates an access log for the destination: AccessLogGateway,
+// AccessLogStdout, AccessLogStderr, or the path of a file that entries are
+// appended to. gatewayLog is used for AccessLogGateway.
+func NewAccessLog(destination string, gatewayLog zerolog.Logger) (*AccessLog, error) {
+	switch destination {
+	case "":
+		return nil, fmt.Errorf("no access log destination")
+	case AccessLogGateway:
+		return &AccessLog{log: gatewayLog.With().Str("log", "access").Logger()}, nil
+	case AccessLogStdout:
+		return newAccessLogWriter(os.Stdout, nil), nil
+	case AccessLogStderr:
+		return newAccessLogWriter(os.Stderr, nil), nil
+	}
+	file, err := os.OpenFile(filepath.Clean(destination), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open access log: %v", err)
+	}
+	return newAccessLogWriter(file, file), nil
+}
+
+// newAccessLogWriter creates an access log that writes to w. closer is
+// closed by Close, if set.
+func newAccessLogWriter(w io.Writer, closer io.Closer) *AccessLog {
+	return &AccessLog{
+		log:    zerolog.New(w).With().Timestamp().Logger(),
+		closer: closer,
+	}
+}
+
+// Close closes the access log file, if any.
+func (a *AccessLog) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// StreamInterceptor returns a gRPC stream interceptor that logs each
+// streaming RPC when it ends.
+func (a *AccessLog) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		logged := &accessLogStream{ServerStream: stream}
+		err := handler(srv, logged)
+		a.write(stream.Context(), info.FullMethod, start, err, logged)
+		return err
+	}
+}
+
+// UnaryInterceptor returns a gRPC unary interceptor that logs each unary RPC.
+func (a *AccessLog) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logged := &accessLogStream{received: 1}
+		if err == nil {
+			logged.sent = 1
+		}
+		a.write(ctx, info.FullMethod, start, err, logged)
+		return resp, err
+	}
+}
+
+// write logs an RPC that ended with err.
+func (a *AccessLog) write(ctx context.Context, method string, start time.Time, err error, stream *accessLogStream) {
+	event := a.log.Info().
+		Str("rpc", method).
+		Str("peer", peerAddress(ctx)).
+		Str("principal", principal(ctx)).
+		Dur("duration", time.Since(start)).
+		Uint64("sent", atomic.LoadUint64(&stream.sent)).
+		Uint64("received", atomic.LoadUint64(&stream.received))
+	if sub, ok := stream.subscribe.Load().(accessLogSubscription); ok {
+		event = event.Str("target", sub.target).Str("mode", sub.mode).Strs("paths", sub.paths)
+	}
+	event.Str("code", status.Code(err).String()).
+		Str("reason", terminationReason(ctx, err)).
+		Msg("rpc")
+}
+
+// terminationReason describes why an RPC ended.
+func terminationReason(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case status.Code(err) == codes.Canceled || ctx.Err() == context.Canceled:
+		return "canceled by client"
+	case ctx.Err() == context.DeadlineExceeded:
+		return "deadline exceeded"
+	default:
+		return status.Convert(err).Message()
+	}
+}
+
+// peerAddress returns the remote address of the RPC's peer.
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+// principal returns the authenticated identity of the RPC's peer: the common
+// name of the verified TLS client certificate or the username sent in the
+// RPC metadata.
+func principal(ctx context.Context) string {
+	if name, err := clientCommonName(ctx); err == nil {
+		return name
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if username := md.Get("username"); len(username) > 0 {
+			return username[0]
+		}
+	}
+	return ""
+}
+
+// accessLogStream counts the messages of a streaming RPC and records the
+// first SubscribeRequest received.
+type accessLogStream struct {
+	grpc.ServerStream
+
+	sent     uint64
+	received uint64
+	// subscribe is the accessLogSubscription of the first SubscribeRequest.
+	subscribe atomic.Value
+}
+
+// accessLogSubscription is the subscription of a Subscribe RPC as received,
+// before the server rewrites target patterns.
+type accessLogSubscription struct {
+	target string
+	mode   string
+	paths  []string
+}
+
+func (s *accessLogStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddUint64(&s.sent, 1)
+	}
+	return err
+}
+
+func (s *accessLogStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddUint64(&s.received, 1)
+		if req, ok := m.(*pb.SubscribeRequest); ok && req.GetSubscribe() != nil && s.subscribe.Load() == nil {
+			list := req.GetSubscribe()
+			s.subscribe.Store(accessLogSubscription{
+				target: list.GetPrefix().GetTarget(),
+				mode:   list.GetMode().String(),
+				paths:  subscriptionPathStrings(list),
+			})
+		}
+	}
+	return err
+}
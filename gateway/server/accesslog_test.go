@@ -0,0 +1,167 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// accessLogTestStream is a grpc.ServerStream that receives reqs and discards
+// sent messages.
+type accessLogTestStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	reqs []*pb.SubscribeRequest
+}
+
+func (s *accessLogTestStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *accessLogTestStream) SendMsg(interface{}) error {
+	return nil
+}
+
+func (s *accessLogTestStream) RecvMsg(m interface{}) error {
+	if len(s.reqs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.reqs[0])
+	s.reqs = s.reqs[1:]
+	return nil
+}
+
+// accessLogEntry decodes the single entry written to buf.
+func accessLogEntry(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid access log entry %q: %v", buf.String(), err)
+	}
+	buf.Reset()
+	return entry
+}
+
+func TestAccessLog_StreamInterceptor(t *testing.T) {
+	assertion := assert.New(t)
+	buf := new(bytes.Buffer)
+	accessLog := newAccessLogWriter(buf, nil)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &fakeNet{}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("username", "alice"))
+	stream := &accessLogTestStream{ctx: ctx, reqs: []*pb.SubscribeRequest{{
+		Request: &pb.SubscribeRequest_Subscribe{Subscribe: &pb.SubscriptionList{
+			Prefix: &pb.Path{Target: "router*", Elem: []*pb.PathElem{{Name: "interfaces"}}},
+			Subscription: []*pb.Subscription{
+				{Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interface"}, {Name: "state"}}}},
+			},
+			Mode: pb.SubscriptionList_ONCE,
+		}},
+	}}}
+	info := &grpc.StreamServerInfo{FullMethod: "/gnmi.gNMI/Subscribe"}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		req := new(pb.SubscribeRequest)
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		// The server rewrites target patterns; the access log keeps the
+		// target as requested.
+		req.GetSubscribe().Prefix.Target = "*"
+		for i := 0; i < 3; i++ {
+			if err := stream.SendMsg(&pb.SubscribeResponse{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := accessLog.StreamInterceptor()(nil, stream, info, handler)
+	assertion.NoError(err)
+	entry := accessLogEntry(t, buf)
+	assertion.Equal("/gnmi.gNMI/Subscribe", entry["rpc"])
+	assertion.Equal("127.0.0.1", entry["peer"])
+	assertion.Equal("alice", entry["principal"])
+	assertion.Equal("router*", entry["target"])
+	assertion.Equal("ONCE", entry["mode"])
+	assertion.Equal([]interface{}{"/interfaces/interface/state"}, entry["paths"])
+	assertion.Equal(float64(3), entry["sent"])
+	assertion.Equal(float64(1), entry["received"])
+	assertion.Equal("OK", entry["code"])
+	assertion.Equal("completed", entry["reason"])
+	assertion.Contains(entry, "duration")
+
+	denied := func(interface{}, grpc.ServerStream) error {
+		return status.Error(codes.PermissionDenied, "not authorized for target \"router1\"")
+	}
+	err = accessLog.StreamInterceptor()(nil, &accessLogTestStream{ctx: ctx}, info, denied)
+	assertion.Error(err)
+	entry = accessLogEntry(t, buf)
+	assertion.Equal("PermissionDenied", entry["code"])
+	assertion.Equal("not authorized for target \"router1\"", entry["reason"])
+	assertion.NotContains(entry, "target")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = accessLog.StreamInterceptor()(nil, &accessLogTestStream{ctx: canceled}, info, func(interface{}, grpc.ServerStream) error {
+		return canceled.Err()
+	})
+	assertion.Error(err)
+	assertion.Equal("canceled by client", accessLogEntry(t, buf)["reason"])
+}
+
+func TestAccessLog_UnaryInterceptor(t *testing.T) {
+	assertion := assert.New(t)
+	buf := new(bytes.Buffer)
+	accessLog := newAccessLogWriter(buf, nil)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &fakeNet{}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/gnmi.gNMI/Capabilities"}
+	_, err := accessLog.UnaryInterceptor()(ctx, &pb.CapabilityRequest{}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unimplemented, "method Capabilities not implemented")
+	})
+	assertion.Error(err)
+	entry := accessLogEntry(t, buf)
+	assertion.Equal("/gnmi.gNMI/Capabilities", entry["rpc"])
+	assertion.Equal("", entry["principal"])
+	assertion.Equal("Unimplemented", entry["code"])
+	assertion.Equal(float64(0), entry["sent"])
+	assertion.Equal(float64(1), entry["received"])
+}
+
+func TestNewAccessLog(t *testing.T) {
+	assertion := assert.New(t)
+
+	_, err := NewAccessLog("", zerolog.Nop())
+	assertion.Error(err)
+	accessLog, err := NewAccessLog(AccessLogGateway, zerolog.Nop())
+	assertion.NoError(err)
+	assertion.NoError(accessLog.Close())
+	_, err = NewAccessLog("/nonexistent/access.log", zerolog.Nop())
+	assertion.Error(err)
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Netflix/spectator-go"
+	"github.com/openconfig/gnmi/path"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+// ClientInfo describes a connected Subscribe client.
+type ClientInfo struct {
+	// ID uniquely identifies the Subscribe RPC for the lifetime of the server.
+	// With tenants the IDs are only unique within the clients of a tenant.
+	ID uint64 `json:"id"`
+	// Tenant is the tenant of the client, if any.
+	Tenant string `json:"tenant,omitempty"`
+	// Peer is the remote address of the client.
+	Peer string `json:"peer"`
+	// ClusterMember is true if the client is another gateway cluster member.
+	ClusterMember bool `json:"cluster_member"`
+	// Target is the target in the subscription prefix. It can be a list of
+	// targets or a glob pattern.
+	Target string `json:"target"`
+	// Mode is the subscription mode (STREAM, ONCE, or POLL).
+	Mode string `json:"mode"`
+	// Paths are the subscribed paths including the prefix path.
+	Paths []string `json:"paths"`
+	// Connected is the time the subscription started.
+	Connected time.Time `json:"connected"`
+	// QueueDepth is the number of items waiting to be sent to the client.
+	QueueDepth int `json:"queue_depth"`
+	// Dropped is the number of updates dropped because the client's queue
+	// was full.
+	Dropped uint64 `json:"dropped"`
+	// Sent is the number of responses sent to the client.
+	Sent uint64 `json:"sent"`
+	// SentBytes is the total encoded size of the responses sent to the client.
+	SentBytes uint64 `json:"sent_bytes"`
+	// SentPerSecond is the average rate of responses sent since the
+	// subscription started.
+	SentPerSecond float64 `json:"sent_per_second"`
+	// LastSent is the time the last response was sent to the client.
+	LastSent time.Time `json:"last_sent,omitempty"`
+}
+
+// clientStats are the counters for a streamClient. The fields are updated
+// atomically.
+type clientStats struct {
+	sent      uint64
+	sentBytes uint64
+	lastSent  int64
+
+	counterSent      *spectator.Counter
+	counterSentBytes *spectator.Counter
+}
+
+// sent records a response of size bytes sent to the client.
+func (c *streamClient) sent(size int) {
+	if c.stats == nil {
+		return
+	}
+	atomic.AddUint64(&c.stats.sent, 1)
+	atomic.AddUint64(&c.stats.sentBytes, uint64(size))
+	atomic.StoreInt64(&c.stats.lastSent, time.Now().UnixNano())
+	c.stats.counterSent.Increment()
+	c.stats.counterSentBytes.Add(int64(size))
+}
+
+// registerClient adds the client to the list of connected clients and returns
+// a function that removes it.
+func (s *Server) registerClient(c *streamClient) (remove func()) {
+	tags := map[string]string{"gnmigateway.server.subscribe.peer": c.peer}
+	c.stats = &clientStats{
+		counterSent:      stats.Registry.Counter("gnmigateway.server.subscribe.sent", tags),
+		counterSentBytes: stats.Registry.Counter("gnmigateway.server.subscribe.sent_bytes", tags),
+	}
+	c.connected = time.Now()
+	s.clientsMutex.Lock()
+	s.nextClientID++
+	c.id = s.nextClientID
+	s.clients[c.id] = c
+	s.clientsMutex.Unlock()
+	return func() {
+		s.clientsMutex.Lock()
+		delete(s.clients, c.id)
+		s.clientsMutex.Unlock()
+	}
+}
+
+// Clients returns information about the currently connected Subscribe
+// clients ordered by ID.
+func (s *Server) Clients() []ClientInfo {
+	s.clientsMutex.Lock()
+	clients := make([]*streamClient, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.clientsMutex.Unlock()
+
+	now := time.Now()
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, c := range clients {
+		info := ClientInfo{
+			ID:            c.id,
+			Peer:          c.peer,
+			ClusterMember: c.clusterMember,
+			Target:        c.target,
+			Mode:          c.sr.GetSubscribe().GetMode().String(),
+			Paths:         clientPaths(c),
+			Connected:     c.connected,
+			Sent:          atomic.LoadUint64(&c.stats.sent),
+			SentBytes:     atomic.LoadUint64(&c.stats.sentBytes),
+		}
+		if c.pattern != nil {
+			info.Target = c.pattern.String()
+		}
+		if c.queue != nil {
+			info.QueueDepth = c.queue.Len()
+			info.Dropped = c.queue.Dropped()
+		}
+		if lastSent := atomic.LoadInt64(&c.stats.lastSent); lastSent > 0 {
+			info.LastSent = time.Unix(0, lastSent)
+		}
+		if elapsed := now.Sub(c.connected).Seconds(); elapsed > 0 {
+			info.SentPerSecond = float64(info.Sent) / elapsed
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// clientPaths returns the subscribed paths of the client as strings.
+func clientPaths(c *streamClient) []string {
+	return subscriptionPathStrings(c.sr.GetSubscribe())
+}
+
+// subscriptionPathStrings returns the paths of the subscription list,
+// including the prefix path, as strings.
+func subscriptionPathStrings(list *pb.SubscriptionList) []string {
+	prefix := path.ToStrings(list.GetPrefix(), false)
+	var paths []string
+	for _, sub := range list.GetSubscription() {
+		elems := append(append([]string{}, prefix...), path.ToStrings(sub.GetPath(), false)...)
+		paths = append(paths, "/"+strings.Join(elems, "/"))
+	}
+	return paths
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestServer_Clients(t *testing.T) {
+	assertion := assert.New(t)
+	s, err := NewServer(&GNMIServerOpts{Config: configuration.NewDefaultGatewayConfig()})
+	assertion.NoError(err)
+
+	c := &streamClient{
+		peer:   "127.0.0.1:1234",
+		target: "dev1",
+		queue:  newClientQueue(0, ""),
+		sr: &pb.SubscribeRequest{Request: &pb.SubscribeRequest_Subscribe{Subscribe: &pb.SubscriptionList{
+			Prefix: &pb.Path{Target: "dev1", Elem: []*pb.PathElem{{Name: "interfaces"}}},
+			Subscription: []*pb.Subscription{
+				{Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interface"}, {Name: "state"}}}},
+			},
+		}}},
+	}
+	remove := s.registerClient(c)
+	_, _ = c.queue.Insert(syncMarker{})
+	c.sent(10)
+	c.sent(20)
+
+	clients := s.Clients()
+	assertion.Len(clients, 1)
+	assertion.Equal("127.0.0.1:1234", clients[0].Peer)
+	assertion.Equal("dev1", clients[0].Target)
+	assertion.Equal("STREAM", clients[0].Mode)
+	assertion.Equal([]string{"/interfaces/interface/state"}, clients[0].Paths)
+	assertion.Equal(1, clients[0].QueueDepth)
+	assertion.Equal(uint64(2), clients[0].Sent)
+	assertion.Equal(uint64(30), clients[0].SentBytes)
+
+	remove()
+	assertion.Empty(s.Clients())
+}
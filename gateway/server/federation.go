@@ -0,0 +1,83 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+// federationClient is another gateway subscribed with a gateway target. The
+// origin of each target is sent to the gateway ahead of the target's first
+// notification, and the targets whose origin contains the gateway aren't
+// sent to it at all so that data doesn't loop between federated gateways.
+type federationClient struct {
+	// id is the FederationID of the subscribed gateway and self is the
+	// FederationID of this gateway.
+	id   string
+	self string
+	// targets is true for the targets whose origin was sent and false for
+	// the targets that aren't sent to the gateway.
+	targets map[string]bool
+}
+
+// newFederationClient returns the federationClient for a Subscribe RPC from
+// a gateway target, or nil if the client isn't a gateway.
+func newFederationClient(ctx context.Context, self string) *federationClient {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	ids := md.Get(connections.FederationMetadataKey)
+	if len(ids) == 0 || ids[0] == "" {
+		return nil
+	}
+	return &federationClient{id: ids[0], self: self, targets: make(map[string]bool)}
+}
+
+// check returns false if the notification must not be sent to the gateway
+// because the gateway is in the origin of its target. The origin notification
+// that must be sent first is returned for the first notification of each
+// target. origin returns the gateways the data of a target passed through
+// before reaching this gateway.
+func (f *federationClient) check(n *pb.Notification, origin func(target string) []string) (*pb.Notification, bool) {
+	target := n.GetPrefix().GetTarget()
+	if target == "" || target == "*" {
+		return nil, true
+	}
+	if send, exists := f.targets[target]; exists {
+		return nil, send
+	}
+	chain := append(origin(target), f.self)
+	for _, id := range chain {
+		if id == f.id {
+			f.targets[target] = false
+			return nil, false
+		}
+	}
+	f.targets[target] = true
+	return connections.OriginNotification(target, chain), true
+}
+
+// forget makes check look up the origin of the target again, e.g. after the
+// target was deleted.
+func (f *federationClient) forget(target string) {
+	delete(f.targets, target)
+}
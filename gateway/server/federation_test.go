@@ -0,0 +1,75 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+func TestNewFederationClient(t *testing.T) {
+	assert.Nil(t, newFederationClient(context.Background(), "region1"))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(connections.FederationMetadataKey, "global"))
+	f := newFederationClient(ctx, "region1")
+	if assert.NotNil(t, f) {
+		assert.Equal(t, "global", f.id)
+		assert.Equal(t, "region1", f.self)
+	}
+}
+
+func TestFederationClient_check(t *testing.T) {
+	assertion := assert.New(t)
+
+	f := &federationClient{id: "global", self: "region1", targets: make(map[string]bool)}
+	origins := map[string][]string{"router2": {"region2", "global"}}
+	lookups := 0
+	origin := func(target string) []string {
+		lookups++
+		return origins[target]
+	}
+	notification := func(target string) *pb.Notification {
+		return &pb.Notification{Prefix: &pb.Path{Target: target}}
+	}
+
+	// local targets are sent with this gateway as their origin
+	first, send := f.check(notification("router1"), origin)
+	assertion.True(send)
+	if assertion.NotNil(first) {
+		assertion.Equal("router1", first.GetPrefix().GetTarget())
+		assertion.Equal("region1", first.GetUpdate()[0].GetVal().GetStringVal())
+	}
+	first, send = f.check(notification("router1"), origin)
+	assertion.True(send)
+	assertion.Nil(first)
+
+	// router2 was received from the subscribed gateway
+	_, send = f.check(notification("router2"), origin)
+	assertion.False(send)
+	_, send = f.check(notification("router2"), origin)
+	assertion.False(send)
+	assertion.Equal(2, lookups)
+
+	f.forget("router1")
+	first, _ = f.check(notification("router1"), origin)
+	assertion.NotNil(first)
+}
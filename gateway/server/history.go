@@ -0,0 +1,257 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/path"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+)
+
+// HistoryProvider serves historical notifications for Subscribe requests that
+// include the gNMI History extension.
+type HistoryProvider interface {
+	// History calls fn in timestamp order for each notification received from
+	// target with a timestamp between start and end (inclusive, in nanoseconds).
+	// If fn returns an error History stops and returns the error.
+	History(target string, start int64, end int64, fn func(*pb.Notification) error) error
+}
+
+// historyRequest is a parsed gNMI History extension.
+type historyRequest struct {
+	// snapshot is set if the request is for the state of the tree at a single
+	// point in time.
+	snapshot bool
+	start    int64
+	end      int64
+}
+
+// parseExtensions returns the history request contained in the extensions, if
+// any. An Unimplemented error is returned for any other extension.
+//
+// The History extension is located by field name rather than the generated
+// type so that the gateway works with any version of the gnmi_ext package.
+func parseExtensions(extensions []*gnmi_ext.Extension) (*historyRequest, error) {
+	var history *historyRequest
+	for _, ext := range extensions {
+		m := proto.MessageReflect(ext)
+		var name protoreflect.Name
+		var value protoreflect.Value
+		m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			name = fd.Name()
+			value = v
+			return false
+		})
+		if name != "history" {
+			return nil, status.Errorf(codes.Unimplemented, "unsupported extension %q", name)
+		}
+
+		history = new(historyRequest)
+		h := value.Message()
+		fields := h.Descriptor().Fields()
+		if fd := fields.ByName("snapshot_time"); fd != nil && h.Has(fd) {
+			history.snapshot = true
+			history.start = math.MinInt64
+			history.end = h.Get(fd).Int()
+		} else if fd := fields.ByName("range"); fd != nil && h.Has(fd) {
+			r := h.Get(fd).Message()
+			rangeFields := r.Descriptor().Fields()
+			if start := rangeFields.ByName("start"); start != nil {
+				history.start = r.Get(start).Int()
+			}
+			if end := rangeFields.ByName("end"); end != nil {
+				history.end = r.Get(end).Int()
+			}
+			if history.end < history.start {
+				return nil, status.Errorf(codes.InvalidArgument, "history range end %d is before start %d", history.end, history.start)
+			}
+		} else {
+			return nil, status.Errorf(codes.InvalidArgument, "history extension must contain a snapshot_time or range")
+		}
+	}
+	return history, nil
+}
+
+// processHistory sends historical notifications matching the subscription to
+// the client followed by a sync response. A snapshot request only sends the
+// latest value of each path at the snapshot time; paths that were deleted
+// before the snapshot time are omitted. A range request replays both updates
+// and deletes.
+func (s *Server) processHistory(c *streamClient, history *historyRequest) error {
+	if s.history == nil {
+		return status.Errorf(codes.Unimplemented, "history extension is not supported: no retention buffer is configured")
+	}
+
+	subscriptions := subscriptionPaths(c.sr.GetSubscribe())
+	send := func(n *pb.Notification) error {
+		return c.stream.Send(&pb.SubscribeResponse{Response: &pb.SubscribeResponse_Update{Update: n}})
+	}
+
+	snapshot := make(map[string]*pb.Notification)
+	var order []string
+	err := s.history.History(c.target, history.start, history.end, func(n *pb.Notification) error {
+		if !c.acl.Check(n.GetPrefix().GetTarget()) {
+			return nil
+		}
+		for _, d := range n.GetDelete() {
+			p := notificationPath(n.GetPrefix(), d)
+			if !overlapsAny(p, subscriptions) {
+				continue
+			}
+			if !history.snapshot {
+				err := send(&pb.Notification{
+					Timestamp: n.GetTimestamp(),
+					Prefix:    n.GetPrefix(),
+					Delete:    []*pb.Path{d},
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			deleted := pathKey(p)
+			for key := range snapshot {
+				if key == deleted || strings.HasPrefix(key, deleted+"/") {
+					delete(snapshot, key)
+				}
+			}
+		}
+		for _, u := range n.GetUpdate() {
+			p := notificationPath(n.GetPrefix(), u.GetPath())
+			if !matchesAny(p, subscriptions) {
+				continue
+			}
+			single := &pb.Notification{
+				Timestamp: n.GetTimestamp(),
+				Prefix:    n.GetPrefix(),
+				Update:    []*pb.Update{u},
+			}
+			if !history.snapshot {
+				if err := send(single); err != nil {
+					return err
+				}
+				continue
+			}
+			key := pathKey(p)
+			if _, exists := snapshot[key]; !exists {
+				order = append(order, key)
+			}
+			snapshot[key] = single
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sent := make(map[string]bool)
+	for _, key := range order {
+		n, exists := snapshot[key]
+		if !exists || sent[key] {
+			continue
+		}
+		sent[key] = true
+		if err := send(n); err != nil {
+			return err
+		}
+	}
+	return c.stream.Send(subscribeSync)
+}
+
+// subscriptionPaths returns the full path strings (including the target) for
+// each subscription in the list.
+func subscriptionPaths(list *pb.SubscriptionList) [][]string {
+	var paths [][]string
+	prefix := path.ToStrings(list.GetPrefix(), true)
+	for _, sub := range list.GetSubscription() {
+		p := append(append([]string{}, prefix...), path.ToStrings(sub.GetPath(), false)...)
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// notificationPath returns the full path strings (including the target) for
+// an update in a notification.
+func notificationPath(prefix *pb.Path, p *pb.Path) []string {
+	return append(path.ToStrings(prefix, true), path.ToStrings(p, false)...)
+}
+
+// matchesAny returns true if p is matched by any of the queries. Query
+// elements of "*" match any single element and "..." matches any number of
+// elements. Queries match all paths below them.
+func matchesAny(p []string, queries [][]string) bool {
+	for _, query := range queries {
+		if matchQuery(p, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsAny returns true if p is matched by any of the queries or if p is
+// an ancestor of a path matched by any of the queries. It is used to find
+// deletes that affect subscribed paths.
+func overlapsAny(p []string, queries [][]string) bool {
+	for _, query := range queries {
+		if overlapsQuery(p, query) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlapsQuery(p []string, query []string) bool {
+	for i, q := range query {
+		if q == "..." || i >= len(p) {
+			return true
+		}
+		if q != "*" && q != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func matchQuery(p []string, query []string) bool {
+	for i, q := range query {
+		if q == "..." {
+			return true
+		}
+		if i >= len(p) {
+			return false
+		}
+		if q != "*" && q != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func pathKey(p []string) string {
+	var key string
+	for _, e := range p {
+		key += "/" + e
+	}
+	return key
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseExtensions(t *testing.T) {
+	assertion := assert.New(t)
+
+	history, err := parseExtensions(nil)
+	assertion.NoError(err)
+	assertion.Nil(history)
+
+	_, err = parseExtensions([]*gnmi_ext.Extension{
+		{Ext: &gnmi_ext.Extension_MasterArbitration{MasterArbitration: &gnmi_ext.MasterArbitration{}}},
+	})
+	assertion.Equal(codes.Unimplemented, status.Code(err))
+}
+
+func TestMatchQuery(t *testing.T) {
+	assertion := assert.New(t)
+
+	p := []string{"dev1", "interfaces", "interface", "eth0", "state"}
+	assertion.True(matchQuery(p, []string{"dev1"}))
+	assertion.True(matchQuery(p, []string{"dev1", "interfaces", "interface", "*", "state"}))
+	assertion.True(matchQuery(p, []string{"*", "..."}))
+	assertion.False(matchQuery(p, []string{"dev2"}))
+	assertion.False(matchQuery(p, []string{"dev1", "interfaces", "interface", "eth0", "state", "counters"}))
+}
+
+func TestOverlapsQuery(t *testing.T) {
+	assertion := assert.New(t)
+
+	query := []string{"dev1", "interfaces", "interface", "*", "state"}
+	assertion.True(overlapsQuery([]string{"dev1", "interfaces", "interface", "eth0", "state", "mtu"}, query))
+	assertion.True(overlapsQuery([]string{"dev1", "interfaces", "interface", "eth0"}, query))
+	assertion.True(overlapsQuery([]string{"dev1"}, query))
+	assertion.False(overlapsQuery([]string{"dev1", "system"}, query))
+}
+
+type historyBuffer []*pb.Notification
+
+func (h historyBuffer) History(_ string, start int64, end int64, fn func(*pb.Notification) error) error {
+	for _, n := range h {
+		if n.Timestamp >= start && n.Timestamp <= end {
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestServer_processHistoryDeletes(t *testing.T) {
+	prefix := &pb.Path{Target: "dev1"}
+	leaf := func(name string) *pb.Path {
+		return &pb.Path{Elem: []*pb.PathElem{{Name: "system"}, {Name: name}}}
+	}
+	update := func(ts int64, name string) *pb.Notification {
+		return &pb.Notification{Timestamp: ts, Prefix: prefix, Update: []*pb.Update{
+			{Path: leaf(name), Val: &pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: ts}}},
+		}}
+	}
+	s := &Server{history: historyBuffer{
+		update(1, "a"),
+		update(2, "b"),
+		{Timestamp: 3, Prefix: prefix, Delete: []*pb.Path{leaf("a")}},
+	}}
+
+	for _, tt := range []struct {
+		name    string
+		history *historyRequest
+		want    []string
+	}{
+		{"range", &historyRequest{start: 0, end: 10}, []string{"update a", "update b", "delete a"}},
+		{"snapshot after delete", &historyRequest{snapshot: true, start: math.MinInt64, end: 10}, []string{"update b"}},
+		{"snapshot before delete", &historyRequest{snapshot: true, start: math.MinInt64, end: 2}, []string{"update a", "update b"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stream := &fakeSubServer{ctx: ctx, rsp: make(chan *pb.SubscribeResponse, 10)}
+			c := &streamClient{
+				acl:    &aclStub{},
+				target: "dev1",
+				sr: &pb.SubscribeRequest{Request: &pb.SubscribeRequest_Subscribe{Subscribe: &pb.SubscriptionList{
+					Prefix:       prefix,
+					Subscription: []*pb.Subscription{{Path: &pb.Path{Elem: []*pb.PathElem{{Name: "system"}}}}},
+				}}},
+				stream: stream,
+			}
+			assert.NoError(t, s.processHistory(c, tt.history))
+			close(stream.rsp)
+
+			var got []string
+			for rsp := range stream.rsp {
+				n := rsp.GetUpdate()
+				if n == nil {
+					continue
+				}
+				for _, u := range n.Update {
+					got = append(got, "update "+u.Path.Elem[1].Name)
+				}
+				for _, d := range n.Delete {
+					got = append(got, "delete "+d.Elem[1].Name)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Messages sent to idle Subscribe clients. See ServerIdleHeartbeatMessage in
+// GatewayConfig.
+const (
+	// IdleHeartbeatNotification sends a notification without updates for the
+	// subscribed target.
+	IdleHeartbeatNotification = "notification"
+	// IdleHeartbeatSync sends a sync response.
+	IdleHeartbeatSync = "sync"
+)
+
+// ValidateIdleHeartbeatMessage returns an error if the message is not a known
+// idle heartbeat message. An empty message is equivalent to
+// IdleHeartbeatNotification.
+func ValidateIdleHeartbeatMessage(message string) error {
+	switch message {
+	case "", IdleHeartbeatNotification, IdleHeartbeatSync:
+		return nil
+	}
+	return fmt.Errorf("unknown idle heartbeat message '%s'", message)
+}
+
+type idleHeartbeatKey struct{}
+
+// idleHeartbeat is the idle heartbeat configuration of a listener.
+type idleHeartbeat struct {
+	interval time.Duration
+	message  string
+}
+
+// idleHeartbeatMarker is queued for a client that hasn't been sent anything
+// for the idle heartbeat interval.
+type idleHeartbeatMarker struct{}
+
+// IdleHeartbeatInterceptor returns a stream interceptor that makes STREAM mode
+// Subscribe RPCs send a heartbeat message to the client once the stream has
+// been idle for interval, so that load balancers and proxies between the
+// client and the gateway don't close quiet streams. The interceptor is added
+// to the gRPC server of each listener with a heartbeat interval.
+func IdleHeartbeatInterceptor(interval time.Duration, message string) grpc.StreamServerInterceptor {
+	heartbeat := idleHeartbeat{interval: interval, message: message}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := context.WithValue(ss.Context(), idleHeartbeatKey{}, heartbeat)
+		return handler(srv, &idleHeartbeatStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// idleHeartbeatStream overrides the context of the wrapped stream.
+type idleHeartbeatStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *idleHeartbeatStream) Context() context.Context {
+	return s.ctx
+}
+
+// idleHeartbeatResponse returns the response sent to an idle client.
+func idleHeartbeatResponse(c *streamClient, message string) *pb.SubscribeResponse {
+	if message == IdleHeartbeatSync {
+		return subscribeSync
+	}
+	target := c.target
+	if c.pattern != nil {
+		target = c.pattern.String()
+	}
+	return &pb.SubscribeResponse{
+		Response: &pb.SubscribeResponse_Update{
+			Update: &pb.Notification{
+				Timestamp: time.Now().UnixNano(),
+				Prefix:    &pb.Path{Target: target},
+			},
+		},
+	}
+}
+
+// sendIdleHeartbeats queues an idle heartbeat marker whenever nothing has
+// been sent to the client for the heartbeat interval, until the stream ends.
+func (s *Server) sendIdleHeartbeats(c *streamClient, heartbeat idleHeartbeat) {
+	defer s.recoverClient(c)
+	timer := time.NewTimer(heartbeat.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-c.stream.Context().Done():
+			return
+		case <-timer.C:
+		}
+		if c.queue.IsClosed() {
+			return
+		}
+		last := c.connected
+		if lastSent := atomic.LoadInt64(&c.stats.lastSent); lastSent > 0 {
+			last = time.Unix(0, lastSent)
+		}
+		idle := time.Since(last)
+		if idle < heartbeat.interval {
+			timer.Reset(heartbeat.interval - idle)
+			continue
+		}
+		if err := c.queue.Offer(idleHeartbeatMarker{}); err != nil {
+			return
+		}
+		timer.Reset(heartbeat.interval)
+	}
+}
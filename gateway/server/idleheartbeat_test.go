@@ -0,0 +1,85 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestValidateIdleHeartbeatMessage(t *testing.T) {
+	assert.NoError(t, ValidateIdleHeartbeatMessage(""))
+	assert.NoError(t, ValidateIdleHeartbeatMessage(IdleHeartbeatSync))
+	assert.Error(t, ValidateIdleHeartbeatMessage("ping"))
+}
+
+func TestIdleHeartbeatInterceptor(t *testing.T) {
+	assertion := assert.New(t)
+
+	s := &fakeSubServer{ctx: context.Background()}
+	interceptor := IdleHeartbeatInterceptor(time.Minute, IdleHeartbeatSync)
+	err := interceptor(nil, s, &grpc.StreamServerInfo{}, func(_ interface{}, stream grpc.ServerStream) error {
+		heartbeat, ok := stream.Context().Value(idleHeartbeatKey{}).(idleHeartbeat)
+		assertion.True(ok)
+		assertion.Equal(idleHeartbeat{interval: time.Minute, message: IdleHeartbeatSync}, heartbeat)
+		return nil
+	})
+	assertion.NoError(err)
+}
+
+func TestGNMIIdleHeartbeat(t *testing.T) {
+	p, _ := newStreamOptionsServer(t)
+	for _, message := range []string{IdleHeartbeatNotification, IdleHeartbeatSync} {
+		ctx, stop := context.WithCancel(peer.NewContext(context.Background(), &peer.Peer{Addr: &fakeNet{}}))
+		s := &fakeSubServer{
+			ctx: context.WithValue(ctx, idleHeartbeatKey{}, idleHeartbeat{interval: 20 * time.Millisecond, message: message}),
+			req: make(chan *pb.SubscribeRequest, 1),
+			rsp: make(chan *pb.SubscribeResponse, 10),
+		}
+		s.req <- &pb.SubscribeRequest{
+			Request: &pb.SubscribeRequest_Subscribe{
+				Subscribe: &pb.SubscriptionList{
+					Prefix:       &pb.Path{Target: "dev1"},
+					Subscription: []*pb.Subscription{{Path: &pb.Path{Element: []string{"a"}}}},
+					Mode:         pb.SubscriptionList_STREAM,
+				},
+			},
+		}
+		go p.Subscribe(s)
+		if !nextResponse(t, s).GetSyncResponse() {
+			t.Errorf("%s: first response is not a sync response", message)
+		}
+		// The subscribed target doesn't have any updates, so only heartbeats
+		// are sent.
+		for i := 0; i < 2; i++ {
+			r := nextResponse(t, s)
+			switch message {
+			case IdleHeartbeatSync:
+				assert.True(t, r.GetSyncResponse())
+			default:
+				assert.Equal(t, "dev1", r.GetUpdate().GetPrefix().GetTarget())
+				assert.Empty(t, r.GetUpdate().GetUpdate())
+			}
+		}
+		stop()
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+// PolicyInput is the input of a policy decision: the client, the target, and
+// the subscribed paths of a Subscribe RPC.
+type PolicyInput struct {
+	// Principal is the common name of the client's TLS certificate or the
+	// username sent in the RPC metadata.
+	Principal string `json:"principal"`
+	// Peer is the remote address of the client.
+	Peer string `json:"peer"`
+	// Target is the target the decision is for.
+	Target string `json:"target"`
+	// Paths are the subscribed paths including the prefix path, if known.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// PolicyDecider is a policy decision point, e.g. an Open Policy Agent.
+type PolicyDecider interface {
+	// Allow returns true if the policy allows the request described by input.
+	Allow(ctx context.Context, input *PolicyInput) (bool, error)
+}
+
+// PathRPCACL is an RPCACL that also authorizes the subscribed paths.
+// newPolicyDecider is set by the policy package when it's included in the
+// binary.
+var newPolicyDecider func(config *configuration.GatewayConfig) (PolicyDecider, error)
+
+// RegisterPolicyDecider sets the function that creates the decision point for
+// the PolicyURL and PolicyRegoFile configuration.
+func RegisterPolicyDecider(new func(config *configuration.GatewayConfig) (PolicyDecider, error)) {
+	newPolicyDecider = new
+}
+
+// ValidatePolicyDecider returns an error if PolicyURL or PolicyRegoFile is
+// set but no decision point is registered.
+func ValidatePolicyDecider(config *configuration.GatewayConfig) error {
+	if (config.PolicyURL != "" || config.PolicyRegoFile != "") && newPolicyDecider == nil {
+		return errors.New("the policy package isn't included in this build")
+	}
+	return nil
+}
+
+// NewPolicyDecider returns the decision point set in the configuration or nil
+// if neither PolicyURL nor PolicyRegoFile is set.
+func NewPolicyDecider(config *configuration.GatewayConfig) (PolicyDecider, error) {
+	if err := ValidatePolicyDecider(config); err != nil {
+		return nil, err
+	}
+	if config.PolicyURL == "" && config.PolicyRegoFile == "" {
+		return nil, nil
+	}
+	return newPolicyDecider(config)
+}
+
+type PathRPCACL interface {
+	RPCACL
+	// CheckPaths returns true if the client may subscribe to paths of target.
+	CheckPaths(target string, paths []string) bool
+}
+
+// pathsACL checks the subscribed paths of an RPC with a PathRPCACL each time
+// a target is checked.
+type pathsACL struct {
+	acl   PathRPCACL
+	paths []string
+}
+
+// Check implements RPCACL.
+func (a *pathsACL) Check(target string) bool {
+	return a.acl.CheckPaths(target, a.paths)
+}
+
+// PolicyACL is an ACL that authorizes gNMI clients with a PolicyDecider so
+// that target and path authorization policies can be managed outside of the
+// gateway. Decisions are made once per target for each RPC; policy changes
+// apply to RPCs started after the change. Requests are denied if the decider
+// fails and the decision is retried the next time the target is checked.
+type PolicyACL struct {
+	decider PolicyDecider
+	timeout time.Duration
+	log     zerolog.Logger
+}
+
+// NewPolicyACL returns a PolicyACL that waits up to timeout for each decision
+// of decider. Decision errors are logged to log.
+func NewPolicyACL(decider PolicyDecider, timeout time.Duration, log zerolog.Logger) *PolicyACL {
+	return &PolicyACL{decider: decider, timeout: timeout, log: log}
+}
+
+// NewRPCACL implements ACL.
+func (a *PolicyACL) NewRPCACL(ctx context.Context) (RPCACL, error) {
+	return &policyRPCACL{
+		acl:       a,
+		principal: principal(ctx),
+		peer:      peerAddress(ctx),
+		decisions: make(map[string]bool),
+	}, nil
+}
+
+// Check implements ACL.
+func (a *PolicyACL) Check(client string, target string) bool {
+	allowed, _ := a.allow(&PolicyInput{Principal: client, Target: target})
+	return allowed
+}
+
+// allow returns the decision for input. Errors deny the request.
+func (a *PolicyACL) allow(input *PolicyInput) (bool, error) {
+	ctx := context.Background()
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+	allowed, err := a.decider.Allow(ctx, input)
+	if err != nil {
+		a.log.Error().Msgf("Policy decision for client %q target %q failed: %v", input.Principal, input.Target, err)
+		stats.Registry.Counter("gnmigateway.server.policy.decisions", map[string]string{"result": "error"}).Increment()
+		return false, err
+	}
+	result := "deny"
+	if allowed {
+		result = "allow"
+	}
+	stats.Registry.Counter("gnmigateway.server.policy.decisions", map[string]string{"result": result}).Increment()
+	return allowed, nil
+}
+
+// policyRPCACL caches the decisions of a PolicyACL for the targets of an RPC.
+type policyRPCACL struct {
+	acl       *PolicyACL
+	principal string
+	peer      string
+
+	mutex     sync.Mutex
+	decisions map[string]bool
+}
+
+var _ PathRPCACL = &policyRPCACL{}
+
+// Check implements RPCACL.
+func (a *policyRPCACL) Check(target string) bool {
+	return a.CheckPaths(target, nil)
+}
+
+// CheckPaths implements PathRPCACL. The paths of an RPC don't change so the
+// decisions are cached by target.
+func (a *policyRPCACL) CheckPaths(target string, paths []string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if allowed, exists := a.decisions[target]; exists {
+		return allowed
+	}
+	allowed, err := a.acl.allow(&PolicyInput{
+		Principal: a.principal,
+		Peer:      a.peer,
+		Target:    target,
+		Paths:     paths,
+	})
+	if err == nil {
+		a.decisions[target] = allowed
+	}
+	return allowed
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// fakeDecider allows the targets in allowed and records the inputs.
+type fakeDecider struct {
+	allowed map[string]bool
+	err     error
+	inputs  []PolicyInput
+}
+
+func (d *fakeDecider) Allow(_ context.Context, input *PolicyInput) (bool, error) {
+	d.inputs = append(d.inputs, *input)
+	return d.allowed[input.Target], d.err
+}
+
+func TestPolicyACL(t *testing.T) {
+	assertion := assert.New(t)
+	decider := &fakeDecider{allowed: map[string]bool{"router1": true}}
+	acl := NewPolicyACL(decider, 0, zerolog.Nop())
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &fakeNet{}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("username", "alice"))
+	rpcACL, err := acl.NewRPCACL(ctx)
+	assertion.NoError(err)
+	pathACL, ok := rpcACL.(PathRPCACL)
+	assertion.True(ok)
+
+	paths := []string{"/interfaces/interface/state"}
+	assertion.True(pathACL.CheckPaths("router1", paths))
+	assertion.False(pathACL.CheckPaths("router2", paths))
+	assertion.Equal([]PolicyInput{
+		{Principal: "alice", Peer: "127.0.0.1", Target: "router1", Paths: paths},
+		{Principal: "alice", Peer: "127.0.0.1", Target: "router2", Paths: paths},
+	}, decider.inputs)
+
+	// Decisions are cached for the RPC.
+	wrapped := &pathsACL{acl: pathACL, paths: paths}
+	assertion.True(wrapped.Check("router1"))
+	assertion.Len(decider.inputs, 2)
+
+	assertion.True(acl.Check("alice", "router1"))
+	assertion.False(acl.Check("alice", "router2"))
+}
+
+func TestPolicyACL_error(t *testing.T) {
+	assertion := assert.New(t)
+	decider := &fakeDecider{allowed: map[string]bool{"router1": true}, err: errors.New("unreachable")}
+	acl := NewPolicyACL(decider, 0, zerolog.Nop())
+
+	rpcACL, err := acl.NewRPCACL(context.Background())
+	assertion.NoError(err)
+	assertion.False(rpcACL.Check("router1"))
+
+	// Failed decisions aren't cached.
+	decider.err = nil
+	assertion.True(rpcACL.Check("router1"))
+	assertion.Len(decider.inputs, 2)
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"sync"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// pathPool holds the buffers of the paths that notifications are matched
+// against in Update.
+var pathPool = sync.Pool{
+	New: func() interface{} {
+		p := make([]string, 0, 32)
+		return &p
+	},
+}
+
+// appendPathStrings appends the strings of p as returned by path.ToStrings to
+// dst. The key values of elems with a single key are appended without
+// allocating.
+func appendPathStrings(dst []string, p *pb.Path, prefix bool) []string {
+	if p == nil {
+		return dst
+	}
+	if prefix {
+		if t := p.GetTarget(); t != "" {
+			dst = append(dst, t)
+		}
+		if o := p.GetOrigin(); o != "" {
+			dst = append(dst, o)
+		}
+	}
+	if len(p.GetElem()) == 0 {
+		return append(dst, p.GetElement()...)
+	}
+	for _, e := range p.GetElem() {
+		dst = append(dst, e.GetName())
+		switch len(e.GetKey()) {
+		case 0:
+		case 1:
+			for _, v := range e.GetKey() {
+				dst = append(dst, v)
+			}
+		default:
+			keys := make([]string, 0, len(e.GetKey()))
+			for k := range e.GetKey() {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				dst = append(dst, e.GetKey()[k])
+			}
+		}
+	}
+	return dst
+}
+
+// withDuplicates returns a copy of notification with the duplicate count of
+// the first update set to dup. Only the notification and the first update
+// are copied; the paths and values are shared with notification.
+func withDuplicates(notification *pb.Notification, dup uint32) *pb.Notification {
+	copied := &pb.Notification{
+		Timestamp: notification.Timestamp,
+		Prefix:    notification.Prefix,
+		Update:    make([]*pb.Update, len(notification.Update)),
+		Delete:    notification.Delete,
+		Atomic:    notification.Atomic,
+	}
+	copy(copied.Update, notification.Update)
+	first := notification.Update[0]
+	copied.Update[0] = &pb.Update{
+		Path:       first.Path,
+		Value:      first.Value,
+		Val:        first.Val,
+		Duplicates: dup,
+	}
+	return copied
+}
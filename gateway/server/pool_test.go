@@ -0,0 +1,115 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/match"
+	"github.com/openconfig/gnmi/path"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestAppendPathStrings(t *testing.T) {
+	paths := []*pb.Path{
+		nil,
+		{},
+		{Target: "router1"},
+		{Target: "router1", Element: []string{"interfaces", "interface"}},
+		{Target: "router1", Origin: "openconfig", Elem: []*pb.PathElem{{Name: "system"}}},
+		{Elem: []*pb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}}},
+		{Target: "router1", Elem: []*pb.PathElem{
+			{Name: "network-instances"},
+			{Name: "network-instance", Key: map[string]string{"name": "default"}},
+			{Name: "protocol", Key: map[string]string{"identifier": "BGP", "name": "bgp"}},
+		}},
+	}
+	for _, p := range paths {
+		for _, prefix := range []bool{true, false} {
+			assert.Equal(t, path.ToStrings(p, prefix), appendPathStrings([]string{}, p, prefix), "%v %v", p, prefix)
+		}
+	}
+	assert.Equal(t, []string{"router1", "interfaces"}, appendPathStrings([]string{"router1"}, &pb.Path{Elem: []*pb.PathElem{{Name: "interfaces"}}}, false))
+}
+
+func TestWithDuplicates(t *testing.T) {
+	assertion := assert.New(t)
+	notification := &pb.Notification{
+		Timestamp: 1,
+		Prefix:    &pb.Path{Target: "router1"},
+		Update: []*pb.Update{
+			{Path: &pb.Path{Elem: []*pb.PathElem{{Name: "a"}}}, Val: &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1}}},
+			{Path: &pb.Path{Elem: []*pb.PathElem{{Name: "b"}}}},
+		},
+	}
+
+	copied := withDuplicates(notification, 3)
+	assertion.Equal(uint32(3), copied.Update[0].Duplicates)
+	assertion.Equal(uint32(0), notification.Update[0].Duplicates)
+	assertion.Equal(notification.Timestamp, copied.Timestamp)
+	assertion.Same(notification.Prefix, copied.Prefix)
+	assertion.Same(notification.Update[0].Val, copied.Update[0].Val)
+	assertion.Same(notification.Update[1], copied.Update[1])
+}
+
+func BenchmarkMakeSubscribeResponse_duplicates(b *testing.B) {
+	notification := &pb.Notification{
+		Timestamp: 1,
+		Prefix:    &pb.Path{Target: "router1"},
+		Update: []*pb.Update{{
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "eth0"}}}},
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1}},
+		}},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MakeSubscribeResponse(notification, 2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// nopClient is a match.Client that discards updates.
+type nopClient struct{}
+
+func (nopClient) Update(interface{}) {}
+
+func BenchmarkServer_Update(b *testing.B) {
+	s := &Server{m: match.New(), config: &configuration.GatewayConfig{}}
+	s.m.AddQuery([]string{"router1", "interfaces"}, nopClient{})
+	leaf := ctree.DetachedLeaf(&pb.Notification{
+		Prefix: &pb.Path{Target: "router1", Elem: []*pb.PathElem{{Name: "interfaces"}}},
+		Update: []*pb.Update{{
+			Path: &pb.Path{Elem: []*pb.PathElem{
+				{Name: "interface", Key: map[string]string{"name": "eth0"}},
+				{Name: "state"},
+				{Name: "counters"},
+				{Name: "in-octets"},
+			}},
+			Val: &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1}},
+		}},
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Update(leaf)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openconfig/gnmi/coalesce"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Policies for clients that can't keep up with streaming updates. See
+// ServerSlowConsumerPolicy in GatewayConfig.
+const (
+	// SlowConsumerCoalesce keeps coalescing updates for leaves that are
+	// already queued and drops updates for new leaves while the queue is full.
+	SlowConsumerCoalesce = "coalesce"
+	// SlowConsumerDropOldest drops the oldest queued update to make room for
+	// the new update.
+	SlowConsumerDropOldest = "drop-oldest"
+	// SlowConsumerDisconnect closes the Subscribe RPC with a ResourceExhausted
+	// error.
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// ValidateSlowConsumerPolicy returns an error if the policy is not a known policy.
+// An empty policy is equivalent to SlowConsumerCoalesce.
+func ValidateSlowConsumerPolicy(policy string) error {
+	switch policy {
+	case "", SlowConsumerCoalesce, SlowConsumerDropOldest, SlowConsumerDisconnect:
+		return nil
+	}
+	return fmt.Errorf("unknown slow consumer policy '%s'", policy)
+}
+
+// clientQueue is the outbound queue for a Subscribe client. Streaming updates
+// are added with Offer which enforces the queue limit; Insert is unbounded and
+// is used for the initial sync so that a large tree doesn't trip the limit.
+type clientQueue struct {
+	*coalesce.Queue
+	limit  int
+	policy string
+
+	mutex sync.Mutex
+	// queued tracks the items in the queue so that updates for already
+	// queued leaves can be coalesced when the queue is full. It is only
+	// maintained if limit is set.
+	queued  map[interface{}]struct{}
+	dropped uint64
+	err     error
+	// ready receives a value when an item is inserted or the queue is closed
+	// so that Next can wait for items without holding the mutex.
+	ready chan struct{}
+}
+
+// cancelledContext makes coalesce.Queue.Next return immediately if the queue
+// is empty instead of waiting for an item.
+var cancelledContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// newClientQueue creates a queue that holds at most limit streaming updates.
+// A limit of zero disables the limit.
+func newClientQueue(limit int, policy string) *clientQueue {
+	q := &clientQueue{
+		Queue:  coalesce.NewQueue(),
+		limit:  limit,
+		policy: policy,
+	}
+	if limit > 0 {
+		q.queued = make(map[interface{}]struct{})
+		q.ready = make(chan struct{}, 1)
+	}
+	return q
+}
+
+// Insert adds an item to the queue regardless of the limit.
+func (q *clientQueue) Insert(i interface{}) (bool, error) {
+	if q.queued == nil {
+		return q.Queue.Insert(i)
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.insert(i)
+}
+
+func (q *clientQueue) insert(i interface{}) (bool, error) {
+	inserted, err := q.Queue.Insert(i)
+	if inserted {
+		q.queued[i] = struct{}{}
+		q.notify()
+	}
+	return inserted, err
+}
+
+// notify wakes up Next if it's waiting for an item.
+func (q *clientQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Offer adds a streaming update to the queue applying the slow consumer policy
+// if the queue is full.
+func (q *clientQueue) Offer(i interface{}) error {
+	if q.queued == nil {
+		_, err := q.Queue.Insert(i)
+		return err
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, exists := q.queued[i]; exists || q.Queue.Len() < q.limit {
+		_, err := q.insert(i)
+		return err
+	}
+
+	switch q.policy {
+	case SlowConsumerDropOldest:
+		oldest, _, err := q.Queue.Next(cancelledContext)
+		if marker, ok := oldest.(syncMarker); err == nil && ok {
+			// The sync response is never dropped; it's queued again after
+			// the updates that are already queued and the next oldest update
+			// is dropped instead.
+			delete(q.queued, marker)
+			oldest, _, err = q.Queue.Next(cancelledContext)
+			if _, err := q.insert(marker); err != nil {
+				return err
+			}
+		}
+		if err == nil {
+			delete(q.queued, oldest)
+			atomic.AddUint64(&q.dropped, 1)
+		}
+		_, err = q.insert(i)
+		return err
+	case SlowConsumerDisconnect:
+		q.err = status.Errorf(codes.ResourceExhausted, "client exceeded the send queue limit of %d updates", q.limit)
+		q.Queue.Close()
+		q.notify()
+		return q.err
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+		return nil
+	}
+}
+
+// Close closes the queue for inserts. See coalesce.Queue.Close.
+func (q *clientQueue) Close() {
+	q.Queue.Close()
+	if q.queued != nil {
+		q.notify()
+	}
+}
+
+// Next returns the next item in the queue. See coalesce.Queue.Next. The item
+// is removed from the queue and from queued under the mutex so that Offer
+// doesn't coalesce updates into an item that was already taken.
+func (q *clientQueue) Next(ctx context.Context) (interface{}, uint32, error) {
+	if q.queued == nil {
+		return q.Queue.Next(ctx)
+	}
+	for {
+		q.mutex.Lock()
+		// Next doesn't wait on a closed queue: it returns the remaining items
+		// and then an error.
+		next := cancelledContext
+		if q.Queue.IsClosed() {
+			next = context.Background()
+		}
+		i, dup, err := q.Queue.Next(next)
+		if err == nil {
+			delete(q.queued, i)
+		}
+		q.mutex.Unlock()
+		if err != context.Canceled {
+			return i, dup, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
+
+// Dropped returns the number of updates dropped because the queue was full.
+func (q *clientQueue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Err returns the error that caused the queue to be closed by the slow
+// consumer policy, if any.
+func (q *clientQueue) Err() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.err
+}
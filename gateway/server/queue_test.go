@@ -0,0 +1,150 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientQueue_Coalesce(t *testing.T) {
+	assertion := assert.New(t)
+	q := newClientQueue(2, SlowConsumerCoalesce)
+
+	assertion.NoError(q.Offer("a"))
+	assertion.NoError(q.Offer("b"))
+	assertion.NoError(q.Offer("c"))
+	assertion.NoError(q.Offer("a"))
+	assertion.Equal(2, q.Len())
+	assertion.Equal(uint64(1), q.Dropped())
+
+	i, dup, err := q.Next(context.Background())
+	assertion.NoError(err)
+	assertion.Equal("a", i)
+	assertion.Equal(uint32(1), dup)
+	assertion.NoError(q.Offer("c"))
+	assertion.Equal(2, q.Len())
+}
+
+func TestClientQueue_DropOldest(t *testing.T) {
+	assertion := assert.New(t)
+	q := newClientQueue(2, SlowConsumerDropOldest)
+
+	for _, i := range []string{"a", "b", "c"} {
+		assertion.NoError(q.Offer(i))
+	}
+	assertion.Equal(2, q.Len())
+	assertion.Equal(uint64(1), q.Dropped())
+
+	i, _, err := q.Next(context.Background())
+	assertion.NoError(err)
+	assertion.Equal("b", i)
+}
+
+func TestClientQueue_DropOldest_syncMarker(t *testing.T) {
+	assertion := assert.New(t)
+	q := newClientQueue(2, SlowConsumerDropOldest)
+
+	_, err := q.Insert(syncMarker{})
+	assertion.NoError(err)
+	assertion.NoError(q.Offer("a"))
+	assertion.NoError(q.Offer("b"))
+	assertion.Equal(uint64(1), q.Dropped())
+
+	var items []interface{}
+	for q.Len() > 0 {
+		i, _, err := q.Next(context.Background())
+		assertion.NoError(err)
+		items = append(items, i)
+	}
+	assertion.Equal([]interface{}{syncMarker{}, "b"}, items)
+
+	// The sync marker is kept when it's the only queued item.
+	q = newClientQueue(1, SlowConsumerDropOldest)
+	_, err = q.Insert(syncMarker{})
+	assertion.NoError(err)
+	assertion.NoError(q.Offer("a"))
+	i, _, err := q.Next(context.Background())
+	assertion.NoError(err)
+	assertion.Equal(syncMarker{}, i)
+}
+
+func TestClientQueue_Disconnect(t *testing.T) {
+	assertion := assert.New(t)
+	q := newClientQueue(1, SlowConsumerDisconnect)
+
+	// The initial sync isn't limited.
+	_, err := q.Insert("a")
+	assertion.NoError(err)
+	_, err = q.Insert("b")
+	assertion.NoError(err)
+
+	err = q.Offer("c")
+	assertion.Equal(codes.ResourceExhausted, status.Code(err))
+	assertion.True(q.IsClosed())
+	assertion.Equal(err, q.Err())
+}
+
+func TestClientQueue_Next_concurrentOffer(t *testing.T) {
+	assertion := assert.New(t)
+	q := newClientQueue(4, SlowConsumerDropOldest)
+
+	const updates = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < updates; i++ {
+			assertion.NoError(q.Offer(i % 8))
+		}
+	}()
+	received := 0
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, _, err := q.Next(ctx)
+		cancel()
+		if err != nil {
+			select {
+			case <-done:
+			default:
+				continue
+			}
+			break
+		}
+		received++
+	}
+	assertion.NotZero(received)
+	// Every item taken by Next is removed from queued.
+	q.mutex.Lock()
+	assertion.Empty(q.queued)
+	q.mutex.Unlock()
+
+	// Next waits for an insert and returns an error once the queue is closed
+	// and empty.
+	go func() {
+		assertion.NoError(q.Offer("a"))
+		q.Close()
+	}()
+	i, _, err := q.Next(context.Background())
+	assertion.NoError(err)
+	assertion.Equal("a", i)
+	_, _, err = q.Next(context.Background())
+	assertion.Error(err)
+}
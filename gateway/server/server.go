@@ -41,16 +41,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/openconfig/gnmi-gateway/gateway/clustering"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 	"github.com/openconfig/gnmi-gateway/gateway/stats"
+	"github.com/openconfig/gnmi-gateway/gateway/tracing"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/coalesce"
 	"github.com/openconfig/gnmi/ctree"
 	"github.com/openconfig/gnmi/match"
@@ -98,9 +101,9 @@ type ACL interface {
 type Server struct {
 	pb.UnimplementedGNMIServer // Stub out all RPCs except Subscribe.
 
-	c       *cache.Cache // The cache queries are performed against.
-	m       *match.Match // Structure to match updates against active subscriptions.
-	a       ACL          // server ACL.
+	c       *shardedcache.Cache // The cache queries are performed against.
+	m       *match.Match        // Structure to match updates against active subscriptions.
+	a       ACL                 // server ACL.
 	config  *configuration.GatewayConfig
 	connMgr connections.ConnectionManager
 	cluster clustering.ClusterMember
@@ -108,18 +111,29 @@ type Server struct {
 	// queries are in flight.
 	subscribeSlots chan struct{}
 	timeout        time.Duration
+	history        HistoryProvider
+
+	clientsMutex sync.Mutex
+	clients      map[uint64]*streamClient
+	nextClientID uint64
 }
 
 type GNMIServerOpts struct {
 	Config  *configuration.GatewayConfig
-	Cache   *cache.Cache
+	Cache   *shardedcache.Cache
 	Cluster clustering.ClusterMember
 	ConnMgr connections.ConnectionManager
+	// History serves requests with the gNMI History extension. History
+	// requests are rejected as Unimplemented if History is nil.
+	History HistoryProvider
 }
 
 // NewServer instantiates server to handle client queries.  The cache should be
 // already instantiated.
 func NewServer(opts *GNMIServerOpts) (*Server, error) {
+	if err := ValidateSlowConsumerPolicy(opts.Config.ServerSlowConsumerPolicy); err != nil {
+		return nil, err
+	}
 	s := &Server{
 		c:       opts.Cache,
 		m:       match.New(),
@@ -127,6 +141,8 @@ func NewServer(opts *GNMIServerOpts) (*Server, error) {
 		cluster: opts.Cluster,
 		connMgr: opts.ConnMgr,
 		timeout: Timeout,
+		history: opts.History,
+		clients: make(map[uint64]*streamClient),
 	}
 	if SubscriptionLimit > 0 {
 		s.subscribeSlots = make(chan struct{}, SubscriptionLimit)
@@ -143,15 +159,19 @@ func (s *Server) SetACL(a ACL) {
 func (s *Server) Update(n *ctree.Leaf) {
 	switch v := n.Value().(type) {
 	case *pb.Notification:
-		p := path.ToStrings(v.Prefix, true)
+		// The path is only used while matching so the buffer is reused.
+		buf := pathPool.Get().(*[]string)
+		p := appendPathStrings((*buf)[:0], v.Prefix, true)
 		if len(v.Update) > 0 {
-			p = append(p, path.ToStrings(v.Update[0].Path, false)...)
+			p = appendPathStrings(p, v.Update[0].Path, false)
 		} else if len(v.Delete) > 0 {
-			p = append(p, path.ToStrings(v.Delete[0], false)...)
+			p = appendPathStrings(p, v.Delete[0], false)
 		}
 		// If neither update nor delete notification exists,
 		// just go with the path in the prefix
 		s.m.Update(n, p)
+		*buf = p[:0]
+		pathPool.Put(buf)
 	default:
 		s.config.Log.Error().Msgf("update is not a known type; type is %T", v)
 	}
@@ -183,8 +203,24 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 	tags := map[string]string{"gnmigateway.server.subscribe.peer": ctxPeer.Addr.String()}
 	stats.Registry.Counter("gnmigateway.server.subscribe.request", tags).Increment()
 	c := streamClient{stream: stream, acl: &aclStub{}}
+
+	var memberList []clustering.MemberID
 	var err error
-	if s.a != nil {
+	// Check if peer is a cluster member
+	if s.cluster != nil {
+		memberList, err = s.cluster.MemberList()
+		if err != nil {
+			tags["gnmigateway.server.subscribe.error_desc"] = "internal"
+			stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
+			return fmt.Errorf("unable to retrieve current cluster member list: %v", err)
+		}
+	}
+	clusterMember := memberAddressInMemberList(ctxPeer.Addr.String(), memberList)
+
+	// Cluster members replicate the data of every target but the source
+	// address of a connection doesn't authenticate it, so only clients with a
+	// cluster member's certificate bypass the ACL.
+	if s.a != nil && !s.clusterMemberCertificate(stream.Context()) {
 		a, err := s.a.NewRPCACL(stream.Context())
 		if err != nil {
 			s.config.Log.Error().Msgf("NewRPCACL fails due to %v", err)
@@ -215,7 +251,48 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 		return status.Errorf(codes.InvalidArgument, "request subscription prefix must contain a target %#v", c.sr)
 	}
 
+	// ACLs that authorize paths check the subscribed paths with each target.
+	if pathACL, ok := c.acl.(PathRPCACL); ok {
+		c.acl = &pathsACL{acl: pathACL, paths: subscriptionPathStrings(c.sr.GetSubscribe())}
+	}
+
 	c.target = c.sr.GetSubscribe().GetPrefix().GetTarget()
+	if isTargetPattern(c.target) {
+		c.pattern, err = parseTargetPattern(c.target)
+		if err != nil {
+			tags["gnmigateway.server.subscribe.error_desc"] = "bad_request"
+			stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		// The subscription is served as a subscription to all targets and
+		// the notifications of other targets are dropped by the ACL.
+		c.target = "*"
+		c.sr.GetSubscribe().Prefix.Target = "*"
+		c.acl = &patternACL{acl: c.acl, pattern: c.pattern}
+	}
+
+	_, span := tracing.Start(stream.Context(), "server.subscribe")
+	span.SetAttribute("gnmi.target", c.target)
+	span.SetAttribute("gnmi.mode", c.sr.GetSubscribe().GetMode().String())
+	span.SetAttribute("net.peer.address", ctxPeer.Addr.String())
+	defer span.End()
+
+	history, err := parseExtensions(c.sr.GetExtension())
+	if err != nil {
+		tags["gnmigateway.server.subscribe.error_desc"] = "bad_extension"
+		stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
+		return err
+	}
+	if history != nil {
+		if c.target != "*" && !c.acl.Check(c.target) {
+			tags["gnmigateway.server.subscribe.error_desc"] = "permission_denied"
+			stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
+			return status.Errorf(codes.PermissionDenied, "not authorized for target %q", c.target)
+		}
+		stats.Registry.Counter("gnmigateway.server.subscribe.history", tags).Increment()
+		return s.processHistory(&c, history)
+	}
+
 	if !s.c.HasTarget(c.target) {
 		tags["gnmigateway.server.subscribe.error_desc"] = "target_not_found"
 		stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
@@ -224,20 +301,7 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 
 	mode := c.sr.GetSubscribe().Mode
 
-	var clusterMember = false
-	var memberList []clustering.MemberID
-	// Check if peer is a cluster member
-	if s.cluster != nil {
-		memberList, err = s.cluster.MemberList()
-		if err != nil {
-			tags["gnmigateway.server.subscribe.error_desc"] = "internal"
-			stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
-			return fmt.Errorf("unable to retrieve current cluster member list: %v", err)
-		}
-	}
-
-	if memberAddressInMemberList(ctxPeer.Addr.String(), memberList) {
-		clusterMember = true
+	if clusterMember {
 		s.config.Log.Info().Msgf(`subscribe: cluster member peer: %v
 								  target: %q subscription: %s`, ctxPeer.Addr, c.target, c.sr)
 		defer s.config.Log.Info().Msgf("subscribe: cluster member peer: %v target %q subscription: end: %q", ctxPeer.Addr, c.target, c.sr)
@@ -246,9 +310,14 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 		defer s.config.Log.Info().Msgf("subscribe: client: %v target %q subscription: end: %q", ctxPeer.Addr, c.target, c.sr)
 	}
 
-	c.queue = coalesce.NewQueue()
+	c.queue = newClientQueue(s.config.ServerClientQueueLimit, s.config.ServerSlowConsumerPolicy)
 	defer c.queue.Close()
 
+	c.peer = ctxPeer.Addr.String()
+	c.clusterMember = clusterMember
+	c.federation = newFederationClient(stream.Context(), connections.FederationID(s.config))
+	defer s.registerClient(&c)()
+
 	// reject single device subscription if not allowed by ACL
 	if c.target != "*" && !c.acl.Check(c.target) {
 		tags["gnmigateway.server.subscribe.error_desc"] = "permission_denied"
@@ -270,6 +339,12 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 	case pb.SubscriptionList_POLL:
 		go s.processPollingSubscription(&c)
 	case pb.SubscriptionList_STREAM:
+		c.options, err = newStreamOptions(c.sr.GetSubscribe())
+		if err != nil {
+			tags["gnmigateway.server.subscribe.error_desc"] = "bad_request"
+			stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
 		if c.sr.GetSubscribe().GetUpdatesOnly() {
 			_, err = c.queue.Insert(syncMarker{})
 			if err != nil {
@@ -283,6 +358,16 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 		if !c.sr.GetSubscribe().GetUpdatesOnly() {
 			go s.processSubscription(&c)
 		}
+		if c.options != nil {
+			for _, sub := range c.options.subscriptions {
+				if sub.heartbeat > 0 {
+					go s.sendHeartbeats(stream.Context(), &c, sub)
+				}
+			}
+		}
+		if heartbeat, ok := stream.Context().Value(idleHeartbeatKey{}).(idleHeartbeat); ok && heartbeat.interval > 0 {
+			go s.sendIdleHeartbeats(&c, heartbeat)
+		}
 	default:
 		tags["gnmigateway.server.subscribe.error_desc"] = "bad_request"
 		stats.Registry.Counter("gnmigateway.server.subscribe.error", tags).Increment()
@@ -294,6 +379,20 @@ func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
 	return <-errC
 }
 
+// recoverClient recovers from a panic in one of the goroutines serving the
+// client and ends the client's RPC instead of crashing the gateway. It must be
+// deferred.
+func (s *Server) recoverClient(c *streamClient) {
+	if r := recover(); r != nil {
+		s.config.Log.Error().Msgf("panic while serving client %s: %v\n%s", c.peer, r, debug.Stack())
+		stats.Registry.Counter("gnmigateway.server.subscribe.panics", stats.NoTags).Increment()
+		select {
+		case c.errC <- status.Errorf(codes.Internal, "internal error"):
+		default:
+		}
+	}
+}
+
 type resp struct {
 	stream pb.GNMI_SubscribeServer
 	n      *ctree.Leaf
@@ -326,7 +425,28 @@ func (s *Server) sendSubscribeResponse(r *resp, c *streamClient) error {
 	// An empty function in production, replaced in test to simulate flow control
 	// by blocking before send.
 	flowControlTest()
-	return r.stream.Send(notification)
+
+	// The latency is the time from the notification timestamp on the target
+	// to the response being sent to the client.
+	ctx := context.Background()
+	if n, ok := r.n.Value().(*pb.Notification); ok {
+		ctx = tracing.NotificationContext(n)
+	}
+	_, span := tracing.Start(ctx, "server.send")
+	if span != nil {
+		span.SetAttribute("gnmi.target", notification.GetUpdate().GetPrefix().GetTarget())
+		span.SetAttribute("net.peer.address", c.peer)
+		if timestamp := notification.GetUpdate().GetTimestamp(); timestamp > 0 {
+			span.SetAttribute("gnmi.latency", time.Since(time.Unix(0, timestamp)))
+		}
+	}
+	err = r.stream.Send(notification)
+	span.SetError(err)
+	span.End()
+	if err == nil {
+		c.sent(proto.Size(notification))
+	}
+	return err
 }
 
 // subscribeSync is a response indicating that a Subscribe RPC has successfully
@@ -339,26 +459,41 @@ type syncMarker struct{}
 // cacheClient implements match.Client interface.
 type matchClient struct {
 	acl RPCACL
-	q   *coalesce.Queue
+	q   *clientQueue
 	err error
 }
 
 // Update implements the match.Client Update interface for coalesce.Queue.
+// Updates are subject to the client queue limit.
 func (c matchClient) Update(n interface{}) {
 	// Stop processing updates on error.
 	if c.err != nil {
 		return
 	}
-	_, c.err = c.q.Insert(n)
+	c.err = c.q.Offer(n)
 }
 
 type streamClient struct {
 	acl    RPCACL
 	target string
-	sr     *pb.SubscribeRequest
-	queue  *coalesce.Queue
-	stream pb.GNMI_SubscribeServer
-	errC   chan<- error
+	// pattern is set if the requested target is a list of targets or a glob
+	// pattern. target is "*" in that case.
+	pattern targetPattern
+	sr      *pb.SubscribeRequest
+	queue   *clientQueue
+	stream  pb.GNMI_SubscribeServer
+	errC    chan<- error
+	// options is set if a subscription of a STREAM mode request sets
+	// suppress_redundant or heartbeat_interval.
+	options *streamOptions
+	// federation is set if the client is another gateway.
+	federation *federationClient
+
+	id            uint64
+	peer          string
+	clusterMember bool
+	connected     time.Time
+	stats         *clientStats
 }
 
 // processSubscription walks the cache tree and inserts all of the matching
@@ -366,6 +501,7 @@ type streamClient struct {
 // If the clusterMember flag is true only matching nodes that were sourced
 // locally will be forwarded.
 func (s *Server) processSubscription(c *streamClient) {
+	defer s.recoverClient(c)
 	var err error
 	s.config.Log.Debug().Msgf("start processSubscription for %p", c)
 	// Close the cache client queue on error.
@@ -453,6 +589,7 @@ func (s *Server) processPollingSubscription(c *streamClient) {
 // sendStreamingResults forwards all streaming updates to a given streaming
 // Subscription RPC client.
 func (s *Server) sendStreamingResults(c *streamClient, connMgr connections.ConnectionManager, clusterMember bool) {
+	defer s.recoverClient(c)
 	ctx := c.stream.Context()
 	ctxPeer, _ := peer.FromContext(ctx)
 	t := time.NewTimer(s.timeout)
@@ -471,10 +608,17 @@ func (s *Server) sendStreamingResults(c *streamClient, connMgr connections.Conne
 		case <-done:
 		}
 	}()
+	// synced is set once the sync response is sent; idle heartbeats are only
+	// sent after it.
+	var synced bool
 	for {
 		item, dup, err := c.queue.Next(ctx)
 		if coalesce.IsClosedQueue(err) {
-			c.errC <- nil
+			if err := c.queue.Err(); err != nil {
+				s.config.Log.Warn().Msgf("Disconnecting slow client %v: %v", ctxPeer.Addr, err)
+				stats.Registry.Counter("gnmigateway.server.subscribe.slow_consumer_disconnect", stats.NoTags).Increment()
+			}
+			c.errC <- c.queue.Err()
 			return
 		}
 		if err != nil {
@@ -488,9 +632,30 @@ func (s *Server) sendStreamingResults(c *streamClient, connMgr connections.Conne
 				c.errC <- err
 				return
 			}
+			c.sent(proto.Size(subscribeSync))
+			synced = true
+			continue
+		}
+
+		if _, ok := item.(idleHeartbeatMarker); ok {
+			if !synced {
+				continue
+			}
+			heartbeat, _ := ctx.Value(idleHeartbeatKey{}).(idleHeartbeat)
+			response := idleHeartbeatResponse(c, heartbeat.message)
+			if err = c.stream.Send(response); err != nil {
+				c.errC <- err
+				return
+			}
+			c.sent(proto.Size(response))
+			stats.Registry.Counter("gnmigateway.server.subscribe.idle_heartbeats", stats.NoTags).Increment()
 			continue
 		}
 
+		var isHeartbeat bool
+		if h, ok := item.(heartbeat); ok {
+			item, isHeartbeat = h.leaf, true
+		}
 		n, ok := item.(*ctree.Leaf)
 		if !ok || n == nil {
 			c.errC <- status.Errorf(codes.Internal, "invalid cache node: %#v", item)
@@ -510,6 +675,37 @@ func (s *Server) sendStreamingResults(c *streamClient, connMgr connections.Conne
 			}
 		}
 
+		// Gateways subscribed with a gateway target are sent the origin of
+		// each target and aren't sent the targets they are the origin of.
+		if c.federation != nil {
+			notification, _ := n.Value().(*pb.Notification)
+			origin, send := c.federation.check(notification, connMgr.Origin)
+			if !send {
+				stats.Registry.Counter("gnmigateway.server.subscribe.federation_loops", stats.NoTags).Increment()
+				continue
+			}
+			if origin != nil {
+				response := &pb.SubscribeResponse{Response: &pb.SubscribeResponse_Update{Update: origin}}
+				if err = c.stream.Send(response); err != nil {
+					c.errC <- err
+					return
+				}
+				c.sent(proto.Size(response))
+			}
+			if isTargetDelete(n) {
+				c.federation.forget(notification.GetPrefix().GetTarget())
+			}
+		}
+
+		// Drop the updates that the client asked to be suppressed because
+		// the value didn't change.
+		if c.options != nil {
+			if n = c.options.filter(n, isHeartbeat); n == nil {
+				stats.Registry.Counter("gnmigateway.server.subscribe.suppressed", stats.NoTags).Increment()
+				continue
+			}
+		}
+
 		if err = s.sendSubscribeResponse(&resp{
 			stream: c.stream,
 			n:      n,
@@ -550,8 +746,7 @@ func MakeSubscribeResponse(n interface{}, dup uint32) (*pb.SubscribeResponse, er
 	if dup > 0 && len(notification.Update) > 0 {
 		// We need a copy of the cached notification before writing a client specific
 		// duplicate count as the notification is shared across all clients.
-		notification = proto.Clone(notification).(*pb.Notification)
-		notification.Update[0].Duplicates = dup
+		notification = withDuplicates(notification, dup)
 	}
 	response := &pb.SubscribeResponse{
 		Response: &pb.SubscribeResponse_Update{
@@ -562,6 +757,24 @@ func MakeSubscribeResponse(n interface{}, dup uint32) (*pb.SubscribeResponse, er
 	return response, nil
 }
 
+// clusterMemberCertificate returns true if the client presented a verified TLS
+// client certificate with one of the ClusterMemberCommonNames.
+func (s *Server) clusterMemberCertificate(ctx context.Context) bool {
+	if len(s.config.ClusterMemberCommonNames) == 0 {
+		return false
+	}
+	name, err := clientCommonName(ctx)
+	if err != nil {
+		return false
+	}
+	for _, member := range s.config.ClusterMemberCommonNames {
+		if name == member {
+			return true
+		}
+	}
+	return false
+}
+
 // memberAddressInMemberList will return true of the IP portion of the supplied address is present in the member list.
 func memberAddressInMemberList(addr string, list []clustering.MemberID) bool {
 	ipParts := strings.Split(addr, ":")
@@ -46,14 +46,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/openconfig/gnmi-gateway/gateway/capture"
 	"github.com/openconfig/gnmi-gateway/gateway/clustering"
 	"github.com/openconfig/gnmi-gateway/gateway/configuration"
 	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/openconfig/gnmi/cache"
 	"github.com/openconfig/gnmi/client"
 	gnmiclient "github.com/openconfig/gnmi/client/gnmi"
 	"github.com/openconfig/gnmi/ctree"
@@ -64,10 +64,11 @@ import (
 	"google.golang.org/grpc/peer"
 
 	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/protobuf/proto"
 )
 
-func startServer(targets []string) (string, *cache.Cache, func(), error) {
-	c := cache.New(targets)
+func startServer(targets []string) (string, *shardedcache.Cache, func(), error) {
+	c := shardedcache.New(1, targets)
 	opts := &GNMIServerOpts{
 		Config:  configuration.NewDefaultGatewayConfig(),
 		Cache:   c,
@@ -293,7 +294,7 @@ func TestGNMIOnce(t *testing.T) {
 
 // sendUpdates generates an update for each supplied path incrementing the
 // timestamp and value for each.
-func sendUpdates(t *testing.T, c *cache.Cache, paths []client.Path, timestamp *time.Time) {
+func sendUpdates(t *testing.T, c *shardedcache.Cache, paths []client.Path, timestamp *time.Time) {
 	t.Helper()
 	for _, path := range paths {
 		*timestamp = timestamp.Add(time.Nanosecond)
@@ -568,6 +569,72 @@ func TestGNMIUpdatesOnly(t *testing.T) {
 	}
 }
 
+// startFakeSubscribe starts a Subscribe RPC for req on a fake stream and
+// returns the stream and a function that ends the RPC.
+func startFakeSubscribe(p *Server, req *pb.SubscribeRequest) (*fakeSubServer, func()) {
+	s := &fakeSubServer{
+		req: make(chan *pb.SubscribeRequest, 2),
+		rsp: make(chan *pb.SubscribeResponse, 100),
+	}
+	ctx, cancel := context.WithCancel(peer.NewContext(context.Background(), &peer.Peer{Addr: &fakeNet{}}))
+	s.ctx = ctx
+	s.req <- req
+	go p.Subscribe(s)
+	return s, cancel
+}
+
+// nextResponse returns the next response sent to s or fails the test if no
+// response is sent within a few seconds.
+func nextResponse(t *testing.T, s *fakeSubServer) *pb.SubscribeResponse {
+	t.Helper()
+	select {
+	case r := <-s.rsp:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a response")
+	}
+	return nil
+}
+
+func TestGNMIUpdatesOnlyOnceAndPoll(t *testing.T) {
+	c := shardedcache.New(1, []string{"dev1"})
+	p, err := NewServer(&GNMIServerOpts{
+		Config:  configuration.NewDefaultGatewayConfig(),
+		Cache:   c,
+		Cluster: &MockCluster{},
+		ConnMgr: &MockConnectionManager{},
+	})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	c.SetClient(p.Update)
+	var timestamp time.Time
+	sendUpdates(t, c, []client.Path{{"dev1", "a", "b"}}, &timestamp)
+
+	for _, mode := range []pb.SubscriptionList_Mode{pb.SubscriptionList_ONCE, pb.SubscriptionList_POLL} {
+		s, stop := startFakeSubscribe(p, &pb.SubscribeRequest{
+			Request: &pb.SubscribeRequest_Subscribe{
+				Subscribe: &pb.SubscriptionList{
+					Prefix:       &pb.Path{Target: "dev1"},
+					Subscription: []*pb.Subscription{{Path: &pb.Path{Element: []string{"a"}}}},
+					Mode:         mode,
+					UpdatesOnly:  true,
+				},
+			},
+		})
+		if r := nextResponse(t, s); !r.GetSyncResponse() {
+			t.Errorf("%v: got %v, want only a sync response", mode, r)
+		}
+		if mode == pb.SubscriptionList_POLL {
+			s.req <- &pb.SubscribeRequest{Request: &pb.SubscribeRequest_Poll{Poll: &pb.Poll{}}}
+			if r := nextResponse(t, s); !r.GetSyncResponse() {
+				t.Errorf("%v: got %v after poll, want only a sync response", mode, r)
+			}
+		}
+		stop()
+	}
+}
+
 // If a client doesn't read any of the responses, it should not affect other
 // clients querying the same target.
 func TestGNMISubscribeUnresponsiveClient(t *testing.T) {
@@ -1098,7 +1165,7 @@ func (s *fakeSubServer) Context() context.Context {
 
 func TestGNMIACL(t *testing.T) {
 	targets := []string{"dev-pii", "dev-no-pii"}
-	c := cache.New(targets)
+	c := shardedcache.New(1, targets)
 	opts := &GNMIServerOpts{
 		Config:  &configuration.GatewayConfig{},
 		Cache:   c,
@@ -1317,7 +1384,15 @@ func (m MockCluster) Unregister() error {
 type MockConnectionManager struct {
 }
 
-func (m MockConnectionManager) Cache() *cache.Cache {
+func (m MockConnectionManager) Cache() *shardedcache.Cache {
+	panic("implement me")
+}
+
+func (m MockConnectionManager) DisableTarget(target string, flush bool) error {
+	panic("implement me")
+}
+
+func (m MockConnectionManager) EnableTarget(target string) error {
 	panic("implement me")
 }
 
@@ -1325,10 +1400,42 @@ func (m MockConnectionManager) Forwardable(target string) bool {
 	panic("implement me")
 }
 
+func (m MockConnectionManager) Origin(target string) []string {
+	return nil
+}
+
+func (m MockConnectionManager) ReconnectTarget(target string) error {
+	panic("implement me")
+}
+
 func (m MockConnectionManager) Start() error {
 	panic("implement me")
 }
 
+func (m MockConnectionManager) StartCapture(target string, file string, format capture.Format) error {
+	panic("implement me")
+}
+
+func (m MockConnectionManager) StopCapture(target string) error {
+	panic("implement me")
+}
+
+func (m MockConnectionManager) Stop() {
+	panic("implement me")
+}
+
 func (m MockConnectionManager) TargetControlChan() chan<- *connections.TargetConnectionControl {
 	panic("implement me")
 }
+
+func (m MockConnectionManager) TargetErrors(target string) ([]connections.TargetError, error) {
+	panic("implement me")
+}
+
+func (m MockConnectionManager) TargetTenant(target string) string {
+	panic("implement me")
+}
+
+func (m MockConnectionManager) Targets() []connections.TargetStatus {
+	panic("implement me")
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/path"
+
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// minHeartbeatInterval is the shortest heartbeat interval that is honored.
+// Shorter intervals are raised to it so that a client can't make the server
+// walk the cache continuously. Value overridden in tests.
+var minHeartbeatInterval = time.Second
+
+// streamSubscription is a subscription of a STREAM mode Subscribe request.
+type streamSubscription struct {
+	// pattern is the subscribed path including the target, as matched
+	// against streamed updates.
+	pattern []string
+	// query is the subscribed path without the target, as passed to Query.
+	query             []string
+	suppressRedundant bool
+	heartbeat         time.Duration
+}
+
+// sentValue is the last value of a leaf that was sent to a client.
+type sentValue struct {
+	path []string
+	val  *pb.TypedValue
+}
+
+// streamOptions emulates the suppress_redundant and heartbeat_interval
+// options of the subscriptions of a STREAM mode Subscribe request. The cache
+// only drops updates that don't change the cached value, so a client is still
+// sent redundant values for atomic notifications and for leaves that changed
+// and changed back while an update was queued for the client. Heartbeats are
+// sent by querying the cache.
+type streamOptions struct {
+	subscriptions []*streamSubscription
+
+	mutex sync.Mutex
+	// sent holds the last value sent for each leaf that is only matched by
+	// subscriptions with suppress_redundant set.
+	sent map[string]sentValue
+}
+
+// heartbeat is a cache leaf queued by a heartbeat. Heartbeats are sent even
+// if the value is redundant.
+type heartbeat struct {
+	leaf *ctree.Leaf
+}
+
+// newStreamOptions returns the options of the subscriptions in s or nil if no
+// subscription sets suppress_redundant or heartbeat_interval.
+func newStreamOptions(s *pb.SubscriptionList) (*streamOptions, error) {
+	o := &streamOptions{
+		sent: make(map[string]sentValue),
+	}
+	var enabled bool
+	prefix := path.ToStrings(s.GetPrefix(), true)
+	for _, subscription := range s.GetSubscription() {
+		if subscription.GetPath() == nil {
+			continue
+		}
+		query, err := path.CompletePath(s.GetPrefix(), subscription.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		sub := &streamSubscription{
+			pattern:           append(append([]string{}, prefix...), path.ToStrings(subscription.GetPath(), false)...),
+			query:             query,
+			suppressRedundant: subscription.GetSuppressRedundant(),
+			heartbeat:         time.Duration(subscription.GetHeartbeatInterval()),
+		}
+		if sub.heartbeat > 0 && sub.heartbeat < minHeartbeatInterval {
+			sub.heartbeat = minHeartbeatInterval
+		}
+		enabled = enabled || sub.suppressRedundant || sub.heartbeat > 0
+		o.subscriptions = append(o.subscriptions, sub)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return o, nil
+}
+
+// suppressed returns true if redundant updates for p are suppressed. Updates
+// are only suppressed if every subscription that matches p sets
+// suppress_redundant.
+func (o *streamOptions) suppressed(p []string) bool {
+	var matched bool
+	for _, sub := range o.subscriptions {
+		if !matchQuery(p, sub.pattern) {
+			continue
+		}
+		if !sub.suppressRedundant {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// filter drops the updates in the notification of l for suppressed leaves
+// that have the same value as the last value sent for the leaf, unless l was
+// queued by a heartbeat, and records the values of the remaining updates as
+// sent. It returns nil if nothing is left to send.
+func (o *streamOptions) filter(l *ctree.Leaf, isHeartbeat bool) *ctree.Leaf {
+	n, ok := l.Value().(*pb.Notification)
+	if !ok {
+		return l
+	}
+	prefix := path.ToStrings(n.GetPrefix(), true)
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, d := range n.GetDelete() {
+		deleted := append(append([]string{}, prefix...), path.ToStrings(d, false)...)
+		for key, sent := range o.sent {
+			if matchQuery(sent.path, deleted) {
+				delete(o.sent, key)
+			}
+		}
+	}
+	updates := make([]*pb.Update, 0, len(n.GetUpdate()))
+	for _, u := range n.GetUpdate() {
+		p := append(append([]string{}, prefix...), path.ToStrings(u.GetPath(), false)...)
+		if !o.suppressed(p) {
+			updates = append(updates, u)
+			continue
+		}
+		key := pathKey(p)
+		if last, exists := o.sent[key]; exists && !isHeartbeat && proto.Equal(last.val, u.GetVal()) {
+			continue
+		}
+		o.sent[key] = sentValue{path: p, val: u.GetVal()}
+		updates = append(updates, u)
+	}
+
+	switch {
+	case len(updates) == len(n.GetUpdate()):
+		return l
+	case len(updates) == 0 && len(n.GetDelete()) == 0:
+		return nil
+	}
+	return ctree.DetachedLeaf(&pb.Notification{
+		Timestamp: n.Timestamp,
+		Prefix:    n.Prefix,
+		Update:    updates,
+		Delete:    n.Delete,
+		Atomic:    n.Atomic,
+	})
+}
+
+// sendHeartbeats queues the cached leaves of sub every heartbeat interval,
+// whether or not they changed, until ctx is done or the client's queue is
+// closed.
+func (s *Server) sendHeartbeats(ctx context.Context, c *streamClient, sub *streamSubscription) {
+	defer s.recoverClient(c)
+	ticker := time.NewTicker(sub.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if c.queue.IsClosed() {
+			return
+		}
+		err := s.c.Query(c.target, sub.query, func(_ []string, l *ctree.Leaf, val interface{}) error {
+			if val == nil {
+				return nil
+			}
+			stats.Registry.Counter("gnmigateway.server.subscribe.heartbeats", stats.NoTags).Increment()
+			return c.queue.Offer(heartbeat{leaf: l})
+		})
+		if err != nil {
+			s.config.Log.Debug().Msgf("stopping heartbeats for client %s: %v", c.peer, err)
+			return
+		}
+	}
+}
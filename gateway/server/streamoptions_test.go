@@ -0,0 +1,204 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/client"
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+func stringUpdate(name string, value string) *pb.Update {
+	return &pb.Update{
+		Path: &pb.Path{Element: []string{name}},
+		Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: value}},
+	}
+}
+
+func updateNames(n *pb.Notification) []string {
+	var names []string
+	for _, u := range n.GetUpdate() {
+		names = append(names, u.GetPath().GetElement()[0])
+	}
+	return names
+}
+
+func TestNewStreamOptions(t *testing.T) {
+	assertion := assert.New(t)
+
+	list := &pb.SubscriptionList{
+		Prefix:       &pb.Path{Target: "dev1"},
+		Subscription: []*pb.Subscription{{Path: &pb.Path{Element: []string{"a"}}}},
+	}
+	o, err := newStreamOptions(list)
+	assertion.NoError(err)
+	assertion.Nil(o)
+
+	list.Subscription[0].HeartbeatInterval = uint64(time.Millisecond)
+	o, err = newStreamOptions(list)
+	if !assertion.NoError(err) || !assertion.NotNil(o) {
+		return
+	}
+	assertion.Equal(minHeartbeatInterval, o.subscriptions[0].heartbeat)
+	assertion.Equal([]string{"dev1", "a"}, o.subscriptions[0].pattern)
+	assertion.Equal([]string{"a"}, o.subscriptions[0].query)
+}
+
+func TestStreamOptions_filter(t *testing.T) {
+	assertion := assert.New(t)
+
+	o, err := newStreamOptions(&pb.SubscriptionList{
+		Prefix: &pb.Path{Target: "dev1"},
+		Subscription: []*pb.Subscription{
+			{Path: &pb.Path{Element: []string{"a"}}, SuppressRedundant: true},
+			{Path: &pb.Path{Element: []string{"a", "all"}}},
+		},
+	})
+	if !assertion.NoError(err) {
+		return
+	}
+	notification := func(updates ...*pb.Update) *ctree.Leaf {
+		return ctree.DetachedLeaf(&pb.Notification{
+			Prefix: &pb.Path{Target: "dev1", Element: []string{"a"}},
+			Update: updates,
+		})
+	}
+
+	first := notification(stringUpdate("b", "1"), stringUpdate("c", "1"), stringUpdate("all", "1"))
+	assertion.Equal(first, o.filter(first, false))
+
+	// Only the changed leaf and the leaf of the subscription without
+	// suppress_redundant are sent.
+	l := o.filter(notification(stringUpdate("b", "1"), stringUpdate("c", "2"), stringUpdate("all", "1")), false)
+	if assertion.NotNil(l) {
+		assertion.Equal([]string{"c", "all"}, updateNames(l.Value().(*pb.Notification)))
+	}
+	assertion.Nil(o.filter(notification(stringUpdate("b", "1")), false))
+
+	// Heartbeats are sent even if the value didn't change.
+	heartbeat := notification(stringUpdate("b", "1"))
+	assertion.Equal(heartbeat, o.filter(heartbeat, true))
+
+	// A deleted leaf is sent again when it is recreated with the same value.
+	deleted := ctree.DetachedLeaf(&pb.Notification{
+		Prefix: &pb.Path{Target: "dev1"},
+		Delete: []*pb.Path{{Element: []string{"a", "b"}}},
+	})
+	assertion.Equal(deleted, o.filter(deleted, false))
+	assertion.NotNil(o.filter(notification(stringUpdate("b", "1")), false))
+}
+
+func newStreamOptionsServer(t *testing.T) (*Server, *shardedcache.Cache) {
+	c := shardedcache.New(1, []string{"dev1"})
+	p, err := NewServer(&GNMIServerOpts{
+		Config:  configuration.NewDefaultGatewayConfig(),
+		Cache:   c,
+		Cluster: &MockCluster{},
+		ConnMgr: &MockConnectionManager{},
+	})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	c.SetClient(p.Update)
+	return p, c
+}
+
+func TestGNMISuppressRedundant(t *testing.T) {
+	assertion := assert.New(t)
+	p, c := newStreamOptionsServer(t)
+
+	s, stop := startFakeSubscribe(p, &pb.SubscribeRequest{
+		Request: &pb.SubscribeRequest_Subscribe{
+			Subscribe: &pb.SubscriptionList{
+				Prefix: &pb.Path{Target: "dev1"},
+				Subscription: []*pb.Subscription{
+					{Path: &pb.Path{Element: []string{"a"}}, SuppressRedundant: true},
+				},
+				Mode: pb.SubscriptionList_STREAM,
+			},
+		},
+	})
+	defer stop()
+	assertion.True(nextResponse(t, s).GetSyncResponse())
+
+	// The cache stores atomic notifications as a single leaf and streams
+	// them even if none of the values changed.
+	var timestamp int64
+	update := func(updates ...*pb.Update) {
+		timestamp++
+		err := c.GnmiUpdate(&pb.Notification{
+			Timestamp: timestamp,
+			Prefix:    &pb.Path{Target: "dev1", Element: []string{"a"}},
+			Update:    updates,
+			Atomic:    true,
+		})
+		if err != nil {
+			t.Fatalf("GnmiUpdate: %v", err)
+		}
+	}
+	update(stringUpdate("b", "1"), stringUpdate("c", "1"))
+	assertion.Equal([]string{"b", "c"}, updateNames(nextResponse(t, s).GetUpdate()))
+	update(stringUpdate("b", "1"), stringUpdate("c", "2"))
+	assertion.Equal([]string{"c"}, updateNames(nextResponse(t, s).GetUpdate()))
+	update(stringUpdate("b", "1"), stringUpdate("c", "2"))
+	update(stringUpdate("b", "2"), stringUpdate("c", "2"))
+	assertion.Equal([]string{"b"}, updateNames(nextResponse(t, s).GetUpdate()))
+}
+
+func TestGNMIHeartbeat(t *testing.T) {
+	defer func(interval time.Duration) { minHeartbeatInterval = interval }(minHeartbeatInterval)
+	minHeartbeatInterval = time.Millisecond
+
+	assertion := assert.New(t)
+	p, c := newStreamOptionsServer(t)
+	var timestamp time.Time
+	sendUpdates(t, c, []client.Path{{"dev1", "a", "b"}}, &timestamp)
+
+	s, stop := startFakeSubscribe(p, &pb.SubscribeRequest{
+		Request: &pb.SubscribeRequest_Subscribe{
+			Subscribe: &pb.SubscriptionList{
+				Prefix: &pb.Path{Target: "dev1"},
+				Subscription: []*pb.Subscription{
+					{
+						Path:              &pb.Path{Element: []string{"a"}},
+						Mode:              pb.SubscriptionMode_ON_CHANGE,
+						HeartbeatInterval: uint64(50 * time.Millisecond),
+						SuppressRedundant: true,
+					},
+				},
+				Mode: pb.SubscriptionList_STREAM,
+			},
+		},
+	})
+	defer stop()
+	first := nextResponse(t, s).GetUpdate()
+	assertion.Equal([]string{"a"}, updateNames(first))
+	assertion.True(nextResponse(t, s).GetSyncResponse())
+
+	// The unchanged value is sent again every heartbeat interval.
+	for i := 0; i < 2; i++ {
+		heartbeat := nextResponse(t, s).GetUpdate()
+		assertion.Equal([]string{"a"}, updateNames(heartbeat))
+		assertion.Equal(first.GetUpdate()[0].GetVal(), heartbeat.GetUpdate()[0].GetVal())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// targetPattern matches the targets of a subscription whose Prefix.Target is
+// a comma-separated list of target names and glob patterns, e.g.
+// "core1,edge-*". The subscription is served as a subscription to all targets
+// ("*") and only the notifications of matching targets are sent, so targets
+// that are added later join the subscription automatically.
+type targetPattern []string
+
+// isTargetPattern returns true if target is a list of targets or a glob
+// pattern other than "*".
+func isTargetPattern(target string) bool {
+	return target != "*" && strings.ContainsAny(target, ",*?[")
+}
+
+// parseTargetPattern parses a comma-separated list of target names and glob
+// patterns.
+func parseTargetPattern(target string) (targetPattern, error) {
+	var pattern targetPattern
+	for _, p := range strings.Split(target, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid target pattern %q: %v", p, err)
+		}
+		pattern = append(pattern, p)
+	}
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("invalid target pattern %q", target)
+	}
+	return pattern, nil
+}
+
+// Match returns true if target matches one of the names or patterns.
+func (p targetPattern) Match(target string) bool {
+	for _, pattern := range p {
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the list of names and patterns.
+func (p targetPattern) String() string {
+	return strings.Join(p, ",")
+}
+
+// patternACL restricts an RPCACL to the targets matching a targetPattern.
+type patternACL struct {
+	acl     RPCACL
+	pattern targetPattern
+}
+
+// Check implements RPCACL.
+func (a *patternACL) Check(target string) bool {
+	return a.pattern.Match(target) && a.acl.Check(target)
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTargetPattern(t *testing.T) {
+	assert.False(t, isTargetPattern(""))
+	assert.False(t, isTargetPattern("*"))
+	assert.False(t, isTargetPattern("router1"))
+	assert.True(t, isTargetPattern("router*"))
+	assert.True(t, isTargetPattern("router1,router2"))
+	assert.True(t, isTargetPattern("router[12]"))
+}
+
+func TestParseTargetPattern(t *testing.T) {
+	pattern, err := parseTargetPattern("core1, edge-*")
+	assert.NoError(t, err)
+	assert.Equal(t, "core1,edge-*", pattern.String())
+	assert.True(t, pattern.Match("core1"))
+	assert.True(t, pattern.Match("edge-7"))
+	assert.False(t, pattern.Match("core2"))
+
+	_, err = parseTargetPattern("router[")
+	assert.Error(t, err)
+	_, err = parseTargetPattern(",")
+	assert.Error(t, err)
+}
+
+func TestPatternACL(t *testing.T) {
+	pattern, err := parseTargetPattern("edge-*")
+	assert.NoError(t, err)
+	acl := &patternACL{acl: &aclStub{}, pattern: pattern}
+	assert.True(t, acl.Check("edge-1"))
+	assert.False(t, acl.Check("core-1"))
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/openconfig/gnmi/cache"
+	"github.com/openconfig/gnmi/ctree"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// TenantACL is an ACL that only allows gNMI clients to access the targets of
+// the tenant that their TLS client certificate is assigned to. Clients are
+// identified by the common name of their certificate.
+type TenantACL struct {
+	// clients maps the certificate common names to tenants.
+	clients map[string]string
+	// targetTenant returns the tenant of a target.
+	targetTenant func(target string) string
+}
+
+// NewTenantACL returns a TenantACL for tenants. targetTenant must return the
+// tenant that a target is assigned to.
+func NewTenantACL(tenants []configuration.Tenant, targetTenant func(target string) string) *TenantACL {
+	clients := make(map[string]string)
+	for _, tenant := range tenants {
+		for _, client := range tenant.Clients {
+			clients[client] = tenant.Name
+		}
+	}
+	return &TenantACL{clients: clients, targetTenant: targetTenant}
+}
+
+// NewRPCACL implements ACL. An error is returned if the client didn't present
+// a TLS certificate or the certificate isn't assigned to a tenant.
+func (a *TenantACL) NewRPCACL(ctx context.Context) (RPCACL, error) {
+	name, err := clientCommonName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenant, exists := a.clients[name]
+	if !exists {
+		return nil, fmt.Errorf("client %q is not assigned to a tenant", name)
+	}
+	return &tenantRPCACL{tenant: tenant, targetTenant: a.targetTenant}, nil
+}
+
+// Check implements ACL. It returns true if the client with the certificate
+// common name belongs to the tenant of target.
+func (a *TenantACL) Check(client string, target string) bool {
+	tenant, exists := a.clients[client]
+	return exists && a.targetTenant(target) == tenant
+}
+
+// tenantRPCACL allows access to the targets of a single tenant.
+type tenantRPCACL struct {
+	tenant       string
+	targetTenant func(target string) string
+}
+
+// Check implements RPCACL.
+func (a *tenantRPCACL) Check(target string) bool {
+	return a.targetTenant(target) == a.tenant
+}
+
+// clientCommonName returns the common name of the verified TLS client
+// certificate of the RPC's peer.
+func clientCommonName(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("no peer information")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return "", errors.New("no verified TLS client certificate")
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+// TenantServer is a gNMI server that keeps a cache per tenant. The updates of
+// the gateway's cache are copied into the cache of the tenant of their target
+// and each tenant's clients are served by a Server on the tenant's cache, so
+// clients can only query and receive the data of their tenant's targets.
+// Clients with a certificate listed in ClusterMemberCommonNames are served
+// from the gateway's cache.
+type TenantServer struct {
+	pb.UnimplementedGNMIServer // Stub out all RPCs except Subscribe.
+
+	config *configuration.GatewayConfig
+	// members serves the other cluster members from the gateway's cache.
+	members *Server
+	// tenants are the tenants by name and clients the tenants by the common
+	// names of their clients' certificates.
+	tenants      map[string]*tenantServer
+	clients      map[string]*tenantServer
+	targetTenant func(target string) string
+
+	// cached are the names of the tenants whose cache holds each target.
+	cached      map[string]string
+	cachedMutex sync.Mutex
+}
+
+// tenantServer is the cache and the Server of a tenant.
+type tenantServer struct {
+	name   string
+	cache  *shardedcache.Cache
+	server *Server
+}
+
+// NewTenantServer returns a TenantServer for tenants. opts.Cache is the
+// gateway's cache, which must pass its updates to Update. targetTenant must
+// return the tenant that a target is assigned to.
+func NewTenantServer(opts *GNMIServerOpts, tenants []configuration.Tenant, targetTenant func(target string) string) (*TenantServer, error) {
+	members, err := NewServer(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &TenantServer{
+		config:       opts.Config,
+		members:      members,
+		tenants:      make(map[string]*tenantServer),
+		clients:      make(map[string]*tenantServer),
+		targetTenant: targetTenant,
+		cached:       make(map[string]string),
+	}
+	// The tenant servers also check the ACL for the History extension, which
+	// is served from the notifications of all tenants.
+	acl := NewTenantACL(tenants, targetTenant)
+	for _, tenant := range tenants {
+		t := &tenantServer{name: tenant.Name, cache: shardedcache.New(opts.Config.CacheShards, nil)}
+		t.server, err = NewServer(&GNMIServerOpts{
+			Config:  opts.Config,
+			Cache:   t.cache,
+			Cluster: opts.Cluster,
+			ConnMgr: opts.ConnMgr,
+			History: opts.History,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t.server.SetACL(acl)
+		t.cache.SetClient(t.server.Update)
+		s.tenants[tenant.Name] = t
+		for _, client := range tenant.Clients {
+			s.clients[client] = t
+		}
+	}
+	return s, nil
+}
+
+// Update copies an update of the gateway's cache into the cache of the
+// tenant of its target and passes it to the cluster members. A target is
+// removed from the cache of its previous tenant if its tenant changes.
+func (s *TenantServer) Update(leaf *ctree.Leaf) {
+	s.members.Update(leaf)
+	notification, ok := leaf.Value().(*pb.Notification)
+	if !ok {
+		return
+	}
+	target := notification.GetPrefix().GetTarget()
+
+	s.cachedMutex.Lock()
+	previous, cached := s.cached[target]
+	var t *tenantServer
+	if utils.IsTargetDelete(notification) {
+		delete(s.cached, target)
+	} else {
+		t = s.tenants[s.targetTenant(target)]
+		switch {
+		case t == nil:
+			delete(s.cached, target)
+		case !cached || previous != t.name:
+			t.cache.Add(target)
+			s.cached[target] = t.name
+		}
+	}
+	s.cachedMutex.Unlock()
+
+	if cached && (t == nil || previous != t.name) {
+		s.tenants[previous].cache.Remove(target)
+	}
+	if t == nil {
+		return
+	}
+	if len(notification.GetDelete()) > 0 {
+		defer t.cache.ResolveDeletes(notification)()
+	}
+	if err := t.cache.GnmiUpdate(notification); err != nil && err != cache.ErrStale {
+		s.config.Log.Error().Msgf("Unable to update the cache of tenant %q for target %q: %v", t.name, target, err)
+	}
+}
+
+// Subscribe implements gnmi.GNMIServer. Clients are served from the cache of
+// the tenant that their certificate is assigned to.
+func (s *TenantServer) Subscribe(stream pb.GNMI_SubscribeServer) error {
+	srv, err := s.server(stream.Context())
+	if err != nil {
+		s.config.Log.Error().Msgf("Unable to find the tenant of a Subscribe client: %v", err)
+		return err
+	}
+	return srv.Subscribe(stream)
+}
+
+// server returns the Server for the client of the RPC.
+func (s *TenantServer) server(ctx context.Context) (*Server, error) {
+	if s.members.clusterMemberCertificate(ctx) {
+		return s.members, nil
+	}
+	name, err := clientCommonName(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "no authentication/authorization for requested operation")
+	}
+	t, exists := s.clients[name]
+	if !exists {
+		return nil, status.Errorf(codes.PermissionDenied, "client %q is not assigned to a tenant", name)
+	}
+	return t.server, nil
+}
+
+// Clients returns information about the currently connected Subscribe clients
+// of the cluster members and then of each tenant ordered by name.
+func (s *TenantServer) Clients() []ClientInfo {
+	clients := s.members.Clients()
+	names := make([]string, 0, len(s.tenants))
+	for name := range s.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, client := range s.tenants[name].server.Clients() {
+			client.Tenant = name
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
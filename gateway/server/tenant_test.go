@@ -0,0 +1,153 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/shardedcache"
+)
+
+func peerContext(commonName string) context.Context {
+	state := tls.ConnectionState{}
+	if commonName != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestTenantACL(t *testing.T) {
+	assertion := assert.New(t)
+
+	targets := map[string]string{"a1": "a", "a2": "a", "b1": "b"}
+	acl := NewTenantACL([]configuration.Tenant{
+		{Name: "a", Clients: []string{"client-a"}},
+		{Name: "b", Clients: []string{"client-b"}},
+	}, func(target string) string { return targets[target] })
+
+	rpcACL, err := acl.NewRPCACL(peerContext("client-a"))
+	assertion.NoError(err)
+	assertion.True(rpcACL.Check("a1"))
+	assertion.True(rpcACL.Check("a2"))
+	assertion.False(rpcACL.Check("b1"))
+	assertion.False(rpcACL.Check("unknown"))
+
+	assertion.True(acl.Check("client-b", "b1"))
+	assertion.False(acl.Check("client-b", "a1"))
+
+	_, err = acl.NewRPCACL(peerContext("client-c"))
+	assertion.Error(err)
+	_, err = acl.NewRPCACL(peerContext(""))
+	assertion.Error(err)
+	_, err = acl.NewRPCACL(context.Background())
+	assertion.Error(err)
+}
+
+func TestServer_clusterMemberCertificate(t *testing.T) {
+	assertion := assert.New(t)
+
+	s := &Server{config: &configuration.GatewayConfig{}}
+	assertion.False(s.clusterMemberCertificate(peerContext("gateway-2")))
+
+	s.config.ClusterMemberCommonNames = []string{"gateway-2"}
+	assertion.True(s.clusterMemberCertificate(peerContext("gateway-2")))
+	assertion.False(s.clusterMemberCertificate(peerContext("client-a")))
+	// the address of a cluster member without its certificate isn't enough
+	assertion.False(s.clusterMemberCertificate(peerContext("")))
+}
+
+func TestTenantServer(t *testing.T) {
+	assertion := assert.New(t)
+
+	targets := map[string]string{"a1": "a", "b1": "b"}
+	c := shardedcache.New(2, []string{"a1", "b1", "c1"})
+	s, err := NewTenantServer(&GNMIServerOpts{
+		Config: &configuration.GatewayConfig{ClusterMemberCommonNames: []string{"gateway-2"}},
+		Cache:  c,
+	}, []configuration.Tenant{
+		{Name: "a", Clients: []string{"client-a"}},
+		{Name: "b", Clients: []string{"client-b"}},
+	}, func(target string) string { return targets[target] })
+	assertion.NoError(err)
+	c.SetClient(s.Update)
+	update := func(target string, timestamp int64) {
+		assertion.NoError(c.GnmiUpdate(&pb.Notification{
+			Timestamp: timestamp,
+			Prefix:    &pb.Path{Target: target},
+			Update: []*pb.Update{{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "x"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: timestamp}},
+			}},
+		}))
+	}
+
+	// each tenant's cache only holds the tenant's targets
+	for _, target := range []string{"a1", "b1", "c1"} {
+		update(target, 1)
+	}
+	assertion.True(s.tenants["a"].cache.HasTarget("a1"))
+	assertion.False(s.tenants["a"].cache.HasTarget("b1"))
+	assertion.True(s.tenants["b"].cache.HasTarget("b1"))
+	assertion.False(s.tenants["b"].cache.HasTarget("a1"))
+	for _, tenant := range s.tenants {
+		assertion.False(tenant.cache.HasTarget("c1"))
+	}
+
+	// a target is moved when its tenant changes
+	targets["a1"] = "b"
+	update("a1", 2)
+	assertion.False(s.tenants["a"].cache.HasTarget("a1"))
+	assertion.True(s.tenants["b"].cache.HasTarget("a1"))
+	snapshot, err := s.tenants["b"].cache.Snapshot("a1", nil)
+	assertion.NoError(err)
+	if assertion.Len(snapshot, 1) {
+		assertion.Equal(int64(2), snapshot[0].GetUpdate()[0].GetVal().GetIntVal())
+	}
+
+	// a target removed from the gateway's cache is removed from its tenant's
+	c.Remove("a1")
+	assertion.False(s.tenants["b"].cache.HasTarget("a1"))
+	assertion.True(s.tenants["b"].cache.HasTarget("b1"))
+
+	// clients are served by the server of their tenant
+	srv, err := s.server(peerContext("client-a"))
+	assertion.NoError(err)
+	assertion.True(srv == s.tenants["a"].server)
+	srv, err = s.server(peerContext("gateway-2"))
+	assertion.NoError(err)
+	assertion.True(srv == s.members)
+	_, err = s.server(peerContext("client-c"))
+	assertion.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = s.server(peerContext(""))
+	assertion.Equal(codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build windows
+
+package gateway
+
+import (
+	"golang.org/x/sys/windows/svc"
+)
+
+// windowsServiceName is the name the gateway runs under when started by the
+// Service Control Manager. The name is only used for logging because the
+// gateway runs in its own process.
+const windowsServiceName = "gnmi-gateway"
+
+// windowsService runs the gateway as a Windows service: the gateway is
+// reported as running once it has started and is stopped gracefully when the
+// service is stopped or the system shuts down.
+type windowsService struct {
+	gateway *Gateway
+	opts    *StartOpts
+	err     error
+}
+
+// runWindowsService runs the gateway until it is stopped by the Service
+// Control Manager if the process was started as a Windows service. It returns
+// false without running the gateway otherwise.
+func runWindowsService(gateway *Gateway, opts *StartOpts) (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, err
+	}
+	service := &windowsService{gateway: gateway, opts: opts}
+	if err := svc.Run(windowsServiceName, service); err != nil {
+		return true, err
+	}
+	return true, service.err
+}
+
+// Execute implements svc.Handler.
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.StartPending}
+
+	started := make(chan struct{})
+	onStarted := s.opts.Hooks.OnStarted
+	s.opts.Hooks.OnStarted = func(g *Gateway) {
+		if onStarted != nil {
+			onStarted(g)
+		}
+		close(started)
+	}
+	result := s.gateway.Start(s.opts)
+	for {
+		select {
+		case <-started:
+			status <- svc.Status{State: svc.Running, Accepts: accepts}
+			started = nil
+		case s.err = <-result:
+			if s.err != nil {
+				// A service-specific exit code tells the Service Control
+				// Manager that the service failed so that the recovery
+				// actions are run.
+				return true, 1
+			}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s.gateway.config.Log.Info().Msg("Windows service stop requested.")
+				status <- svc.Status{State: svc.StopPending}
+				s.gateway.Stop()
+			}
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shardedcache spreads the targets of the gateway across several
+// cache.Cache instances so that updates for different targets don't contend
+// on the lock of a single cache.
+//
+// Targets are assigned to a shard by the hash of their name. Queries for a
+// single target are sent to its shard and queries for all targets ("*") are
+// merged across the shards. The client set with SetClient receives the
+// updates of every shard.
+//
+// cache.Cache reports each deleted leaf to the client with the deprecated
+// string Element path of the leaf in the cache, which loses the key names.
+// ResolveDeletes records the gNMI paths of the leaves a notification deletes
+// so that the client receives deletes with Elem paths instead.
+//
+// Snapshot returns copies of the cached notifications that match a path so
+// that exporters and the admin API can read the current state of targets
+// without keeping their own copy of the updates. Each shard has a lock that is
+// held for reading while its targets are updated and for writing while
+// Snapshot collects the leaves of its targets, so a snapshot doesn't include
+// partial updates. Updates made through a cache.Target returned by Add or
+// GetTarget must hold the lock returned by UpdateLocker.
+package shardedcache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/cache"
+	"github.com/openconfig/gnmi/ctree"
+	"github.com/openconfig/gnmi/path"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	"github.com/openconfig/gnmi-gateway/gateway/utils"
+)
+
+// Cache is a set of cache.Cache shards with the same methods as a
+// cache.Cache that the gateway uses.
+type Cache struct {
+	shards []*cache.Cache
+	// locks are the update locks of the shards with the same index.
+	locks []sync.RWMutex
+	// deleted are the paths of the leaves being deleted by the notifications
+	// passed to ResolveDeletes, by target and cache path.
+	deleted      map[string]deletedLeaf
+	deletedMutex sync.Mutex
+}
+
+// deletedLeaf is the gNMI path of a leaf being deleted.
+type deletedLeaf struct {
+	origin string
+	elems  []*gnmipb.PathElem
+	// pending is the number of notifications being inserted that delete the
+	// leaf.
+	pending int
+}
+
+// New creates a Cache with the specified number of shards that contains the
+// targets. A Cache with one shard behaves like a single cache.Cache.
+func New(shards int, targets []string) *Cache {
+	if shards < 1 {
+		shards = 1
+	}
+	c := &Cache{
+		shards:  make([]*cache.Cache, shards),
+		locks:   make([]sync.RWMutex, shards),
+		deleted: make(map[string]deletedLeaf),
+	}
+	assigned := make([][]string, shards)
+	for _, target := range targets {
+		i := c.index(target)
+		assigned[i] = append(assigned[i], target)
+	}
+	for i := range c.shards {
+		c.shards[i] = cache.New(assigned[i])
+	}
+	return c
+}
+
+func (c *Cache) index(target string) int {
+	if len(c.shards) == 1 {
+		return 0
+	}
+	return int(xxhash.Sum64String(target) % uint64(len(c.shards)))
+}
+
+// Shard returns the cache.Cache that contains the target.
+func (c *Cache) Shard(target string) *cache.Cache {
+	return c.shards[c.index(target)]
+}
+
+// UpdateLocker returns the lock that must be held while the target is updated
+// through its cache.Target. It must not be held while Snapshot is called.
+func (c *Cache) UpdateLocker(target string) sync.Locker {
+	return c.locks[c.index(target)].RLocker()
+}
+
+// Shards returns the number of shards.
+func (c *Cache) Shards() int {
+	return len(c.shards)
+}
+
+// SetClient sets the function that receives the updates of all shards.
+// Deletes of leaves resolved with ResolveDeletes are passed to client with
+// Elem paths.
+func (c *Cache) SetClient(client func(*ctree.Leaf)) {
+	for _, shard := range c.shards {
+		shard.SetClient(func(l *ctree.Leaf) {
+			client(c.resolveDelete(l))
+		})
+	}
+}
+
+// deletedKey returns the key of a leaf in deleted.
+func deletedKey(target string, p []string) string {
+	return target + "\x00" + strings.Join(p, "\x00")
+}
+
+// ResolveDeletes looks up the cached leaves that the deletes of the
+// notification remove so that the client set with SetClient receives their
+// deletes with Elem paths. The returned function must be called once the
+// notification has been inserted into the cache.
+func (c *Cache) ResolveDeletes(notification *gnmipb.Notification) func() {
+	target := notification.GetPrefix().GetTarget()
+	if len(notification.GetDelete()) == 0 || target == "" || target == "*" || !c.HasTarget(target) {
+		return func() {}
+	}
+	prefix := path.ToStrings(notification.GetPrefix(), true)[1:]
+	leaves := make(map[string]deletedLeaf)
+	for _, del := range notification.GetDelete() {
+		query := append(append([]string{}, prefix...), path.ToStrings(del, false)...)
+		if len(query) == 1 && query[0] == "*" {
+			// the whole target is deleted
+			continue
+		}
+		_ = c.Query(target, query, func(p []string, _ *ctree.Leaf, val interface{}) error {
+			cached, ok := val.(*gnmipb.Notification)
+			if !ok || len(cached.GetUpdate()) != 1 {
+				return nil
+			}
+			elems := make([]*gnmipb.PathElem, 0, len(cached.GetPrefix().GetElem())+len(cached.GetUpdate()[0].GetPath().GetElem()))
+			elems = append(elems, cached.GetPrefix().GetElem()...)
+			elems = append(elems, cached.GetUpdate()[0].GetPath().GetElem()...)
+			leaves[deletedKey(target, p)] = deletedLeaf{origin: cached.GetPrefix().GetOrigin(), elems: elems}
+			return nil
+		})
+	}
+
+	// The cache isn't queried while deletedMutex is held.
+	c.deletedMutex.Lock()
+	for key, leaf := range leaves {
+		if existing, exists := c.deleted[key]; exists {
+			leaf.pending = existing.pending
+		}
+		leaf.pending++
+		c.deleted[key] = leaf
+	}
+	c.deletedMutex.Unlock()
+	return func() {
+		c.deletedMutex.Lock()
+		defer c.deletedMutex.Unlock()
+		for key := range leaves {
+			leaf := c.deleted[key]
+			if leaf.pending--; leaf.pending > 0 {
+				c.deleted[key] = leaf
+			} else {
+				delete(c.deleted, key)
+			}
+		}
+	}
+}
+
+// resolveDelete returns a leaf with the Elem path of the deleted leaf if l is
+// the delete of a leaf recorded by ResolveDeletes, otherwise l.
+func (c *Cache) resolveDelete(l *ctree.Leaf) *ctree.Leaf {
+	notification, ok := l.Value().(*gnmipb.Notification)
+	if !ok || len(notification.GetDelete()) != 1 || len(notification.GetDelete()[0].GetElem()) > 0 {
+		return l
+	}
+	target := notification.GetPrefix().GetTarget()
+	c.deletedMutex.Lock()
+	leaf, exists := c.deleted[deletedKey(target, notification.GetDelete()[0].GetElement())]
+	c.deletedMutex.Unlock()
+	if !exists {
+		return l
+	}
+	return ctree.DetachedLeaf(&gnmipb.Notification{
+		Timestamp: notification.GetTimestamp(),
+		Prefix:    &gnmipb.Path{Target: target, Origin: leaf.origin},
+		Delete:    []*gnmipb.Path{{Elem: leaf.elems}},
+	})
+}
+
+// Add adds the target to its shard and returns the target cache.
+func (c *Cache) Add(target string) *cache.Target {
+	return c.Shard(target).Add(target)
+}
+
+// GetTarget returns the target cache or nil if the target isn't cached.
+func (c *Cache) GetTarget(target string) *cache.Target {
+	return c.Shard(target).GetTarget(target)
+}
+
+// HasTarget returns true if the target is cached.
+func (c *Cache) HasTarget(target string) bool {
+	return c.Shard(target).HasTarget(target)
+}
+
+// Remove removes the target and its data from its shard.
+func (c *Cache) Remove(target string) {
+	locker := c.UpdateLocker(target)
+	locker.Lock()
+	defer locker.Unlock()
+	c.Shard(target).Remove(target)
+}
+
+// GnmiUpdate inserts the notification into the shard of its prefix target.
+func (c *Cache) GnmiUpdate(notification *gnmipb.Notification) error {
+	target := notification.GetPrefix().GetTarget()
+	locker := c.UpdateLocker(target)
+	locker.Lock()
+	defer locker.Unlock()
+	return c.Shard(target).GnmiUpdate(notification)
+}
+
+// Query calls fn for the leaves of the target that match the query. The
+// target "*" queries all targets in all shards; the first error returned by
+// a shard stops the query.
+func (c *Cache) Query(target string, query []string, fn ctree.VisitFunc) error {
+	if target != "*" {
+		return c.Shard(target).Query(target, query, fn)
+	}
+	for _, shard := range c.shards {
+		if err := shard.Query(target, query, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateMetadata updates the metadata leaves of the targets in all shards.
+func (c *Cache) UpdateMetadata() {
+	for i, shard := range c.shards {
+		c.locks[i].RLock()
+		shard.UpdateMetadata()
+		c.locks[i].RUnlock()
+	}
+}
+
+// UpdateSize updates the size metadata leaves of the targets in all shards.
+func (c *Cache) UpdateSize() {
+	for i, shard := range c.shards {
+		c.locks[i].RLock()
+		shard.UpdateSize()
+		c.locks[i].RUnlock()
+	}
+}
+
+// ParseQuery converts an XPath such as
+// /interfaces/interface[name=*]/state/counters into a query for Query and
+// Snapshot. Elements and key values may be "*" to match any value; the query
+// matches every leaf below the path.
+func ParseQuery(p string) ([]string, error) {
+	if p == "" || p == "/" {
+		return nil, nil
+	}
+	parsed, err := utils.WildcardXPathToGNMIPath(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query path '%s': %v", p, err)
+	}
+	return path.ToStrings(parsed, false), nil
+}
+
+// Snapshot returns copies of the notifications of the leaves of the target
+// that match the query, sorted by target and path. The target "*" returns
+// the leaves of all targets. The copies aren't changed by later updates and
+// may be modified by the caller.
+//
+// The shards are read one at a time and each shard is only locked while the
+// leaves of its targets are collected, so the snapshot of a target is the
+// state of the target at a single point in time while the other shards keep
+// receiving updates. The cached notifications are replaced rather than
+// modified by updates, so they are copied once the shard is unlocked.
+// Snapshot waits for the updates in progress in a shard, which may wait for
+// the clients of the cache, so it must not be called from the client set with
+// SetClient or from a function that the client waits for, such as an
+// exporter's Export.
+func (c *Cache) Snapshot(target string, query []string) ([]*gnmipb.Notification, error) {
+	shards := []int{c.index(target)}
+	if target == "*" {
+		shards = make([]int, len(c.shards))
+		for i := range shards {
+			shards[i] = i
+		}
+	}
+
+	type leaf struct {
+		key          string
+		notification *gnmipb.Notification
+	}
+	var leaves []leaf
+	for _, i := range shards {
+		c.locks[i].Lock()
+		err := c.shards[i].Query(target, query, func(p []string, _ *ctree.Leaf, val interface{}) error {
+			notification, ok := val.(*gnmipb.Notification)
+			if !ok {
+				return nil
+			}
+			leaves = append(leaves, leaf{
+				key:          notification.GetPrefix().GetTarget() + "\x00" + strings.Join(p, "\x00"),
+				notification: notification,
+			})
+			return nil
+		})
+		c.locks[i].Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return leaves[i].key < leaves[j].key
+	})
+	notifications := make([]*gnmipb.Notification, len(leaves))
+	for i, l := range leaves {
+		notifications[i] = proto.Clone(l.notification).(*gnmipb.Notification)
+	}
+	return notifications, nil
+}
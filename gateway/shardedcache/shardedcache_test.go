@@ -0,0 +1,276 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shardedcache
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/ctree"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func notification(target string, leaf string, value uint64) *gnmipb.Notification {
+	return &gnmipb.Notification{
+		Timestamp: int64(value),
+		Prefix:    &gnmipb.Path{Target: target},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: leaf}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: value}},
+		}},
+	}
+}
+
+func TestCache(t *testing.T) {
+	assertion := assert.New(t)
+
+	var targets []string
+	for i := 0; i < 20; i++ {
+		targets = append(targets, "target"+strconv.Itoa(i))
+	}
+	c := New(4, targets)
+	assertion.Equal(4, c.Shards())
+
+	var mutex sync.Mutex
+	var updated []string
+	c.SetClient(func(leaf *ctree.Leaf) {
+		if n, ok := leaf.Value().(*gnmipb.Notification); ok {
+			mutex.Lock()
+			updated = append(updated, n.GetPrefix().GetTarget())
+			mutex.Unlock()
+		}
+	})
+
+	used := make(map[int]bool)
+	for _, target := range targets {
+		assertion.True(c.HasTarget(target))
+		assertion.NotNil(c.GetTarget(target))
+		used[c.index(target)] = true
+		assertion.NoError(c.GnmiUpdate(notification(target, "a", 1)))
+	}
+	assertion.Len(used, 4, "targets should be spread across all shards")
+	assertion.Len(updated, len(targets))
+
+	var queried []string
+	assertion.NoError(c.Query("*", []string{"*"}, func(_ []string, _ *ctree.Leaf, val interface{}) error {
+		queried = append(queried, val.(*gnmipb.Notification).GetPrefix().GetTarget())
+		return nil
+	}))
+	sort.Strings(queried)
+	sorted := append([]string(nil), targets...)
+	sort.Strings(sorted)
+	assertion.Equal(sorted, queried)
+
+	queried = nil
+	assertion.NoError(c.Query("target3", []string{"*"}, func(_ []string, _ *ctree.Leaf, val interface{}) error {
+		queried = append(queried, val.(*gnmipb.Notification).GetPrefix().GetTarget())
+		return nil
+	}))
+	assertion.Equal([]string{"target3"}, queried)
+
+	c.Remove("target3")
+	assertion.False(c.HasTarget("target3"))
+	assertion.Error(c.GnmiUpdate(notification("target3", "a", 2)))
+
+	c.Add("new")
+	assertion.True(c.HasTarget("new"))
+	assertion.NoError(c.GnmiUpdate(notification("new", "a", 1)))
+}
+
+func TestCache_Query_error(t *testing.T) {
+	c := New(4, []string{"a", "b", "c", "d", "e", "f"})
+	for _, target := range []string{"a", "b", "c", "d", "e", "f"} {
+		assert.NoError(t, c.GnmiUpdate(notification(target, "a", 1)))
+	}
+	calls := 0
+	err := c.Query("*", []string{"*"}, func(_ []string, _ *ctree.Leaf, _ interface{}) error {
+		calls++
+		return fmt.Errorf("stop")
+	})
+	assert.EqualError(t, err, "stop")
+	assert.Equal(t, 1, calls)
+}
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery("/interfaces/interface[name=*]/state/counters")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"interfaces", "interface", "*", "state", "counters"}, query)
+
+	query, err = ParseQuery("/")
+	assert.NoError(t, err)
+	assert.Empty(t, query)
+
+	_, err = ParseQuery("/interfaces/interface[name=*")
+	assert.Error(t, err)
+}
+
+func TestCache_Snapshot(t *testing.T) {
+	assertion := assert.New(t)
+
+	c := New(4, []string{"a", "b", "c"})
+	for _, target := range []string{"c", "a", "b"} {
+		assert.NoError(t, c.GnmiUpdate(notification(target, "y", 1)))
+		assert.NoError(t, c.GnmiUpdate(notification(target, "x", 2)))
+	}
+
+	snapshot, err := c.Snapshot("*", nil)
+	assertion.NoError(err)
+	var leaves []string
+	for _, n := range snapshot {
+		leaves = append(leaves, n.GetPrefix().GetTarget()+"/"+n.GetUpdate()[0].GetPath().GetElem()[0].GetName())
+	}
+	assertion.Equal([]string{"a/x", "a/y", "b/x", "b/y", "c/x", "c/y"}, leaves)
+
+	snapshot, err = c.Snapshot("b", []string{"x"})
+	assertion.NoError(err)
+	if assertion.Len(snapshot, 1) {
+		// the snapshot isn't changed by updates and doesn't change the cache
+		assertion.NoError(c.GnmiUpdate(notification("b", "x", 3)))
+		assertion.Equal(uint64(2), snapshot[0].GetUpdate()[0].GetVal().GetUintVal())
+		snapshot[0].Update = nil
+		snapshot, err = c.Snapshot("b", []string{"x"})
+		assertion.NoError(err)
+		assertion.Equal(uint64(3), snapshot[0].GetUpdate()[0].GetVal().GetUintVal())
+	}
+
+	_, err = c.Snapshot("missing", nil)
+	assertion.Error(err)
+}
+
+func TestCache_Snapshot_updateLocker(t *testing.T) {
+	assertion := assert.New(t)
+
+	c := New(4, []string{"a", "b", "c", "d", "e"})
+	assertion.NoError(c.GnmiUpdate(notification("a", "x", 1)))
+	other := "b"
+	for _, target := range []string{"c", "d", "e"} {
+		if c.index(other) == c.index("a") {
+			other = target
+		}
+	}
+
+	// A snapshot waits for the updates in progress in the shards it reads.
+	locker := c.UpdateLocker("a")
+	locker.Lock()
+	done := make(chan []*gnmipb.Notification)
+	go func() {
+		snapshot, _ := c.Snapshot("*", nil)
+		done <- snapshot
+	}()
+	select {
+	case <-done:
+		t.Fatal("the snapshot didn't wait for the update")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// The other shards aren't locked while the snapshot waits.
+	assertion.NoError(c.GnmiUpdate(notification(other, "x", 1)))
+	assertion.NoError(c.GetTarget("a").GnmiUpdate(notification("a", "x", 2)))
+	locker.Unlock()
+	snapshot := <-done
+	for _, n := range snapshot {
+		if n.GetPrefix().GetTarget() == "a" {
+			assertion.Equal(uint64(2), n.GetUpdate()[0].GetVal().GetUintVal())
+		}
+	}
+}
+
+func TestCache_ResolveDeletes(t *testing.T) {
+	assertion := assert.New(t)
+
+	c := New(2, []string{"a"})
+	var deletes []*gnmipb.Notification
+	c.SetClient(func(l *ctree.Leaf) {
+		if n, ok := l.Value().(*gnmipb.Notification); ok && len(n.GetDelete()) > 0 {
+			deletes = append(deletes, n)
+		}
+	})
+	neighbor := []*gnmipb.PathElem{{Name: "neighbors"}, {Name: "neighbor", Key: map[string]string{"address": "10.0.0.1"}}}
+	assertion.NoError(c.GnmiUpdate(&gnmipb.Notification{
+		Timestamp: 1,
+		Prefix:    &gnmipb.Path{Target: "a", Origin: "openconfig", Elem: neighbor},
+		Update: []*gnmipb.Update{{
+			Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "state"}, {Name: "session-state"}}},
+			Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "ESTABLISHED"}},
+		}},
+	}))
+
+	del := &gnmipb.Notification{
+		Timestamp: 2,
+		Prefix:    &gnmipb.Path{Target: "a", Origin: "openconfig"},
+		Delete:    []*gnmipb.Path{{Elem: neighbor}},
+	}
+	resolved := c.ResolveDeletes(del)
+	assertion.NoError(c.GnmiUpdate(del))
+	resolved()
+	if assertion.Len(deletes, 1) {
+		assertion.Equal("openconfig", deletes[0].GetPrefix().GetOrigin())
+		elems := deletes[0].GetDelete()[0].GetElem()
+		if assertion.Len(elems, 4) {
+			assertion.Equal(map[string]string{"address": "10.0.0.1"}, elems[1].GetKey())
+			assertion.Equal("session-state", elems[3].GetName())
+		}
+	}
+	assertion.Empty(c.deleted)
+
+	// deletes of leaves that weren't resolved are passed through
+	assertion.NoError(c.GnmiUpdate(notification("a", "x", 3)))
+	assertion.NoError(c.GnmiUpdate(&gnmipb.Notification{
+		Timestamp: 4,
+		Prefix:    &gnmipb.Path{Target: "a"},
+		Delete:    []*gnmipb.Path{{Elem: []*gnmipb.PathElem{{Name: "x"}}}},
+	}))
+	if assertion.Len(deletes, 2) {
+		assertion.Equal("x", deletes[1].GetDelete()[0].GetElem()[0].GetName())
+	}
+}
+
+func TestNew_shards(t *testing.T) {
+	assert.Equal(t, 1, New(0, nil).Shards())
+	assert.Equal(t, 1, New(-1, nil).Shards())
+}
+
+// BenchmarkCache_GnmiUpdate updates many targets concurrently. Compare the
+// shard counts with -cpu to see how the cache scales with the number of cores.
+func BenchmarkCache_GnmiUpdate(b *testing.B) {
+	const targetCount = 1000
+	var targets []string
+	for i := 0; i < targetCount; i++ {
+		targets = append(targets, "target"+strconv.Itoa(i))
+	}
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := New(shards, targets)
+			c.SetClient(func(*ctree.Leaf) {})
+			var next uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := atomic.AddUint64(&next, 1)
+					// Concurrent updates of the same target may arrive out of
+					// order and be rejected as stale, which is fine here.
+					_ = c.GnmiUpdate(notification(targets[i%targetCount], "counter", i))
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemdNotifier sends the service state to systemd with the sd_notify
+// protocol when the gateway runs in a unit with Type=notify. A nil
+// systemdNotifier ignores all notifications so that the gateway runs the same
+// outside of systemd.
+type systemdNotifier struct {
+	socket string
+	// watchdog is the interval that systemd expects keep-alive pings within,
+	// zero if the unit doesn't set WatchdogSec.
+	watchdog time.Duration
+}
+
+// newSystemdNotifier returns the notifier for the socket in NOTIFY_SOCKET, or
+// nil if the gateway wasn't started by systemd with Type=notify.
+func newSystemdNotifier() *systemdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	n := &systemdNotifier{socket: socket}
+	// WATCHDOG_PID is set if the watchdog is meant for another process of
+	// the unit.
+	if pid := os.Getenv("WATCHDOG_PID"); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+		if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+			n.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return n
+}
+
+// notify sends the state, e.g. "READY=1", to systemd.
+func (n *systemdNotifier) notify(state string) error {
+	if n == nil {
+		return nil
+	}
+	// A socket name starting with @ is in the abstract namespace, which the
+	// net package handles.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// runWatchdog pings the systemd watchdog at half of the watchdog interval
+// until stop is closed. It returns immediately if the watchdog isn't enabled.
+func (n *systemdNotifier) runWatchdog(stop <-chan struct{}, onError func(error)) {
+	if n == nil || n.watchdog <= 0 {
+		return
+	}
+	ticker := time.NewTicker(n.watchdog / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := n.notify("WATCHDOG=1"); err != nil {
+				onError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
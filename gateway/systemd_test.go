@@ -0,0 +1,103 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setenv sets the environment variables and returns a function that
+// restores their previous values.
+func setenv(t *testing.T, values map[string]string) func() {
+	var restore []func()
+	for name, value := range values {
+		name := name
+		previous, exists := os.LookupEnv(name)
+		assert.NoError(t, os.Setenv(name, value))
+		restore = append(restore, func() {
+			if exists {
+				_ = os.Setenv(name, previous)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		})
+	}
+	return func() {
+		for _, f := range restore {
+			f()
+		}
+	}
+}
+
+func TestNewSystemdNotifier(t *testing.T) {
+	assertion := assert.New(t)
+
+	defer setenv(t, map[string]string{"NOTIFY_SOCKET": "", "WATCHDOG_USEC": "", "WATCHDOG_PID": ""})()
+	assertion.Nil(newSystemdNotifier())
+	assertion.NoError(newSystemdNotifier().notify("READY=1"))
+
+	assertion.NoError(os.Setenv("NOTIFY_SOCKET", "/run/systemd/notify"))
+	n := newSystemdNotifier()
+	if assertion.NotNil(n) {
+		assertion.Equal("/run/systemd/notify", n.socket)
+		assertion.Zero(n.watchdog)
+	}
+
+	assertion.NoError(os.Setenv("WATCHDOG_USEC", "30000000"))
+	assertion.Equal(30*time.Second, newSystemdNotifier().watchdog)
+	assertion.NoError(os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid())))
+	assertion.Equal(30*time.Second, newSystemdNotifier().watchdog)
+	// the watchdog is meant for another process
+	assertion.NoError(os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1)))
+	assertion.Zero(newSystemdNotifier().watchdog)
+}
+
+func TestSystemdNotifier_notify(t *testing.T) {
+	assertion := assert.New(t)
+	dir, err := ioutil.TempDir("", "gnmi-gateway")
+	assertion.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if !assertion.NoError(err) {
+		return
+	}
+	defer conn.Close()
+
+	n := &systemdNotifier{socket: path, watchdog: 20 * time.Millisecond}
+	assertion.NoError(n.notify("READY=1"))
+	buf := make([]byte, 64)
+	assertion.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	size, err := conn.Read(buf)
+	assertion.NoError(err)
+	assertion.Equal("READY=1", string(buf[:size]))
+
+	stop := make(chan struct{})
+	go n.runWatchdog(stop, func(err error) { t.Error(err) })
+	size, err = conn.Read(buf)
+	close(stop)
+	assertion.NoError(err)
+	assertion.Equal("WATCHDOG=1", string(buf[:size]))
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// linkTTL is how long a notification stays linked to the span that received
+// it. Notifications that are queued for longer start new traces.
+const linkTTL = time.Minute
+
+type link struct {
+	span    *Span
+	expires time.Time
+}
+
+// The cache splits notifications into a notification per leaf but keeps the
+// *gnmipb.Update values, so spans are linked to the updates rather than the
+// notification that was received.
+var links = struct {
+	sync.Mutex
+	updates map[*gnmipb.Update]link
+	swept   time.Time
+}{updates: make(map[*gnmipb.Update]link)}
+
+// LinkNotification links the updates in the notification to the span in ctx
+// so that the spans of later stages, such as sending the updates to gNMI
+// clients, are started as children of it with NotificationContext. Nothing
+// is linked if ctx doesn't contain a sampled span.
+func LinkNotification(ctx context.Context, notification *gnmipb.Notification) {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	if span == nil || len(notification.GetUpdate()) == 0 {
+		return
+	}
+	now := time.Now()
+	links.Lock()
+	defer links.Unlock()
+	if now.Sub(links.swept) > linkTTL {
+		for update, l := range links.updates {
+			if now.After(l.expires) {
+				delete(links.updates, update)
+			}
+		}
+		links.swept = now
+	}
+	for _, update := range notification.GetUpdate() {
+		links.updates[update] = link{span: span, expires: now.Add(linkTTL)}
+	}
+}
+
+// NotificationContext returns a context containing the span linked to the
+// notification's updates with LinkNotification, or context.Background if
+// the notification isn't linked.
+func NotificationContext(notification *gnmipb.Notification) context.Context {
+	ctx := context.Background()
+	updates := notification.GetUpdate()
+	if len(updates) == 0 {
+		return ctx
+	}
+	links.Lock()
+	l, exists := links.updates[updates[0]]
+	links.Unlock()
+	if !exists || time.Now().After(l.expires) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, l.span)
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/Netflix/spectator-go"
+	"github.com/rs/zerolog"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/otlp"
+	"github.com/openconfig/gnmi-gateway/gateway/stats"
+)
+
+const (
+	// otlpBatchSize is the maximum number of spans in an export request.
+	otlpBatchSize = 512
+	// otlpQueueSize is the number of finished spans buffered for export.
+	// Spans are dropped if the queue is full.
+	otlpQueueSize = 8192
+	// otlpFlushInterval is the maximum time a span is buffered.
+	otlpFlushInterval = 5 * time.Second
+	otlpTimeout       = 10 * time.Second
+	// otlpSpanKindInternal is the OTLP SPAN_KIND_INTERNAL value.
+	otlpSpanKindInternal = 1
+	// otlpStatusError is the OTLP STATUS_CODE_ERROR value.
+	otlpStatusError = 2
+	serviceName     = "gnmi-gateway"
+)
+
+// otlpExporter exports spans to an OTLP/HTTP endpoint with JSON encoding.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+	log      zerolog.Logger
+	spans    chan *Span
+	stopped  chan struct{}
+	done     chan struct{}
+
+	counterDropped *spectator.Counter
+	counterErrors  *spectator.Counter
+	counterSent    *spectator.Counter
+}
+
+func newOTLPExporter(config *configuration.GatewayConfig) *otlpExporter {
+	e := &otlpExporter{
+		endpoint:       config.TracingOTLPEndpoint,
+		client:         &http.Client{Timeout: otlpTimeout},
+		log:            config.Log,
+		spans:          make(chan *Span, otlpQueueSize),
+		stopped:        make(chan struct{}),
+		done:           make(chan struct{}),
+		counterDropped: stats.Registry.Counter("gnmigateway.tracing.spans_dropped", stats.NoTags),
+		counterErrors:  stats.Registry.Counter("gnmigateway.tracing.export_errors", stats.NoTags),
+		counterSent:    stats.Registry.Counter("gnmigateway.tracing.spans_sent", stats.NoTags),
+	}
+	go e.run()
+	return e
+}
+
+func (e *otlpExporter) export(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		e.counterDropped.Increment()
+	}
+}
+
+// stop exports the buffered spans and stops the exporter.
+func (e *otlpExporter) stop() {
+	close(e.stopped)
+	<-e.done
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, otlpBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.counterErrors.Increment()
+			e.log.Warn().Msgf("Unable to export %d spans: %v", len(batch), err)
+		} else {
+			e.counterSent.Add(int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) == otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stopped:
+			for {
+				select {
+				case s := <-e.spans:
+					batch = append(batch, s)
+					if len(batch) == otlpBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *otlpExporter) send(batch []*Span) error {
+	return otlp.Post(e.client, e.endpoint, encodeSpans(batch))
+}
+
+// OTLP JSON encoding of an ExportTraceServiceRequest.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlp.Resource    `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlp.Scope `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlp.KeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func encodeSpans(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		s.mutex.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: otlp.UnixNano(s.start),
+			EndTimeUnixNano:   otlp.UnixNano(s.end),
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for key, value := range s.attributes {
+			span.Attributes = append(span.Attributes, otlp.Attribute(key, value))
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+		}
+		s.mutex.Unlock()
+		spans = append(spans, span)
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlp.Resource{Attributes: []otlp.KeyValue{otlp.Attribute("service.name", serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlp.DefaultScope, Spans: spans}},
+	}}}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides spans for tracing telemetry through the gateway:
+// target connections and subscriptions, the handling of each update received
+// from a target, and the responses sent to gNMI clients.
+//
+// Tracing is disabled until Enable is called, and Start returns a nil *Span
+// for spans that aren't sampled. All Span methods are safe to call on a nil
+// *Span so instrumented code doesn't need to check whether tracing is enabled.
+// Finished spans are exported with OTLP over HTTP (JSON encoding) to any
+// OpenTelemetry collector.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+// spanExporter receives finished spans.
+type spanExporter interface {
+	export(s *Span)
+	stop()
+}
+
+// Tracer creates spans. Root spans are sampled with the sample ratio and
+// child spans are sampled if their parent is.
+type Tracer struct {
+	exporter    spanExporter
+	sampleRatio float64
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+var global = struct {
+	sync.RWMutex
+	tracer *Tracer
+}{}
+
+// Enable starts exporting spans to the TracingOTLPEndpoint in the
+// configuration.
+func Enable(config *configuration.GatewayConfig) error {
+	if config.TracingOTLPEndpoint == "" {
+		return errors.New("TracingOTLPEndpoint is required to enable tracing")
+	}
+	if config.TracingSampleRatio < 0 || config.TracingSampleRatio > 1 {
+		return errors.New("TracingSampleRatio must be between 0 and 1")
+	}
+	setTracer(newTracer(newOTLPExporter(config), config.TracingSampleRatio))
+	config.Log.Info().Msgf("Tracing is enabled: exporting %g of traces to %s.", config.TracingSampleRatio, config.TracingOTLPEndpoint)
+	return nil
+}
+
+// Disable stops tracing and exports any buffered spans.
+func Disable() {
+	global.Lock()
+	tracer := global.tracer
+	global.tracer = nil
+	global.Unlock()
+	if tracer != nil {
+		tracer.exporter.stop()
+	}
+}
+
+func newTracer(exporter spanExporter, sampleRatio float64) *Tracer {
+	return &Tracer{
+		exporter:    exporter,
+		sampleRatio: sampleRatio,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func setTracer(t *Tracer) {
+	global.Lock()
+	previous := global.tracer
+	global.tracer = t
+	global.Unlock()
+	if previous != nil {
+		previous.exporter.stop()
+	}
+}
+
+// Span is a timed operation in a trace.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	end      time.Time
+
+	mutex      sync.Mutex
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+type spanKey struct{}
+
+// Start starts a span as a child of the span in ctx, if any. The returned
+// context contains the new span. A nil *Span is returned if tracing is
+// disabled or the span isn't sampled.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	global.RLock()
+	tracer := global.tracer
+	global.RUnlock()
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	parent, _ := ctx.Value(spanKey{}).(*Span)
+	s := &Span{
+		tracer: tracer,
+		name:   name,
+		start:  time.Now(),
+	}
+	tracer.mutex.Lock()
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		if tracer.rand.Float64() >= tracer.sampleRatio {
+			tracer.mutex.Unlock()
+			return ctx, nil
+		}
+		tracer.rand.Read(s.traceID[:])
+	}
+	tracer.rand.Read(s.spanID[:])
+	tracer.mutex.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute sets an attribute on the span. Values should be strings,
+// bools, integers, floats, or time.Durations.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+	s.mutex.Unlock()
+}
+
+// SetError marks the span as failed with err. nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.err = err
+	s.mutex.Unlock()
+}
+
+// End finishes the span and exports it. Only the first call to End has an
+// effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+	s.tracer.exporter.export(s)
+}
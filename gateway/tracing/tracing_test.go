@@ -0,0 +1,165 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/otlp"
+)
+
+type testExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+func (e *testExporter) export(s *Span) {
+	e.mutex.Lock()
+	e.spans = append(e.spans, s)
+	e.mutex.Unlock()
+}
+
+func (e *testExporter) stop() {}
+
+func TestStart_Disabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "test")
+	assert.Nil(t, span)
+	assert.Equal(t, context.Background(), ctx)
+	// nil spans are no-ops
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("error"))
+	span.End()
+}
+
+func TestStart_Sampling(t *testing.T) {
+	assertion := assert.New(t)
+	defer setTracer(nil)
+
+	exporter := new(testExporter)
+	setTracer(newTracer(exporter, 1))
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+	parent.End()
+
+	if assertion.Len(exporter.spans, 2) {
+		assertion.Equal("child", exporter.spans[0].name)
+		assertion.Equal(parent.traceID, child.traceID)
+		assertion.Equal(parent.spanID, child.parentID)
+		assertion.NotEqual(parent.spanID, child.spanID)
+	}
+
+	setTracer(newTracer(exporter, 0))
+	_, span := Start(context.Background(), "unsampled")
+	assertion.Nil(span)
+	_, child = Start(ctx, "child of sampled")
+	assertion.NotNil(child)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	assertion := assert.New(t)
+
+	received := make(chan *otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req := new(otlpRequest)
+		assertion.NoError(json.Unmarshal(body, req))
+		received <- req
+	}))
+	defer collector.Close()
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.TracingOTLPEndpoint = collector.URL
+	config.TracingSampleRatio = 1
+	assertion.NoError(Enable(config))
+	_, span := Start(context.Background(), "target.update")
+	span.SetAttribute("gnmi.target", "dev1")
+	span.SetAttribute("gnmi.updates", 3)
+	span.SetAttribute("gnmi.latency", time.Second)
+	span.SetError(errors.New("failed"))
+	span.End()
+	Disable()
+
+	select {
+	case req := <-received:
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		if assertion.Len(spans, 1) {
+			assertion.Equal("target.update", spans[0].Name)
+			assertion.Len(spans[0].TraceID, 32)
+			assertion.Len(spans[0].SpanID, 16)
+			assertion.Empty(spans[0].ParentSpanID)
+			assertion.Equal(otlpStatusError, spans[0].Status.Code)
+			attributes := make(map[string]otlp.AnyValue)
+			for _, attribute := range spans[0].Attributes {
+				attributes[attribute.Key] = attribute.Value
+			}
+			assertion.Equal("dev1", *attributes["gnmi.target"].StringValue)
+			assertion.Equal("3", *attributes["gnmi.updates"].IntValue)
+			assertion.Equal("1000000000", *attributes["gnmi.latency"].IntValue)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the export")
+	}
+}
+
+func TestEnable_Invalid(t *testing.T) {
+	config := configuration.NewDefaultGatewayConfig()
+	assert.Error(t, Enable(config))
+	config.TracingOTLPEndpoint = "http://localhost:4318/v1/traces"
+	config.TracingSampleRatio = 2
+	assert.Error(t, Enable(config))
+}
+
+func TestNotificationContext(t *testing.T) {
+	assertion := assert.New(t)
+	defer setTracer(nil)
+
+	exporter := new(testExporter)
+	setTracer(newTracer(exporter, 1))
+
+	received := &gnmipb.Notification{
+		Prefix: &gnmipb.Path{Target: "dev1"},
+		Update: []*gnmipb.Update{{Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "a"}}}}},
+	}
+	ctx, parent := Start(context.Background(), "target.update")
+	LinkNotification(ctx, received)
+	parent.End()
+
+	// The cache stores a new notification for each leaf with the same updates.
+	leaf := &gnmipb.Notification{Prefix: received.Prefix, Update: received.Update}
+	_, child := Start(NotificationContext(leaf), "server.send")
+	if assertion.NotNil(child) {
+		assertion.Equal(parent.traceID, child.traceID)
+		assertion.Equal(parent.spanID, child.parentID)
+	}
+
+	unlinked := &gnmipb.Notification{Update: []*gnmipb.Update{{}}}
+	assertion.Equal(context.Background(), NotificationContext(unlinked))
+}
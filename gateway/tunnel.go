@@ -0,0 +1,137 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	tpb "github.com/openconfig/grpctunnel/proto/tunnel"
+	"github.com/openconfig/grpctunnel/tunnel"
+	"google.golang.org/grpc"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+)
+
+// tunnelTargetType is the target type that targets register with the tunnel
+// server to accept gNMI connections through the tunnel.
+var tunnelTargetType = tpb.TargetType_GNMI_GNOI.String()
+
+var _ connections.TunnelDialer = new(tunnelServer)
+
+// tunnelServer is a gRPC tunnel server (github.com/openconfig/grpctunnel)
+// that targets behind NAT register with. Targets with the UseTunnel meta
+// field set are dialed through the tunnel.
+type tunnelServer struct {
+	config *configuration.GatewayConfig
+	server *tunnel.Server
+
+	mutex   sync.Mutex
+	targets map[string]tunnel.Target
+}
+
+func newTunnelServer(config *configuration.GatewayConfig) (*tunnelServer, error) {
+	ts := &tunnelServer{
+		config:  config,
+		targets: make(map[string]tunnel.Target),
+	}
+	server, err := tunnel.NewServer(tunnel.ServerConfig{
+		AddTargetHandler:    ts.addTarget,
+		DeleteTargetHandler: ts.deleteTarget,
+		RegisterHandler:     ts.register,
+		Handler:             ts.handle,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create tunnel server: %v", err)
+	}
+	ts.server = server
+	return ts, nil
+}
+
+// addTarget is called when a target registers with the tunnel server.
+func (ts *tunnelServer) addTarget(target tunnel.Target) error {
+	if target.Type != tunnelTargetType {
+		ts.config.Log.Info().Msgf("Tunnel: ignoring target %s with unsupported type %s", target.ID, target.Type)
+		return nil
+	}
+	ts.mutex.Lock()
+	ts.targets[target.ID] = target
+	ts.mutex.Unlock()
+	ts.config.Log.Info().Msgf("Tunnel: target %s registered", target.ID)
+	return nil
+}
+
+// deleteTarget is called when a target is removed from the tunnel server.
+func (ts *tunnelServer) deleteTarget(target tunnel.Target) error {
+	ts.mutex.Lock()
+	delete(ts.targets, target.ID)
+	ts.mutex.Unlock()
+	ts.config.Log.Info().Msgf("Tunnel: target %s unregistered", target.ID)
+	return nil
+}
+
+func (ts *tunnelServer) register(session tunnel.ServerSession) error {
+	ts.config.Log.Debug().Msgf("Tunnel: session for %s from %s", session.Target.ID, session.Addr)
+	return nil
+}
+
+// handle is called for tunnels opened by targets. The gateway doesn't serve
+// any services through the tunnel.
+func (ts *tunnelServer) handle(session tunnel.ServerSession, rwc io.ReadWriteCloser) error {
+	_ = rwc.Close()
+	return errors.New("the gateway doesn't accept tunnels from targets")
+}
+
+// DialTunnel opens a tunnel to the registered target with the ID.
+func (ts *tunnelServer) DialTunnel(ctx context.Context, id string) (net.Conn, error) {
+	ts.mutex.Lock()
+	target, exists := ts.targets[id]
+	ts.mutex.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("target %s isn't registered with the tunnel server", id)
+	}
+	return tunnel.ServerConn(ctx, ts.server, &target)
+}
+
+// startTunnelServer serves the tunnel server on the TunnelListener.
+// startTunnelServer blocks until the gateway is stopped.
+func (g *Gateway) startTunnelServer(ts *tunnelServer) error {
+	l, err := g.newServerListener(g.config.TunnelListener)
+	if err != nil {
+		return fmt.Errorf("invalid tunnel listener: %v", err)
+	}
+	opts := g.serverOptions()
+	if l.creds != nil {
+		opts = append(opts, grpc.Creds(l.creds))
+	}
+	srv := grpc.NewServer(opts...)
+	g.gnmiServerLock.Lock()
+	g.grpcServers = append(g.grpcServers, srv)
+	g.gnmiServerLock.Unlock()
+	tpb.RegisterTunnelServer(srv, ts.server)
+
+	lis, err := l.listen()
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", l.address, err)
+	}
+	g.config.Log.Info().Msgf("Tunnel server listening on %s (%s).", l.address, l.description)
+	return srv.Serve(lis)
+}
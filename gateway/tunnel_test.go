@@ -0,0 +1,49 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openconfig/grpctunnel/tunnel"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+)
+
+func TestTunnelServer_targets(t *testing.T) {
+	assertion := assert.New(t)
+
+	config := configuration.NewDefaultGatewayConfig()
+	config.Log = zerolog.Nop()
+	ts, err := newTunnelServer(config)
+	assertion.NoError(err)
+
+	assertion.NoError(ts.addTarget(tunnel.Target{ID: "dev1", Type: tunnelTargetType}))
+	assertion.NoError(ts.addTarget(tunnel.Target{ID: "ssh1", Type: "SSH"}))
+	assertion.Contains(ts.targets, "dev1")
+	assertion.NotContains(ts.targets, "ssh1", "only gNMI targets are dialed")
+
+	_, err = ts.DialTunnel(context.Background(), "ssh1")
+	assertion.Error(err)
+
+	assertion.NoError(ts.deleteTarget(tunnel.Target{ID: "dev1", Type: tunnelTargetType}))
+	assertion.Empty(ts.targets)
+	_, err = ts.DialTunnel(context.Background(), "dev1")
+	assertion.Error(err)
+}
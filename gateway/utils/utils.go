@@ -16,10 +16,13 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
+	"github.com/google/gnxi/utils/xpath"
 	"github.com/openconfig/gnmi/proto/gnmi"
 )
 
@@ -33,9 +36,6 @@ func GNMINotificationPrettyString(notification *gnmi.Notification) string {
 	pretty := "{ "
 	pretty += fmt.Sprintf("timestamp:%d ", notification.Timestamp)
 	pretty += fmt.Sprintf("prefix:%s ", PathToXPath(notification.Prefix))
-	if notification.Alias != "" {
-		pretty += fmt.Sprintf("alias:%v ", notification.Alias)
-	}
 	if len(notification.Update) > 0 {
 		pretty += "update:[ "
 		for _, u := range notification.Update {
@@ -108,6 +108,126 @@ func PathToXPath(path *gnmi.Path) string {
 	return xPath
 }
 
+// wildcardElem stands in for "*" elem names while an XPath is parsed because
+// xpath.ToGNMIPath only accepts YANG identifiers as elem names.
+const wildcardElem = "_gnmi_gateway_wildcard_"
+
+// WildcardXPathToGNMIPath converts an XPath to a gnmi.Path like
+// xpath.ToGNMIPath but also accepts "*" as an elem name, e.g.
+// "/system/*/state".
+func WildcardXPathToGNMIPath(p string) (*gnmi.Path, error) {
+	var replaced strings.Builder
+	inKey := false
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case inKey && c == '\\' && i+1 < len(p):
+			replaced.WriteByte(c)
+			i++
+			c = p[i]
+		case c == '[':
+			inKey = true
+		case c == ']':
+			inKey = false
+		case !inKey && c == '*' && (i == 0 || p[i-1] == '/') && (i+1 == len(p) || p[i+1] == '/' || p[i+1] == '['):
+			replaced.WriteString(wildcardElem)
+			continue
+		}
+		replaced.WriteByte(c)
+	}
+	parsed, err := xpath.ToGNMIPath(replaced.String())
+	if err != nil {
+		return nil, errors.New(strings.Replace(err.Error(), wildcardElem, "*", -1))
+	}
+	for _, elem := range parsed.Elem {
+		if elem.Name == wildcardElem {
+			elem.Name = "*"
+		}
+	}
+	return parsed, nil
+}
+
+// MatchPathPrefix returns true if the first elems of path match toMatch.
+// Names and key values in toMatch may be "*"; keys that aren't in toMatch
+// match any value.
+func MatchPathPrefix(path []*gnmi.PathElem, toMatch []*gnmi.PathElem) bool {
+	if len(path) < len(toMatch) {
+		return false
+	}
+	for i, elem := range toMatch {
+		if elem.Name != "*" && path[i].Name != elem.Name {
+			return false
+		}
+		for k, v := range elem.Key {
+			ov, exists := path[i].Key[k]
+			if !exists || (v != "*" && v != ov) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// elemsPool holds the buffers used by MatchJoinedPath.
+var elemsPool = sync.Pool{
+	New: func() interface{} {
+		elems := make([]*gnmi.PathElem, 0, 16)
+		return &elems
+	},
+}
+
+// MatchJoinedPath returns the result of match for the elems of prefix
+// followed by elems. The elems are joined in a pooled buffer to avoid an
+// allocation for each update; match must not retain the joined elems.
+func MatchJoinedPath(prefix []*gnmi.PathElem, elems []*gnmi.PathElem, match func([]*gnmi.PathElem) bool) bool {
+	if len(prefix) == 0 {
+		return match(elems)
+	}
+	buf := elemsPool.Get().(*[]*gnmi.PathElem)
+	joined := append(append((*buf)[:0], prefix...), elems...)
+	result := match(joined)
+	for i := range joined {
+		joined[i] = nil
+	}
+	*buf = joined[:0]
+	elemsPool.Put(buf)
+	return result
+}
+
+// ShallowCopyNotification returns a copy of notification that shares its
+// prefix, updates, and deletes. It is much cheaper than proto.Clone when only
+// the top-level fields of the copy are replaced; the shared fields must not
+// be modified.
+func ShallowCopyNotification(notification *gnmi.Notification) *gnmi.Notification {
+	return &gnmi.Notification{
+		Timestamp: notification.Timestamp,
+		Prefix:    notification.Prefix,
+		Update:    notification.Update,
+		Delete:    notification.Delete,
+		Atomic:    notification.Atomic,
+	}
+}
+
+// IsTargetDelete returns true if the notification deletes all of the data for
+// the target in its prefix, as sent by the cache when a target is removed.
+func IsTargetDelete(notification *gnmi.Notification) bool {
+	prefix := notification.GetPrefix()
+	if prefix.GetTarget() == "" || len(prefix.GetElem()) > 0 || len(notification.GetUpdate()) > 0 {
+		return false
+	}
+	for _, d := range notification.GetDelete() {
+		elems := d.GetElem()
+		element := d.GetElement()
+		switch {
+		case len(elems) == 0 && len(element) == 0,
+			len(elems) == 1 && elems[0].GetName() == "*",
+			len(element) == 1 && element[0] == "*":
+			return true
+		}
+	}
+	return false
+}
+
 func GetNumberValues(tv *gnmi.TypedValue) (float64, bool) {
 	if tv != nil && tv.Value != nil {
 		switch tv.Value.(type) {
@@ -125,6 +245,8 @@ func GetNumberValues(tv *gnmi.TypedValue) (float64, bool) {
 			}
 		case *gnmi.TypedValue_FloatVal:
 			return float64(tv.GetFloatVal()), true
+		case *gnmi.TypedValue_DoubleVal:
+			return tv.GetDoubleVal(), true
 		case *gnmi.TypedValue_LeaflistVal:
 			return 0, false
 		case *gnmi.TypedValue_BytesVal:
@@ -0,0 +1,109 @@
+// Copyright 2020 Netflix Inc
+// Author: Colin McIntosh (colin@netflix.com)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openconfig/gnmi-gateway/gateway/configuration"
+	"github.com/openconfig/gnmi-gateway/gateway/connections"
+	"github.com/openconfig/gnmi-gateway/gateway/exporters"
+	fileexporter "github.com/openconfig/gnmi-gateway/gateway/exporters/file"
+	"github.com/openconfig/gnmi-gateway/gateway/loaders"
+	"github.com/openconfig/gnmi-gateway/gateway/maintenance"
+	"github.com/openconfig/gnmi-gateway/gateway/rates"
+	"github.com/openconfig/gnmi-gateway/gateway/secrets"
+	"github.com/openconfig/gnmi-gateway/gateway/server"
+)
+
+// ValidateConfig checks config with configuration.GatewayConfig.Validate
+// and additionally checks the values that are interpreted by the gateway's
+// sub-packages, such as the enabled exporters and target loaders. Every
+// problem that is found is returned in a *configuration.ValidationError.
+func ValidateConfig(config *configuration.GatewayConfig) error {
+	var problems []string
+	if err := config.Validate(); err != nil {
+		validationErr, ok := err.(*configuration.ValidationError)
+		if !ok {
+			return err
+		}
+		problems = append(problems, validationErr.Problems...)
+	}
+	check := func(key string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	check("redactions", connections.ValidateRedactions(config.Redactions))
+	check("schema_validation", connections.ValidateSchemaValidation(config.SchemaValidation))
+	check("server_idle_heartbeat_message", server.ValidateIdleHeartbeatMessage(config.ServerIdleHeartbeatMessage))
+	for i, listener := range config.ServerListeners {
+		check(fmt.Sprintf("server_listeners[%d].idle_heartbeat_message", i), server.ValidateIdleHeartbeatMessage(listener.IdleHeartbeatMessage))
+	}
+	check("server_slow_consumer_policy", server.ValidateSlowConsumerPolicy(config.ServerSlowConsumerPolicy))
+	check("synthetic_deletes", connections.ValidateSyntheticDeletes(config.SyntheticDeletes))
+	check("target_compression", connections.ValidateCompression(config.TargetCompression))
+	check("timestamp_policy", connections.ValidateTimestampPolicy(config.TimestampPolicy))
+	_, err := rates.New(config.CounterRates, false)
+	check("counter_rates", err)
+	_, err = maintenance.New(config.MaintenanceWindows)
+	check("maintenance_windows", err)
+	_, err = secrets.Providers(config)
+	check("secrets", err)
+	check("inventory", exporters.ValidateLabeler(config))
+	check("policy", server.ValidatePolicyDecider(config))
+
+	if config.Exporters != nil {
+		for i, name := range config.Exporters.Enabled {
+			if _, exists := exporters.Registry[name]; !exists {
+				check(fmt.Sprintf("exporters.enabled[%d]", i), fmt.Errorf("no registered exporter: '%s'", name))
+			}
+		}
+		check("exporters.file_format", fileexporter.ValidateFormat(config.Exporters.FileFormat))
+		var exporterProblems []string
+		for name, aggregation := range config.Exporters.Aggregations {
+			if _, err := exporters.NewAggregator(aggregation); err != nil {
+				exporterProblems = append(exporterProblems, fmt.Sprintf("exporters.aggregations.%s: %v", name, err))
+			}
+		}
+		for name, filter := range config.Exporters.Filters {
+			if _, err := exporters.NewFilter(filter, nil); err != nil {
+				exporterProblems = append(exporterProblems, fmt.Sprintf("exporters.filters.%s: %v", name, err))
+			}
+		}
+		for name, barrier := range config.Exporters.SyncBarriers {
+			if _, err := exporters.NewSyncBarrier(barrier, nil); err != nil {
+				exporterProblems = append(exporterProblems, fmt.Sprintf("exporters.sync_barriers.%s: %v", name, err))
+			}
+		}
+		sort.Strings(exporterProblems)
+		problems = append(problems, exporterProblems...)
+	}
+	if config.TargetLoaders != nil {
+		for i, name := range config.TargetLoaders.Enabled {
+			if _, exists := loaders.Registry[name]; !exists {
+				check(fmt.Sprintf("target_loaders.enabled[%d]", i), fmt.Errorf("no registered target loader: '%s'", name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &configuration.ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -8,22 +8,26 @@ require (
 	github.com/frankban/quicktest v1.11.1 // indirect
 	github.com/go-zookeeper/zk v1.0.2
 	github.com/golang/mock v1.4.3
-	github.com/golang/protobuf v1.4.2
+	github.com/golang/protobuf v1.5.2
 	github.com/google/gnxi v0.0.0-20200831120455-017df4756f78
-	github.com/google/go-cmp v0.5.2
+	github.com/google/go-cmp v0.5.7
 	github.com/influxdata/influxdb-client-go/v2 v2.2.3
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/klauspost/compress v1.11.7
 	github.com/netbox-community/go-netbox v0.0.0-20201002085217-91e5d561efe4
-	github.com/openconfig/gnmi v0.0.0-20200617225440-d2b4e6a45802
+	github.com/open-policy-agent/opa v0.24.0
+	github.com/openconfig/gnmi v0.10.0
 	github.com/openconfig/goyang v0.0.0-20200623182805-6be32aef2bcd
+	github.com/openconfig/grpctunnel v0.0.0-20220819142823-6f5422b8ca70
 	github.com/prometheus/client_golang v1.4.1
 	github.com/rs/zerolog v1.17.2
 	github.com/segmentio/kafka-go v0.4.6
 	github.com/stretchr/testify v1.6.1
-	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
-	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
-	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	google.golang.org/grpc v1.37.0
-	google.golang.org/protobuf v1.25.1-0.20200805231151-a709e31e5d12 // indirect
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/net v0.0.0-20210805182204-aaa1db679c0d
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.28.0
 	gopkg.in/yaml.v2 v2.3.0
 )